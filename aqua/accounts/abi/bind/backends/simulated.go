@@ -50,8 +50,8 @@ var errGasEstimationFailed = errors.New("gas required exceeds allowance or alway
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
 // the background. Its main purpose is to allow easily testing contract bindings.
 type SimulatedBackend struct {
-	database   aquadb.Database  // In memory database to store our testing data
-	blockchain *core.BlockChain // AquaChain blockchain to handle the consensus
+	database   *aquadb.MemDatabase // In memory database to store our testing data
+	blockchain *core.BlockChain    // AquaChain blockchain to handle the consensus
 
 	mu           sync.Mutex
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
@@ -68,7 +68,8 @@ func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
 	database := aquadb.NewMemDatabase()
 	genesis := core.Genesis{Config: params.AllAquahashProtocolChanges, Alloc: alloc}
 	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, aquahash.NewFaker(), vm.Config{})
+	// Keep all state in the database, so it is covered by snapshots
+	blockchain, _ := core.NewBlockChain(database, &core.CacheConfig{Disabled: true}, genesis.Config, aquahash.NewFaker(), vm.Config{})
 
 	backend := &SimulatedBackend{
 		database:   database,
@@ -100,6 +101,32 @@ func (b *SimulatedBackend) Rollback() {
 	b.rollback()
 }
 
+// Snapshot marks the current state of the simulated blockchain, returning an
+// identifier to revert to it later. Pending transactions are not included.
+func (b *SimulatedBackend) Snapshot() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.database.Snapshot()
+}
+
+// Revert rolls the simulated blockchain back to the given snapshot, dropping
+// all blocks committed since and any pending transactions. The snapshot stays
+// valid, so every test case can start out from the same chain.
+func (b *SimulatedBackend) Revert(id int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.database.Restore(id); err != nil {
+		return err
+	}
+	if err := b.blockchain.ReloadHead(); err != nil {
+		return err
+	}
+	b.rollback()
+	return nil
+}
+
 func (b *SimulatedBackend) rollback() {
 	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), aquahash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
 	statedb, _ := b.blockchain.State()
@@ -465,6 +492,9 @@ func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEve
 func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return fb.bc.SubscribeLogsEvent(ch)
 }
+func (fb *filterBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return fb.bc.SubscribeReorgEvent(ch)
+}
 
 func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
@@ -0,0 +1,65 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(1000000000000000000)
+)
+
+// Tests that reverting to a snapshot drops the blocks committed since.
+func TestSimulatedRevert(t *testing.T) {
+	sim := NewSimulatedBackend(core.GenesisAlloc{testAddr: {Balance: testBalance}})
+	snap := sim.Snapshot()
+
+	recipient := common.HexToAddress("0xdeadbeef")
+	for i := 0; i < 2; i++ {
+		tx, _ := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(1000), 21000, big.NewInt(1), nil), types.HomesteadSigner{}, testKey)
+		if err := sim.SendTransaction(context.Background(), tx); err != nil {
+			t.Fatalf("run %d: failed to send transaction: %v", i, err)
+		}
+		sim.Commit()
+
+		if balance, _ := sim.BalanceAt(context.Background(), recipient, nil); balance.Cmp(big.NewInt(1000)) != 0 {
+			t.Fatalf("run %d: recipient balance mismatch: have %v, want 1000", i, balance)
+		}
+		if err := sim.Revert(snap); err != nil {
+			t.Fatalf("run %d: failed to revert: %v", i, err)
+		}
+		if number := sim.blockchain.CurrentBlock().NumberU64(); number != 0 {
+			t.Fatalf("run %d: head not reverted: have %d, want 0", i, number)
+		}
+		if balance, _ := sim.BalanceAt(context.Background(), recipient, nil); balance.Sign() != 0 {
+			t.Fatalf("run %d: recipient balance not reverted: %v", i, balance)
+		}
+		if balance, _ := sim.BalanceAt(context.Background(), testAddr, nil); balance.Cmp(testBalance) != 0 {
+			t.Fatalf("run %d: sender balance not reverted: have %v, want %v", i, balance, testBalance)
+		}
+	}
+}
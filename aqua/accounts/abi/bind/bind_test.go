@@ -18,6 +18,7 @@ package bind
 
 import (
 	"fmt"
+	"go/build"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -756,6 +757,16 @@ func TestBindings(t *testing.T) {
 	if !strings.Contains(string(linkTestDeps), "aquachain") {
 		t.Skip("symlinked environment doesn't support bind (https://github.com/golang/go/issues/14845)")
 	}
+	// Skip the test if the aquachain sources are not in GOPATH, the workspace is built without modules
+	var ingopath bool
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		if common.FileExist(filepath.Join(gopath, "src", "gitlab.com", "aquachain", "aquachain")) {
+			ingopath = true
+		}
+	}
+	if !ingopath {
+		t.Skip("aquachain sources not found in GOPATH")
+	}
 	// Create a temporary workspace for the test suite
 	ws, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -790,6 +801,7 @@ func TestBindings(t *testing.T) {
 	// Test the entire package and report any failures
 	cmd := exec.Command(gocmd, "test", "-v", "-count", "1")
 	cmd.Dir = pkg
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
 	if out, err := cmd.CombinedOutput(); err != nil {
 		t.Fatalf("failed to run binding test: %v\n%s", err, out)
 	}
@@ -19,16 +19,20 @@ package aqua
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/hexutil"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
 	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/state"
 	"gitlab.com/aquachain/aquachain/core/types"
@@ -47,7 +51,7 @@ type PublicTestingAPI struct {
 
 // NewPublicAquaChainAPI creates a new AquaChain protocol API for full nodes.
 func NewPublicTestingAPI(cfg *params.ChainConfig, e *AquaChain) *PublicTestingAPI {
-	agent := miner.NewRemoteAgent(e.BlockChain(), e.Engine())
+	agent := e.NewRemoteAgent()
 	e.Miner().Register(agent)
 	return &PublicTestingAPI{cfg, agent, e}
 }
@@ -78,6 +82,50 @@ func (api *PublicAquaChainAPI) Hashrate() hexutil.Uint64 {
 	return hexutil.Uint64(api.e.Miner().HashRate())
 }
 
+// ChainStats returns the uncle rate of the recent blocks, the reorgs seen since
+// startup and the propagation delays of the recent blocks, to monitor the
+// health of the network.
+func (api *PublicAquaChainAPI) ChainStats() core.ChainStats {
+	return api.e.blockchain.ChainStats()
+}
+
+// GetLocalTxStatus returns the lifecycle status (submitted, pending, mined,
+// dropped or replaced) of a transaction submitted through this node, or nil
+// if the transaction is not tracked.
+func (api *PublicAquaChainAPI) GetLocalTxStatus(hash common.Hash) *core.LocalTxStatus {
+	return api.e.txTracker.Status(hash)
+}
+
+// LocalTransactions creates a subscription that is triggered each time a
+// transaction submitted through this node changes its lifecycle status.
+func (api *PublicAquaChainAPI) LocalTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.LocalTxEvent, 64)
+		sub := api.e.txTracker.SubscribeLocalTxEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev.Status)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -87,7 +135,7 @@ type PublicMinerAPI struct {
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
 func NewPublicMinerAPI(e *AquaChain) *PublicMinerAPI {
-	agent := miner.NewRemoteAgent(e.BlockChain(), e.Engine())
+	agent := e.NewRemoteAgent()
 	e.Miner().Register(agent)
 
 	return &PublicMinerAPI{e, agent}
@@ -152,6 +200,47 @@ func (api *PublicMinerAPI) GetWork() ([3]string, error) {
 	return work, nil
 }
 
+// NewWork creates a subscription that is triggered with a work package, in the
+// format returned by GetWork, each time new work is available for external
+// miners, starting with the current one. This spares miners polling GetWork
+// and mining stale work in the meantime.
+func (api *PublicMinerAPI) NewWork(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if !api.e.IsMining() {
+		if err := api.e.StartMining(false); err != nil {
+			return &rpc.Subscription{}, err
+		}
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		works := make(chan [3]string, 16)
+		sub := api.agent.SubscribeNewWork(works)
+		defer sub.Unsubscribe()
+
+		// Subscribed first, so no work can be missed in between
+		if work, err := api.agent.GetWork(); err == nil {
+			notifier.Notify(rpcSub.ID, work)
+		}
+		for {
+			select {
+			case work := <-works:
+				notifier.Notify(rpcSub.ID, work)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // SubmitHashrate can be used for remote miners to submit their hash rate. This enables the node to report the combined
 // hash rate of all miners which submit work through this node. It accepts the miner hash rate and an identifier which
 // must be unique between nodes.
@@ -160,6 +249,19 @@ func (api *PublicMinerAPI) SubmitHashrate(hashrate hexutil.Uint64, id common.Has
 	return true
 }
 
+// SubmitStats counts the solutions remote miners submitted to this node: accepted
+// ones, stale ones still accepted within the stale window, expired ones and ones
+// failing verification.
+func (api *PublicMinerAPI) SubmitStats() map[string]hexutil.Uint64 {
+	stats := api.agent.SubmitStats()
+	return map[string]hexutil.Uint64{
+		"accepted": hexutil.Uint64(stats.Accepted),
+		"stale":    hexutil.Uint64(stats.Stale),
+		"expired":  hexutil.Uint64(stats.Expired),
+		"invalid":  hexutil.Uint64(stats.Invalid),
+	}
+}
+
 // PrivateMinerAPI provides private RPC methods to control the miner.
 // These methods can be abused by external users and must be considered insecure for use by untrusted users.
 type PrivateMinerAPI struct {
@@ -196,7 +298,7 @@ func (api *PrivateMinerAPI) Start(threads *int) error {
 		price := api.e.gasPrice
 		api.e.lock.RUnlock()
 
-		api.e.txPool.SetGasPrice(price)
+		api.e.SetGasPrice(price)
 		return api.e.StartMining(true)
 	}
 	return nil
@@ -214,6 +316,73 @@ func (api *PrivateMinerAPI) Stop() bool {
 	return true
 }
 
+// errNotCPUMiner is returned when tuning the mining threads of an engine
+// without any.
+var errNotCPUMiner = errors.New("consensus engine has no CPU miner")
+
+// SetThreads updates the number of CPU mining threads without starting or
+// stopping the miner. Zero uses all CPUs, a negative count idles the miner.
+func (api *PrivateMinerAPI) SetThreads(threads int) (bool, error) {
+	type threaded interface {
+		SetThreads(threads int)
+	}
+	th, ok := api.e.engine.(threaded)
+	if !ok {
+		return false, errNotCPUMiner
+	}
+	log.Info("Updated mining threads", "threads", threads)
+	th.SetThreads(threads)
+	return true, nil
+}
+
+// SetThrottle limits the CPU mining threads to hashing the given percentage of
+// the time, leaving room for serving RPC on the same machine. Zero or 100
+// lifts the throttle.
+func (api *PrivateMinerAPI) SetThrottle(percent int) (bool, error) {
+	if percent < 0 || percent > 100 {
+		return false, fmt.Errorf("invalid throttle %d%%, want 0-100", percent)
+	}
+	pow, ok := api.e.engine.(*aquahash.Aquahash)
+	if !ok {
+		return false, errNotCPUMiner
+	}
+	log.Info("Updated mining throttle", "percent", percent)
+	pow.SetThrottle(percent)
+	return true, nil
+}
+
+// SetNice sets the scheduling priority (nice level) of the CPU mining threads.
+// Zero leaves their priority unchanged; negative levels need privileges.
+func (api *PrivateMinerAPI) SetNice(nice int) (bool, error) {
+	if nice < -20 || nice > 19 {
+		return false, fmt.Errorf("invalid nice level %d, want -20-19", nice)
+	}
+	pow, ok := api.e.engine.(*aquahash.Aquahash)
+	if !ok {
+		return false, errNotCPUMiner
+	}
+	log.Info("Updated mining thread priority", "nice", nice)
+	pow.SetNice(nice)
+	return true, nil
+}
+
+// SetAffinity pins the CPU mining threads to the given CPUs, assigned round
+// robin. An empty list lets them run on any CPU.
+func (api *PrivateMinerAPI) SetAffinity(cpus []int) (bool, error) {
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= runtime.NumCPU() {
+			return false, fmt.Errorf("invalid cpu %d, have %d", cpu, runtime.NumCPU())
+		}
+	}
+	pow, ok := api.e.engine.(*aquahash.Aquahash)
+	if !ok {
+		return false, errNotCPUMiner
+	}
+	log.Info("Updated mining thread affinity", "cpus", cpus)
+	pow.SetAffinity(cpus)
+	return true, nil
+}
+
 // SetExtra sets the extra data string that is included when this miner mines a block.
 func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	if err := api.e.Miner().SetExtra([]byte(extra)); err != nil {
@@ -224,11 +393,7 @@ func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 
 // SetGasPrice sets the minimum accepted gas price for the miner.
 func (api *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
-	api.e.lock.Lock()
-	api.e.gasPrice = (*big.Int)(&gasPrice)
-	api.e.lock.Unlock()
-
-	api.e.txPool.SetGasPrice((*big.Int)(&gasPrice))
+	api.e.SetGasPrice((*big.Int)(&gasPrice))
 	return true
 }
 
@@ -243,6 +408,106 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(api.e.miner.HashRate())
 }
 
+// TxPoolPolicy is the tunable subset of the transaction pool configuration. Any
+// field left nil in an admin_setTxPoolConfig request keeps its current value.
+type TxPoolPolicy struct {
+	PriceBump    *hexutil.Uint64 `json:"priceBump"`
+	AccountSlots *hexutil.Uint64 `json:"accountSlots"`
+	GlobalSlots  *hexutil.Uint64 `json:"globalSlots"`
+	AccountQueue *hexutil.Uint64 `json:"accountQueue"`
+	GlobalQueue  *hexutil.Uint64 `json:"globalQueue"`
+	Lifetime     *hexutil.Uint64 `json:"lifetime"` // in seconds
+	Eviction     *string         `json:"eviction"` // accounts or transactions
+	SenderLimit  *hexutil.Uint64 `json:"senderLimit"`
+	OriginLimit  *hexutil.Uint64 `json:"originLimit"`
+	GasPrice     *hexutil.Big    `json:"gasPrice"` // minimum accepted gas price
+}
+
+// PrivateTxPoolAPI provides private RPC methods to tune the transaction pool
+// policy of a running node. They're served in the admin namespace, as the public
+// txpool one may be exposed to remote callers.
+type PrivateTxPoolAPI struct {
+	e *AquaChain
+}
+
+// NewPrivateTxPoolAPI creates a new RPC service which controls the transaction
+// pool of this node.
+func NewPrivateTxPoolAPI(e *AquaChain) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{e: e}
+}
+
+// TxPoolConfig returns the currently active transaction pool policy.
+func (api *PrivateTxPoolAPI) TxPoolConfig() TxPoolPolicy {
+	config := api.e.TxPool().Config()
+	policy := func(v uint64) *hexutil.Uint64 { return (*hexutil.Uint64)(&v) }
+
+	return TxPoolPolicy{
+		PriceBump:    policy(config.PriceBump),
+		AccountSlots: policy(config.AccountSlots),
+		GlobalSlots:  policy(config.GlobalSlots),
+		AccountQueue: policy(config.AccountQueue),
+		GlobalQueue:  policy(config.GlobalQueue),
+		Lifetime:     policy(uint64(config.Lifetime / time.Second)),
+		Eviction:     &config.Eviction,
+		SenderLimit:  policy(config.SenderLimit),
+		OriginLimit:  policy(config.OriginLimit),
+		GasPrice:     (*hexutil.Big)(api.e.TxPool().GasPrice()),
+	}
+}
+
+// SetTxPoolConfig updates the transaction pool policy, returning the resulting
+// one.
+func (api *PrivateTxPoolAPI) SetTxPoolConfig(args TxPoolPolicy) (TxPoolPolicy, error) {
+	config := api.e.TxPool().Config()
+	if args.PriceBump != nil {
+		if *args.PriceBump == 0 {
+			return TxPoolPolicy{}, fmt.Errorf("price bump must be positive")
+		}
+		config.PriceBump = uint64(*args.PriceBump)
+	}
+	if args.AccountSlots != nil {
+		config.AccountSlots = uint64(*args.AccountSlots)
+	}
+	if args.GlobalSlots != nil {
+		config.GlobalSlots = uint64(*args.GlobalSlots)
+	}
+	if args.AccountQueue != nil {
+		config.AccountQueue = uint64(*args.AccountQueue)
+	}
+	if args.GlobalQueue != nil {
+		config.GlobalQueue = uint64(*args.GlobalQueue)
+	}
+	if args.Lifetime != nil {
+		config.Lifetime = time.Duration(*args.Lifetime) * time.Second
+	}
+	if args.Eviction != nil {
+		if *args.Eviction != core.EvictAccounts && *args.Eviction != core.EvictTransactions {
+			return TxPoolPolicy{}, fmt.Errorf("unknown eviction strategy %q", *args.Eviction)
+		}
+		config.Eviction = *args.Eviction
+	}
+	if args.SenderLimit != nil {
+		config.SenderLimit = uint64(*args.SenderLimit)
+	}
+	if args.OriginLimit != nil {
+		config.OriginLimit = uint64(*args.OriginLimit)
+	}
+	if args.GasPrice != nil {
+		if args.GasPrice.ToInt().Sign() <= 0 {
+			return TxPoolPolicy{}, fmt.Errorf("gas price must be positive")
+		}
+		api.e.SetGasPrice(new(big.Int).Set(args.GasPrice.ToInt()))
+	}
+	api.e.TxPool().SetConfig(config)
+	return api.TxPoolConfig(), nil
+}
+
+// EvictSender drops all transactions of the given account from the pool,
+// returning the number of transactions removed.
+func (api *PrivateTxPoolAPI) EvictSender(addr common.Address) int {
+	return api.e.TxPool().EvictSender(addr)
+}
+
 // PrivateAdminAPI is the collection of AquaChain full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -480,6 +745,41 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// CompactDatabase starts compacting the chain database in the background. Its
+// progress is reported by CompactionProgress.
+func (api *PrivateAdminAPI) CompactDatabase() (bool, error) {
+	if api.aqua.compactor == nil {
+		return false, errCompactionUnsupported
+	}
+	if err := api.aqua.compactor.compact(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompactionProgress returns the progress of the current or last compaction of
+// the chain database.
+func (api *PrivateAdminAPI) CompactionProgress() (CompactionProgress, error) {
+	if api.aqua.compactor == nil {
+		return CompactionProgress{}, errCompactionUnsupported
+	}
+	return api.aqua.compactor.status(), nil
+}
+
+// SetCacheSize changes the number of entries kept by one of the chain caches,
+// see CacheStats for the available caches and their hit rates.
+func (api *PrivateAdminAPI) SetCacheSize(name string, size int) (bool, error) {
+	if err := api.aqua.BlockChain().SetCacheSize(name, size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CacheStats returns the size, fill and hit/miss counts of the chain caches.
+func (api *PrivateAdminAPI) CacheStats() map[string]core.CacheStats {
+	return api.aqua.BlockChain().CacheStats()
+}
+
 // PublicDebugAPI is the collection of AquaChain full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
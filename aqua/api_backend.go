@@ -18,6 +18,7 @@ package aqua
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 
 	"gitlab.com/aquachain/aquachain/aqua/accounts"
@@ -53,11 +54,14 @@ func (b *AquaApiBackend) CurrentBlock() *types.Block {
 	return b.aqua.blockchain.CurrentBlock()
 }
 
-func (b *AquaApiBackend) SetHead(number uint64) {
+func (b *AquaApiBackend) SetHead(number uint64) error {
+	if head := b.aqua.blockchain.CurrentHeader().Number.Uint64(); number > head {
+		return fmt.Errorf("block %d is above the current head %d", number, head)
+	}
 	if b.aqua.protocolManager != nil {
 		b.aqua.protocolManager.downloader.Cancel()
 	}
-	b.aqua.blockchain.SetHead(number)
+	return b.aqua.blockchain.SetHead(number)
 }
 
 func (b *AquaApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
@@ -145,6 +149,10 @@ func (b *AquaApiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent)
 	return b.aqua.BlockChain().SubscribeChainHeadEvent(ch)
 }
 
+func (b *AquaApiBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.aqua.BlockChain().SubscribeReorgEvent(ch)
+}
+
 func (b *AquaApiBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
 	return b.aqua.BlockChain().SubscribeChainSideEvent(ch)
 }
@@ -154,7 +162,19 @@ func (b *AquaApiBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscr
 }
 
 func (b *AquaApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
-	return b.aqua.txPool.AddLocal(signedTx)
+	if err := b.aqua.txPool.AddLocal(signedTx); err != nil {
+		return err
+	}
+	b.aqua.txTracker.Track(signedTx)
+	return nil
+}
+
+func (b *AquaApiBackend) SendConditionalTx(ctx context.Context, signedTx *types.Transaction, cond *core.TxConditions) error {
+	if err := b.aqua.txPool.AddConditional(signedTx, cond); err != nil {
+		return err
+	}
+	b.aqua.txTracker.Track(signedTx)
+	return nil
 }
 
 func (b *AquaApiBackend) GetPoolTransactions() (types.Transactions, error) {
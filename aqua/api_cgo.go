@@ -552,6 +552,46 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+// TraceCall executes a call on top of the state of the given block, like
+// aqua_call does, and returns the structured logs or tracer output created
+// during its execution. Nothing is committed to the chain.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args aquaapi.CallArgs, number rpc.BlockNumber, config *TraceConfig) (interface{}, error) {
+	var (
+		block   *types.Block
+		statedb *state.StateDB
+		err     error
+	)
+	switch number {
+	case rpc.PendingBlockNumber:
+		block, statedb = api.aqua.miner.Pending()
+	case rpc.LatestBlockNumber:
+		block = api.aqua.blockchain.CurrentBlock()
+	default:
+		block = api.aqua.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if statedb == nil {
+		reexec := defaultTraceReexec
+		if config != nil && config.Reexec != nil {
+			reexec = *config.Reexec
+		}
+		if statedb, err = api.computeStateDB(block, reexec); err != nil {
+			return nil, err
+		}
+	}
+	// Assemble the call message, allowing it all the gas of the block by default
+	gas := uint64(args.Gas)
+	if gas == 0 {
+		gas = block.GasLimit()
+	}
+	msg := types.NewMessage(args.From, args.To, 0, args.Value.ToInt(), gas, args.GasPrice.ToInt(), args.Data, false)
+	vmctx := core.NewEVMContext(msg, block.Header(), api.aqua.blockchain, nil)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, config)
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
@@ -570,15 +610,20 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
-			return nil, err
+		// Constuct the native or JavaScript tracer to execute with
+		traced, ok := tracers.NewNative(*config.Tracer)
+		if !ok {
+			if traced, err = tracers.New(*config.Tracer); err != nil {
+				return nil, err
+			}
 		}
+		tracer = traced
+
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			traced.Stop(errors.New("execution timeout"))
 		}()
 		defer cancel()
 
@@ -605,7 +650,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 			StructLogs:  aquaapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *tracers.Tracer:
+	case tracers.ResultTracer:
 		return tracer.GetResult()
 
 	default:
@@ -19,13 +19,25 @@
 package aqua
 
 import (
+	"context"
+	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/internal/aquaapi"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rpc"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -91,3 +103,130 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that calls can be traced on top of a block's state, reporting why a
+// contract call reverted.
+func TestTraceCall(t *testing.T) {
+	var (
+		db       = aquadb.NewMemDatabase()
+		contract = common.Address{0xc0}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				contract: {Balance: new(big.Int), Code: common.FromHex("60006000fd")}, // revert(0, 0)
+			},
+		}
+	)
+	gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, aquahash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	api := NewPrivateDebugAPI(params.TestChainConfig, &AquaChain{blockchain: chain, chainDb: db})
+	args := aquaapi.CallArgs{From: common.Address{0x01}, To: &contract, Data: hexutil.Bytes{0x01, 0x02, 0x03, 0x04}}
+
+	// The struct logger reports the failure and the executed opcodes
+	res, err := api.TraceCall(context.Background(), args, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	if logs := res.(*aquaapi.ExecutionResult); !logs.Failed || len(logs.StructLogs) != 3 {
+		t.Errorf("struct logs mismatch: failed %v, %d steps", logs.Failed, len(logs.StructLogs))
+	}
+	// The call tracer reports the reason
+	tracer := "callTracer"
+	res, err = api.TraceCall(context.Background(), args, rpc.LatestBlockNumber, &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	var call struct {
+		To    common.Address
+		Input hexutil.Bytes
+		Error string
+	}
+	if err := json.Unmarshal(res.(json.RawMessage), &call); err != nil {
+		t.Fatalf("failed to unmarshal call trace: %v", err)
+	}
+	if call.To != contract || !reflect.DeepEqual(call.Input, args.Data) || call.Error != "execution reverted" {
+		t.Errorf("call trace mismatch: %+v", call)
+	}
+}
+
+// Tests that the state diff of a block reports the balances, nonces and storage
+// slots it changed, along with the accounts it created.
+func TestStateDiff(t *testing.T) {
+	var (
+		engine   = aquahash.NewFaker()
+		db       = aquadb.NewMemDatabase()
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		fresh    = common.Address{0xf0}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender:   {Balance: big.NewInt(params.Aqua)},
+				contract: {Balance: new(big.Int), Code: common.FromHex("602a600055")}, // sstore(0, 42)
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0xcb})
+		for _, tx := range []*types.Transaction{
+			types.NewTransaction(0, fresh, big.NewInt(1000), params.TxGas, nil, nil),
+			types.NewTransaction(1, contract, new(big.Int), 100000, nil, nil),
+		} {
+			tx, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPublicAquaChainAPI(&AquaChain{blockchain: chain, chainDb: db, chainConfig: gspec.Config})
+	diff, err := api.GetStateDiff(context.Background(), rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to get state diff: %v", err)
+	}
+	if diff.Block != blocks[0].Hash() || uint64(diff.Number) != 1 {
+		t.Errorf("block mismatch: have %x #%d", diff.Block, diff.Number)
+	}
+	// The sender paid and bumped its nonce
+	if pre, post := diff.Pre[sender], diff.Post[sender]; pre == nil || post == nil || uint64(*pre.Nonce) != 0 || uint64(*post.Nonce) != 2 {
+		t.Errorf("sender diff mismatch: pre %s, post %s", dumper.Sdump(pre), dumper.Sdump(post))
+	}
+	// The transfer created the fresh account
+	if pre, post := diff.Pre[fresh], diff.Post[fresh]; pre != nil || post == nil || post.Balance.ToInt().Int64() != 1000 {
+		t.Errorf("created account diff mismatch: pre %s, post %s", dumper.Sdump(pre), dumper.Sdump(post))
+	}
+	// The contract call only changed its storage
+	pre, post := diff.Pre[contract], diff.Post[contract]
+	if pre == nil || post == nil || pre.Balance != nil || post.Nonce != nil || len(post.Storage) != 1 {
+		t.Fatalf("contract diff mismatch: pre %s, post %s", dumper.Sdump(pre), dumper.Sdump(post))
+	}
+	if have := post.Storage[common.Hash{}]; have != common.BigToHash(big.NewInt(42)) {
+		t.Errorf("storage slot mismatch: have %x, want 42", have)
+	}
+	if have := pre.Storage[common.Hash{}]; have != (common.Hash{}) {
+		t.Errorf("previous storage slot mismatch: have %x, want 0", have)
+	}
+	// The coinbase was rewarded
+	if diff.Post[common.Address{0xcb}] == nil {
+		t.Errorf("coinbase missing from diff")
+	}
+	if len(diff.Post) != 4 {
+		t.Errorf("changed account count mismatch: have %d, want 4", len(diff.Post))
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gccgo cgo !nocgo
+
+package aqua
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// StateDiff holds the accounts changed by a block. Pre holds the changed fields
+// of the accounts before the block and Post after it. Accounts missing from Pre
+// were created by the block, and those missing from Post were deleted.
+type StateDiff struct {
+	Block  common.Hash                      `json:"block"`
+	Number hexutil.Uint64                   `json:"number"`
+	Pre    map[common.Address]*AccountState `json:"pre"`
+	Post   map[common.Address]*AccountState `json:"post"`
+}
+
+// AccountState holds fields of an account, those unchanged being omitted. The
+// storage holds the changed slots only.
+type AccountState struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// GetStateDiff returns the accounts and storage slots changed by the block with
+// the given number, along with their values before and after it. The block is
+// reexecuted, which takes a while for blocks whose parent state isn't at hand.
+func (api *PublicAquaChainAPI) GetStateDiff(ctx context.Context, number rpc.BlockNumber) (*StateDiff, error) {
+	var block *types.Block
+	switch number {
+	case rpc.PendingBlockNumber:
+		return nil, fmt.Errorf("state diff of the pending block not available")
+	case rpc.LatestBlockNumber:
+		block = api.e.blockchain.CurrentBlock()
+	default:
+		block = api.e.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return api.stateDiff(ctx, block)
+}
+
+// GetStateDiffByHash returns the accounts and storage slots changed by the block
+// with the given hash, along with their values before and after it.
+func (api *PublicAquaChainAPI) GetStateDiffByHash(ctx context.Context, hash common.Hash) (*StateDiff, error) {
+	block := api.e.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block #%x not found", hash)
+	}
+	return api.stateDiff(ctx, block)
+}
+
+// stateDiff reexecutes the block on the state of its parent with a tracer
+// collecting the accounts and slots it touches, then compares them before and
+// after the block.
+func (api *PublicAquaChainAPI) stateDiff(ctx context.Context, block *types.Block) (*StateDiff, error) {
+	if block.NumberU64() == 0 {
+		return nil, fmt.Errorf("genesis block has no state diff")
+	}
+	parent := api.e.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	debug := NewPrivateDebugAPI(api.e.chainConfig, api.e)
+	statedb, err := debug.computeStateDB(parent, defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	pre := statedb.Copy()
+
+	// Rewards are paid outside of the transactions, touch the beneficiaries
+	tracer := newStateDiffTracer()
+	tracer.touch(block.Coinbase())
+	for _, uncle := range block.Uncles() {
+		tracer.touch(uncle.Coinbase)
+	}
+	deadline, cancel := context.WithTimeout(ctx, defaultTraceTimeout*time.Duration(1+len(block.Transactions())))
+	defer cancel()
+	tracer.ctx = deadline
+
+	if _, _, _, err := api.e.blockchain.Processor().Process(block, statedb, vm.Config{Debug: true, Tracer: tracer}); err != nil {
+		return nil, err
+	}
+	if err := deadline.Err(); err != nil {
+		return nil, err
+	}
+	statedb.Finalise(true)
+
+	diff := &StateDiff{
+		Block:  block.Hash(),
+		Number: hexutil.Uint64(block.NumberU64()),
+		Pre:    make(map[common.Address]*AccountState),
+		Post:   make(map[common.Address]*AccountState),
+	}
+	for addr, slots := range tracer.accounts {
+		before, after := diffAccount(pre, statedb, addr, slots)
+		if before != nil {
+			diff.Pre[addr] = before
+		}
+		if after != nil {
+			diff.Post[addr] = after
+		}
+	}
+	return diff, nil
+}
+
+// diffAccount returns the changed fields of an account and the given storage
+// slots before and after, nil if it didn't exist or nothing changed.
+func diffAccount(pre, post *state.StateDB, addr common.Address, slots map[common.Hash]struct{}) (*AccountState, *AccountState) {
+	existed, exists := pre.Exist(addr), post.Exist(addr)
+	switch {
+	case !existed && !exists:
+		return nil, nil
+	case !existed:
+		return nil, dumpAccount(post, addr, slots)
+	case !exists:
+		return dumpAccount(pre, addr, slots), nil
+	}
+	var (
+		before  = new(AccountState)
+		after   = new(AccountState)
+		changed bool
+	)
+	if b, a := pre.GetBalance(addr), post.GetBalance(addr); b.Cmp(a) != 0 {
+		before.Balance, after.Balance = (*hexutil.Big)(b), (*hexutil.Big)(a)
+		changed = true
+	}
+	if b, a := pre.GetNonce(addr), post.GetNonce(addr); b != a {
+		before.Nonce, after.Nonce = (*hexutil.Uint64)(&b), (*hexutil.Uint64)(&a)
+		changed = true
+	}
+	if b, a := pre.GetCodeHash(addr), post.GetCodeHash(addr); b != a {
+		before.Code, after.Code = pre.GetCode(addr), post.GetCode(addr)
+		changed = true
+	}
+	for slot := range slots {
+		b, a := pre.GetState(addr, slot), post.GetState(addr, slot)
+		if b == a {
+			continue
+		}
+		if before.Storage == nil {
+			before.Storage = make(map[common.Hash]common.Hash)
+			after.Storage = make(map[common.Hash]common.Hash)
+		}
+		before.Storage[slot], after.Storage[slot] = b, a
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return before, after
+}
+
+// dumpAccount returns all the fields of an account and its non-empty slots
+// among the given ones.
+func dumpAccount(statedb *state.StateDB, addr common.Address, slots map[common.Hash]struct{}) *AccountState {
+	nonce := statedb.GetNonce(addr)
+	account := &AccountState{
+		Balance: (*hexutil.Big)(statedb.GetBalance(addr)),
+		Nonce:   (*hexutil.Uint64)(&nonce),
+		Code:    statedb.GetCode(addr),
+	}
+	for slot := range slots {
+		if value := statedb.GetState(addr, slot); value != (common.Hash{}) {
+			if account.Storage == nil {
+				account.Storage = make(map[common.Hash]common.Hash)
+			}
+			account.Storage[slot] = value
+		}
+	}
+	return account
+}
+
+// stateDiffTracer is a vm.Tracer collecting the accounts and storage slots that
+// may be changed by the traced transactions. Whether they actually were is
+// found by comparing the states before and after.
+type stateDiffTracer struct {
+	ctx      context.Context
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+func newStateDiffTracer() *stateDiffTracer {
+	return &stateDiffTracer{
+		ctx:      context.Background(),
+		accounts: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// touch records an account that may have changed.
+func (t *stateDiffTracer) touch(addr common.Address) {
+	if _, ok := t.accounts[addr]; !ok {
+		t.accounts[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+func (t *stateDiffTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.touch(from)
+	t.touch(to)
+	return nil
+}
+
+func (t *stateDiffTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err := t.ctx.Err(); err != nil {
+		env.Cancel()
+		return err
+	}
+	data := stack.Data()
+	switch op {
+	case vm.SSTORE:
+		if len(data) > 0 {
+			t.touch(contract.Address())
+			t.accounts[contract.Address()][common.BigToHash(data[len(data)-1])] = struct{}{}
+		}
+	case vm.CALL, vm.CALLCODE:
+		// Value transfers change the balance of the callee
+		if len(data) > 1 {
+			t.touch(common.BigToAddress(data[len(data)-2]))
+		}
+	case vm.CREATE:
+		// The new contract is addressed by the nonce of its creator
+		t.touch(contract.Address())
+		t.touch(crypto.CreateAddress(contract.Address(), env.StateDB.GetNonce(contract.Address())))
+	case vm.SELFDESTRUCT:
+		t.touch(contract.Address())
+		if len(data) > 0 {
+			t.touch(common.BigToAddress(data[len(data)-1]))
+		}
+	}
+	return nil
+}
+
+func (t *stateDiffTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *stateDiffTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
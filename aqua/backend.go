@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/aqua/accounts"
 	"gitlab.com/aquachain/aquachain/aqua/downloader"
@@ -48,6 +49,10 @@ import (
 	"gitlab.com/aquachain/aquachain/rpc"
 )
 
+// remoteHeadInterval is the time between two head updates of a replica reading
+// its chain from another node.
+const remoteHeadInterval = time.Second
+
 // AquaChain implements the AquaChain full node service.
 type AquaChain struct {
 	config      *Config
@@ -59,11 +64,14 @@ type AquaChain struct {
 
 	// Handlers
 	txPool          *core.TxPool
+	txTracker       *core.TxTracker
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
 
 	// DB interfaces
-	chainDb aquadb.Database // Block chain database
+	chainDb   aquadb.Database // Block chain database
+	compactor *compactor      // Chain database compaction scheduler, nil if unsupported
+	diskMon   *diskMonitor    // Free disk space and database size monitor, nil if ephemeral
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -72,11 +80,15 @@ type AquaChain struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	cachePurgeFeed  event.Feed              // Notifies the RPC response caches of chain rewinds
+	cachePurgeScope event.SubscriptionScope // Ends the cache purge subscriptions on shutdown
+
 	ApiBackend *AquaApiBackend
 
-	miner    *miner.Miner
-	gasPrice *big.Int
-	aquabase common.Address
+	miner     *miner.Miner
+	gasPrice  *big.Int
+	gasOracle *gasprice.Oracle // Shared by the RPC price suggestions and the miner price floor
+	aquabase  common.Address
 
 	networkId     uint64
 	netRPCService *aquaapi.PublicNetAPI
@@ -90,6 +102,13 @@ func New(ctx *node.ServiceContext, config *Config) (*AquaChain, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	var window *maintenanceWindow
+	if config.CompactionWindow != "" {
+		var err error
+		if window, err = parseMaintenanceWindow(config.CompactionWindow); err != nil {
+			return nil, err
+		}
+	}
 	chainDb, err := CreateDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
@@ -134,7 +153,9 @@ func New(ctx *node.ServiceContext, config *Config) (*AquaChain, error) {
 	//}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, TriesInMemory: config.TriesInMemory, Snapshot: config.Snapshot,
+			BodyCacheLimit: config.BodyCache, BlockCacheLimit: config.BlockCache, ReceiptsCacheLimit: config.ReceiptCache, HeaderCacheLimit: config.HeaderCache,
+			TxLookupLimit: config.TxLookupLimit}
 	)
 	aqua.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, aqua.chainConfig, aqua.engine, vmConfig)
 	if err != nil {
@@ -148,23 +169,52 @@ func New(ctx *node.ServiceContext, config *Config) (*AquaChain, error) {
 	}
 	aqua.bloomIndexer.Start(aqua.blockchain)
 
+	if db, ok := aquadb.Unwrap(chainDb).(aquadb.Compacter); ok {
+		aqua.compactor = newCompactor(db, window)
+	} else if window != nil {
+		log.Warn("Chain database does not support compaction, ignoring maintenance window")
+	}
+	if path := ctx.ResolvePath("chaindata"); path != "" {
+		aqua.diskMon = newDiskMonitor(chainDb, path, config.MinFreeDisk*1024*1024)
+	}
+	// Replicas pick up the blocks imported by the node serving their chain
+	if _, ok := aquadb.Unwrap(chainDb).(*aquadb.RemoteDatabase); ok {
+		aqua.blockchain.FollowHead(remoteHeadInterval)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
+		if config.TxPool.JournalSecret, err = ctx.DatabaseSecret(); err != nil {
+			return nil, err
+		}
 	}
 	aqua.txPool = core.NewTxPool(config.TxPool, aqua.chainConfig, aqua.blockchain)
+	aqua.txTracker = core.NewTxTracker(aqua.txPool, aqua.blockchain, chainDb)
 
 	if aqua.protocolManager, err = NewProtocolManager(aqua.chainConfig, config.SyncMode, config.NetworkId, aqua.eventMux, aqua.txPool, aqua.engine, aqua.blockchain, chainDb); err != nil {
 		return nil, err
 	}
 	aqua.miner = miner.New(aqua, aqua.chainConfig, aqua.EventMux(), aqua.engine)
 	aqua.miner.SetExtra(makeExtraData(config.ExtraData))
+	if config.TxOrdering != "" {
+		if err := aqua.miner.SetTxOrdering(config.TxOrdering); err != nil {
+			return nil, err
+		}
+	}
+	if pow, ok := aqua.engine.(*aquahash.Aquahash); ok {
+		pow.SetThrottle(config.MinerThrottle)
+		pow.SetNice(config.MinerNice)
+		pow.SetAffinity(config.MinerAffinity)
+	}
 
 	aqua.ApiBackend = &AquaApiBackend{aqua, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.GasPrice
 	}
-	aqua.ApiBackend.gpo = gasprice.NewOracle(aqua.ApiBackend, gpoParams)
+	aqua.gasOracle = gasprice.NewOracle(aqua.ApiBackend, gpoParams)
+	aqua.gasOracle.SetMinimumPrice(aqua.txPool.GasPrice())
+	aqua.ApiBackend.gpo = aqua.gasOracle
 
 	return aqua, nil
 }
@@ -232,9 +282,42 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (aquadb.Dat
 		return nil, err
 	}
 	if db, ok := db.(*aquadb.LDBDatabase); ok {
-		db.Meter("aqua/db/chaindata/")
+		db.Meter("db/chaindata/")
+	}
+	if config.DatabaseWriteBuffer > 0 {
+		if _, remote := db.(*aquadb.RemoteDatabase); !remote {
+			if journal := ctx.ResolvePath(name + ".journal"); journal != "" {
+				bdb, err := aquadb.NewBufferedDatabase(db, journal, config.DatabaseWriteBuffer*1024*1024)
+				if err != nil {
+					db.Close()
+					return nil, err
+				}
+				db = bdb
+			}
+		}
 	}
-	return db, nil
+	if config.DatabaseAncient == "" {
+		return db, nil
+	}
+	if _, ok := db.(*aquadb.RemoteDatabase); ok {
+		log.Warn("Ancient chain store not used with a remote database")
+		return db, nil
+	}
+	dir := ctx.ResolvePath(config.DatabaseAncient)
+	if dir == "" {
+		log.Warn("Ancient chain store not used with an ephemeral data directory")
+		return db, nil
+	}
+	threshold := config.AncientThreshold
+	if threshold == 0 {
+		threshold = core.DefaultAncientThreshold
+	}
+	fdb, err := core.NewFreezerDatabase(db, dir, threshold)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return fdb, nil
 }
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an AquaChain service
@@ -298,6 +381,9 @@ func (s *AquaChain) APIs() []rpc.API {
 		})
 	}
 
+	filterAPI := filters.NewPublicFilterAPI(s.ApiBackend, false)
+	filterAPI.SetLogLimits(s.config.LogsMaxResults, s.config.LogsMaxBlockRange)
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -318,8 +404,12 @@ func (s *AquaChain) APIs() []rpc.API {
 		}, {
 			Namespace: "aqua",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, false),
+			Service:   filterAPI,
 			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateTxPoolAPI(s),
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
@@ -406,13 +496,43 @@ func (s *AquaChain) StartMining(local bool) error {
 	return nil
 }
 
+// SetGasPrice changes the minimum gas price of transactions accepted into the
+// pool and included in mined blocks, which is also the floor of the prices
+// suggested by the gas price oracle.
+func (s *AquaChain) SetGasPrice(gasPrice *big.Int) {
+	s.lock.Lock()
+	s.gasPrice = gasPrice
+	s.lock.Unlock()
+
+	s.txPool.SetGasPrice(gasPrice)
+	s.gasOracle.SetMinimumPrice(gasPrice)
+}
+
+// SetMaxPeers changes the maximum number of peers the AquaChain protocol
+// accepts, keeping it in line with the networking layer limit.
+func (s *AquaChain) SetMaxPeers(maxPeers int) {
+	if s.protocolManager != nil {
+		s.protocolManager.SetMaxPeers(maxPeers)
+	}
+}
+
 func (s *AquaChain) StopMining()         { s.miner.Stop() }
 func (s *AquaChain) IsMining() bool      { return s.miner.Mining() }
 func (s *AquaChain) Miner() *miner.Miner { return s.miner }
 
+// NewRemoteAgent creates an agent handing out the work of the miner to external
+// miners, accepting solutions within the configured stale window. The caller
+// registers it with the miner.
+func (s *AquaChain) NewRemoteAgent() *miner.RemoteAgent {
+	agent := miner.NewRemoteAgent(s.blockchain, s.engine)
+	agent.SetStaleWindow(s.config.MinerStaleWindow)
+	return agent
+}
+
 func (s *AquaChain) AccountManager() *accounts.Manager { return s.accountManager }
 func (s *AquaChain) BlockChain() *core.BlockChain      { return s.blockchain }
 func (s *AquaChain) TxPool() *core.TxPool              { return s.txPool }
+func (s *AquaChain) GasOracle() *gasprice.Oracle       { return s.gasOracle }
 func (s *AquaChain) EventMux() *event.TypeMux          { return s.eventMux }
 func (s *AquaChain) Engine() consensus.Engine          { return s.engine }
 func (s *AquaChain) ChainDb() aquadb.Database          { return s.chainDb }
@@ -423,8 +543,32 @@ func (s *AquaChain) AquaVersion() int {
 	}
 	return 0
 }
-func (s *AquaChain) NetVersion() uint64                 { return s.networkId }
-func (s *AquaChain) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
+func (s *AquaChain) NetVersion() uint64 { return s.networkId }
+
+// Downloader returns the chain downloader, or nil when running offline.
+func (s *AquaChain) Downloader() *downloader.Downloader {
+	if s.protocolManager == nil {
+		return nil
+	}
+	return s.protocolManager.downloader
+}
+
+// ChainStatus reports whether the chain is being synchronised and the number
+// and timestamp of its head block, for the health checks of the node.
+func (s *AquaChain) ChainStatus() (bool, uint64, time.Time) {
+	head := s.blockchain.CurrentBlock()
+	syncing := s.protocolManager != nil && s.protocolManager.downloader.Synchronising()
+	return syncing, head.NumberU64(), time.Unix(head.Time().Int64(), 0)
+}
+
+// ChainMismatches returns the number of peers rejected since startup for being
+// on another network or chain.
+func (s *AquaChain) ChainMismatches() uint64 {
+	if s.protocolManager == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.protocolManager.chainMismatches)
+}
 
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
@@ -441,6 +585,20 @@ func (s *AquaChain) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Start the database maintenance scheduler
+	if s.compactor != nil {
+		s.compactor.start()
+	}
+	if s.diskMon != nil {
+		s.diskMon.start()
+	}
+
+	// Report the chain head and pool sizes in the always-on expvars
+	publishVars(s)
+
+	// Purge the RPC response caches when the chain goes backwards
+	s.startCachePurger()
+
 	// Start the RPC service
 	s.netRPCService = aquaapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -456,14 +614,23 @@ func (s *AquaChain) Start(srvr *p2p.Server) error {
 // Stop implements node.Service, terminating all internal goroutines used by the
 // AquaChain protocol.
 func (s *AquaChain) Stop() error {
+	unpublishVars(s)
 	if s.stopDbUpgrade != nil {
 		s.stopDbUpgrade()
 	}
+	if s.compactor != nil {
+		s.compactor.stop()
+	}
+	if s.diskMon != nil {
+		s.diskMon.stop()
+	}
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
+	s.cachePurgeScope.Close()
 	if s.protocolManager != nil {
 		s.protocolManager.Stop()
 	}
+	s.txTracker.Stop()
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
@@ -0,0 +1,284 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+const (
+	// compactionRanges is the number of key ranges the chain database is split
+	// into for compaction, by leading key byte. Compacting range by range keeps
+	// the individual stalls short and allows pausing between them.
+	compactionRanges = 256
+
+	// compactionCheckInterval is the time between two checks whether the
+	// maintenance window has opened.
+	compactionCheckInterval = time.Minute
+)
+
+var (
+	errCompactionUnsupported = errors.New("chain database does not support compaction")
+	errCompactionRunning     = errors.New("compaction already running")
+)
+
+// CompactionProgress reports the state of the current or last compaction of
+// the chain database.
+type CompactionProgress struct {
+	Running   bool      `json:"running"`
+	Scheduled bool      `json:"scheduled"`       // Whether started by the maintenance window
+	Done      int       `json:"done"`            // Number of key ranges compacted
+	Total     int       `json:"total"`           // Total number of key ranges
+	Started   time.Time `json:"started"`         // Start of the current or last run
+	Finished  time.Time `json:"finished"`        // End of the last run, zero while running
+	Error     string    `json:"error,omitempty"` // Failure of the last run
+}
+
+// maintenanceWindow is a daily time window in local time, which may wrap
+// around midnight.
+type maintenanceWindow struct {
+	start, end time.Duration // Offsets since midnight
+}
+
+// parseMaintenanceWindow parses a window in the HH:MM-HH:MM format.
+func parseMaintenanceWindow(s string) (*maintenanceWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid maintenance window %q, want HH:MM-HH:MM", s)
+	}
+	var offsets [2]time.Duration
+	for i, part := range parts {
+		hm := strings.Split(strings.TrimSpace(part), ":")
+		if len(hm) != 2 {
+			return nil, fmt.Errorf("invalid maintenance window time %q, want HH:MM", part)
+		}
+		hour, err := strconv.Atoi(hm[0])
+		if err != nil || hour < 0 || hour > 23 {
+			return nil, fmt.Errorf("invalid maintenance window hour %q", hm[0])
+		}
+		minute, err := strconv.Atoi(hm[1])
+		if err != nil || minute < 0 || minute > 59 {
+			return nil, fmt.Errorf("invalid maintenance window minute %q", hm[1])
+		}
+		offsets[i] = time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+	}
+	if offsets[0] == offsets[1] {
+		return nil, fmt.Errorf("empty maintenance window %q", s)
+	}
+	return &maintenanceWindow{start: offsets[0], end: offsets[1]}, nil
+}
+
+// opened returns the time the window containing t opened, or false if t is
+// outside of the window.
+func (w *maintenanceWindow) opened(t time.Time) (time.Time, bool) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	switch {
+	case w.start < w.end && offset >= w.start && offset < w.end:
+		return midnight.Add(w.start), true
+	case w.start > w.end && offset >= w.start:
+		return midnight.Add(w.start), true
+	case w.start > w.end && offset < w.end:
+		return midnight.AddDate(0, 0, -1).Add(w.start), true
+	}
+	return time.Time{}, false
+}
+
+// compactor compacts the chain database on request or in a daily maintenance
+// window. Scheduled runs pause when the window closes and resume in the next.
+type compactor struct {
+	db     aquadb.Compacter
+	window *maintenanceWindow // Maintenance window, nil if not scheduled
+
+	progress CompactionProgress
+	next     int       // Next key range of an interrupted scheduled run
+	lastRun  time.Time // Completion time of the last scheduled run
+	lock     sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newCompactor(db aquadb.Compacter, window *maintenanceWindow) *compactor {
+	return &compactor{
+		db:       db,
+		window:   window,
+		progress: CompactionProgress{Total: compactionRanges},
+		quit:     make(chan struct{}),
+	}
+}
+
+// start launches the maintenance window scheduler, if configured.
+func (c *compactor) start() {
+	if c.window == nil {
+		return
+	}
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// stop aborts any running compaction after the current key range and waits
+// for the background goroutines to exit.
+func (c *compactor) stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// loop starts a compaction whenever the maintenance window opens.
+func (c *compactor) loop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(compactionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			opened, ok := c.window.opened(now)
+			if !ok {
+				continue
+			}
+			c.lock.Lock()
+			due := c.lastRun.Before(opened)
+			c.lock.Unlock()
+
+			if !due {
+				continue
+			}
+			if first, ok := c.begin(true); ok {
+				c.run(first, true, func() bool {
+					_, ok := c.window.opened(time.Now())
+					return ok
+				})
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// compact starts a full compaction in the background.
+func (c *compactor) compact() error {
+	first, ok := c.begin(false)
+	if !ok {
+		return errCompactionRunning
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run(first, false, func() bool { return true })
+	}()
+	return nil
+}
+
+// begin marks a compaction as running, returning the first key range to compact
+// or false if another compaction is already running. Scheduled runs continue
+// from where the last one was interrupted.
+func (c *compactor) begin(scheduled bool) (int, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.progress.Running {
+		return 0, false
+	}
+	first := 0
+	if scheduled {
+		first = c.next
+	}
+	c.progress = CompactionProgress{
+		Running:   true,
+		Scheduled: scheduled,
+		Done:      first,
+		Total:     compactionRanges,
+		Started:   time.Now(),
+	}
+	return first, true
+}
+
+// run compacts the chain database range by range as long as proceed allows.
+func (c *compactor) run(first int, scheduled bool, proceed func() bool) {
+	log.Info("Compacting chain database", "scheduled", scheduled, "from", fmt.Sprintf("0x%02x", first))
+
+	var err error
+	done := first
+	for ; done < compactionRanges; done++ {
+		select {
+		case <-c.quit:
+			err = errors.New("node stopping")
+		default:
+			if !proceed() {
+				err = errors.New("maintenance window closed")
+			}
+		}
+		if err != nil {
+			break
+		}
+		start, limit := []byte{byte(done)}, []byte{byte(done + 1)}
+		if done == compactionRanges-1 {
+			limit = nil
+		}
+		rangeStart := time.Now()
+		if err = c.db.Compact(start, limit); err != nil {
+			break
+		}
+		log.Debug("Compacted chain database range", "range", fmt.Sprintf("0x%02x", done), "elapsed", common.PrettyDuration(time.Since(rangeStart)))
+
+		c.lock.Lock()
+		c.progress.Done = done + 1
+		started := c.progress.Started
+		c.lock.Unlock()
+
+		if (done+1)%32 == 0 && done+1 < compactionRanges {
+			log.Info("Chain database compaction in progress", "done", done+1, "total", compactionRanges, "elapsed", common.PrettyDuration(time.Since(started)))
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.progress.Running = false
+	c.progress.Finished = time.Now()
+	elapsed := c.progress.Finished.Sub(c.progress.Started)
+
+	if scheduled {
+		c.next = done % compactionRanges
+		c.lastRun = c.progress.Finished
+	}
+	if err != nil {
+		c.progress.Error = err.Error()
+		log.Warn("Chain database compaction interrupted", "done", done, "total", compactionRanges, "elapsed", common.PrettyDuration(elapsed), "err", err)
+		return
+	}
+	log.Info("Compacted chain database", "elapsed", common.PrettyDuration(elapsed))
+}
+
+// status returns the progress of the current or last compaction.
+func (c *compactor) status() CompactionProgress {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.progress
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	for _, bad := range []string{"", "02:00", "2-4", "25:00-04:00", "02:60-04:00", "03:00-03:00"} {
+		if _, err := parseMaintenanceWindow(bad); err == nil {
+			t.Errorf("window %q accepted", bad)
+		}
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2018, 6, 15, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		window string
+		now    time.Time
+		opened time.Time
+		inside bool
+	}{
+		{"02:00-04:30", at(1, 59), time.Time{}, false},
+		{"02:00-04:30", at(2, 0), at(2, 0), true},
+		{"02:00-04:30", at(4, 29), at(2, 0), true},
+		{"02:00-04:30", at(4, 30), time.Time{}, false},
+		{"23:00-01:00", at(23, 30), at(23, 0), true},
+		{"23:00-01:00", at(0, 30), at(23, 0).AddDate(0, 0, -1), true},
+		{"23:00-01:00", at(12, 0), time.Time{}, false},
+	}
+	for i, tt := range tests {
+		window, err := parseMaintenanceWindow(tt.window)
+		if err != nil {
+			t.Fatalf("test %d: failed to parse window: %v", i, err)
+		}
+		opened, inside := window.opened(tt.now)
+		if inside != tt.inside || !opened.Equal(tt.opened) {
+			t.Errorf("test %d: window %s at %v: have %v/%v, want %v/%v", i, tt.window, tt.now, opened, inside, tt.opened, tt.inside)
+		}
+	}
+}
+
+// recordingCompacter records the key ranges it was asked to compact.
+type recordingCompacter struct {
+	ranges [][2][]byte
+	lock   sync.Mutex
+}
+
+func (c *recordingCompacter) Compact(start []byte, limit []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ranges = append(c.ranges, [2][]byte{start, limit})
+	return nil
+}
+
+func TestCompactorResume(t *testing.T) {
+	db := new(recordingCompacter)
+	c := newCompactor(db, nil)
+	defer c.stop()
+
+	// Interrupt a scheduled run halfway through
+	first, ok := c.begin(true)
+	if !ok || first != 0 {
+		t.Fatalf("failed to begin scheduled run: %d %v", first, ok)
+	}
+	done := 0
+	c.run(first, true, func() bool { done++; return done <= compactionRanges/2 })
+
+	if status := c.status(); status.Running || status.Done != compactionRanges/2 || status.Error == "" {
+		t.Fatalf("interrupted status mismatch: %+v", status)
+	}
+	// The next scheduled run must continue where the last one stopped
+	if first, ok = c.begin(true); !ok || first != compactionRanges/2 {
+		t.Fatalf("scheduled run not resumed: %d %v", first, ok)
+	}
+	if err := c.compact(); err != errCompactionRunning {
+		t.Fatalf("concurrent compaction error mismatch: have %v, want %v", err, errCompactionRunning)
+	}
+	c.run(first, true, func() bool { return true })
+
+	if status := c.status(); status.Running || status.Done != compactionRanges || status.Error != "" {
+		t.Fatalf("completed status mismatch: %+v", status)
+	}
+	if len(db.ranges) != compactionRanges {
+		t.Fatalf("compacted range count mismatch: have %d, want %d", len(db.ranges), compactionRanges)
+	}
+	for i, r := range db.ranges {
+		if r[0][0] != byte(i) {
+			t.Errorf("range %d: start mismatch: have %x", i, r[0])
+		}
+		if i < compactionRanges-1 && r[1][0] != byte(i+1) {
+			t.Errorf("range %d: limit mismatch: have %x", i, r[1])
+		}
+	}
+	if db.ranges[compactionRanges-1][1] != nil {
+		t.Errorf("last range not open ended")
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"gitlab.com/aquachain/aquachain/common/hexutil"
 	"gitlab.com/aquachain/aquachain/consensus/aquahash"
 	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/opt/miner"
 )
 
 // DefaultConfig contains default settings for use on the AquaChain main net.
@@ -42,11 +43,15 @@ var DefaultConfig = Config{
 		DatasetsInMem:  0,
 		DatasetsOnDisk: 0,
 	},
-	NetworkId:     61717561,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(10000000), // 0.01 gwei
+	NetworkId:        61717561,
+	DatabaseCache:    768,
+	TrieCache:        256,
+	TrieTimeout:      5 * time.Minute,
+	MinFreeDisk:      1024,
+	GasPrice:         big.NewInt(10000000), // 0.01 gwei
+	MinerStaleWindow: miner.DefaultStaleWindow,
+
+	LogsMaxResults: 10000,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -85,14 +90,60 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
-	TrieCache          int
-	TrieTimeout        time.Duration
+
+	// DatabaseWriteBuffer is the number of megabytes of chain database writes
+	// buffered in memory and flushed in the background, 0 to write through.
+	DatabaseWriteBuffer int `toml:",omitempty"`
+
+	TrieCache   int
+	TrieTimeout time.Duration
+
+	// TriesInMemory is the number of recent block states kept by a pruning
+	// node, older ones are garbage collected. 0 selects the default of 128.
+	TriesInMemory uint64 `toml:",omitempty"`
+
+	// Snapshot keeps a flat copy of the head state next to the tries, serving
+	// account and storage reads without trie traversals.
+	Snapshot bool `toml:",omitempty"`
+
+	// Number of block bodies, entire blocks, receipt lists and headers kept
+	// in memory by the chain, 0 selects the built in defaults. They can be
+	// tuned at runtime with admin_setCacheSize.
+	BodyCache    int `toml:",omitempty"`
+	BlockCache   int `toml:",omitempty"`
+	ReceiptCache int `toml:",omitempty"`
+	HeaderCache  int `toml:",omitempty"`
+
+	// TxLookupLimit is the number of recent blocks whose transactions are
+	// indexed by hash, 0 indexes the whole chain.
+	TxLookupLimit uint64 `toml:",omitempty"`
+
+	// CompactionWindow is a daily HH:MM-HH:MM window in local time in which the
+	// chain database is compacted, keeping compaction stalls out of busy hours.
+	CompactionWindow string `toml:",omitempty"`
+
+	// MinFreeDisk is the free space in MiB on the chain database's disk below
+	// which a warning is logged, 0 to never warn.
+	MinFreeDisk uint64
+
+	// DatabaseAncient is the directory the headers, bodies and receipts of old
+	// canonical blocks are moved to, empty to keep them in the chain database.
+	DatabaseAncient string `toml:",omitempty"`
+
+	// AncientThreshold is the number of recent blocks kept in the chain
+	// database when DatabaseAncient is set. 0 selects the default of 90000.
+	AncientThreshold uint64 `toml:",omitempty"`
 
 	// Mining-related options
-	Aquabase     common.Address `toml:",omitempty"`
-	MinerThreads int            `toml:",omitempty"`
-	ExtraData    []byte         `toml:",omitempty"`
-	GasPrice     *big.Int
+	Aquabase         common.Address `toml:",omitempty"`
+	MinerThreads     int            `toml:",omitempty"`
+	MinerThrottle    int            `toml:",omitempty"` // Percentage of time the mining threads spend hashing (0 = all)
+	MinerNice        int            `toml:",omitempty"` // Scheduling priority of the mining threads (0 = unchanged)
+	MinerAffinity    []int          `toml:",omitempty"` // CPUs the mining threads are pinned to (empty = any)
+	MinerStaleWindow uint64         `toml:",omitempty"` // Blocks remote solutions to work behind the head are still accepted for
+	TxOrdering       string         `toml:",omitempty"` // Miner transaction ordering strategy (empty = price)
+	ExtraData        []byte         `toml:",omitempty"`
+	GasPrice         *big.Int
 
 	// Aquahash options
 	Aquahash aquahash.Config
@@ -106,6 +157,12 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// LogsMaxResults and LogsMaxBlockRange cap the results and the block range
+	// of aqua_getLogs queries, see filters.PublicFilterAPI.SetLogLimits. Zero
+	// means unlimited.
+	LogsMaxResults    int
+	LogsMaxBlockRange uint64
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
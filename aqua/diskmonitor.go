@@ -0,0 +1,128 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/diskspace"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/core"
+)
+
+const (
+	diskCheckInterval  = time.Minute      // Interval between free disk space checks
+	diskSizesInterval  = 10 * time.Minute // Interval between database size estimations
+	diskWarnInterval   = 10 * time.Minute // Minimum interval between low disk space warnings
+	diskSizesMetricsNs = "db/chaindata/size/"
+)
+
+// diskMonitor periodically reports the free space of the file system holding
+// the chain database and the size of its tables, warning when space runs low.
+type diskMonitor struct {
+	db      aquadb.Database
+	path    string // Chain database directory
+	minFree uint64 // Free space in bytes below which to warn, 0 to never warn
+
+	freeGauge  metrics.Gauge
+	sizeGauges map[string]metrics.Gauge
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newDiskMonitor(db aquadb.Database, path string, minFree uint64) *diskMonitor {
+	return &diskMonitor{
+		db:         db,
+		path:       path,
+		minFree:    minFree,
+		freeGauge:  metrics.NewRegisteredGauge("system/disk/free", nil),
+		sizeGauges: make(map[string]metrics.Gauge),
+		quit:       make(chan struct{}),
+	}
+}
+
+// start launches the monitoring loop.
+func (m *diskMonitor) start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// stop terminates the monitoring loop.
+func (m *diskMonitor) stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+func (m *diskMonitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+
+	var lastWarn, lastSizes time.Time
+	for {
+		now := time.Now()
+		if free, err := diskspace.Free(m.path); err == nil {
+			m.freeGauge.Update(int64(free))
+			if free < m.minFree && now.Sub(lastWarn) >= diskWarnInterval {
+				log.Warn("Low disk space, free space or move the datadir to a larger disk, and stop the node before the disk fills up to avoid database corruption",
+					"path", m.path, "free", common.StorageSize(free), "threshold", common.StorageSize(m.minFree))
+				lastWarn = now
+			}
+		} else {
+			log.Debug("Failed to read free disk space", "path", m.path, "err", err)
+		}
+		if metrics.Enabled && now.Sub(lastSizes) >= diskSizesInterval {
+			m.updateSizes()
+			lastSizes = now
+		}
+		select {
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// updateSizes estimates the size of the chain database tables and reports them.
+func (m *diskMonitor) updateSizes() {
+	sizes, err := core.DatabaseSizes(m.db)
+	if err != nil {
+		log.Debug("Failed to estimate database sizes", "err", err)
+		return
+	}
+	var total uint64
+	for name, size := range sizes {
+		m.sizeGauge(name).Update(int64(size))
+		total += size
+	}
+	m.sizeGauge("total").Update(int64(total))
+}
+
+func (m *diskMonitor) sizeGauge(name string) metrics.Gauge {
+	gauge, ok := m.sizeGauges[name]
+	if !ok {
+		gauge = metrics.NewRegisteredGauge(diskSizesMetricsNs+name, nil)
+		m.sizeGauges[name] = gauge
+	}
+	return gauge
+}
@@ -67,8 +67,10 @@ var (
 	fsMinFullBlocks        = 64              // Number of blocks to retrieve fully even in fast sync
 )
 
+// ErrBusy is returned by Synchronise while another synchronisation is running.
+var ErrBusy = errors.New("busy")
+
 var (
-	errBusy                    = errors.New("busy")
 	errUnknownPeer             = errors.New("peer is unknown or unhealthy")
 	errBadPeer                 = errors.New("action from evil peer ignored")
 	errStallingPeer            = errors.New("peer is stalling")
@@ -327,7 +329,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 	err := d.synchronise(id, head, td, mode)
 	switch err {
 	case nil:
-	case errBusy:
+	case ErrBusy:
 	case errBadPeer:
 		log.Debug("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
@@ -364,7 +366,7 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	}
 	// Make sure only one goroutine is ever allowed past this point at once
 	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
-		return errBusy
+		return ErrBusy
 	}
 	defer atomic.StoreInt32(&d.synchronising, 0)
 
@@ -30,7 +30,9 @@ import (
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/internal/aquaapi"
 	"gitlab.com/aquachain/aquachain/rpc"
 )
 
@@ -38,6 +40,10 @@ var (
 	deadline = 5 * time.Minute // consider a filter inactive if it has not been polled for within deadline
 )
 
+// defaultLogsPageSize is the number of logs per page of GetLogsPaged if the
+// results of log queries are not capped.
+const defaultLogsPageSize = 10000
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -58,6 +64,9 @@ type PublicFilterAPI struct {
 	events    *EventSystem
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
+
+	maxLogResults int    // maximum number of logs returned by a query, 0 for unlimited
+	maxLogRange   uint64 // maximum number of blocks searched by a query, 0 for unlimited
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
@@ -74,6 +83,13 @@ func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
 	return api
 }
 
+// SetLogLimits caps the log queries of GetLogs and GetFilterLogs, which fail if
+// they span more than maxBlockRange blocks or match more than maxResults logs.
+// GetLogsPaged splits such queries into pages instead. Zero means unlimited.
+func (api *PublicFilterAPI) SetLogLimits(maxResults int, maxBlockRange uint64) {
+	api.maxLogResults, api.maxLogRange = maxResults, maxBlockRange
+}
+
 // timeoutLoop runs every 5 minutes and deletes filters that have not been recently used.
 // Tt is started when the api is created.
 func (api *PublicFilterAPI) timeoutLoop() {
@@ -134,7 +150,8 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// If fullTx is set, the full transaction objects are delivered instead of their hashes.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -143,13 +160,22 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		txHashes := make(chan common.Hash)
-		pendingTxSub := api.events.SubscribePendingTxEvents(txHashes)
-
+		var (
+			txHashes     = make(chan common.Hash)
+			txs          = make(chan *types.Transaction)
+			pendingTxSub *Subscription
+		)
+		if fullTx != nil && *fullTx {
+			pendingTxSub = api.events.SubscribePendingTxs(txs)
+		} else {
+			pendingTxSub = api.events.SubscribePendingTxEvents(txHashes)
+		}
 		for {
 			select {
 			case h := <-txHashes:
 				notifier.Notify(rpcSub.ID, h)
+			case tx := <-txs:
+				notifier.Notify(rpcSub.ID, aquaapi.NewRPCPendingTransaction(tx))
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
 				return
@@ -228,6 +254,56 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// ReorgNotification is sent to reorgs subscribers when blocks are dropped from
+// the canonical chain. Blocks are listed in ascending order, transactions are
+// those included by only one side of the reorg.
+type ReorgNotification struct {
+	CommonHash   common.Hash    `json:"commonHash"`
+	CommonNumber hexutil.Uint64 `json:"commonNumber"`
+	Dropped      []common.Hash  `json:"dropped"`
+	Added        []common.Hash  `json:"added"`
+	DroppedTxs   []common.Hash  `json:"droppedTransactions"`
+	AddedTxs     []common.Hash  `json:"addedTransactions"`
+}
+
+// Reorgs sends a notification each time the canonical chain is reorganised, so
+// that confirmations of dropped transactions can be revoked promptly.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ReorgEvent)
+		reorgsSub := api.events.SubscribeReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, &ReorgNotification{
+					CommonHash:   ev.CommonHash,
+					CommonNumber: hexutil.Uint64(ev.CommonNumber),
+					Dropped:      ev.Dropped,
+					Added:        ev.Added,
+					DroppedTxs:   ev.DroppedTxs,
+					AddedTxs:     ev.AddedTxs,
+				})
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -333,15 +409,107 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		crit.ToBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
 	}
 	// Create and run the filter to get all the logs
-	filter := New(api.backend, crit.FromBlock.Int64(), crit.ToBlock.Int64(), crit.Addresses, crit.Topics)
-
-	logs, err := filter.Logs(ctx)
+	logs, err := api.queryLogs(ctx, crit.FromBlock.Int64(), crit.ToBlock.Int64(), crit)
 	if err != nil {
 		return nil, err
 	}
 	return returnLogs(logs), err
 }
 
+// LogsPage is a part of the results of a log query, see GetLogsPaged.
+type LogsPage struct {
+	Logs   []*types.Log    `json:"logs"`
+	Cursor *hexutil.Uint64 `json:"cursor"` // block to continue from, nil if done
+}
+
+// GetLogsPaged returns the logs matching the given argument like GetLogs, but
+// in pages within the configured limits. The next page is retrieved by passing
+// the cursor of the previous one along with the same criteria, until no cursor
+// is returned. Logs of one block are never split over pages.
+func (api *PublicFilterAPI) GetLogsPaged(ctx context.Context, crit FilterCriteria, cursor *hexutil.Uint64) (*LogsPage, error) {
+	begin, end := rpc.LatestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	if cursor != nil {
+		begin = int64(*cursor)
+	}
+	// Resolve the range to clamp it to the maximum
+	begin, end, err := api.resolveRange(ctx, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	page := &LogsPage{Logs: []*types.Log{}}
+	if begin > end {
+		return page, nil
+	}
+	last := end
+	if api.maxLogRange > 0 && uint64(end-begin) >= api.maxLogRange {
+		last = begin + int64(api.maxLogRange) - 1
+	}
+	size := api.maxLogResults
+	if size == 0 {
+		size = defaultLogsPageSize
+	}
+	logs, next, err := New(api.backend, begin, last, crit.Addresses, crit.Topics).LogsLimited(ctx, size)
+	if err != nil {
+		return nil, err
+	}
+	if next == -1 && last < end {
+		next = last + 1
+	}
+	if next != -1 {
+		cursor := hexutil.Uint64(next)
+		page.Cursor = &cursor
+	}
+	page.Logs = returnLogs(logs)
+	return page, nil
+}
+
+// queryLogs runs a log query within the configured limits.
+func (api *PublicFilterAPI) queryLogs(ctx context.Context, begin, end int64, crit FilterCriteria) ([]*types.Log, error) {
+	if api.maxLogRange > 0 {
+		from, to, err := api.resolveRange(ctx, begin, end)
+		if err != nil {
+			return nil, err
+		}
+		if to >= from && uint64(to-from) >= api.maxLogRange {
+			return nil, fmt.Errorf("block range too large (%d > %d), use aqua_getLogsPaged", to-from+1, api.maxLogRange)
+		}
+	}
+	filter := New(api.backend, begin, end, crit.Addresses, crit.Topics)
+	if api.maxLogResults == 0 {
+		return filter.Logs(ctx)
+	}
+	logs, _, err := filter.LogsLimited(ctx, api.maxLogResults+1)
+	if err == nil && len(logs) > api.maxLogResults {
+		return nil, fmt.Errorf("query returned more than %d results, use aqua_getLogsPaged", api.maxLogResults)
+	}
+	return logs, err
+}
+
+// resolveRange replaces "latest" in the bounds of a block range with the number
+// of the current head.
+func (api *PublicFilterAPI) resolveRange(ctx context.Context, begin, end int64) (int64, int64, error) {
+	header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	if header == nil {
+		return 0, 0, errors.New("no head block")
+	}
+	if begin == rpc.LatestBlockNumber.Int64() {
+		begin = header.Number.Int64()
+	}
+	if end == rpc.LatestBlockNumber.Int64() {
+		end = header.Number.Int64()
+	}
+	return begin, end, nil
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://github.com/aquanetwork/wiki/wiki/JSON-RPC#aqua_uninstallfilter
@@ -381,9 +549,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		end = f.crit.ToBlock.Int64()
 	}
 	// Create and run the filter to get all the logs
-	filter := New(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-
-	logs, err := filter.Logs(ctx)
+	logs, err := api.queryLogs(ctx, begin, end, f.crit)
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +23,7 @@ import (
 	"gitlab.com/aquachain/aquachain/aqua/event"
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/tracing"
 	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/bloombits"
 	"gitlab.com/aquachain/aquachain/core/types"
@@ -42,6 +43,7 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
@@ -97,10 +99,19 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+	logs, _, err := f.LogsLimited(ctx, 0)
+	return logs, err
+}
+
+// LogsLimited is like Logs, but stops searching after the block in which the
+// number of matching logs reaches limit, zero meaning unlimited. Logs of a
+// block are never split. It also returns the number of the block to continue
+// the search from, or -1 if the whole range has been searched.
+func (f *Filter) LogsLimited(ctx context.Context, limit int) ([]*types.Log, int64, error) {
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if header == nil {
-		return nil, nil
+		return nil, -1, nil
 	}
 	head := header.Number.Uint64()
 
@@ -111,6 +122,12 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
+	next := func() int64 {
+		if f.begin > int64(end) {
+			return -1
+		}
+		return f.begin
+	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
@@ -119,22 +136,34 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			logs, err = f.indexedLogs(ctx, end, limit)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			logs, err = f.indexedLogs(ctx, indexed-1, limit)
 		}
 		if err != nil {
-			return logs, err
+			return logs, next(), err
 		}
+		if limit > 0 && len(logs) >= limit {
+			return logs, next(), nil
+		}
+	}
+	if limit > 0 {
+		limit -= len(logs)
 	}
-	rest, err := f.unindexedLogs(ctx, end)
+	rest, err := f.unindexedLogs(ctx, end, limit)
 	logs = append(logs, rest...)
-	return logs, err
+	return logs, next(), err
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, limit int) (logs []*types.Log, err error) {
+	ctx, span := tracing.StartSpan(ctx, "filter.indexed", "from", f.begin, "to", end)
+	defer func() {
+		span.SetAttributes("logs", len(logs))
+		span.SetError(err)
+		span.End()
+	}()
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
@@ -147,8 +176,6 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	f.backend.ServiceFilter(ctx, session)
 
 	// Iterate over the matches until exhausted or context closed
-	var logs []*types.Log
-
 	for {
 		select {
 		case number, ok := <-matches:
@@ -172,6 +199,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				return logs, err
 			}
 			logs = append(logs, found...)
+			if limit > 0 && len(logs) >= limit {
+				return logs, nil
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -181,8 +211,13 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 
 // indexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, limit int) (logs []*types.Log, err error) {
+	ctx, span := tracing.StartSpan(ctx, "filter.unindexed", "from", f.begin, "to", end)
+	defer func() {
+		span.SetAttributes("logs", len(logs))
+		span.SetError(err)
+		span.End()
+	}()
 
 	for ; f.begin <= int64(end); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
@@ -195,6 +230,10 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 				return logs, err
 			}
 			logs = append(logs, found...)
+			if limit > 0 && len(logs) >= limit {
+				f.begin++
+				return logs, nil
+			}
 		}
 	}
 	return logs, nil
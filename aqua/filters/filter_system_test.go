@@ -45,6 +45,7 @@ type testBackend struct {
 	rmLogsFeed *event.Feed
 	logsFeed   *event.Feed
 	chainFeed  *event.Feed
+	reorgFeed  *event.Feed
 }
 
 func (b *testBackend) ChainDb() aquadb.Database {
@@ -107,6 +108,10 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.reorgFeed.Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -153,7 +158,7 @@ func TestBlockSubscription(t *testing.T) {
 		rmLogsFeed  = new(event.Feed)
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api         = NewPublicFilterAPI(backend, false)
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, aquahash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
@@ -199,6 +204,58 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestReorgSubscription tests that reorgs are delivered to all subscribers and
+// not to other subscriptions.
+func TestReorgSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db         = aquadb.NewMemDatabase()
+		chainFeed  = new(event.Feed)
+		reorgFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, new(event.Feed), new(event.Feed), new(event.Feed), chainFeed, reorgFeed}
+		api        = NewPublicFilterAPI(backend, false)
+		reorg      = core.ReorgEvent{CommonNumber: 1, Dropped: []common.Hash{{2}}, Added: []common.Hash{{3}, {4}}, DroppedTxs: []common.Hash{{5}}}
+		reorgs0    = make(chan core.ReorgEvent)
+		reorgs1    = make(chan core.ReorgEvent)
+		headers    = make(chan *types.Header)
+		sub0       = api.events.SubscribeReorgs(reorgs0)
+		sub1       = api.events.SubscribeReorgs(reorgs1)
+		headersSub = api.events.SubscribeNewHeads(headers)
+	)
+	defer headersSub.Unsubscribe()
+
+	reorgFeed.Send(reorg)
+
+	// The subscriptions are served in no particular order, accept either first
+	delivered := make([]bool, 2)
+	for i := 0; i < len(delivered); i++ {
+		var (
+			ev  core.ReorgEvent
+			sub int
+		)
+		select {
+		case ev = <-reorgs0:
+		case ev = <-reorgs1:
+			sub = 1
+		case <-headers:
+			t.Fatalf("reorg delivered as a head")
+		case <-time.After(time.Second):
+			t.Fatalf("reorg not delivered to all subscriptions: %v", delivered)
+		}
+		if delivered[sub] {
+			t.Fatalf("sub%d: reorg delivered twice", sub)
+		}
+		delivered[sub] = true
+		if !reflect.DeepEqual(ev, reorg) {
+			t.Errorf("sub%d: reorg mismatch: have %+v, want %+v", sub, ev, reorg)
+		}
+	}
+	sub0.Unsubscribe()
+	sub1.Unsubscribe()
+}
+
 // TestPendingTxFilter tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
@@ -210,7 +267,7 @@ func TestPendingTxFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		transactions = []*types.Transaction{
@@ -263,6 +320,58 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxSubscription tests that full transaction and hash subscribers
+// both receive the transactions entering the pool.
+func TestPendingTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db         = aquadb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api        = NewPublicFilterAPI(backend, false)
+
+		transactions = []*types.Transaction{
+			types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
+			types.NewTransaction(1, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
+			types.NewTransaction(2, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
+		}
+		txs    = make(chan *types.Transaction)
+		hashes = make(chan common.Hash)
+	)
+	txSub := api.events.SubscribePendingTxs(txs)
+	defer txSub.Unsubscribe()
+	hashSub := api.events.SubscribePendingTxEvents(hashes)
+	defer hashSub.Unsubscribe()
+
+	go func() {
+		for _, tx := range transactions {
+			txFeed.Send(core.TxPreEvent{Tx: tx})
+		}
+	}()
+	timeout := time.After(time.Second)
+	for i, want := range transactions {
+		for received := 0; received < 2; received++ {
+			select {
+			case tx := <-txs:
+				if tx.Hash() != want.Hash() {
+					t.Errorf("tx %d: transaction mismatch, want %x, got %x", i, want.Hash(), tx.Hash())
+				}
+			case hash := <-hashes:
+				if hash != want.Hash() {
+					t.Errorf("tx %d: hash mismatch, want %x, got %x", i, want.Hash(), hash)
+				}
+			case <-timeout:
+				t.Fatalf("tx %d: timeout waiting for pending transaction events", i)
+			}
+		}
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -273,7 +382,7 @@ func TestLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		testCases = []struct {
@@ -322,7 +431,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 	)
 
@@ -352,7 +461,7 @@ func TestLogFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -471,7 +580,7 @@ func TestPendingLogsSubscription(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
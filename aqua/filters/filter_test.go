@@ -26,6 +26,7 @@ import (
 	"gitlab.com/aquachain/aquachain/aqua/event"
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
 	"gitlab.com/aquachain/aquachain/consensus/aquahash"
 	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/types"
@@ -56,7 +57,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -121,7 +122,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -241,3 +242,93 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestLogsPaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filtertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		db, _      = aquadb.NewLDBDatabase(dir, 0, 0)
+		mux        = new(event.TypeMux)
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		addr       = common.BytesToAddress([]byte("logger"))
+	)
+	defer db.Close()
+
+	// Every block has one log, with the block number as topic
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, aquahash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{
+				Address: addr,
+				Topics:  []common.Hash{common.BigToHash(gen.Number())},
+			},
+		}
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+			t.Fatal("error writing block receipts:", err)
+		}
+	}
+
+	logs, next, err := New(backend, 0, -1, []common.Address{addr}, nil).LogsLimited(context.Background(), 3)
+	if err != nil || len(logs) != 3 || next != 4 {
+		t.Fatalf("limited logs: have %d logs, next %d, err %v, want 3 logs, next 4", len(logs), next, err)
+	}
+
+	api := NewPublicFilterAPI(backend, false)
+	crit := FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr}}
+	for _, limits := range []struct {
+		results int
+		blocks  uint64
+	}{{3, 0}, {0, 4}, {2, 3}} {
+		api.SetLogLimits(limits.results, limits.blocks)
+		if _, err := api.GetLogs(context.Background(), crit); err == nil {
+			t.Errorf("limits %+v: oversized query succeeded", limits)
+		}
+		var (
+			collected []*types.Log
+			cursor    *hexutil.Uint64
+		)
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("limits %+v: paging does not end", limits)
+			}
+			page, err := api.GetLogsPaged(context.Background(), crit, cursor)
+			if err != nil {
+				t.Fatalf("limits %+v: paged query failed: %v", limits, err)
+			}
+			if limits.results > 0 && len(page.Logs) > limits.results {
+				t.Errorf("limits %+v: page of %d logs", limits, len(page.Logs))
+			}
+			collected = append(collected, page.Logs...)
+			if cursor = page.Cursor; cursor == nil {
+				break
+			}
+		}
+		if len(collected) != 10 {
+			t.Errorf("limits %+v: have %d logs, want 10", limits, len(collected))
+		}
+		for i, log := range collected {
+			if block := log.Topics[0].Big().Int64(); block != int64(i+1) {
+				t.Errorf("limits %+v: log %d from block %d, want %d", limits, i, block, i+1)
+			}
+		}
+	}
+}
@@ -29,16 +29,23 @@ import (
 	"gitlab.com/aquachain/aquachain/rpc"
 )
 
-var maxPrice = big.NewInt(500 * params.Shannon)
+// DefaultMaxPrice is the highest gas price suggested if no ceiling is
+// configured.
+var DefaultMaxPrice = big.NewInt(500 * params.Shannon)
 
 type Config struct {
 	Blocks     int
 	Percentile int
 	Default    *big.Int `toml:",omitempty"`
+	MaxPrice   *big.Int `toml:",omitempty"` // Ceiling of suggested prices (nil = DefaultMaxPrice)
 }
 
 // Oracle recommends gas prices based on the content of recent
 // blocks. Suitable for both light and full clients.
+//
+// The suggestions are never below the minimum price the local transaction
+// pool and miner accept, so transactions priced by the oracle are not
+// rejected by the node suggesting the price.
 type Oracle struct {
 	backend   aquaapi.Backend
 	lastHead  common.Hash
@@ -46,6 +53,9 @@ type Oracle struct {
 	cacheLock sync.RWMutex
 	fetchLock sync.Mutex
 
+	minPrice *big.Int // Minimum price accepted locally, guarded by cacheLock
+	maxPrice *big.Int
+
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
 }
@@ -63,9 +73,14 @@ func NewOracle(backend aquaapi.Backend, params Config) *Oracle {
 	if percent > 100 {
 		percent = 100
 	}
+	maxPrice := params.MaxPrice
+	if maxPrice == nil || maxPrice.Sign() <= 0 {
+		maxPrice = DefaultMaxPrice
+	}
 	return &Oracle{
 		backend:     backend,
 		lastPrice:   params.Default,
+		maxPrice:    maxPrice,
 		checkBlocks: blocks,
 		maxEmpty:    blocks / 2,
 		maxBlocks:   blocks * 5,
@@ -73,8 +88,42 @@ func NewOracle(backend aquaapi.Backend, params Config) *Oracle {
 	}
 }
 
+// SetMinimumPrice sets the lowest gas price accepted by the local transaction
+// pool and miner, which is the floor of all suggestions.
+func (gpo *Oracle) SetMinimumPrice(price *big.Int) {
+	gpo.cacheLock.Lock()
+	gpo.minPrice = new(big.Int).Set(price)
+	gpo.cacheLock.Unlock()
+}
+
+// MinimumPrice returns the lowest gas price accepted by the local transaction
+// pool and miner, or nil if none was set.
+func (gpo *Oracle) MinimumPrice() *big.Int {
+	gpo.cacheLock.RLock()
+	defer gpo.cacheLock.RUnlock()
+
+	if gpo.minPrice == nil {
+		return nil
+	}
+	return new(big.Int).Set(gpo.minPrice)
+}
+
 // SuggestPrice returns the recommended gas price.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	price, err := gpo.suggestPrice(ctx)
+	if price == nil {
+		return price, err
+	}
+	// Never suggest a price the local node would reject
+	if min := gpo.MinimumPrice(); min != nil && price.Cmp(min) < 0 {
+		price = min
+	}
+	return price, err
+}
+
+// suggestPrice returns the configured percentile of the recent gas prices,
+// cached until the chain head changes.
+func (gpo *Oracle) suggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.cacheLock.RLock()
 	lastHead := gpo.lastHead
 	lastPrice := gpo.lastPrice
@@ -136,8 +185,8 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 		sort.Sort(bigIntArray(blockPrices))
 		price = blockPrices[(len(blockPrices)-1)*gpo.percentile/100]
 	}
-	if price.Cmp(maxPrice) > 0 {
-		price = new(big.Int).Set(maxPrice)
+	if price.Cmp(gpo.maxPrice) > 0 {
+		price = new(big.Int).Set(gpo.maxPrice)
 	}
 
 	gpo.cacheLock.Lock()
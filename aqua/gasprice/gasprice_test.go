@@ -0,0 +1,121 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/internal/aquaapi"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// testBackend serves a chain whose blocks each contain a single transaction
+// with the gas price given in gwei.
+type testBackend struct {
+	aquaapi.Backend
+	blocks []*types.Block
+}
+
+func newTestBackend(t *testing.T, prices ...int64) *testBackend {
+	key, _ := crypto.GenerateKey()
+	signer := types.MakeSigner(params.TestChainConfig, new(big.Int))
+
+	b := &testBackend{blocks: []*types.Block{types.NewBlock(&types.Header{Number: new(big.Int), Version: types.H_KECCAK256}, nil, nil, nil)}}
+	for i, price := range prices {
+		tx, err := types.SignTx(types.NewTransaction(uint64(i), common.Address{}, nil, 21000, big.NewInt(price*params.Shannon), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := &types.Header{Number: big.NewInt(int64(i + 1)), Version: types.H_KECCAK256}
+		b.blocks = append(b.blocks, types.NewBlock(header, []*types.Transaction{tx}, nil, nil))
+	}
+	return b
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func TestSuggestPrice(t *testing.T) {
+	gwei := func(n int64) *big.Int { return big.NewInt(n * params.Shannon) }
+
+	tests := []struct {
+		config   Config
+		minimum  *big.Int
+		expected *big.Int
+	}{
+		// Plain percentile of the recent blocks
+		{Config{Blocks: 5, Percentile: 60, Default: gwei(1)}, nil, gwei(3)},
+		// Suggestions are capped by the ceiling
+		{Config{Blocks: 5, Percentile: 60, Default: gwei(1), MaxPrice: gwei(2)}, nil, gwei(2)},
+		// But never below the local minimum price
+		{Config{Blocks: 5, Percentile: 60, Default: gwei(1)}, gwei(4), gwei(4)},
+		{Config{Blocks: 5, Percentile: 60, Default: gwei(1), MaxPrice: gwei(2)}, gwei(4), gwei(4)},
+	}
+	for i, test := range tests {
+		gpo := NewOracle(newTestBackend(t, 1, 2, 3, 4, 5), test.config)
+		if test.minimum != nil {
+			gpo.SetMinimumPrice(test.minimum)
+		}
+		price, err := gpo.SuggestPrice(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to suggest price: %v", i, err)
+		}
+		if price.Cmp(test.expected) != 0 {
+			t.Errorf("test %d: price mismatch: have %v, want %v", i, price, test.expected)
+		}
+	}
+}
+
+func TestSuggestPriceCache(t *testing.T) {
+	backend := newTestBackend(t, 1, 2, 3)
+	gpo := NewOracle(backend, Config{Blocks: 3, Percentile: 0, Default: big.NewInt(1)})
+
+	if price, _ := gpo.SuggestPrice(context.Background()); price.Cmp(big.NewInt(params.Shannon)) != 0 {
+		t.Fatalf("price mismatch: have %v, want 1 gwei", price)
+	}
+	// Raising the minimum takes effect without a new head
+	gpo.SetMinimumPrice(big.NewInt(5 * params.Shannon))
+	if price, _ := gpo.SuggestPrice(context.Background()); price.Cmp(big.NewInt(5*params.Shannon)) != 0 {
+		t.Fatalf("price mismatch after raising minimum: have %v, want 5 gwei", price)
+	}
+	// Changing older blocks without a new head is served from the cache
+	backend.blocks[1] = backend.blocks[3]
+	gpo.SetMinimumPrice(new(big.Int))
+	if price, _ := gpo.SuggestPrice(context.Background()); price.Cmp(big.NewInt(params.Shannon)) != 0 {
+		t.Fatalf("price mismatch from cache: have %v, want 1 gwei", price)
+	}
+}
@@ -23,14 +23,33 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SkipBcVersionCheck      bool `toml:"-"`
 		DatabaseHandles         int  `toml:"-"`
 		DatabaseCache           int
+		DatabaseWriteBuffer     int    `toml:",omitempty"`
+		TriesInMemory           uint64 `toml:",omitempty"`
+		Snapshot                bool   `toml:",omitempty"`
+		BodyCache               int    `toml:",omitempty"`
+		BlockCache              int    `toml:",omitempty"`
+		ReceiptCache            int    `toml:",omitempty"`
+		HeaderCache             int    `toml:",omitempty"`
+		TxLookupLimit           uint64 `toml:",omitempty"`
+		CompactionWindow        string `toml:",omitempty"`
+		MinFreeDisk             uint64
+		DatabaseAncient         string         `toml:",omitempty"`
+		AncientThreshold        uint64         `toml:",omitempty"`
 		Aquabase                common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
+		MinerThrottle           int            `toml:",omitempty"`
+		MinerNice               int            `toml:",omitempty"`
+		MinerAffinity           []int          `toml:",omitempty"`
+		MinerStaleWindow        uint64         `toml:",omitempty"`
+		TxOrdering              string         `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
 		Aquahash                aquahash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		LogsMaxResults          int
+		LogsMaxBlockRange       uint64
 		DocRoot                 string `toml:"-"`
 	}
 	var enc Config
@@ -40,14 +59,33 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseWriteBuffer = c.DatabaseWriteBuffer
+	enc.TriesInMemory = c.TriesInMemory
+	enc.Snapshot = c.Snapshot
+	enc.BodyCache = c.BodyCache
+	enc.BlockCache = c.BlockCache
+	enc.ReceiptCache = c.ReceiptCache
+	enc.HeaderCache = c.HeaderCache
+	enc.TxLookupLimit = c.TxLookupLimit
+	enc.CompactionWindow = c.CompactionWindow
+	enc.MinFreeDisk = c.MinFreeDisk
+	enc.DatabaseAncient = c.DatabaseAncient
+	enc.AncientThreshold = c.AncientThreshold
 	enc.Aquabase = c.Aquabase
 	enc.MinerThreads = c.MinerThreads
+	enc.MinerThrottle = c.MinerThrottle
+	enc.MinerNice = c.MinerNice
+	enc.MinerAffinity = c.MinerAffinity
+	enc.MinerStaleWindow = c.MinerStaleWindow
+	enc.TxOrdering = c.TxOrdering
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
 	enc.Aquahash = c.Aquahash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.LogsMaxResults = c.LogsMaxResults
+	enc.LogsMaxBlockRange = c.LogsMaxBlockRange
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -60,14 +98,33 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SkipBcVersionCheck      *bool `toml:"-"`
 		DatabaseHandles         *int  `toml:"-"`
 		DatabaseCache           *int
+		DatabaseWriteBuffer     *int    `toml:",omitempty"`
+		TriesInMemory           *uint64 `toml:",omitempty"`
+		Snapshot                *bool   `toml:",omitempty"`
+		BodyCache               *int    `toml:",omitempty"`
+		BlockCache              *int    `toml:",omitempty"`
+		ReceiptCache            *int    `toml:",omitempty"`
+		HeaderCache             *int    `toml:",omitempty"`
+		TxLookupLimit           *uint64 `toml:",omitempty"`
+		CompactionWindow        *string `toml:",omitempty"`
+		MinFreeDisk             *uint64
+		DatabaseAncient         *string         `toml:",omitempty"`
+		AncientThreshold        *uint64         `toml:",omitempty"`
 		Aquabase                *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
+		MinerThrottle           *int            `toml:",omitempty"`
+		MinerNice               *int            `toml:",omitempty"`
+		MinerAffinity           []int           `toml:",omitempty"`
+		MinerStaleWindow        *uint64         `toml:",omitempty"`
+		TxOrdering              *string         `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
 		Aquahash                *aquahash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		LogsMaxResults          *int
+		LogsMaxBlockRange       *uint64
 		DocRoot                 *string `toml:"-"`
 	}
 	var dec Config
@@ -92,12 +149,63 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseWriteBuffer != nil {
+		c.DatabaseWriteBuffer = *dec.DatabaseWriteBuffer
+	}
+	if dec.TriesInMemory != nil {
+		c.TriesInMemory = *dec.TriesInMemory
+	}
+	if dec.Snapshot != nil {
+		c.Snapshot = *dec.Snapshot
+	}
+	if dec.BodyCache != nil {
+		c.BodyCache = *dec.BodyCache
+	}
+	if dec.BlockCache != nil {
+		c.BlockCache = *dec.BlockCache
+	}
+	if dec.ReceiptCache != nil {
+		c.ReceiptCache = *dec.ReceiptCache
+	}
+	if dec.HeaderCache != nil {
+		c.HeaderCache = *dec.HeaderCache
+	}
+	if dec.TxLookupLimit != nil {
+		c.TxLookupLimit = *dec.TxLookupLimit
+	}
+	if dec.CompactionWindow != nil {
+		c.CompactionWindow = *dec.CompactionWindow
+	}
+	if dec.MinFreeDisk != nil {
+		c.MinFreeDisk = *dec.MinFreeDisk
+	}
+	if dec.DatabaseAncient != nil {
+		c.DatabaseAncient = *dec.DatabaseAncient
+	}
+	if dec.AncientThreshold != nil {
+		c.AncientThreshold = *dec.AncientThreshold
+	}
 	if dec.Aquabase != nil {
 		c.Aquabase = *dec.Aquabase
 	}
 	if dec.MinerThreads != nil {
 		c.MinerThreads = *dec.MinerThreads
 	}
+	if dec.MinerThrottle != nil {
+		c.MinerThrottle = *dec.MinerThrottle
+	}
+	if dec.MinerNice != nil {
+		c.MinerNice = *dec.MinerNice
+	}
+	if dec.MinerAffinity != nil {
+		c.MinerAffinity = dec.MinerAffinity
+	}
+	if dec.MinerStaleWindow != nil {
+		c.MinerStaleWindow = *dec.MinerStaleWindow
+	}
+	if dec.TxOrdering != nil {
+		c.TxOrdering = *dec.TxOrdering
+	}
 	if dec.ExtraData != nil {
 		c.ExtraData = *dec.ExtraData
 	}
@@ -116,6 +224,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.LogsMaxResults != nil {
+		c.LogsMaxResults = *dec.LogsMaxResults
+	}
+	if dec.LogsMaxBlockRange != nil {
+		c.LogsMaxBlockRange = *dec.LogsMaxBlockRange
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -64,10 +64,15 @@ type ProtocolManager struct {
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
+	fastSyncFailures uint32 // Number of fast sync cycles failed in a row (atomic access)
+	fastSyncAborted  uint32 // Flag whether fast sync fell back to full sync for good
+
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
-	maxPeers    int
+	maxPeers    int32 // Maximum number of aqua peers (atomic access)
+
+	chainMismatches uint64 // Number of peers rejected for being on another chain (atomic access)
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
@@ -200,7 +205,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 }
 
 func (pm *ProtocolManager) Start(maxPeers int) {
-	pm.maxPeers = maxPeers
+	pm.SetMaxPeers(maxPeers)
 
 	// broadcast transactions
 	pm.txCh = make(chan core.TxPreEvent, txChanSize)
@@ -216,6 +221,12 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.txsyncLoop()
 }
 
+// SetMaxPeers changes the maximum number of aqua peers accepted. Existing peers
+// are not dropped if the new limit is lower.
+func (pm *ProtocolManager) SetMaxPeers(maxPeers int) {
+	atomic.StoreInt32(&pm.maxPeers, int32(maxPeers))
+}
+
 func (pm *ProtocolManager) Stop() {
 	log.Info("Stopping AquaChain protocol")
 
@@ -248,7 +259,7 @@ func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *p
 // handle is the callback invoked to manage the life cycle of an aqua peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	if pm.peers.Len() >= pm.maxPeers {
+	if pm.peers.Len() >= int(atomic.LoadInt32(&pm.maxPeers)) {
 		return p2p.DiscTooManyPeers
 	}
 	p.Log().Trace("AquaChain peer connected", "name", p.Name())
@@ -263,6 +274,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	)
 	if err := p.Handshake(pm.networkId, td, hash, genesis.Hash()); err != nil {
 		p.Log().Trace("AquaChain handshake failed", "err", err)
+		if _, ok := err.(chainMismatchError); ok {
+			atomic.AddUint64(&pm.chainMismatches, 1)
+		}
 		return err
 	}
 	if rw, ok := p.rw.(*meteredMsgReadWriter); ok {
@@ -274,6 +288,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		return err
 	}
 	defer pm.removePeer(p.id)
+	defer func() { p.meters.unregister() }()
 
 	// Register the peer in the downloader. If the downloader considers it banned, we disconnect
 	if err := pm.downloader.RegisterPeer(p.id, p.version, p); err != nil {
@@ -306,6 +321,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	defer msg.Discard()
 
+	if peerMetrics.Enabled() {
+		if p.meters == nil {
+			p.meters = newPeerMeters(p.id)
+		}
+		defer p.meters.mark(msg, time.Now())
+	}
 	// Handle the message depending on its contents
 	switch {
 	case msg.Code == StatusMsg:
@@ -616,7 +637,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		pm.txpool.AddRemotesFrom(p.Origin(), txs)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -685,6 +706,10 @@ func (self *ProtocolManager) txBroadcastLoop() {
 	for {
 		select {
 		case event := <-self.txCh:
+			// Conditional transactions are only ever included by the local miner
+			if self.txpool.Conditions(event.Tx.Hash()) != nil {
+				continue
+			}
 			self.BroadcastTx(event.Tx.Hash(), event.Tx)
 
 		// Err() channel will be closed when unsubscribing.
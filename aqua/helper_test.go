@@ -110,6 +110,16 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// Conditions returns nil, the test pool doesn't hold conditional transactions.
+func (p *testTxPool) Conditions(hash common.Hash) *core.TxConditions {
+	return nil
+}
+
+// AddRemotesFrom appends a batch of transactions to the pool, ignoring the origin.
+func (p *testTxPool) AddRemotesFrom(origin string, txs []*types.Transaction) []error {
+	return p.AddRemotes(txs)
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
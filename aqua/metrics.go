@@ -17,6 +17,8 @@
 package aqua
 
 import (
+	"time"
+
 	"gitlab.com/aquachain/aquachain/common/metrics"
 	"gitlab.com/aquachain/aquachain/p2p"
 )
@@ -56,6 +58,42 @@ var (
 	miscOutTrafficMeter       = metrics.NewRegisteredMeter("aqua/misc/out/traffic", nil)
 )
 
+// peerMetrics switches the per-peer message metrics, which are too expensive to
+// collect for every peer all the time.
+var peerMetrics = metrics.NewModule("aqua/peers", false)
+
+// peerMeters are the metrics collected for a single remote peer.
+type peerMeters struct {
+	prefix string
+	sizes  metrics.Histogram // Sizes of the messages received from the peer
+	times  metrics.Timer     // Time spent handling the messages of the peer
+}
+
+func newPeerMeters(id string) *peerMeters {
+	prefix := "aqua/peers/" + id + "/"
+	return &peerMeters{
+		prefix: prefix,
+		sizes:  peerMetrics.Histogram(prefix+"msgsize", nil),
+		times:  peerMetrics.Timer(prefix+"handling", nil),
+	}
+}
+
+// mark records a message handled since start.
+func (m *peerMeters) mark(msg p2p.Msg, start time.Time) {
+	m.sizes.Update(int64(msg.Size))
+	m.times.UpdateSince(start)
+}
+
+// unregister removes the metrics of the peer from the registry.
+func (m *peerMeters) unregister() {
+	if m == nil {
+		return
+	}
+	m.times.Stop()
+	metrics.DefaultRegistry.Unregister(m.prefix + "msgsize")
+	metrics.DefaultRegistry.Unregister(m.prefix + "handling")
+}
+
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
 // accumulating the above defined metrics based on the data stream contents.
 type meteredMsgReadWriter struct {
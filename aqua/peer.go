@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"sync"
 	"time"
 
@@ -64,6 +65,8 @@ type peer struct {
 
 	knownTxs    set.Set // Set of transaction hashes known to be known by this peer
 	knownBlocks set.Set // Set of block hashes known to be known by this peer
+
+	meters *peerMeters // Per-peer metrics, only touched by the message handler
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -129,6 +132,16 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 	p.knownTxs.Add(hash)
 }
 
+// Origin returns the identity the transactions relayed by the peer are accounted
+// against by the transaction pool admission limits: the IP address of the peer,
+// so a single host can't dodge the limits by connecting with many node keys.
+func (p *peer) Origin() string {
+	if addr, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return p.id
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
@@ -262,6 +275,10 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
+// chainMismatchError is returned by the handshake if the remote peer is on
+// another network or chain.
+type chainMismatchError struct{ error }
+
 func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
@@ -278,10 +295,10 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
-		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+		return chainMismatchError{errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])}
 	}
 	if status.NetworkId != network {
-		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+		return chainMismatchError{errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)}
 	}
 	if int(status.ProtocolVersion) != p.version {
 		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
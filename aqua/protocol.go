@@ -101,6 +101,14 @@ type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// AddRemotesFrom should add the given transactions relayed by the given
+	// origin to the pool, enforcing the per origin admission limits.
+	AddRemotesFrom(string, []*types.Transaction) []error
+
+	// Conditions should return the inclusion constraints of a conditional
+	// transaction, which must not be propagated to the network.
+	Conditions(common.Hash) *core.TxConditions
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
@@ -19,6 +19,7 @@ package aqua
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -90,6 +91,10 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 		}
 		p.close()
 	}
+	// Only the peers on another network or chain count as mismatches
+	if n := atomic.LoadUint64(&pm.chainMismatches); n != 2 {
+		t.Errorf("chain mismatch count mismatch: have %d, want 2", n)
+	}
 }
 
 // This test checks that received transactions are added to the local pool.
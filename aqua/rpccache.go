@@ -0,0 +1,84 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/internal/aquaapi"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// rpcCacheConfirmations is the number of blocks built on top of a block before
+// the RPC results about it are considered final and may be cached.
+const rpcCacheConfirmations = 32
+
+// CachePolicies implements node.CacheableService, allowing the RPC results about
+// blocks and transactions deep enough in the chain to be cached.
+func (s *AquaChain) CachePolicies() map[string]rpc.CachePolicy {
+	return aquaapi.CachePolicies(s.ApiBackend, rpcCacheConfirmations)
+}
+
+// SubscribeCachePurge implements node.CacheableService, notifying the RPC
+// response caches whenever the results they deem final may have changed.
+func (s *AquaChain) SubscribeCachePurge(ch chan<- struct{}) event.Subscription {
+	return s.cachePurgeScope.Track(s.cachePurgeFeed.Subscribe(ch))
+}
+
+// startCachePurger tells the RPC response caches to purge their results when
+// the chain goes backwards: on a rewind to an older head, or a reorg dropping
+// blocks deep enough to have been cached. It stops along with the chain.
+func (s *AquaChain) startCachePurger() {
+	var (
+		headCh   = make(chan core.ChainHeadEvent, 16)
+		reorgCh  = make(chan core.ReorgEvent, 16)
+		rewindCh = make(chan core.ChainRewindEvent, 1)
+	)
+	headSub := s.blockchain.SubscribeChainHeadEvent(headCh)
+	reorgSub := s.blockchain.SubscribeReorgEvent(reorgCh)
+	rewindSub := s.blockchain.SubscribeChainRewindEvent(rewindCh)
+	head := s.blockchain.CurrentBlock().NumberU64()
+
+	go func() {
+		defer headSub.Unsubscribe()
+		defer reorgSub.Unsubscribe()
+		defer rewindSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-headCh:
+				if number := ev.Block.NumberU64(); number < head {
+					s.cachePurgeFeed.Send(struct{}{})
+				}
+				head = ev.Block.NumberU64()
+
+			case ev := <-reorgCh:
+				// The oldest dropped block had len(Dropped)-1 blocks on top
+				if uint64(len(ev.Dropped)) > rpcCacheConfirmations {
+					s.cachePurgeFeed.Send(struct{}{})
+				}
+
+			case ev := <-rewindCh:
+				head = ev.Block.NumberU64()
+				s.cachePurgeFeed.Send(struct{}{})
+
+			case <-headSub.Err():
+				return
+			}
+		}
+	}()
+}
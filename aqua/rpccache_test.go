@@ -0,0 +1,98 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/downloader"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/internal/aquaapi"
+	"gitlab.com/aquachain/aquachain/rpc"
+	rpcclient "gitlab.com/aquachain/aquachain/rpc/rpcclient"
+)
+
+// Tests that the RPC results cached about final blocks are not served anymore
+// once the chain is rewound below them.
+func TestCachePurgeOnSetHead(t *testing.T) {
+	pm, db, err := newTestProtocolManager(downloader.FullSync, rpcCacheConfirmations+8, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	defer pm.Stop()
+
+	aqua := &AquaChain{chainConfig: pm.blockchain.Config(), chainDb: db, blockchain: pm.blockchain}
+	aqua.ApiBackend = &AquaApiBackend{aqua: aqua}
+	aqua.startCachePurger()
+
+	cache, err := rpc.NewResponseCache(16, aqua.CachePolicies())
+	if err != nil {
+		t.Fatal(err)
+	}
+	purgeCh := make(chan struct{}, 1)
+	sub := aqua.SubscribeCachePurge(purgeCh)
+	defer sub.Unsubscribe()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("aqua", aquaapi.NewPublicBlockChainAPI(aqua.ApiBackend)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetResponseCache(cache)
+	client := rpcclient.DialInProc(server)
+	defer client.Close()
+
+	type block struct{ Hash *common.Hash }
+	byHash := func(hash common.Hash) (result block) {
+		if err := client.Call(&result, "aqua_getBlockByHash", hash, false); err != nil {
+			t.Fatalf("failed to get block %x: %v", hash, err)
+		}
+		return result
+	}
+	byNumber := func(number uint64) (result block) {
+		if err := client.Call(&result, "aqua_getBlockByNumber", hexutil.Uint64(number), false); err != nil {
+			t.Fatalf("failed to get block %d: %v", number, err)
+		}
+		return result
+	}
+	// Cache a final block, under both its hash and its number
+	hash := pm.blockchain.GetBlockByNumber(5).Hash()
+	if have := byHash(hash).Hash; have == nil || *have != hash {
+		t.Fatalf("block by hash mismatch: have %v, want %x", have, hash)
+	}
+	if have := byNumber(5).Hash; have == nil || *have != hash {
+		t.Fatalf("block by number mismatch: have %v, want %x", have, hash)
+	}
+	// Rewind below it and check that the block is gone
+	if err := aqua.ApiBackend.SetHead(3); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if have := byNumber(5).Hash; have != nil {
+		t.Errorf("rewound block served by number before purge: %x", *have)
+	}
+	select {
+	case <-purgeCh:
+		cache.Purge()
+	case <-time.After(time.Second):
+		t.Fatal("cache purge not requested on rewind")
+	}
+	if have := byHash(hash).Hash; have != nil {
+		t.Errorf("rewound block served by hash after purge: %x", *have)
+	}
+}
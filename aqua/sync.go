@@ -31,6 +31,7 @@ import (
 const (
 	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
 	minDesiredPeerCount = 5                // Amount of peers desired to start syncing
+	maxFastSyncFailures = 5                // Failed fast sync cycles in a row before falling back to full sync
 
 	// This is the target size for the packs of transactions sent by txsyncLoop.
 	// A pack can get larger than this if a single transactions exceeds this size.
@@ -47,7 +48,12 @@ func (pm *ProtocolManager) syncTransactions(p *peer) {
 	var txs types.Transactions
 	pending, _ := pm.txpool.Pending()
 	for _, batch := range pending {
-		txs = append(txs, batch...)
+		for _, tx := range batch {
+			// Conditional transactions are only ever included by the local miner
+			if pm.txpool.Conditions(tx.Hash()) == nil {
+				txs = append(txs, tx)
+			}
+		}
 	}
 	if len(txs) == 0 {
 		return
@@ -187,7 +193,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		// Fast sync was explicitly requested, and explicitly granted
 		mode = downloader.FastSync
-	} else if currentBlock.NumberU64() == 0 && pm.blockchain.CurrentFastBlock().NumberU64() > 0 {
+	} else if currentBlock.NumberU64() == 0 && pm.blockchain.CurrentFastBlock().NumberU64() > 0 && atomic.LoadUint32(&pm.fastSyncAborted) == 0 {
 		// The database seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
 		// The only scenario where this can happen is if the user manually (or via a
@@ -206,8 +212,12 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 
 	// Run the sync cycle, and disable fast sync if we've went past the pivot block
 	if err := pm.downloader.Synchronise(peer.id, pHead, pTd, mode); err != nil {
+		if mode == downloader.FastSync && err != downloader.ErrBusy {
+			pm.fastSyncFailed(err)
+		}
 		return
 	}
+	atomic.StoreUint32(&pm.fastSyncFailures, 0)
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		log.Info("Fast sync complete, auto disabling")
 		atomic.StoreUint32(&pm.fastSync, 0)
@@ -223,3 +233,17 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		go pm.BroadcastBlock(head, false)
 	}
 }
+
+// fastSyncFailed records a failed fast sync cycle. Once fast sync has failed
+// too often in a row, e.g. because no peer serves the state of the pivot block
+// anymore, it is abandoned and the chain is synchronised by importing all
+// blocks instead, starting from the blocks already downloaded.
+func (pm *ProtocolManager) fastSyncFailed(err error) {
+	failures := atomic.AddUint32(&pm.fastSyncFailures, 1)
+	if failures < maxFastSyncFailures || atomic.LoadUint32(&pm.fastSync) == 0 {
+		return
+	}
+	log.Warn("Fast sync keeps failing, falling back to full sync", "failures", failures, "err", err)
+	atomic.StoreUint32(&pm.fastSyncAborted, 1)
+	atomic.StoreUint32(&pm.fastSync, 0)
+}
@@ -17,6 +17,7 @@
 package aqua
 
 import (
+	"errors"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -53,3 +54,24 @@ func TestFastSyncDisabling(t *testing.T) {
 		t.Fatalf("fast sync not disabled after successful synchronisation")
 	}
 }
+
+// Tests that fast sync falls back to full sync after failing repeatedly, and
+// isn't re-enabled by the partially fast synced chain.
+func TestFastSyncFallback(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FastSync, 0, nil, nil)
+	defer pm.Stop()
+
+	for i := 1; i < maxFastSyncFailures; i++ {
+		pm.fastSyncFailed(errors.New("state sync failed"))
+		if atomic.LoadUint32(&pm.fastSync) == 0 {
+			t.Fatalf("fast sync disabled after %d failures", i)
+		}
+	}
+	pm.fastSyncFailed(errors.New("state sync failed"))
+	if atomic.LoadUint32(&pm.fastSync) == 1 {
+		t.Fatalf("fast sync not disabled after %d failures", maxFastSyncFailures)
+	}
+	if atomic.LoadUint32(&pm.fastSyncAborted) == 0 {
+		t.Fatalf("fast sync may be re-enabled after falling back to full sync")
+	}
+}
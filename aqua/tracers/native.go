@@ -0,0 +1,162 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/vm"
+)
+
+// ResultTracer is a vm.Tracer assembling a result once the traced execution is
+// done, which can be stopped early. Both the JavaScript and the native tracers
+// implement it.
+type ResultTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace.
+	GetResult() (json.RawMessage, error)
+
+	// Stop ends tracing at the first opportunity, failing the result with err.
+	Stop(err error)
+}
+
+// natives contains the constructors of the tracers implemented in Go by name.
+// They mirror the output of the JavaScript tracers of the same name, but run
+// much faster and don't need cgo.
+var natives = map[string]func() ResultTracer{
+	"callTracer":  func() ResultTracer { return newCallTracer() },
+	"4byteTracer": func() ResultTracer { return newFourByteTracer() },
+}
+
+// NewNative returns the native tracer with the given name, if there's one.
+func NewNative(name string) (ResultTracer, bool) {
+	ctor, ok := natives[name]
+	if !ok {
+		return nil, false
+	}
+	return ctor(), true
+}
+
+// nativeStopper implements Stop for the native tracers.
+type nativeStopper struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Reason for the interruption
+}
+
+func (s *nativeStopper) Stop(err error) {
+	s.reason = err
+	atomic.StoreUint32(&s.interrupt, 1)
+}
+
+func (s *nativeStopper) stopped() bool {
+	return atomic.LoadUint32(&s.interrupt) > 0
+}
+
+// peekStack returns the nth item from the top of the stack, or zero if the
+// stack isn't that deep.
+func peekStack(stack *vm.Stack, n int) *big.Int {
+	data := stack.Data()
+	if len(data) <= n {
+		return new(big.Int)
+	}
+	return data[len(data)-n-1]
+}
+
+// sliceMemory returns a copy of memory[begin:end], or nil if out of bounds.
+func sliceMemory(memory *vm.Memory, begin, end int64) []byte {
+	if end < begin || int64(memory.Len()) < end {
+		return nil
+	}
+	return memory.Get(begin, end-begin)
+}
+
+func isPrecompiled(addr common.Address) bool {
+	_, ok := vm.PrecompiledContractsByzantium[addr]
+	return ok
+}
+
+// fourByteTracer collects the 4 byte method identifiers of all calls along with
+// the size of their arguments, so that reversed signatures can be matched
+// against the size of the data. It's the native version of 4byte_tracer.js.
+type fourByteTracer struct {
+	nativeStopper
+	ids   map[string]int
+	input []byte
+}
+
+func newFourByteTracer() *fourByteTracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+func (t *fourByteTracer) store(id []byte, size int64) {
+	t.ids[hexutil.Encode(id)+"-"+big.NewInt(size).String()]++
+}
+
+func (t *fourByteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.input = common.CopyBytes(input)
+	return nil
+}
+
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.stopped() {
+		return nil
+	}
+	// Skip any opcodes that are not internal calls, and find the input offset
+	var in int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		in = 3
+	case vm.DELEGATECALL, vm.STATICCALL:
+		in = 2
+	default:
+		return nil
+	}
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	if isPrecompiled(common.BigToAddress(peekStack(stack, 1))) {
+		return nil
+	}
+	if size := peekStack(stack, in+1).Int64(); size >= 4 {
+		offset := peekStack(stack, in).Int64()
+		t.store(sliceMemory(memory, offset, offset+4), size-4)
+	}
+	return nil
+}
+
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	// Save the outer calldata also
+	if len(t.input) >= 4 {
+		t.store(t.input[:4], int64(len(t.input)-4))
+	}
+	return json.Marshal(t.ids)
+}
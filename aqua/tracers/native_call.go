@@ -0,0 +1,258 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/vm"
+)
+
+// callFrame is a single call reported by the call tracer. Fields are in the
+// order of the JavaScript tracer's output, absent ones are left out.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    *common.Address `json:"from,omitempty"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   *hexutil.Bytes  `json:"input,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    string          `json:"time,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // Gas available when the call was made
+	gasCost uint64 // Cost of the calling opcode
+	outOff  int64  // Memory offset of the call's return data
+	outLen  int64  // Size of the call's return data
+}
+
+// callTracer extracts and reports all the internal calls made by a transaction,
+// along with any useful information. It's the native version of call_tracer.js,
+// producing the same output.
+type callTracer struct {
+	nativeStopper
+
+	callstack []*callFrame // Current recursive call stack of the EVM execution
+	descended bool         // Whether an inner call was just entered
+
+	ctx    callFrame // The outermost call
+	ctxErr error     // Error the outermost call ended with
+}
+
+func newCallTracer() *callTracer {
+	return &callTracer{callstack: []*callFrame{{}}}
+}
+
+func (t *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.ctx.Type = "CALL"
+	if create {
+		t.ctx.Type = "CREATE"
+	}
+	t.ctx.From, t.ctx.To = &from, &to
+	t.ctx.Input = bytesPtr(input)
+	t.ctx.Gas = uint64Ptr(gas)
+	if value == nil {
+		value = new(big.Int)
+	}
+	t.ctx.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	return nil
+}
+
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.stopped() {
+		return nil
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	switch op {
+	case vm.CREATE:
+		// A new contract is being created, add to the call stack
+		inOff := peekStack(stack, 1).Int64()
+		inEnd := inOff + peekStack(stack, 2).Int64()
+
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    addressPtr(contract.Address()),
+			Input:   bytesPtr(sliceMemory(memory, inOff, inEnd)),
+			Value:   (*hexutil.Big)(new(big.Int).Set(peekStack(stack, 0))),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		// A contract is being self destructed, gather that as a subcall too
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, &callFrame{Type: op.String()})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// A new method invocation is being done, add to the call stack. Skip
+		// any pre-compile invocations, those are just fancy opcodes.
+		to := common.BigToAddress(peekStack(stack, 1))
+		if isPrecompiled(to) {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		inOff := peekStack(stack, 2+off).Int64()
+		inEnd := inOff + peekStack(stack, 3+off).Int64()
+
+		call := &callFrame{
+			Type:    op.String(),
+			From:    addressPtr(contract.Address()),
+			To:      &to,
+			Input:   bytesPtr(sliceMemory(memory, inOff, inEnd)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  peekStack(stack, 4+off).Int64(),
+			outLen:  peekStack(stack, 5+off).Int64(),
+		}
+		if op == vm.CALL || op == vm.CALLCODE {
+			call.Value = (*hexutil.Big)(new(big.Int).Set(peekStack(stack, 2)))
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return nil
+	}
+	// If we've just descended into an inner call, retrieve its true allowance,
+	// as there may be funky gas dynamics with regard to requested and actually
+	// given gas (2300 stipend, 63/64 rule). Calls to plain accounts don't run
+	// any code, their gas is left out.
+	if t.descended {
+		if depth >= len(t.callstack) {
+			t.callstack[len(t.callstack)-1].Gas = uint64Ptr(gas)
+		}
+		t.descended = false
+	}
+	if op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return nil
+	}
+	if depth != len(t.callstack)-1 {
+		return nil
+	}
+	// An existing call is returning, pop it off the call stack
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	ret := peekStack(stack, 0)
+	if call.Type == vm.CREATE.String() {
+		// Retrieve the contract address and code of a creation
+		call.GasUsed = uint64Ptr(call.gasIn - call.gasCost - gas)
+		if ret.Sign() != 0 {
+			addr := common.BigToAddress(ret)
+			call.To = &addr
+			call.Output = bytesPtr(env.StateDB.GetCode(addr))
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	} else if call.Gas != nil {
+		// Retrieve the gas usage and output of a contract call
+		call.GasUsed = uint64Ptr(call.gasIn - call.gasCost + uint64(*call.Gas) - gas)
+		if ret.Sign() != 0 {
+			call.Output = bytesPtr(sliceMemory(memory, call.outOff, call.outOff+call.outLen))
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	}
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, call)
+	return nil
+}
+
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if !t.stopped() {
+		t.fault(err)
+	}
+	return nil
+}
+
+// fault handles the failure of the innermost call.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	// Pop off the just failed call, consuming all its gas
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	call.Error = err.Error()
+	if call.Gas != nil {
+		call.GasUsed = uint64Ptr(uint64(*call.Gas))
+	}
+	// Flatten the failed call into its parent, unless it's the last one left
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	t.callstack = append(t.callstack, call)
+}
+
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.ctx.Output = bytesPtr(output)
+	t.ctx.GasUsed = uint64Ptr(gasUsed)
+	t.ctx.Time = d.String()
+	t.ctxErr = err
+	return nil
+}
+
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	result := t.ctx
+	result.Calls = t.callstack[0].Calls
+	if err := t.callstack[0].Error; err != "" {
+		result.Error = err
+	} else if t.ctxErr != nil {
+		result.Error = t.ctxErr.Error()
+	}
+	if result.Error != "" {
+		result.Output = nil
+	}
+	return json.Marshal(&result)
+}
+
+func addressPtr(addr common.Address) *common.Address {
+	return &addr
+}
+
+func bytesPtr(b []byte) *hexutil.Bytes {
+	enc := hexutil.Bytes(common.CopyBytes(b))
+	return &enc
+}
+
+func uint64Ptr(n uint64) *hexutil.Uint64 {
+	enc := hexutil.Uint64(n)
+	return &enc
+}
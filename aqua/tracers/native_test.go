@@ -0,0 +1,112 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+var (
+	nativeTestCaller = common.HexToAddress("0x1000")
+	nativeTestOuter  = common.HexToAddress("0xaa")
+	nativeTestInner  = common.HexToAddress("0xbb")
+)
+
+// runNativeTestCall traces a call to a contract calling another one with the
+// method id 0x12345678, which returns 42.
+func runNativeTestCall(t *testing.T, tracer ResultTracer) json.RawMessage {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(aquadb.NewMemDatabase()))
+	statedb.SetCode(nativeTestOuter, common.FromHex(
+		"6312345678600052602060006004601c600060bb61fffff1"+ // mstore(0, 0x12345678); call(0xffff, 0xbb, 0, 28, 4, 0, 32)
+			"5060206000f3")) // pop; return(0, 32)
+	statedb.SetCode(nativeTestInner, common.FromHex("602a60005260206000f3")) // mstore(0, 42); return(0, 32)
+
+	vmctx := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      nativeTestCaller,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		GasLimit:    1000000,
+		GasPrice:    big.NewInt(1),
+	}
+	evm := vm.NewEVM(vmctx, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, err := evm.Call(vm.AccountRef(nativeTestCaller), nativeTestOuter, common.FromHex("0xdeadbeef"), 100000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+func TestNativeCallTracer(t *testing.T) {
+	tracer, ok := NewNative("callTracer")
+	if !ok {
+		t.Fatal("native call tracer missing")
+	}
+	res := new(callFrame)
+	if err := json.Unmarshal(runNativeTestCall(t, tracer), res); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	if res.Type != "CALL" || *res.From != nativeTestCaller || *res.To != nativeTestOuter || res.Error != "" {
+		t.Fatalf("outer call mismatch: %+v", res)
+	}
+	if len(res.Calls) != 1 {
+		t.Fatalf("inner calls mismatch: have %d, want 1", len(res.Calls))
+	}
+	inner := res.Calls[0]
+	if inner.Type != "CALL" || *inner.From != nativeTestOuter || *inner.To != nativeTestInner {
+		t.Errorf("inner call mismatch: %+v", inner)
+	}
+	if have, want := *inner.Input, hexutil.Bytes(common.FromHex("0x12345678")); !reflect.DeepEqual(have, want) {
+		t.Errorf("inner call input mismatch: have %x, want %x", have, want)
+	}
+	if have := new(big.Int).SetBytes(*inner.Output); have.Int64() != 42 {
+		t.Errorf("inner call output mismatch: have %v, want 42", have)
+	}
+	if inner.Gas == nil || inner.GasUsed == nil || *inner.GasUsed == 0 || *inner.GasUsed > *inner.Gas {
+		t.Errorf("inner call gas mismatch: gas %v, used %v", inner.Gas, inner.GasUsed)
+	}
+}
+
+func TestNative4ByteTracer(t *testing.T) {
+	tracer, ok := NewNative("4byteTracer")
+	if !ok {
+		t.Fatal("native 4byte tracer missing")
+	}
+	var res map[string]int
+	if err := json.Unmarshal(runNativeTestCall(t, tracer), &res); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	if want := map[string]int{"0x12345678-0": 1, "0xdeadbeef-0": 1}; !reflect.DeepEqual(res, want) {
+		t.Errorf("ids mismatch: have %v, want %v", res, want)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"math/big"
+	"reflect"
 	"testing"
 	"time"
 
@@ -140,3 +141,30 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+// Tests that the native tracers produce the same output as the JavaScript ones
+// they replace.
+func TestNativeTracersMatchJavaScript(t *testing.T) {
+	for name := range natives {
+		jst, err := New(name)
+		if err != nil {
+			t.Fatalf("failed to create JavaScript %s: %v", name, err)
+		}
+		native, _ := NewNative(name)
+
+		var want, have map[string]interface{}
+		if err := json.Unmarshal(runNativeTestCall(t, jst), &want); err != nil {
+			t.Fatalf("%s: failed to unmarshal JavaScript result: %v", name, err)
+		}
+		if err := json.Unmarshal(runNativeTestCall(t, native), &have); err != nil {
+			t.Fatalf("%s: failed to unmarshal native result: %v", name, err)
+		}
+		// Execution times naturally differ
+		delete(want, "time")
+		delete(have, "time")
+
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: result mismatch:\nhave %v\nwant %v", name, have, want)
+		}
+	}
+}
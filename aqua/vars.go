@@ -0,0 +1,89 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aqua
+
+import (
+	"expvar"
+	"sync"
+)
+
+// chainVars tracks the AquaChain service reported in the "chain" and "txpool"
+// expvars, which stay cheap enough to be always on. Only one service per
+// process is published, the last started.
+var chainVars struct {
+	once sync.Once
+	lock sync.RWMutex
+	aqua *AquaChain
+}
+
+// publishVars makes the given service the one reported in the expvars.
+func publishVars(s *AquaChain) {
+	chainVars.lock.Lock()
+	chainVars.aqua = s
+	chainVars.lock.Unlock()
+
+	chainVars.once.Do(func() {
+		expvar.Publish("chain", expvar.Func(readChainVars))
+		expvar.Publish("txpool", expvar.Func(readTxPoolVars))
+	})
+}
+
+// unpublishVars stops reporting the given service.
+func unpublishVars(s *AquaChain) {
+	chainVars.lock.Lock()
+	defer chainVars.lock.Unlock()
+
+	if chainVars.aqua == s {
+		chainVars.aqua = nil
+	}
+}
+
+func readChainVars() interface{} {
+	chainVars.lock.RLock()
+	defer chainVars.lock.RUnlock()
+
+	if chainVars.aqua == nil {
+		return nil
+	}
+	head := chainVars.aqua.blockchain.CurrentBlock()
+	vars := map[string]interface{}{
+		"number":    head.NumberU64(),
+		"hash":      head.Hash(),
+		"timestamp": head.Time(),
+		"networkId": chainVars.aqua.networkId,
+	}
+	if downloader := chainVars.aqua.Downloader(); downloader != nil {
+		progress := downloader.Progress()
+		vars["highestBlock"] = progress.HighestBlock
+		vars["syncing"] = progress.HighestBlock > head.NumberU64()
+	}
+	return vars
+}
+
+func readTxPoolVars() interface{} {
+	chainVars.lock.RLock()
+	defer chainVars.lock.RUnlock()
+
+	if chainVars.aqua == nil {
+		return nil
+	}
+	pending, queued := chainVars.aqua.txPool.Stats()
+	return map[string]int{
+		"pending": pending,
+		"queued":  queued,
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/rlp"
+)
+
+// backupMagic identifies a database backup stream.
+const backupMagic = "aquadb-backup-v1"
+
+// backupRestoreSuffix is appended to a database path to form the marker file
+// requesting a restore on the next open.
+const backupRestoreSuffix = ".restore"
+
+// Backup writes a consistent point-in-time copy of the database into w. The
+// stream is a gzip compressed sequence of RLP encoded key/value pairs. Values
+// are copied raw, so backups of encrypted databases stay encrypted.
+func (db *LDBDatabase) Backup(w io.Writer) (int, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer snap.Release()
+
+	zw := gzip.NewWriter(w)
+	if err := rlp.Encode(zw, backupMagic); err != nil {
+		return 0, err
+	}
+	it := snap.NewIterator(nil, nil)
+	defer it.Release()
+
+	count := 0
+	for it.Next() {
+		if err := rlp.Encode(zw, [][]byte{it.Key(), it.Value()}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := it.Error(); err != nil {
+		return count, err
+	}
+	return count, zw.Close()
+}
+
+// RestoreBackup recreates the database at path from a backup stream written by
+// Backup, replacing any existing database there. The database must not be open.
+func RestoreBackup(path string, r io.Reader) (int, error) {
+	zr, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return 0, err
+	}
+	stream := rlp.NewStream(zr, 0)
+
+	var magic string
+	if err := stream.Decode(&magic); err != nil || magic != backupMagic {
+		return 0, errors.New("not a database backup")
+	}
+	// Restore into a scratch location, only replacing the live database once
+	// the whole backup was imported successfully.
+	tmp := path + ".restoring"
+	os.RemoveAll(tmp)
+	ldb, err := leveldb.OpenFile(tmp, nil)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		batch = new(leveldb.Batch)
+		count = 0
+	)
+	for {
+		var kv [][]byte
+		if err = stream.Decode(&kv); err != nil {
+			break
+		}
+		if len(kv) != 2 {
+			err = fmt.Errorf("invalid backup entry #%d", count)
+			break
+		}
+		batch.Put(kv[0], kv[1])
+		if batch.Len() >= 1024 {
+			if err = ldb.Write(batch, nil); err != nil {
+				break
+			}
+			batch.Reset()
+		}
+		count++
+	}
+	if err == io.EOF {
+		err = ldb.Write(batch, nil)
+	}
+	if cerr := ldb.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return count, err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return count, err
+	}
+	return count, os.Rename(tmp, path)
+}
+
+// ScheduleRestore requests the database at path to be replaced by the given
+// backup file the next time it is opened via ApplyPendingRestore.
+func ScheduleRestore(path, backup string) error {
+	if _, err := os.Stat(backup); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+backupRestoreSuffix, []byte(backup), 0600)
+}
+
+// ApplyPendingRestore restores the database at path from a backup, if one was
+// scheduled via ScheduleRestore. The database must not be open.
+func ApplyPendingRestore(path string) error {
+	marker := path + backupRestoreSuffix
+	blob, err := ioutil.ReadFile(marker)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	backup := strings.TrimSpace(string(blob))
+	f, err := os.Open(backup)
+	if err != nil {
+		return fmt.Errorf("scheduled database restore failed: %v", err)
+	}
+	defer f.Close()
+
+	log.Warn("Restoring database from backup", "database", path, "backup", backup)
+	count, err := RestoreBackup(path, f)
+	if err != nil {
+		return fmt.Errorf("scheduled database restore failed: %v", err)
+	}
+	log.Info("Restored database from backup", "database", path, "entries", count)
+	return os.Remove(marker)
+}
@@ -0,0 +1,279 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build badger
+
+package aquadb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/options"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+const (
+	// badgerValueThreshold is the size above which values are moved out of the
+	// LSM tree into the value log. Trie nodes stay below it, keeping state reads
+	// to a single lookup, while block bodies and receipts go to the value log.
+	badgerValueThreshold = 1024
+
+	// badgerValueLogFileSize is the size of the value log files. Smaller files
+	// than Badger's default let the garbage collector reclaim space sooner, as
+	// chain data is rarely overwritten but pruned state is deleted in bulk.
+	badgerValueLogFileSize = 256 << 20
+
+	// badgerGCInterval is the time between two value log garbage collections.
+	badgerGCInterval = 5 * time.Minute
+
+	// badgerGCDiscardRatio is the share of stale data in a value log file
+	// required for the garbage collector to rewrite it.
+	badgerGCDiscardRatio = 0.5
+)
+
+// badgerKeyPrefix is prepended to all keys stored in Badger.
+var badgerKeyPrefix = []byte("a")
+
+func init() {
+	engines[EngineBadger] = func(file string, cache int, handles int) (Database, error) {
+		return NewBadgerDatabase(file, cache, handles)
+	}
+}
+
+// BadgerDatabase is a persistent database backed by BadgerDB, which keeps
+// large values out of its LSM tree, lowering write amplification on SSDs.
+type BadgerDatabase struct {
+	fn string     // filename for reporting
+	db *badger.DB // BadgerDB instance
+
+	quit chan struct{}  // Channel to stop the value log garbage collector
+	wg   sync.WaitGroup // Wait group for the garbage collector to terminate
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewBadgerDatabase returns a BadgerDB wrapped object. Badger manages its file
+// handles itself, the cache allowance sizes the in-memory tables.
+func NewBadgerDatabase(file string, cache int, handles int) (*BadgerDatabase, error) {
+	logger := log.New("database", file)
+
+	// Ensure we have some minimal caching
+	if cache < 16 {
+		cache = 16
+	}
+	logger.Info("Allocated cache", "engine", EngineBadger, "cache", cache)
+
+	opts := badger.DefaultOptions
+	opts.Dir, opts.ValueDir = file, file
+	opts.NumVersionsToKeep = 1
+	opts.MaxTableSize = int64(cache/4) << 20
+	opts.ValueThreshold = badgerValueThreshold
+	opts.ValueLogFileSize = badgerValueLogFileSize
+	opts.ValueLogLoadingMode = options.FileIO
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	bdb := &BadgerDatabase{
+		fn:   file,
+		db:   db,
+		quit: make(chan struct{}),
+		log:  logger,
+	}
+	bdb.wg.Add(1)
+	go bdb.collectGarbage()
+
+	return bdb, nil
+}
+
+// collectGarbage periodically rewrites value log files consisting mostly of
+// deleted or overwritten values.
+func (db *BadgerDatabase) collectGarbage() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(badgerGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Keep collecting as long as files get rewritten
+			rewritten := 0
+			for db.db.RunValueLogGC(badgerGCDiscardRatio) == nil {
+				rewritten++
+			}
+			if rewritten > 0 {
+				db.log.Debug("Collected value log garbage", "files", rewritten)
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Path returns the path to the database directory.
+func (db *BadgerDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the queue
+func (db *BadgerDatabase) Put(key []byte, value []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(badgerKey(key), copyBytes(value))
+	})
+}
+
+func (db *BadgerDatabase) Has(key []byte) (bool, error) {
+	err := db.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(badgerKey(key))
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the given key if it's present.
+func (db *BadgerDatabase) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(badgerKey(key))
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	return value, err
+}
+
+// Delete deletes the key from the queue and database
+func (db *BadgerDatabase) Delete(key []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(badgerKey(key))
+	})
+}
+
+// Walk implements Walker.
+func (db *BadgerDatabase) Walk(fn func(key, value []byte) error) error {
+	return db.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(badgerKeyPrefix); it.ValidForPrefix(badgerKeyPrefix); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(item.Key()[len(badgerKeyPrefix):], value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *BadgerDatabase) Close() {
+	close(db.quit)
+	db.wg.Wait()
+
+	if err := db.db.Close(); err == nil {
+		db.log.Info("Database closed")
+	} else {
+		db.log.Error("Failed to close database", "err", err)
+	}
+}
+
+func (db *BadgerDatabase) NewBatch() Batch {
+	return &badgerBatch{db: db.db}
+}
+
+// badgerBatch collects writes to commit them in as few transactions as Badger
+// allows. Badger retains the passed slices until they are committed, so they
+// are copied.
+type badgerBatch struct {
+	db   *badger.DB
+	ops  []bufferedOp
+	size int
+}
+
+func (b *badgerBatch) Put(key, value []byte) error {
+	b.ops = append(b.ops, bufferedOp{key: badgerKey(key), value: copyBytes(value)})
+	b.size += len(value)
+	return nil
+}
+
+func (b *badgerBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, bufferedOp{key: badgerKey(key)})
+	b.size++
+	return nil
+}
+
+// Write commits the batch, splitting it over several transactions if it's too
+// large for a single one.
+func (b *badgerBatch) Write() error {
+	txn := b.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+
+	for _, op := range b.ops {
+		err := applyBadgerOp(txn, op)
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(nil); err != nil {
+				return err
+			}
+			txn = b.db.NewTransaction(true)
+			err = applyBadgerOp(txn, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit(nil)
+}
+
+func (b *badgerBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *badgerBatch) Reset() {
+	b.ops = b.ops[:0]
+	b.size = 0
+}
+
+// applyBadgerOp adds a batched put or delete to a transaction.
+func applyBadgerOp(txn *badger.Txn, op bufferedOp) error {
+	if op.value == nil {
+		return txn.Delete(op.key)
+	}
+	return txn.Set(op.key, op.value)
+}
+
+// badgerKey returns the prefixed copy of a key under which it is stored, as
+// Badger rejects empty keys.
+func badgerKey(key []byte) []byte {
+	return append(append([]byte{}, badgerKeyPrefix...), key...)
+}
+
+// copyBytes returns a copy of a byte slice, as Badger keeps references to keys
+// and values until they are committed.
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
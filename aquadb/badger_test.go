@@ -0,0 +1,101 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build badger
+
+package aquadb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+func newTestBadger() (*aquadb.BadgerDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "aquadb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := aquadb.NewBadgerDatabase(dirname, 0, 0)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+func TestBadger_PutGet(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+	testPutGet(db, t)
+}
+
+func TestBadger_ParallelPutGet(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
+func TestBadger_Batch(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+
+	batch := db.NewBatch()
+	for _, v := range test_values {
+		batch.Put([]byte("key"+v), []byte(v))
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for _, v := range test_values {
+		data, err := db.Get([]byte("key" + v))
+		if err != nil {
+			t.Fatalf("get failed for %q: %v", v, err)
+		}
+		if string(data) != v {
+			t.Fatalf("get returned wrong result, got %q expected %q", string(data), v)
+		}
+	}
+	if engine := aquadb.DetectEngine(db.Path()); engine != aquadb.EngineBadger {
+		t.Fatalf("engine detection mismatch: have %q, want %q", engine, aquadb.EngineBadger)
+	}
+}
+
+func TestBadger_LargeBatch(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+
+	// Write more entries than fit into a single Badger transaction
+	batch := db.NewBatch()
+	for i := 0; i < 100000; i++ {
+		batch.Put([]byte{byte(i >> 16), byte(i >> 8), byte(i)}, []byte{byte(i)})
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	count := 0
+	db.Walk(func(key, value []byte) error {
+		count++
+		return nil
+	})
+	if count != 100000 {
+		t.Fatalf("entry count mismatch: have %d, want 100000", count)
+	}
+}
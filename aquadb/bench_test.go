@@ -0,0 +1,117 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+// benchImportBatch is the amount of data flushed at once, mirroring the
+// ideal batch size used by the chain importer.
+const benchImportBatch = 100 * 1024
+
+// benchEntries generates random trie-node like entries: 32 byte hash keys with
+// values between 100 and 500 bytes.
+func benchEntries(n int) (keys, values [][]byte) {
+	rng := rand.New(rand.NewSource(1))
+	keys = make([][]byte, n)
+	values = make([][]byte, n)
+	for i := 0; i < n; i++ {
+		keys[i] = make([]byte, 32)
+		rng.Read(keys[i])
+		values[i] = make([]byte, 100+rng.Intn(400))
+		rng.Read(values[i])
+	}
+	return keys, values
+}
+
+// benchEngines runs the given benchmark against a fresh database of every
+// engine compiled into the binary.
+func benchEngines(b *testing.B, bench func(b *testing.B, db aquadb.Database)) {
+	for _, engine := range aquadb.Engines() {
+		b.Run(engine, func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "aquadb_bench_")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			db, err := aquadb.Open(engine, filepath.Join(dir, "chaindata"), 128, 256)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			bench(b, db)
+		})
+	}
+}
+
+// BenchmarkImportWrite measures batched insertion of random entries, as done
+// when importing blocks and committing their state.
+func BenchmarkImportWrite(b *testing.B) {
+	benchEngines(b, func(b *testing.B, db aquadb.Database) {
+		keys, values := benchEntries(b.N)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		batch := db.NewBatch()
+		for i := 0; i < b.N; i++ {
+			batch.Put(keys[i], values[i])
+			if batch.ValueSize() >= benchImportBatch {
+				if err := batch.Write(); err != nil {
+					b.Fatal(err)
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+// BenchmarkImportRead measures random lookups of previously imported entries,
+// as done when executing transactions against the imported state.
+func BenchmarkImportRead(b *testing.B) {
+	const entries = 10000
+
+	keys, values := benchEntries(entries)
+	benchEngines(b, func(b *testing.B, db aquadb.Database) {
+		batch := db.NewBatch()
+		for i := range keys {
+			batch.Put(keys[i], values[i])
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := db.Get(keys[i%entries]); err != nil {
+				b.Fatalf("entry %d: %v", i%entries, err)
+			}
+		}
+	})
+}
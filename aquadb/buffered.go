@@ -0,0 +1,485 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+// bufferedFlushInterval is the longest time writes stay buffered in memory.
+const bufferedFlushInterval = 5 * time.Second
+
+var errJournalCorrupt = errors.New("corrupt journal record")
+
+// BufferedDatabase batches the writes to another database in memory, flushing
+// them in large batches from the background instead of writing them one at a
+// time. Reads are served from the buffered writes first, so buffering is
+// invisible to the users of the database.
+//
+// Every write is appended to a journal file before being acknowledged, which is
+// replayed into the database when it's opened again after a crash. Batches are
+// journaled as a single record, so they stay atomic across crashes. The journal
+// is only synced to disk when its buffer is handed over for flushing, so it
+// survives crashes of the process, but the latest writes may be lost if the
+// machine goes down, like the unsynced writes of leveldb itself.
+//
+// Writes are flushed once limit bytes are buffered, or every few seconds. While
+// a flush is in progress, a second buffer of up to limit bytes takes the new
+// writes; writers beyond that wait for the flush to complete.
+type BufferedDatabase struct {
+	db      Database
+	journal string // Path of the journal of the active buffer
+	limit   int    // Buffered bytes triggering a flush
+
+	lock     sync.RWMutex
+	cond     *sync.Cond   // Wakes writers waiting for a flush to complete
+	active   *writeBuffer // Writes since the last rotation
+	flushing *writeBuffer // Writes being flushed, nil if none
+	file     *os.File     // Journal of the active buffer
+
+	flushLock sync.Mutex // Serializes flushes
+
+	kick chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// bufferedOp is a buffered put, or a delete if value is nil.
+type bufferedOp struct {
+	key, value []byte
+}
+
+// writeBuffer holds the latest buffered value of keys, nil for deleted ones.
+type writeBuffer struct {
+	values map[string][]byte
+	size   int
+}
+
+func newWriteBuffer() *writeBuffer {
+	return &writeBuffer{values: make(map[string][]byte)}
+}
+
+func (b *writeBuffer) apply(ops []bufferedOp) {
+	for _, op := range ops {
+		b.values[string(op.key)] = op.value
+		b.size += len(op.key) + len(op.value)
+	}
+}
+
+// NewBufferedDatabase wraps db with a write buffer of limit bytes, journaled
+// into the file at journal. Writes left over in the journal by a crash are
+// replayed into db first.
+func NewBufferedDatabase(db Database, journal string, limit int) (*BufferedDatabase, error) {
+	// Recover the writes of an unclean shutdown, oldest buffer first
+	for _, path := range []string{journal + ".flush", journal} {
+		if err := replayJournal(db, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Remove(journal + ".flush"); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(journal, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	bdb := &BufferedDatabase{
+		db:      db,
+		journal: journal,
+		limit:   limit,
+		active:  newWriteBuffer(),
+		file:    file,
+		kick:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	bdb.cond = sync.NewCond(&bdb.lock)
+
+	bdb.wg.Add(1)
+	go bdb.loop()
+	return bdb, nil
+}
+
+// replayJournal writes the records of the journal at path into db, stopping at
+// the first incomplete record, which was being written at the time of a crash.
+func replayJournal(db Database, path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		r       = bufio.NewReader(file)
+		batch   = db.NewBatch()
+		records int
+	)
+	for {
+		ops, err := readJournalRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn("Dropping incomplete journal record", "journal", path, "records", records, "err", err)
+			break
+		}
+		for _, op := range ops {
+			if op.value == nil {
+				err = batch.Delete(op.key)
+			} else {
+				err = batch.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		records++
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if records > 0 {
+		log.Info("Replayed database write journal", "journal", path, "records", records)
+	}
+	return nil
+}
+
+// encodeJournalRecord encodes ops as a journal record: the length and CRC32 of
+// the payload, followed by the payload holding the key and value of every op.
+// Deletes are told apart from puts of empty values by a flag.
+func encodeJournalRecord(ops []bufferedOp) []byte {
+	payload := make([]byte, 0, 64)
+	for _, op := range ops {
+		if op.value == nil {
+			payload = append(payload, 0)
+		} else {
+			payload = append(payload, 1)
+		}
+		payload = appendUvarint(payload, uint64(len(op.key)))
+		payload = append(payload, op.key...)
+		payload = appendUvarint(payload, uint64(len(op.value)))
+		payload = append(payload, op.value...)
+	}
+	record := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	return append(record, payload...)
+}
+
+func appendUvarint(b []byte, n uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], n)]...)
+}
+
+// readJournalRecord decodes the next record of a journal.
+func readJournalRecord(r io.Reader) ([]bufferedOp, error) {
+	var head [8]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errJournalCorrupt
+		}
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(head[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errJournalCorrupt
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(head[4:]) {
+		return nil, errJournalCorrupt
+	}
+	var ops []bufferedOp
+	for len(payload) > 0 {
+		put := payload[0] == 1
+		payload = payload[1:]
+
+		var fields [2][]byte
+		for i := range fields {
+			size, n := binary.Uvarint(payload)
+			if n <= 0 || uint64(len(payload)-n) < size {
+				return nil, errJournalCorrupt
+			}
+			fields[i], payload = payload[n:n+int(size)], payload[n+int(size):]
+		}
+		op := bufferedOp{key: fields[0]}
+		if put {
+			op.value = append([]byte{}, fields[1]...)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// Unwrap implements Wrapper. The wrapped database misses the buffered writes,
+// direct users reading from it need to Flush first.
+func (db *BufferedDatabase) Unwrap() Database {
+	return db.db
+}
+
+// Put buffers the write of key.
+func (db *BufferedDatabase) Put(key []byte, value []byte) error {
+	return db.write([]bufferedOp{{key: common.CopyBytes(key), value: append([]byte{}, value...)}})
+}
+
+// Delete buffers the deletion of key.
+func (db *BufferedDatabase) Delete(key []byte) error {
+	return db.write([]bufferedOp{{key: common.CopyBytes(key)}})
+}
+
+// write journals and buffers ops, rotating the buffer for flushing once full.
+func (db *BufferedDatabase) write(ops []bufferedOp) error {
+	record := encodeJournalRecord(ops)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	for db.flushing != nil && db.active.size >= db.limit {
+		db.cond.Wait()
+	}
+	if _, err := db.file.Write(record); err != nil {
+		return err
+	}
+	db.active.apply(ops)
+
+	if db.flushing == nil && db.active.size >= db.limit {
+		if err := db.rotate(); err != nil {
+			return err
+		}
+		select {
+		case db.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// rotate hands the active buffer over for flushing, along with its journal.
+// The lock must be held and no flush pending.
+func (db *BufferedDatabase) rotate() error {
+	if err := db.file.Sync(); err != nil {
+		return err
+	}
+	if err := db.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(db.journal, db.journal+".flush"); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(db.journal, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	db.file = file
+	db.flushing, db.active = db.active, newWriteBuffer()
+	return nil
+}
+
+// lookup returns the buffered value of key, if buffered.
+func (db *BufferedDatabase) lookup(key []byte) ([]byte, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if value, ok := db.active.values[string(key)]; ok {
+		return value, true
+	}
+	if db.flushing != nil {
+		if value, ok := db.flushing.values[string(key)]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Get retrieves key, from the buffered writes if there.
+func (db *BufferedDatabase) Get(key []byte) ([]byte, error) {
+	if value, ok := db.lookup(key); ok {
+		if value == nil {
+			return nil, leveldb.ErrNotFound
+		}
+		return common.CopyBytes(value), nil
+	}
+	return db.db.Get(key)
+}
+
+// Has checks key, in the buffered writes first.
+func (db *BufferedDatabase) Has(key []byte) (bool, error) {
+	if value, ok := db.lookup(key); ok {
+		return value != nil, nil
+	}
+	return db.db.Has(key)
+}
+
+// loop flushes the buffered writes when the buffer is full or has been waiting
+// for too long.
+func (db *BufferedDatabase) loop() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(bufferedFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.Flush(); err != nil {
+				log.Error("Failed to flush database writes", "err", err)
+			}
+		case <-db.kick:
+			if err := db.flush(); err != nil {
+				log.Error("Failed to flush database writes", "err", err)
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Flush writes all the buffered writes to the wrapped database.
+func (db *BufferedDatabase) Flush() error {
+	// Finish any pending flush, then flush what's buffered since
+	if err := db.flush(); err != nil {
+		return err
+	}
+	db.lock.Lock()
+	if db.flushing == nil && db.active.size > 0 {
+		if err := db.rotate(); err != nil {
+			db.lock.Unlock()
+			return err
+		}
+	}
+	db.lock.Unlock()
+	return db.flush()
+}
+
+// flush writes the buffer handed over for flushing to the wrapped database,
+// dropping it and its journal once written. A failed flush is retried later.
+func (db *BufferedDatabase) flush() error {
+	db.flushLock.Lock()
+	defer db.flushLock.Unlock()
+
+	db.lock.RLock()
+	buffer := db.flushing
+	db.lock.RUnlock()
+	if buffer == nil {
+		return nil
+	}
+	batch := db.db.NewBatch()
+	for key, value := range buffer.values {
+		var err error
+		if value == nil {
+			err = batch.Delete([]byte(key))
+		} else {
+			err = batch.Put([]byte(key), value)
+		}
+		if err != nil {
+			return err
+		}
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Drop the journal before the buffer, a rotation may reuse its path as soon
+	// as the buffer is gone
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if err := os.Remove(db.journal + ".flush"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db.flushing = nil
+	db.cond.Broadcast()
+	return nil
+}
+
+// Close flushes the buffered writes and closes the wrapped database. The
+// journal is kept if the writes could not be flushed, to be replayed on the
+// next start.
+func (db *BufferedDatabase) Close() {
+	close(db.quit)
+	db.wg.Wait()
+
+	err := db.Flush()
+	db.file.Close()
+	if err != nil {
+		log.Error("Failed to flush database writes, kept in journal", "journal", db.journal, "err", err)
+	} else {
+		os.Remove(db.journal)
+	}
+	db.db.Close()
+}
+
+// NewBatch returns a batch buffered as a whole on Write.
+func (db *BufferedDatabase) NewBatch() Batch {
+	return &bufferedBatch{db: db}
+}
+
+type bufferedBatch struct {
+	db   *BufferedDatabase
+	ops  []bufferedOp
+	size int
+}
+
+func (b *bufferedBatch) Put(key, value []byte) error {
+	b.ops = append(b.ops, bufferedOp{key: common.CopyBytes(key), value: append([]byte{}, value...)})
+	b.size += len(value)
+	return nil
+}
+
+func (b *bufferedBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, bufferedOp{key: common.CopyBytes(key)})
+	b.size++
+	return nil
+}
+
+func (b *bufferedBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *bufferedBatch) Write() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	return b.db.write(b.ops)
+}
+
+func (b *bufferedBatch) Reset() {
+	b.ops = b.ops[:0]
+	b.size = 0
+}
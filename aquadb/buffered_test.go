@@ -0,0 +1,157 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that buffered writes survive a crash through the journal, dropping the
+// batch being journaled at the time of the crash.
+func TestBufferedDatabaseRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb-buffered-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		disk    = NewMemDatabase()
+		journal = filepath.Join(dir, "journal")
+	)
+	db, err := NewBufferedDatabase(disk, journal, 1024*1024)
+	if err != nil {
+		t.Fatalf("failed to open buffered database: %v", err)
+	}
+	db.Put([]byte("flushed"), []byte{1})
+	if err := db.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	db.Put([]byte("buffered"), []byte{2})
+	db.Delete([]byte("flushed"))
+	batch := db.NewBatch()
+	batch.Put([]byte("batched1"), []byte{3})
+	batch.Put([]byte("batched2"), []byte{})
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	if has, _ := disk.Has([]byte("buffered")); has {
+		t.Fatalf("write reached the disk before the flush")
+	}
+	// Crash with a batch half journaled
+	close(db.quit)
+	db.wg.Wait()
+	record := encodeJournalRecord([]bufferedOp{{key: []byte("torn1"), value: []byte{4}}, {key: []byte("torn2"), value: []byte{5}}})
+	db.file.Write(record[:len(record)-1])
+	db.file.Close()
+
+	db, err = NewBufferedDatabase(disk, journal, 1024*1024)
+	if err != nil {
+		t.Fatalf("failed to reopen buffered database: %v", err)
+	}
+	defer db.Close()
+
+	for key, want := range map[string][]byte{"buffered": {2}, "batched1": {3}, "batched2": {}} {
+		if have, err := disk.Get([]byte(key)); err != nil || string(have) != string(want) {
+			t.Errorf("key %s: have %x (%v), want %x", key, have, err, want)
+		}
+	}
+	for _, key := range []string{"flushed", "torn1", "torn2"} {
+		if has, _ := db.Has([]byte(key)); has {
+			t.Errorf("key %s present after recovery", key)
+		}
+	}
+}
+
+// Tests that writers wait for the pending flush once the buffer fills up again,
+// and that reads see the writes throughout.
+func TestBufferedDatabaseRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb-buffered-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	disk := NewMemDatabase()
+	db, err := NewBufferedDatabase(disk, filepath.Join(dir, "journal"), 100)
+	if err != nil {
+		t.Fatalf("failed to open buffered database: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := []byte{byte(i >> 8), byte(i)}
+		if err := db.Put(key, make([]byte, 10)); err != nil {
+			t.Fatalf("put %d failed: %v", i, err)
+		}
+		if has, _ := db.Has(key); !has {
+			t.Fatalf("put %d not readable", i)
+		}
+	}
+	db.Close()
+	if disk.Len() != 1000 {
+		t.Errorf("flushed key count mismatch: have %d, want 1000", disk.Len())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "journal")); !os.IsNotExist(err) {
+		t.Errorf("journal left after clean close: %v", err)
+	}
+}
+
+// Tests that no acknowledged write is lost by a crash while flushes run
+// concurrently with the writes rotating the buffers.
+func TestBufferedDatabaseFlushCrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb-buffered-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	disk := NewMemDatabase()
+	journal := filepath.Join(dir, "journal")
+	db, err := NewBufferedDatabase(disk, journal, 100)
+	if err != nil {
+		t.Fatalf("failed to open buffered database: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			db.Put([]byte{byte(i >> 8), byte(i)}, make([]byte, 10))
+		}
+	}()
+	for flushing := true; flushing; {
+		select {
+		case <-done:
+			flushing = false
+		default:
+			db.Flush()
+		}
+	}
+	// Crash without flushing, then recover from the journals
+	close(db.quit)
+	db.wg.Wait()
+	db.file.Close()
+
+	if db, err = NewBufferedDatabase(disk, journal, 100); err != nil {
+		t.Fatalf("failed to reopen buffered database: %v", err)
+	}
+	defer db.Close()
+	if disk.Len() != 1000 {
+		t.Errorf("recovered key count mismatch: have %d, want 1000", disk.Len())
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
@@ -40,8 +41,9 @@ const (
 var OpenFileLimit = 64
 
 type LDBDatabase struct {
-	fn string      // filename for reporting
-	db *leveldb.DB // LevelDB instance
+	fn  string      // filename for reporting
+	db  *leveldb.DB // LevelDB instance
+	enc *Encryptor  // Value encryption, nil if the database is not encrypted
 
 	compTimeMeter    metrics.Meter // Meter for measuring the total time spent in database compaction
 	compReadMeter    metrics.Meter // Meter for measuring the data read during compaction
@@ -51,6 +53,9 @@ type LDBDatabase struct {
 	diskReadMeter    metrics.Meter // Meter for measuring the effective amount of data read
 	diskWriteMeter   metrics.Meter // Meter for measuring the effective amount of data written
 
+	writeAmpGauge metrics.GaugeFloat64 // Gauge for the ratio of data written to disk to data written by the user
+	userWritten   uint64               // Bytes of keys and values written by the user (atomic)
+
 	quitLock sync.Mutex      // Mutex protecting the quit channel access
 	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
 
@@ -98,6 +103,10 @@ func (db *LDBDatabase) Path() string {
 
 // Put puts the given key / value to the queue
 func (db *LDBDatabase) Put(key []byte, value []byte) error {
+	atomic.AddUint64(&db.userWritten, uint64(len(key)+len(value)))
+	if db.enc != nil {
+		value = db.enc.Seal(key, value)
+	}
 	return db.db.Put(key, value, nil)
 }
 
@@ -111,6 +120,9 @@ func (db *LDBDatabase) Get(key []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if db.enc != nil {
+		return db.enc.Open(key, dat)
+	}
 	return dat, nil
 }
 
@@ -120,12 +132,20 @@ func (db *LDBDatabase) Delete(key []byte) error {
 }
 
 func (db *LDBDatabase) NewIterator() iterator.Iterator {
-	return db.db.NewIterator(nil, nil)
+	return db.wrapIterator(db.db.NewIterator(nil, nil))
 }
 
 // NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
 func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
-	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
+	return db.wrapIterator(db.db.NewIterator(util.BytesPrefix(prefix), nil))
+}
+
+// wrapIterator decrypts the iterated values if the database is encrypted.
+func (db *LDBDatabase) wrapIterator(it iterator.Iterator) iterator.Iterator {
+	if db.enc == nil {
+		return it
+	}
+	return &decryptingIterator{Iterator: it, enc: db.enc}
 }
 
 func (db *LDBDatabase) Close() {
@@ -153,6 +173,20 @@ func (db *LDBDatabase) LDB() *leveldb.DB {
 	return db.db
 }
 
+// ApproximateSize implements Sizer.
+func (db *LDBDatabase) ApproximateSize(start []byte, limit []byte) (uint64, error) {
+	sizes, err := db.db.SizeOf([]util.Range{{Start: start, Limit: limit}})
+	if err != nil {
+		return 0, err
+	}
+	return uint64(sizes.Sum()), nil
+}
+
+// Compact implements Compacter.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
 // Meter configures the database metrics collectors and
 func (db *LDBDatabase) Meter(prefix string) {
 	if metrics.Enabled {
@@ -162,6 +196,7 @@ func (db *LDBDatabase) Meter(prefix string) {
 		db.compWriteMeter = metrics.NewRegisteredMeter(prefix+"compact/output", nil)
 		db.diskReadMeter = metrics.NewRegisteredMeter(prefix+"disk/read", nil)
 		db.diskWriteMeter = metrics.NewRegisteredMeter(prefix+"disk/write", nil)
+		db.writeAmpGauge = metrics.NewRegisteredGaugeFloat64(prefix+"writeamp", nil)
 	}
 	// Initialize write delay metrics no matter we are in metric mode or not.
 	db.writeDelayMeter = metrics.NewRegisteredMeter(prefix+"compact/writedelay/duration", nil)
@@ -334,6 +369,11 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 		}
 		iostats[0], iostats[1] = nRead, nWrite
 
+		// Write amplification covers the journal and compactions since opening
+		if written := atomic.LoadUint64(&db.userWritten); db.writeAmpGauge != nil && written > 0 {
+			db.writeAmpGauge.Update(nWrite * 1024 * 1024 / float64(written))
+		}
+
 		// Sleep a bit, then repeat the stats collection
 		select {
 		case errc = <-db.quitChan:
@@ -350,18 +390,23 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 }
 
 func (db *LDBDatabase) NewBatch() Batch {
-	return &ldbBatch{db: db.db, b: new(leveldb.Batch)}
+	return &ldbBatch{db: db.db, b: new(leveldb.Batch), enc: db.enc, written: &db.userWritten}
 }
 
 type ldbBatch struct {
-	db   *leveldb.DB
-	b    *leveldb.Batch
-	size int
+	db      *leveldb.DB
+	b       *leveldb.Batch
+	enc     *Encryptor
+	size    int
+	written *uint64 // User write counter of the database
 }
 
 func (b *ldbBatch) Put(key, value []byte) error {
-	b.b.Put(key, value)
 	b.size += len(value)
+	if b.enc != nil {
+		value = b.enc.Seal(key, value)
+	}
+	b.b.Put(key, value)
 	return nil
 }
 
@@ -372,6 +417,7 @@ func (b *ldbBatch) Delete(key []byte) error {
 }
 
 func (b *ldbBatch) Write() error {
+	atomic.AddUint64(b.written, uint64(len(b.b.Dump())))
 	return b.db.Write(b.b, nil)
 }
 
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
@@ -44,6 +45,23 @@ func newTestLDB() (*aquadb.LDBDatabase, func()) {
 	}
 }
 
+// newTestBufferedDB returns a buffered memory database with a tiny buffer, so
+// that writes are flushed all the time.
+func newTestBufferedDB(t *testing.T) (*aquadb.BufferedDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "aquadb_test_")
+	if err != nil {
+		t.Fatalf("failed to create test dir: %v", err)
+	}
+	db, err := aquadb.NewBufferedDatabase(aquadb.NewMemDatabase(), filepath.Join(dirname, "journal"), 64)
+	if err != nil {
+		t.Fatalf("failed to create buffered database: %v", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
 var test_values = []string{"", "a", "1251", "\x00123\x00"}
 
 func TestLDB_PutGet(t *testing.T) {
@@ -56,6 +74,12 @@ func TestMemoryDB_PutGet(t *testing.T) {
 	testPutGet(aquadb.NewMemDatabase(), t)
 }
 
+func TestBufferedDB_PutGet(t *testing.T) {
+	db, remove := newTestBufferedDB(t)
+	defer remove()
+	testPutGet(db, t)
+}
+
 func testPutGet(db aquadb.Database, t *testing.T) {
 	t.Parallel()
 
@@ -155,6 +179,12 @@ func TestMemoryDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(aquadb.NewMemDatabase(), t)
 }
 
+func TestBufferedDB_ParallelPutGet(t *testing.T) {
+	db, remove := newTestBufferedDB(t)
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
 func testParallelPutGet(db aquadb.Database, t *testing.T) {
 	const n = 8
 	var pending sync.WaitGroup
@@ -210,3 +240,199 @@ func testParallelPutGet(db aquadb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_Encryption(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "aquadb_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+
+	db, err := aquadb.NewEncryptedLDBDatabase(dirname, 0, 0, []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to create encrypted database: %v", err)
+	}
+	testPutGet(db, t)
+
+	// Values must be stored sealed, and iterate in plain
+	if err := db.Put([]byte("plain"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := db.LDB().Get([]byte("plain"), nil); bytes.Contains(raw, []byte("value")) {
+		t.Fatalf("value stored unencrypted: %x", raw)
+	}
+	it := db.NewIteratorWithPrefix([]byte("plain"))
+	if !it.Next() || !bytes.Equal(it.Value(), []byte("value")) {
+		t.Fatalf("iterator value mismatch: have %q, want %q", it.Value(), "value")
+	}
+	it.Release()
+
+	// Sealed values must not open under another key
+	raw, _ := db.LDB().Get([]byte("plain"), nil)
+	if err := db.LDB().Put([]byte("moved"), raw, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := db.Get([]byte("moved")); err == nil {
+		t.Fatalf("value opened under another key: %q", value)
+	}
+	db.Close()
+
+	// Reopening must require the same secret
+	if _, err := aquadb.NewEncryptedLDBDatabase(dirname, 0, 0, nil); err != aquadb.ErrEncrypted {
+		t.Fatalf("plain open error mismatch: have %v, want %v", err, aquadb.ErrEncrypted)
+	}
+	if _, err := aquadb.NewEncryptedLDBDatabase(dirname, 0, 0, []byte("wrong")); err != aquadb.ErrWrongSecret {
+		t.Fatalf("wrong secret error mismatch: have %v, want %v", err, aquadb.ErrWrongSecret)
+	}
+	db, err = aquadb.NewEncryptedLDBDatabase(dirname, 0, 0, []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to reopen encrypted database: %v", err)
+	}
+	defer db.Close()
+
+	if value, err := db.Get([]byte("plain")); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Fatalf("reopened value mismatch: have %q, %v, want %q", value, err, "value")
+	}
+}
+
+func TestLDB_BackupRestore(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+
+	for i := 0; i < 2000; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if count, err := db.Backup(&buf); err != nil || count != 2000 {
+		t.Fatalf("backup failed: %d entries, %v", count, err)
+	}
+	// Modifications after the backup must not be restored
+	db.Put([]byte("key0"), []byte("modified"))
+
+	dirname, err := ioutil.TempDir(os.TempDir(), "aquadb_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+
+	backup := filepath.Join(dirname, "backup")
+	if err := ioutil.WriteFile(backup, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dirname, "restored")
+	if err := aquadb.ScheduleRestore(path, backup); err != nil {
+		t.Fatalf("failed to schedule restore: %v", err)
+	}
+	if err := aquadb.ApplyPendingRestore(path); err != nil {
+		t.Fatalf("failed to restore backup: %v", err)
+	}
+	restored, err := aquadb.NewLDBDatabase(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+
+	for i := 0; i < 2000; i++ {
+		value, err := restored.Get([]byte(fmt.Sprintf("key%d", i)))
+		if err != nil || string(value) != fmt.Sprintf("value%d", i) {
+			t.Fatalf("restored value %d mismatch: have %q, %v", i, value, err)
+		}
+	}
+	// The restore marker must be consumed
+	if err := aquadb.ApplyPendingRestore(path); err != nil {
+		t.Fatalf("repeated restore failed: %v", err)
+	}
+}
+
+func TestOpenEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb_engine_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if engine := aquadb.DetectEngine(dir); engine != "" {
+		t.Fatalf("engine detected on empty directory: %q", engine)
+	}
+	db, err := aquadb.Open("", dir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.Put([]byte("key"), []byte("value"))
+	db.Close()
+
+	if engine := aquadb.DetectEngine(dir); engine != aquadb.EngineLevelDB {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, aquadb.EngineLevelDB)
+	}
+	if _, err := aquadb.Open(aquadb.EnginePebble, dir, 0, 0); err == nil {
+		t.Fatalf("opened leveldb database with pebble engine")
+	}
+	if _, err := aquadb.MigrateEngine(dir, aquadb.EngineLevelDB, 0, 0); err == nil {
+		t.Fatalf("migrated database to its own engine")
+	}
+}
+
+func TestMemoryDB_Snapshot(t *testing.T) {
+	db := aquadb.NewMemDatabase()
+	db.Put([]byte("a"), []byte("1"))
+	db.Put([]byte("b"), []byte("2"))
+
+	base := db.Snapshot()
+	db.Put([]byte("a"), []byte("changed"))
+	db.Delete([]byte("b"))
+
+	mid := db.Snapshot()
+	batch := db.NewBatch()
+	batch.Put([]byte("c"), []byte("3"))
+	batch.Put([]byte("a"), []byte("again"))
+	batch.Write()
+
+	check := func(want map[string]string) {
+		t.Helper()
+		if len(db.Keys()) != len(want) {
+			t.Fatalf("key count mismatch: have %d, want %d", len(db.Keys()), len(want))
+		}
+		for k, v := range want {
+			if data, err := db.Get([]byte(k)); err != nil || string(data) != v {
+				t.Fatalf("value mismatch for %q: have %q (%v), want %q", k, data, err, v)
+			}
+		}
+	}
+	if err := db.Restore(mid); err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	check(map[string]string{"a": "changed"})
+
+	if err := db.Restore(base); err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	check(map[string]string{"a": "1", "b": "2"})
+
+	// The restored snapshot must remain usable, later ones are released
+	db.Put([]byte("d"), []byte("4"))
+	if err := db.Restore(base); err != nil {
+		t.Fatalf("failed to restore snapshot again: %v", err)
+	}
+	check(map[string]string{"a": "1", "b": "2"})
+
+	if err := db.Restore(mid); err == nil {
+		t.Fatalf("released snapshot restored")
+	}
+}
+
+func TestMemoryDB_Walk(t *testing.T) {
+	db := aquadb.NewMemDatabase()
+	for _, k := range []string{"c", "a", "b", "aa"} {
+		db.Put([]byte(k), []byte(k))
+	}
+	var keys []string
+	db.Walk(func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if fmt.Sprint(keys) != "[a aa b c]" {
+		t.Fatalf("iteration order mismatch: have %v", keys)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// scrypt parameters used to derive encryption keys from a secret.
+	encryptionScryptN = 1 << 15
+	encryptionScryptR = 8
+	encryptionScryptP = 1
+
+	encryptionKeyLen  = 32 // AES-256
+	encryptionSaltLen = 32
+)
+
+var (
+	// Reserved plaintext keys holding the key derivation salt and a sealed
+	// check value used to detect a wrong secret before touching any data.
+	encryptionSaltKey  = []byte("aquadb-encryption-salt")
+	encryptionCheckKey = []byte("aquadb-encryption-check")
+	encryptionCheck    = []byte("aquachain")
+
+	// ErrWrongSecret is returned when an encrypted database is opened with a
+	// secret different from the one it was created with.
+	ErrWrongSecret = errors.New("wrong database encryption secret")
+
+	// ErrNotEncrypted is returned when encryption is requested on a database
+	// that already contains unencrypted data.
+	ErrNotEncrypted = errors.New("database already contains unencrypted data")
+
+	// ErrEncrypted is returned when an encrypted database is opened without
+	// an encryption secret.
+	ErrEncrypted = errors.New("database is encrypted, no secret provided")
+)
+
+// Encryptor seals and opens values with AES-256-GCM using a random nonce for
+// every value. The key a value is stored under is authenticated along with it,
+// so that sealed values cannot be swapped between keys.
+type Encryptor struct {
+	aead cipher.AEAD
+}
+
+// NewEncryptor creates an encryptor from a secret and a salt, deriving the
+// actual encryption key via scrypt.
+func NewEncryptor(secret, salt []byte) (*Encryptor, error) {
+	key, err := scrypt.Key(secret, salt, encryptionScryptN, encryptionScryptR, encryptionScryptP, encryptionKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Encryptor{aead: aead}, nil
+}
+
+// NewEncryptionSalt generates a fresh random salt for key derivation.
+func NewEncryptionSalt() ([]byte, error) {
+	salt := make([]byte, encryptionSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Seal encrypts and authenticates a value stored under key, prepending the
+// random nonce.
+func (e *Encryptor) Seal(key, plain []byte) []byte {
+	nonce := make([]byte, e.aead.NonceSize(), e.aead.NonceSize()+len(plain)+e.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic("aquadb: failed to read random nonce: " + err.Error())
+	}
+	return e.aead.Seal(nonce, nonce, plain, key)
+}
+
+// Open authenticates and decrypts a value previously sealed by Seal under the
+// same key.
+func (e *Encryptor) Open(key, sealed []byte) ([]byte, error) {
+	size := e.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("aquadb: sealed value too short")
+	}
+	return e.aead.Open(nil, sealed[:size], sealed[size:], key)
+}
+
+// Encrypt enables transparent encryption of all values stored in the database
+// from now on. Keys are stored in plain, as lookups and prefix iteration depend
+// on them; they mostly consist of hashes and block numbers.
+//
+// A freshly created database is initialized for encryption with the secret,
+// while an existing encrypted database verifies that the same secret is used.
+// Enabling encryption on a database already containing plain data fails.
+func (db *LDBDatabase) Encrypt(secret []byte) error {
+	salt, err := db.db.Get(encryptionSaltKey, nil)
+	switch {
+	case err == leveldb.ErrNotFound:
+		// Not yet encrypted, only allow setting it up on an empty database
+		it := db.db.NewIterator(nil, nil)
+		empty := !it.Next()
+		it.Release()
+		if !empty {
+			return ErrNotEncrypted
+		}
+		if salt, err = NewEncryptionSalt(); err != nil {
+			return err
+		}
+		enc, err := NewEncryptor(secret, salt)
+		if err != nil {
+			return err
+		}
+		batch := new(leveldb.Batch)
+		batch.Put(encryptionSaltKey, salt)
+		batch.Put(encryptionCheckKey, enc.Seal(encryptionCheckKey, encryptionCheck))
+		if err := db.db.Write(batch, nil); err != nil {
+			return err
+		}
+		db.enc = enc
+		db.log.Info("Initialized encrypted database")
+		return nil
+
+	case err != nil:
+		return err
+	}
+	// Database already encrypted, make sure the secret matches
+	enc, err := NewEncryptor(secret, salt)
+	if err != nil {
+		return err
+	}
+	check, err := db.db.Get(encryptionCheckKey, nil)
+	if err != nil {
+		return err
+	}
+	if plain, err := enc.Open(encryptionCheckKey, check); err != nil || !bytes.Equal(plain, encryptionCheck) {
+		return ErrWrongSecret
+	}
+	db.enc = enc
+	return nil
+}
+
+// Encrypted returns whether the values in the database are encrypted.
+func (db *LDBDatabase) Encrypted() bool {
+	return db.enc != nil
+}
+
+// hasEncryptionSalt returns whether the database was initialized for encryption,
+// regardless of whether it was opened with a secret.
+func (db *LDBDatabase) hasEncryptionSalt() bool {
+	has, _ := db.db.Has(encryptionSaltKey, nil)
+	return has
+}
+
+// NewEncryptedLDBDatabase opens a LevelDB database with transparent value
+// encryption using the given secret. If the secret is empty, the database is
+// opened in plain mode, but only after verifying that it isn't encrypted, as
+// mixing plain and encrypted values would corrupt it.
+func NewEncryptedLDBDatabase(file string, cache int, handles int, secret []byte) (*LDBDatabase, error) {
+	db, err := NewLDBDatabase(file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) > 0 {
+		err = db.Encrypt(secret)
+	} else if has, herr := db.db.Has(encryptionSaltKey, nil); herr != nil {
+		err = herr
+	} else if has {
+		err = ErrEncrypted
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// decryptingIterator wraps a database iterator, transparently decrypting the
+// values and skipping the reserved encryption metadata entries.
+type decryptingIterator struct {
+	iterator.Iterator
+	enc *Encryptor
+	err error
+}
+
+func isEncryptionKey(key []byte) bool {
+	return bytes.Equal(key, encryptionSaltKey) || bytes.Equal(key, encryptionCheckKey)
+}
+
+func (it *decryptingIterator) forward(ok bool) bool {
+	for ok && isEncryptionKey(it.Key()) {
+		ok = it.Iterator.Next()
+	}
+	return ok
+}
+
+func (it *decryptingIterator) backward(ok bool) bool {
+	for ok && isEncryptionKey(it.Key()) {
+		ok = it.Iterator.Prev()
+	}
+	return ok
+}
+
+func (it *decryptingIterator) First() bool          { return it.forward(it.Iterator.First()) }
+func (it *decryptingIterator) Last() bool           { return it.backward(it.Iterator.Last()) }
+func (it *decryptingIterator) Seek(key []byte) bool { return it.forward(it.Iterator.Seek(key)) }
+func (it *decryptingIterator) Next() bool           { return it.forward(it.Iterator.Next()) }
+func (it *decryptingIterator) Prev() bool           { return it.backward(it.Iterator.Prev()) }
+
+// Value returns the decrypted value of the current entry. Decryption failures
+// are reported via Error.
+func (it *decryptingIterator) Value() []byte {
+	plain, err := it.enc.Open(it.Iterator.Key(), it.Iterator.Value())
+	if err != nil {
+		it.err = err
+		return nil
+	}
+	return plain
+}
+
+func (it *decryptingIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.Iterator.Error()
+}
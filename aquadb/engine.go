@@ -0,0 +1,196 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+// Names of the supported database engines.
+const (
+	EngineLevelDB = "leveldb"
+	EnginePebble  = "pebble"
+	EngineBadger  = "badger"
+)
+
+// engineOpener opens or creates a persistent database with the given cache
+// allowance in megabytes and file handle limit.
+type engineOpener func(file string, cache int, handles int) (Database, error)
+
+// engines contains the database engines compiled into the binary. Optional
+// engines add themselves from build-tag guarded files.
+var engines = map[string]engineOpener{
+	EngineLevelDB: func(file string, cache int, handles int) (Database, error) {
+		return NewLDBDatabase(file, cache, handles)
+	},
+}
+
+// Engines returns the names of the database engines compiled into the binary.
+func Engines() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectEngine returns the engine of the existing database at file, or an empty
+// string if there is no database.
+func DetectEngine(file string) string {
+	// Pebble keeps an OPTIONS file, which LevelDB doesn't have
+	if matches, _ := filepath.Glob(filepath.Join(file, "OPTIONS-*")); len(matches) > 0 {
+		return EnginePebble
+	}
+	// Badger stores values separately in value log files
+	if matches, _ := filepath.Glob(filepath.Join(file, "*.vlog")); len(matches) > 0 {
+		return EngineBadger
+	}
+	if _, err := os.Stat(filepath.Join(file, "CURRENT")); err == nil {
+		return EngineLevelDB
+	}
+	return ""
+}
+
+// Open opens the database at file with the given engine, creating it if none
+// exists yet. An empty engine selects the one of the existing database, or
+// LevelDB for new ones. Opening an existing database with a different engine
+// fails, it has to be converted with MigrateEngine first.
+func Open(engine string, file string, cache int, handles int) (Database, error) {
+	existing := DetectEngine(file)
+	if engine == "" {
+		engine = existing
+	}
+	if engine == "" {
+		engine = EngineLevelDB
+	}
+	if existing != "" && existing != engine {
+		return nil, fmt.Errorf("database %s uses engine %s, not %s (see 'aquachain migratedb')", file, existing, engine)
+	}
+	opener, ok := engines[engine]
+	if !ok {
+		return nil, fmt.Errorf("database engine %q not available, have %v", engine, Engines())
+	}
+	return opener(file, cache, handles)
+}
+
+// Walker is implemented by databases able to enumerate all their entries.
+type Walker interface {
+	// Walk calls fn for every key/value pair in key order, aborting on the
+	// first error. The slices are only valid until fn returns.
+	Walk(fn func(key, value []byte) error) error
+}
+
+// Walk implements Walker.
+func (db *LDBDatabase) Walk(fn func(key, value []byte) error) error {
+	it := db.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// Copy writes all entries of src into dst, returning the number of entries.
+func Copy(dst Database, src Walker) (int, error) {
+	var (
+		batch  = dst.NewBatch()
+		count  = 0
+		logged = time.Now()
+	)
+	err := src.Walk(func(key, value []byte) error {
+		if err := batch.Put(key, value); err != nil {
+			return err
+		}
+		count++
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Copying database entries", "count", count)
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, batch.Write()
+}
+
+// MigrateEngine converts the database at file to another engine. The database
+// is copied into a new one next to it, which then replaces the original. The
+// original database is kept under file.<engine> until removed by the user. The
+// database must not be open.
+func MigrateEngine(file string, engine string, cache int, handles int) (int, error) {
+	existing := DetectEngine(file)
+	switch {
+	case existing == "":
+		return 0, fmt.Errorf("no database at %s", file)
+	case existing == engine:
+		return 0, fmt.Errorf("database %s already uses engine %s", file, engine)
+	}
+	if _, ok := engines[engine]; !ok {
+		return 0, fmt.Errorf("database engine %q not available, have %v", engine, Engines())
+	}
+	src, err := Open(existing, file, cache/2, handles/2)
+	if err != nil {
+		return 0, err
+	}
+	walker, ok := src.(Walker)
+	if !ok {
+		src.Close()
+		return 0, fmt.Errorf("database engine %s can't be migrated", existing)
+	}
+	if ldb, ok := src.(*LDBDatabase); ok && ldb.hasEncryptionSalt() {
+		src.Close()
+		return 0, errors.New("encrypted databases can't be migrated")
+	}
+	tmp := file + ".migrating"
+	os.RemoveAll(tmp)
+
+	dst, err := Open(engine, tmp, cache/2, handles/2)
+	if err != nil {
+		src.Close()
+		return 0, err
+	}
+	count, err := Copy(dst, walker)
+	dst.Close()
+	src.Close()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return count, err
+	}
+	backup := file + "." + existing
+	if err := os.Rename(file, backup); err != nil {
+		return count, err
+	}
+	return count, os.Rename(tmp, file)
+}
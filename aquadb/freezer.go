@@ -0,0 +1,326 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/snappy"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+var (
+	// ErrFreezerOutOfBounds is returned when retrieving an item not in the freezer.
+	ErrFreezerOutOfBounds = errors.New("out of bounds")
+
+	errFreezerClosed       = errors.New("freezer closed")
+	errFreezerUnknownTable = errors.New("unknown freezer table")
+)
+
+// Freezer is an append-only store of immutable items, kept in flat files
+// instead of a key-value store. Items are numbered from zero, with every table
+// of the freezer holding exactly one item per number.
+//
+// Each table is made of a data file with the snappy compressed items one after
+// the other, and an index file with the end offset of every item in the data
+// file. Interrupted writes are repaired on opening, truncating all tables to
+// the items fully written to every one of them.
+type Freezer struct {
+	lock   sync.RWMutex
+	items  uint64                   // Number of items in every table
+	tables map[string]*freezerTable // Tables by name
+}
+
+// NewFreezer opens or creates a freezer with the given tables in dir.
+func NewFreezer(dir string, tables []string) (*Freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &Freezer{tables: make(map[string]*freezerTable)}
+	for i, name := range tables {
+		table, err := openFreezerTable(dir, name)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[name] = table
+		if i == 0 || table.items < f.items {
+			f.items = table.items
+		}
+	}
+	for name, table := range f.tables {
+		if table.items > f.items {
+			log.Warn("Truncating dangling freezer items", "table", name, "items", table.items, "limit", f.items)
+		}
+		if err := table.truncate(f.items); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Items returns the number of items in the freezer, which is also the number
+// the next appended item gets.
+func (f *Freezer) Items() uint64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.items
+}
+
+// Retrieve returns the item with the given number from a table.
+func (f *Freezer) Retrieve(table string, number uint64) ([]byte, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.tables == nil {
+		return nil, errFreezerClosed
+	}
+	t, ok := f.tables[table]
+	if !ok {
+		return nil, errFreezerUnknownTable
+	}
+	if number >= f.items {
+		return nil, ErrFreezerOutOfBounds
+	}
+	return t.retrieve(number)
+}
+
+// Append adds the item with the given number to every table. The number must be
+// the next one in the freezer, and items must hold a value for all tables.
+func (f *Freezer) Append(number uint64, items map[string][]byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tables == nil {
+		return errFreezerClosed
+	}
+	if number != f.items {
+		return fmt.Errorf("freezer append out of order: have %d, want %d", number, f.items)
+	}
+	if len(items) != len(f.tables) {
+		return fmt.Errorf("freezer append with %d items for %d tables", len(items), len(f.tables))
+	}
+	for name, blob := range items {
+		t, ok := f.tables[name]
+		if !ok {
+			return errFreezerUnknownTable
+		}
+		if err := t.append(blob); err != nil {
+			// Roll back the tables already written to
+			for _, t := range f.tables {
+				t.truncate(f.items)
+			}
+			return err
+		}
+	}
+	f.items++
+	return nil
+}
+
+// Truncate discards all items from the given number on.
+func (f *Freezer) Truncate(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tables == nil {
+		return errFreezerClosed
+	}
+	if items >= f.items {
+		return nil
+	}
+	for _, t := range f.tables {
+		if err := t.truncate(items); err != nil {
+			return err
+		}
+	}
+	f.items = items
+	return nil
+}
+
+// Sync flushes all tables to disk.
+func (f *Freezer) Sync() error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	for _, t := range f.tables {
+		if err := t.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes all tables.
+func (f *Freezer) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var errs []error
+	for _, t := range f.tables {
+		if err := t.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	f.tables = nil
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freezerTable is a single table of a freezer. It isn't safe for concurrent
+// writes, the freezer lock guards it.
+type freezerTable struct {
+	data  *os.File // Compressed items, one after the other
+	index *os.File // Big endian end offset of every item in the data file
+	size  uint64   // Number of bytes used in the data file
+	items uint64   // Number of items in the table
+}
+
+// openFreezerTable opens or creates the files of a table, dropping items not
+// fully written to both of them.
+func openFreezerTable(dir string, name string) (*freezerTable, error) {
+	data, err := os.OpenFile(filepath.Join(dir, name+".dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	t := &freezerTable{data: data, index: index}
+	if err := t.repair(); err != nil {
+		t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// repair makes the data and index files agree with each other.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	t.items = uint64(stat.Size()) / 8
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	size := uint64(stat.Size())
+
+	// Drop indexed items whose data didn't make it to disk
+	for t.items > 0 {
+		end, err := t.offset(t.items)
+		if err != nil {
+			return err
+		}
+		if end <= size {
+			break
+		}
+		t.items--
+	}
+	t.size = size
+	return t.truncate(t.items)
+}
+
+// offset returns the offset the item with the given number starts at, which is
+// also the end offset of the previous one.
+func (t *freezerTable) offset(number uint64) (uint64, error) {
+	if number == 0 {
+		return 0, nil
+	}
+	var buf [8]byte
+	if _, err := t.index.ReadAt(buf[:], int64(number-1)*8); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (t *freezerTable) retrieve(number uint64) ([]byte, error) {
+	start, err := t.offset(number)
+	if err != nil {
+		return nil, err
+	}
+	end, err := t.offset(number + 1)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		return nil, fmt.Errorf("corrupt freezer index at item %d", number)
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	return snappy.Decode(nil, blob)
+}
+
+func (t *freezerTable) append(item []byte) error {
+	blob := snappy.Encode(nil, item)
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(buf[:], int64(t.items)*8); err != nil {
+		return err
+	}
+	t.size += uint64(len(blob))
+	t.items++
+	return nil
+}
+
+func (t *freezerTable) truncate(items uint64) error {
+	if items > t.items {
+		return nil
+	}
+	end, err := t.offset(items)
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64(items) * 8); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.size, t.items = end, items
+	return nil
+}
+
+func (t *freezerTable) sync() error {
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+func (t *freezerTable) close() error {
+	t.sync()
+	if err := t.data.Close(); err != nil {
+		return err
+	}
+	return t.index.Close()
+}
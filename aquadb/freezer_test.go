@@ -0,0 +1,101 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+var freezerTables = []string{"a", "b"}
+
+func freezerItems(n uint64) map[string][]byte {
+	return map[string][]byte{
+		"a": []byte(fmt.Sprintf("a-%d", n)),
+		"b": bytes.Repeat([]byte{byte(n)}, int(n)),
+	}
+}
+
+func TestFreezer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb-freezer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := aquadb.NewFreezer(dir, freezerTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < 10; i++ {
+		if err := f.Append(i, freezerItems(i)); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+	if err := f.Append(20, freezerItems(20)); err == nil {
+		t.Fatal("out of order append succeeded")
+	}
+	if _, err := f.Retrieve("a", 10); err != aquadb.ErrFreezerOutOfBounds {
+		t.Fatalf("retrieve past end: have %v, want %v", err, aquadb.ErrFreezerOutOfBounds)
+	}
+	if err := f.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash while appending: an index entry without its data
+	idx, err := os.OpenFile(filepath.Join(dir, "b.idx"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Write([]byte{0, 0, 0, 0, 0, 0, 1, 0})
+	idx.Close()
+
+	if f, err = aquadb.NewFreezer(dir, freezerTables); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if items := f.Items(); items != 8 {
+		t.Fatalf("items after reopen: have %d, want 8", items)
+	}
+	for i := uint64(0); i < 8; i++ {
+		for table, want := range freezerItems(i) {
+			have, err := f.Retrieve(table, i)
+			if err != nil {
+				t.Fatalf("retrieve %s/%d: %v", table, i, err)
+			}
+			if !bytes.Equal(have, want) {
+				t.Errorf("item %s/%d: have %x, want %x", table, i, have, want)
+			}
+		}
+	}
+	if err := f.Append(8, freezerItems(8)); err != nil {
+		t.Fatalf("append after reopen: %v", err)
+	}
+	if have, _ := f.Retrieve("b", 8); !bytes.Equal(have, freezerItems(8)["b"]) {
+		t.Errorf("item b/8 after reopen: have %x", have)
+	}
+}
@@ -40,6 +40,22 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// Sizer wraps the estimation of the disk space used by a key range, supported
+// by persistent databases.
+type Sizer interface {
+	// ApproximateSize returns the approximate number of bytes used on disk by the
+	// given key range. A nil start is treated as a key before all keys, a nil
+	// limit as after all keys.
+	ApproximateSize(start []byte, limit []byte) (uint64, error)
+}
+
+// Compacter wraps the compaction of a key range supported by persistent databases.
+type Compacter interface {
+	// Compact flattens the underlying data store for the given key range. A nil
+	// start is treated as a key before all keys, a nil limit as after all keys.
+	Compact(start []byte, limit []byte) error
+}
+
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
@@ -50,3 +66,39 @@ type Batch interface {
 	// Reset resets the batch for reuse
 	Reset()
 }
+
+// Wrapper is implemented by databases layering extra behaviour over another
+// database, giving access to the wrapped one.
+type Wrapper interface {
+	Unwrap() Database
+}
+
+// Unwrap returns the innermost database wrapped by db, or db itself if it's
+// not a Wrapper. It's used to reach backend specific features, like metering or
+// compaction, through wrapping layers.
+func Unwrap(db Database) Database {
+	for {
+		w, ok := db.(Wrapper)
+		if !ok {
+			return db
+		}
+		db = w.Unwrap()
+	}
+}
+
+// Flush writes the buffered writes of the wrapping layers of db through to the
+// innermost database, for users reading it directly after Unwrap.
+func Flush(db Database) error {
+	for {
+		if bdb, ok := db.(*BufferedDatabase); ok {
+			if err := bdb.Flush(); err != nil {
+				return err
+			}
+		}
+		w, ok := db.(Wrapper)
+		if !ok {
+			return nil
+		}
+		db = w.Unwrap()
+	}
+}
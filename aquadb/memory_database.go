@@ -18,6 +18,8 @@ package aquadb
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
 
 	"gitlab.com/aquachain/aquachain/common"
@@ -29,6 +31,17 @@ import (
 type MemDatabase struct {
 	db   map[string][]byte
 	lock sync.RWMutex
+
+	snaps  []*memSnapshot // Currently valid snapshots, oldest first
+	nextID int            // Identifier of the next snapshot
+}
+
+// memSnapshot journals the original values of the keys modified since a
+// snapshot was taken, allowing them to be restored.
+type memSnapshot struct {
+	id   int
+	undo []kv                // Original values in modification order, del if the key was missing
+	seen map[string]struct{} // Keys already journaled
 }
 
 func NewMemDatabase() *MemDatabase {
@@ -47,6 +60,7 @@ func (db *MemDatabase) Put(key []byte, value []byte) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
+	db.journal(string(key))
 	db.db[string(key)] = common.CopyBytes(value)
 	return nil
 }
@@ -69,27 +83,105 @@ func (db *MemDatabase) Get(key []byte) ([]byte, error) {
 	return nil, errors.New("not found")
 }
 
+// Keys returns all keys in the database in ascending order.
 func (db *MemDatabase) Keys() [][]byte {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
 	keys := [][]byte{}
-	for key := range db.db {
+	for _, key := range db.sortedKeys() {
 		keys = append(keys, []byte(key))
 	}
 	return keys
 }
 
+// Walk implements Walker, iterating the entries in ascending key order.
+func (db *MemDatabase) Walk(fn func(key, value []byte) error) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	for _, key := range db.sortedKeys() {
+		if err := fn([]byte(key), common.CopyBytes(db.db[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *MemDatabase) sortedKeys() []string {
+	keys := make([]string, 0, len(db.db))
+	for key := range db.db {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (db *MemDatabase) Delete(key []byte) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
+	db.journal(string(key))
 	delete(db.db, string(key))
 	return nil
 }
 
 func (db *MemDatabase) Close() {}
 
+// Snapshot marks the current contents of the database, returning an identifier
+// to restore them with later. Snapshots are copy-on-write, only the original
+// values of keys modified afterwards are retained.
+func (db *MemDatabase) Snapshot() int {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	id := db.nextID
+	db.nextID++
+	db.snaps = append(db.snaps, &memSnapshot{id: id, seen: make(map[string]struct{})})
+	return id
+}
+
+// Restore reverts the database to its contents at the given snapshot. The
+// snapshot stays valid, so a baseline can be restored repeatedly, while all
+// snapshots taken after it are released.
+func (db *MemDatabase) Restore(id int) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	idx := sort.Search(len(db.snaps), func(i int) bool { return db.snaps[i].id >= id })
+	if idx == len(db.snaps) || db.snaps[idx].id != id {
+		return fmt.Errorf("snapshot %d cannot be restored", id)
+	}
+	for i := len(db.snaps) - 1; i >= idx; i-- {
+		undo := db.snaps[i].undo
+		for j := len(undo) - 1; j >= 0; j-- {
+			if undo[j].del {
+				delete(db.db, string(undo[j].k))
+			} else {
+				db.db[string(undo[j].k)] = undo[j].v
+			}
+		}
+	}
+	db.snaps = append(db.snaps[:idx], &memSnapshot{id: id, seen: make(map[string]struct{})})
+	return nil
+}
+
+// journal records the original value of a key about to be modified in the most
+// recent snapshot. The caller must hold the write lock.
+func (db *MemDatabase) journal(key string) {
+	if len(db.snaps) == 0 {
+		return
+	}
+	snap := db.snaps[len(db.snaps)-1]
+	if _, ok := snap.seen[key]; ok {
+		return
+	}
+	snap.seen[key] = struct{}{}
+
+	value, ok := db.db[key]
+	snap.undo = append(snap.undo, kv{[]byte(key), value, !ok})
+}
+
 func (db *MemDatabase) NewBatch() Batch {
 	return &memBatch{db: db}
 }
@@ -124,6 +216,7 @@ func (b *memBatch) Write() error {
 	defer b.db.lock.Unlock()
 
 	for _, kv := range b.writes {
+		b.db.journal(string(kv.k))
 		if kv.del {
 			delete(b.db.db, string(kv.k))
 			continue
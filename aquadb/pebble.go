@@ -0,0 +1,209 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build pebble
+
+package aquadb
+
+import (
+	"bytes"
+
+	"github.com/cockroachdb/pebble"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+func init() {
+	engines[EnginePebble] = func(file string, cache int, handles int) (Database, error) {
+		return NewPebbleDatabase(file, cache, handles)
+	}
+}
+
+// PebbleDatabase is a persistent database backed by Pebble, a LevelDB inspired
+// key-value store with better behaved compactions on large datasets.
+type PebbleDatabase struct {
+	fn string     // filename for reporting
+	db *pebble.DB // Pebble instance
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewPebbleDatabase returns a Pebble wrapped object.
+func NewPebbleDatabase(file string, cache int, handles int) (*PebbleDatabase, error) {
+	logger := log.New("database", file)
+
+	// Ensure we have some minimal caching and file guarantees
+	if cache < 16 {
+		cache = 16
+	}
+	if handles < 16 {
+		handles = 16
+	}
+	logger.Info("Allocated cache and file handles", "engine", EnginePebble, "cache", cache, "handles", handles)
+
+	blockCache := pebble.NewCache(int64(cache / 2 * 1024 * 1024))
+	defer blockCache.Unref()
+
+	db, err := pebble.Open(file, &pebble.Options{
+		Cache:        blockCache,
+		MaxOpenFiles: handles,
+		MemTableSize: cache / 4 * 1024 * 1024,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PebbleDatabase{
+		fn:  file,
+		db:  db,
+		log: logger,
+	}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *PebbleDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the queue
+func (db *PebbleDatabase) Put(key []byte, value []byte) error {
+	return db.db.Set(key, value, pebble.NoSync)
+}
+
+func (db *PebbleDatabase) Has(key []byte) (bool, error) {
+	_, closer, err := db.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, closer.Close()
+}
+
+// Get returns the given key if it's present.
+func (db *PebbleDatabase) Get(key []byte) ([]byte, error) {
+	dat, closer, err := db.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	return append([]byte{}, dat...), nil
+}
+
+// Delete deletes the key from the queue and database
+func (db *PebbleDatabase) Delete(key []byte) error {
+	return db.db.Delete(key, pebble.NoSync)
+}
+
+// Walk implements Walker.
+func (db *PebbleDatabase) Walk(fn func(key, value []byte) error) error {
+	it := db.db.NewIter(nil)
+	for it.First(); it.Valid(); it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			it.Close()
+			return err
+		}
+	}
+	return it.Close()
+}
+
+// ApproximateSize implements Sizer.
+func (db *PebbleDatabase) ApproximateSize(start []byte, limit []byte) (uint64, error) {
+	if start == nil {
+		start = []byte{}
+	}
+	if limit == nil {
+		// Pebble needs an explicit upper bound, use one after the last stored key
+		it := db.db.NewIter(nil)
+		if it.Last() {
+			limit = append(append([]byte{}, it.Key()...), 0)
+		}
+		if err := it.Close(); err != nil {
+			return 0, err
+		}
+		if limit == nil {
+			return 0, nil // empty database
+		}
+	}
+	if bytes.Compare(start, limit) >= 0 {
+		return 0, nil
+	}
+	return db.db.EstimateDiskUsage(start, limit)
+}
+
+// Compact implements Compacter.
+func (db *PebbleDatabase) Compact(start []byte, limit []byte) error {
+	// Pebble needs explicit bounds, derive missing ones from the stored keys
+	if start == nil || limit == nil {
+		it := db.db.NewIter(nil)
+		if start == nil && it.First() {
+			start = append([]byte{}, it.Key()...)
+		}
+		if limit == nil && it.Last() {
+			limit = append(append([]byte{}, it.Key()...), 0)
+		}
+		if err := it.Close(); err != nil {
+			return err
+		}
+		if start == nil || limit == nil {
+			return nil // empty database
+		}
+	}
+	if bytes.Compare(start, limit) >= 0 {
+		return nil
+	}
+	return db.db.Compact(start, limit)
+}
+
+func (db *PebbleDatabase) Close() {
+	if err := db.db.Close(); err == nil {
+		db.log.Info("Database closed")
+	} else {
+		db.log.Error("Failed to close database", "err", err)
+	}
+}
+
+func (db *PebbleDatabase) NewBatch() Batch {
+	return &pebbleBatch{b: db.db.NewBatch()}
+}
+
+type pebbleBatch struct {
+	b    *pebble.Batch
+	size int
+}
+
+func (b *pebbleBatch) Put(key, value []byte) error {
+	b.size += len(value)
+	return b.b.Set(key, value, nil)
+}
+
+func (b *pebbleBatch) Delete(key []byte) error {
+	b.size++
+	return b.b.Delete(key, nil)
+}
+
+func (b *pebbleBatch) Write() error {
+	return b.b.Commit(pebble.NoSync)
+}
+
+func (b *pebbleBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *pebbleBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build pebble
+
+package aquadb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+func newTestPebble() (*aquadb.PebbleDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "aquadb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := aquadb.NewPebbleDatabase(dirname, 0, 0)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+func TestPebble_PutGet(t *testing.T) {
+	db, remove := newTestPebble()
+	defer remove()
+	testPutGet(db, t)
+}
+
+func TestPebble_ParallelPutGet(t *testing.T) {
+	db, remove := newTestPebble()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
+func TestMigrateEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquadb_migrate_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "chaindata")
+	db, err := aquadb.NewLDBDatabase(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range test_values {
+		db.Put([]byte("key"+v), []byte(v))
+	}
+	db.Close()
+
+	count, err := aquadb.MigrateEngine(path, aquadb.EnginePebble, 0, 0)
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if count != len(test_values) {
+		t.Fatalf("migrated entry count mismatch: have %d, want %d", count, len(test_values))
+	}
+	if engine := aquadb.DetectEngine(path); engine != aquadb.EnginePebble {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, aquadb.EnginePebble)
+	}
+	if engine := aquadb.DetectEngine(path + "." + aquadb.EngineLevelDB); engine != aquadb.EngineLevelDB {
+		t.Fatalf("original database not kept, detected %q", engine)
+	}
+	migrated, err := aquadb.Open("", path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open migrated database: %v", err)
+	}
+	defer migrated.Close()
+
+	for _, v := range test_values {
+		if data, err := migrated.Get([]byte("key" + v)); err != nil || string(data) != v {
+			t.Errorf("value mismatch for %q: have %q, %v", v, data, err)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+// The remote database protocol gives read-only access to a database over a
+// stream connection. After accepting a connection, the server sends a random
+// nonce, which the client answers with a nonce of its own and its proof, the
+// HMAC-SHA256 of both nonces under the shared secret. The server checks it and
+// replies with a status byte and its own proof over both nonces, so that the
+// client also knows it talks to a holder of the secret. Afterwards the client
+// sends requests consisting of an opcode and a length-prefixed key, each
+// answered by a status byte and a length-prefixed payload holding the value or
+// an error message.
+const (
+	remoteNonceSize    = 32
+	remoteMaxKeySize   = 1024     // Maximum accepted key size
+	remoteMaxValueSize = 64 << 20 // Maximum accepted value size
+	remoteTimeout      = 10 * time.Second
+	remoteMaxIdleConns = 16       // Maximum number of pooled client connections
+	remoteMaxOverlay   = 64 << 20 // Maximum size of the local modifications
+
+	remoteClientRole = "client"
+	remoteServerRole = "server"
+
+	remoteOpGet = 0x01
+	remoteOpHas = 0x02
+
+	remoteStatusOK       = 0x00
+	remoteStatusNotFound = 0x01
+	remoteStatusError    = 0x02
+)
+
+var (
+	// ErrRemoteAuth is returned if the remote database rejected the secret, or
+	// failed to prove it knows the secret itself.
+	ErrRemoteAuth = errors.New("remote database authentication failed")
+
+	// ErrRemoteNotFound is returned if a key is not present in the remote database.
+	ErrRemoteNotFound = errors.New("not found")
+)
+
+// RemoteServer serves a database read-only to authenticated remote clients.
+type RemoteServer struct {
+	db       Database
+	secret   []byte
+	listener net.Listener
+
+	conns map[net.Conn]struct{} // Currently open client connections
+	lock  sync.Mutex
+	wg    sync.WaitGroup
+
+	log log.Logger
+}
+
+// NewRemoteServer starts serving the database on the given listener to clients
+// knowing the secret.
+func NewRemoteServer(listener net.Listener, db Database, secret []byte) *RemoteServer {
+	srv := &RemoteServer{
+		db:       db,
+		secret:   secret,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+		log:      log.New("database", "remote", "addr", listener.Addr()),
+	}
+	srv.wg.Add(1)
+	go srv.loop()
+
+	return srv
+}
+
+// Addr returns the address the server is listening on.
+func (srv *RemoteServer) Addr() net.Addr {
+	return srv.listener.Addr()
+}
+
+// Close stops accepting new clients and terminates all open connections.
+func (srv *RemoteServer) Close() error {
+	err := srv.listener.Close()
+
+	srv.lock.Lock()
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.lock.Unlock()
+
+	srv.wg.Wait()
+	return err
+}
+
+// loop accepts client connections until the listener is closed.
+func (srv *RemoteServer) loop() {
+	defer srv.wg.Done()
+
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return
+		}
+		srv.lock.Lock()
+		srv.conns[conn] = struct{}{}
+		srv.lock.Unlock()
+
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			if err := srv.serve(conn); err != nil && err != io.EOF {
+				srv.log.Debug("Remote database client dropped", "remote", conn.RemoteAddr(), "err", err)
+			}
+			srv.lock.Lock()
+			delete(srv.conns, conn)
+			srv.lock.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serve authenticates a single client and answers its requests.
+func (srv *RemoteServer) serve(conn net.Conn) error {
+	nonce := make([]byte, remoteNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(remoteTimeout))
+	if _, err := conn.Write(nonce); err != nil {
+		return err
+	}
+	answer := make([]byte, remoteNonceSize+sha256.Size)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return err
+	}
+	clientNonce, proof := answer[:remoteNonceSize], answer[remoteNonceSize:]
+	if !hmac.Equal(proof, remoteAuth(srv.secret, remoteClientRole, nonce, clientNonce)) {
+		srv.log.Warn("Rejected unauthenticated remote database client", "remote", conn.RemoteAddr())
+		return ErrRemoteAuth
+	}
+	reply := append([]byte{remoteStatusOK}, remoteAuth(srv.secret, remoteServerRole, nonce, clientNonce)...)
+	if _, err := conn.Write(reply); err != nil {
+		return err
+	}
+	srv.log.Debug("Remote database client connected", "remote", conn.RemoteAddr())
+
+	for {
+		conn.SetDeadline(time.Time{})
+		op, key, err := readRemoteFrame(conn, remoteMaxKeySize)
+		if err != nil {
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(remoteTimeout))
+
+		var (
+			status  byte = remoteStatusOK
+			payload []byte
+		)
+		switch op {
+		case remoteOpGet:
+			if payload, err = srv.db.Get(key); err != nil {
+				if has, herr := srv.db.Has(key); herr == nil && !has {
+					status, payload = remoteStatusNotFound, nil
+				} else {
+					status, payload = remoteStatusError, []byte(err.Error())
+				}
+			}
+		case remoteOpHas:
+			has, err := srv.db.Has(key)
+			switch {
+			case err != nil:
+				status, payload = remoteStatusError, []byte(err.Error())
+			case !has:
+				status = remoteStatusNotFound
+			}
+		default:
+			return fmt.Errorf("unknown remote database opcode %#x", op)
+		}
+		if err := writeRemoteFrame(conn, status, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// RemoteDatabase reads the contents of a database served by a RemoteServer,
+// typically the chain database of a node syncing the network.
+//
+// The remote database is never modified. Writes are retained in memory and
+// shadow the remote contents, so local services maintaining their own
+// bookkeeping keep working. The retained writes are capped at remoteMaxOverlay
+// bytes, beyond which the least recently written keys are forgotten and read
+// from the remote database again.
+type RemoteDatabase struct {
+	addr   string
+	secret []byte
+	idle   chan net.Conn // Authenticated connections ready for reuse
+
+	overlay map[string]*list.Element // Local modifications, indexing into order
+	order   *list.List               // Local modifications, least recently written first
+	size    int                      // Total size of the local modifications
+	lock    sync.RWMutex
+
+	log log.Logger
+}
+
+// NewRemoteDatabase connects to the database served at the given address,
+// failing if the server is unreachable or the secret is rejected.
+func NewRemoteDatabase(addr string, secret []byte) (*RemoteDatabase, error) {
+	db := &RemoteDatabase{
+		addr:    addr,
+		secret:  secret,
+		idle:    make(chan net.Conn, remoteMaxIdleConns),
+		overlay: make(map[string]*list.Element),
+		order:   list.New(),
+		log:     log.New("database", addr),
+	}
+	conn, err := db.dial()
+	if err != nil {
+		return nil, err
+	}
+	db.release(conn)
+
+	db.log.Info("Connected to remote database")
+	return db, nil
+}
+
+// dial opens and authenticates a new connection to the server.
+func (db *RemoteDatabase) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", db.addr, remoteTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(remoteTimeout))
+
+	nonce := make([]byte, remoteNonceSize)
+	if _, err := io.ReadFull(conn, nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	clientNonce := make([]byte, remoteNonceSize)
+	if _, err := rand.Read(clientNonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	answer := append(clientNonce, remoteAuth(db.secret, remoteClientRole, nonce, clientNonce)...)
+	if _, err := conn.Write(answer); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Only trust the server once it proved knowing the secret too
+	reply := make([]byte, 1+sha256.Size)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[0] != remoteStatusOK {
+		conn.Close()
+		return nil, ErrRemoteAuth
+	}
+	if !hmac.Equal(reply[1:], remoteAuth(db.secret, remoteServerRole, nonce, clientNonce)) {
+		conn.Close()
+		db.log.Warn("Remote database failed to authenticate")
+		return nil, ErrRemoteAuth
+	}
+	return conn, nil
+}
+
+// release returns a healthy connection to the idle pool.
+func (db *RemoteDatabase) release(conn net.Conn) {
+	select {
+	case db.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// request sends a single request to the server, preferring an idle connection.
+// Pooled connections may have gone stale if the serving node restarted, so a
+// failed request is retried once on a fresh connection.
+func (db *RemoteDatabase) request(op byte, key []byte) (byte, []byte, error) {
+	select {
+	case conn := <-db.idle:
+		if status, payload, err := db.roundtrip(conn, op, key); err == nil {
+			return status, payload, nil
+		}
+	default:
+	}
+	conn, err := db.dial()
+	if err != nil {
+		return 0, nil, err
+	}
+	return db.roundtrip(conn, op, key)
+}
+
+// roundtrip sends a request over the connection and reads its response. The
+// connection is closed on failure and returned to the pool otherwise.
+func (db *RemoteDatabase) roundtrip(conn net.Conn, op byte, key []byte) (byte, []byte, error) {
+	conn.SetDeadline(time.Now().Add(remoteTimeout))
+	if err := writeRemoteFrame(conn, op, key); err != nil {
+		conn.Close()
+		return 0, nil, err
+	}
+	status, payload, err := readRemoteFrame(conn, remoteMaxValueSize)
+	if err != nil {
+		conn.Close()
+		return 0, nil, err
+	}
+	db.release(conn)
+	return status, payload, nil
+}
+
+// overlayEntry is a local modification, a nil value marks a deletion.
+type overlayEntry struct {
+	key   string
+	value []byte
+}
+
+// Put stores the value locally, the remote database is left untouched.
+func (db *RemoteDatabase) Put(key []byte, value []byte) error {
+	db.lock.Lock()
+	db.shadow(string(key), append([]byte{}, value...))
+	db.lock.Unlock()
+	return nil
+}
+
+// Delete hides the key locally, the remote database is left untouched.
+func (db *RemoteDatabase) Delete(key []byte) error {
+	db.lock.Lock()
+	db.shadow(string(key), nil)
+	db.lock.Unlock()
+	return nil
+}
+
+// shadow records a local modification, forgetting the least recently written
+// ones if the overlay grows too large. The caller must hold the write lock.
+func (db *RemoteDatabase) shadow(key string, value []byte) {
+	if elem, ok := db.overlay[key]; ok {
+		entry := elem.Value.(*overlayEntry)
+		db.size += len(value) - len(entry.value)
+		entry.value = value
+		db.order.MoveToBack(elem)
+	} else {
+		db.overlay[key] = db.order.PushBack(&overlayEntry{key: key, value: value})
+		db.size += len(key) + len(value)
+	}
+	for db.size > remoteMaxOverlay && db.order.Len() > 1 {
+		entry := db.order.Remove(db.order.Front()).(*overlayEntry)
+		delete(db.overlay, entry.key)
+		db.size -= len(entry.key) + len(entry.value)
+	}
+}
+
+// local returns the local modification of the key, if there is one.
+func (db *RemoteDatabase) local(key []byte) ([]byte, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if elem, ok := db.overlay[string(key)]; ok {
+		return elem.Value.(*overlayEntry).value, true
+	}
+	return nil, false
+}
+
+// Get returns the given key if it's present.
+func (db *RemoteDatabase) Get(key []byte) ([]byte, error) {
+	if value, ok := db.local(key); ok {
+		if value == nil {
+			return nil, ErrRemoteNotFound
+		}
+		return append([]byte{}, value...), nil
+	}
+	status, payload, err := db.request(remoteOpGet, key)
+	if err != nil {
+		return nil, err
+	}
+	return remoteResult(status, payload)
+}
+
+func (db *RemoteDatabase) Has(key []byte) (bool, error) {
+	if value, ok := db.local(key); ok {
+		return value != nil, nil
+	}
+	status, payload, err := db.request(remoteOpHas, key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := remoteResult(status, payload); err != nil {
+		if err == ErrRemoteNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Close drops all pooled connections and discards the local modifications.
+func (db *RemoteDatabase) Close() {
+	for {
+		select {
+		case conn := <-db.idle:
+			conn.Close()
+		default:
+			db.lock.Lock()
+			db.overlay = make(map[string]*list.Element)
+			db.order.Init()
+			db.size = 0
+			db.lock.Unlock()
+
+			db.log.Info("Database closed")
+			return
+		}
+	}
+}
+
+func (db *RemoteDatabase) NewBatch() Batch {
+	return &remoteBatch{db: db}
+}
+
+type remoteBatch struct {
+	db     *RemoteDatabase
+	writes []kv
+	size   int
+}
+
+func (b *remoteBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{append([]byte{}, key...), append([]byte{}, value...), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *remoteBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{append([]byte{}, key...), nil, true})
+	b.size++
+	return nil
+}
+
+func (b *remoteBatch) Write() error {
+	b.db.lock.Lock()
+	defer b.db.lock.Unlock()
+
+	for _, w := range b.writes {
+		b.db.shadow(string(w.k), w.v)
+	}
+	return nil
+}
+
+func (b *remoteBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *remoteBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// remoteAuth computes the proof of the client or the server of knowing the
+// secret, binding the nonces of both so that it cannot be replayed.
+func remoteAuth(secret []byte, role string, serverNonce, clientNonce []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(role))
+	mac.Write(serverNonce)
+	mac.Write(clientNonce)
+	return mac.Sum(nil)
+}
+
+// remoteResult converts a response into the value or error it carries.
+func remoteResult(status byte, payload []byte) ([]byte, error) {
+	switch status {
+	case remoteStatusOK:
+		return payload, nil
+	case remoteStatusNotFound:
+		return nil, ErrRemoteNotFound
+	case remoteStatusError:
+		return nil, fmt.Errorf("remote database: %s", payload)
+	default:
+		return nil, fmt.Errorf("remote database: unknown status %#x", status)
+	}
+}
+
+// writeRemoteFrame sends a type byte followed by a length-prefixed payload.
+func writeRemoteFrame(w io.Writer, kind byte, payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = kind
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readRemoteFrame reads a type byte followed by a length-prefixed payload of at
+// most limit bytes.
+func readRemoteFrame(r io.Reader, limit int) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > uint32(limit) {
+		return 0, nil, fmt.Errorf("remote database frame too large: %d > %d", size, limit)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquadb_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+func newTestRemote(t *testing.T, secret []byte) (*aquadb.MemDatabase, *aquadb.RemoteServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := aquadb.NewMemDatabase()
+	for _, v := range test_values {
+		backend.Put([]byte("key"+v), []byte(v))
+	}
+	return backend, aquadb.NewRemoteServer(listener, backend, secret)
+}
+
+func TestRemote_Get(t *testing.T) {
+	_, srv := newTestRemote(t, []byte("secret"))
+	defer srv.Close()
+
+	db, err := aquadb.NewRemoteDatabase(srv.Addr().String(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	for _, v := range test_values {
+		data, err := db.Get([]byte("key" + v))
+		if err != nil {
+			t.Fatalf("get failed for %q: %v", v, err)
+		}
+		if !bytes.Equal(data, []byte(v)) {
+			t.Fatalf("get returned wrong result, got %q expected %q", string(data), v)
+		}
+		if has, err := db.Has([]byte("key" + v)); !has || err != nil {
+			t.Fatalf("has failed for %q: %v %v", v, has, err)
+		}
+	}
+	if _, err := db.Get([]byte("missing")); err != aquadb.ErrRemoteNotFound {
+		t.Fatalf("missing key error mismatch: have %v, want %v", err, aquadb.ErrRemoteNotFound)
+	}
+	if has, err := db.Has([]byte("missing")); has || err != nil {
+		t.Fatalf("missing key reported present: %v %v", has, err)
+	}
+}
+
+func TestRemote_LocalWrites(t *testing.T) {
+	backend, srv := newTestRemote(t, []byte("secret"))
+	defer srv.Close()
+
+	db, err := aquadb.NewRemoteDatabase(srv.Addr().String(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	// Writes and deletions must shadow the remote data without modifying it
+	db.Put([]byte("keya"), []byte("local"))
+	db.Delete([]byte("key1251"))
+
+	batch := db.NewBatch()
+	batch.Put([]byte("new"), []byte("value"))
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	if data, err := db.Get([]byte("keya")); err != nil || string(data) != "local" {
+		t.Fatalf("local write not visible: %q %v", data, err)
+	}
+	if has, _ := db.Has([]byte("key1251")); has {
+		t.Fatalf("local deletion not visible")
+	}
+	if data, err := db.Get([]byte("new")); err != nil || string(data) != "value" {
+		t.Fatalf("batch write not visible: %q %v", data, err)
+	}
+	if data, _ := backend.Get([]byte("keya")); string(data) != "a" {
+		t.Fatalf("remote database modified: %q", data)
+	}
+	if has, _ := backend.Has([]byte("key1251")); !has {
+		t.Fatalf("remote database key deleted")
+	}
+	if has, _ := backend.Has([]byte("new")); has {
+		t.Fatalf("remote database received batch")
+	}
+}
+
+func TestRemote_LocalWritesBounded(t *testing.T) {
+	_, srv := newTestRemote(t, []byte("secret"))
+	defer srv.Close()
+
+	db, err := aquadb.NewRemoteDatabase(srv.Addr().String(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	// Shadow a remote key, then keep writing until the overlay overflows
+	db.Put([]byte("keya"), []byte("local"))
+	db.Put([]byte("keyb"), []byte("local"))
+
+	value := make([]byte, 1<<20)
+	for i := 0; i < 80; i++ {
+		db.Put([]byte{byte(i)}, value)
+		if i == 40 {
+			db.Put([]byte("keyb"), []byte("rewritten"))
+		}
+	}
+	if data, err := db.Get([]byte("keya")); err != nil || string(data) != "a" {
+		t.Fatalf("stale local write not dropped: %q %v", data, err)
+	}
+	if data, err := db.Get([]byte("keyb")); err != nil || string(data) != "rewritten" {
+		t.Fatalf("recent local write dropped: %q %v", data, err)
+	}
+	if data, err := db.Get([]byte{79}); err != nil || len(data) != len(value) {
+		t.Fatalf("latest local write dropped: %d bytes, %v", len(data), err)
+	}
+	if _, err := db.Get([]byte{0}); err != aquadb.ErrRemoteNotFound {
+		t.Fatalf("oldest local write error mismatch: have %v, want %v", err, aquadb.ErrRemoteNotFound)
+	}
+}
+
+func TestRemote_BadSecret(t *testing.T) {
+	_, srv := newTestRemote(t, []byte("secret"))
+	defer srv.Close()
+
+	if _, err := aquadb.NewRemoteDatabase(srv.Addr().String(), []byte("wrong")); err != aquadb.ErrRemoteAuth {
+		t.Fatalf("authentication error mismatch: have %v, want %v", err, aquadb.ErrRemoteAuth)
+	}
+}
+
+// Tests that clients reject a server which accepts them without knowing the
+// secret, like an impostor serving forged chain data.
+func TestRemote_BadServerSecret(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		nonce := make([]byte, 32)
+		conn.Write(nonce)
+		answer := make([]byte, 32+sha256.Size)
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return
+		}
+		// Accept the client, proving the wrong secret
+		mac := hmac.New(sha256.New, []byte("wrong"))
+		mac.Write([]byte("server"))
+		mac.Write(nonce)
+		mac.Write(answer[:32])
+		conn.Write(append([]byte{0x00}, mac.Sum(nil)...))
+		io.Copy(ioutil.Discard, conn)
+	}()
+	if _, err := aquadb.NewRemoteDatabase(listener.Addr().String(), []byte("secret")); err != aquadb.ErrRemoteAuth {
+		t.Fatalf("authentication error mismatch: have %v, want %v", err, aquadb.ErrRemoteAuth)
+	}
+}
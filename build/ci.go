@@ -28,6 +28,7 @@ import (
 	"go/token"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -48,9 +49,12 @@ Usage: go run build/ci.go <command> <command flags/arguments>
 Available commands are:
 
    install    [ -arch architecture ] [ -cc compiler ] [ -musl ] [-race] [ packages... ]        -- builds packages and executables
-   test       [ -coverage ] [ packages... ]                                                    -- runs the tests
+   test       [ -coverage ] [ -shard i/n ] [ -junit file ] [ packages... ]                     -- runs the tests
    lint                                                                                        -- runs certain pre-selected linters
-   archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ] [ -upload dest ] -- archives build artefacts
+   archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ]                  -- archives build artefacts and updates SHA256SUMS
+   release    [ -notes file ] [ -token key-envvar ]                                             -- publishes archives, SHA256SUMS and signatures as a GitLab release
+   docker     [ -platforms linux/arch,... ] [ -repo repository ] [ -push ]                     -- builds multi-arch Docker images
+   rpm        [ -signer key-name ] [ -workdir dir ]                                             -- builds RPM packages
    importkeys                                                                                  -- imports signing keys from env
    nsis                                                                                        -- creates a Windows NSIS installer
    aar        [ -local ] [ -sign key-id ] [-deploy repo] [ -upload dest ]                      -- creates an Android archive
@@ -74,35 +78,20 @@ var (
 	allToolsArchiveFiles = []string{
 		"COPYING",
 		"README.md",
-		executablePath("aqua-abigen"),
-		executablePath("aqua-maw"),
-		executablePath("aqua-puppet"),
-		executablePath("aqua-rlpdump"),
-		executablePath("aqua-swarm"),
-		executablePath("aqua-vm"),
-		executablePath("aqua-wnode"),
 		executablePath("aquabootnode"),
-		executablePath("aquakey"),
-		executablePath("aquap2psim"),
-		executablePath("aquapaper"),
 		executablePath("aquachain"),
 		executablePath("aquaminer"),
+		executablePath("aquapaper"),
+		executablePath("aquastrat"),
+		executablePath("attacher"),
 	}
 
 	// A debian package is created for all executables listed here.
 	debExecutables = []debExecutable{
 		{
-			Name:        "aqua-bootnode",
+			Name:        "aquabootnode",
 			Description: "AquaChain bootnode (discovery-only)",
 		},
-		{
-			Name:        "aqua-vm",
-			Description: "Developer utility version of the AVM (AquaChain Virtual Machine) that is capable of running bytecode snippets within a configurable environment and execution mode",
-		},
-		{
-			Name:        "aqua-rlpdump",
-			Description: "Developer utility tool that prints RLP structures",
-		},
 		{
 			Name:        "aquachain",
 			Description: "AquaChain Full Node and Command Line Wallet",
@@ -142,8 +131,14 @@ func main() {
 		doLint(os.Args[2:])
 	case "archive":
 		doArchive(os.Args[2:])
+	case "release":
+		doRelease(os.Args[2:])
+	case "docker":
+		doDocker(os.Args[2:])
 	case "debsrc":
 		doDebianSource(os.Args[2:])
+	case "rpm":
+		doRPM(os.Args[2:])
 	case "nsis":
 		doWindowsInstaller(os.Args[2:])
 	case "aar":
@@ -169,11 +164,7 @@ func doInstall(cmdline []string) {
 	// Check Go version. People regularly open issues about compilation
 	// failure with outdated Go. This should save them the trouble.
 	if !strings.Contains(runtime.Version(), "devel") {
-		// Figure out the minor version number since we can't textually compare (1.10 < 1.7)
-		var minor int
-		fmt.Sscanf(strings.TrimPrefix(runtime.Version(), "go1."), "%d", &minor)
-
-		if minor < 10 {
+		if goMinorVersion() < 10 {
 			log.Println("You have Go version", runtime.Version())
 			log.Println("aquachain requires at least Go version 1.10 and cannot")
 			log.Println("be compiled with an earlier version. Please upgrade your Go installation.")
@@ -214,24 +205,42 @@ func doInstall(cmdline []string) {
 	goinstall.Args = append(goinstall.Args, packages...)
 	build.MustRun(goinstall)
 
+	for _, cmd := range commands() {
+		gobuild := goToolArch(*arch, *cc, "build", buildFlags(env)...)
+		gobuild.Args = append(gobuild.Args, "-v")
+		gobuild.Args = append(gobuild.Args, []string{"-o", executablePath(cmd)}...)
+		gobuild.Args = append(gobuild.Args, "."+string(filepath.Separator)+filepath.Join("cmd", cmd))
+		build.MustRun(gobuild)
+	}
+}
+
+// commands returns the names of the executables in the cmd directory.
+func commands() []string {
+	var names []string
 	if cmds, err := ioutil.ReadDir("cmd"); err == nil {
 		for _, cmd := range cmds {
 			pkgs, err := parser.ParseDir(token.NewFileSet(), filepath.Join(".", "cmd", cmd.Name()), nil, parser.PackageClauseOnly)
 			if err != nil {
 				log.Fatal(err)
 			}
-			for name := range pkgs {
-				if name == "main" {
-					gobuild := goToolArch(*arch, *cc, "build", buildFlags(env)...)
-					gobuild.Args = append(gobuild.Args, "-v")
-					gobuild.Args = append(gobuild.Args, []string{"-o", executablePath(cmd.Name())}...)
-					gobuild.Args = append(gobuild.Args, "."+string(filepath.Separator)+filepath.Join("cmd", cmd.Name()))
-					build.MustRun(gobuild)
-					break
-				}
+			if _, ok := pkgs["main"]; ok {
+				names = append(names, cmd.Name())
 			}
 		}
 	}
+	return names
+}
+
+// goMinorVersion returns the minor version number of the Go toolchain, since
+// versions can't be compared textually (1.10 < 1.7). Development versions
+// are considered to be the newest.
+func goMinorVersion() int {
+	if strings.Contains(runtime.Version(), "devel") {
+		return math.MaxInt32
+	}
+	var minor int
+	fmt.Sscanf(strings.TrimPrefix(runtime.Version(), "go1."), "%d", &minor)
+	return minor
 }
 
 func buildFlags(env build.Environment) (flags []string) {
@@ -243,6 +252,12 @@ func buildFlags(env build.Environment) (flags []string) {
 	ld = append(ld, "-s")
 	ld = append(ld, "-w")
 
+	// reproducible builds, independent of the build directory and host
+	ld = append(ld, "-buildid=")
+	if goMinorVersion() >= 13 {
+		flags = append(flags, "-trimpath")
+	}
+
 	// use go if possible (net, os/user)
 	tags = append(tags, "netgo", "osusergo")
 
@@ -326,6 +341,8 @@ func doTest(cmdline []string) {
 	var (
 		coverage          = flag.Bool("coverage", false, "Whether to record code coverage")
 		verbose           = flag.Bool("v", false, "Verbose logs")
+		shard             = flag.String("shard", "", `Only test shard i of n ("i/n", counting from 1)`)
+		junit             = flag.String("junit", "", `Write the test results as JUnit XML to this file`)
 		shortpkg, longpkg []string
 		allpkgs           = []string{"./..."}
 		args              []string
@@ -338,9 +355,21 @@ func doTest(cmdline []string) {
 	}
 
 	allpkgs, shortpkg, longpkg = build.ExpandPackagesNoVendor(allpkgs)
+	if *shard != "" {
+		index, total, err := parseShard(*shard)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shortpkg, longpkg = shardPackages(shortpkg, longpkg, index, total)
+		allpkgs = append(append([]string(nil), shortpkg...), longpkg...)
+		fmt.Printf("Shard %d of %d\n", index, total)
+	}
 
 	fmt.Println("Quick tests:", len(shortpkg))
 	fmt.Println("Slow tests: ", len(longpkg))
+	if len(allpkgs) == 0 {
+		return
+	}
 
 	// Run analysis tools on all packages before the tests.
 	build.MustRun(goTool("vet", allpkgs...))
@@ -349,11 +378,8 @@ func doTest(cmdline []string) {
 	gotestLong := goTool("test", buildFlags(env)...)
 	// Test a single package at a time. CI builders are slow
 	// and some tests run into timeouts under load.
-	args = append(gotestShort.Args, "-p", "1", "-timeout", "0")
+	args = append(gotestShort.Args, "-p", "1", "-timeout", "0", "-json")
 
-	if *verbose {
-		args = append(args, "-v")
-	}
 	if *coverage {
 		args = append(args, "-covermode=atomic", "-cover")
 	}
@@ -362,20 +388,66 @@ func doTest(cmdline []string) {
 	gotestLong.Args = args
 
 	// Run the actual tests, with verbose flag on long running pkgs
+	var (
+		report = new(build.TestReport)
+		failed error
+	)
 	if len(shortpkg) > 0 {
 		fmt.Println("Running fast package tests")
 		gotestShort.Args = append(gotestShort.Args, shortpkg...)
-		build.MustRun(gotestShort)
+		report.Verbose = *verbose
+		failed = report.Run(gotestShort)
 	}
 
 	// Run long packages last, most likely wont be the one failing
 	if len(longpkg) > 0 {
 		fmt.Println("Running long-running package tests")
-		gotestLong.Args = append(gotestLong.Args, "-v")
 		gotestLong.Args = append(gotestLong.Args, longpkg...)
-		build.MustRun(gotestLong)
+		report.Verbose = true
+		if err := report.Run(gotestLong); failed == nil {
+			failed = err
+		}
+	}
+
+	if len(report.Packages) > 0 {
+		fmt.Println("Package timings:")
+		report.PrintTimings(os.Stdout)
+	}
+	if *junit != "" {
+		if err := report.WriteJUnit(*junit); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed != nil {
+		log.Fatal(failed)
 	}
+}
+
+// parseShard parses the -shard flag of the test command.
+func parseShard(s string) (index, total int, err error) {
+	if _, err := fmt.Sscanf(s, "%d/%d", &index, &total); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q, want i/n", s)
+	}
+	if total < 1 || index < 1 || index > total {
+		return 0, 0, fmt.Errorf("invalid shard %q, need 1 <= i <= n", s)
+	}
+	return index, total, nil
+}
 
+// shardPackages returns the packages tested by the given shard. Packages are
+// dealt out in turn, slow ones first so they end up on different shards.
+func shardPackages(short, long []string, index, total int) (shardShort, shardLong []string) {
+	for i, pkg := range append(append([]string(nil), long...), short...) {
+		if i%total != index-1 {
+			continue
+		}
+		if i < len(long) {
+			shardLong = append(shardLong, pkg)
+		} else {
+			shardShort = append(shardShort, pkg)
+		}
+	}
+	return shardShort, shardLong
 }
 
 // runs gometalinter on requested packages
@@ -410,21 +482,27 @@ func doLint(cmdline []string) {
 }
 
 func doImportTest(cmdline []string) {
+	verify := flag.Bool("verify", false, "Verify the checksums of the bootstrap file before importing it")
 	flag.CommandLine.Parse(cmdline)
 	build.Env()
 	bootstrap := "bootstrap.dat"
-	if len(cmdline) == 1 {
-		bootstrap = cmdline[0]
+	if flag.NArg() == 1 {
+		bootstrap = flag.Arg(0)
 	}
-	build.MustRunCommand(filepath.Join(GOBIN, "aquachain"), "import", bootstrap)
+	args := []string{"import"}
+	if *verify {
+		args = append(args, "--verify")
+	}
+	build.MustRunCommand(filepath.Join(GOBIN, "aquachain"), append(args, bootstrap)...)
 }
 
 // Release Packaging
 func doArchive(cmdline []string) {
 	var (
-		arch  = flag.String("arch", runtime.GOARCH, "Architecture cross packaging")
-		atype = flag.String("type", "zip", "Type of archive to write (zip|tar)")
-		ext   string
+		arch   = flag.String("arch", runtime.GOARCH, "Architecture cross packaging")
+		atype  = flag.String("type", "zip", "Type of archive to write (zip|tar)")
+		signer = flag.String("signer", "", `Environment variable holding the signing key (e.g. LINUX_SIGNING_KEY)`)
+		ext    string
 	)
 	flag.CommandLine.Parse(cmdline)
 	switch *atype {
@@ -449,6 +527,102 @@ func doArchive(cmdline []string) {
 	if err := build.WriteArchive(alltools, allToolsArchiveFiles); err != nil {
 		log.Fatal(err)
 	}
+	// List the archives in the checksum manifest, shared by all archive runs
+	if err := build.UpdateChecksums(checksumManifest, []string{aquachain, alltools}); err != nil {
+		log.Fatal(err)
+	}
+	if *signer != "" {
+		for _, file := range []string{aquachain, alltools, checksumManifest} {
+			if err := archiveSign(file, *signer); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// checksumManifest is the file listing the SHA256 checksums of all archives.
+const checksumManifest = "SHA256SUMS"
+
+// archiveSign creates a detached PGP signature of a file, using the armored
+// key held in the given environment variable.
+func archiveSign(file, keyVar string) error {
+	key := os.Getenv(keyVar)
+	if key == "" {
+		return fmt.Errorf("signing key variable %s is empty", keyVar)
+	}
+	return build.PGPSignFile(file, file+".asc", key)
+}
+
+// doRelease publishes the archives listed in the checksum manifest, along
+// with the manifest and all signatures, as the GitLab release of the tag.
+func doRelease(cmdline []string) {
+	var (
+		api      = flag.String("api", envDefault("CI_API_V4_URL", "https://gitlab.com/api/v4"), `GitLab API base URL`)
+		project  = flag.String("project", envDefault("CI_PROJECT_PATH", "aquachain/aquachain"), `GitLab project path`)
+		tokenVar = flag.String("token", "GITLAB_TOKEN", `Environment variable holding the GitLab API token`)
+		notes    = flag.String("notes", "", `File with the release notes (markdown)`)
+	)
+	flag.CommandLine.Parse(cmdline)
+	env := build.Env()
+	if env.Tag == "" {
+		log.Fatal("release needs a tagged build")
+	}
+
+	archives, err := build.ChecksumFiles(checksumManifest)
+	if err != nil {
+		log.Fatal("can't read checksum manifest: ", err)
+	}
+	var files []string
+	for _, file := range append(archives, checksumManifest) {
+		files = append(files, file)
+		if _, err := os.Stat(file + ".asc"); err == nil {
+			files = append(files, file+".asc")
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sums, err := ioutil.ReadFile(checksumManifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	description := "SHA256 checksums:\n\n```\n" + string(sums) + "```\n"
+	if *notes != "" {
+		text, err := ioutil.ReadFile(*notes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		description = string(text) + "\n" + description
+	}
+	rel := build.GitLabRelease{
+		API:         *api,
+		Project:     *project,
+		Token:       os.Getenv(*tokenVar),
+		Tag:         env.Tag,
+		Name:        "AquaChain " + env.Tag,
+		Description: description,
+	}
+	fmt.Printf(">>> publishing release %s of %s: %s\n", rel.Tag, rel.Project, strings.Join(files, " "))
+	if *build.DryRunFlag {
+		return
+	}
+	if rel.Token == "" {
+		log.Fatalf("token variable %s is empty", *tokenVar)
+	}
+	if err := build.PublishGitLabRelease(rel, files); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// envDefault returns the value of an environment variable, or def if unset.
+func envDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
 }
 
 func archiveBasename(arch string, env build.Environment) string {
@@ -492,6 +666,92 @@ func maybeSkipArchive(env build.Environment) {
 	}
 }
 
+// Docker images
+
+// dockerImages are the images built by the docker command, with the
+// executables contained (nil meaning all of them) and run by default.
+var dockerImages = []dockerImage{
+	{Name: "aquachain", Executables: []string{"aquachain"}, Cmd: "aquachain"},
+	{Name: "aquachain-alltools", Cmd: "aquachain"},
+}
+
+type dockerImage struct {
+	Name        string
+	Executables []string
+	Cmd         string
+}
+
+func doDocker(cmdline []string) {
+	var (
+		platforms = flag.String("platforms", "linux/amd64,linux/arm64", "Comma separated platforms to build the images for")
+		repo      = flag.String("repo", "registry.gitlab.com/aquachain/aquachain", "Repository the images are tagged in")
+		push      = flag.Bool("push", false, "Push the images to the repository")
+		workdir   = flag.String("workdir", "", `Output directory for the image build contexts (uses temp dir if unset)`)
+	)
+	flag.CommandLine.Parse(cmdline)
+	*workdir = makeWorkdir(*workdir)
+	env := build.Env()
+	if *push {
+		maybeSkipArchive(env)
+	}
+
+	var arches []string
+	for _, platform := range strings.Split(*platforms, ",") {
+		parts := strings.Split(strings.TrimSpace(platform), "/")
+		if len(parts) != 2 || parts[0] != "linux" {
+			log.Fatalf("unsupported platform %q, want linux/<arch>", platform)
+		}
+		arches = append(arches, parts[1])
+	}
+	// Cross compile static executables once per architecture
+	exes := commands()
+	for _, arch := range arches {
+		for _, exe := range exes {
+			gobuild := goToolArch(arch, "", "build", buildFlags(env)...)
+			gobuild.Env = append(gobuild.Env, "GOOS=linux", "CGO_ENABLED=0")
+			gobuild.Args = append(gobuild.Args, "-o", filepath.Join(*workdir, "bin", arch, exe))
+			gobuild.Args = append(gobuild.Args, "."+string(filepath.Separator)+filepath.Join("cmd", exe))
+			build.MustRun(gobuild)
+		}
+	}
+	// Stage the executables of each image and build it for all platforms
+	tags := []string{archiveVersion(env), "unstable"}
+	if !isUnstableBuild(env) {
+		tags[1] = "latest"
+	}
+	for _, image := range dockerImages {
+		context := filepath.Join(*workdir, image.Name)
+		contents := image.Executables
+		if contents == nil {
+			contents = exes
+		}
+		for _, arch := range arches {
+			for _, exe := range contents {
+				src := filepath.Join(*workdir, "bin", arch, exe)
+				if !*build.DryRunFlag {
+					build.CopyFile(filepath.Join(context, "bin", arch, exe), src, 0755)
+				}
+			}
+		}
+		dockerfile := filepath.Join(context, "Dockerfile")
+		build.Render("build/docker.Dockerfile", dockerfile, 0644, image)
+
+		args := []string{"buildx", "build", "--platform", *platforms, "--file", dockerfile}
+		for _, tag := range tags {
+			args = append(args, "--tag", *repo+"/"+image.Name+":"+tag)
+		}
+		switch {
+		case *push:
+			args = append(args, "--push")
+		case len(arches) == 1:
+			args = append(args, "--load")
+		default:
+			log.Printf("multi-platform image %s is only kept in the build cache, use -push to publish it", image.Name)
+		}
+		build.MustRunCommand("docker", append(args, context)...)
+	}
+}
+
 // Debian Packaging
 
 func doDebianSource(cmdline []string) {
@@ -671,6 +931,112 @@ func stageDebianSource(tmpdir string, meta debMetadata) (pkgdir string) {
 	return pkgdir
 }
 
+// RPM Packaging
+
+func doRPM(cmdline []string) {
+	var (
+		signer  = flag.String("signer", "", `Signing key name, also used as package author`)
+		workdir = flag.String("workdir", "", `Output directory for packages (uses temp dir if unset)`)
+		now     = time.Now()
+	)
+	flag.CommandLine.Parse(cmdline)
+	*workdir = makeWorkdir(*workdir)
+	env := build.Env()
+	maybeSkipArchive(env)
+
+	// Import the signing key.
+	if b64key := os.Getenv("RPM_SIGNING_KEY"); b64key != "" {
+		key, err := base64.StdEncoding.DecodeString(b64key)
+		if err != nil {
+			log.Fatal("invalid base64 RPM_SIGNING_KEY")
+		}
+		gpg := exec.Command("gpg", "--import")
+		gpg.Stdin = bytes.NewReader(key)
+		build.MustRun(gpg)
+	}
+
+	// Create the packages.
+	meta := newRPMMetadata(*signer, env, now)
+	topdir, spec := stageRPMSource(*workdir, meta)
+	build.MustRunCommand("rpmbuild", "-ba", "--define", "_topdir "+topdir, spec)
+
+	if *signer != "" {
+		rpms, err := filepath.Glob(filepath.Join(topdir, "RPMS", "*", "*.rpm"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		srpms, err := filepath.Glob(filepath.Join(topdir, "SRPMS", "*.rpm"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		args := []string{"--addsign", "--define", "_gpg_name " + *signer}
+		build.MustRunCommand("rpmsign", append(append(args, rpms...), srpms...)...)
+	}
+}
+
+type rpmMetadata struct {
+	debMetadata
+
+	// Release is the RPM release number of the packages.
+	Release string
+}
+
+func newRPMMetadata(author string, env build.Environment, t time.Time) rpmMetadata {
+	meta := rpmMetadata{
+		debMetadata: newDebMetadata("", author, env, t),
+		Release:     "1",
+	}
+	// RPM changelog entries need the date without the time of day.
+	meta.Time = t.Format("Mon Jan 2 2006")
+	if env.Buildnum != "" {
+		meta.Release = env.Buildnum
+	}
+	return meta
+}
+
+// Main returns the executable packaged in the main package, which is
+// the one named like the metapackage. The main package depends on the
+// packages of all other executables.
+func (meta rpmMetadata) Main() debExecutable {
+	for _, exe := range meta.Executables {
+		if meta.ExeName(exe) == meta.Name() {
+			return exe
+		}
+	}
+	log.Fatalf("no executable for package %s", meta.Name())
+	return debExecutable{}
+}
+
+// SubPackages returns the executables packaged in subpackages.
+func (meta rpmMetadata) SubPackages() []debExecutable {
+	var exes []debExecutable
+	for _, exe := range meta.Executables {
+		if meta.ExeName(exe) != meta.Name() {
+			exes = append(exes, exe)
+		}
+	}
+	return exes
+}
+
+func stageRPMSource(tmpdir string, meta rpmMetadata) (topdir, spec string) {
+	topdir = filepath.Join(tmpdir, "rpmbuild")
+	for _, dir := range []string{"SOURCES", "SPECS"} {
+		if err := os.MkdirAll(filepath.Join(topdir, dir), 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Pack the source code into the tarball referenced by the spec.
+	pkg := meta.Name() + "-" + meta.Version
+	tarball := filepath.Join(topdir, "SOURCES", pkg+".tar.gz")
+	build.MustRunCommand("git", "archive", "--format=tar.gz", "--prefix="+pkg+"/", "-o", tarball, "HEAD")
+
+	// Put the spec file in place.
+	spec = filepath.Join(topdir, "SPECS", meta.Name()+".spec")
+	build.Render("build/rpm.spec", spec, 0644, meta)
+	return topdir, spec
+}
+
 // Windows installer
 
 func doWindowsInstaller(cmdline []string) {
@@ -0,0 +1,54 @@
+# Go binaries carry no separate debug information.
+%global debug_package %{nil}
+
+Name:           {{.Name}}
+Version:        {{.Version}}
+Release:        {{.Release}}%{?dist}
+Summary:        {{.Main.Description}}
+License:        GPLv3+ and LGPLv3+
+URL:            https://aquachain.org
+Source0:        %{name}-%{version}.tar.gz
+BuildRequires:  golang >= 1.12
+{{- range .SubPackages}}
+Requires:       {{$.ExeName .}} = %{version}-%{release}
+{{- end}}
+{{- with .ExeConflicts .Main}}
+Conflicts:      aquachain
+{{- end}}
+
+%description
+{{.Main.Description}}, along with the other AquaChain tools.
+{{range .SubPackages}}
+%package -n {{$.ExeName .}}
+Summary:        {{.Description}}
+{{- with $.ExeConflicts .}}
+Conflicts:      {{.}}
+{{- end}}
+
+%description -n {{$.ExeName .}}
+{{.Description}}.
+{{end}}
+%prep
+%setup -q
+
+%build
+build/env.sh go run build/ci.go install -git-commit={{.Env.Commit}} -git-branch={{.Env.Branch}} -git-tag={{.Env.Tag}} -buildnum={{.Env.Buildnum}} -pull-request={{.Env.IsPullRequest}}{{range .Executables}} ./cmd/{{.Name}}{{end}}
+
+%install
+{{- range .Executables}}
+install -D -m 0755 build/bin/{{.Name}} %{buildroot}%{_bindir}/{{.Name}}
+{{- end}}
+
+%files
+%license COPYING COPYING.LESSER
+%doc AUTHORS README.md
+%{_bindir}/{{.Main.Name}}
+{{range .SubPackages}}
+%files -n {{$.ExeName .}}
+%license COPYING COPYING.LESSER
+%doc AUTHORS README.md
+%{_bindir}/{{.Name}}
+{{end}}
+%changelog
+* {{.Time}} {{.Author}} - {{.Version}}-{{.Release}}
+- git build of {{.Env.Commit}}
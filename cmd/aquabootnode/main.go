@@ -23,9 +23,12 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"gitlab.com/aquachain/aquachain/cmd/utils"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
 	"gitlab.com/aquachain/aquachain/crypto"
 	"gitlab.com/aquachain/aquachain/p2p/discover"
 	"gitlab.com/aquachain/aquachain/p2p/discv5"
@@ -42,10 +45,16 @@ func main() {
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
 		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
+		netdeny     = flag.String("deny", "", "drop packets from the given IP networks (CIDR masks, v4 only)")
+		rateLimit   = flag.Int("ratelimit", 20, "maximum number of discovery packets per second accepted from a single IP (0 = unlimited, v4 only)")
 		runv5       = flag.Bool("v5", false, "run a v5 topic discovery bootnode")
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
 		chainid     = flag.Uint64("chainid", 61717561, "chain id for p2p communication (set to 3 for ethereum-like)")
+		nodeDBPath  = flag.String("nodedb", "", "directory to persist the node table in, reloaded on restart (empty = in memory)")
+		bucketSize  = flag.Int("bucketsize", 16, "maximum number of nodes per node table bucket")
+		seedCount   = flag.Int("seeds", 1000, "maximum number of nodes to reload from the node database on restart")
+		statsAddr   = flag.String(metrics.MetricsAddrFlag, "", "HTTP listen address for discovery statistics on /stats and metrics (empty = disabled)")
 		nodeKey     *ecdsa.PrivateKey
 		err         error
 	)
@@ -96,6 +105,13 @@ func main() {
 			utils.Fatalf("-netrestrict: %v", err)
 		}
 	}
+	var denyList *netutil.Netlist
+	if *netdeny != "" {
+		denyList, err = netutil.ParseNetlist(*netdeny)
+		if err != nil {
+			utils.Fatalf("-deny: %v", err)
+		}
+	}
 
 	addr, err := net.ResolveUDPAddr("udp", *listenAddr)
 	if err != nil {
@@ -117,21 +133,54 @@ func main() {
 		}
 	}
 
+	if *bucketSize <= 0 || *seedCount <= 0 {
+		utils.Fatalf("-bucketsize and -seeds must be positive")
+	}
+	if *rateLimit < 0 {
+		utils.Fatalf("-ratelimit must not be negative")
+	}
+	var (
+		closer interface{ Close() }
+		table  *discover.Table
+	)
 	if *runv5 {
-		if _, err := discv5.ListenUDP(nodeKey, conn, realaddr, "", restrictList); err != nil {
+		if denyList != nil {
+			utils.Fatalf("-deny is not supported with -v5")
+		}
+		tab, err := discv5.ListenUDP(nodeKey, conn, realaddr, *nodeDBPath, restrictList)
+		if err != nil {
 			utils.Fatalf("%v", err)
 		}
+		closer = tab
 	} else {
 		cfg := discover.Config{
 			PrivateKey:   nodeKey,
 			AnnounceAddr: realaddr,
+			NodeDBPath:   *nodeDBPath,
 			NetRestrict:  restrictList,
+			NetDeny:      denyList,
+			RateLimit:    *rateLimit,
 			ChainId:      *chainid,
+			BucketSize:   *bucketSize,
+			SeedCount:    *seedCount,
 		}
-		if _, err := discover.ListenUDP(conn, cfg); err != nil {
+		tab, err := discover.ListenUDP(conn, cfg)
+		if err != nil {
 			utils.Fatalf("%v", err)
 		}
+		closer, table = tab, tab
+	}
+	if *statsAddr != "" {
+		self := discover.PubkeyID(&nodeKey.PublicKey).String()
+		if err := startStatsServer(*statsAddr, self, table); err != nil {
+			utils.Fatalf("-%s: %v", metrics.MetricsAddrFlag, err)
+		}
 	}
 
-	select {}
+	// Close the table on shutdown, persisting it to the node database
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down bootnode")
+	closer.Close()
 }
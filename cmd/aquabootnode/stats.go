@@ -0,0 +1,93 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of aquachain.
+//
+// aquachain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// aquachain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with aquachain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/common/metrics/exp"
+	"gitlab.com/aquachain/aquachain/common/metrics/prometheus"
+	"gitlab.com/aquachain/aquachain/p2p/discover"
+)
+
+// trafficStats are the totals and recent rates of a discovery traffic meter.
+type trafficStats struct {
+	Count int64   `json:"count"`
+	Rate1 float64 `json:"rate1m"` // per second, one minute moving average
+	Rate5 float64 `json:"rate5m"` // per second, five minute moving average
+}
+
+// bootnodeStats is the JSON document served on /stats.
+type bootnodeStats struct {
+	Self    string                  `json:"self"`
+	Uptime  int64                   `json:"uptime"` // seconds
+	Table   *discover.TableStats    `json:"table,omitempty"`
+	Traffic map[string]trafficStats `json:"traffic"` // by meter, e.g. ingress/ping
+}
+
+// startStatsServer serves the bootnode statistics as JSON on /stats, next to
+// the discovery metrics in expvar and Prometheus format.
+func startStatsServer(addr string, self string, tab *discover.Table) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := bootnodeStats{
+			Self:    self,
+			Uptime:  int64(time.Since(start) / time.Second),
+			Traffic: make(map[string]trafficStats),
+		}
+		if tab != nil {
+			table := tab.Stats()
+			stats.Table = &table
+		}
+		metrics.DefaultRegistry.Each(func(name string, i interface{}) {
+			meter, ok := i.(metrics.Meter)
+			if !ok || !strings.HasPrefix(name, "discover/") {
+				return
+			}
+			snapshot := meter.Snapshot()
+			stats.Traffic[strings.TrimPrefix(name, "discover/")] = trafficStats{
+				Count: snapshot.Count(),
+				Rate1: snapshot.Rate1(),
+				Rate5: snapshot.Rate5(),
+			}
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+	mux.Handle("/debug/metrics", exp.ExpHandler(metrics.DefaultRegistry))
+	mux.Handle("/debug/metrics/prometheus", prometheus.Handler(metrics.DefaultRegistry))
+
+	log.Info("Starting stats server", "addr", "http://"+listener.Addr().String()+"/stats")
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Error("Failure in running stats server", "err", err)
+		}
+	}()
+	return nil
+}
@@ -17,11 +17,15 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -41,6 +45,14 @@ import (
 )
 
 var (
+	blockRangeFlag = cli.StringFlag{
+		Name:  "range",
+		Usage: "Range of blocks to import or export, as FIRST-LAST with either end optional",
+	}
+	importVerifyFlag = cli.BoolFlag{
+		Name:  "verify",
+		Usage: "Verify the checksums of the files before importing any block",
+	}
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initGenesis),
 		Name:      "init",
@@ -66,13 +78,26 @@ It expects the genesis file as argument.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.GCModeFlag,
+			utils.GCModeTriesFlag,
+			utils.SnapshotFlag,
+			utils.TxLookupLimitFlag,
+			utils.AncientDirFlag,
+			utils.AncientThresholdFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheWriteBufferFlag,
 			utils.CacheGCFlag,
+			blockRangeFlag,
+			importVerifyFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The import command imports blocks from an RLP-encoded form. The form can be one file
-with several RLP-encoded blocks, or several files can be used.
+with several RLP-encoded blocks, or several files can be used. Files ending in .gz
+or .zst are decompressed on the fly, and --range only imports the blocks within it.
+
+The checksums closing each exported run of blocks are verified along the way. With
+--verify, all files are verified before any block is imported, failing if a file
+has no checksum or blocks past its last one.
 
 If only one file is used, import error will result in failure. If several files are used,
 processing will proceed even if an individual RLP-file import failure occurs.`,
@@ -85,13 +110,22 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
 			utils.CacheFlag,
+			utils.AncientDirFlag,
+			utils.AncientThresholdFlag,
+			blockRangeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
-Requires a first argument of the file to write to.
+Requires a first argument of the file to write to, compressed
+with gzip or zstd if ending in .gz or .zst. --range selects
+the blocks to write, all of them by default.
+
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
-if already existing.`,
+if already existing.
+
+Each run of exported blocks is closed by a checksum, verified
+on import.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -122,6 +156,152 @@ The first argument must be the directory containing the blockchain to download f
 		Description: `
 Remove blockchain and state databases`,
 	}
+	migratedbCommand = cli.Command{
+		Action:    utils.MigrateFlags(migrateDB),
+		Name:      "migratedb",
+		Usage:     "Convert the blockchain database to another database engine",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.DatabaseEngineFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Copies the blockchain database into a new database using the engine selected
+with --db.engine. The original database is kept next to the converted one,
+suffixed with the name of its engine, and can be removed once the node runs
+fine on the new engine. Engines other than LevelDB are only available in
+builds with the matching tag, e.g. 'go build -tags pebble'.`,
+	}
+	rollbackCommand = cli.Command{
+		Action:    utils.MigrateFlags(rollback),
+		Name:      "rollback",
+		Usage:     "Rewind the blockchain to an earlier block",
+		ArgsUsage: "<number>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.Testnet2Flag,
+			utils.AncientDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+    aquachain rollback <number>
+
+Rewinds the head of the blockchain to the given canonical block, deleting the
+canonical mappings, bodies, receipts and transaction lookup entries of all the
+blocks above it. The state of the target block must be available, otherwise the
+newest earlier block with its state is suggested instead. The dropped blocks
+are synced again from the network on the next start.
+
+On a running node, debug.setHead does the same.`,
+	}
+	dbVerifyDepthFlag = cli.Uint64Flag{
+		Name:  "depth",
+		Usage: "Number of most recent blocks to check",
+		Value: 1024,
+	}
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Verify, repair and compact the blockchain database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Checks the most recent blocks of the blockchain database for corruption, as may
+be left behind by an unclean shutdown or a failing disk, and repairs it. The
+database can also be compacted while the node is down.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "verify",
+				Aliases:   []string{"check"},
+				Usage:     "Check the most recent blocks for inconsistencies",
+				Action:    utils.MigrateFlags(verifyDB),
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					dbVerifyDepthFlag,
+				},
+				Description: `
+    aquachain db verify [--depth 1024]
+
+Cross-checks the canonical hash mappings, the consistency of headers, bodies,
+receipts and total difficulties, and the reachability of the state roots of the
+most recent blocks. Reports the issues found and the last consistent block.
+
+Only the last --depth blocks are checked, damage below them goes unnoticed. Use
+a depth beyond the head block to check the whole chain.`,
+			},
+			{
+				Name:      "repair",
+				Usage:     "Truncate the chain back to the last consistent block",
+				Action:    utils.MigrateFlags(repairDB),
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					dbVerifyDepthFlag,
+				},
+				Description: `
+    aquachain db repair [--depth 1024]
+
+Verifies the most recent blocks like 'db verify' and rewinds the head of the
+chain to the last consistent block with its state available. The blocks above
+it are dropped and synced again from the network on the next start.
+
+The consistent block is only known to be sound within the last --depth blocks,
+blocks below them aren't checked. Repairs after an unclean shutdown only need
+the default depth, other damage may require checking the whole chain.`,
+			},
+			{
+				Name:      "reindex-txs",
+				Usage:     "Rebuild the transaction index for a range of blocks",
+				Action:    utils.MigrateFlags(reindexTxs),
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					blockRangeFlag,
+				},
+				Description: `
+    aquachain db reindex-txs [--range FIRST-LAST]
+
+Writes the transaction lookup entries of the canonical blocks in the range,
+the whole chain by default. Use it to restore the index dropped by running
+with --txlookuplimit, e.g. when a node is promoted to archive duty. Restart the
+node without --txlookuplimit afterwards, or the blocks are unindexed again.`,
+			},
+			{
+				Name:      "compact",
+				Usage:     "Compact the whole blockchain database",
+				Action:    utils.MigrateFlags(compactDB),
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+				},
+				Description: `
+    aquachain db compact
+
+Compacts the full key space of the blockchain database, reclaiming the space of
+deleted and overwritten entries. Running it offline avoids the write stalls a
+full compaction causes on a live node, which are worst on spinning disks.`,
+			},
+		},
+	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
 		Name:      "dump",
@@ -130,12 +310,68 @@ Remove blockchain and state databases`,
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
 			utils.CacheFlag,
+			utils.AncientDirFlag,
+			utils.AncientThresholdFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The arguments are interpreted as block numbers or hashes.
 Use "aquachain dump 0" to dump the genesis block.`,
 	}
+	inspectFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to analyze",
+	}
+	inspectToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to analyze (default: head block)",
+	}
+	inspectPeriodFlag = cli.DurationFlag{
+		Name:  "period",
+		Usage: "Length of the periods the blocks are grouped into",
+		Value: 24 * time.Hour,
+	}
+	inspectTopFlag = cli.IntFlag{
+		Name:  "top",
+		Usage: "Number of most called contracts to report per period",
+		Value: 5,
+	}
+	inspectFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Output format ("csv" or "json")`,
+		Value: "csv",
+	}
+	inspectOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the statistics to (default: stdout)",
+	}
+	inspectChainCommand = cli.Command{
+		Action:    utils.MigrateFlags(inspectChain),
+		Name:      "inspect-chain",
+		Usage:     "Report network statistics of the local chain",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.Testnet2Flag,
+			inspectFromFlag,
+			inspectToFlag,
+			inspectPeriodFlag,
+			inspectTopFlag,
+			inspectFormatFlag,
+			inspectOutputFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+    aquachain inspect-chain [--from 0] [--to head] [--period 24h] [--format csv|json]
+
+Walks the canonical blocks of the local chain and reports per period the block
+count, average block time, average and highest difficulty, gas used and gas
+limit, transaction count, number of distinct senders and the most called
+contracts. Contract calls are transactions with input data sent to an address.
+The node must not be running.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -146,20 +382,10 @@ func initGenesis(ctx *cli.Context) error {
 	if len(genesisPath) == 0 {
 		utils.Fatalf("Must supply path to genesis JSON file")
 	}
-	file, err := os.Open(genesisPath)
+	genesis, err := core.LoadGenesis(genesisPath)
 	if err != nil {
 		utils.Fatalf("Failed to read genesis file: %v", err)
 	}
-	defer file.Close()
-
-	genesis := new(core.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
-		utils.Fatalf("invalid genesis file: %v", err)
-	}
-
-	if genesis.Config == nil || genesis.Config.ChainId == nil {
-		utils.Fatalf("invalid genesis file: no chainid")
-	}
 
 	// Open an initialise db
 	stack := makeFullNode(ctx)
@@ -181,6 +407,22 @@ func importChain(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
 	}
+	first, last := uint64(0), uint64(math.MaxUint64)
+	if ctx.IsSet(blockRangeFlag.Name) {
+		var err error
+		if first, last, err = utils.ParseBlockRange(ctx.String(blockRangeFlag.Name), last); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	if ctx.Bool(importVerifyFlag.Name) {
+		for _, arg := range ctx.Args() {
+			blocks, err := utils.VerifyChainFile(arg)
+			if err != nil {
+				utils.Fatalf("Verification of %s failed: %v", arg, err)
+			}
+			log.Info("Verified chain export", "file", arg, "blocks", blocks)
+		}
+	}
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
 	defer chainDb.Close()
@@ -205,13 +447,13 @@ func importChain(ctx *cli.Context) error {
 	exitcode := 0
 
 	if len(ctx.Args()) == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := utils.ImportChainRange(chain, ctx.Args().First(), first, last); err != nil {
 			log.Error("Import error", "err", err)
 			exitcode = 111
 		}
 	} else {
 		for _, arg := range ctx.Args() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := utils.ImportChainRange(chain, arg, first, last); err != nil {
 				log.Error("Import error", "file", arg, "err", err)
 			}
 		}
@@ -220,13 +462,14 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*aquadb.LDBDatabase)
-
-	stats, err := db.LDB().GetProperty("leveldb.stats")
-	if err != nil {
-		utils.Fatalf("Failed to read database stats: %v", err)
+	db, isLDB := aquadb.Unwrap(chainDb).(*aquadb.LDBDatabase)
+	if isLDB {
+		stats, err := db.LDB().GetProperty("leveldb.stats")
+		if err != nil {
+			utils.Fatalf("Failed to read database stats: %v", err)
+		}
+		fmt.Println(stats)
 	}
-	fmt.Println(stats)
 	fmt.Printf("Trie cache misses:  %d\n", trie.CacheMisses())
 	fmt.Printf("Trie cache unloads: %d\n\n", trie.CacheUnloads())
 
@@ -239,19 +482,19 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Allocations:   %.3f million\n", float64(mem.Mallocs)/1000000)
 	fmt.Printf("GC pause:      %v\n\n", time.Duration(mem.PauseTotalNs))
 
-	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) {
+	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) || !isLDB {
 		return nil
 	}
 
 	// Compact the entire database to more accurately measure disk io and print the stats
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = db.LDB().CompactRange(util.Range{}); err != nil {
+	if err := db.LDB().CompactRange(util.Range{}); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	stats, err = db.LDB().GetProperty("leveldb.stats")
+	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
 	}
@@ -274,7 +517,15 @@ func exportChain(ctx *cli.Context) error {
 	var err error
 	fp := ctx.Args().First()
 	if len(ctx.Args()) < 3 {
-		err = utils.ExportChain(chain, fp)
+		if ctx.IsSet(blockRangeFlag.Name) {
+			first, last, rerr := utils.ParseBlockRange(ctx.String(blockRangeFlag.Name), chain.CurrentBlock().NumberU64())
+			if rerr != nil {
+				utils.Fatalf("Export error: %v\n", rerr)
+			}
+			err = utils.ExportChainRange(chain, fp, first, last)
+		} else {
+			err = utils.ExportChain(chain, fp)
+		}
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
 		first, ferr := strconv.ParseInt(ctx.Args().Get(1), 10, 64)
@@ -335,8 +586,10 @@ func copyDb(ctx *cli.Context) error {
 	// Compact the entire database to remove any sync overhead
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*aquadb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
-		utils.Fatalf("Compaction failed: %v", err)
+	if db, ok := aquadb.Unwrap(chainDb).(*aquadb.LDBDatabase); ok {
+		if err = db.LDB().CompactRange(util.Range{}); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
@@ -372,6 +625,212 @@ func removeDB(ctx *cli.Context) error {
 	return nil
 }
 
+func migrateDB(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+
+	engine := cfg.Node.DatabaseEngine
+	if engine == "" {
+		utils.Fatalf("Target engine missing, specify one of %v with --%s", aquadb.Engines(), utils.DatabaseEngineFlag.Name)
+	}
+	dbdir := stack.ResolvePath("chaindata")
+	logger := log.New("database", dbdir)
+
+	start := time.Now()
+	logger.Info("Migrating database", "from", aquadb.DetectEngine(dbdir), "to", engine)
+	count, err := aquadb.MigrateEngine(dbdir, engine, ctx.GlobalInt(utils.CacheFlag.Name), 256)
+	if err != nil {
+		utils.Fatalf("Database migration failed: %v", err)
+	}
+	logger.Info("Database successfully migrated", "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyChainDB opens the chain database and checks the most recent blocks,
+// printing the issues found.
+func verifyChainDB(ctx *cli.Context) (aquadb.Database, *core.ChainReport) {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+
+	config, err := core.GetChainConfig(chainDb, core.GetCanonicalHash(chainDb, 0))
+	if err != nil {
+		utils.Fatalf("Could not load chain configuration: %v", err)
+	}
+	start := time.Now()
+	report, err := core.VerifyChain(chainDb, config, ctx.Uint64(dbVerifyDepthFlag.Name))
+	if err != nil {
+		utils.Fatalf("Verification failed: %v", err)
+	}
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	log.Info("Verified blockchain", "head", report.Head, "checked", report.Checked, "issues", len(report.Issues), "elapsed", common.PrettyDuration(time.Since(start)))
+	return chainDb, report
+}
+
+func verifyDB(ctx *cli.Context) error {
+	chainDb, report := verifyChainDB(ctx)
+	defer chainDb.Close()
+
+	switch {
+	case len(report.Issues) == 0:
+		fmt.Printf("Database is consistent within the last %d blocks\n", report.Checked)
+	case report.Consistent == nil:
+		utils.Fatalf("No consistent block within %d blocks of the head, try a larger --%s", report.Checked, dbVerifyDepthFlag.Name)
+	default:
+		utils.Fatalf("Last consistent block is %d [%x…], run 'db repair' to rewind to it", report.Consistent.Number, report.Consistent.Hash().Bytes()[:4])
+	}
+	return nil
+}
+
+func repairDB(ctx *cli.Context) error {
+	chainDb, report := verifyChainDB(ctx)
+	defer chainDb.Close()
+
+	if len(report.Issues) == 0 {
+		fmt.Println("Database is consistent, nothing to repair")
+		return nil
+	}
+	if report.Consistent == nil {
+		utils.Fatalf("No consistent block within %d blocks of the head, try a larger --%s", report.Checked, dbVerifyDepthFlag.Name)
+	}
+	number := report.Consistent.Number.Uint64()
+	if report.Checked <= report.Head {
+		fmt.Printf("Blocks below %d were not checked and may be damaged too, a larger --%s checks more\n", report.Head-report.Checked+1, dbVerifyDepthFlag.Name)
+	}
+	fmt.Printf("Rewinding chain from block %d to %d [%x…]\n", report.Head, number, report.Consistent.Hash().Bytes()[:4])
+	confirm, err := console.Stdin.PromptConfirm("Drop the blocks above it?")
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Warn("Database repair aborted")
+	default:
+		if err := core.RewindChain(chainDb, report.Consistent); err != nil {
+			utils.Fatalf("Repair failed: %v", err)
+		}
+		log.Info("Database repaired", "head", number, "dropped", report.Head-number)
+	}
+	return nil
+}
+
+func rollback(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	number, err := strconv.ParseUint(ctx.Args().First(), 0, 64)
+	if err != nil {
+		utils.Fatalf("Invalid block number: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	config, err := core.GetChainConfig(chainDb, core.GetCanonicalHash(chainDb, 0))
+	if err != nil {
+		utils.Fatalf("Could not load chain configuration: %v", err)
+	}
+	head := core.GetHeaderNoVersion(chainDb, core.GetHeadBlockHash(chainDb), core.GetBlockNumber(chainDb, core.GetHeadBlockHash(chainDb)))
+	if head == nil {
+		utils.Fatalf("Head block missing, try 'db repair'")
+	}
+	if number >= head.Number.Uint64() {
+		utils.Fatalf("Block %d is not below the head block %d", number, head.Number)
+	}
+	// Refuse to rewind to a block whose state would have to be regenerated
+	target := core.LatestStateBlock(chainDb, config, number)
+	switch {
+	case target == nil:
+		utils.Fatalf("No block at or below %d has its state available", number)
+	case target.Number.Uint64() != number:
+		utils.Fatalf("State of block %d missing, the newest block before it with state is %d", number, target.Number)
+	}
+	fmt.Printf("Rewinding chain from block %d to %d [%x…]\n", head.Number, number, target.Hash().Bytes()[:4])
+	confirm, err := console.Stdin.PromptConfirm("Drop the blocks above it?")
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Warn("Rollback aborted")
+	default:
+		start := time.Now()
+		if err := core.RewindChain(chainDb, target); err != nil {
+			utils.Fatalf("Rollback failed: %v", err)
+		}
+		log.Info("Rolled back chain", "head", number, "dropped", head.Number.Uint64()-number, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+func reindexTxs(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	config, err := core.GetChainConfig(chainDb, core.GetCanonicalHash(chainDb, 0))
+	if err != nil {
+		utils.Fatalf("Could not load chain configuration: %v", err)
+	}
+	first, last := uint64(0), core.GetBlockNumber(chainDb, core.GetHeadBlockHash(chainDb))
+	if ctx.IsSet(blockRangeFlag.Name) {
+		if first, last, err = utils.ParseBlockRange(ctx.String(blockRangeFlag.Name), last); err != nil {
+			utils.Fatalf("Invalid block range: %v", err)
+		}
+	}
+	start := time.Now()
+	log.Info("Indexing transactions", "first", first, "last", last)
+	count, err := core.IndexTransactions(chainDb, config, first, last+1, nil)
+	if err != nil {
+		utils.Fatalf("Indexing failed: %v", err)
+	}
+	log.Info("Indexed transactions", "blocks", last-first+1, "txs", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	if tail, ok := core.GetTxIndexTail(chainDb); ok {
+		log.Warn("Transaction index still incomplete", "tail", tail)
+	}
+	return nil
+}
+
+func compactDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	db, ok := aquadb.Unwrap(chainDb).(aquadb.Compacter)
+	if !ok {
+		utils.Fatalf("Database does not support compaction")
+	}
+	sizer, _ := db.(aquadb.Sizer)
+	size := func() common.StorageSize {
+		if sizer == nil {
+			return 0
+		}
+		size, _ := sizer.ApproximateSize(nil, nil)
+		return common.StorageSize(size)
+	}
+	before := size()
+	start, logged := time.Now(), time.Now()
+	log.Info("Compacting database", "size", before)
+
+	// Compact by leading key byte, so progress can be reported along the way
+	for b := 0; b < 256; b++ {
+		from, to := []byte{byte(b)}, []byte{byte(b + 1)}
+		if b == 0 {
+			from = nil
+		}
+		if b == 255 {
+			to = nil
+		}
+		if err := db.Compact(from, to); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Compacting database", "range", fmt.Sprintf("0x%02x", b), "total", 256, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Compacted database", "before", before, "after", size(), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 func dump(ctx *cli.Context) error {
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
@@ -398,6 +857,89 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+func inspectChain(ctx *cli.Context) error {
+	period := ctx.Duration(inspectPeriodFlag.Name)
+	if period < time.Second {
+		utils.Fatalf("Period must be at least a second")
+	}
+	format := ctx.String(inspectFormatFlag.Name)
+	if format != "csv" && format != "json" {
+		utils.Fatalf("Unknown output format %q", format)
+	}
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	config, err := core.GetChainConfig(chainDb, core.GetCanonicalHash(chainDb, 0))
+	if err != nil {
+		utils.Fatalf("Could not load chain configuration: %v", err)
+	}
+	head := core.GetHeadBlockHash(chainDb)
+	if head == (common.Hash{}) {
+		utils.Fatalf("No head block stored")
+	}
+	from, to := ctx.Uint64(inspectFromFlag.Name), core.GetBlockNumber(chainDb, head)
+	if ctx.IsSet(inspectToFlag.Name) {
+		to = ctx.Uint64(inspectToFlag.Name)
+	}
+	start := time.Now()
+	periods, err := core.AnalyzeChain(chainDb, config, from, to, uint64(period/time.Second), ctx.Int(inspectTopFlag.Name))
+	if err != nil {
+		utils.Fatalf("Analysis failed: %v", err)
+	}
+	log.Info("Analyzed blockchain", "from", from, "to", to, "periods", len(periods), "elapsed", common.PrettyDuration(time.Since(start)))
+
+	out := os.Stdout
+	if file := ctx.String(inspectOutputFlag.Name); file != "" {
+		if out, err = os.Create(file); err != nil {
+			utils.Fatalf("Could not create output file: %v", err)
+		}
+		defer out.Close()
+	}
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(periods)
+	} else {
+		err = writeChainStatsCSV(out, periods)
+	}
+	if err != nil {
+		utils.Fatalf("Could not write statistics: %v", err)
+	}
+	return nil
+}
+
+// writeChainStatsCSV writes the period statistics as CSV, a row per period.
+func writeChainStatsCSV(w io.Writer, periods []*core.PeriodStats) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"period_start", "first_block", "last_block", "blocks", "avg_block_time", "avg_difficulty", "max_difficulty",
+		"gas_used", "gas_limit", "txs", "unique_senders", "top_contracts",
+	})
+	for _, p := range periods {
+		top := make([]string, len(p.TopContracts))
+		for i, c := range p.TopContracts {
+			top[i] = fmt.Sprintf("%s:%d", c.Address.Hex(), c.Calls)
+		}
+		out.Write([]string{
+			time.Unix(int64(p.Start), 0).UTC().Format(time.RFC3339),
+			strconv.FormatUint(p.FirstBlock, 10),
+			strconv.FormatUint(p.LastBlock, 10),
+			strconv.Itoa(p.Blocks),
+			strconv.FormatFloat(p.BlockTime, 'f', 2, 64),
+			p.Difficulty.String(),
+			p.MaxDifficulty.String(),
+			strconv.FormatUint(p.GasUsed, 10),
+			strconv.FormatUint(p.GasLimit, 10),
+			strconv.Itoa(p.Txs),
+			strconv.Itoa(p.Senders),
+			strings.Join(top, " "),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
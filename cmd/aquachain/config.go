@@ -30,9 +30,18 @@ import (
 	"github.com/naoina/toml"
 	"gitlab.com/aquachain/aquachain/aqua"
 	"gitlab.com/aquachain/aquachain/cmd/utils"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/internal/debug"
 	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/opt/alerting"
+	"gitlab.com/aquachain/aquachain/opt/dashboard"
+	"gitlab.com/aquachain/aquachain/opt/dbbackup"
+	"gitlab.com/aquachain/aquachain/opt/dbserver"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
+	"gitlab.com/aquachain/aquachain/opt/whisper/mailserver"
 	whisper "gitlab.com/aquachain/aquachain/opt/whisper/whisperv6"
 	"gitlab.com/aquachain/aquachain/params"
+	aquagrpc "gitlab.com/aquachain/aquachain/rpc/grpc"
 )
 
 var (
@@ -73,11 +82,26 @@ type ethstatsConfig struct {
 	URL string `toml:",omitempty"`
 }
 
+// logConfig contains the logging settings of the config file. Command line
+// flags take precedence over them.
+type logConfig struct {
+	Verbosity int    `toml:",omitempty"`
+	Vmodule   string `toml:",omitempty"`
+}
+
 type gethConfig struct {
 	Aqua      aqua.Config
 	Shh       whisper.Config
+	ShhMail   mailserver.Config
 	Node      node.Config
 	Aquastats ethstatsConfig
+	Backup    dbbackup.Config
+	DBServer  dbserver.Config
+	Stratum   stratum.Config
+	GRPC      aquagrpc.Config
+	Dashboard dashboard.Config
+	Alerting  alerting.Config
+	Log       logConfig
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -105,20 +129,49 @@ func defaultNodeConfig() node.Config {
 	return cfg
 }
 
-func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
+// loadDefaultConfig returns the default configuration, overridden by the
+// config file if one was given.
+func loadDefaultConfig(ctx *cli.Context) (gethConfig, error) {
 	// Load defaults.
 	cfg := gethConfig{
-		Aqua: aqua.DefaultConfig,
-		Shh:  whisper.DefaultConfig,
-		Node: defaultNodeConfig(),
+		Aqua:      aqua.DefaultConfig,
+		Shh:       whisper.DefaultConfig,
+		ShhMail:   mailserver.DefaultConfig,
+		Node:      defaultNodeConfig(),
+		Backup:    dbbackup.DefaultConfig,
+		Stratum:   stratum.DefaultConfig,
+		Dashboard: dashboard.DefaultConfig,
+		Alerting:  alerting.DefaultConfig,
 	}
 
 	// Load config file.
 	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
 		if err := loadConfig(file, &cfg); err != nil {
-			utils.Fatalf("%v", err)
+			return cfg, err
 		}
 	}
+	return cfg, nil
+}
+
+// setLogConfig applies the logging settings of the config file, unless they
+// were overridden on the command line.
+func setLogConfig(ctx *cli.Context, cfg *logConfig) {
+	if cfg.Verbosity != 0 && !ctx.GlobalIsSet("verbosity") {
+		debug.Handler.Verbosity(cfg.Verbosity)
+	}
+	if cfg.Vmodule != "" && !ctx.GlobalIsSet("vmodule") {
+		if err := debug.Handler.Vmodule(cfg.Vmodule); err != nil {
+			log.Warn("Invalid vmodule in config file", "vmodule", cfg.Vmodule, "err", err)
+		}
+	}
+}
+
+func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
+	cfg, err := loadDefaultConfig(ctx)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	setLogConfig(ctx, &cfg.Log)
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
@@ -132,6 +185,13 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	}
 
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
+	utils.SetMailServerConfig(ctx, &cfg.ShhMail)
+	utils.SetBackupConfig(ctx, &cfg.Backup)
+	utils.SetDBServerConfig(ctx, &cfg.DBServer)
+	utils.SetStratumConfig(ctx, &cfg.Stratum)
+	utils.SetGRPCConfig(ctx, &cfg.GRPC)
+	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
+	utils.SetAlertingConfig(ctx, &cfg.Alerting)
 
 	return stack, cfg
 }
@@ -152,7 +212,7 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	utils.RegisterAquaService(stack, &cfg.Aqua)
 
 	// Whisper must be explicitly enabled by specifying at least 1 whisper flag or in dev mode
-	shhEnabled := enableWhisper(ctx)
+	shhEnabled := enableWhisper(ctx) || cfg.ShhMail.Dir != ""
 	shhAutoEnabled := !ctx.GlobalIsSet(utils.WhisperEnabledFlag.Name) && ctx.GlobalIsSet(utils.DeveloperFlag.Name)
 	if shhEnabled || shhAutoEnabled {
 		if ctx.GlobalIsSet(utils.WhisperMaxMessageSizeFlag.Name) {
@@ -162,15 +222,98 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 			cfg.Shh.MinimumAcceptedPOW = ctx.Float64(utils.WhisperMinPOWFlag.Name)
 		}
 		utils.RegisterShhService(stack, &cfg.Shh)
+
+		if cfg.ShhMail.Dir != "" {
+			utils.RegisterMailServerService(stack, &cfg.ShhMail)
+		}
 	}
 
 	// Add the AquaChain Stats daemon if requested.
 	if cfg.Aquastats.URL != "" {
 		utils.RegisterAquaStatsService(stack, cfg.Aquastats.URL)
 	}
+
+	// Add the database backup service if requested.
+	if cfg.Backup.Dir != "" {
+		utils.RegisterBackupService(stack, &cfg.Backup)
+	}
+
+	// Add the chain database server for replicas if requested.
+	if cfg.DBServer.Addr != "" {
+		utils.RegisterDBServerService(stack, &cfg.DBServer)
+	}
+
+	// Add the stratum server for external miners if requested.
+	if cfg.Stratum.Addr != "" {
+		utils.RegisterStratumService(stack, &cfg.Stratum)
+	}
+
+	// Add the gRPC server if requested.
+	if cfg.GRPC.Addr != "" {
+		utils.RegisterGRPCService(stack, &cfg.GRPC)
+	}
+
+	// Add the web dashboard if requested.
+	if cfg.Dashboard.Addr != "" {
+		utils.RegisterDashboardService(stack, &cfg.Dashboard)
+	}
+
+	// Add the webhook alerting if requested.
+	if len(cfg.Alerting.Webhooks) > 0 {
+		utils.RegisterAlertingService(stack, &cfg.Alerting)
+	}
+
+	// Add any services linked in by external packages.
+	if err := stack.RegisterPlugins(); err != nil {
+		utils.Fatalf("Failed to register node plugins: %v", err)
+	}
+	// Allow tuning parts of the configuration without a restart.
+	stack.SetReloadHandler(func() error {
+		return reloadConfig(ctx, stack, &cfg)
+	})
 	return stack
 }
 
+// reloadConfig re-reads the config file and flags, applying the changes to the
+// settings that are safe to update on a running node: logging, the RPC access
+// lists, the peer limit and the minimum gas price. Changes to any other setting
+// require a restart. The current configuration is updated on success.
+func reloadConfig(ctx *cli.Context, stack *node.Node, current *gethConfig) error {
+	next, err := loadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	utils.SetNodeConfig(ctx, &next.Node)
+	utils.SetChainId(ctx, &next.Aqua)
+	if ctx.GlobalIsSet(utils.GasPriceFlag.Name) {
+		next.Aqua.GasPrice = utils.GlobalBig(ctx, utils.GasPriceFlag.Name)
+	}
+
+	if next.Log != current.Log {
+		setLogConfig(ctx, &next.Log)
+		current.Log = next.Log
+	}
+	if err := stack.Reconfigure(&next.Node); err != nil {
+		return err
+	}
+	current.Node.P2P.MaxPeers = next.Node.P2P.MaxPeers
+	current.Node.HTTPCors = next.Node.HTTPCors
+	current.Node.WSOrigins = next.Node.WSOrigins
+	current.Node.RPCAllowIP = next.Node.RPCAllowIP
+
+	var aquachain *aqua.AquaChain
+	if err := stack.Service(&aquachain); err != nil {
+		return nil // No chain service running, nothing more to update
+	}
+	aquachain.SetMaxPeers(next.Node.P2P.MaxPeers)
+	if next.Aqua.GasPrice.Cmp(current.Aqua.GasPrice) != 0 {
+		log.Info("Updating minimum gas price", "old", current.Aqua.GasPrice, "new", next.Aqua.GasPrice)
+		aquachain.SetGasPrice(next.Aqua.GasPrice)
+		current.Aqua.GasPrice = next.Aqua.GasPrice
+	}
+	return nil
+}
+
 // dumpConfig is the dumpconfig command.
 func dumpConfig(ctx *cli.Context) error {
 	_, cfg := makeConfigNode(ctx)
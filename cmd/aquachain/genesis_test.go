@@ -107,3 +107,32 @@ func TestCustomGenesis(t *testing.T) {
 		aquachain.ExpectExit()
 	}
 }
+
+// Tests that --genesis sets up a custom network on first start, and refuses a
+// different genesis afterwards.
+func TestGenesisFlag(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	genesis := func(nonce string) string {
+		file := filepath.Join(datadir, nonce+".json")
+		spec := `{"alloc": {}, "difficulty": "0x20000", "gasLimit": "0x2fefd8", "nonce": "` + nonce + `", "config": {"chainId": 101}}`
+		if err := ioutil.WriteFile(file, []byte(spec), 0600); err != nil {
+			t.Fatalf("failed to write genesis file: %v", err)
+		}
+		return file
+	}
+	aquachain := runAquaChain(t,
+		"--datadir", datadir, "--genesis", genesis("0x000000000000002b"),
+		"--maxpeers", "0", "--port", "0", "--nodiscover", "--nat", "none", "--ipcdisable",
+		"--exec", "aqua.getBlock(0).nonce + ' ' + admin.nodeInfo.protocols.aqua.network", "console")
+	aquachain.ExpectRegexp("0x000000000000002b 101")
+	aquachain.ExpectExit()
+
+	aquachain = runAquaChain(t,
+		"--datadir", datadir, "--genesis", genesis("0x000000000000002c"),
+		"--maxpeers", "0", "--port", "0", "--nodiscover", "--nat", "none", "--ipcdisable",
+		"--exec", "aqua.getBlock(0).nonce", "console")
+	aquachain.ExpectRegexp("Fatal: .*incompatible genesis block.*\n")
+	aquachain.ExpectExit()
+}
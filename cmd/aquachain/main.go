@@ -56,6 +56,12 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.DatabaseEngineFlag,
+		utils.DataDirEncryptKeyFlag,
+		utils.CompactionWindowFlag,
+		utils.MinFreeDiskFlag,
+		utils.AncientDirFlag,
+		utils.AncientThresholdFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoKeysFlag,
 		utils.UseUSBFlag,
@@ -67,6 +73,7 @@ var (
 		utils.AquahashDatasetsOnDiskFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
+		utils.TxPoolJournalRemotesFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
@@ -75,12 +82,23 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolEvictionFlag,
+		utils.TxPoolSenderLimitFlag,
+		utils.TxPoolOriginLimitFlag,
 		utils.FastSyncFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.GCModeTriesFlag,
+		utils.TxLookupLimitFlag,
+		utils.SnapshotFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
+		utils.CacheWriteBufferFlag,
 		utils.CacheGCFlag,
+		utils.CacheBodiesFlag,
+		utils.CacheBlocksFlag,
+		utils.CacheReceiptsFlag,
+		utils.CacheHeadersFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.ListenAddrFlag,
@@ -89,6 +107,11 @@ var (
 		utils.AquabaseFlag,
 		utils.GasPriceFlag,
 		utils.MinerThreadsFlag,
+		utils.MinerThrottleFlag,
+		utils.MinerNiceFlag,
+		utils.MinerAffinityFlag,
+		utils.MinerStaleWindowFlag,
+		utils.MinerTxOrderingFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
 		utils.NATFlag,
@@ -105,13 +128,50 @@ var (
 		utils.NetworkEthFlag,
 		utils.VMEnableDebugFlag,
 		utils.NetworkIdFlag,
+		utils.GenesisFlag,
+		utils.NetworkPresetsFlag,
 		utils.AquaStatsURLFlag,
 		utils.MetricsEnabledFlag,
+		utils.MetricsAddrFlag,
+		utils.MetricsModulesFlag,
+		utils.MetricsEnableInfluxDBFlag,
+		utils.MetricsEnableInfluxDBV2Flag,
+		utils.MetricsInfluxDBEndpointFlag,
+		utils.MetricsInfluxDBDatabaseFlag,
+		utils.MetricsInfluxDBUsernameFlag,
+		utils.MetricsInfluxDBPasswordFlag,
+		utils.MetricsInfluxDBTokenFlag,
+		utils.MetricsInfluxDBBucketFlag,
+		utils.MetricsInfluxDBOrganizationFlag,
+		utils.MetricsInfluxDBTagsFlag,
+		utils.TracingEndpointFlag,
+		utils.TracingSampleRatioFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
+		utils.GpoMaxPriceFlag,
 		utils.ExtraDataFlag,
+		utils.BackupDirFlag,
+		utils.BackupIntervalFlag,
+		utils.BackupKeepFlag,
+		utils.BackupURLFlag,
+		utils.DBServeFlag,
+		utils.DBServeKeyFileFlag,
+		utils.DBRemoteFlag,
+		utils.DBRemoteKeyFileFlag,
+		utils.StratumAddrFlag,
+		utils.StratumDifficultyFlag,
+		utils.StratumMinDifficultyFlag,
+		utils.StratumMaxConnsFlag,
+		utils.StratumMaxConnsPerIPFlag,
+		utils.DashboardAddrFlag,
+		utils.DashboardRefreshFlag,
+		utils.AlertWebhookFlag,
+		utils.AlertReorgDepthFlag,
+		utils.AlertStallFlag,
+		utils.AlertMinPeersFlag,
+		utils.AlertMismatchesFlag,
 		configFileFlag,
 	}
 
@@ -122,6 +182,27 @@ var (
 		utils.RPCVirtualHostsFlag,
 		utils.RPCListenAddrFlag,
 		utils.RPCAllowIPFlag,
+		utils.RPCBehindProxyFlag,
+		utils.RPCTrustedProxiesFlag,
+		utils.RPCMaxBodySizeFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCMethodRateLimitsFlag,
+		utils.RPCTimeoutFlag,
+		utils.RPCMethodTimeoutsFlag,
+		utils.RPCBatchItemLimitFlag,
+		utils.RPCBatchResponseMaxSizeFlag,
+		utils.RPCNotificationQueueFlag,
+		utils.RPCCacheSizeFlag,
+		utils.RPCJWTSecretFlag,
+		utils.RPCTLSCertFlag,
+		utils.RPCTLSKeyFlag,
+		utils.RPCTLSClientCAFlag,
+		utils.RPCLogsMaxResultsFlag,
+		utils.RPCLogsMaxBlockRangeFlag,
+		utils.RPCAccessLogFlag,
+		utils.RPCHealthMinPeersFlag,
+		utils.RPCHealthMaxBlockAgeFlag,
+		utils.GRPCAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
 		utils.WSEnabledFlag,
@@ -131,12 +212,19 @@ var (
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.IPCApiFlag,
+		utils.IPCModeFlag,
+		utils.IPCGroupFlag,
 	}
 
 	whisperFlags = []cli.Flag{
 		utils.WhisperEnabledFlag,
 		utils.WhisperMaxMessageSizeFlag,
 		utils.WhisperMinPOWFlag,
+		utils.WhisperMailServerDirFlag,
+		utils.WhisperMailServerPasswordFlag,
+		utils.WhisperMailServerRetentionFlag,
+		utils.WhisperMailServerTopicsFlag,
 	}
 )
 
@@ -153,7 +241,13 @@ func init() {
 		exportCommand,
 		copydbCommand,
 		removedbCommand,
+		migratedbCommand,
+		rollbackCommand,
+		dbCommand,
 		dumpCommand,
+		inspectChainCommand,
+		// See snapshotcmd.go:
+		snapshotCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -175,6 +269,8 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See servicecmd.go
+		serviceCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -189,6 +285,9 @@ func init() {
 		if err := debug.Setup(ctx); err != nil {
 			return err
 		}
+		if err := utils.SetupTracing(ctx); err != nil {
+			return err
+		}
 		// Start system runtime metrics collection
 		go metrics.CollectProcessMetrics(3 * time.Second)
 
@@ -240,6 +339,7 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 	}
 	// Start up the node itself
 	utils.StartNode(stack)
+	utils.SetupMetrics(ctx)
 
 	// Register wallet event handlers to open and auto-derive wallets
 	if !stack.Config().NoKeys {
@@ -300,7 +400,7 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 			}
 		}
 		// Set the gas price to the limits from the CLI and start mining
-		aquachain.TxPool().SetGasPrice(utils.GlobalBig(ctx, utils.GasPriceFlag.Name))
+		aquachain.SetGasPrice(utils.GlobalBig(ctx, utils.GasPriceFlag.Name))
 		if err := aquachain.StartMining(true); err != nil {
 			utils.Fatalf("Failed to start mining: %v", err)
 		}
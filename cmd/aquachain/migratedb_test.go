@@ -0,0 +1,58 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build pebble
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+// Tests that an initialized LevelDB chain can be converted to Pebble with
+// migratedb, and that the node starts on the converted database.
+func TestMigrateDBPebble(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	json := filepath.Join(datadir, "genesis.json")
+	if err := ioutil.WriteFile(json, []byte(`{"alloc": {}, "difficulty": "0x20000", "gasLimit": "0x2fefd8", "nonce": "0x000000000000002b", "config": {"chainId": 101}}`), 0600); err != nil {
+		t.Fatalf("failed to write genesis file: %v", err)
+	}
+	runAquaChain(t, "--datadir", datadir, "init", json).WaitExit()
+
+	chaindata := filepath.Join(datadir, "aquachain", "chaindata")
+	if engine := aquadb.DetectEngine(chaindata); engine != aquadb.EngineLevelDB {
+		t.Fatalf("engine mismatch before migration: have %q, want %q", engine, aquadb.EngineLevelDB)
+	}
+	runAquaChain(t, "--datadir", datadir, "--db.engine", aquadb.EnginePebble, "migratedb").WaitExit()
+
+	if engine := aquadb.DetectEngine(chaindata); engine != aquadb.EnginePebble {
+		t.Fatalf("engine mismatch after migration: have %q, want %q", engine, aquadb.EnginePebble)
+	}
+	// The engine of an existing database is detected, no flag needed
+	aquachain := runAquaChain(t,
+		"--datadir", datadir, "--maxpeers", "0", "--port", "0",
+		"--nodiscover", "--nat", "none", "--ipcdisable",
+		"--exec", "aqua.getBlock(0).nonce", "console")
+	aquachain.ExpectRegexp("0x000000000000002b")
+	aquachain.ExpectExit()
+}
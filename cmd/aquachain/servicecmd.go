@@ -0,0 +1,221 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of aquachain.
+//
+// aquachain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// aquachain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with aquachain. If not, see <http://www.gnu.org/licenses/>.
+
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/aquachain/aquachain/cmd/utils"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/internal/debug"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// serviceName is the name aquachain registers itself under with the Windows
+// service control manager and the event log.
+const serviceName = "aquachain"
+
+var (
+	serviceCommand = cli.Command{
+		Name:     "service",
+		Usage:    "Manage aquachain as a Windows service",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+Register aquachain with the Windows service control manager, so the node is
+started automatically on boot without a console window. Log output of the
+service is written to the Windows event log.`,
+		Subcommands: []cli.Command{
+			{
+				Name:            "install",
+				Usage:           "Install aquachain as a Windows service",
+				Action:          serviceInstall,
+				ArgsUsage:       "[node flags...]",
+				SkipFlagParsing: true,
+				Description: `
+    aquachain service install [node flags...]
+
+Installs aquachain as an automatically started Windows service. Any flags
+given after 'install' are passed to the node every time the service starts,
+for example:
+
+    aquachain service install --datadir D:\aquachain --rpc
+
+Requires administrative privileges.`,
+			},
+			{
+				Name:   "uninstall",
+				Usage:  "Remove the aquachain Windows service",
+				Action: serviceUninstall,
+				Description: `
+    aquachain service uninstall
+
+Removes the aquachain service and its event log source. The service should be
+stopped first. Requires administrative privileges.`,
+			},
+			{
+				Name:   "run",
+				Usage:  "Run the node under the Windows service control manager",
+				Action: utils.MigrateFlags(serviceRun),
+				Flags:  append(append(nodeFlags, rpcFlags...), whisperFlags...),
+				Description: `
+    aquachain [node flags...] service run
+
+Entry point used by the service control manager, not meant to be invoked by
+hand.`,
+			},
+		},
+	}
+)
+
+// serviceInstall registers the running executable as a Windows service along
+// with an event log source for its output.
+func serviceInstall(ctx *cli.Context) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already installed", serviceName)
+	}
+	// Node flags are global, so they have to precede the subcommand
+	args := append(ctx.Args(), "service", "run")
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Aquachain",
+		Description: "Aquachain full node",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %v", err)
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to register event log source: %v", err)
+	}
+	fmt.Printf("Installed service %s: %s %v\n", serviceName, exe, args)
+	return nil
+}
+
+// serviceUninstall removes the aquachain Windows service and its event log
+// source.
+func serviceUninstall(ctx *cli.Context) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %v", err)
+	}
+	if err := eventlog.Remove(serviceName); err != nil {
+		return fmt.Errorf("failed to remove event log source: %v", err)
+	}
+	fmt.Printf("Removed service %s\n", serviceName)
+	return nil
+}
+
+// serviceRun runs the node, reporting its state to the service control manager
+// and redirecting all log output to the event log.
+func serviceRun(ctx *cli.Context) error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return err
+	}
+	if interactive {
+		return errors.New("not started by the service control manager, use 'aquachain service install'")
+	}
+	handler, err := log.EventlogHandler(serviceName, log.LogfmtFormat())
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %v", err)
+	}
+	debug.SetLogOutput(ctx, handler)
+
+	return svc.Run(serviceName, &nodeService{ctx: ctx})
+}
+
+// nodeService implements svc.Handler, running a full node until the service
+// control manager requests it to stop.
+type nodeService struct {
+	ctx *cli.Context
+}
+
+// Execute implements svc.Handler.
+func (s *nodeService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+
+	status <- svc.Status{State: svc.StartPending}
+
+	stack := makeFullNode(s.ctx)
+	startNode(s.ctx, stack)
+
+	stopped := make(chan struct{})
+	go func() {
+		stack.Wait()
+		close(stopped)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Info("Service stop requested")
+				status <- svc.Status{State: svc.StopPending}
+				if err := stack.Stop(); err != nil {
+					log.Error("Failed to stop node", "err", err)
+				}
+				<-stopped
+				return false, 0
+			default:
+				log.Warn("Unexpected service control request", "cmd", req.Cmd)
+			}
+		case <-stopped:
+			log.Error("Node terminated unexpectedly")
+			return false, 1
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of aquachain.
+//
+// aquachain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// aquachain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with aquachain. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !windows
+
+package main
+
+import (
+	"errors"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	serviceCommand = cli.Command{
+		Name:     "service",
+		Usage:    "Manage aquachain as a Windows service",
+		Category: "MISCELLANEOUS COMMANDS",
+		Action:   serviceUnsupported,
+		Description: `
+Windows services are not available on this platform, use the init system of
+your operating system (e.g. systemd) to run 'aquachain daemon' instead.`,
+	}
+)
+
+func serviceUnsupported(ctx *cli.Context) error {
+	return errors.New("Windows services are not supported on this platform")
+}
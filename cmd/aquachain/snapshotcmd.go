@@ -0,0 +1,238 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of aquachain.
+//
+// aquachain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// aquachain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with aquachain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/cmd/utils"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	pruneKeepFlag = cli.Uint64Flag{
+		Name:  "keep",
+		Usage: "Number of most recent blocks whose state is kept",
+		Value: 128,
+	}
+	snapshotNumberFlag = cli.Uint64Flag{
+		Name:  "number",
+		Usage: "Checkpoint block of the snapshot (default: the head block)",
+	}
+	snapshotBlocksFlag = cli.Uint64Flag{
+		Name:  "blocks",
+		Usage: "Number of blocks up to the checkpoint included in the snapshot",
+		Value: 256,
+	}
+	snapshotSignKeyFlag = cli.StringFlag{
+		Name:  "signkey",
+		Usage: "Private key file to sign the snapshot with",
+	}
+	snapshotSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "Address the snapshot must be signed by",
+	}
+	snapshotInsecureFlag = cli.BoolFlag{
+		Name:  "insecure",
+		Usage: "Import a snapshot without a --signer, trusting its state",
+	}
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage the state stored in the blockchain database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Offline maintenance of the state stored in the blockchain database, and export
+and import of chain snapshots to bootstrap new nodes. The node must not be
+running.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Delete the state of all but the most recent blocks",
+				Action:    utils.MigrateFlags(pruneState),
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					pruneKeepFlag,
+				},
+				Description: `
+    aquachain snapshot prune-state [--keep 128]
+
+Deletes the state trie nodes and contract code which are not part of the state
+of the most recent blocks or the genesis block, reclaiming the disk space taken
+by states written before. States older than --keep blocks are lost, so tracing
+or querying the state of old blocks fails afterwards. Archive nodes should not
+be pruned.`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the state at a checkpoint block into a snapshot file",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				ArgsUsage: "<filename>",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					snapshotNumberFlag,
+					snapshotBlocksFlag,
+					snapshotSignKeyFlag,
+				},
+				Description: `
+    aquachain snapshot export [--number N] [--blocks 256] [--signkey file] <filename>
+
+Writes the state of the checkpoint block, the head block by default, along with
+the last --blocks blocks up to it and their receipts, into a single file,
+compressed with gzip or zstd if ending in .gz or .zst. The file only depends on
+the chain, so every node exports the same snapshot of a checkpoint and anyone
+can check a published one.
+
+The whole file is covered by a checksum, which is signed with the key in the
+--signkey file if given, letting users check who published the snapshot. The
+state of the checkpoint must be available.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Bootstrap a new node from a snapshot file",
+				Action:    utils.MigrateFlags(importSnapshot),
+				ArgsUsage: "<filename>",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.Testnet2Flag,
+					utils.AncientDirFlag,
+					snapshotSignerFlag,
+					snapshotInsecureFlag,
+				},
+				Description: `
+    aquachain snapshot import --signer 0xaddress <filename>
+
+Bootstraps an empty blockchain database from a snapshot made by "snapshot
+export", verifying its checksum, the headers of its blocks and that it was
+signed by the --signer address. The node then syncs from the checkpoint on,
+without the blocks before it. Their transactions are not indexed, and their
+state is unavailable.
+
+Snapshots are trusted: the state is not recomputed from the blocks, and the
+total difficulty of the chain before them is taken from the signed snapshot.
+Snapshots without a known signer are only imported with --insecure, after
+checking their checksum against a trusted source. Their total difficulty only
+counts the blocks they include, so the node switches to any heavier chain.`,
+			},
+		},
+	}
+)
+
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	stats, err := core.PruneState(chainDb, ctx.Uint64(pruneKeepFlag.Name))
+	if err != nil {
+		utils.Fatalf("Pruning failed: %v", err)
+	}
+	log.Info("Pruned state", "states", stats.States, "kept", stats.Kept, "deleted", stats.Deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Reclaim the disk space of the deleted entries
+	if db, ok := aquadb.Unwrap(chainDb).(aquadb.Compacter); ok {
+		start = time.Now()
+		log.Info("Compacting database")
+		if err := db.Compact(nil, nil); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+func exportSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	number := chain.CurrentBlock().NumberU64()
+	if ctx.IsSet(snapshotNumberFlag.Name) {
+		number = ctx.Uint64(snapshotNumberFlag.Name)
+	}
+	var key *ecdsa.PrivateKey
+	if file := ctx.String(snapshotSignKeyFlag.Name); file != "" {
+		var err error
+		if key, err = crypto.LoadECDSA(file); err != nil {
+			utils.Fatalf("Failed to load signing key: %v", err)
+		}
+	}
+	start := time.Now()
+	if err := utils.ExportSnapshot(chain, ctx.Args().First(), number, ctx.Uint64(snapshotBlocksFlag.Name), key); err != nil {
+		utils.Fatalf("Snapshot export failed: %v", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func importSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	var signer *common.Address
+	if hex := ctx.String(snapshotSignerFlag.Name); hex != "" {
+		if !common.IsHexAddress(hex) {
+			utils.Fatalf("Invalid signer address %q", hex)
+		}
+		address := common.HexToAddress(hex)
+		signer = &address
+	}
+	insecure := ctx.Bool(snapshotInsecureFlag.Name)
+	if signer == nil && !insecure {
+		utils.Fatalf("Snapshot imports require a --signer, or --insecure to skip the signature check")
+	}
+	// Set up the genesis block of the network, then hand the database over
+	stack, _ := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	engine := chain.Engine()
+	chain.Stop()
+	defer chainDb.Close()
+
+	start := time.Now()
+	info, err := utils.ImportSnapshot(chainDb, engine, ctx.Args().First(), signer, insecure)
+	if err != nil {
+		utils.Fatalf("Snapshot import failed: %v", err)
+	}
+	if signer == nil {
+		log.Warn("Imported snapshot without checking its signer, make sure its checksum is trusted", "checksum", fmt.Sprintf("%x", info.Checksum))
+	}
+	log.Info("Imported chain snapshot", "number", info.Header.Number, "hash", info.Header.Hash, "entries", info.Entries,
+		"checksum", fmt.Sprintf("%x", info.Checksum), "signer", info.Signer, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -77,13 +77,24 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.DatabaseEngineFlag,
+			utils.DataDirEncryptKeyFlag,
+			utils.CompactionWindowFlag,
+			utils.MinFreeDiskFlag,
+			utils.AncientDirFlag,
+			utils.AncientThresholdFlag,
 			utils.KeyStoreDirFlag,
 			utils.UseUSBFlag,
 			utils.NetworkIdFlag,
+			utils.GenesisFlag,
+			utils.NetworkPresetsFlag,
 			utils.TestnetFlag,
 			utils.Testnet2Flag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.GCModeTriesFlag,
+			utils.TxLookupLimitFlag,
+			utils.SnapshotFlag,
 			utils.AquaStatsURLFlag,
 			utils.IdentityFlag,
 		},
@@ -110,6 +121,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
+			utils.TxPoolJournalRemotesFlag,
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
@@ -118,6 +130,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolEvictionFlag,
+			utils.TxPoolSenderLimitFlag,
+			utils.TxPoolOriginLimitFlag,
 		},
 	},
 	{
@@ -125,10 +140,60 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheWriteBufferFlag,
 			utils.CacheGCFlag,
+			utils.CacheBodiesFlag,
+			utils.CacheBlocksFlag,
+			utils.CacheReceiptsFlag,
+			utils.CacheHeadersFlag,
 			utils.TrieCacheGenFlag,
 		},
 	},
+	{
+		Name: "DATABASE BACKUP",
+		Flags: []cli.Flag{
+			utils.BackupDirFlag,
+			utils.BackupIntervalFlag,
+			utils.BackupKeepFlag,
+			utils.BackupURLFlag,
+		},
+	},
+	{
+		Name: "DATABASE REPLICATION",
+		Flags: []cli.Flag{
+			utils.DBServeFlag,
+			utils.DBServeKeyFileFlag,
+			utils.DBRemoteFlag,
+			utils.DBRemoteKeyFileFlag,
+		},
+	},
+	{
+		Name: "STRATUM",
+		Flags: []cli.Flag{
+			utils.StratumAddrFlag,
+			utils.StratumDifficultyFlag,
+			utils.StratumMinDifficultyFlag,
+			utils.StratumMaxConnsFlag,
+			utils.StratumMaxConnsPerIPFlag,
+		},
+	},
+	{
+		Name: "DASHBOARD",
+		Flags: []cli.Flag{
+			utils.DashboardAddrFlag,
+			utils.DashboardRefreshFlag,
+		},
+	},
+	{
+		Name: "ALERTING",
+		Flags: []cli.Flag{
+			utils.AlertWebhookFlag,
+			utils.AlertReorgDepthFlag,
+			utils.AlertStallFlag,
+			utils.AlertMinPeersFlag,
+			utils.AlertMismatchesFlag,
+		},
+	},
 	{
 		Name: "ACCOUNT",
 		Flags: []cli.Flag{
@@ -150,8 +215,32 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.WSAllowedOriginsFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
+			utils.IPCApiFlag,
+			utils.IPCModeFlag,
+			utils.IPCGroupFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCMaxBodySizeFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCMethodRateLimitsFlag,
+			utils.RPCTimeoutFlag,
+			utils.RPCMethodTimeoutsFlag,
+			utils.RPCBatchItemLimitFlag,
+			utils.RPCBatchResponseMaxSizeFlag,
+			utils.RPCNotificationQueueFlag,
+			utils.RPCCacheSizeFlag,
+			utils.RPCJWTSecretFlag,
+			utils.RPCTLSCertFlag,
+			utils.RPCTLSKeyFlag,
+			utils.RPCTLSClientCAFlag,
+			utils.RPCLogsMaxResultsFlag,
+			utils.RPCLogsMaxBlockRangeFlag,
+			utils.RPCAccessLogFlag,
+			utils.RPCHealthMinPeersFlag,
+			utils.RPCHealthMaxBlockAgeFlag,
+			utils.GRPCAddrFlag,
+			utils.RPCBehindProxyFlag,
+			utils.RPCTrustedProxiesFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -180,6 +269,11 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.MiningEnabledFlag,
 			utils.MinerThreadsFlag,
+			utils.MinerThrottleFlag,
+			utils.MinerNiceFlag,
+			utils.MinerAffinityFlag,
+			utils.MinerStaleWindowFlag,
+			utils.MinerTxOrderingFlag,
 			utils.AquabaseFlag,
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
@@ -191,6 +285,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.GpoBlocksFlag,
 			utils.GpoPercentileFlag,
+			utils.GpoMaxPriceFlag,
 		},
 	},
 	{
@@ -203,6 +298,20 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsAddrFlag,
+			utils.MetricsModulesFlag,
+			utils.MetricsEnableInfluxDBFlag,
+			utils.MetricsEnableInfluxDBV2Flag,
+			utils.MetricsInfluxDBEndpointFlag,
+			utils.MetricsInfluxDBDatabaseFlag,
+			utils.MetricsInfluxDBUsernameFlag,
+			utils.MetricsInfluxDBPasswordFlag,
+			utils.MetricsInfluxDBTokenFlag,
+			utils.MetricsInfluxDBBucketFlag,
+			utils.MetricsInfluxDBOrganizationFlag,
+			utils.MetricsInfluxDBTagsFlag,
+			utils.TracingEndpointFlag,
+			utils.TracingSampleRatioFlag,
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
 		}, debug.Flags...),
@@ -22,6 +22,8 @@ import (
 	"gitlab.com/aquachain/aquachain/core/types"
 	"gitlab.com/aquachain/aquachain/crypto"
 	"gitlab.com/aquachain/aquachain/opt/aquaclient"
+	"gitlab.com/aquachain/aquachain/opt/pool"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
 	rpc "gitlab.com/aquachain/aquachain/rpc/rpcclient"
 )
 
@@ -41,6 +43,12 @@ var (
 	nonceseed      = flag.Int64("seed", 1, "nonce seed multiplier")
 	refresh        = flag.Duration("r", time.Second*3, "seconds to wait between asking for more work")
 	proxypath      = flag.String("prx", "", "example: socks5://192.168.1.3:1080 or 'tor' for localhost:9050")
+	stratumaddr    = flag.String("stratum", "", "serve the work of the rpc server to stratum miners on this address instead of mining (example: 0.0.0.0:8008)")
+	stratumdiff    = flag.Uint64("stratumdiff", stratum.DefaultConfig.Difficulty, "share difficulty of stratum miners not requesting one")
+	pooldb         = flag.String("pooldb", "", "directory of the database accounting the shares of stratum miners (empty = no accounting)")
+	poolscheme     = flag.String("poolscheme", pool.DefaultConfig.Scheme, "payout scheme of the pool accounting: pplns or prop")
+	poolfee        = flag.Uint64("poolfee", pool.DefaultConfig.Fee, "pool fee in basis points of the block reward")
+	poolrpc        = flag.String("poolrpc", "", "serve pool stats over JSON-RPC on this address (example: 127.0.0.1:8009)")
 )
 
 // big numbers
@@ -128,6 +136,12 @@ func main() {
 
 		// wrap with aquaclient
 		client = aquaclient.NewClient(rpcclient)
+
+		// act as a pool frontend instead of mining
+		if *stratumaddr != "" {
+			serveStratum(client)
+			return
+		}
 	} else {
 		fmt.Println("OFFLINE MODE")
 		<-time.After(time.Second)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/cmd/utils"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/opt/aquaclient"
+	"gitlab.com/aquachain/aquachain/opt/pool"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// rpcWork is a stratum backend fetching work from the rpc server
+type rpcWork struct {
+	client *aquaclient.Client
+}
+
+func (w rpcWork) GetWork() ([3]string, error) {
+	return w.client.GetWork(context.Background())
+}
+
+func (w rpcWork) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.Hash) bool {
+	return w.client.SubmitWork(context.Background(), nonce, hash, mixDigest)
+}
+
+// serveStratum distributes the work of the rpc server to stratum miners until
+// interrupted
+func serveStratum(client *aquaclient.Client) {
+	listener, err := net.Listen("tcp", *stratumaddr)
+	if err != nil {
+		utils.Fatalf("listen err: %v", err)
+	}
+	config := stratum.DefaultConfig
+	config.Difficulty = *stratumdiff
+
+	server := stratum.NewServer(rpcWork{client}, config)
+	server.Start(listener)
+	log.Printf("serving stratum work from %s on %s (share difficulty: %v)\n", *farm, listener.Addr(), config.Difficulty)
+
+	if *pooldb != "" {
+		p, db := startPool(server)
+		defer db.Close()
+		defer p.Stop()
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	server.Stop()
+}
+
+// startPool accounts the shares of the stratum miners, optionally serving the
+// pool stats over JSON-RPC
+func startPool(server *stratum.Server) (*pool.Pool, aquadb.Database) {
+	db, err := aquadb.NewLDBDatabase(*pooldb, 16, 16)
+	if err != nil {
+		utils.Fatalf("pool database err: %v", err)
+	}
+	config := pool.DefaultConfig
+	config.Scheme = *poolscheme
+	config.Fee = *poolfee
+
+	p, err := pool.New(db, config)
+	if err != nil {
+		utils.Fatalf("pool err: %v", err)
+	}
+	p.Start(server)
+	log.Printf("accounting pool shares in %s (scheme: %s, fee: %v bp)\n", *pooldb, config.Scheme, config.Fee)
+
+	if *poolrpc != "" {
+		handler := rpc.NewServer()
+		for _, api := range p.APIs() {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				utils.Fatalf("pool rpc err: %v", err)
+			}
+		}
+		listener, err := net.Listen("tcp", *poolrpc)
+		if err != nil {
+			utils.Fatalf("pool rpc listen err: %v", err)
+		}
+		go http.Serve(listener, handler)
+		log.Printf("serving pool stats on http://%s\n", listener.Addr())
+	}
+	return p, db
+}
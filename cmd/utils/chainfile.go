@@ -0,0 +1,228 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/rlp"
+)
+
+// Chain export files are a stream of RLP encoded blocks, each run of exported
+// blocks closed by a checksum trailer: an RLP string holding the magic below,
+// the number of blocks in the run and the SHA256 checksum of their encoding.
+// Being a string rather than a list, it is told apart from the blocks.
+const exportChecksumMagic = "aquachain-export-sha256"
+
+var (
+	errZstdUnsupported = errors.New("zstd compression not supported by this build, rebuild with -tags zstd")
+	errNoChecksum      = errors.New("no checksum trailer")
+)
+
+// chainFileCodec is a compression of chain export files.
+type chainFileCodec struct {
+	writer func(io.Writer) (io.WriteCloser, error)
+	reader func(io.Reader) (io.ReadCloser, error)
+}
+
+// chainFileCodecs are the compressions of chain export files by file extension.
+// Files with other extensions are not compressed.
+var chainFileCodecs = map[string]chainFileCodec{
+	".gz": {
+		writer: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		reader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+}
+
+// chainFileCodecFor returns the compression of the chain export file fn, nil
+// if not compressed.
+func chainFileCodecFor(fn string) (*chainFileCodec, error) {
+	ext := filepath.Ext(fn)
+	if codec, ok := chainFileCodecs[ext]; ok {
+		return &codec, nil
+	}
+	if ext == ".zst" {
+		return nil, errZstdUnsupported
+	}
+	return nil, nil
+}
+
+// ParseBlockRange parses a block range given as FIRST-LAST, either end being
+// optional. Open ends default to 0 and the given last block.
+func ParseBlockRange(s string, last uint64) (uint64, uint64, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid block range %q, want FIRST-LAST", s)
+	}
+	var (
+		first uint64
+		err   error
+	)
+	if parts[0] != "" {
+		if first, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid first block in range %q: %v", s, err)
+		}
+	}
+	if parts[1] != "" {
+		if last, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid last block in range %q: %v", s, err)
+		}
+	}
+	if first > last {
+		return 0, 0, fmt.Errorf("invalid block range %q: first block above last", s)
+	}
+	return first, last, nil
+}
+
+// encodeChecksumTrailer returns the checksum trailer closing a run of blocks.
+func encodeChecksumTrailer(count uint64, sum []byte) []byte {
+	trailer := make([]byte, len(exportChecksumMagic)+8, len(exportChecksumMagic)+8+len(sum))
+	copy(trailer, exportChecksumMagic)
+	binary.BigEndian.PutUint64(trailer[len(exportChecksumMagic):], count)
+	return append(trailer, sum...)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// chainFileReader streams the blocks of a chain export file, verifying them
+// against the checksum trailers along the way.
+type chainFileReader struct {
+	fh     *os.File
+	size   int64           // Size of the file, for progress reports
+	read   *countingReader // Bytes read from the file
+	codec  io.ReadCloser   // Decompressor, if any
+	stream *rlp.Stream
+
+	sum      hash.Hash // Checksum of the blocks since the last trailer
+	count    uint64    // Number of blocks since the last trailer
+	verified uint64    // Number of blocks verified by trailers
+	trailers int       // Number of trailers verified
+}
+
+// openChainFile opens the chain export file fn for reading.
+func openChainFile(fn string) (*chainFileReader, error) {
+	codec, err := chainFileCodecFor(fn)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	r := &chainFileReader{fh: fh, read: &countingReader{r: fh}, sum: sha256.New()}
+	if info, err := fh.Stat(); err == nil {
+		r.size = info.Size()
+	}
+	var reader io.Reader = r.read
+	if codec != nil {
+		if r.codec, err = codec.reader(reader); err != nil {
+			fh.Close()
+			return nil, err
+		}
+		reader = r.codec
+	}
+	r.stream = rlp.NewStream(reader, 0)
+	return r, nil
+}
+
+// Next returns the next block of the file, or io.EOF at its end.
+func (r *chainFileReader) Next() (*types.Block, error) {
+	for {
+		kind, _, err := r.stream.Kind()
+		if err != nil {
+			return nil, err
+		}
+		if kind != rlp.List {
+			trailer, err := r.stream.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			if err := r.verify(trailer); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		raw, err := r.stream.Raw()
+		if err != nil {
+			return nil, err
+		}
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(raw, block); err != nil {
+			return nil, err
+		}
+		r.sum.Write(raw)
+		r.count++
+		return block, nil
+	}
+}
+
+// verify checks a checksum trailer against the blocks read since the last one.
+func (r *chainFileReader) verify(trailer []byte) error {
+	if len(trailer) != len(exportChecksumMagic)+8+sha256.Size || !bytes.HasPrefix(trailer, []byte(exportChecksumMagic)) {
+		return fmt.Errorf("invalid checksum trailer %x", trailer)
+	}
+	count := binary.BigEndian.Uint64(trailer[len(exportChecksumMagic):])
+	if count != r.count {
+		return fmt.Errorf("block count mismatch: file has %d, checksum covers %d", r.count, count)
+	}
+	if sum := r.sum.Sum(nil); !bytes.Equal(sum, trailer[len(exportChecksumMagic)+8:]) {
+		return fmt.Errorf("checksum mismatch over %d blocks: have %x, want %x", count, sum, trailer[len(exportChecksumMagic)+8:])
+	}
+	r.verified += r.count
+	r.trailers++
+	r.sum.Reset()
+	r.count = 0
+	return nil
+}
+
+// Progress returns the share of the file read so far, in percent.
+func (r *chainFileReader) Progress() float64 {
+	if r.size == 0 {
+		return 0
+	}
+	return 100 * float64(r.read.n) / float64(r.size)
+}
+
+// Close closes the file.
+func (r *chainFileReader) Close() error {
+	if r.codec != nil {
+		r.codec.Close()
+	}
+	return r.fh.Close()
+}
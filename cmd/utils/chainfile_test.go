@@ -0,0 +1,158 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+func newTestChain(t *testing.T, blocks int) *core.BlockChain {
+	var (
+		db      = aquadb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, aquahash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if blocks > 0 {
+		gen, _ := core.GenerateChain(gspec.Config, genesis, aquahash.NewFaker(), db, blocks, func(i int, b *core.BlockGen) { b.SetCoinbase(common.Address{1}) })
+		if _, err := chain.InsertChain(gen); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+	}
+	return chain
+}
+
+// Tests that exported chains import back, compressed or not, in full or in
+// part, with their checksums verified.
+func TestExportImportChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquachain-export-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newTestChain(t, 20)
+	defer source.Stop()
+
+	names := []string{"chain.rlp", "chain.rlp.gz"}
+	if _, ok := chainFileCodecs[".zst"]; ok {
+		names = append(names, "chain.rlp.zst")
+	}
+	for _, name := range names {
+		fn := filepath.Join(dir, name)
+		if err := ExportChainRange(source, fn, 0, 10); err != nil {
+			t.Fatalf("%s: failed to export chain: %v", name, err)
+		}
+		if err := ExportAppendChain(source, fn, 11, 20); err != nil {
+			t.Fatalf("%s: failed to append chain: %v", name, err)
+		}
+		if blocks, err := VerifyChainFile(fn); err != nil || blocks != 21 {
+			t.Fatalf("%s: verification mismatch: have %d/%v, want 21/nil", name, blocks, err)
+		}
+		dest := newTestChain(t, 0)
+		if err := ImportChainRange(dest, fn, 0, 15); err != nil {
+			t.Fatalf("%s: failed to import chain: %v", name, err)
+		}
+		if head := dest.CurrentBlock(); head.Hash() != source.GetBlockByNumber(15).Hash() {
+			t.Errorf("%s: head mismatch after ranged import: have %d", name, head.NumberU64())
+		}
+		if err := ImportChain(dest, fn); err != nil {
+			t.Fatalf("%s: failed to import chain: %v", name, err)
+		}
+		if head := dest.CurrentBlock(); head.Hash() != source.CurrentBlock().Hash() {
+			t.Errorf("%s: head mismatch after import: have %d", name, head.NumberU64())
+		}
+		dest.Stop()
+	}
+	// Change the coinbase of a block and check the checksum catches it
+	fn := filepath.Join(dir, "chain.rlp")
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase := append([]byte{0x94}, common.Address{1}.Bytes()...)
+	i := bytes.LastIndex(data, coinbase)
+	if i < 0 {
+		t.Fatal("coinbase not found in export")
+	}
+	data[i+common.AddressLength] = 2
+	if err := ioutil.WriteFile(fn, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChainFile(fn); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("corrupted export verification error mismatch: have %v, want checksum mismatch", err)
+	}
+	// Files without checksums still import, but don't verify
+	legacy := filepath.Join(dir, "legacy.rlp")
+	fh, err := os.Create(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.ExportN(fh, 0, 20); err != nil {
+		t.Fatal(err)
+	}
+	fh.Close()
+	if _, err := VerifyChainFile(legacy); err != errNoChecksum {
+		t.Errorf("legacy file verification error mismatch: have %v, want %v", err, errNoChecksum)
+	}
+	dest := newTestChain(t, 0)
+	defer dest.Stop()
+	if err := ImportChain(dest, legacy); err != nil {
+		t.Fatalf("failed to import legacy file: %v", err)
+	}
+}
+
+func TestParseBlockRange(t *testing.T) {
+	tests := []struct {
+		in          string
+		first, last uint64
+		fail        bool
+	}{
+		{in: "10-20", first: 10, last: 20},
+		{in: "10-", first: 10, last: 100},
+		{in: "-20", first: 0, last: 20},
+		{in: "-", first: 0, last: 100},
+		{in: "20-10", fail: true},
+		{in: "10", fail: true},
+		{in: "a-b", fail: true},
+	}
+	for _, tt := range tests {
+		first, last, err := ParseBlockRange(tt.in, 100)
+		if (err != nil) != tt.fail {
+			t.Errorf("%q: error mismatch: have %v, want failure %v", tt.in, err, tt.fail)
+			continue
+		}
+		if !tt.fail && (first != tt.first || last != tt.last) {
+			t.Errorf("%q: range mismatch: have %d-%d, want %d-%d", tt.in, first, last, tt.first, tt.last)
+		}
+	}
+}
@@ -18,16 +18,21 @@
 package utils
 
 import (
-	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/signal"
 	"runtime"
-	"strings"
 	"syscall"
+	"time"
 
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/consensus"
 	"gitlab.com/aquachain/aquachain/core"
 	"gitlab.com/aquachain/aquachain/core/types"
 	"gitlab.com/aquachain/aquachain/internal/debug"
@@ -98,9 +103,27 @@ func StartNode(stack *node.Node) {
 		debug.Exit() // ensure trace and CPU profile data is flushed.
 		debug.LoudPanic("boom")
 	}()
+	go func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGHUP)
+		defer signal.Stop(sigc)
+		for range sigc {
+			log.Info("Got SIGHUP, reloading configuration...")
+			if err := stack.Reload(); err == node.ErrReloadUnsupported {
+				log.Warn("Configuration reload not supported by this node")
+			}
+		}
+	}()
 }
 
+// ImportChain imports all the blocks of a chain export file.
 func ImportChain(chain *core.BlockChain, fn string) error {
+	return ImportChainRange(chain, fn, 0, math.MaxUint64)
+}
+
+// ImportChainRange imports the blocks of a chain export file in the given
+// range, verifying the checksum trailers read along the way.
+func ImportChainRange(chain *core.BlockChain, fn string, first, last uint64) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
 	interrupt := make(chan os.Signal, 1)
@@ -124,42 +147,45 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	}
 
 	log.Info("Importing blockchain", "file", fn)
-	fh, err := os.Open(fn)
+	file, err := openChainFile(fn)
 	if err != nil {
 		return err
 	}
-	defer fh.Close()
-
-	var reader io.Reader = fh
-	if strings.HasSuffix(fn, ".gz") {
-		if reader, err = gzip.NewReader(reader); err != nil {
-			return err
-		}
-	}
-	stream := rlp.NewStream(reader, 0)
+	defer file.Close()
 
 	// Run actual the import.
-	blocks := make(types.Blocks, importBatchSize)
-	n := 0
-	for batch := 0; ; batch++ {
+	var (
+		blocks = make(types.Blocks, importBatchSize)
+		n      = 0
+		done   = false // Whether all blocks in range were read
+		eof    = false // Whether the whole file was read
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for batch := 0; !done; batch++ {
 		// Load a batch of RLP blocks.
 		if checkInterrupt() {
 			return fmt.Errorf("interrupted")
 		}
 		i := 0
 		for ; i < importBatchSize; i++ {
-			var b types.Block
-			if err := stream.Decode(&b); err == io.EOF {
+			b, err := file.Next()
+			if err == io.EOF {
+				done, eof = true, true
 				break
 			} else if err != nil {
 				return fmt.Errorf("at block %d: %v", n, err)
 			}
-			// don't import first block
-			if b.NumberU64() == 0 {
+			// don't import first block, nor the ones out of range
+			if b.NumberU64() == 0 || b.NumberU64() < first {
 				i--
 				continue
 			}
-			blocks[i] = &b
+			if b.NumberU64() > last {
+				done = true
+				break
+			}
+			blocks[i] = b
 			n++
 		}
 		if i == 0 {
@@ -169,6 +195,10 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 		if checkInterrupt() {
 			return fmt.Errorf("interrupted")
 		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing blockchain", "file", fn, "blocks", n, "number", blocks[i-1].NumberU64(), "progress", fmt.Sprintf("%.2f%%", file.Progress()), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
 		missing := missingBlocks(chain, blocks[:i])
 		if len(missing) == 0 {
 			log.Info("Skipping batch as all blocks present", "batch", batch, "first", blocks[0].Hash(), "last", blocks[i-1].Hash())
@@ -178,9 +208,46 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 			return fmt.Errorf("%v", err)
 		}
 	}
+	if eof && file.count > 0 && file.trailers > 0 {
+		log.Warn("Imported blocks not covered by a checksum", "file", fn, "blocks", file.count)
+	}
+	log.Info("Imported blockchain", "file", fn, "blocks", n, "verified", file.verified, "elapsed", common.PrettyDuration(time.Since(start)))
 	return nil
 }
 
+// VerifyChainFile checks the blocks of a chain export file against its checksum
+// trailers, returning the number of blocks verified. Files without trailers,
+// or with blocks past the last one, fail the verification.
+func VerifyChainFile(fn string) (uint64, error) {
+	log.Info("Verifying chain export", "file", fn)
+	file, err := openChainFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	logged := time.Now()
+	for {
+		block, err := file.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return file.verified, fmt.Errorf("at block %d: %v", file.verified+file.count, err)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain export", "file", fn, "number", block.NumberU64(), "progress", fmt.Sprintf("%.2f%%", file.Progress()))
+			logged = time.Now()
+		}
+	}
+	if file.trailers == 0 {
+		return 0, errNoChecksum
+	}
+	if file.count > 0 {
+		return file.verified, fmt.Errorf("%d blocks past the last checksum trailer", file.count)
+	}
+	return file.verified, nil
+}
+
 func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block {
 	head := chain.CurrentBlock()
 	for i, block := range blocks {
@@ -200,46 +267,133 @@ func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block
 	return nil
 }
 
+// ExportChain exports the whole chain into the file fn, replacing it.
 func ExportChain(blockchain *core.BlockChain, fn string) error {
-	log.Info("Exporting blockchain", "file", fn)
-	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	return exportChain(blockchain, fn, os.O_TRUNC, 0, blockchain.CurrentBlock().NumberU64())
+}
+
+// ExportChainRange exports the given range of the chain into the file fn,
+// replacing it.
+func ExportChainRange(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+	return exportChain(blockchain, fn, os.O_TRUNC, first, last)
+}
+
+// ExportAppendChain exports the given range of the chain at the end of the
+// file fn.
+func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+	return exportChain(blockchain, fn, os.O_APPEND, first, last)
+}
+
+// exportChain writes a range of the chain into the file fn, compressed as its
+// extension tells, and closes it with a checksum trailer.
+func exportChain(blockchain *core.BlockChain, fn string, mode int, first uint64, last uint64) error {
+	log.Info("Exporting blockchain", "file", fn, "first", first, "last", last)
+	codec, err := chainFileCodecFor(fn)
+	if err != nil {
+		return err
+	}
+	// TODO verify mode perms
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|mode, os.ModePerm)
 	if err != nil {
 		return err
 	}
 	defer fh.Close()
 
-	var writer io.Writer = fh
-	if strings.HasSuffix(fn, ".gz") {
-		writer = gzip.NewWriter(writer)
-		defer writer.(*gzip.Writer).Close()
+	var (
+		writer io.Writer = fh
+		closer io.Closer
+	)
+	if codec != nil {
+		cw, err := codec.writer(fh)
+		if err != nil {
+			return err
+		}
+		writer, closer = cw, cw
 	}
-
-	if err := blockchain.Export(writer); err != nil {
+	sum := sha256.New()
+	if err := blockchain.ExportN(io.MultiWriter(writer, sum), first, last); err != nil {
 		return err
 	}
-	log.Info("Exported blockchain", "file", fn)
-
+	if err := rlp.Encode(writer, encodeChecksumTrailer(last-first+1, sum.Sum(nil))); err != nil {
+		return err
+	}
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain", "file", fn, "blocks", last-first+1, "checksum", fmt.Sprintf("%x", sum.Sum(nil)))
 	return nil
 }
 
-func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
-	log.Info("Exporting blockchain", "file", fn)
-	// TODO verify mode perms
-	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
+// ExportSnapshot writes a snapshot of the state at block number and of the
+// given number of blocks up to it into the file fn, compressed as its extension
+// tells. The snapshot is signed with key if not nil.
+func ExportSnapshot(blockchain *core.BlockChain, fn string, number uint64, blocks uint64, key *ecdsa.PrivateKey) error {
+	log.Info("Exporting chain snapshot", "file", fn, "number", number, "blocks", blocks)
+	codec, err := chainFileCodecFor(fn)
+	if err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer fh.Close()
 
-	var writer io.Writer = fh
-	if strings.HasSuffix(fn, ".gz") {
-		writer = gzip.NewWriter(writer)
-		defer writer.(*gzip.Writer).Close()
+	var (
+		writer io.Writer = fh
+		closer io.Closer
+	)
+	if codec != nil {
+		cw, err := codec.writer(fh)
+		if err != nil {
+			return err
+		}
+		writer, closer = cw, cw
 	}
-
-	if err := blockchain.ExportN(writer, first, last); err != nil {
+	sum, err := core.ExportSnapshot(writer, blockchain, number, blocks, key)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	if err := fh.Close(); err != nil {
 		return err
 	}
-	log.Info("Exported blockchain to", "file", fn)
+	log.Info("Exported chain snapshot", "file", fn, "number", number, "checksum", fmt.Sprintf("%x", sum), "signed", key != nil)
 	return nil
 }
+
+// ImportSnapshot bootstraps the chain database from the snapshot file fn,
+// verifying its blocks with the consensus engine. If signer is given, the
+// snapshot must be signed by it, otherwise insecure must be set.
+func ImportSnapshot(db aquadb.Database, engine consensus.Engine, fn string, signer *common.Address, insecure bool) (*core.SnapshotInfo, error) {
+	log.Info("Importing chain snapshot", "file", fn)
+	codec, err := chainFileCodecFor(fn)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if codec != nil {
+		cr, err := codec.reader(fh)
+		if err != nil {
+			return nil, err
+		}
+		defer cr.Close()
+		reader = cr
+	}
+	return core.ImportSnapshot(db, engine, reader, signer, insecure)
+}
@@ -27,6 +27,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/aqua"
 	"gitlab.com/aquachain/aquachain/aqua/accounts"
@@ -38,6 +40,9 @@ import (
 	"gitlab.com/aquachain/aquachain/common/fdlimit"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/common/metrics/exp"
+	"gitlab.com/aquachain/aquachain/common/metrics/influxdb"
+	"gitlab.com/aquachain/aquachain/common/tracing"
 	"gitlab.com/aquachain/aquachain/consensus"
 	"gitlab.com/aquachain/aquachain/consensus/aquahash"
 	"gitlab.com/aquachain/aquachain/core"
@@ -45,7 +50,14 @@ import (
 	"gitlab.com/aquachain/aquachain/core/vm"
 	"gitlab.com/aquachain/aquachain/crypto"
 	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/opt/alerting"
 	"gitlab.com/aquachain/aquachain/opt/aquastats"
+	"gitlab.com/aquachain/aquachain/opt/dashboard"
+	"gitlab.com/aquachain/aquachain/opt/dbbackup"
+	"gitlab.com/aquachain/aquachain/opt/dbserver"
+	"gitlab.com/aquachain/aquachain/opt/miner"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
+	"gitlab.com/aquachain/aquachain/opt/whisper/mailserver"
 	whisper "gitlab.com/aquachain/aquachain/opt/whisper/whisperv6"
 	"gitlab.com/aquachain/aquachain/p2p"
 	"gitlab.com/aquachain/aquachain/p2p/discover"
@@ -53,6 +65,7 @@ import (
 	"gitlab.com/aquachain/aquachain/p2p/nat"
 	"gitlab.com/aquachain/aquachain/p2p/netutil"
 	"gitlab.com/aquachain/aquachain/params"
+	aquagrpc "gitlab.com/aquachain/aquachain/rpc/grpc"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
@@ -124,6 +137,32 @@ var (
 		Usage: "Data directory for the databases, IPC socket, and keystore (also see -keystore flag)",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	DataDirEncryptKeyFlag = cli.StringFlag{
+		Name:  "datadir.encryptkey",
+		Usage: "File holding the secret to encrypt the databases and transaction journal at rest",
+	}
+	DatabaseEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Database engine for new databases (leveldb, or pebble/badger in builds with the matching tag)",
+	}
+	CompactionWindowFlag = cli.StringFlag{
+		Name:  "db.compact.window",
+		Usage: "Daily HH:MM-HH:MM window (local time) to compact the chain database in (empty = on demand only)",
+	}
+	MinFreeDiskFlag = cli.Uint64Flag{
+		Name:  "datadir.minfreedisk",
+		Usage: "Free disk space in MiB below which to warn (0 = no warning)",
+		Value: aqua.DefaultConfig.MinFreeDisk,
+	}
+	AncientDirFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Directory to move old headers, bodies and receipts to, e.g. on a cheaper volume (default = kept in the chain database)",
+	}
+	AncientThresholdFlag = cli.Uint64Flag{
+		Name:  "ancient.threshold",
+		Usage: "Number of recent blocks kept in the chain database when moving old ones to --datadir.ancient",
+		Value: core.DefaultAncientThreshold,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -137,6 +176,14 @@ var (
 		Usage: "Network identifier (integer)",
 		Value: aqua.DefaultConfig.NetworkId,
 	}
+	GenesisFlag = cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Genesis JSON file of a custom network, written on first start and checked against the chain afterwards",
+	}
+	NetworkPresetsFlag = cli.StringFlag{
+		Name:  "network.presets",
+		Usage: "JSON file of custom network presets, selected by --networkid",
+	}
 	TestnetFlag = cli.BoolFlag{
 		Name:  "testnet",
 		Usage: "Aquachain Regression Test Network",
@@ -181,6 +228,18 @@ var (
 		Usage: `GC mode to use, either "full" or "archive". Use "archive" for full, accurate state (for example, 'admin.supply')`,
 		Value: "full",
 	}
+	GCModeTriesFlag = cli.Uint64Flag{
+		Name:  "gcmode.tries",
+		Usage: `Number of recent block states kept with --gcmode=full before pruning them (default 128)`,
+	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to keep transactions indexed by hash for (0 = entire chain)",
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Keep a flat snapshot of the head state to speed up account and storage reads",
+	}
 	// Aquahash settings
 	AquahashCacheDirFlag = DirectoryFlag{
 		Name:  "aquahash.cachedir",
@@ -221,6 +280,10 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolJournalRemotesFlag = cli.BoolFlag{
+		Name:  "txpool.journalremotes",
+		Usage: "Also journal pending remote transactions to survive node restarts",
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
 		Usage: "Time interval to regenerate the local transaction journal",
@@ -261,6 +324,21 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: aqua.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolEvictionFlag = cli.StringFlag{
+		Name:  "txpool.eviction",
+		Usage: `Queue eviction strategy ("accounts" evicts idle senders, "transactions" evicts stale transactions)`,
+		Value: aqua.DefaultConfig.TxPool.Eviction,
+	}
+	TxPoolSenderLimitFlag = cli.Uint64Flag{
+		Name:  "txpool.senderlimit",
+		Usage: "Maximum number of remote transactions pooled per sender account (0 = unlimited)",
+		Value: aqua.DefaultConfig.TxPool.SenderLimit,
+	}
+	TxPoolOriginLimitFlag = cli.Uint64Flag{
+		Name:  "txpool.originlimit",
+		Usage: "Maximum number of remote transactions pooled per relaying peer IP (0 = unlimited)",
+		Value: aqua.DefaultConfig.TxPool.OriginLimit,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -272,11 +350,36 @@ var (
 		Usage: "Percentage of cache memory allowance to use for database io",
 		Value: 75,
 	}
+	CacheWriteBufferFlag = cli.IntFlag{
+		Name:  "cache.writebuffer",
+		Usage: "Megabytes of chain database writes buffered in memory and flushed in the background (0 = write through)",
+		Value: aqua.DefaultConfig.DatabaseWriteBuffer,
+	}
 	CacheGCFlag = cli.IntFlag{
 		Name:  "cache.gc",
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	CacheBodiesFlag = cli.IntFlag{
+		Name:  "cache.bodies",
+		Usage: "Number of recent block bodies to keep in memory",
+		Value: 256,
+	}
+	CacheBlocksFlag = cli.IntFlag{
+		Name:  "cache.blocks",
+		Usage: "Number of recent entire blocks to keep in memory",
+		Value: 256,
+	}
+	CacheReceiptsFlag = cli.IntFlag{
+		Name:  "cache.receipts",
+		Usage: "Number of recent block receipt lists to keep in memory",
+		Value: 32,
+	}
+	CacheHeadersFlag = cli.IntFlag{
+		Name:  "cache.headers",
+		Usage: "Number of recent block headers to keep in memory",
+		Value: 512,
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -292,6 +395,28 @@ var (
 		Usage: "Number of CPU threads to use for mining",
 		Value: runtime.NumCPU(),
 	}
+	MinerThrottleFlag = cli.IntFlag{
+		Name:  "miner.throttle",
+		Usage: "Percentage of time the CPU mining threads spend hashing (0 = unthrottled)",
+	}
+	MinerNiceFlag = cli.IntFlag{
+		Name:  "miner.nice",
+		Usage: "Scheduling priority (nice level) of the CPU mining threads (0 = unchanged)",
+	}
+	MinerAffinityFlag = cli.StringFlag{
+		Name:  "miner.affinity",
+		Usage: "Comma separated list of CPUs to pin the mining threads to, round robin (empty = any)",
+	}
+	MinerStaleWindowFlag = cli.Uint64Flag{
+		Name:  "miner.stalewindow",
+		Usage: "Number of blocks remote solutions to work behind the head are still accepted for",
+		Value: miner.DefaultStaleWindow,
+	}
+	MinerTxOrderingFlag = cli.StringFlag{
+		Name:  "miner.txorder",
+		Usage: "Transaction ordering strategy of the miner (price, locals or a compiled in one)",
+		Value: miner.DefaultTxOrdering,
+	}
 	TargetGasLimitFlag = cli.Uint64Flag{
 		Name:  "targetgaslimit",
 		Usage: "Target gas limit sets the artificial target gas floor for the blocks to mine",
@@ -336,6 +461,67 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	MetricsAddrFlag = cli.StringFlag{
+		Name:  metrics.MetricsAddrFlag,
+		Usage: "Metrics server listening address, exporting in expvar and Prometheus format (implies --metrics)",
+	}
+	MetricsModulesFlag = cli.StringFlag{
+		Name:  "metrics.modules",
+		Usage: "Comma-separated expensive metrics modules to collect from startup (e.g. evm/opcodes,aqua/peers), switchable at runtime with debug.setMetricsModule",
+	}
+	MetricsEnableInfluxDBFlag = cli.BoolFlag{
+		Name:  "metrics.influxdb",
+		Usage: "Enable metrics export/push to an external InfluxDB v1 database",
+	}
+	MetricsEnableInfluxDBV2Flag = cli.BoolFlag{
+		Name:  "metrics.influxdbv2",
+		Usage: "Enable metrics export/push to an external InfluxDB v2 database",
+	}
+	MetricsInfluxDBEndpointFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.endpoint",
+		Usage: "InfluxDB API endpoint to report metrics to",
+		Value: "http://localhost:8086",
+	}
+	MetricsInfluxDBDatabaseFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.database",
+		Usage: "InfluxDB v1 database name to push reported metrics to",
+		Value: "aquachain",
+	}
+	MetricsInfluxDBUsernameFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.username",
+		Usage: "Username to authorize access to the InfluxDB v1 database",
+	}
+	MetricsInfluxDBPasswordFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.password",
+		Usage: "Password to authorize access to the InfluxDB v1 database",
+	}
+	MetricsInfluxDBTokenFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.token",
+		Usage: "API token to authorize access to the InfluxDB v2 database",
+	}
+	MetricsInfluxDBBucketFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.bucket",
+		Usage: "InfluxDB v2 bucket name to push reported metrics to",
+		Value: "aquachain",
+	}
+	MetricsInfluxDBOrganizationFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.organization",
+		Usage: "InfluxDB v2 organization name",
+	}
+	MetricsInfluxDBTagsFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.tags",
+		Usage: "Comma-separated InfluxDB tags (key/values) attached to all measurements",
+		Value: "host=localhost",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "OpenTelemetry collector OTLP/HTTP endpoint to export block import and RPC traces to (e.g. http://localhost:4318)",
+	}
+	TracingSampleRatioFlag = cli.Float64Flag{
+		Name:  "tracing.sampleratio",
+		Usage: "Ratio of traces to record and export",
+		Value: 1.0,
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -361,7 +547,7 @@ var (
 	}
 	RPCCORSDomainFlag = cli.StringFlag{
 		Name:  "rpccorsdomain",
-		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
+		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced, wildcards like https://*.example.com allowed)",
 		Value: "",
 	}
 	RPCVirtualHostsFlag = cli.StringFlag{
@@ -387,6 +573,18 @@ var (
 		Name:  "ipcpath",
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 	}
+	IPCApiFlag = cli.StringFlag{
+		Name:  "ipcapi",
+		Usage: "API's offered over the IPC-RPC interface (default = all)",
+	}
+	IPCModeFlag = cli.StringFlag{
+		Name:  "ipcmode",
+		Usage: "Octal file mode of the IPC socket, like 0660 (default owner only, ignored on Windows)",
+	}
+	IPCGroupFlag = cli.StringFlag{
+		Name:  "ipcgroup",
+		Usage: "Group to own the IPC socket, by name or id (ignored on Windows)",
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -408,7 +606,7 @@ var (
 	}
 	WSAllowedOriginsFlag = cli.StringFlag{
 		Name:  "wsorigins",
-		Usage: "Origins from which to accept websockets requests",
+		Usage: "Origins from which to accept websockets requests (wildcards like https://*.example.com allowed)",
 		Value: "",
 	}
 	RPCAllowIPFlag = cli.StringFlag{
@@ -416,10 +614,94 @@ var (
 		Usage: "Comma separated allowed RPC clients (CIDR notation OK) (http/ws)",
 		Value: "127.0.0.1/24",
 	}
+	RPCMaxBodySizeFlag = cli.Int64Flag{
+		Name:  "rpc.maxbodysize",
+		Usage: "Maximum size in bytes of HTTP-RPC request bodies and websocket messages (default 128KB)",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Calls per second allowed per HTTP-RPC/WS client IP for methods without their own limit (0 = unlimited)",
+	}
+	RPCMethodRateLimitsFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.methods",
+		Usage: "Comma separated per method rate limits per client IP (e.g. aqua_getLogs=2,aqua_blockNumber=0)",
+	}
+	RPCTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc.timeout",
+		Usage: "Maximum execution time of HTTP-RPC/WS calls of methods without their own timeout (0 = unlimited)",
+	}
+	RPCMethodTimeoutsFlag = cli.StringFlag{
+		Name:  "rpc.timeout.methods",
+		Usage: "Comma separated per method execution timeouts (e.g. debug_traceTransaction=30s,aqua_getLogs=10s)",
+	}
+	RPCBatchItemLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of calls in a HTTP-RPC/WS batch request (0 = unlimited)",
+		Value: node.DefaultConfig.RPCBatchItemLimit,
+	}
+	RPCBatchResponseMaxSizeFlag = cli.IntFlag{
+		Name:  "rpc.batchresponsesize",
+		Usage: "Maximum size in bytes of a HTTP-RPC/WS batch response (0 = unlimited)",
+		Value: node.DefaultConfig.RPCBatchResponseMaxSize,
+	}
+	RPCNotificationQueueFlag = cli.IntFlag{
+		Name:  "rpc.notificationqueue",
+		Usage: "Maximum number of notifications of a subscription waiting to be sent before the subscriber is dropped (default 10000)",
+	}
+	RPCCacheSizeFlag = cli.IntFlag{
+		Name:  "rpc.cachesize",
+		Usage: "Number of RPC results about final blocks and transactions to cache in memory (0 = disabled)",
+	}
+	RPCJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a hex encoded secret authenticating HTTP-RPC/WS requests with JWT bearer tokens (created if missing)",
+	}
+	RPCTLSCertFlag = cli.StringFlag{
+		Name:  "rpc.tlscert",
+		Usage: "PEM encoded certificate to serve HTTP-RPC/WS over TLS with",
+	}
+	RPCTLSKeyFlag = cli.StringFlag{
+		Name:  "rpc.tlskey",
+		Usage: "PEM encoded private key of the HTTP-RPC/WS TLS certificate",
+	}
+	RPCTLSClientCAFlag = cli.StringFlag{
+		Name:  "rpc.tlsclientca",
+		Usage: "PEM encoded authorities HTTP-RPC/WS clients must present a certificate from (requires TLS)",
+	}
+	RPCLogsMaxResultsFlag = cli.IntFlag{
+		Name:  "rpc.logs.maxresults",
+		Usage: "Maximum number of logs returned by aqua_getLogs (0 = unlimited, use aqua_getLogsPaged beyond)",
+		Value: aqua.DefaultConfig.LogsMaxResults,
+	}
+	RPCLogsMaxBlockRangeFlag = cli.Uint64Flag{
+		Name:  "rpc.logs.maxblockrange",
+		Usage: "Maximum number of blocks searched by aqua_getLogs (0 = unlimited, use aqua_getLogsPaged beyond)",
+	}
+	RPCAccessLogFlag = cli.StringFlag{
+		Name:  "rpc.accesslog",
+		Usage: "Log every HTTP-RPC request, to the node log (\"log\") or as JSON records appended to the given file",
+	}
+	RPCHealthMinPeersFlag = cli.IntFlag{
+		Name:  "rpc.health.minpeers",
+		Usage: "Minimum number of peers for the node to report ready on the HTTP-RPC /ready path",
+		Value: node.DefaultConfig.HealthMinPeers,
+	}
+	RPCHealthMaxBlockAgeFlag = cli.DurationFlag{
+		Name:  "rpc.health.maxblockage",
+		Usage: "Maximum age of the head block for the node to report ready on the HTTP-RPC /ready path (0 = any)",
+	}
+	GRPCAddrFlag = cli.StringFlag{
+		Name:  "grpc.addr",
+		Usage: "Listening address of the gRPC server for chain data (empty = disabled)",
+	}
 	RPCBehindProxyFlag = cli.BoolFlag{
 		Name:  "behindproxy",
 		Usage: "If RPC is behind a reverse proxy. Changes the way IP is fetched when comparing to allowed IP addresses",
 	}
+	RPCTrustedProxiesFlag = cli.StringFlag{
+		Name:  "rpc.trustedproxies",
+		Usage: "Comma separated reverse proxies (CIDR notation) allowed to forward client IPs with -behindproxy (default = loopback)",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -517,6 +799,108 @@ var (
 		Usage: "Suggested gas price is the given percentile of a set of recent transaction gas prices",
 		Value: aqua.DefaultConfig.GPO.Percentile,
 	}
+	GpoMaxPriceFlag = BigFlag{
+		Name:  "gpomaxprice",
+		Usage: "Maximum gas price suggested by the oracle, unless the local minimum (--gasprice) is higher",
+		Value: gasprice.DefaultMaxPrice,
+	}
+	// Database backup settings
+	BackupDirFlag = DirectoryFlag{
+		Name:  "backup.dir",
+		Usage: "Directory to store automatic chain database backups in (empty = disabled)",
+	}
+	BackupIntervalFlag = cli.DurationFlag{
+		Name:  "backup.interval",
+		Usage: "Time interval between automatic database backups (0 = on demand only)",
+		Value: dbbackup.DefaultConfig.Interval,
+	}
+	BackupKeepFlag = cli.IntFlag{
+		Name:  "backup.keep",
+		Usage: "Number of database backups to retain (0 = keep all)",
+		Value: dbbackup.DefaultConfig.Keep,
+	}
+	BackupURLFlag = cli.StringFlag{
+		Name:  "backup.url",
+		Usage: "Remote URL to additionally upload each backup to via HTTP PUT",
+	}
+	// Database replication settings
+	DBServeFlag = cli.StringFlag{
+		Name:  "db.serve",
+		Usage: "Listening address to serve the chain database read-only to replicas (empty = disabled)",
+	}
+	DBServeKeyFileFlag = cli.StringFlag{
+		Name:  "db.serve.keyfile",
+		Usage: "File holding the secret replicas must know to read the served chain database",
+	}
+	DBRemoteFlag = cli.StringFlag{
+		Name:  "db.remote",
+		Usage: "Address of a node serving its chain database, to run as a read-only replica of it",
+	}
+	DBRemoteKeyFileFlag = cli.StringFlag{
+		Name:  "db.remote.keyfile",
+		Usage: "File holding the secret shared with the node serving the chain database",
+	}
+	// Stratum server settings
+	StratumAddrFlag = cli.StringFlag{
+		Name:  "stratum.addr",
+		Usage: "Listening address of the stratum server distributing mining work (empty = disabled)",
+	}
+	StratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "stratum.difficulty",
+		Usage: "Share difficulty of stratum workers not requesting one with a \"d=<difficulty>\" password",
+		Value: stratum.DefaultConfig.Difficulty,
+	}
+	StratumMinDifficultyFlag = cli.Uint64Flag{
+		Name:  "stratum.mindifficulty",
+		Usage: "Lowest share difficulty stratum workers may request",
+		Value: stratum.DefaultConfig.MinDifficulty,
+	}
+	StratumMaxConnsFlag = cli.IntFlag{
+		Name:  "stratum.maxconns",
+		Usage: "Maximum number of connected stratum workers",
+		Value: stratum.DefaultConfig.MaxConns,
+	}
+	StratumMaxConnsPerIPFlag = cli.IntFlag{
+		Name:  "stratum.maxconnsperip",
+		Usage: "Maximum number of stratum workers connected from a single IP",
+		Value: stratum.DefaultConfig.MaxConnsPerIP,
+	}
+	// Dashboard settings
+	DashboardAddrFlag = cli.StringFlag{
+		Name:  "dashboard.addr",
+		Usage: "Listening address of the web dashboard, e.g. localhost:8080 (empty = disabled)",
+	}
+	DashboardRefreshFlag = cli.DurationFlag{
+		Name:  "dashboard.refresh",
+		Usage: "Time between two samples of the web dashboard charts",
+		Value: dashboard.DefaultConfig.Refresh,
+	}
+	// Alerting settings
+	AlertWebhookFlag = cli.StringFlag{
+		Name:  "alert.webhook",
+		Usage: "Comma separated URLs to POST JSON alerts on chain anomalies to (empty = disabled)",
+	}
+	AlertReorgDepthFlag = cli.Uint64Flag{
+		Name:  "alert.reorgdepth",
+		Usage: "Minimum depth of a chain reorg to alert on (0 = never)",
+		Value: alerting.DefaultConfig.ReorgDepth,
+	}
+	AlertStallFlag = cli.DurationFlag{
+		Name:  "alert.stall",
+		Usage: "Time without a new block to alert after (0 = never)",
+		Value: alerting.DefaultConfig.Stall,
+	}
+	AlertMinPeersFlag = cli.IntFlag{
+		Name:  "alert.minpeers",
+		Usage: "Peer count below which to alert (0 = never)",
+		Value: alerting.DefaultConfig.MinPeers,
+	}
+	AlertMismatchesFlag = cli.IntFlag{
+		Name:  "alert.mismatches",
+		Usage: "Number of peers rejected for being on another chain within 10 minutes to alert on (0 = never)",
+		Value: alerting.DefaultConfig.Mismatches,
+	}
+
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable Whisper",
@@ -531,6 +915,23 @@ var (
 		Usage: "Minimum POW accepted",
 		Value: whisper.DefaultMinimumPoW,
 	}
+	WhisperMailServerDirFlag = DirectoryFlag{
+		Name:  "shh.mailserver",
+		Usage: "Directory to archive whisper envelopes in, serving them to peers as a mail server (empty = disabled)",
+	}
+	WhisperMailServerPasswordFlag = cli.StringFlag{
+		Name:  "shh.mailserver.password",
+		Usage: "Password deriving the symmetric key peers must encrypt mail server requests with",
+	}
+	WhisperMailServerRetentionFlag = cli.DurationFlag{
+		Name:  "shh.mailserver.retention",
+		Usage: "Time archived envelopes are kept for (0 = forever)",
+		Value: mailserver.DefaultConfig.Retention,
+	}
+	WhisperMailServerTopicsFlag = cli.StringFlag{
+		Name:  "shh.mailserver.topics",
+		Usage: "Comma separated retention overrides per topic (e.g. 0x01020304=24h,0xaabbccdd=0)",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -606,6 +1007,13 @@ func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
 		urls = params.Testnet2Bootnodes
 	case ctx.GlobalBool(NetworkEthFlag.Name):
 		urls = params.EthnetBootnodes
+	case ctx.GlobalIsSet(GenesisFlag.Name):
+		urls = nil // custom networks don't bootstrap from the main network
+	case ctx.GlobalIsSet(NetworkIdFlag.Name):
+		urls = nil
+		if preset := makeNetworkPreset(ctx); preset != nil {
+			urls = preset.Bootnodes
+		}
 	case cfg.BootstrapNodes != nil:
 		return // already set, don't apply defaults.
 	}
@@ -713,6 +1121,102 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 
 	cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	cfg.RPCAllowIP = splitAndTrim(ctx.GlobalString(RPCAllowIPFlag.Name))
+	if ctx.GlobalIsSet(RPCMaxBodySizeFlag.Name) {
+		cfg.RPCMaxBodySize = ctx.GlobalInt64(RPCMaxBodySizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchItemLimitFlag.Name) {
+		cfg.RPCBatchItemLimit = ctx.GlobalInt(RPCBatchItemLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchResponseMaxSizeFlag.Name) {
+		cfg.RPCBatchResponseMaxSize = ctx.GlobalInt(RPCBatchResponseMaxSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCNotificationQueueFlag.Name) {
+		cfg.RPCNotificationQueue = ctx.GlobalInt(RPCNotificationQueueFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCCacheSizeFlag.Name) {
+		cfg.RPCCacheSize = ctx.GlobalInt(RPCCacheSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCJWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(RPCJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSCertFlag.Name) {
+		cfg.RPCTLSCert = ctx.GlobalString(RPCTLSCertFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSKeyFlag.Name) {
+		cfg.RPCTLSKey = ctx.GlobalString(RPCTLSKeyFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSClientCAFlag.Name) {
+		cfg.RPCTLSClientCA = ctx.GlobalString(RPCTLSClientCAFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAccessLogFlag.Name) {
+		cfg.RPCAccessLog = ctx.GlobalString(RPCAccessLogFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCHealthMinPeersFlag.Name) {
+		cfg.HealthMinPeers = ctx.GlobalInt(RPCHealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCHealthMaxBlockAgeFlag.Name) {
+		cfg.HealthMaxBlockAge = ctx.GlobalDuration(RPCHealthMaxBlockAgeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCRateLimit = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodRateLimitsFlag.Name) {
+		limits, err := parseMethodRateLimits(ctx.GlobalString(RPCMethodRateLimitsFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", RPCMethodRateLimitsFlag.Name, err)
+		}
+		cfg.RPCMethodRateLimits = limits
+	}
+	if ctx.GlobalIsSet(RPCTimeoutFlag.Name) {
+		cfg.RPCTimeout = ctx.GlobalDuration(RPCTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodTimeoutsFlag.Name) {
+		timeouts, err := parseMethodTimeouts(ctx.GlobalString(RPCMethodTimeoutsFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", RPCMethodTimeoutsFlag.Name, err)
+		}
+		cfg.RPCMethodTimeouts = timeouts
+	}
+}
+
+// parseMethodRateLimits parses a comma separated list of method=rate pairs.
+func parseMethodRateLimits(s string) (map[string]float64, error) {
+	limits := make(map[string]float64)
+	for _, entry := range splitAndTrim(s) {
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, want method=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate of %s: %q", parts[0], parts[1])
+		}
+		limits[strings.TrimSpace(parts[0])] = rate
+	}
+	return limits, nil
+}
+
+// parseMethodTimeouts parses a comma separated list of method=duration pairs.
+func parseMethodTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range splitAndTrim(s) {
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid timeout %q, want method=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout of %s: %q", parts[0], parts[1])
+		}
+		timeouts[strings.TrimSpace(parts[0])] = timeout
+	}
+	return timeouts, nil
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -737,7 +1241,8 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
-// returning an empty string if IPC was explicitly disabled, or the set path.
+// returning an empty string if IPC was explicitly disabled, or the set path,
+// along with the permissions of the socket.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
 	checkExclusive(ctx, IPCDisabledFlag, IPCPathFlag)
 	switch {
@@ -746,6 +1251,15 @@ func setIPC(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalIsSet(IPCPathFlag.Name):
 		cfg.IPCPath = ctx.GlobalString(IPCPathFlag.Name)
 	}
+	if ctx.GlobalIsSet(IPCApiFlag.Name) {
+		cfg.IPCModules = splitAndTrim(ctx.GlobalString(IPCApiFlag.Name))
+	}
+	if ctx.GlobalIsSet(IPCModeFlag.Name) {
+		cfg.IPCMode = ctx.GlobalString(IPCModeFlag.Name)
+	}
+	if ctx.GlobalIsSet(IPCGroupFlag.Name) {
+		cfg.IPCGroup = ctx.GlobalString(IPCGroupFlag.Name)
+	}
 }
 
 // makeDatabaseHandles raises out the number of allowed file handles per process
@@ -890,6 +1404,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalIsSet(NetworkIdFlag.Name):
 		cfg.P2P.ChainId = ctx.GlobalUint64(NetworkIdFlag.Name)
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), fmt.Sprintf("chainid-%v", cfg.P2P.ChainId))
+	case ctx.GlobalIsSet(GenesisFlag.Name):
+		cfg.P2P.ChainId = makeCustomGenesis(ctx).Config.ChainId.Uint64()
+		cfg.DataDir = filepath.Join(node.DefaultDataDir(), fmt.Sprintf("chainid-%v", cfg.P2P.ChainId))
 	case ctx.GlobalBool(DeveloperFlag.Name):
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "develop")
 		cfg.P2P.ChainId = 1337
@@ -917,8 +1434,26 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(DataDirFlag.Name) {
 		cfg.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(DataDirEncryptKeyFlag.Name) {
+		cfg.DatabaseKeyFile = ctx.GlobalString(DataDirEncryptKeyFlag.Name)
+	}
+	if ctx.GlobalIsSet(DatabaseEngineFlag.Name) {
+		cfg.DatabaseEngine = ctx.GlobalString(DatabaseEngineFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBRemoteFlag.Name) {
+		cfg.DatabaseRemote = ctx.GlobalString(DBRemoteFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBRemoteKeyFileFlag.Name) {
+		cfg.DatabaseRemoteKeyFile = ctx.GlobalString(DBRemoteKeyFileFlag.Name)
+	}
 
 	SetP2PConfig(ctx, &cfg.P2P)
+	if cfg.DatabaseRemote != "" {
+		// Replicas don't sync themselves, the serving node does it for them
+		log.Info("Running as read-only replica, disabling networking", "remote", cfg.DatabaseRemote)
+		cfg.P2P.MaxPeers = 0
+		cfg.P2P.NoDiscovery = true
+	}
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
@@ -936,6 +1471,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCBehindProxyFlag.Name) {
 		cfg.RPCBehindProxy = ctx.GlobalBool(RPCBehindProxyFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCTrustedProxiesFlag.Name) {
+		cfg.RPCTrustedProxies = splitAndTrim(ctx.GlobalString(RPCTrustedProxiesFlag.Name))
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -945,6 +1483,9 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	if ctx.GlobalIsSet(GpoPercentileFlag.Name) {
 		cfg.Percentile = ctx.GlobalInt(GpoPercentileFlag.Name)
 	}
+	if ctx.GlobalIsSet(GpoMaxPriceFlag.Name) {
+		cfg.MaxPrice = GlobalBig(ctx, GpoMaxPriceFlag.Name)
+	}
 }
 
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
@@ -954,6 +1495,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolJournalRemotesFlag.Name) {
+		cfg.JournalRemotes = ctx.GlobalBool(TxPoolJournalRemotesFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
@@ -978,6 +1522,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolEvictionFlag.Name) {
+		cfg.Eviction = ctx.GlobalString(TxPoolEvictionFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSenderLimitFlag.Name) {
+		cfg.SenderLimit = ctx.GlobalUint64(TxPoolSenderLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolOriginLimitFlag.Name) {
+		cfg.OriginLimit = ctx.GlobalUint64(TxPoolOriginLimitFlag.Name)
+	}
 }
 
 func setAquahash(ctx *cli.Context, cfg *aqua.Config) {
@@ -1049,10 +1602,31 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// SetMailServerConfig applies whisper mail server related command line flags to
+// the config.
+func SetMailServerConfig(ctx *cli.Context, cfg *mailserver.Config) {
+	if ctx.GlobalIsSet(WhisperMailServerDirFlag.Name) {
+		cfg.Dir = ctx.GlobalString(WhisperMailServerDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(WhisperMailServerPasswordFlag.Name) {
+		cfg.Password = ctx.GlobalString(WhisperMailServerPasswordFlag.Name)
+	}
+	if ctx.GlobalIsSet(WhisperMailServerRetentionFlag.Name) {
+		cfg.Retention = ctx.GlobalDuration(WhisperMailServerRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(WhisperMailServerTopicsFlag.Name) {
+		topics, err := mailserver.ParseTopicRetention(ctx.GlobalString(WhisperMailServerTopicsFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", WhisperMailServerTopicsFlag.Name, err)
+		}
+		cfg.Topics = topics
+	}
+}
+
 // SetAquaConfig applies aqua-related command line flags to the config.
 func SetAquaConfig(ctx *cli.Context, stack *node.Node, cfg *aqua.Config) {
 	// Avoid conflicting network flags
-	checkExclusive(ctx, DeveloperFlag, TestnetFlag, Testnet2Flag, NetworkEthFlag)
+	checkExclusive(ctx, DeveloperFlag, TestnetFlag, Testnet2Flag, NetworkEthFlag, GenesisFlag)
 	checkExclusive(ctx, FastSyncFlag, SyncModeFlag, OfflineFlag)
 
 	SetChainId(ctx, cfg)
@@ -1084,18 +1658,82 @@ func SetAquaConfig(ctx *cli.Context, stack *node.Node, cfg *aqua.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(CompactionWindowFlag.Name) {
+		cfg.CompactionWindow = ctx.GlobalString(CompactionWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinFreeDiskFlag.Name) {
+		cfg.MinFreeDisk = ctx.GlobalUint64(MinFreeDiskFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientDirFlag.Name) {
+		cfg.DatabaseAncient = ctx.GlobalString(AncientDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheWriteBufferFlag.Name) {
+		cfg.DatabaseWriteBuffer = ctx.GlobalInt(CacheWriteBufferFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientThresholdFlag.Name) {
+		cfg.AncientThreshold = ctx.GlobalUint64(AncientThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsMaxResultsFlag.Name) {
+		cfg.LogsMaxResults = ctx.GlobalInt(RPCLogsMaxResultsFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsMaxBlockRangeFlag.Name) {
+		cfg.LogsMaxBlockRange = ctx.GlobalUint64(RPCLogsMaxBlockRangeFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive', use 'archive' for full state", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	if ctx.GlobalIsSet(GCModeTriesFlag.Name) {
+		cfg.TriesInMemory = ctx.GlobalUint64(GCModeTriesFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalBool(SnapshotFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
+		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheBodiesFlag.Name) {
+		cfg.BodyCache = ctx.GlobalInt(CacheBodiesFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheBlocksFlag.Name) {
+		cfg.BlockCache = ctx.GlobalInt(CacheBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheReceiptsFlag.Name) {
+		cfg.ReceiptCache = ctx.GlobalInt(CacheReceiptsFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheHeadersFlag.Name) {
+		cfg.HeaderCache = ctx.GlobalInt(CacheHeadersFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerThrottleFlag.Name) {
+		cfg.MinerThrottle = ctx.GlobalInt(MinerThrottleFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerNiceFlag.Name) {
+		cfg.MinerNice = ctx.GlobalInt(MinerNiceFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerAffinityFlag.Name) {
+		cfg.MinerAffinity = nil
+		for _, field := range strings.Split(ctx.GlobalString(MinerAffinityFlag.Name), ",") {
+			cpu, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || cpu < 0 {
+				Fatalf("Invalid CPU %q in --%s", field, MinerAffinityFlag.Name)
+			}
+			cfg.MinerAffinity = append(cfg.MinerAffinity, cpu)
+		}
+	}
+	if ctx.GlobalIsSet(MinerStaleWindowFlag.Name) {
+		cfg.MinerStaleWindow = ctx.GlobalUint64(MinerStaleWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerTxOrderingFlag.Name) {
+		cfg.TxOrdering = ctx.GlobalString(MinerTxOrderingFlag.Name)
+	}
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
 	}
@@ -1160,6 +1798,13 @@ func SetChainId(ctx *cli.Context, cfg *aqua.Config) {
 			cfg.NetworkId = params.EthnetChainConfig.ChainId.Uint64()
 		}
 		cfg.Genesis = core.DefaultEthnetGenesisBlock()
+	case ctx.GlobalIsSet(GenesisFlag.Name) || ctx.GlobalIsSet(NetworkIdFlag.Name):
+		if genesis := makeCustomGenesis(ctx); genesis != nil {
+			cfg.Genesis = genesis
+			if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
+				cfg.NetworkId = genesis.Config.ChainId.Uint64()
+			}
+		}
 	}
 	// TODO(fjl): move trie cache generations into config
 	if gen := ctx.GlobalInt(TrieCacheGenFlag.Name); gen > 0 {
@@ -1191,6 +1836,16 @@ func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// RegisterMailServerService configures the whisper mail server and adds it to
+// the given node. The whisper service must be registered before.
+func RegisterMailServerService(stack *node.Node, cfg *mailserver.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return mailserver.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the whisper mail server: %v", err)
+	}
+}
+
 // RegisterAquaStatsService configures the AquaChain Stats daemon and adds it to
 // th egiven node.
 func RegisterAquaStatsService(stack *node.Node, url string) {
@@ -1205,12 +1860,245 @@ func RegisterAquaStatsService(stack *node.Node, url string) {
 	}
 }
 
+// SetBackupConfig applies database backup related command line flags to the
+// config.
+func SetBackupConfig(ctx *cli.Context, cfg *dbbackup.Config) {
+	if ctx.GlobalIsSet(BackupDirFlag.Name) {
+		cfg.Dir = ctx.GlobalString(BackupDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(BackupIntervalFlag.Name) {
+		cfg.Interval = ctx.GlobalDuration(BackupIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(BackupKeepFlag.Name) {
+		cfg.Keep = ctx.GlobalInt(BackupKeepFlag.Name)
+	}
+	if ctx.GlobalIsSet(BackupURLFlag.Name) {
+		cfg.URL = ctx.GlobalString(BackupURLFlag.Name)
+	}
+}
+
+// SetDBServerConfig applies database server related command line flags to the
+// config.
+func SetDBServerConfig(ctx *cli.Context, cfg *dbserver.Config) {
+	if ctx.GlobalIsSet(DBServeFlag.Name) {
+		cfg.Addr = ctx.GlobalString(DBServeFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBServeKeyFileFlag.Name) {
+		cfg.KeyFile = ctx.GlobalString(DBServeKeyFileFlag.Name)
+	}
+}
+
+// RegisterDBServerService configures the chain database server and adds it to
+// the given node.
+func RegisterDBServerService(stack *node.Node, cfg *dbserver.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return dbserver.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the database server service: %v", err)
+	}
+}
+
+// SetStratumConfig applies stratum server related command line flags to the
+// config.
+func SetStratumConfig(ctx *cli.Context, cfg *stratum.Config) {
+	if ctx.GlobalIsSet(StratumAddrFlag.Name) {
+		cfg.Addr = ctx.GlobalString(StratumAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumDifficultyFlag.Name) {
+		cfg.Difficulty = ctx.GlobalUint64(StratumDifficultyFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumMinDifficultyFlag.Name) {
+		cfg.MinDifficulty = ctx.GlobalUint64(StratumMinDifficultyFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumMaxConnsFlag.Name) {
+		cfg.MaxConns = ctx.GlobalInt(StratumMaxConnsFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumMaxConnsPerIPFlag.Name) {
+		cfg.MaxConnsPerIP = ctx.GlobalInt(StratumMaxConnsPerIPFlag.Name)
+	}
+}
+
+// RegisterStratumService configures the stratum server and adds it to the given
+// node.
+func RegisterStratumService(stack *node.Node, cfg *stratum.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return stratum.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the stratum server service: %v", err)
+	}
+}
+
+// SetGRPCConfig applies gRPC server related command line flags to the config.
+func SetGRPCConfig(ctx *cli.Context, cfg *aquagrpc.Config) {
+	if ctx.GlobalIsSet(GRPCAddrFlag.Name) {
+		cfg.Addr = ctx.GlobalString(GRPCAddrFlag.Name)
+	}
+}
+
+// RegisterGRPCService configures the gRPC server and adds it to the given node.
+func RegisterGRPCService(stack *node.Node, cfg *aquagrpc.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return aquagrpc.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the gRPC server service: %v", err)
+	}
+}
+
+// SetDashboardConfig applies dashboard related command line flags to the config.
+func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
+	if ctx.GlobalIsSet(DashboardAddrFlag.Name) {
+		cfg.Addr = ctx.GlobalString(DashboardAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(DashboardRefreshFlag.Name) {
+		cfg.Refresh = ctx.GlobalDuration(DashboardRefreshFlag.Name)
+	}
+}
+
+// RegisterDashboardService configures the web dashboard and adds it to the given
+// node.
+func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return dashboard.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the dashboard service: %v", err)
+	}
+}
+
+// SetAlertingConfig applies alerting related command line flags to the config.
+func SetAlertingConfig(ctx *cli.Context, cfg *alerting.Config) {
+	if ctx.GlobalIsSet(AlertWebhookFlag.Name) {
+		cfg.Webhooks = nil
+		for _, url := range strings.Split(ctx.GlobalString(AlertWebhookFlag.Name), ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				cfg.Webhooks = append(cfg.Webhooks, url)
+			}
+		}
+	}
+	if ctx.GlobalIsSet(AlertReorgDepthFlag.Name) {
+		cfg.ReorgDepth = ctx.GlobalUint64(AlertReorgDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(AlertStallFlag.Name) {
+		cfg.Stall = ctx.GlobalDuration(AlertStallFlag.Name)
+	}
+	if ctx.GlobalIsSet(AlertMinPeersFlag.Name) {
+		cfg.MinPeers = ctx.GlobalInt(AlertMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(AlertMismatchesFlag.Name) {
+		cfg.Mismatches = ctx.GlobalInt(AlertMismatchesFlag.Name)
+	}
+}
+
+// RegisterAlertingService configures the webhook alerting and adds it to the
+// given node.
+func RegisterAlertingService(stack *node.Node, cfg *alerting.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return alerting.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the alerting service: %v", err)
+	}
+}
+
+// RegisterBackupService configures the database backup service and adds it to
+// the given node.
+func RegisterBackupService(stack *node.Node, cfg *dbbackup.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return dbbackup.New(ctx, *cfg)
+	}); err != nil {
+		Fatalf("Failed to register the database backup service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetworkGasLimit(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
 	params.TargetGasLimit = ctx.GlobalUint64(TargetGasLimitFlag.Name)
 }
 
+// SetupMetrics enables the requested metrics modules and starts the metrics
+// server and the InfluxDB reporters if requested on the command line.
+func SetupMetrics(ctx *cli.Context) {
+	if ctx.GlobalIsSet(MetricsModulesFlag.Name) {
+		for _, module := range strings.Split(ctx.GlobalString(MetricsModulesFlag.Name), ",") {
+			if module = strings.TrimSpace(module); module == "" {
+				continue
+			}
+			if err := metrics.SetModule(module, true); err != nil {
+				Fatalf("Invalid --%s: %v %q, have %v", MetricsModulesFlag.Name, err, module, metrics.ModuleNames())
+			}
+		}
+	}
+	if address := ctx.GlobalString(MetricsAddrFlag.Name); address != "" {
+		if err := exp.Setup(address); err != nil {
+			Fatalf("Failed to start metrics server: %v", err)
+		}
+	}
+	var (
+		enableV1 = ctx.GlobalBool(MetricsEnableInfluxDBFlag.Name)
+		enableV2 = ctx.GlobalBool(MetricsEnableInfluxDBV2Flag.Name)
+		endpoint = ctx.GlobalString(MetricsInfluxDBEndpointFlag.Name)
+	)
+	if !enableV1 && !enableV2 {
+		return
+	}
+	if enableV1 && enableV2 {
+		Fatalf("Flags --%s and --%s can't be used at the same time", MetricsEnableInfluxDBFlag.Name, MetricsEnableInfluxDBV2Flag.Name)
+	}
+	if !metrics.Enabled {
+		log.Warn("InfluxDB reporting requested without metrics collection", "flag", "--"+MetricsEnabledFlag.Name)
+		return
+	}
+	tags, err := splitTagsFlag(ctx.GlobalString(MetricsInfluxDBTagsFlag.Name))
+	if err != nil {
+		Fatalf("Invalid --%s: %v", MetricsInfluxDBTagsFlag.Name, err)
+	}
+	if enableV1 {
+		database := ctx.GlobalString(MetricsInfluxDBDatabaseFlag.Name)
+		log.Info("Enabling metrics export to InfluxDB", "endpoint", endpoint, "database", database)
+		go influxdb.InfluxDBWithTags(metrics.DefaultRegistry, 10*time.Second, endpoint, database,
+			ctx.GlobalString(MetricsInfluxDBUsernameFlag.Name), ctx.GlobalString(MetricsInfluxDBPasswordFlag.Name), "aquachain.", tags)
+		return
+	}
+	var (
+		token  = ctx.GlobalString(MetricsInfluxDBTokenFlag.Name)
+		bucket = ctx.GlobalString(MetricsInfluxDBBucketFlag.Name)
+		org    = ctx.GlobalString(MetricsInfluxDBOrganizationFlag.Name)
+	)
+	if token == "" || org == "" {
+		Fatalf("InfluxDB v2 requires --%s and --%s", MetricsInfluxDBTokenFlag.Name, MetricsInfluxDBOrganizationFlag.Name)
+	}
+	log.Info("Enabling metrics export to InfluxDB (v2)", "endpoint", endpoint, "bucket", bucket, "organization", org)
+	go influxdb.InfluxDBV2WithTags(metrics.DefaultRegistry, 10*time.Second, endpoint, token, bucket, org, "aquachain.", tags)
+}
+
+// SetupTracing enables exporting traces if requested on the command line.
+func SetupTracing(ctx *cli.Context) error {
+	endpoint := ctx.GlobalString(TracingEndpointFlag.Name)
+	if endpoint == "" {
+		return nil
+	}
+	if err := tracing.Setup(endpoint, "aquachain", ctx.GlobalFloat64(TracingSampleRatioFlag.Name)); err != nil {
+		return err
+	}
+	log.Info("Exporting traces", "endpoint", endpoint, "ratio", ctx.GlobalFloat64(TracingSampleRatioFlag.Name))
+	return nil
+}
+
+// splitTagsFlag parses a comma-separated list of key=value tags.
+func splitTagsFlag(tagsFlag string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, t := range strings.Split(tagsFlag, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("malformed tag %q, want key=value", t)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) aquadb.Database {
 	var (
@@ -1222,6 +2110,23 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) aquadb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	if size := ctx.GlobalInt(CacheWriteBufferFlag.Name); size > 0 {
+		if journal := stack.ResolvePath(name + ".journal"); journal != "" {
+			bdb, err := aquadb.NewBufferedDatabase(chainDb, journal, size*1024*1024)
+			if err != nil {
+				Fatalf("Could not open database write buffer: %v", err)
+			}
+			chainDb = bdb
+		}
+	}
+	if ctx.GlobalIsSet(AncientDirFlag.Name) {
+		dir := stack.ResolvePath(ctx.GlobalString(AncientDirFlag.Name))
+		fdb, err := core.NewFreezerDatabase(chainDb, dir, ctx.GlobalUint64(AncientThresholdFlag.Name))
+		if err != nil {
+			Fatalf("Could not open ancient chain store: %v", err)
+		}
+		chainDb = fdb
+	}
 	return chainDb
 }
 
@@ -1236,10 +2141,49 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 		genesis = core.DefaultEthnetGenesisBlock()
 	case ctx.GlobalBool(DeveloperFlag.Name):
 		Fatalf("Developer chains are ephemeral")
+	default:
+		genesis = makeCustomGenesis(ctx)
 	}
 	return genesis
 }
 
+var loadPresetsOnce sync.Once
+
+// makeNetworkPreset returns the preset of the network selected by --networkid,
+// loading the presets of --network.presets first. It returns nil if there's no
+// preset for the network.
+func makeNetworkPreset(ctx *cli.Context) *core.NetworkPreset {
+	loadPresetsOnce.Do(func() {
+		if file := ctx.GlobalString(NetworkPresetsFlag.Name); file != "" {
+			list, err := core.LoadNetworkPresets(file)
+			if err != nil {
+				Fatalf("Could not load network presets: %v", err)
+			}
+			log.Debug("Loaded network presets", "file", file, "count", len(list))
+		}
+	})
+	if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
+		return nil
+	}
+	return core.NetworkPresetByID(ctx.GlobalUint64(NetworkIdFlag.Name))
+}
+
+// makeCustomGenesis returns the genesis given by --genesis, or else by the preset
+// of the network selected by --networkid, nil if neither.
+func makeCustomGenesis(ctx *cli.Context) *core.Genesis {
+	if file := ctx.GlobalString(GenesisFlag.Name); file != "" {
+		genesis, err := core.LoadGenesis(file)
+		if err != nil {
+			Fatalf("Could not load genesis: %v", err)
+		}
+		return genesis
+	}
+	if preset := makeNetworkPreset(ctx); preset != nil {
+		return preset.GenesisBlock()
+	}
+	return nil
+}
+
 // MakeChain creates a chain manager from set command line flags.
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb aquadb.Database) {
 	var err error
@@ -1270,6 +2214,15 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: aqua.DefaultConfig.TrieCache,
 		TrieTimeLimit: aqua.DefaultConfig.TrieTimeout,
+		TriesInMemory: ctx.GlobalUint64(GCModeTriesFlag.Name),
+		Snapshot:      ctx.GlobalBool(SnapshotFlag.Name),
+
+		BodyCacheLimit:     ctx.GlobalInt(CacheBodiesFlag.Name),
+		BlockCacheLimit:    ctx.GlobalInt(CacheBlocksFlag.Name),
+		ReceiptsCacheLimit: ctx.GlobalInt(CacheReceiptsFlag.Name),
+		HeaderCacheLimit:   ctx.GlobalInt(CacheHeadersFlag.Name),
+
+		TxLookupLimit: ctx.GlobalUint64(TxLookupLimitFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -0,0 +1,41 @@
+// +build windows
+
+package log
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event identifier all records are reported with. Sources
+// registered via eventlog.InstallAsEventCreate accept any id from 1 to 1000.
+const eventID = 1
+
+// EventlogHandler opens the Windows event log under the given source name and
+// writes all records to it. The source must have been registered beforehand,
+// which requires administrative privileges.
+func EventlogHandler(source string, fmtr Format) (Handler, error) {
+	el, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	h := FuncHandler(func(r *Record) error {
+		s := strings.TrimSpace(string(fmtr.Format(r)))
+		switch r.Lvl {
+		case LvlCrit, LvlError:
+			return el.Error(eventID, s)
+		case LvlWarn:
+			return el.Warning(eventID, s)
+		case LvlTrace:
+			return nil // Keep the event log readable, there's no trace level either
+		default:
+			return el.Info(eventID, s)
+		}
+	})
+	return LazyHandler(h), nil
+}
+
+func (m muster) EventlogHandler(source string, fmtr Format) Handler {
+	return must(EventlogHandler(source, fmtr))
+}
@@ -5,10 +5,13 @@ package exp
 import (
 	"expvar"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
 
+	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/common/metrics/prometheus"
 )
 
 type exp struct {
@@ -50,6 +53,45 @@ func ExpHandler(r metrics.Registry) http.Handler {
 	return http.HandlerFunc(e.expHandler)
 }
 
+// VarsHandler returns a handler serving the process's expvars like the standard
+// /debug/vars endpoint, leaving out the command line as it may hold secrets.
+func VarsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if kv.Key == "cmdline" {
+				return
+			}
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
+
+// SetupVars starts a dedicated server at the given address, serving only the
+// expvars on /debug/vars. Unlike Setup, it doesn't need metrics collection.
+func SetupVars(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	m := http.NewServeMux()
+	m.Handle("/debug/vars", VarsHandler())
+	log.Info("Starting debug vars server", "addr", fmt.Sprintf("http://%s/debug/vars", listener.Addr()))
+	go func() {
+		if err := http.Serve(listener, m); err != nil {
+			log.Error("Failure in running debug vars server", "err", err)
+		}
+	}()
+	return nil
+}
+
 func (exp *exp) getInt(name string) *expvar.Int {
 	var v *expvar.Int
 	exp.expvarLock.Lock()
@@ -154,3 +196,24 @@ func (exp *exp) syncToExpvar() {
 		}
 	})
 }
+
+// Setup starts a dedicated metrics server at the given address, serving the
+// registry as expvar JSON on /debug/metrics and in Prometheus exposition format
+// on /debug/metrics/prometheus, as well as the expvars on /debug/vars.
+func Setup(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	m := http.NewServeMux()
+	m.Handle("/debug/metrics", ExpHandler(metrics.DefaultRegistry))
+	m.Handle("/debug/metrics/prometheus", prometheus.Handler(metrics.DefaultRegistry))
+	m.Handle("/debug/vars", VarsHandler())
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/debug/metrics", listener.Addr()))
+	go func() {
+		if err := http.Serve(listener, m); err != nil {
+			log.Error("Failure in running metrics server", "err", err)
+		}
+	}()
+	return nil
+}
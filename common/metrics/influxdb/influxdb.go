@@ -95,125 +95,13 @@ func (r *reporter) send() error {
 	var pts []client.Point
 
 	r.reg.Each(func(name string, i interface{}) {
-		now := time.Now()
-		namespace := r.namespace
-
-		switch metric := i.(type) {
-		case metrics.Counter:
-			v := metric.Count()
-			l := r.cache[name]
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.count", namespace, name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": v - l,
-				},
-				Time: now,
-			})
-			r.cache[name] = v
-		case metrics.Gauge:
-			ms := metric.Snapshot()
+		if measurement, fields, ok := readMeter(r.namespace, name, i, r.cache); ok {
 			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.gauge", namespace, name),
+				Measurement: measurement,
 				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": ms.Value(),
-				},
-				Time: now,
+				Fields:      fields,
+				Time:        time.Now(),
 			})
-		case metrics.GaugeFloat64:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.gauge", namespace, name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"value": ms.Value(),
-				},
-				Time: now,
-			})
-		case metrics.Histogram:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.histogram", namespace, name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count":    ms.Count(),
-					"max":      ms.Max(),
-					"mean":     ms.Mean(),
-					"min":      ms.Min(),
-					"stddev":   ms.StdDev(),
-					"variance": ms.Variance(),
-					"p50":      ps[0],
-					"p75":      ps[1],
-					"p95":      ps[2],
-					"p99":      ps[3],
-					"p999":     ps[4],
-					"p9999":    ps[5],
-				},
-				Time: now,
-			})
-		case metrics.Meter:
-			ms := metric.Snapshot()
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.meter", namespace, name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count": ms.Count(),
-					"m1":    ms.Rate1(),
-					"m5":    ms.Rate5(),
-					"m15":   ms.Rate15(),
-					"mean":  ms.RateMean(),
-				},
-				Time: now,
-			})
-		case metrics.Timer:
-			ms := metric.Snapshot()
-			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
-			pts = append(pts, client.Point{
-				Measurement: fmt.Sprintf("%s%s.timer", namespace, name),
-				Tags:        r.tags,
-				Fields: map[string]interface{}{
-					"count":    ms.Count(),
-					"max":      ms.Max(),
-					"mean":     ms.Mean(),
-					"min":      ms.Min(),
-					"stddev":   ms.StdDev(),
-					"variance": ms.Variance(),
-					"p50":      ps[0],
-					"p75":      ps[1],
-					"p95":      ps[2],
-					"p99":      ps[3],
-					"p999":     ps[4],
-					"p9999":    ps[5],
-					"m1":       ms.Rate1(),
-					"m5":       ms.Rate5(),
-					"m15":      ms.Rate15(),
-					"meanrate": ms.RateMean(),
-				},
-				Time: now,
-			})
-		case metrics.ResettingTimer:
-			t := metric.Snapshot()
-
-			if len(t.Values()) > 0 {
-				ps := t.Percentiles([]float64{50, 95, 99})
-				val := t.Values()
-				pts = append(pts, client.Point{
-					Measurement: fmt.Sprintf("%s%s.span", namespace, name),
-					Tags:        r.tags,
-					Fields: map[string]interface{}{
-						"count": len(val),
-						"max":   val[len(val)-1],
-						"mean":  t.Mean(),
-						"min":   val[0],
-						"p50":   ps[0],
-						"p95":   ps[1],
-						"p99":   ps[2],
-					},
-					Time: now,
-				})
-			}
 		}
 	})
 
@@ -225,3 +113,92 @@ func (r *reporter) send() error {
 	_, err := r.client.Write(bps)
 	return err
 }
+
+// readMeter returns the measurement name and fields to report for a single
+// metric. Counters are reported as the difference since the last report, which
+// is tracked in cache.
+func readMeter(namespace, name string, i interface{}, cache map[string]int64) (string, map[string]interface{}, bool) {
+	switch metric := i.(type) {
+	case metrics.Counter:
+		v := metric.Count()
+		l := cache[name]
+		cache[name] = v
+		return fmt.Sprintf("%s%s.count", namespace, name), map[string]interface{}{
+			"value": v - l,
+		}, true
+	case metrics.Gauge:
+		ms := metric.Snapshot()
+		return fmt.Sprintf("%s%s.gauge", namespace, name), map[string]interface{}{
+			"value": ms.Value(),
+		}, true
+	case metrics.GaugeFloat64:
+		ms := metric.Snapshot()
+		return fmt.Sprintf("%s%s.gauge", namespace, name), map[string]interface{}{
+			"value": ms.Value(),
+		}, true
+	case metrics.Histogram:
+		ms := metric.Snapshot()
+		ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
+		return fmt.Sprintf("%s%s.histogram", namespace, name), map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      ms.Max(),
+			"mean":     ms.Mean(),
+			"min":      ms.Min(),
+			"stddev":   ms.StdDev(),
+			"variance": ms.Variance(),
+			"p50":      ps[0],
+			"p75":      ps[1],
+			"p95":      ps[2],
+			"p99":      ps[3],
+			"p999":     ps[4],
+			"p9999":    ps[5],
+		}, true
+	case metrics.Meter:
+		ms := metric.Snapshot()
+		return fmt.Sprintf("%s%s.meter", namespace, name), map[string]interface{}{
+			"count": ms.Count(),
+			"m1":    ms.Rate1(),
+			"m5":    ms.Rate5(),
+			"m15":   ms.Rate15(),
+			"mean":  ms.RateMean(),
+		}, true
+	case metrics.Timer:
+		ms := metric.Snapshot()
+		ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
+		return fmt.Sprintf("%s%s.timer", namespace, name), map[string]interface{}{
+			"count":    ms.Count(),
+			"max":      ms.Max(),
+			"mean":     ms.Mean(),
+			"min":      ms.Min(),
+			"stddev":   ms.StdDev(),
+			"variance": ms.Variance(),
+			"p50":      ps[0],
+			"p75":      ps[1],
+			"p95":      ps[2],
+			"p99":      ps[3],
+			"p999":     ps[4],
+			"p9999":    ps[5],
+			"m1":       ms.Rate1(),
+			"m5":       ms.Rate5(),
+			"m15":      ms.Rate15(),
+			"meanrate": ms.RateMean(),
+		}, true
+	case metrics.ResettingTimer:
+		t := metric.Snapshot()
+
+		if len(t.Values()) > 0 {
+			ps := t.Percentiles([]float64{50, 95, 99})
+			val := t.Values()
+			return fmt.Sprintf("%s%s.span", namespace, name), map[string]interface{}{
+				"count": len(val),
+				"max":   val[len(val)-1],
+				"mean":  t.Mean(),
+				"min":   val[0],
+				"p50":   ps[0],
+				"p95":   ps[1],
+				"p99":   ps[2],
+			}, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,152 @@
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	uurl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+type v2Reporter struct {
+	reg      metrics.Registry
+	interval time.Duration
+
+	endpoint     string
+	token        string
+	bucket       string
+	organization string
+	namespace    string
+	tags         map[string]string
+
+	client *http.Client
+
+	cache map[string]int64
+}
+
+// InfluxDBV2WithTags starts a InfluxDB v2 reporter which will post the metrics
+// from the given metrics.Registry at each d interval with the specified tags,
+// authenticating with an API token and writing into a bucket of an organization.
+func InfluxDBV2WithTags(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string, tags map[string]string) {
+	if _, err := uurl.Parse(endpoint); err != nil {
+		log.Printf("unable to parse InfluxDB url %s. err=%v", endpoint, err)
+		return
+	}
+	rep := &v2Reporter{
+		reg:          r,
+		interval:     d,
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		token:        token,
+		bucket:       bucket,
+		organization: organization,
+		namespace:    namespace,
+		tags:         tags,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		cache:        make(map[string]int64),
+	}
+	rep.run()
+}
+
+func (r *v2Reporter) run() {
+	intervalTicker := time.Tick(r.interval)
+
+	for range intervalTicker {
+		if err := r.send(); err != nil {
+			log.Printf("unable to send to InfluxDB. err=%v", err)
+		}
+	}
+}
+
+func (r *v2Reporter) send() error {
+	var (
+		buf = new(bytes.Buffer)
+		now = time.Now().UnixNano()
+	)
+	r.reg.Each(func(name string, i interface{}) {
+		if measurement, fields, ok := readMeter(r.namespace, name, i, r.cache); ok {
+			writeLine(buf, measurement, r.tags, fields, now)
+		}
+	})
+	if buf.Len() == 0 {
+		return nil
+	}
+	query := uurl.Values{
+		"org":       {r.organization},
+		"bucket":    {r.bucket},
+		"precision": {"ns"},
+	}
+	req, err := http.NewRequest("POST", r.endpoint+"/api/v2/write?"+query.Encode(), buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+r.token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// writeLine writes a single point in the InfluxDB line protocol, with the tags
+// and fields sorted by key. Points without any finite field are skipped.
+func writeLine(buf *bytes.Buffer, measurement string, tags map[string]string, fields map[string]interface{}, timestamp int64) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		var value string
+		switch v := fields[key].(type) {
+		case int:
+			value = strconv.Itoa(v) + "i"
+		case int64:
+			value = strconv.FormatInt(v, 10) + "i"
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			continue
+		}
+		values = append(values, keyEscaper.Replace(key)+"="+value)
+	}
+	if len(values) == 0 {
+		return
+	}
+	buf.WriteString(measurementEscaper.Replace(measurement))
+
+	keys = keys[:0]
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(buf, ",%s=%s", keyEscaper.Replace(key), keyEscaper.Replace(tags[key]))
+	}
+	fmt.Fprintf(buf, " %s %d\n", strings.Join(values, ","), timestamp)
+}
@@ -0,0 +1,92 @@
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+func init() {
+	metrics.Enabled = true
+}
+
+func TestV2Send(t *testing.T) {
+	var (
+		path, auth string
+		body       []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.String(), r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	reg := metrics.NewRegistry()
+	counter := metrics.NewCounter()
+	counter.Inc(5)
+	reg.Register("txpool/pending/discard", counter)
+	gauge := metrics.NewGaugeFloat64()
+	gauge.Update(1.5)
+	reg.Register("p2p/peers", gauge)
+
+	rep := &v2Reporter{
+		reg:          reg,
+		endpoint:     server.URL,
+		token:        "secret",
+		bucket:       "chain",
+		organization: "aqua org",
+		namespace:    "aquachain.",
+		tags:         map[string]string{"host": "node 1", "env": "test"},
+		client:       http.DefaultClient,
+		cache:        make(map[string]int64),
+	}
+	if err := rep.send(); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if want := "/api/v2/write?bucket=chain&org=aqua+org&precision=ns"; path != want {
+		t.Errorf("path mismatch: have %s, want %s", path, want)
+	}
+	if auth != "Token secret" {
+		t.Errorf("authorization mismatch: have %q", auth)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("line count mismatch: have %d, want 2:\n%s", len(lines), body)
+	}
+	for _, want := range []string{
+		`aquachain.txpool/pending/discard.count,env=test,host=node\ 1 value=5i `,
+		`aquachain.p2p/peers.gauge,env=test,host=node\ 1 value=1.5 `,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+	// Counters are reported as the difference since the last report
+	counter.Inc(2)
+	if err := rep.send(); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if !strings.Contains(string(body), "discard.count,env=test,host=node\\ 1 value=2i ") {
+		t.Errorf("counter delta mismatch:\n%s", body)
+	}
+}
+
+func TestV2SendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	reg := metrics.NewRegistry()
+	reg.Register("p2p/peers", metrics.NewGauge())
+
+	rep := &v2Reporter{reg: reg, endpoint: server.URL, client: http.DefaultClient, cache: make(map[string]int64)}
+	if err := rep.send(); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("error mismatch: have %v", err)
+	}
+}
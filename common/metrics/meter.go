@@ -35,6 +35,12 @@ func NewMeter() Meter {
 	if !Enabled {
 		return NilMeter{}
 	}
+	return newArbitratedMeter()
+}
+
+// newArbitratedMeter constructs a new StandardMeter ticked by the arbiter,
+// regardless of whether metrics are enabled.
+func newArbitratedMeter() *StandardMeter {
 	m := newStandardMeter()
 	arbiter.Lock()
 	defer arbiter.Unlock()
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrUnknownModule is returned when toggling a metrics module which doesn't
+// exist.
+var ErrUnknownModule = errors.New("unknown metrics module")
+
+// Module is a switch for a group of metrics which are expensive to collect,
+// like per-opcode or per-peer metrics. Unlike the rest of the metrics system,
+// modules can be switched on and off at runtime, and their metrics are
+// collected regardless of the global Enabled kill-switch.
+//
+// Code collecting the metrics of a module must check Enabled before updating
+// them.
+type Module struct {
+	name    string
+	enabled int32
+}
+
+var (
+	modules        = make(map[string]*Module)
+	modulesMu      sync.Mutex
+	errEmptyModule = errors.New("empty metrics module name")
+)
+
+// NewModule registers a metrics module with the given name and initial state,
+// returning the existing one if already registered.
+func NewModule(name string, enabled bool) *Module {
+	if name == "" {
+		panic(errEmptyModule)
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	if m, ok := modules[name]; ok {
+		return m
+	}
+	m := &Module{name: name}
+	m.set(enabled)
+	modules[name] = m
+	return m
+}
+
+// Name returns the name of the module.
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Enabled reports whether the metrics of the module should be collected.
+func (m *Module) Enabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+func (m *Module) set(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&m.enabled, 1)
+	} else {
+		atomic.StoreInt32(&m.enabled, 0)
+	}
+}
+
+// Counter creates and registers a counter of the module.
+func (m *Module) Counter(name string, r Registry) Counter {
+	return registerForced(name, r, func() interface{} { return &StandardCounter{} }).(Counter)
+}
+
+// Meter creates and registers a meter of the module. Be sure to unregister it
+// from the registry once it is of no use to allow for garbage collection.
+func (m *Module) Meter(name string, r Registry) Meter {
+	return registerForced(name, r, func() interface{} { return newArbitratedMeter() }).(Meter)
+}
+
+// Histogram creates and registers a histogram of the module, using an
+// exponentially-decaying sample.
+func (m *Module) Histogram(name string, r Registry) Histogram {
+	return registerForced(name, r, func() interface{} {
+		return &StandardHistogram{sample: newExpDecaySample(1028, 0.015)}
+	}).(Histogram)
+}
+
+// Timer creates and registers a timer of the module. Be sure to unregister it
+// from the registry once it is of no use to allow for garbage collection.
+func (m *Module) Timer(name string, r Registry) Timer {
+	return registerForced(name, r, func() interface{} {
+		return &StandardTimer{
+			histogram: &StandardHistogram{sample: newExpDecaySample(1028, 0.015)},
+			meter:     newArbitratedMeter(),
+		}
+	}).(Timer)
+}
+
+func registerForced(name string, r Registry, constructor func() interface{}) interface{} {
+	if r == nil {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, constructor)
+}
+
+// Modules returns the state of all registered metrics modules.
+func Modules() map[string]bool {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	states := make(map[string]bool, len(modules))
+	for name, m := range modules {
+		states[name] = m.Enabled()
+	}
+	return states
+}
+
+// ModuleNames returns the sorted names of all registered metrics modules.
+func ModuleNames() []string {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetModule switches the collection of a metrics module on or off.
+func SetModule(name string, enabled bool) error {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	m, ok := modules[name]
+	if !ok {
+		return ErrUnknownModule
+	}
+	m.set(enabled)
+	return nil
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import "testing"
+
+func TestModule(t *testing.T) {
+	m := NewModule("test/module", false)
+	if m.Enabled() {
+		t.Fatal("module enabled initially")
+	}
+	if NewModule("test/module", true) != m {
+		t.Fatal("module registered twice")
+	}
+	if err := SetModule("test/module", true); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Enabled() || !Modules()["test/module"] {
+		t.Fatal("module not enabled")
+	}
+	if err := SetModule("test/missing", true); err != ErrUnknownModule {
+		t.Fatalf("unknown module error mismatch: have %v, want %v", err, ErrUnknownModule)
+	}
+}
+
+func TestModuleMetricsForced(t *testing.T) {
+	Enabled = false
+	defer func() { Enabled = true }()
+
+	m := NewModule("test/forced", true)
+	r := NewRegistry()
+
+	c := m.Counter("test/forced/counter", r)
+	c.Inc(3)
+	if c.Count() != 3 {
+		t.Errorf("counter not collected while metrics disabled: %d", c.Count())
+	}
+	h := m.Histogram("test/forced/histogram", r)
+	h.Update(5)
+	if h.Count() != 1 {
+		t.Errorf("histogram not collected while metrics disabled: %d", h.Count())
+	}
+	timer := m.Timer("test/forced/timer", r)
+	defer timer.Stop()
+	timer.Update(1)
+	if timer.Count() != 1 {
+		t.Errorf("timer not collected while metrics disabled: %d", timer.Count())
+	}
+	if r.Get("test/forced/counter") != c {
+		t.Errorf("counter not registered")
+	}
+	// Registering again returns the existing metric
+	if m.Counter("test/forced/counter", r) != c {
+		t.Errorf("counter registered twice")
+	}
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// quantiles are the percentiles reported for histograms and timers.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// collector accumulates metrics in the Prometheus text exposition format.
+type collector struct {
+	buf *bytes.Buffer
+}
+
+func newCollector() *collector {
+	return &collector{buf: new(bytes.Buffer)}
+}
+
+func (c *collector) addCounter(name string, value float64) {
+	c.writeSample(mutateKey(name), "counter", value)
+}
+
+func (c *collector) addGauge(name string, value float64) {
+	c.writeSample(mutateKey(name), "gauge", value)
+}
+
+func (c *collector) addSummary(name string, count int64, sum float64, values []float64) {
+	name = mutateKey(name)
+	fmt.Fprintf(c.buf, "# TYPE %s summary\n", name)
+	for i, q := range quantiles {
+		fmt.Fprintf(c.buf, "%s{quantile=\"%s\"} %s\n", name, strconv.FormatFloat(q, 'f', -1, 64), formatValue(values[i]))
+	}
+	fmt.Fprintf(c.buf, "%s_sum %s\n", name, formatValue(sum))
+	fmt.Fprintf(c.buf, "%s_count %d\n\n", name, count)
+}
+
+func (c *collector) writeSample(name string, kind string, value float64) {
+	fmt.Fprintf(c.buf, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(c.buf, "%s %s\n\n", name, formatValue(value))
+}
+
+// mutateKey converts a go-metrics name (e.g. chain/inserts) into a valid
+// Prometheus metric name (e.g. chain_inserts).
+func mutateKey(key string) string {
+	return strings.NewReplacer("/", "_", "-", "_", ".", "_").Replace(key)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,69 @@
+// Package prometheus exposes go-metrics registries in the Prometheus text
+// exposition format.
+package prometheus
+
+import (
+	"net/http"
+	"sort"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+// Handler returns an HTTP handler which writes all metrics of the registry in
+// the Prometheus text exposition format on every request.
+func Handler(reg metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Gather and sort the metric names for a stable output
+		names := []string{}
+		reg.Each(func(name string, i interface{}) {
+			names = append(names, name)
+		})
+		sort.Strings(names)
+
+		c := newCollector()
+		for _, name := range names {
+			switch m := reg.Get(name).(type) {
+			case metrics.Counter:
+				c.addGauge(name, float64(m.Count()))
+			case metrics.Gauge:
+				c.addGauge(name, float64(m.Value()))
+			case metrics.GaugeFloat64:
+				c.addGauge(name, m.Value())
+			case metrics.Meter:
+				c.addCounter(name, float64(m.Snapshot().Count()))
+			case metrics.Histogram:
+				h := m.Snapshot()
+				c.addSummary(name, h.Count(), float64(h.Sum()), h.Percentiles(quantiles))
+			case metrics.Timer:
+				t := m.Snapshot()
+				c.addSummary(name, t.Count(), float64(t.Sum()), t.Percentiles(quantiles))
+			case metrics.ResettingTimer:
+				t := m.Snapshot()
+				values := t.Values()
+				if len(values) == 0 {
+					continue
+				}
+				var sum int64
+				for _, v := range values {
+					sum += v
+				}
+				// Resetting timers take percentiles instead of quantiles
+				pcts := make([]float64, len(quantiles))
+				for i, q := range quantiles {
+					pcts[i] = q * 100
+				}
+				ps := t.Percentiles(pcts)
+				fs := make([]float64, len(ps))
+				for i, p := range ps {
+					fs[i] = float64(p)
+				}
+				c.addSummary(name, int64(len(values)), float64(sum), fs)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if _, err := w.Write(c.buf.Bytes()); err != nil {
+			log.Debug("Failed to write Prometheus metrics", "err", err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+func init() {
+	metrics.Enabled = true
+}
+
+func TestHandler(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	counter := metrics.NewCounter()
+	counter.Inc(12345)
+	reg.Register("txpool/pending/discard", counter)
+
+	gauge := metrics.NewGauge()
+	gauge.Update(23456)
+	reg.Register("p2p/peers", gauge)
+
+	meter := metrics.NewMeter()
+	meter.Mark(9999)
+	reg.Register("rpc/requests", meter)
+	defer meter.Stop()
+
+	timer := metrics.NewTimer()
+	timer.Update(10 * time.Millisecond)
+	timer.Update(20 * time.Millisecond)
+	reg.Register("chain/inserts", timer)
+	defer timer.Stop()
+
+	reg.Register("rpc/idle", metrics.NewResettingTimer())
+
+	span := metrics.NewResettingTimer()
+	for i := 1; i <= 100; i++ {
+		span.Update(time.Duration(i))
+	}
+	reg.Register("rpc/span", span)
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/metrics/prometheus", nil))
+
+	have := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE txpool_pending_discard gauge\ntxpool_pending_discard 12345\n",
+		"# TYPE p2p_peers gauge\np2p_peers 23456\n",
+		"# TYPE rpc_requests counter\nrpc_requests 9999\n",
+		"# TYPE chain_inserts summary\n",
+		"chain_inserts{quantile=\"0.5\"} 1.5e+07\n",
+		"chain_inserts_sum 3e+07\n",
+		"chain_inserts_count 2\n",
+		"rpc_span{quantile=\"0.5\"} 50\n",
+		"rpc_span{quantile=\"0.99\"} 99\n",
+		"rpc_span_sum 5050\n",
+		"rpc_span_count 100\n",
+	} {
+		if !strings.Contains(have, want) {
+			t.Errorf("output missing %q:\n%s", want, have)
+		}
+	}
+	if strings.Contains(have, "rpc_idle") {
+		t.Errorf("empty resetting timer exported:\n%s", have)
+	}
+	// Metrics must be sorted by name
+	if strings.Index(have, "chain_inserts") > strings.Index(have, "txpool_pending_discard") {
+		t.Errorf("metrics not sorted:\n%s", have)
+	}
+}
@@ -49,6 +49,12 @@ func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
 	if !Enabled {
 		return NilSample{}
 	}
+	return newExpDecaySample(reservoirSize, alpha)
+}
+
+// newExpDecaySample constructs a new exponentially-decaying sample regardless
+// of whether metrics are enabled.
+func newExpDecaySample(reservoirSize int, alpha float64) *ExpDecaySample {
 	s := &ExpDecaySample{
 		alpha:         alpha,
 		reservoirSize: reservoirSize,
@@ -0,0 +1,237 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+const (
+	exportQueueSize  = 4096            // Maximum number of spans waiting for export
+	exportBatchSize  = 512             // Maximum number of spans sent in one request
+	exportInterval   = 5 * time.Second // Maximum time spans wait in the queue
+	exportTimeout    = 10 * time.Second
+	otlpTracesPath   = "/v1/traces"
+	otlpSpanInternal = 1
+	otlpSpanServer   = 2
+	otlpStatusError  = 2
+)
+
+// Setup enables tracing, exporting the recorded spans to the OTLP/HTTP endpoint
+// of an OpenTelemetry collector (e.g. http://localhost:4318). Only the given
+// ratio of new traces is recorded.
+func Setup(endpoint, service string, ratio float64) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if ratio <= 0 || ratio > 1 {
+		return fmt.Errorf("invalid sample ratio %v, want (0, 1]", ratio)
+	}
+	if !strings.HasSuffix(u.Path, otlpTracesPath) {
+		u.Path = strings.TrimRight(u.Path, "/") + otlpTracesPath
+	}
+	exp = &exporter{
+		url:     u.String(),
+		service: service,
+		queue:   make(chan *Span, exportQueueSize),
+		client:  &http.Client{Timeout: exportTimeout},
+	}
+	sampleRatio = ratio
+	Enabled = true
+
+	go exp.loop()
+	return nil
+}
+
+// exporter batches finished spans and posts them to a collector.
+type exporter struct {
+	url     string
+	service string
+	queue   chan *Span
+	client  *http.Client
+	dropped int
+}
+
+// export queues a finished span, dropping it if the collector can't keep up.
+func (e *exporter) export(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped++
+	}
+}
+
+func (e *exporter) loop() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := e.send(batch); err != nil {
+			log.Warn("Failed to export traces", "spans", len(batch), "err", err)
+		}
+		if e.dropped > 0 {
+			log.Warn("Dropped traces, collector too slow", "spans", e.dropped)
+			e.dropped = 0
+		}
+		batch = batch[:0]
+	}
+}
+
+// send posts a batch of spans to the collector, encoded as OTLP JSON.
+func (e *exporter) send(batch []*Span) error {
+	body, err := json.Marshal(encodeSpans(e.service, batch))
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// OTLP JSON encoding of the trace export request, see
+// https://github.com/open-telemetry/opentelemetry-proto.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+)
+
+func encodeSpans(service string, batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              otlpSpanInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attrs),
+		}
+		if s.parent != ([8]byte{}) {
+			spans[i].ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		if s.server {
+			spans[i].Kind = otlpSpanServer
+		}
+		if s.err != nil {
+			spans[i].Status = &otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+		}
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]interface{}{"service.name", service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "aquachain"}, Spans: spans}},
+	}}}
+}
+
+// encodeAttributes converts key/value pairs into OTLP attributes. Values which
+// aren't strings, integers, floats or booleans are formatted as strings.
+func encodeAttributes(attrs []interface{}) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		kv := otlpKeyValue{Key: fmt.Sprint(attrs[i])}
+		switch v := attrs[i+1].(type) {
+		case string:
+			kv.Value.StringValue = &v
+		case bool:
+			kv.Value.BoolValue = &v
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			s := fmt.Sprint(v)
+			kv.Value.IntValue = &s
+		case float32:
+			f := float64(v)
+			kv.Value.DoubleValue = &f
+		case float64:
+			kv.Value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			kv.Value.StringValue = &s
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing records spans of work done by the node, like block imports
+// and RPC requests, and exports them to an OpenTelemetry collector over OTLP.
+//
+// Tracing is disabled by default, in which case starting a span is a no-op
+// returning a nil span, whose methods are safe to call.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enabled is set when an exporter has been set up. Instrumented code may check
+// it to skip preparing expensive span attributes.
+var Enabled = false
+
+var (
+	exp         *exporter
+	sampleRatio = 1.0
+
+	idLock sync.Mutex
+	idRand *rand.Rand
+)
+
+func init() {
+	var seed [8]byte
+	crand.Read(seed[:])
+	idRand = rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))
+}
+
+// spanContext identifies a span within a trace.
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+type contextKey struct{}
+
+// Span is a timed operation within a trace.
+type Span struct {
+	spanContext
+	parent [8]byte
+	name   string
+	server bool
+	start  time.Time
+	end    time.Time
+	attrs  []interface{}
+	err    error
+}
+
+// StartSpan starts a new span with the given name and key/value attributes as
+// a child of the span in ctx, or as the root of a new trace. The returned
+// context carries the new span for its children.
+func StartSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	if !Enabled {
+		return ctx, nil
+	}
+	span := &Span{name: name, start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(contextKey{}).(spanContext); ok {
+		if !parent.sampled {
+			return ctx, nil
+		}
+		span.traceID, span.parent, span.sampled = parent.traceID, parent.spanID, true
+	} else {
+		span.sampled = sampleRatio >= 1 || randFloat() < sampleRatio
+		if !span.sampled {
+			// Remember the decision so that children are dropped too
+			return context.WithValue(ctx, contextKey{}, span.spanContext), nil
+		}
+		randRead(span.traceID[:])
+	}
+	randRead(span.spanID[:])
+	return context.WithValue(ctx, contextKey{}, span.spanContext), span
+}
+
+// StartServerSpan starts a span like StartSpan, marking it as the handling of a
+// request made by a remote client.
+func StartServerSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	ctx, span := StartSpan(ctx, name, attrs...)
+	if span != nil {
+		span.server = true
+	}
+	return ctx, span
+}
+
+// SetAttributes adds key/value attributes to the span.
+func (s *Span) SetAttributes(attrs ...interface{}) {
+	if s != nil {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// SetError marks the span as failed if err is not nil.
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.err = err
+	}
+}
+
+// End finishes the span and queues it for export.
+func (s *Span) End() {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	if exp != nil {
+		exp.export(s)
+	}
+}
+
+// ContextWithTraceParent returns a context continuing the trace of a remote
+// caller, as described by a W3C traceparent header. Malformed or unsupported
+// headers are ignored.
+func ContextWithTraceParent(ctx context.Context, header string) context.Context {
+	if !Enabled || header == "" {
+		return ctx
+	}
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+	var (
+		sc    spanContext
+		flags [1]byte
+	)
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == ([16]byte{}) {
+		return ctx
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == ([8]byte{}) {
+		return ctx
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return ctx
+	}
+	sc.sampled = flags[0]&0x01 != 0
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// TraceParent returns the W3C traceparent header identifying the span, for
+// propagating the trace to a remote service.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
+
+func randRead(b []byte) {
+	idLock.Lock()
+	idRand.Read(b)
+	idLock.Unlock()
+}
+
+func randFloat() float64 {
+	idLock.Lock()
+	defer idLock.Unlock()
+	return idRand.Float64()
+}
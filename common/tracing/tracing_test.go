@@ -0,0 +1,162 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStartSpanDisabled(t *testing.T) {
+	Enabled = false
+	ctx, span := StartSpan(context.Background(), "test")
+	if span != nil || ctx != context.Background() {
+		t.Fatalf("span started while disabled")
+	}
+	// Methods of nil spans must be no-ops
+	span.SetAttributes("key", "value")
+	span.SetError(errors.New("failure"))
+	span.End()
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	Enabled, sampleRatio = true, 1
+	defer func() { Enabled = false }()
+
+	ctx, root := StartServerSpan(context.Background(), "root")
+	_, child := StartSpan(ctx, "child")
+	if root.traceID != child.traceID {
+		t.Errorf("trace mismatch: root %x, child %x", root.traceID, child.traceID)
+	}
+	if child.parent != root.spanID {
+		t.Errorf("parent mismatch: have %x, want %x", child.parent, root.spanID)
+	}
+	if root.parent != ([8]byte{}) {
+		t.Errorf("root has parent %x", root.parent)
+	}
+	// Remote parents are continued from the traceparent header
+	ctx = ContextWithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := StartSpan(ctx, "remote")
+	if have := span.TraceParent()[:35]; have != "00-4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("remote trace not continued: %s", span.TraceParent())
+	}
+	if want := [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}; span.parent != want {
+		t.Errorf("remote parent mismatch: have %x, want %x", span.parent, want)
+	}
+	// Unsampled remote parents and malformed headers
+	ctx = ContextWithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if _, span := StartSpan(ctx, "unsampled"); span != nil {
+		t.Errorf("span started for unsampled remote parent")
+	}
+	if ctx := ContextWithTraceParent(context.Background(), "00-xyz-00f067aa0ba902b7-01"); ctx != context.Background() {
+		t.Errorf("malformed traceparent accepted")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	Enabled, sampleRatio = true, 0.5
+	defer func() { Enabled, sampleRatio = false, 1 }()
+
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		ctx, root := StartSpan(context.Background(), "root")
+		_, child := StartSpan(ctx, "child")
+		if (root == nil) != (child == nil) {
+			t.Fatalf("child sampling differs from root")
+		}
+		if root != nil {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Errorf("sampled %d of 1000 traces, want about 500", sampled)
+	}
+}
+
+func TestSetupValidation(t *testing.T) {
+	for _, endpoint := range []string{"", "localhost:4318", "ftp://localhost:4318"} {
+		if err := Setup(endpoint, "aquachain", 1); err == nil {
+			t.Errorf("endpoint %q accepted", endpoint)
+		}
+	}
+	for _, ratio := range []float64{0, -1, 1.5} {
+		if err := Setup("http://localhost:4318", "aquachain", ratio); err == nil {
+			t.Errorf("sample ratio %v accepted", ratio)
+		}
+	}
+	if Enabled {
+		t.Errorf("tracing enabled by invalid setup")
+	}
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan *otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("path mismatch: have %s, want /v1/traces", r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		req := new(otlpRequest)
+		if err := json.Unmarshal(body, req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		requests <- req
+	}))
+	defer server.Close()
+
+	exp = &exporter{
+		url:     server.URL + otlpTracesPath,
+		service: "aquachain-test",
+		queue:   make(chan *Span, 2),
+		client:  http.DefaultClient,
+	}
+	Enabled, sampleRatio = true, 1
+	defer func() { Enabled, exp = false, nil }()
+
+	ctx, root := StartServerSpan(context.Background(), "rpc.aqua_getLogs", "fromBlock", 10)
+	_, child := StartSpan(ctx, "filter.unindexed")
+	child.SetError(errors.New("failure"))
+	child.End()
+	root.End()
+
+	exp.send([]*Span{<-exp.queue, <-exp.queue})
+	var req *otlpRequest
+	select {
+	case req = <-requests:
+	case <-time.After(time.Second):
+		t.Fatalf("no spans exported")
+	}
+	if have := *req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue; have != "aquachain-test" {
+		t.Errorf("service name mismatch: have %s", have)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("span count mismatch: have %d, want 2", len(spans))
+	}
+	if spans[0].Name != "filter.unindexed" || spans[0].ParentSpanID != spans[1].SpanID || spans[0].Status == nil {
+		t.Errorf("child span mismatch: %+v", spans[0])
+	}
+	if spans[1].Kind != otlpSpanServer || *spans[1].Attributes[0].Value.IntValue != "10" {
+		t.Errorf("root span mismatch: %+v", spans[1])
+	}
+}
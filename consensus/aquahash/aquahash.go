@@ -29,10 +29,12 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	mmap "github.com/edsrzf/mmap-go"
+	golru "github.com/hashicorp/golang-lru"
 	"github.com/hashicorp/golang-lru/simplelru"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/common/metrics"
@@ -401,9 +403,14 @@ type Aquahash struct {
 	// Mining related fields
 	rand     *rand.Rand    // Properly seeded random source for nonces
 	threads  int           // Number of threads to mine on if mining
+	throttle int32         // Percentage of time the threads spend hashing (0 = all), accessed atomically
+	nice     int           // Scheduling priority of the threads (0 = unchanged)
+	affinity []int         // CPUs the threads are pinned to, round robin (empty = any)
 	update   chan struct{} // Notification channel to update mining parameters
 	hashrate metrics.Meter // Meter tracking the average hashrate
 
+	verified *golru.Cache // Seals of recently verified headers, keyed by sealKey
+
 	// The fields below are hooks for testing
 	shared    *Aquahash     // Shared PoW verifier to avoid cache regeneration
 	fakeFail  uint64        // Block number which fails PoW check even in fake mode
@@ -415,11 +422,13 @@ type Aquahash struct {
 // New creates a full sized aquahash PoW scheme.
 func New(config Config) *Aquahash {
 	log.Info("Starting new Aquahash engine", "startVersion", config.StartVersion)
+	verified, _ := golru.New(verifiedSeals)
 	if config.StartVersion > 1 {
 		return &Aquahash{
 			config:   config,
 			update:   make(chan struct{}),
 			hashrate: metrics.NewMeter(),
+			verified: verified,
 		}
 	}
 	if config.CachesInMem <= 0 {
@@ -438,6 +447,7 @@ func New(config Config) *Aquahash {
 		datasets: newlru("dataset", config.DatasetsInMem, newDataset),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeter(),
+		verified: verified,
 	}
 }
 
@@ -565,6 +575,66 @@ func (aquahash *Aquahash) SetThreads(threads int) {
 	}
 	// Update the threads and ping any running seal to pull in any changes
 	aquahash.threads = threads
+	aquahash.notifyUpdate()
+}
+
+// Throttle returns the percentage of time the mining threads spend hashing, 0
+// meaning they are not throttled.
+func (aquahash *Aquahash) Throttle() int {
+	if aquahash.shared != nil {
+		return aquahash.shared.Throttle()
+	}
+	return int(atomic.LoadInt32(&aquahash.throttle))
+}
+
+// SetThrottle limits the mining threads to hashing the given percentage of the
+// time, sleeping in between, so mining leaves room for other work on the same
+// machine. Zero or 100 lifts the throttle. Running threads pick up the change
+// right away.
+func (aquahash *Aquahash) SetThrottle(percent int) {
+	if aquahash.shared != nil {
+		aquahash.shared.SetThrottle(percent)
+		return
+	}
+	if percent < 0 || percent >= 100 {
+		percent = 0
+	}
+	atomic.StoreInt32(&aquahash.throttle, int32(percent))
+}
+
+// SetNice sets the scheduling priority (nice level) of the mining threads,
+// restarting any running seal to apply it. Zero leaves the priority of the
+// threads unchanged.
+func (aquahash *Aquahash) SetNice(nice int) {
+	aquahash.lock.Lock()
+	defer aquahash.lock.Unlock()
+
+	if aquahash.shared != nil {
+		aquahash.shared.SetNice(nice)
+		return
+	}
+	aquahash.nice = nice
+	aquahash.notifyUpdate()
+}
+
+// SetAffinity pins the mining threads to the given CPUs, assigned round robin,
+// restarting any running seal to apply it. An empty list lets the threads run
+// on any CPU.
+func (aquahash *Aquahash) SetAffinity(cpus []int) {
+	aquahash.lock.Lock()
+	defer aquahash.lock.Unlock()
+
+	if aquahash.shared != nil {
+		aquahash.shared.SetAffinity(cpus)
+		return
+	}
+	aquahash.affinity = append([]int(nil), cpus...)
+	aquahash.notifyUpdate()
+}
+
+// notifyUpdate pings any running seal to pull in changed mining parameters. The
+// caller must hold aquahash.lock.
+func (aquahash *Aquahash) notifyUpdate() {
 	select {
 	case aquahash.update <- struct{}{}:
 	default:
@@ -44,6 +44,61 @@ func TestTestMode(t *testing.T) {
 	}
 }
 
+// Tests that verified seals are remembered, without accepting tampered ones.
+func TestVerifiedSealCache(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	head.Version = types.H_KECCAK256
+	aquahash := NewTester()
+	block, err := aquahash.Seal(nil, types.NewBlockWithHeader(head), nil)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	head.Nonce = types.EncodeNonce(block.Nonce())
+	head.MixDigest = block.MixDigest()
+	for i := 0; i < 2; i++ {
+		if err := aquahash.VerifySeal(nil, head); err != nil {
+			t.Fatalf("verification %d: unexpected error: %v", i, err)
+		}
+		if aquahash.verified.Len() != 1 {
+			t.Fatalf("verification %d: cached seals mismatch: have %d, want 1", i, aquahash.verified.Len())
+		}
+	}
+	head.MixDigest[0]++
+	if err := aquahash.VerifySeal(nil, head); err != errInvalidMixDigest {
+		t.Fatalf("tampered seal error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+	if aquahash.verified.Len() != 1 {
+		t.Fatalf("tampered seal cached")
+	}
+}
+
+// Tests that throttled mining threads with a changed priority and affinity
+// still seal blocks.
+func TestThrottledSeal(t *testing.T) {
+	aquahash := NewTester()
+	aquahash.SetThreads(2)
+	aquahash.SetNice(1)
+	aquahash.SetAffinity([]int{0})
+
+	if aquahash.SetThrottle(150); aquahash.Throttle() != 0 {
+		t.Fatalf("throttle above 100%% not lifted: %d", aquahash.Throttle())
+	}
+	if aquahash.SetThrottle(50); aquahash.Throttle() != 50 {
+		t.Fatalf("throttle mismatch: have %d, want 50", aquahash.Throttle())
+	}
+	head := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	head.Version = types.H_KECCAK256
+	block, err := aquahash.Seal(nil, types.NewBlockWithHeader(head), nil)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	head.Nonce = types.EncodeNonce(block.Nonce())
+	head.MixDigest = block.MixDigest()
+	if err := aquahash.VerifySeal(nil, head); err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+}
+
 // This test checks that cache lru logic doesn't crash under load.
 // It reproduces https://gitlab.com/aquachain/aquachain/issues/14943
 func TestCacheFileEvict(t *testing.T) {
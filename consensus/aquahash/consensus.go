@@ -43,8 +43,19 @@ var (
 	maxUncles              = 2                // Maximum number of uncles allowed in a single block
 	maxUnclesHF5           = 1                // Maximum number of uncles allowed in a single block after HF5 is activated
 	allowedFutureBlockTime = 15 * time.Second // Max time from current time allowed for blocks, before they're considered future blocks
+	verifiedSeals          = 8192             // Number of verified seals to remember
 )
 
+// sealKey identifies a header seal in the cache of verified ones. The hash
+// without nonce is used since it's cheap to compute, unlike the argon2id hash
+// of the full header.
+type sealKey struct {
+	hash    common.Hash
+	nonce   types.BlockNonce
+	mix     common.Hash
+	version types.HeaderVersion
+}
+
 // Various error messages to mark blocks invalid. These should be private to
 // prevent engine specific errors from being referenced in the remainder of the
 // codebase, inherently breaking if the engine is swapped out. Please put common
@@ -399,32 +410,38 @@ func (aquahash *Aquahash) VerifySeal(chain consensus.ChainReader, header *types.
 		return errInvalidDifficulty
 	}
 
-	// Recompute the digest and PoW value and verify against the header
-	cache := aquahash.cache(number)
-	size := datasetSize(number)
-	if aquahash.config.PowMode == ModeTest {
-		size = 32 * 1024
+	// Headers are verified repeatedly during sync and import, skip known seals
+	if header.Version == types.H_UNSET {
+		panic("header version not set")
+	}
+	key := sealKey{header.HashNoNonce(), header.Nonce, header.MixDigest, header.Version}
+	if aquahash.verified != nil && aquahash.verified.Contains(key) {
+		return nil
 	}
+	// Recompute the digest and PoW value and verify against the header
 	var (
 		digest []byte
 		result []byte
 	)
 	switch header.Version {
-	case types.H_UNSET: // 0
-		panic("header version not set")
 	case types.H_KECCAK256: // 1
-		digest, result = hashimotoLight(size, cache.cache, header.HashNoNonce().Bytes(), header.Nonce.Uint64())
+		cache := aquahash.cache(number)
+		size := datasetSize(number)
+		if aquahash.config.PowMode == ModeTest {
+			size = 32 * 1024
+		}
+		digest, result = hashimotoLight(size, cache.cache, key.hash.Bytes(), header.Nonce.Uint64())
+
+		// Caches are unmapped in a finalizer. Ensure that the cache stays live
+		// until after the call to hashimotoLight so it's not unmapped while being used.
+		runtime.KeepAlive(cache)
 	default:
 		seed := make([]byte, 40)
-		copy(seed, header.HashNoNonce().Bytes())
+		copy(seed, key.hash.Bytes())
 		binary.LittleEndian.PutUint64(seed[32:], header.Nonce.Uint64())
 		result = crypto.VersionHash(byte(header.Version), seed)
 		digest = make([]byte, common.HashLength)
 	}
-	// Caches are unmapped in a finalizer. Ensure that the cache stays live
-	// until after the call to hashimotoLight so it's not unmapped while being used.
-	runtime.KeepAlive(cache)
-
 	if !bytes.Equal(header.MixDigest[:], digest) {
 		//fmt.Printf("Invalid Digest (%v):\n%x (!=) %x\n", header.Number.Uint64(), header.MixDigest[:], digest)
 		return errInvalidMixDigest
@@ -433,6 +450,9 @@ func (aquahash *Aquahash) VerifySeal(chain consensus.ChainReader, header *types.
 	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
 		return errInvalidPoW
 	}
+	if aquahash.verified != nil {
+		aquahash.verified.Add(key, struct{}{})
+	}
 	return nil
 }
 
@@ -24,6 +24,8 @@ import (
 	"math/rand"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
@@ -33,6 +35,10 @@ import (
 	"gitlab.com/aquachain/aquachain/params"
 )
 
+// throttleRounds is the number of nonces tried between the rests of throttled
+// mining threads.
+const throttleRounds = 64
+
 // Seal implements consensus.Engine, attempting to find a nonce that satisfies
 // the block's difficulty requirements.
 func (aquahash *Aquahash) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
@@ -58,7 +64,7 @@ func (aquahash *Aquahash) Seal(chain consensus.ChainReader, block *types.Block,
 	found := make(chan *types.Block)
 
 	aquahash.lock.Lock()
-	threads := aquahash.threads
+	threads, nice, affinity := aquahash.threads, aquahash.nice, aquahash.affinity
 	if aquahash.rand == nil {
 		seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64))
 		if err != nil {
@@ -80,6 +86,19 @@ func (aquahash *Aquahash) Seal(chain consensus.ChainReader, block *types.Block,
 		pend.Add(1)
 		go func(id int, nonce uint64) {
 			defer pend.Done()
+			if nice != 0 || len(affinity) > 0 {
+				// The thread is never unlocked, so it's discarded along with its
+				// changed priority and affinity once the search terminates
+				runtime.LockOSThread()
+
+				cpu := -1
+				if len(affinity) > 0 {
+					cpu = affinity[id%len(affinity)]
+				}
+				if err := setThreadPolicy(nice, cpu); err != nil {
+					log.Warn("Failed to apply mining thread policy", "miner", id, "err", err)
+				}
+			}
 			log.Trace("launching miner")
 			aquahash.mine(version, block, id, nonce, abort, found)
 		}(i, uint64(aquahash.rand.Int63()))
@@ -128,6 +147,7 @@ func (aquahash *Aquahash) mine(version params.HeaderVersion, block *types.Block,
 	var (
 		attempts = int64(0)
 		nonce    = seed
+		busy     = time.Now() // Start of the current hashing period when throttled
 	)
 	logger := log.New("miner", id)
 	logger.Trace("Started aquahash search for new nonces", "seed", seed, "algo", version)
@@ -148,6 +168,18 @@ search:
 				aquahash.hashrate.Mark(attempts)
 				attempts = 0
 			}
+			// Rest in proportion to the time spent hashing if throttled
+			if (attempts % throttleRounds) == 0 {
+				if throttle := time.Duration(atomic.LoadInt32(&aquahash.throttle)); throttle > 0 {
+					rest := time.NewTimer(time.Since(busy) * (100 - throttle) / throttle)
+					select {
+					case <-abort:
+					case <-rest.C:
+					}
+					rest.Stop()
+				}
+				busy = time.Now()
+			}
 
 			// Compute the PoW value of this nonce
 			var (
@@ -0,0 +1,42 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquahash
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setThreadPolicy applies the scheduling priority and the CPU affinity to the
+// calling OS thread. A cpu below zero leaves the affinity unchanged.
+func setThreadPolicy(nice int, cpu int) error {
+	if cpu >= 0 {
+		var set unix.CPUSet
+		set.Set(cpu)
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("failed to pin thread to cpu %d: %v", cpu, err)
+		}
+	}
+	if nice != 0 {
+		// Scheduling priorities are per thread on Linux
+		if err := unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), nice); err != nil {
+			return fmt.Errorf("failed to set thread priority %d: %v", nice, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build !linux
+
+package aquahash
+
+import "errors"
+
+// setThreadPolicy is unsupported on this platform.
+func setThreadPolicy(nice int, cpu int) error {
+	if nice != 0 || cpu >= 0 {
+		return errors.New("thread priority and affinity not supported on this platform")
+	}
+	return nil
+}
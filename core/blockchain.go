@@ -18,6 +18,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -36,8 +37,10 @@ import (
 	"gitlab.com/aquachain/aquachain/common/mclock"
 	"gitlab.com/aquachain/aquachain/common/metrics"
 	"gitlab.com/aquachain/aquachain/common/prque"
+	"gitlab.com/aquachain/aquachain/common/tracing"
 	"gitlab.com/aquachain/aquachain/consensus"
 	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/state/snapshot"
 	"gitlab.com/aquachain/aquachain/core/types"
 	"gitlab.com/aquachain/aquachain/core/vm"
 	"gitlab.com/aquachain/aquachain/crypto"
@@ -55,6 +58,7 @@ var (
 const (
 	bodyCacheLimit      = 256
 	blockCacheLimit     = 256
+	receiptsCacheLimit  = 32
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
@@ -70,6 +74,15 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TriesInMemory uint64        // Number of recent block states to keep before pruning, 0 for the default of 128
+	Snapshot      bool          // Whether to keep a flat snapshot of the head state to speed up state reads
+
+	BodyCacheLimit     int // Number of block bodies to keep in memory, 0 for the default
+	BlockCacheLimit    int // Number of entire blocks to keep in memory, 0 for the default
+	ReceiptsCacheLimit int // Number of block receipt lists to keep in memory, 0 for the default
+	HeaderCacheLimit   int // Number of headers to keep in memory, 0 for the default
+
+	TxLookupLimit uint64 // Number of recent blocks to keep transaction lookups for, 0 for all
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -96,6 +109,8 @@ type BlockChain struct {
 
 	hc            *HeaderChain
 	rmLogsFeed    event.Feed
+	reorgFeed     event.Feed
+	rewindFeed    event.Feed
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
@@ -110,11 +125,18 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
-	stateCache   state.Database // State database to reuse between imports (contains state cache)
-	bodyCache    *lru.Cache     // Cache for the most recent block bodies
-	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
-	blockCache   *lru.Cache     // Cache for the most recent entire blocks
-	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	stateCache    state.Database     // State database to reuse between imports (contains state cache)
+	snaps         *snapshot.Snapshot // Flat snapshot of the head state, nil if disabled
+	bodyCache     *chainCache        // Cache for the most recent block bodies
+	bodyRLPCache  *chainCache        // Cache for the most recent block bodies in RLP encoded format
+	receiptsCache *chainCache        // Cache for the most recent block receipts
+	blockCache    *chainCache        // Cache for the most recent entire blocks
+	futureBlocks  *lru.Cache         // future blocks are blocks added for later processing
+
+	stats *chainStats // Uncle, reorg and propagation statistics
+
+	txIndexCh   chan struct{} // Notifies the transaction indexer of new heads, nil if not pruning
+	txIndexHead uint64        // Latest canonical head for the transaction indexer (atomic)
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -122,10 +144,11 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine    consensus.Engine
-	processor Processor // block processor interface
-	validator Validator // block and state validator interface
-	vmConfig  vm.Config
+	engine     consensus.Engine
+	processor  Processor        // block processor interface
+	prefetcher *statePrefetcher // warms the state caches for the next block of an import
+	validator  Validator        // block and state validator interface
+	vmConfig   vm.Config
 
 	badBlocks *lru.Cache // Bad block cache
 }
@@ -143,35 +166,48 @@ func NewBlockChain(db aquadb.Database, cacheConfig *CacheConfig, chainConfig *pa
 	if chainConfig == nil {
 		return nil, fmt.Errorf("nil config")
 	}
-	bodyCache, _ := lru.New(bodyCacheLimit)
-	bodyRLPCache, _ := lru.New(bodyCacheLimit)
-	blockCache, _ := lru.New(blockCacheLimit)
+	bodyLimit, blockLimit, receiptsLimit := bodyCacheLimit, blockCacheLimit, receiptsCacheLimit
+	if cacheConfig.BodyCacheLimit > 0 {
+		bodyLimit = cacheConfig.BodyCacheLimit
+	}
+	if cacheConfig.BlockCacheLimit > 0 {
+		blockLimit = cacheConfig.BlockCacheLimit
+	}
+	if cacheConfig.ReceiptsCacheLimit > 0 {
+		receiptsLimit = cacheConfig.ReceiptsCacheLimit
+	}
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
-		chainConfig:  chainConfig,
-		cacheConfig:  cacheConfig,
-		db:           db,
-		triegc:       prque.New(nil),
-		stateCache:   state.NewDatabase(db),
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
-		engine:       engine,
-		vmConfig:     vmConfig,
-		badBlocks:    badBlocks,
+		chainConfig:   chainConfig,
+		cacheConfig:   cacheConfig,
+		db:            db,
+		triegc:        prque.New(nil),
+		stateCache:    state.NewDatabase(db),
+		quit:          make(chan struct{}),
+		bodyCache:     newChainCache("bodies", bodyLimit),
+		bodyRLPCache:  newChainCache("bodiesrlp", bodyLimit),
+		receiptsCache: newChainCache("receipts", receiptsLimit),
+		blockCache:    newChainCache("blocks", blockLimit),
+		futureBlocks:  futureBlocks,
+		stats:         newChainStats(),
+		engine:        engine,
+		vmConfig:      vmConfig,
+		badBlocks:     badBlocks,
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
+	bc.prefetcher = newStatePrefetcher(chainConfig, bc)
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.getProcInterrupt)
 	if err != nil {
 		return nil, err
 	}
+	if cacheConfig.HeaderCacheLimit > 0 {
+		bc.hc.headerCache.Resize(cacheConfig.HeaderCacheLimit)
+	}
 	bc.genesisBlock = bc.GetBlockByNumber(0)
 	if bc.genesisBlock == nil {
 		return nil, ErrNoGenesis
@@ -179,6 +215,13 @@ func NewBlockChain(db aquadb.Database, cacheConfig *CacheConfig, chainConfig *pa
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if cacheConfig.Snapshot {
+		if bc.snaps, err = snapshot.New(db, bc.stateCache, bc.CurrentBlock().Header()); err != nil {
+			log.Warn("State snapshot disabled", "err", err)
+		} else {
+			state.SetFlatState(bc.stateCache, bc.snaps)
+		}
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -192,6 +235,15 @@ func NewBlockChain(db aquadb.Database, cacheConfig *CacheConfig, chainConfig *pa
 			}
 		}
 	}
+	// Prune the transaction index if limited, or warn if it's incomplete
+	if cacheConfig.TxLookupLimit > 0 {
+		bc.txIndexCh = make(chan struct{}, 1)
+		bc.wg.Add(1)
+		go bc.txIndexLoop()
+		bc.indexTxs(bc.CurrentBlock().NumberU64())
+	} else if tail, ok := GetTxIndexTail(db); ok {
+		log.Warn("Transaction index incomplete, run 'aquachain db reindex-txs' to rebuild it", "tail", tail)
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -245,6 +297,7 @@ func (bc *BlockChain) loadLastState() error {
 			bc.currentFastBlock.Store(block)
 		}
 	}
+	bc.updateSnapshot()
 
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
@@ -269,9 +322,9 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
-	// Rewind the header chain, deleting all block bodies until then
+	// Rewind the header chain, deleting all block data until then
 	delFn := func(hash common.Hash, num uint64) {
-		DeleteBody(bc.db, hash, num)
+		deleteBlockData(bc.db, bc.db, hash, num)
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
@@ -279,6 +332,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
+	bc.receiptsCache.Purge()
 	bc.blockCache.Purge()
 	bc.futureBlocks.Purge()
 
@@ -286,11 +340,18 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if currentBlock := bc.CurrentBlock(); currentBlock != nil && currentHeader.Number.Uint64() < currentBlock.NumberU64() {
 		bc.currentBlock.Store(bc.GetBlock(currentHeader.Hash(), currentHeader.Number.Uint64()))
 	}
-	if currentBlock := bc.CurrentBlock(); currentBlock != nil {
-		if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
-			// Rewound state missing, rolled back to before pivot, reset to genesis
-			bc.currentBlock.Store(bc.genesisBlock)
+	if currentBlock := bc.CurrentBlock(); currentBlock != nil && !bc.HasState(currentBlock.Root()) {
+		// Rewound state missing, fall back to the newest block with its state
+		// available, or to genesis if rolled back to before the pivot
+		block := currentBlock
+		for block != nil && block.NumberU64() > 0 && !bc.HasState(block.Root()) {
+			block = bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
 		}
+		if block == nil || block.NumberU64() == 0 {
+			block = bc.genesisBlock
+		}
+		log.Warn("Rewound head state missing", "number", currentBlock.NumberU64(), "fallback", block.NumberU64())
+		bc.currentBlock.Store(block)
 	}
 	// Rewind the fast block in a simpleton way to the target head
 	if currentFastBlock := bc.CurrentFastBlock(); currentFastBlock != nil && currentHeader.Number.Uint64() < currentFastBlock.NumberU64() {
@@ -311,7 +372,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	go bc.rewindFeed.Send(ChainRewindEvent{Block: bc.CurrentBlock()})
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -328,6 +393,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock.Store(block)
+	bc.updateSnapshot()
 	bc.mu.Unlock()
 
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
@@ -456,6 +522,10 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	}
 	log.Info("Exporting batch of blocks", "count", last-first+1)
 
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
 	for nr := first; nr <= last; nr++ {
 		block := bc.GetBlockByNumber(nr)
 		if block == nil {
@@ -465,6 +535,10 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 		if err := block.EncodeRLP(w); err != nil {
 			return err
 		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting blocks", "exported", nr-first+1, "number", nr, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
 	}
 
 	return nil
@@ -488,6 +562,9 @@ func (bc *BlockChain) insert(block *types.Block) {
 		log.Crit("Failed to insert head block hash", "err", err)
 	}
 	bc.currentBlock.Store(block)
+	bc.updateSnapshot()
+	bc.indexTxs(block.NumberU64())
+	bc.stats.canonical(block)
 
 	// If the block is better than our head or is on a different chain, force update heads
 	if updateHeads {
@@ -612,7 +689,15 @@ func (bc *BlockChain) GetBlockByNumber(number uint64) *types.Block {
 
 // GetReceiptsByHash retrieves the receipts for all transactions in a given block.
 func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
-	return GetBlockReceipts(bc.db, hash, GetBlockNumber(bc.db, hash))
+	if cached, ok := bc.receiptsCache.Get(hash); ok {
+		return cached.(types.Receipts)
+	}
+	receipts := GetBlockReceipts(bc.db, hash, bc.hc.GetBlockNumber(hash))
+	if receipts == nil {
+		return nil
+	}
+	bc.receiptsCache.Add(hash, receipts)
+	return receipts
 }
 
 // GetBlocksFromHash returns the block corresponding to hash and up to n-1 ancestors.
@@ -661,6 +746,10 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Stop generating the state snapshot before the tries are released
+	if bc.snaps != nil {
+		bc.snaps.Close()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -669,7 +758,7 @@ func (bc *BlockChain) Stop() {
 	if !bc.cacheConfig.Disabled {
 		triedb := bc.stateCache.TrieDB()
 
-		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
+		for _, offset := range []uint64{0, 1, bc.recentTries() - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				//fmt.Printf("number: %v\n", number-offset)
 				recent := bc.GetBlockByNumber(number - offset)
@@ -692,6 +781,14 @@ func (bc *BlockChain) Stop() {
 	log.Info("Blockchain manager stopped")
 }
 
+// recentTries returns the number of recent block states kept before pruning.
+func (bc *BlockChain) recentTries() uint64 {
+	if bc.cacheConfig.TriesInMemory > 0 {
+		return bc.cacheConfig.TriesInMemory
+	}
+	return triesInMemory
+}
+
 func (bc *BlockChain) procFutureBlocks() {
 	blocks := make([]*types.Block, 0, bc.futureBlocks.Len())
 	for _, hash := range bc.futureBlocks.Keys() {
@@ -742,6 +839,16 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 			WriteHeadBlockHash(bc.db, newBlock.Hash())
 		}
 	}
+	bc.updateSnapshot()
+}
+
+// updateSnapshot moves the state snapshot, if any, to the current head block.
+//
+// Note, this function assumes that the `mu` mutex is held!
+func (bc *BlockChain) updateSnapshot() {
+	if bc.snaps != nil {
+		bc.snaps.Update(bc.CurrentBlock().Header(), bc)
+	}
 }
 
 // SetReceiptsData computes all the non-consensus fields of the receipts
@@ -920,6 +1027,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return NonStatTy, err
 	}
+	if diff := state.Diff(); bc.snaps != nil && diff != nil {
+		bc.snaps.Record(block.Header(), diff)
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -932,9 +1042,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
 		bc.triegc.Push(root, -int64(block.NumberU64()))
 
-		if current := block.NumberU64(); current > triesInMemory {
+		if current, tries := block.NumberU64(), bc.recentTries(); current > tries {
 			// Find the next state trie we need to commit
-			header := bc.GetHeaderByNumber(current - triesInMemory)
+			header := bc.GetHeaderByNumber(current - tries)
 			chosen := header.Number.Uint64()
 			// Only write to disk if we exceeded our memory allowance *and* also have at
 			// least a given number of tries gapped.
@@ -945,16 +1055,16 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 			if size > limit || bc.gcproc > bc.cacheConfig.TrieTimeLimit {
 				// If we're exceeding limits but haven't reached a large enough memory gap,
 				// warn the user that the system is becoming unstable.
-				if chosen < lastWrite+triesInMemory {
+				if chosen < lastWrite+tries {
 					switch {
 					case size >= 2*limit:
-						log.Warn("State memory usage too high, committing", "size", size, "limit", limit, "optimum", float64(chosen-lastWrite)/triesInMemory)
+						log.Warn("State memory usage too high, committing", "size", size, "limit", limit, "optimum", float64(chosen-lastWrite)/float64(tries))
 					case bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit:
-						log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-lastWrite)/triesInMemory)
+						log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-lastWrite)/float64(tries))
 					}
 				}
 				// If optimum or critical limits reached, write to disk
-				if chosen >= lastWrite+triesInMemory || size >= 2*limit || bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit {
+				if chosen >= lastWrite+tries || size >= 2*limit || bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit {
 					triedb.Commit(header.Root, true)
 					lastWrite = chosen
 					bc.gcproc = 0
@@ -1021,7 +1131,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 //
 // After insertion is done, all accumulated events will be fired.
 func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
-	n, events, logs, err := bc.insertChain(chain)
+	ctx, span := tracing.StartSpan(context.Background(), "chain.insert", "blocks", len(chain))
+	if len(chain) > 0 {
+		span.SetAttributes("first", chain[0].NumberU64(), "last", chain[len(chain)-1].NumberU64())
+	}
+	n, events, logs, err := bc.insertChain(ctx, chain)
+	span.SetError(err)
+	span.End()
+
 	bc.PostChainEvents(events, logs)
 	return n, err
 }
@@ -1029,7 +1146,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // insertChain will execute the actual chain insertion and event aggregation. The
 // only reason this method exists as a separate one is to make locking cleaner
 // with deferred statements.
-func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*types.Log, error) {
+func (bc *BlockChain) insertChain(ctx context.Context, chain types.Blocks) (int, []interface{}, []*types.Log, error) {
 	if len(chain) == 0 {
 		return 0, nil, nil, fmt.Errorf("no chain to insert")
 	}
@@ -1051,7 +1168,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 				chain[i-1].Hash().Bytes()[:4], i, chain[i].NumberU64(), chain[i].Hash().Bytes()[:4], chain[i].ParentHash().Bytes()[:4])
 		}
 	}
-	// Pre-checks passed, start the full block imports
+	// Pre-checks passed, recover the senders in the background and start the full block imports
+	senderCacher.recoverFromBlocks(bc.chainConfig, chain)
+
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
@@ -1098,12 +1217,18 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		}
 		// Wait for the block's verification to complete
 		bstart := time.Now()
+		_, span := tracing.StartSpan(ctx, "chain.verify", "number", block.NumberU64(), "hash", block.Hash())
 
 		err := <-results
 		if err == nil {
 			block.Hash()
 			err = bc.Validator().ValidateBody(block)
 		}
+		if err != ErrKnownBlock {
+			span.SetError(err)
+		}
+		span.End()
+
 		switch {
 		case err == ErrKnownBlock:
 			// Block and state both already known. However if the current block is below
@@ -1154,7 +1279,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			}
 			// Import all the pruned blocks to make the state available
 			bc.chainmu.Unlock()
-			_, evs, logs, err := bc.insertChain(winner)
+			_, evs, logs, err := bc.insertChain(ctx, winner)
 			bc.chainmu.Lock()
 			events, coalescedLogs = evs, logs
 
@@ -1178,14 +1303,29 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		// If we have a followup block, run that against the current state to pre-cache
+		// transactions and probabilistic state
+		var followupInterrupt uint32
+		if i+1 < len(chain) {
+			throwaway := state.Copy()
+			go bc.prefetcher.Prefetch(chain[i+1], throwaway, &followupInterrupt)
+		}
 		// Process block using the parent state as reference point.
+		_, span = tracing.StartSpan(ctx, "chain.process", "number", block.NumberU64(), "txs", len(block.Transactions()))
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
+		atomic.StoreUint32(&followupInterrupt, 1)
+		span.SetAttributes("gas", usedGas)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
 		// Validate the state using the default validator
+		_, span = tracing.StartSpan(ctx, "chain.validate", "number", block.NumberU64())
 		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -1193,10 +1333,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		proctime := time.Since(bstart)
 
 		// Write the block to the chain and get the status.
+		_, span = tracing.StartSpan(ctx, "chain.write", "number", block.NumberU64())
 		status, err := bc.WriteBlockWithState(block, receipts, state)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		bc.stats.arrived(block, bstart)
+
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", block.Number(), "hash", block.Hash(), "uncles", len(block.Uncles()),
@@ -1383,6 +1528,31 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	if len(deletedLogs) > 0 {
 		go bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
 	}
+	if len(oldChain) > 0 {
+		bc.stats.reorg(uint64(len(oldChain)))
+
+		ev := ReorgEvent{
+			CommonHash:   commonBlock.Hash(),
+			CommonNumber: commonBlock.NumberU64(),
+			Dropped:      make([]common.Hash, 0, len(oldChain)),
+			Added:        make([]common.Hash, 0, len(newChain)),
+			DroppedTxs:   make([]common.Hash, 0, len(diff)),
+			AddedTxs:     make([]common.Hash, 0, len(addedTxs)),
+		}
+		for i := len(oldChain) - 1; i >= 0; i-- {
+			ev.Dropped = append(ev.Dropped, oldChain[i].Hash())
+		}
+		for i := len(newChain) - 1; i >= 0; i-- {
+			ev.Added = append(ev.Added, newChain[i].Hash())
+		}
+		for _, tx := range diff {
+			ev.DroppedTxs = append(ev.DroppedTxs, tx.Hash())
+		}
+		for _, tx := range types.TxDifference(addedTxs, deletedTxs) {
+			ev.AddedTxs = append(ev.AddedTxs, tx.Hash())
+		}
+		go bc.reorgFeed.Send(ev)
+	}
 	if len(oldChain) > 0 {
 		go func() {
 			for _, block := range oldChain {
@@ -1429,6 +1599,54 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// ReloadHead re-reads the head block marker from the database, adopting a head
+// written by another process sharing the chain database, or restored by a test
+// harness rolling the database back. It never imports or rewinds blocks itself,
+// so it is only meant for chains not importing blocks on their own.
+func (bc *BlockChain) ReloadHead() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	hash := GetHeadBlockHash(bc.db)
+	if hash == (common.Hash{}) || hash == bc.CurrentBlock().Hash() {
+		return nil
+	}
+	block := bc.GetBlockByHash(hash)
+	if block == nil {
+		return fmt.Errorf("head block %x missing", hash)
+	}
+	bc.currentBlock.Store(block)
+	bc.currentFastBlock.Store(block)
+	bc.hc.SetCurrentHeader(block.Header())
+
+	log.Debug("Reloaded chain head", "number", block.Number(), "hash", hash)
+	bc.chainFeed.Send(ChainEvent{Block: block, Hash: hash})
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+	return nil
+}
+
+// FollowHead periodically reloads the head block until the chain is stopped,
+// see ReloadHead.
+func (bc *BlockChain) FollowHead(interval time.Duration) {
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := bc.ReloadHead(); err != nil {
+					log.Warn("Failed to reload chain head", "err", err)
+				}
+			case <-bc.quit:
+				return
+			}
+		}
+	}()
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash   common.Hash   `json:"hash"`
@@ -1575,6 +1793,16 @@ func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) even
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
 }
 
+// SubscribeReorgEvent registers a subscription of ReorgEvent.
+func (bc *BlockChain) SubscribeReorgEvent(ch chan<- ReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
+// SubscribeChainRewindEvent registers a subscription of ChainRewindEvent.
+func (bc *BlockChain) SubscribeChainRewindEvent(ch chan<- ChainRewindEvent) event.Subscription {
+	return bc.scope.Track(bc.rewindFeed.Subscribe(ch))
+}
+
 // SubscribeChainEvent registers a subscription of ChainEvent.
 func (bc *BlockChain) SubscribeChainEvent(ch chan<- ChainEvent) event.Subscription {
 	return bc.scope.Track(bc.chainFeed.Subscribe(ch))
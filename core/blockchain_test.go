@@ -19,6 +19,7 @@ package core
 import (
 	"math/big"
 	"math/rand"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -907,6 +908,63 @@ func TestLogReorgs(t *testing.T) {
 	}
 }
 
+// Tests that reorgs are announced with the blocks and transactions they drop and
+// add.
+func TestReorgEvent(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		db      = aquadb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+		tx      *types.Transaction
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, aquahash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	reorgCh := make(chan ReorgEvent, 1)
+	blockchain.SubscribeReorgEvent(reorgCh)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, aquahash.NewFaker(), db, 2, func(i int, gen *BlockGen) {
+		if i == 1 {
+			tx, _ = types.SignTx(types.NewTransaction(gen.TxNonce(addr1), common.Address{1}, big.NewInt(1), params.TxGas, nil, nil), signer, key1)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	forks, _ := GenerateChain(gspec.Config, chain[0], aquahash.NewFaker(), db, 2, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{2})
+	})
+	if _, err := blockchain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert forked chain: %v", err)
+	}
+	select {
+	case ev := <-reorgCh:
+		// The fork takes over with whichever of its blocks outweighs the chain
+		want := ReorgEvent{
+			CommonHash:   chain[0].Hash(),
+			CommonNumber: 1,
+			Dropped:      []common.Hash{chain[1].Hash()},
+			Added:        []common.Hash{forks[0].Hash(), forks[1].Hash()}[:len(ev.Added)],
+			DroppedTxs:   []common.Hash{tx.Hash()},
+			AddedTxs:     []common.Hash{},
+		}
+		if len(ev.Added) == 0 || !reflect.DeepEqual(ev, want) {
+			t.Errorf("reorg event mismatch:\nhave %+v\nwant %+v", ev, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reorg event")
+	}
+	select {
+	case ev := <-reorgCh:
+		t.Errorf("unexpected reorg event %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func TestReorgSideEvent(t *testing.T) {
 	var (
 		db      = aquadb.NewMemDatabase()
@@ -1359,3 +1417,185 @@ func TestLargeReorgTrieGC(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a chain sharing its database with another one picks up the blocks
+// imported by it when reloading its head.
+func TestReloadHead(t *testing.T) {
+	db, writer, err := newCanonical(aquahash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer writer.Stop()
+
+	replica, err := NewBlockChain(db, nil, writer.chainConfig, aquahash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create replica chain: %v", err)
+	}
+	defer replica.Stop()
+
+	heads := make(chan ChainHeadEvent, 1)
+	sub := replica.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	blocks := makeBlockChain(writer.CurrentBlock(), 5, aquahash.NewFaker(), db, canonicalSeed)
+	if _, err := writer.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if replica.CurrentBlock().NumberU64() != 0 {
+		t.Fatalf("replica head moved before reload: %d", replica.CurrentBlock().NumberU64())
+	}
+	if err := replica.ReloadHead(); err != nil {
+		t.Fatalf("failed to reload head: %v", err)
+	}
+	if have, want := replica.CurrentBlock().Hash(), blocks[len(blocks)-1].Hash(); have != want {
+		t.Errorf("replica head mismatch: have %x, want %x", have, want)
+	}
+	if have, want := replica.CurrentHeader().Hash(), blocks[len(blocks)-1].Hash(); have != want {
+		t.Errorf("replica header mismatch: have %x, want %x", have, want)
+	}
+	select {
+	case ev := <-heads:
+		if ev.Block.Hash() != blocks[len(blocks)-1].Hash() {
+			t.Errorf("head event mismatch: have %x, want %x", ev.Block.Hash(), blocks[len(blocks)-1].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no head event after reload")
+	}
+}
+
+// Tests that pruning nodes keep the configured number of recent states.
+func TestTriesInMemory(t *testing.T) {
+	engine := aquahash.NewFaker()
+
+	db := aquadb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 20, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	diskdb := aquadb.NewMemDatabase()
+	new(Genesis).MustCommit(diskdb)
+
+	cache := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, TriesInMemory: 4}
+	chain, err := NewBlockChain(diskdb, cache, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if size := chain.triegc.Size(); size != 4 {
+		t.Errorf("recent tries mismatch: have %d, want 4", size)
+	}
+}
+
+// Tests that the state snapshot follows the chain head through imports, reorgs
+// and rewinds, serving the same state as the tries.
+func TestBlockChainSnapshot(t *testing.T) {
+	var (
+		engine   = aquahash.NewFaker()
+		gendb    = aquadb.NewMemDatabase()
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000)},
+				contract: {
+					Balance: big.NewInt(1),
+					// sstore(number, timestamp); sstore(1, 0)
+					Code:    []byte{0x42, 0x43, 0x55, 0x60, 0x00, 0x60, 0x01, 0x55, 0x00},
+					Storage: map[common.Hash]common.Hash{{1}: {2}},
+				},
+			},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	call := func(b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), contract, new(big.Int), 100000, nil, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	}
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 6, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+		call(b)
+	})
+	forks, _ := GenerateChain(gspec.Config, blocks[1], engine, gendb, 6, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+		if i%2 == 0 {
+			call(b)
+		}
+	})
+	// Import as an archive node, so the states can be read without the snapshot
+	db := aquadb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	cache := &CacheConfig{Disabled: true, Snapshot: true}
+	chain, err := NewBlockChain(db, cache, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	check := func(number uint64) {
+		t.Helper()
+
+		for start := time.Now(); !chain.snaps.Generated(); time.Sleep(time.Millisecond) {
+			if time.Since(start) > 10*time.Second {
+				t.Fatal("snapshot generation timed out")
+			}
+		}
+		head := chain.CurrentBlock()
+		if head.NumberU64() != number {
+			t.Fatalf("head number mismatch: have %d, want %d", head.NumberU64(), number)
+		}
+		if _, ok := chain.snaps.Account(head.Root(), crypto.Keccak256Hash(contract[:])); !ok {
+			t.Fatal("head state not served by the snapshot")
+		}
+		flat, _ := chain.State()
+		plain, _ := state.New(head.Root(), state.NewDatabase(db))
+		for _, addr := range []common.Address{address, contract, {1}, {2}} {
+			if have, want := flat.GetBalance(addr), plain.GetBalance(addr); have.Cmp(want) != 0 {
+				t.Errorf("block %d: balance of %x mismatch: have %v, want %v", number, addr, have, want)
+			}
+			if have, want := flat.GetNonce(addr), plain.GetNonce(addr); have != want {
+				t.Errorf("block %d: nonce of %x mismatch: have %d, want %d", number, addr, have, want)
+			}
+		}
+		for i := int64(0); i < 10; i++ {
+			slot := common.BigToHash(big.NewInt(i))
+			if have, want := flat.GetState(contract, slot), plain.GetState(contract, slot); have != want {
+				t.Errorf("block %d: slot %x mismatch: have %x, want %x", number, slot, have, want)
+			}
+		}
+	}
+	check(0)
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check(6)
+
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	check(8)
+
+	if err := chain.SetHead(4); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	check(4)
+	chain.Stop()
+
+	// Reopen the chain, picking up the snapshot where it was left
+	if chain, err = NewBlockChain(db, cache, gspec.Config, engine, vm.Config{}); err != nil {
+		t.Fatalf("failed to reopen tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if !chain.snaps.Generated() {
+		t.Error("snapshot regenerated after restart")
+	}
+	check(4)
+}
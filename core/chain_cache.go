@@ -0,0 +1,179 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+// errInvalidCacheSize is returned when a cache is resized to zero or less entries.
+var errInvalidCacheSize = errors.New("cache size must be positive")
+
+// CacheStats is a snapshot of the usage of one of the chain caches.
+type CacheStats struct {
+	Size   int    `json:"size"`   // Maximum number of entries
+	Len    int    `json:"len"`    // Current number of entries
+	Hits   uint64 `json:"hits"`   // Lookups answered from memory
+	Misses uint64 `json:"misses"` // Lookups that went to the database
+}
+
+// chainCache is a resizable LRU cache that keeps track of its hit rate, both
+// locally and in the chain/cache/<name> metrics.
+type chainCache struct {
+	lock  sync.RWMutex
+	cache *lru.Cache
+	size  int
+
+	hits, misses            uint64
+	hitCounter, missCounter metrics.Counter
+}
+
+// newChainCache creates a cache of the given size reporting under name.
+func newChainCache(name string, size int) *chainCache {
+	cache, _ := lru.New(size)
+	return &chainCache{
+		cache:       cache,
+		size:        size,
+		hitCounter:  metrics.GetOrRegisterCounter("chain/cache/"+name+"/hits", nil),
+		missCounter: metrics.GetOrRegisterCounter("chain/cache/"+name+"/misses", nil),
+	}
+}
+
+// Get looks up a key, counting the lookup as a hit or a miss.
+func (c *chainCache) Get(key interface{}) (interface{}, bool) {
+	c.lock.RLock()
+	value, ok := c.cache.Get(key)
+	c.lock.RUnlock()
+
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		c.hitCounter.Inc(1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+		c.missCounter.Inc(1)
+	}
+	return value, ok
+}
+
+// Add inserts a value into the cache, evicting the oldest one if full.
+func (c *chainCache) Add(key, value interface{}) {
+	c.lock.RLock()
+	c.cache.Add(key, value)
+	c.lock.RUnlock()
+}
+
+// Contains checks whether a key is cached without touching its recency or
+// the hit statistics.
+func (c *chainCache) Contains(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cache.Contains(key)
+}
+
+// Purge drops every cached entry.
+func (c *chainCache) Purge() {
+	c.lock.RLock()
+	c.cache.Purge()
+	c.lock.RUnlock()
+}
+
+// Resize changes the capacity of the cache, keeping the most recently used
+// entries that still fit.
+func (c *chainCache) Resize(size int) error {
+	if size <= 0 {
+		return errInvalidCacheSize
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if size == c.size {
+		return nil
+	}
+	cache, _ := lru.New(size)
+	for _, key := range c.cache.Keys() { // oldest first
+		if value, ok := c.cache.Peek(key); ok {
+			cache.Add(key, value)
+		}
+	}
+	c.cache, c.size = cache, size
+	return nil
+}
+
+// Stats returns the current usage of the cache.
+func (c *chainCache) Stats() CacheStats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return CacheStats{
+		Size:   c.size,
+		Len:    c.cache.Len(),
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+// caches returns the tunable chain caches by name.
+func (bc *BlockChain) caches() map[string][]*chainCache {
+	return map[string][]*chainCache{
+		"bodies":   {bc.bodyCache, bc.bodyRLPCache},
+		"blocks":   {bc.blockCache},
+		"receipts": {bc.receiptsCache},
+		"headers":  {bc.hc.headerCache},
+		"tds":      {bc.hc.tdCache},
+		"numbers":  {bc.hc.numberCache},
+	}
+}
+
+// CacheNames returns the names accepted by SetCacheSize, sorted.
+func (bc *BlockChain) CacheNames() []string {
+	var names []string
+	for name := range bc.caches() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetCacheSize changes the number of entries kept by the named chain cache.
+func (bc *BlockChain) SetCacheSize(name string, size int) error {
+	caches, ok := bc.caches()[name]
+	if !ok {
+		return fmt.Errorf("unknown cache %q, want one of %v", name, bc.CacheNames())
+	}
+	for _, cache := range caches {
+		if err := cache.Resize(size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheStats reports the size and hit rate of every chain cache.
+func (bc *BlockChain) CacheStats() map[string]CacheStats {
+	stats := make(map[string]CacheStats)
+	for name, caches := range bc.caches() {
+		stats[name] = caches[0].Stats()
+	}
+	return stats
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+)
+
+// Tests that resizing a cache keeps the most recently used entries.
+func TestChainCacheResize(t *testing.T) {
+	cache := newChainCache("test", 4)
+	for i := 0; i < 4; i++ {
+		cache.Add(i, i)
+	}
+	cache.Get(0) // 0 becomes the most recent entry
+
+	if err := cache.Resize(2); err != nil {
+		t.Fatalf("failed to shrink cache: %v", err)
+	}
+	for _, key := range []int{0, 3} {
+		if !cache.Contains(key) {
+			t.Errorf("recent entry %d evicted", key)
+		}
+	}
+	for _, key := range []int{1, 2} {
+		if cache.Contains(key) {
+			t.Errorf("old entry %d retained", key)
+		}
+	}
+	if err := cache.Resize(0); err != errInvalidCacheSize {
+		t.Errorf("zero size: have %v, want %v", err, errInvalidCacheSize)
+	}
+	cache.Get(1)
+	if stats := cache.Stats(); stats != (CacheStats{Size: 2, Len: 2, Hits: 1, Misses: 1}) {
+		t.Errorf("stats mismatch: have %+v", stats)
+	}
+}
+
+// Tests that the chain caches can be tuned by name and report their usage.
+func TestBlockChainSetCacheSize(t *testing.T) {
+	_, chain, err := newCanonical(aquahash.NewFaker(), 8, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if err := chain.SetCacheSize("receipts", 3); err != nil {
+		t.Fatalf("failed to resize receipts cache: %v", err)
+	}
+	if err := chain.SetCacheSize("nonexistent", 3); err == nil {
+		t.Errorf("unknown cache resized")
+	}
+	for i := uint64(1); i <= 8; i++ {
+		hash := chain.GetBlockByNumber(i).Hash()
+		chain.GetReceiptsByHash(hash)
+		chain.GetReceiptsByHash(hash)
+	}
+	stats := chain.CacheStats()["receipts"]
+	if stats != (CacheStats{Size: 3, Len: 3, Hits: 8, Misses: 8}) {
+		t.Errorf("receipts cache stats mismatch: have %+v", stats)
+	}
+}
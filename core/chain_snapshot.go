@@ -0,0 +1,524 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/consensus"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rlp"
+)
+
+// Chain snapshot files bootstrap a node from the state of a checkpoint block,
+// without importing the chain before it. They are a stream of RLP items:
+//
+//   - a SnapshotHeader describing the checkpoint,
+//   - the last Blocks blocks up to the checkpoint with their receipts,
+//   - the trie nodes and contract code of the checkpoint state, keyed by hash,
+//   - a trailer string: the magic below, the number of state entries, the
+//     SHA256 checksum of all the items before and an optional signature of
+//     the checksum by the publisher of the snapshot.
+//
+// The encoding only depends on the chain, so snapshots of the same checkpoint
+// made by different nodes are identical.
+const (
+	snapshotMagic        = "aquachain-snapshot"
+	snapshotTrailerMagic = "aquachain-snapshot-sha256"
+	snapshotVersion      = 1
+)
+
+var (
+	errSnapshotNotEmpty  = errors.New("chain database is not empty")
+	errSnapshotUnsigned  = errors.New("snapshot is not signed")
+	errSnapshotUntrusted = errors.New("snapshot signer required")
+	errSnapshotTruncated = errors.New("snapshot truncated")
+)
+
+// SnapshotHeader describes the checkpoint of a chain snapshot.
+type SnapshotHeader struct {
+	Magic   string
+	Version uint64
+	Genesis common.Hash // Genesis block of the chain
+	Number  uint64      // Checkpoint block number
+	Hash    common.Hash // Checkpoint block hash
+	Root    common.Hash // State root of the checkpoint
+	Blocks  uint64      // Number of blocks up to the checkpoint included
+	Td      *big.Int    // Total difficulty of the parent of the first block
+}
+
+// snapshotBlock is a block of a chain snapshot along with its receipts.
+type snapshotBlock struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+}
+
+// snapshotEntry is a state trie node or contract code of a chain snapshot.
+type snapshotEntry struct {
+	Hash common.Hash
+	Data []byte
+}
+
+// snapshotWriter encodes the items of a snapshot, checksumming them.
+type snapshotWriter struct {
+	w   io.Writer
+	sum hash.Hash
+}
+
+func (w *snapshotWriter) write(item interface{}) error {
+	blob, err := rlp.EncodeToBytes(item)
+	if err != nil {
+		return err
+	}
+	w.sum.Write(blob)
+	_, err = w.w.Write(blob)
+	return err
+}
+
+// ExportSnapshot writes a snapshot of the state of the canonical block number,
+// along with the given number of blocks up to it, signing it with key if not
+// nil. It returns the checksum of the snapshot.
+func ExportSnapshot(w io.Writer, bc *BlockChain, number uint64, blocks uint64, key *ecdsa.PrivateKey) ([]byte, error) {
+	if number == 0 {
+		return nil, errors.New("cannot snapshot the genesis block")
+	}
+	checkpoint := bc.GetBlockByNumber(number)
+	if checkpoint == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	statedb, err := bc.StateAt(checkpoint.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state of block %d not available: %v", number, err)
+	}
+	if blocks == 0 || blocks > number {
+		blocks = number
+	}
+	first := number - blocks + 1
+	parent := bc.GetBlockByNumber(first - 1)
+	td := bc.GetTd(parent.Hash(), parent.NumberU64())
+	sw := &snapshotWriter{w: w, sum: sha256.New()}
+	err = sw.write(&SnapshotHeader{
+		Magic:   snapshotMagic,
+		Version: snapshotVersion,
+		Genesis: bc.Genesis().Hash(),
+		Number:  number,
+		Hash:    checkpoint.Hash(),
+		Root:    checkpoint.Root(),
+		Blocks:  blocks,
+		Td:      td,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for n := first; n <= number; n++ {
+		block := bc.GetBlockByNumber(n)
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found", n)
+		}
+		receipts := bc.GetReceiptsByHash(block.Hash())
+		stored := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			stored[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := sw.write(&snapshotBlock{Block: block, Receipts: stored}); err != nil {
+			return nil, err
+		}
+	}
+	// Dump the state trie nodes and contract code, skipping repeated ones
+	var (
+		triedb  = bc.stateCache.TrieDB()
+		it      = state.NewNodeIterator(statedb)
+		seen    = make(map[common.Hash]struct{})
+		entries uint64
+		logged  = time.Now()
+	)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		if _, ok := seen[it.Hash]; ok {
+			continue
+		}
+		seen[it.Hash] = struct{}{}
+
+		data, err := triedb.Node(it.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("state entry %x missing: %v", it.Hash, err)
+		}
+		if err := sw.write(&snapshotEntry{Hash: it.Hash, Data: data}); err != nil {
+			return nil, err
+		}
+		entries++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state snapshot", "entries", entries)
+			logged = time.Now()
+		}
+	}
+	if it.Error != nil {
+		return nil, it.Error
+	}
+	sum := sw.sum.Sum(nil)
+	var sig []byte
+	if key != nil {
+		if sig, err = crypto.Sign(sum, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := rlp.Encode(w, encodeSnapshotTrailer(entries, sum, sig)); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// encodeSnapshotTrailer returns the trailer closing a snapshot.
+func encodeSnapshotTrailer(entries uint64, sum []byte, sig []byte) []byte {
+	trailer := append([]byte(snapshotTrailerMagic), encodeBlockNumber(entries)...)
+	trailer = append(trailer, sum...)
+	return append(trailer, sig...)
+}
+
+// SnapshotInfo reports the outcome of a snapshot import.
+type SnapshotInfo struct {
+	Header   *SnapshotHeader
+	Entries  uint64         // Number of state entries imported
+	Checksum []byte         // Checksum of the snapshot
+	Signer   common.Address // Signer of the snapshot, zero if unsigned
+}
+
+// ImportSnapshot bootstraps the chain database from a snapshot. The database
+// must hold nothing but the genesis block of the chain the snapshot was made
+// of. The headers of the snapshot blocks are verified by the consensus engine,
+// and the blocks and head markers are only written once the whole snapshot is
+// verified.
+//
+// If signer is given, the snapshot must be signed by it, and the total
+// difficulty it claims is adopted. Unsigned snapshots are only imported if
+// insecure is set, and then only count the work of the blocks they include on
+// top of the genesis block, so the node gives way to any heavier chain.
+func ImportSnapshot(db aquadb.Database, engine consensus.Engine, r io.Reader, signer *common.Address, insecure bool) (*SnapshotInfo, error) {
+	if signer == nil && !insecure {
+		return nil, errSnapshotUntrusted
+	}
+	genesis := GetCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil, ErrNoGenesis
+	}
+	if head := GetHeadBlockHash(db); head != (common.Hash{}) && head != genesis {
+		return nil, errSnapshotNotEmpty
+	}
+	config, err := GetChainConfig(db, genesis)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		stream = rlp.NewStream(r, 0)
+		sum    = sha256.New()
+		info   = new(SnapshotInfo)
+	)
+	next := func(item interface{}) error {
+		raw, err := stream.Raw()
+		if err == io.EOF {
+			return errSnapshotTruncated
+		}
+		if err != nil {
+			return err
+		}
+		sum.Write(raw)
+		return rlp.DecodeBytes(raw, item)
+	}
+	header := new(SnapshotHeader)
+	if err := next(header); err != nil {
+		return nil, fmt.Errorf("invalid snapshot header: %v", err)
+	}
+	switch {
+	case header.Magic != snapshotMagic:
+		return nil, fmt.Errorf("not a chain snapshot")
+	case header.Version != snapshotVersion:
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	case header.Genesis != genesis:
+		return nil, fmt.Errorf("snapshot of another chain: genesis %x, have %x", header.Genesis, genesis)
+	case header.Blocks == 0 || header.Blocks > header.Number:
+		return nil, fmt.Errorf("invalid snapshot block count %d", header.Blocks)
+	}
+	info.Header = header
+
+	// Read and check the linkage of the blocks, keeping them until verified
+	blocks := make([]*snapshotBlock, header.Blocks)
+	for i := range blocks {
+		blocks[i] = new(snapshotBlock)
+		if err := next(blocks[i]); err != nil {
+			return nil, fmt.Errorf("block %d: %v", i, err)
+		}
+		block := blocks[i].Block
+		block.SetVersion(config.GetBlockVersion(block.Number()))
+		if want := header.Number - header.Blocks + 1 + uint64(i); block.NumberU64() != want {
+			return nil, fmt.Errorf("block number mismatch: have %d, want %d", block.NumberU64(), want)
+		}
+		if i > 0 && block.ParentHash() != blocks[i-1].Block.Hash() {
+			return nil, fmt.Errorf("block %d not linked to its parent", block.NumberU64())
+		}
+		if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+			return nil, fmt.Errorf("block %d: transaction root mismatch", block.NumberU64())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return nil, fmt.Errorf("block %d: uncle root mismatch", block.NumberU64())
+		}
+		receipts := make(types.Receipts, len(blocks[i].Receipts))
+		for j, receipt := range blocks[i].Receipts {
+			receipts[j] = (*types.Receipt)(receipt)
+		}
+		if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+			return nil, fmt.Errorf("block %d: receipt root mismatch", block.NumberU64())
+		}
+	}
+	checkpoint := blocks[len(blocks)-1].Block
+	if checkpoint.Hash() != header.Hash || checkpoint.Root() != header.Root {
+		return nil, fmt.Errorf("checkpoint block mismatch")
+	}
+	if err := verifySnapshotHeaders(db, config, engine, blocks); err != nil {
+		return nil, err
+	}
+	// Write the state entries as they come, they're unreferenced until the head
+	// is moved to the checkpoint
+	var (
+		batch  = db.NewBatch()
+		logged = time.Now()
+	)
+	for {
+		kind, _, err := stream.Kind()
+		if err == io.EOF {
+			return nil, errSnapshotTruncated
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind != rlp.List {
+			break
+		}
+		var entry snapshotEntry
+		if err := next(&entry); err != nil {
+			return nil, err
+		}
+		if crypto.Keccak256Hash(entry.Data) != entry.Hash {
+			return nil, fmt.Errorf("state entry %x corrupted", entry.Hash)
+		}
+		if err := batch.Put(entry.Hash[:], entry.Data); err != nil {
+			return nil, err
+		}
+		info.Entries++
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state snapshot", "entries", info.Entries)
+			logged = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	// Verify the trailer and the signature
+	trailer, err := stream.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if info.Checksum, info.Signer, err = verifySnapshotTrailer(trailer, info.Entries, sum.Sum(nil)); err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		if info.Signer == (common.Address{}) {
+			return nil, errSnapshotUnsigned
+		}
+		if info.Signer != *signer {
+			return nil, fmt.Errorf("snapshot signed by %x, want %x", info.Signer, *signer)
+		}
+	}
+	// Make sure the checkpoint state is complete before adopting it
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint state incomplete: %v", err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return nil, fmt.Errorf("checkpoint state incomplete: %v", it.Error)
+	}
+	// Everything checks out, write the blocks and move the head
+	td := GetTd(db, genesis, 0)
+	if signer != nil && header.Td != nil {
+		td = new(big.Int).Set(header.Td)
+	}
+	for _, b := range blocks {
+		block := b.Block
+		receipts := make(types.Receipts, len(b.Receipts))
+		for i, receipt := range b.Receipts {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		td.Add(td, block.Difficulty())
+
+		if err := WriteBlock(db, block); err != nil {
+			return nil, err
+		}
+		if err := WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts); err != nil {
+			return nil, err
+		}
+		if err := WriteTd(db, block.Hash(), block.NumberU64(), td); err != nil {
+			return nil, err
+		}
+		if err := WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			return nil, err
+		}
+		if err := WriteTxLookupEntries(db, block); err != nil {
+			return nil, err
+		}
+	}
+	// The transactions of the blocks before the snapshot are unknown
+	if err := WriteTxIndexTail(db, blocks[0].Block.NumberU64()); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadHeaderHash(db, header.Hash); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadFastBlockHash(db, header.Hash); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadBlockHash(db, header.Hash); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// verifySnapshotHeaders checks the headers of the snapshot blocks against the
+// consensus rules. The first blocks lack the ancestors needed for a full
+// verification unless they follow the genesis block, so only their seals are
+// checked.
+func verifySnapshotHeaders(db aquadb.Database, config *params.ChainConfig, engine consensus.Engine, blocks []*snapshotBlock) error {
+	chain := &snapshotChain{db: db, config: config, headers: make(map[common.Hash]*types.Header)}
+	for _, b := range blocks {
+		var (
+			header = b.Block.Header()
+			number = header.Number.Uint64()
+			err    error
+		)
+		parent := chain.GetHeader(header.ParentHash, number-1)
+		if parent != nil && (number < 3 || chain.GetHeader(parent.ParentHash, number-2) != nil) {
+			err = engine.VerifyHeader(chain, header, true)
+		} else {
+			err = engine.VerifySeal(chain, header)
+		}
+		if err != nil {
+			return fmt.Errorf("block %d: %v", number, err)
+		}
+		chain.headers[header.Hash()] = header
+		chain.head = header
+	}
+	return nil
+}
+
+// snapshotChain is the chain seen by the consensus engine while verifying the
+// blocks of a snapshot: the genesis block and the snapshot blocks verified so
+// far.
+type snapshotChain struct {
+	db      aquadb.Database
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+	head    *types.Header
+}
+
+func (c *snapshotChain) Config() *params.ChainConfig  { return c.config }
+func (c *snapshotChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *snapshotChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := c.headers[hash]; ok && header.Number.Uint64() == number {
+		return header
+	}
+	if number != 0 {
+		return nil
+	}
+	header := GetHeaderNoVersion(c.db, hash, 0)
+	if header != nil {
+		header.Version = c.config.GetBlockVersion(header.Number)
+	}
+	return header
+}
+
+func (c *snapshotChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range c.headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	if number != 0 {
+		return nil
+	}
+	return c.GetHeader(GetCanonicalHash(c.db, 0), 0)
+}
+
+func (c *snapshotChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	if header, ok := c.headers[hash]; ok {
+		return header
+	}
+	return c.GetHeader(hash, 0)
+}
+
+func (c *snapshotChain) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+
+// verifySnapshotTrailer checks a snapshot trailer against the number of state
+// entries and the checksum of the items read, returning the checksum and the
+// signer, if signed.
+func verifySnapshotTrailer(trailer []byte, entries uint64, sum []byte) ([]byte, common.Address, error) {
+	var (
+		prefix = len(snapshotTrailerMagic) + 8
+		signer common.Address
+	)
+	if len(trailer) < prefix+sha256.Size || !bytes.HasPrefix(trailer, []byte(snapshotTrailerMagic)) {
+		return nil, signer, fmt.Errorf("invalid snapshot trailer")
+	}
+	if count := binary.BigEndian.Uint64(trailer[len(snapshotTrailerMagic):prefix]); count != entries {
+		return nil, signer, fmt.Errorf("state entry count mismatch: snapshot has %d, trailer covers %d", entries, count)
+	}
+	if want := trailer[prefix : prefix+sha256.Size]; !bytes.Equal(sum, want) {
+		return nil, signer, fmt.Errorf("checksum mismatch: have %x, want %x", sum, want)
+	}
+	if sig := trailer[prefix+sha256.Size:]; len(sig) > 0 {
+		pub, err := crypto.SigToPub(sum, sig)
+		if err != nil {
+			return nil, signer, fmt.Errorf("invalid snapshot signature: %v", err)
+		}
+		signer = crypto.PubkeyToAddress(*pub)
+	}
+	return sum, signer, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that a chain snapshot bootstraps a new node at the checkpoint, and
+// that tampered, wrongly signed or unsigned snapshots are rejected.
+func TestChainSnapshot(t *testing.T) {
+	var (
+		engine  = aquahash.NewFaker()
+		gendb   = aquadb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 8, func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{1}, big.NewInt(1), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	db := aquadb.NewMemDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var snapshot bytes.Buffer
+	sum, err := ExportSnapshot(&snapshot, chain, 6, 3, key)
+	if err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	// Exports of the same checkpoint are identical
+	var again bytes.Buffer
+	if _, err := ExportSnapshot(&again, chain, 6, 3, key); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	if !bytes.Equal(snapshot.Bytes(), again.Bytes()) {
+		t.Errorf("snapshot export not deterministic")
+	}
+	newdb := func() aquadb.Database {
+		db := aquadb.NewMemDatabase()
+		gspec.MustCommit(db)
+		return db
+	}
+	// Snapshots signed by someone else or tampered with are rejected
+	other := common.Address{0xff}
+	if _, err := ImportSnapshot(newdb(), engine, bytes.NewReader(snapshot.Bytes()), &other, false); err == nil {
+		t.Errorf("snapshot with wrong signer imported")
+	}
+	tampered := common.CopyBytes(snapshot.Bytes())
+	tampered[len(tampered)/2] ^= 0x01
+	if _, err := ImportSnapshot(newdb(), engine, bytes.NewReader(tampered), nil, true); err == nil {
+		t.Errorf("tampered snapshot imported")
+	}
+	// Snapshots without a signer are only imported if explicitly insecure
+	if _, err := ImportSnapshot(newdb(), engine, bytes.NewReader(snapshot.Bytes()), nil, false); err != errSnapshotUntrusted {
+		t.Errorf("import without signer: have %v, want %v", err, errSnapshotUntrusted)
+	}
+	// A valid snapshot bootstraps a node that follows the chain from there
+	bootdb := newdb()
+	info, err := ImportSnapshot(bootdb, engine, bytes.NewReader(snapshot.Bytes()), &address, false)
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if !bytes.Equal(info.Checksum, sum) || info.Signer != address {
+		t.Errorf("snapshot info mismatch: checksum %x, signer %x", info.Checksum, info.Signer)
+	}
+	boot, err := NewBlockChain(bootdb, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create bootstrapped chain: %v", err)
+	}
+	defer boot.Stop()
+	if head := boot.CurrentBlock(); head.Hash() != blocks[5].Hash() {
+		t.Fatalf("head mismatch: have %d, want 6", head.NumberU64())
+	}
+	if td, want := boot.GetTd(blocks[5].Hash(), 6), chain.GetTd(blocks[5].Hash(), 6); td.Cmp(want) != 0 {
+		t.Errorf("total difficulty mismatch: have %v, want %v", td, want)
+	}
+	if _, err := boot.InsertChain(blocks[6:]); err != nil {
+		t.Fatalf("failed to extend bootstrapped chain: %v", err)
+	}
+	if head := boot.CurrentBlock(); head.Hash() != blocks[7].Hash() {
+		t.Errorf("head mismatch after extension: have %d, want 8", head.NumberU64())
+	}
+	// Importing over a non-empty chain is refused
+	if _, err := ImportSnapshot(bootdb, engine, bytes.NewReader(snapshot.Bytes()), nil, true); err != errSnapshotNotEmpty {
+		t.Errorf("import over existing chain: have %v, want %v", err, errSnapshotNotEmpty)
+	}
+	// Insecure imports don't take the claimed total difficulty at face value
+	insecuredb := newdb()
+	if _, err := ImportSnapshot(insecuredb, engine, bytes.NewReader(snapshot.Bytes()), nil, true); err != nil {
+		t.Fatalf("failed to import insecure snapshot: %v", err)
+	}
+	want := GetTd(insecuredb, genesis.Hash(), 0)
+	for _, block := range blocks[3:6] {
+		want.Add(want, block.Difficulty())
+	}
+	if td := GetTd(insecuredb, blocks[5].Hash(), 6); td.Cmp(want) != 0 {
+		t.Errorf("insecure total difficulty mismatch: have %v, want %v", td, want)
+	}
+	// Blocks failing the consensus rules are rejected
+	if _, err := ImportSnapshot(newdb(), aquahash.NewFakeFailer(5), bytes.NewReader(snapshot.Bytes()), &address, false); err == nil {
+		t.Errorf("snapshot with invalid seal imported")
+	}
+}
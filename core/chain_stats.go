@@ -0,0 +1,166 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// ContractCalls counts the transactions calling a contract.
+type ContractCalls struct {
+	Address common.Address `json:"address"`
+	Calls   int            `json:"calls"`
+}
+
+// PeriodStats summarizes the canonical blocks mined within a period of time.
+type PeriodStats struct {
+	Start      uint64 `json:"start"`      // Unix time the period starts at
+	FirstBlock uint64 `json:"firstBlock"` // Number of the first block in the period
+	LastBlock  uint64 `json:"lastBlock"`  // Number of the last block in the period
+	Blocks     int    `json:"blocks"`
+
+	BlockTime     float64  `json:"blockTime"`     // Average seconds since the parent block
+	Difficulty    *big.Int `json:"difficulty"`    // Average difficulty
+	MaxDifficulty *big.Int `json:"maxDifficulty"` // Highest difficulty
+	GasUsed       uint64   `json:"gasUsed"`
+	GasLimit      uint64   `json:"gasLimit"` // Sum of the gas limits, for utilization
+	Txs           int      `json:"txs"`
+	Senders       int      `json:"senders"` // Distinct transaction senders
+
+	// TopContracts are the most called contracts, counting transactions with
+	// input data sent to an address.
+	TopContracts []ContractCalls `json:"topContracts"`
+}
+
+// AnalyzeChain walks the canonical blocks from first to last and aggregates
+// their statistics into periods of the given number of seconds, aligned to
+// the Unix epoch. At most top contracts are reported per period. Blocks are
+// read straight from the database, so the chain must not be running.
+func AnalyzeChain(db aquadb.Database, config *params.ChainConfig, first, last, period uint64, top int) ([]*PeriodStats, error) {
+	if period == 0 {
+		return nil, errors.New("zero period length")
+	}
+	if first > last {
+		return nil, fmt.Errorf("first block %d above last block %d", first, last)
+	}
+	var (
+		periods  []*PeriodStats
+		current  *PeriodStats
+		senders  map[common.Address]struct{}
+		calls    map[common.Address]int
+		gaps     int      // Blocks in the current period with a known parent time
+		diffSum  *big.Int // Sum of the difficulties in the current period
+		prevTime uint64
+		havePrev bool // Whether prevTime holds the time of the parent block
+	)
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if gaps > 0 {
+			current.BlockTime /= float64(gaps)
+		}
+		current.Difficulty = diffSum.Div(diffSum, big.NewInt(int64(current.Blocks)))
+		current.Senders = len(senders)
+		current.TopContracts = topContracts(calls, top)
+		periods = append(periods, current)
+	}
+	if first > 0 {
+		if parent := GetHeaderNoVersion(db, GetCanonicalHash(db, first-1), first-1); parent != nil {
+			prevTime, havePrev = parent.Time.Uint64(), true
+		}
+	}
+	for n := first; n <= last; n++ {
+		hash := GetCanonicalHash(db, n)
+		if hash == (common.Hash{}) {
+			return nil, fmt.Errorf("block %d not found", n)
+		}
+		block := GetBlockNoVersion(db, hash, n)
+		if block == nil {
+			return nil, fmt.Errorf("block %d [%x…] incomplete", n, hash[:4])
+		}
+		header := block.Header()
+		time := header.Time.Uint64()
+
+		if start := time - time%period; current == nil || start != current.Start {
+			finish()
+			current = &PeriodStats{Start: start, FirstBlock: n, MaxDifficulty: new(big.Int)}
+			senders = make(map[common.Address]struct{})
+			calls = make(map[common.Address]int)
+			gaps, diffSum = 0, new(big.Int)
+		}
+		current.LastBlock = n
+		current.Blocks++
+		if havePrev && prevTime <= time {
+			current.BlockTime += float64(time - prevTime)
+			gaps++
+		}
+		prevTime, havePrev = time, true
+
+		diffSum.Add(diffSum, header.Difficulty)
+		if header.Difficulty.Cmp(current.MaxDifficulty) > 0 {
+			current.MaxDifficulty = new(big.Int).Set(header.Difficulty)
+		}
+		current.GasUsed += header.GasUsed
+		current.GasLimit += header.GasLimit
+		current.Txs += len(block.Transactions())
+
+		signer := types.MakeSigner(config, header.Number)
+		for _, tx := range block.Transactions() {
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, fmt.Errorf("block %d [%x…]: invalid transaction %x: %v", n, hash[:4], tx.Hash(), err)
+			}
+			senders[from] = struct{}{}
+			if to := tx.To(); to != nil && len(tx.Data()) > 0 {
+				calls[*to]++
+			}
+		}
+		if n == last {
+			break // last may be the highest uint64
+		}
+	}
+	finish()
+	return periods, nil
+}
+
+// topContracts returns the n most called addresses, most calls first.
+func topContracts(calls map[common.Address]int, n int) []ContractCalls {
+	list := make([]ContractCalls, 0, len(calls))
+	for addr, count := range calls {
+		list = append(list, ContractCalls{Address: addr, Calls: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Calls != list[j].Calls {
+			return list[i].Calls > list[j].Calls
+		}
+		return bytes.Compare(list[i].Address[:], list[j].Address[:]) < 0
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
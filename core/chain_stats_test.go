@@ -0,0 +1,125 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that chain analysis aggregates blocks into the right periods and
+// counts transactions, senders and contract calls.
+func TestAnalyzeChain(t *testing.T) {
+	var (
+		db        = aquadb.NewMemDatabase()
+		key1, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _   = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1     = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2     = crypto.PubkeyToAddress(key2.PublicKey)
+		contractA = common.Address{0xaa}
+		contractB = common.Address{0xbb}
+		funds     = big.NewInt(1000000000000)
+		gspec     = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{addr1: {Balance: funds}, addr2: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	// Every block is 240 seconds after its parent, so periods of 20 minutes
+	// hold the genesis and blocks 1-4, blocks 5-9 and block 10.
+	blocks, _ := GenerateChain(gspec.Config, genesis, aquahash.NewFaker(), db, 10, func(i int, block *BlockGen) {
+		send := func(key *ecdsa.PrivateKey, to common.Address, data []byte) {
+			from := crypto.PubkeyToAddress(key.PublicKey)
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(from), to, big.NewInt(1), 100000, nil, data), signer, key)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		}
+		send(key1, contractA, []byte{0x01})
+		if i%2 == 0 {
+			send(key2, contractB, []byte{0x01})
+		}
+		if i == 4 {
+			send(key2, common.Address{0xcc}, nil) // plain transfer, not a call
+		}
+	})
+	chain, _ := NewBlockChain(db, nil, gspec.Config, aquahash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	periods, err := AnalyzeChain(db, gspec.Config, 0, 10, 1200, 1)
+	if err != nil {
+		t.Fatalf("failed to analyze chain: %v", err)
+	}
+	want := []struct {
+		start, first, last uint64
+		blocks, txs        int
+		senders            int
+		blockTime          float64
+		top                ContractCalls
+	}{
+		{0, 0, 4, 5, 6, 2, 240, ContractCalls{contractA, 4}},
+		{1200, 5, 9, 5, 9, 2, 240, ContractCalls{contractA, 5}},
+		{2400, 10, 10, 1, 1, 1, 240, ContractCalls{contractA, 1}},
+	}
+	if len(periods) != len(want) {
+		t.Fatalf("period count mismatch: have %d, want %d", len(periods), len(want))
+	}
+	for i, w := range want {
+		p := periods[i]
+		if p.Start != w.start || p.FirstBlock != w.first || p.LastBlock != w.last || p.Blocks != w.blocks {
+			t.Errorf("period %d: range mismatch: have start %d blocks %d-%d (%d), want start %d blocks %d-%d (%d)",
+				i, p.Start, p.FirstBlock, p.LastBlock, p.Blocks, w.start, w.first, w.last, w.blocks)
+		}
+		if p.Txs != w.txs || p.Senders != w.senders {
+			t.Errorf("period %d: have %d txs from %d senders, want %d from %d", i, p.Txs, p.Senders, w.txs, w.senders)
+		}
+		if p.BlockTime != w.blockTime {
+			t.Errorf("period %d: block time mismatch: have %v, want %v", i, p.BlockTime, w.blockTime)
+		}
+		if len(p.TopContracts) != 1 || p.TopContracts[0] != w.top {
+			t.Errorf("period %d: top contracts mismatch: have %v, want %v", i, p.TopContracts, w.top)
+		}
+		if p.Difficulty.Sign() <= 0 || p.MaxDifficulty.Cmp(p.Difficulty) < 0 {
+			t.Errorf("period %d: invalid difficulties: average %v, max %v", i, p.Difficulty, p.MaxDifficulty)
+		}
+	}
+	// Starting mid-chain should still measure the first block time
+	periods, err = AnalyzeChain(db, gspec.Config, 7, 10, 1200, 5)
+	if err != nil {
+		t.Fatalf("failed to analyze partial chain: %v", err)
+	}
+	if len(periods) != 2 || periods[0].Blocks != 3 || periods[0].BlockTime != 240 || len(periods[0].TopContracts) != 2 {
+		t.Errorf("partial analysis mismatch: have %+v", periods[0])
+	}
+	if _, err := AnalyzeChain(db, gspec.Config, 5, 11, 1200, 5); err == nil {
+		t.Error("analysis beyond the head succeeded")
+	}
+}
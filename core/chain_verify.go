@@ -0,0 +1,241 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// ChainIssue describes an inconsistency found in the stored chain.
+type ChainIssue struct {
+	Number  uint64
+	Hash    common.Hash
+	Problem string
+}
+
+func (i ChainIssue) String() string {
+	return fmt.Sprintf("block %d [%x…]: %s", i.Number, i.Hash[:4], i.Problem)
+}
+
+// ChainReport summarizes a consistency check of the stored chain.
+type ChainReport struct {
+	Head    uint64       // Number of the head block
+	Checked uint64       // Number of blocks checked, counting down from the head
+	Issues  []ChainIssue // Inconsistencies found, highest block first
+
+	// Consistent is the highest checked block below all issues which has its
+	// state available, nil if there is none within the checked range. Blocks
+	// below the checked range may still be damaged.
+	Consistent *types.Header
+}
+
+// VerifyChain cross-checks the most recent blocks of the stored chain, down to
+// depth blocks below the head. It verifies the canonical hash mappings, that
+// headers, bodies, receipts and total difficulties are present and match each
+// other, and whether the state roots are reachable.
+//
+// Only the checked blocks are vouched for: damage deeper than depth goes
+// unnoticed, and the consistent block reported may sit right above it. Rewinding
+// to it is only safe if the damage is known to be recent, as after an unclean
+// shutdown, or if the scan covers the whole chain.
+func VerifyChain(db aquadb.Database, config *params.ChainConfig, depth uint64) (*ChainReport, error) {
+	head := GetHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return nil, errors.New("no head block stored")
+	}
+	number := GetBlockNumber(db, head)
+	if number == missingNumber {
+		return nil, fmt.Errorf("number of head block %x unknown", head)
+	}
+	report := &ChainReport{Head: number}
+
+	var (
+		lowest   = missingNumber                  // Lowest block with an issue
+		states   = make(map[uint64]*types.Header) // Checked blocks with state available
+		parent   common.Hash                      // Parent hash of the previously checked block
+		sdb      = state.NewDatabase(db)
+		addIssue = func(n uint64, hash common.Hash, problem string) {
+			report.Issues = append(report.Issues, ChainIssue{Number: n, Hash: hash, Problem: problem})
+			lowest = n
+		}
+	)
+	for n := number; ; n-- {
+		report.Checked++
+
+		hash := GetCanonicalHash(db, n)
+		switch {
+		case hash == (common.Hash{}):
+			addIssue(n, hash, "missing canonical hash")
+		case n == number && hash != head:
+			addIssue(n, hash, fmt.Sprintf("head block %x not canonical", head))
+		case parent != (common.Hash{}) && parent != hash:
+			addIssue(n+1, GetCanonicalHash(db, n+1), fmt.Sprintf("parent %x not canonical", parent))
+		}
+		parent = common.Hash{}
+
+		if header := verifyBlock(db, config, hash, n, addIssue); header != nil {
+			parent = header.ParentHash
+			if _, err := state.New(header.Root, sdb); err == nil {
+				states[n] = header
+			}
+		}
+		if n == 0 || number-n >= depth {
+			break
+		}
+	}
+	// Find the highest block below all issues that can be resumed from
+	for n := number; ; n-- {
+		if header, ok := states[n]; ok && (lowest == missingNumber || n < lowest) {
+			report.Consistent = header
+			break
+		}
+		if n == 0 || number-n >= depth {
+			break
+		}
+	}
+	return report, nil
+}
+
+// verifyBlock checks the data stored for a single canonical block, reporting
+// all problems found. The header is returned if it is present.
+func verifyBlock(db aquadb.Database, config *params.ChainConfig, hash common.Hash, n uint64, addIssue func(uint64, common.Hash, string)) *types.Header {
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	header := GetHeaderNoVersion(db, hash, n)
+	if header == nil {
+		addIssue(n, hash, "missing header")
+		return nil
+	}
+	header.Version = config.GetBlockVersion(header.Number)
+	if header.Hash() != hash {
+		addIssue(n, hash, fmt.Sprintf("header hash mismatch: have %x", header.Hash()))
+	}
+	if header.Number.Uint64() != n {
+		addIssue(n, hash, fmt.Sprintf("header number mismatch: have %d", header.Number))
+	}
+	if GetBlockNumber(db, hash) != n {
+		addIssue(n, hash, "missing hash to number mapping")
+	}
+	if GetTd(db, hash, n) == nil {
+		addIssue(n, hash, "missing total difficulty")
+	}
+	body := GetBodyNoVersion(db, hash, n)
+	if body == nil {
+		addIssue(n, hash, "missing body")
+		return header
+	}
+	if root := types.DeriveSha(types.Transactions(body.Transactions)); root != header.TxHash {
+		addIssue(n, hash, fmt.Sprintf("transaction root mismatch: have %x, want %x", root, header.TxHash))
+	}
+	for _, uncle := range body.Uncles {
+		uncle.Version = config.GetBlockVersion(uncle.Number)
+	}
+	if uncleHash := types.CalcUncleHash(body.Uncles); uncleHash != header.UncleHash {
+		addIssue(n, hash, fmt.Sprintf("uncle hash mismatch: have %x, want %x", uncleHash, header.UncleHash))
+	}
+	receipts := GetBlockReceipts(db, hash, n)
+	switch {
+	case receipts == nil && len(body.Transactions) > 0:
+		addIssue(n, hash, "missing receipts")
+	case receipts != nil:
+		if root := types.DeriveSha(receipts); root != header.ReceiptHash {
+			addIssue(n, hash, fmt.Sprintf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash))
+		}
+	}
+	return header
+}
+
+// RewindChain truncates the stored chain back to the given canonical block,
+// deleting the canonical hash mappings, bodies, receipts and transaction lookup
+// entries of all blocks above it, like SetHead does on a running chain.
+func RewindChain(db aquadb.Database, header *types.Header) error {
+	number := header.Number.Uint64()
+	hash := GetCanonicalHash(db, number)
+	if hash != header.Hash() {
+		return fmt.Errorf("block %d [%x…] is not canonical", number, header.Hash().Bytes()[:4])
+	}
+	head := GetBlockNumber(db, GetHeadBlockHash(db))
+	if headerHead := GetBlockNumber(db, GetHeadHeaderHash(db)); headerHead != missingNumber && (head == missingNumber || headerHead > head) {
+		head = headerHead
+	}
+	batch := db.NewBatch()
+	for n := number + 1; (head != missingNumber && n <= head) || GetCanonicalHash(db, n) != (common.Hash{}); n++ {
+		if stale := GetCanonicalHash(db, n); stale != (common.Hash{}) {
+			deleteBlockData(db, batch, stale, n)
+		}
+		DeleteCanonicalHash(batch, n)
+
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := WriteHeadHeaderHash(db, hash); err != nil {
+		return err
+	}
+	if err := WriteHeadBlockHash(db, hash); err != nil {
+		return err
+	}
+	return WriteHeadFastBlockHash(db, hash)
+}
+
+// deleteBlockData removes the body, receipts and transaction lookup entries of
+// a block dropped from the canonical chain. Lookup entries pointing to another
+// block including the same transaction are kept.
+func deleteBlockData(db DatabaseReader, batch DatabaseDeleter, hash common.Hash, number uint64) {
+	if body := GetBodyNoVersion(db, hash, number); body != nil {
+		for _, tx := range body.Transactions {
+			if blockHash, _, _ := GetTxLookupEntry(db, tx.Hash()); blockHash == hash {
+				DeleteTxLookupEntry(batch, tx.Hash())
+			}
+		}
+	}
+	DeleteBody(batch, hash, number)
+	DeleteBlockReceipts(batch, hash, number)
+}
+
+// LatestStateBlock returns the newest canonical block at or below number whose
+// state is available in the database, or nil if there is none.
+func LatestStateBlock(db aquadb.Database, config *params.ChainConfig, number uint64) *types.Header {
+	statedb := state.NewDatabase(db)
+	for n := number; ; n-- {
+		if hash := GetCanonicalHash(db, n); hash != (common.Hash{}) {
+			if header := GetHeaderNoVersion(db, hash, n); header != nil {
+				if _, err := statedb.OpenTrie(header.Root); err == nil {
+					header.Version = config.GetBlockVersion(header.Number)
+					return header
+				}
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
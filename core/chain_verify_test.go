@@ -0,0 +1,153 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that chain verification reports damaged blocks and that rewinding to
+// the consistent block found leaves a clean chain behind.
+func TestVerifyAndRewindChain(t *testing.T) {
+	db, blockchain, err := newCanonical(aquahash.NewFaker(), 10, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	blockchain.Stop()
+	config := params.AllAquahashProtocolChanges
+
+	report, err := VerifyChain(db, config, 1024)
+	if err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+	if report.Head != 10 || report.Checked != 11 {
+		t.Errorf("report range mismatch: have head %d checked %d, want 10 and 11", report.Head, report.Checked)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("issues found in pristine chain: %v", report.Issues)
+	}
+	if report.Consistent == nil || report.Consistent.Number.Uint64() != 10 {
+		t.Fatalf("consistent block mismatch: have %v, want 10", report.Consistent)
+	}
+	// Damage a few blocks and make sure all of them are found
+	DeleteBody(db, GetCanonicalHash(db, 7), 7)
+	DeleteTd(db, GetCanonicalHash(db, 9), 9)
+
+	report, err = VerifyChain(db, config, 1024)
+	if err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+	if len(report.Issues) != 2 {
+		t.Fatalf("issue count mismatch: have %v, want 2", report.Issues)
+	}
+	if report.Issues[0].Number != 9 || report.Issues[1].Number != 7 {
+		t.Errorf("issue blocks mismatch: have %v", report.Issues)
+	}
+	if report.Consistent == nil || report.Consistent.Number.Uint64() != 6 {
+		t.Fatalf("consistent block mismatch: have %v, want 6", report.Consistent)
+	}
+	// A limited depth should only check the most recent blocks, missing the
+	// damage below them
+	if report, _ := VerifyChain(db, config, 2); report.Checked != 3 || len(report.Issues) != 1 || report.Consistent.Number.Uint64() != 8 {
+		t.Errorf("shallow report mismatch: checked %d, issues %v, consistent %v", report.Checked, report.Issues, report.Consistent)
+	}
+	// Rewind to the consistent block and verify again
+	if err := RewindChain(db, report.Consistent); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	report, err = VerifyChain(db, config, 1024)
+	if err != nil {
+		t.Fatalf("failed to verify rewound chain: %v", err)
+	}
+	if report.Head != 6 || len(report.Issues) != 0 {
+		t.Errorf("rewound chain mismatch: have head %d, issues %v", report.Head, report.Issues)
+	}
+	if hash := GetCanonicalHash(db, 7); hash != (common.Hash{}) {
+		t.Errorf("stale canonical hash left at 7: %x", hash)
+	}
+}
+
+// Tests that rewinding the chain, both offline and on a live chain, drops the
+// receipts and transaction lookups of the removed blocks.
+func TestRewindChainBlockData(t *testing.T) {
+	var (
+		engine  = aquahash.NewFaker()
+		gendb   = aquadb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 8, func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{1}, big.NewInt(1), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	db := aquadb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, &CacheConfig{Disabled: true}, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// check verifies that the blocks up to head still have their data, and the
+	// ones above it don't
+	check := func(head uint64) {
+		t.Helper()
+		for _, block := range blocks {
+			n := block.NumberU64()
+			kept := GetBlockReceipts(db, block.Hash(), n) != nil
+			lookup, _, _ := GetTxLookupEntry(db, block.Transactions()[0].Hash())
+			indexed := lookup != (common.Hash{})
+
+			if want := n <= head; kept != want || indexed != want {
+				t.Errorf("block %d: receipts kept %v, tx indexed %v, want %v", n, kept, indexed, want)
+			}
+		}
+	}
+	if err := chain.SetHead(6); err != nil {
+		t.Fatalf("failed to set head: %v", err)
+	}
+	check(6)
+	chain.Stop()
+
+	if header := LatestStateBlock(db, gspec.Config, 4); header == nil || header.Hash() != blocks[3].Hash() {
+		t.Fatalf("state block mismatch: have %v, want 4", header)
+	}
+	if err := RewindChain(db, blocks[3].Header()); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	check(4)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/core/state/snapshot"
+)
+
+// DatabaseTable is a group of chain database entries sharing a key prefix.
+type DatabaseTable struct {
+	Name   string
+	Prefix []byte
+}
+
+// DatabaseTables are the tables of the chain database. Entries not belonging to
+// any of them, mostly state trie nodes keyed by their hash, are reported as the
+// state table.
+var DatabaseTables = []DatabaseTable{
+	{"headers", headerPrefix},
+	{"hashes", blockHashPrefix},
+	{"bodies", bodyPrefix},
+	{"receipts", blockReceiptsPrefix},
+	{"lookups", lookupPrefix},
+	{"bloombits", bloomBitsPrefix},
+	{"indexes", []byte("i")},
+	{"preimages", []byte(preimagePrefix)},
+	{"config", configPrefix},
+	{"snapaccounts", snapshot.AccountPrefix},
+	{"snapstorage", snapshot.StoragePrefix},
+}
+
+// errSizesUnsupported is returned if the database can't estimate its size.
+var errSizesUnsupported = errors.New("database size estimation unsupported")
+
+// DatabaseSizes estimates the disk space used by each table of the chain
+// database, and by the state.
+//
+// Trie nodes are keyed by their hash, so some of them share the leading byte of
+// the tables with single byte prefixes. Their share is estimated from the size
+// of the key ranges of leading bytes not used by any table, and deducted.
+func DatabaseSizes(db aquadb.Database) (map[string]uint64, error) {
+	sizer, ok := aquadb.Unwrap(db).(aquadb.Sizer)
+	if !ok {
+		return nil, errSizesUnsupported
+	}
+	total, err := sizer.ApproximateSize(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Estimate the size of the trie nodes sharing a single leading byte
+	var used [256]bool
+	for _, table := range DatabaseTables {
+		used[table.Prefix[0]] = true
+	}
+	var trieShare, unused uint64
+	for b := 0; b < 256; b++ {
+		if used[b] {
+			continue
+		}
+		size, err := sizer.ApproximateSize([]byte{byte(b)}, prefixLimit([]byte{byte(b)}))
+		if err != nil {
+			return nil, err
+		}
+		trieShare += size
+		unused++
+	}
+	trieShare /= unused
+
+	// Size all the tables and attribute the rest to the state
+	sizes := make(map[string]uint64, len(DatabaseTables)+1)
+	state := total
+	for _, table := range DatabaseTables {
+		size, err := sizer.ApproximateSize(table.Prefix, prefixLimit(table.Prefix))
+		if err != nil {
+			return nil, err
+		}
+		if len(table.Prefix) == 1 {
+			if size > trieShare {
+				size -= trieShare
+			} else {
+				size = 0
+			}
+		}
+		if size > state {
+			size = state
+		}
+		sizes[table.Name] = size
+		state -= size
+	}
+	sizes["state"] = state
+	return sizes, nil
+}
+
+// prefixLimit returns the smallest key after all keys with the given prefix, or
+// nil if there is none.
+func prefixLimit(prefix []byte) []byte {
+	limit := append([]byte{}, prefix...)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+// sizedDatabase is a memory database reporting the size of its key ranges as
+// the sum of the key and value lengths within.
+type sizedDatabase struct {
+	*aquadb.MemDatabase
+}
+
+func (db sizedDatabase) ApproximateSize(start, limit []byte) (uint64, error) {
+	var size uint64
+	for _, key := range db.Keys() {
+		if bytes.Compare(key, start) < 0 || (limit != nil && bytes.Compare(key, limit) >= 0) {
+			continue
+		}
+		value, _ := db.Get(key)
+		size += uint64(len(key) + len(value))
+	}
+	return size, nil
+}
+
+func TestDatabaseSizes(t *testing.T) {
+	db := sizedDatabase{aquadb.NewMemDatabase()}
+
+	db.Put(append(append([]byte{}, headerPrefix...), make([]byte, 40)...), make([]byte, 59)) // 100 bytes
+	db.Put(append(append([]byte{}, bodyPrefix...), make([]byte, 40)...), make([]byte, 159))  // 200 bytes
+	db.Put(append([]byte(preimagePrefix), make([]byte, 32)...), make([]byte, 57))            // 100 bytes
+
+	sizes, err := DatabaseSizes(db)
+	if err != nil {
+		t.Fatalf("failed to size database: %v", err)
+	}
+	want := map[string]uint64{"headers": 100, "bodies": 200, "preimages": 100, "state": 0}
+	for name, size := range want {
+		if sizes[name] != size {
+			t.Errorf("%s size mismatch: have %d, want %d", name, sizes[name], size)
+		}
+	}
+	// Add some state and check that it's attributed correctly
+	db.Put(append([]byte{0x01}, make([]byte, 31)...), make([]byte, 2528)) // 2560 bytes
+
+	if sizes, err = DatabaseSizes(db); err != nil {
+		t.Fatalf("failed to size database: %v", err)
+	}
+	var total uint64
+	for _, size := range sizes {
+		total += size
+	}
+	if total != 2960 {
+		t.Errorf("total size mismatch: have %d, want 2960", total)
+	}
+	if sizes["state"] < 2560 {
+		t.Errorf("state size too small: have %d, want at least 2560", sizes["state"])
+	}
+	if _, err := DatabaseSizes(aquadb.NewMemDatabase()); err == nil {
+		t.Errorf("expected error sizing a database without size estimation")
+	}
+}
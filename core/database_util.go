@@ -48,6 +48,7 @@ var (
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
 	trieSyncKey   = []byte("TrieSync")
+	txIndexTail   = []byte("TransactionIndexTail") // oldest block with indexed transactions, if pruned
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
@@ -385,6 +386,28 @@ func WriteHeadFastBlockHash(db aquadb.Putter, hash common.Hash) error {
 	return nil
 }
 
+// GetTxIndexTail retrieves the number of the oldest block whose transactions
+// are still indexed, or false if the index was never pruned.
+func GetTxIndexTail(db DatabaseReader) (uint64, bool) {
+	data, _ := db.Get(txIndexTail)
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions are
+// still indexed.
+func WriteTxIndexTail(db aquadb.Putter, number uint64) error {
+	return db.Put(txIndexTail, encodeBlockNumber(number))
+}
+
+// DeleteTxIndexTail removes the transaction index tail, marking the index as
+// complete again.
+func DeleteTxIndexTail(db DatabaseDeleter) error {
+	return db.Delete(txIndexTail)
+}
+
 // WriteTrieSyncProgress stores the fast sync trie process counter to support
 // retrieving it across restarts.
 func WriteTrieSyncProgress(db aquadb.Putter, count uint64) error {
@@ -41,6 +41,22 @@ type RemovedTransactionEvent struct{ Txs types.Transactions }
 // RemovedLogsEvent is posted when a reorg happens
 type RemovedLogsEvent struct{ Logs []*types.Log }
 
+// ReorgEvent is posted when a reorg drops blocks from the canonical chain. The
+// blocks are listed in ascending order, the transactions are those included by
+// only one side of the reorg.
+type ReorgEvent struct {
+	CommonHash   common.Hash   // Last block shared by both chains
+	CommonNumber uint64        // Number of the last shared block
+	Dropped      []common.Hash // Blocks dropped from the canonical chain
+	Added        []common.Hash // Blocks added to the canonical chain
+	DroppedTxs   []common.Hash // Transactions no longer in the canonical chain
+	AddedTxs     []common.Hash // Transactions new to the canonical chain
+}
+
+// ChainRewindEvent is posted when the head of the chain is rewound, dropping
+// the blocks above the new head.
+type ChainRewindEvent struct{ Block *types.Block }
+
 type ChainEvent struct {
 	Block *types.Block
 	Hash  common.Hash
@@ -0,0 +1,367 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+// Tables of the freezer, each holding one entry per frozen canonical block.
+const (
+	freezerHashTable      = "hashes"
+	freezerHeaderTable    = "headers"
+	freezerBodyTable      = "bodies"
+	freezerReceiptTable   = "receipts"
+	freezerTdTable        = "diffs"
+	freezerRecheck        = time.Minute // Time between checks for blocks to freeze
+	freezerBlocksPerCycle = 30000       // Maximum number of blocks frozen at once
+)
+
+var freezerTables = []string{freezerHashTable, freezerHeaderTable, freezerBodyTable, freezerReceiptTable, freezerTdTable}
+
+// DefaultAncientThreshold is the number of recent blocks kept in the key-value
+// store by default, with older ones moved to the freezer.
+const DefaultAncientThreshold = 90000
+
+// FreezerDatabase is a chain database moving the headers, bodies, receipts and
+// total difficulties of old canonical blocks out of the key-value store into
+// append-only flat files, which can live on a separate, cheaper volume.
+//
+// Blocks are frozen in the background once they are threshold blocks behind the
+// head. Reads missing the key-value store are served from the freezer, so the
+// move is transparent to the chain accessors. Removing the canonical hash of a
+// frozen block, as done when rewinding the chain, truncates the freezer.
+type FreezerDatabase struct {
+	aquadb.Database // Key-value store holding everything not frozen
+
+	freezer   *aquadb.Freezer
+	threshold uint64     // Number of recent blocks kept out of the freezer
+	lock      sync.Mutex // Serializes freezing with truncations
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewFreezerDatabase wraps db with a freezer stored in dir, freezing canonical
+// blocks more than threshold blocks behind the head.
+func NewFreezerDatabase(db aquadb.Database, dir string, threshold uint64) (*FreezerDatabase, error) {
+	freezer, err := aquadb.NewFreezer(dir, freezerTables)
+	if err != nil {
+		return nil, err
+	}
+	fdb := &FreezerDatabase{
+		Database:  db,
+		freezer:   freezer,
+		threshold: threshold,
+		quit:      make(chan struct{}),
+	}
+	if err := fdb.cleanup(); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	log.Info("Opened ancient chain store", "dir", dir, "frozen", freezer.Items(), "threshold", threshold)
+
+	fdb.wg.Add(1)
+	go fdb.loop()
+	return fdb, nil
+}
+
+// Unwrap implements aquadb.Wrapper.
+func (db *FreezerDatabase) Unwrap() aquadb.Database {
+	return db.Database
+}
+
+// Frozen returns the number of blocks moved to the freezer.
+func (db *FreezerDatabase) Frozen() uint64 {
+	return db.freezer.Items()
+}
+
+// Get retrieves key from the key-value store, falling back to the freezer for
+// the chain data of frozen blocks.
+func (db *FreezerDatabase) Get(key []byte) ([]byte, error) {
+	value, err := db.Database.Get(key)
+	if err == nil {
+		return value, nil
+	}
+	if ancient := db.ancient(key); ancient != nil {
+		return ancient, nil
+	}
+	return nil, err
+}
+
+// Has checks key in the key-value store, falling back to the freezer for the
+// chain data of frozen blocks.
+func (db *FreezerDatabase) Has(key []byte) (bool, error) {
+	if has, err := db.Database.Has(key); has || err != nil {
+		return has, err
+	}
+	return db.ancient(key) != nil, nil
+}
+
+// Delete removes key from the key-value store. Removing the canonical hash of
+// a frozen block truncates the freezer to the blocks before it.
+func (db *FreezerDatabase) Delete(key []byte) error {
+	if number, ok := canonicalKeyNumber(key); ok {
+		if err := db.truncate(number); err != nil {
+			return err
+		}
+	}
+	return db.Database.Delete(key)
+}
+
+// NewBatch returns a batch truncating the freezer like Delete does.
+func (db *FreezerDatabase) NewBatch() aquadb.Batch {
+	return &freezerBatch{Batch: db.Database.NewBatch(), db: db, truncate: missingNumber}
+}
+
+// Close stops freezing and closes both the freezer and the key-value store.
+func (db *FreezerDatabase) Close() {
+	close(db.quit)
+	db.wg.Wait()
+
+	if err := db.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient chain store", "err", err)
+	}
+	db.Database.Close()
+}
+
+// ancient returns the frozen value of a chain data key, or nil if the key
+// isn't one or its block isn't frozen.
+func (db *FreezerDatabase) ancient(key []byte) []byte {
+	if len(key) < 9 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(key[1:9])
+	if number >= db.freezer.Items() {
+		return nil
+	}
+	if number, ok := canonicalKeyNumber(key); ok {
+		hash, _ := db.freezer.Retrieve(freezerHashTable, number)
+		return hash
+	}
+	var table string
+	switch {
+	case len(key) == 41 && key[0] == headerPrefix[0]:
+		table = freezerHeaderTable
+	case len(key) == 42 && key[0] == headerPrefix[0] && key[41] == tdSuffix[0]:
+		table = freezerTdTable
+	case len(key) == 41 && key[0] == bodyPrefix[0]:
+		table = freezerBodyTable
+	case len(key) == 41 && key[0] == blockReceiptsPrefix[0]:
+		table = freezerReceiptTable
+	default:
+		return nil
+	}
+	// Only the canonical block of a number is frozen, check that's the one asked
+	if hash, _ := db.freezer.Retrieve(freezerHashTable, number); !bytes.Equal(hash, key[9:41]) {
+		return nil
+	}
+	value, _ := db.freezer.Retrieve(table, number)
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
+// truncate drops the frozen blocks from number on.
+func (db *FreezerDatabase) truncate(number uint64) error {
+	if number >= db.freezer.Items() {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	log.Warn("Truncating ancient chain store", "frozen", db.freezer.Items(), "number", number)
+	return db.freezer.Truncate(number)
+}
+
+// cleanup removes chain data left in the key-value store by a freezing cycle
+// interrupted between writing the freezer and deleting the moved entries.
+func (db *FreezerDatabase) cleanup() error {
+	batch := db.Database.NewBatch()
+	for n := db.freezer.Items(); n > 0; n-- {
+		hash, err := db.freezer.Retrieve(freezerHashTable, n-1)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, GetCanonicalHash(db.Database, n-1).Bytes()) {
+			break
+		}
+		deleteFrozen(batch, common.BytesToHash(hash), n-1)
+	}
+	return batch.Write()
+}
+
+// loop freezes old blocks until the database is closed.
+func (db *FreezerDatabase) loop() {
+	defer db.wg.Done()
+
+	for {
+		frozen, err := db.freeze(freezerBlocksPerCycle)
+		if err != nil {
+			log.Error("Failed to freeze blocks", "err", err)
+		}
+		// Carry on right away when behind, otherwise wait for more blocks
+		wait := freezerRecheck
+		if frozen == freezerBlocksPerCycle {
+			wait = 0
+		}
+		select {
+		case <-db.quit:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// freeze moves up to limit canonical blocks past the threshold into the
+// freezer, returning the number of blocks moved.
+func (db *FreezerDatabase) freeze(limit uint64) (uint64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	head := GetBlockNumber(db.Database, GetHeadBlockHash(db.Database))
+	if head == missingNumber || head < db.threshold {
+		return 0, nil
+	}
+	var (
+		start = time.Now()
+		first = db.freezer.Items()
+		last  = head - db.threshold
+	)
+	if first > last {
+		return 0, nil
+	}
+	if last-first >= limit {
+		last = first + limit - 1
+	}
+	var hashes []common.Hash
+freeze:
+	for n := first; n <= last; n++ {
+		select {
+		case <-db.quit:
+			break freeze
+		default:
+		}
+		hash := GetCanonicalHash(db.Database, n)
+		if hash == (common.Hash{}) {
+			break freeze
+		}
+		items := map[string][]byte{
+			freezerHashTable:    hash.Bytes(),
+			freezerHeaderTable:  GetHeaderRLP(db.Database, hash, n),
+			freezerBodyTable:    GetBodyRLP(db.Database, hash, n),
+			freezerReceiptTable: getReceiptsRLP(db.Database, hash, n),
+			freezerTdTable:      getTdRLP(db.Database, hash, n),
+		}
+		// Headers and difficulties are always around, bodies and receipts may
+		// be missing on pruned or partially synced chains
+		if len(items[freezerHeaderTable]) == 0 || len(items[freezerTdTable]) == 0 {
+			break freeze
+		}
+		if err := db.freezer.Append(n, items); err != nil {
+			return uint64(len(hashes)), err
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+	if err := db.freezer.Sync(); err != nil {
+		return 0, err
+	}
+	// The blocks are safely frozen, drop them from the key-value store
+	batch := db.Database.NewBatch()
+	for i, hash := range hashes {
+		deleteFrozen(batch, hash, first+uint64(i))
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return uint64(len(hashes)), err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return uint64(len(hashes)), err
+	}
+	log.Info("Froze ancient blocks", "count", len(hashes), "number", first+uint64(len(hashes))-1, "elapsed", common.PrettyDuration(time.Since(start)))
+	return uint64(len(hashes)), nil
+}
+
+// deleteFrozen removes the key-value entries of a frozen block, keeping its
+// hash to number mapping.
+func deleteFrozen(db DatabaseDeleter, hash common.Hash, number uint64) {
+	DeleteCanonicalHash(db, number)
+	db.Delete(headerKey(hash, number))
+	DeleteTd(db, hash, number)
+	DeleteBody(db, hash, number)
+	DeleteBlockReceipts(db, hash, number)
+}
+
+func getReceiptsRLP(db DatabaseReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	return data
+}
+
+func getTdRLP(db DatabaseReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(append(headerKey(hash, number), tdSuffix...))
+	return data
+}
+
+// canonicalKeyNumber returns the block number of a canonical hash key.
+func canonicalKeyNumber(key []byte) (uint64, bool) {
+	if len(key) != 10 || key[0] != headerPrefix[0] || key[9] != numSuffix[0] {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(key[1:9]), true
+}
+
+// freezerBatch is a batch of a FreezerDatabase, truncating the freezer when
+// written if it removed the canonical hash of a frozen block.
+type freezerBatch struct {
+	aquadb.Batch
+	db       *FreezerDatabase
+	truncate uint64 // Lowest canonical number deleted
+}
+
+func (b *freezerBatch) Delete(key []byte) error {
+	if number, ok := canonicalKeyNumber(key); ok && number < b.truncate {
+		b.truncate = number
+	}
+	return b.Batch.Delete(key)
+}
+
+func (b *freezerBatch) Write() error {
+	if b.truncate != missingNumber {
+		if err := b.db.truncate(b.truncate); err != nil {
+			return err
+		}
+	}
+	return b.Batch.Write()
+}
+
+func (b *freezerBatch) Reset() {
+	b.Batch.Reset()
+	b.truncate = missingNumber
+}
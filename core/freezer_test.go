@@ -0,0 +1,105 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that blocks moved to the freezer are still served by the chain, and that
+// rewinding the chain truncates the freezer.
+func TestFreezerDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aquachain-ancient-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		engine  = aquahash.NewFaker()
+		kvdb    = aquadb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(kvdb)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, kvdb, 10, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	chain, err := NewBlockChain(kvdb, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	db, err := NewFreezerDatabase(kvdb, dir, 4)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	if _, err := db.freeze(freezerBlocksPerCycle); err != nil {
+		t.Fatalf("failed to freeze blocks: %v", err)
+	}
+	if frozen := db.Frozen(); frozen != 7 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 7", frozen)
+	}
+	if has, _ := kvdb.Has(headerKey(blocks[2].Hash(), 3)); has {
+		t.Error("frozen header still in key-value store")
+	}
+	if has, _ := db.Has(headerKey(blocks[2].Hash(), 3)); !has {
+		t.Error("frozen header not found")
+	}
+
+	chain, err = NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	for _, block := range blocks {
+		n := block.NumberU64()
+		if have := chain.GetBlockByNumber(n); have == nil || have.Hash() != block.Hash() {
+			t.Errorf("block %d mismatch: have %v", n, have)
+		}
+		if td := chain.GetTd(block.Hash(), n); td == nil {
+			t.Errorf("block %d: total difficulty missing", n)
+		}
+		if receipts := chain.GetReceiptsByHash(block.Hash()); receipts == nil {
+			t.Errorf("block %d: receipts missing", n)
+		}
+	}
+	if err := chain.SetHead(5); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	chain.Stop()
+
+	if frozen := db.Frozen(); frozen != 6 {
+		t.Errorf("frozen blocks after rewind mismatch: have %d, want 6", frozen)
+	}
+	if hash := GetCanonicalHash(db, 6); hash != (common.Hash{}) {
+		t.Errorf("rewound block still canonical: %x", hash)
+	}
+	if hash := GetCanonicalHash(db, 5); hash != blocks[4].Hash() {
+		t.Errorf("canonical hash of block 5 mismatch: have %x, want %x", hash, blocks[4].Hash())
+	}
+	db.Close()
+}
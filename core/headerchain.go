@@ -27,7 +27,6 @@ import (
 
 	"sync/atomic"
 
-	lru "github.com/hashicorp/golang-lru"
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
@@ -56,9 +55,9 @@ type HeaderChain struct {
 	currentHeader     atomic.Value // Current head of the header chain (may be above the block chain!)
 	currentHeaderHash common.Hash  // Hash of the current head of the header chain (prevent recomputing all the time)
 
-	headerCache *lru.Cache // Cache for the most recent block headers
-	tdCache     *lru.Cache // Cache for the most recent block total difficulties
-	numberCache *lru.Cache // Cache for the most recent block numbers
+	headerCache *chainCache // Cache for the most recent block headers
+	tdCache     *chainCache // Cache for the most recent block total difficulties
+	numberCache *chainCache // Cache for the most recent block numbers
 
 	procInterrupt func() bool
 
@@ -71,10 +70,6 @@ type HeaderChain struct {
 //  procInterrupt points to the parent's interrupt semaphore
 //  wg points to the parent's shutdown wait group
 func NewHeaderChain(chainDb aquadb.Database, config *params.ChainConfig, engine consensus.Engine, procInterrupt func() bool) (*HeaderChain, error) {
-	headerCache, _ := lru.New(headerCacheLimit)
-	tdCache, _ := lru.New(tdCacheLimit)
-	numberCache, _ := lru.New(numberCacheLimit)
-
 	if config == nil {
 		return nil, fmt.Errorf("nil config")
 	}
@@ -87,9 +82,9 @@ func NewHeaderChain(chainDb aquadb.Database, config *params.ChainConfig, engine
 	hc := &HeaderChain{
 		config:        config,
 		chainDb:       chainDb,
-		headerCache:   headerCache,
-		tdCache:       tdCache,
-		numberCache:   numberCache,
+		headerCache:   newChainCache("headers", headerCacheLimit),
+		tdCache:       newChainCache("tds", tdCacheLimit),
+		numberCache:   newChainCache("numbers", numberCacheLimit),
 		procInterrupt: procInterrupt,
 		rand:          mrand.New(mrand.NewSource(seed.Int64())),
 		engine:        engine,
@@ -0,0 +1,199 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+const (
+	// chainStatsWindow is the number of most recent canonical blocks the uncle
+	// rate is computed over.
+	chainStatsWindow = 1024
+
+	// propagationSamples is the number of most recent block arrivals the
+	// propagation delays are computed over.
+	propagationSamples = 256
+
+	// propagationCutoff is the delay above which an imported block is deemed
+	// synced rather than propagated, not counting towards propagation delays.
+	propagationCutoff = 10 * time.Minute
+)
+
+var (
+	uncleRateGauge         = metrics.NewRegisteredGaugeFloat64("chain/uncles/rate", nil)
+	reorgCounter           = metrics.NewRegisteredCounter("chain/reorg/count", nil)
+	reorgDepthHistogram    = metrics.NewRegisteredHistogram("chain/reorg/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+	reorgMaxDepthGauge     = metrics.NewRegisteredGauge("chain/reorg/maxdepth", nil)
+	propagationHistogram   = metrics.NewRegisteredHistogram("chain/propagation", nil, metrics.NewExpDecaySample(1028, 0.015))
+	propagationMedianGauge = metrics.NewRegisteredGauge("chain/propagation/median", nil)
+)
+
+// ChainStats reports on the health of the network as seen by the chain: how
+// often uncles are mined, how deep reorgs go and how long blocks take to
+// arrive.
+type ChainStats struct {
+	Blocks    uint64  `json:"blocks"`    // Recent canonical blocks sampled
+	Uncles    uint64  `json:"uncles"`    // Uncles included by the sampled blocks
+	UncleRate float64 `json:"uncleRate"` // Uncles per sampled block
+
+	Reorgs        uint64            `json:"reorgs"`        // Reorgs since startup
+	ReorgDepths   map[uint64]uint64 `json:"reorgDepths"`   // Reorgs since startup by number of dropped blocks
+	MaxReorgDepth uint64            `json:"maxReorgDepth"` // Deepest reorg since startup
+
+	PropagationSamples int    `json:"propagationSamples"` // Recent block arrivals sampled
+	PropagationMedian  uint64 `json:"propagationMedian"`  // Median delay from block timestamp to import, in ms
+	Propagation95      uint64 `json:"propagation95"`      // 95th percentile of the delays, in ms
+	PropagationMax     uint64 `json:"propagationMax"`     // Longest delay, in ms
+}
+
+// canonStat is the uncle count of a canonical block.
+type canonStat struct {
+	number uint64
+	uncles int
+	set    bool
+}
+
+// chainStats tracks the statistics reported by ChainStats, keeping them in the
+// chain metrics too.
+type chainStats struct {
+	lock sync.Mutex
+
+	canon [chainStatsWindow]canonStat // Canonical blocks by number modulo the window
+
+	reorgs   uint64
+	depths   map[uint64]uint64
+	maxDepth uint64
+
+	delays    [propagationSamples]time.Duration // Ring of recent propagation delays
+	delayNext int                               // Next ring slot to fill
+	delayLen  int                               // Number of ring slots filled
+}
+
+func newChainStats() *chainStats {
+	return &chainStats{depths: make(map[uint64]uint64)}
+}
+
+// canonical records a block made canonical, replacing any block of the same
+// height from an earlier fork.
+func (s *chainStats) canonical(block *types.Block) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	number := block.NumberU64()
+	s.canon[number%chainStatsWindow] = canonStat{number: number, uncles: len(block.Uncles()), set: true}
+
+	blocks, uncles := s.uncles(number)
+	if blocks > 0 {
+		uncleRateGauge.Update(float64(uncles) / float64(blocks))
+	}
+}
+
+// uncles returns the number of canonical blocks known in the window ending at
+// head, and the number of uncles they include.
+func (s *chainStats) uncles(head uint64) (blocks uint64, uncles uint64) {
+	for _, stat := range s.canon {
+		if stat.set && stat.number <= head && stat.number+chainStatsWindow > head {
+			blocks++
+			uncles += uint64(stat.uncles)
+		}
+	}
+	return blocks, uncles
+}
+
+// reorg records a reorg dropping depth canonical blocks.
+func (s *chainStats) reorg(depth uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reorgs++
+	s.depths[depth]++
+	if depth > s.maxDepth {
+		s.maxDepth = depth
+	}
+	reorgCounter.Inc(1)
+	reorgDepthHistogram.Update(int64(depth))
+	reorgMaxDepthGauge.Update(int64(s.maxDepth))
+}
+
+// arrived records the import of a block at the given time, ignoring blocks too
+// old to have been just propagated.
+func (s *chainStats) arrived(block *types.Block, now time.Time) {
+	delay := now.Sub(time.Unix(block.Time().Int64(), 0))
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > propagationCutoff {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.delays[s.delayNext] = delay
+	s.delayNext = (s.delayNext + 1) % propagationSamples
+	if s.delayLen < propagationSamples {
+		s.delayLen++
+	}
+	propagationHistogram.Update(int64(delay / time.Millisecond))
+	propagationMedianGauge.Update(int64(s.sortedDelays()[s.delayLen/2] / time.Millisecond))
+}
+
+// sortedDelays returns the recorded propagation delays in increasing order.
+func (s *chainStats) sortedDelays() []time.Duration {
+	delays := make([]time.Duration, s.delayLen)
+	copy(delays, s.delays[:s.delayLen])
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	return delays
+}
+
+// stats returns the statistics of the chain with the given head.
+func (s *chainStats) stats(head uint64) ChainStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := ChainStats{
+		Reorgs:             s.reorgs,
+		ReorgDepths:        make(map[uint64]uint64, len(s.depths)),
+		MaxReorgDepth:      s.maxDepth,
+		PropagationSamples: s.delayLen,
+	}
+	stats.Blocks, stats.Uncles = s.uncles(head)
+	if stats.Blocks > 0 {
+		stats.UncleRate = float64(stats.Uncles) / float64(stats.Blocks)
+	}
+	for depth, count := range s.depths {
+		stats.ReorgDepths[depth] = count
+	}
+	if delays := s.sortedDelays(); len(delays) > 0 {
+		stats.PropagationMedian = uint64(delays[len(delays)/2] / time.Millisecond)
+		stats.Propagation95 = uint64(delays[len(delays)*95/100] / time.Millisecond)
+		stats.PropagationMax = uint64(delays[len(delays)-1] / time.Millisecond)
+	}
+	return stats
+}
+
+// ChainStats returns the uncle rate over the most recent canonical blocks, the
+// reorgs seen since startup and the propagation delays of the most recent
+// blocks.
+func (bc *BlockChain) ChainStats() ChainStats {
+	return bc.stats.stats(bc.CurrentBlock().NumberU64())
+}
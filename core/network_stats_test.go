@@ -0,0 +1,95 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that the chain statistics follow the uncles of the canonical chain
+// across reorgs, and record the depth of the reorgs.
+func TestNetworkStatsUnclesAndReorgs(t *testing.T) {
+	var (
+		engine  = aquahash.NewFaker()
+		db      = aquadb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	uncles, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 4, func(i int, b *BlockGen) {
+		if i == 1 {
+			b.AddUncle(uncles[0].Header())
+		}
+	})
+	forks, _ := GenerateChain(gspec.Config, blocks[1], engine, db, 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	stats := chain.ChainStats()
+	if stats.Blocks != 4 || stats.Uncles != 1 || stats.UncleRate != 0.25 || stats.Reorgs != 0 {
+		t.Errorf("stats mismatch before reorg: %+v", stats)
+	}
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	stats = chain.ChainStats()
+	if stats.Blocks != 5 || stats.Uncles != 1 {
+		t.Errorf("uncle stats mismatch after reorg: %d blocks, %d uncles", stats.Blocks, stats.Uncles)
+	}
+	if stats.Reorgs != 1 || stats.MaxReorgDepth != 2 || stats.ReorgDepths[2] != 1 {
+		t.Errorf("reorg stats mismatch: %+v", stats)
+	}
+}
+
+// Tests that propagation delays are measured from the block timestamps, leaving
+// out blocks too old to have just been propagated.
+func TestNetworkStatsPropagation(t *testing.T) {
+	var (
+		stats = newChainStats()
+		now   = time.Unix(time.Now().Unix(), 0)
+	)
+	for _, delay := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second, time.Hour} {
+		header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(now.Add(-delay).Unix())}
+		stats.arrived(types.NewBlockWithHeader(header), now)
+	}
+	have := stats.stats(1)
+	if have.PropagationSamples != 3 {
+		t.Fatalf("sample count mismatch: have %d, want 3", have.PropagationSamples)
+	}
+	if have.PropagationMedian != 2000 || have.PropagationMax != 3000 {
+		t.Errorf("delays mismatch: median %dms, max %dms", have.PropagationMedian, have.PropagationMax)
+	}
+}
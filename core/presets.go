@@ -0,0 +1,158 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+var errGenesisNoChainId = errors.New("genesis has no chain id")
+
+// NetworkPreset holds what a node needs to join a network, selected by its
+// network id. Besides the built-in networks, presets of private networks can be
+// loaded from JSON files, so that they don't need patching the chain configs.
+type NetworkPreset struct {
+	Name      string   `json:"name"`
+	NetworkId uint64   `json:"networkId"`
+	Genesis   *Genesis `json:"genesis"`
+	Bootnodes []string `json:"bootnodes,omitempty"`
+
+	makeGenesis func() *Genesis // Lazy genesis of the built-in presets
+}
+
+// GenesisBlock returns the genesis of the network.
+func (p *NetworkPreset) GenesisBlock() *Genesis {
+	if p.Genesis == nil && p.makeGenesis != nil {
+		return p.makeGenesis()
+	}
+	return p.Genesis
+}
+
+var (
+	presetsLock sync.RWMutex
+	presets     = make(map[uint64]*NetworkPreset)
+)
+
+func init() {
+	for _, preset := range []*NetworkPreset{
+		{Name: "mainnet", NetworkId: params.MainnetChainConfig.ChainId.Uint64(), Bootnodes: params.MainnetBootnodes, makeGenesis: DefaultGenesisBlock},
+		{Name: "testnet", NetworkId: params.TestnetChainConfig.ChainId.Uint64(), Bootnodes: params.TestnetBootnodes, makeGenesis: DefaultTestnetGenesisBlock},
+		{Name: "testnet2", NetworkId: params.Testnet2ChainConfig.ChainId.Uint64(), Bootnodes: params.Testnet2Bootnodes, makeGenesis: DefaultTestnet2GenesisBlock},
+	} {
+		presets[preset.NetworkId] = preset
+	}
+}
+
+// RegisterNetworkPreset adds a network preset, which must have a genesis and a
+// network id not taken by another preset.
+func RegisterNetworkPreset(preset *NetworkPreset) error {
+	if preset.NetworkId == 0 {
+		return fmt.Errorf("network preset %q: no network id", preset.Name)
+	}
+	if err := checkGenesis(preset.Genesis); err != nil {
+		return fmt.Errorf("network preset %q: %v", preset.Name, err)
+	}
+	presetsLock.Lock()
+	defer presetsLock.Unlock()
+
+	if have, ok := presets[preset.NetworkId]; ok {
+		return fmt.Errorf("network preset %q: network id %d taken by %q", preset.Name, preset.NetworkId, have.Name)
+	}
+	presets[preset.NetworkId] = preset
+	return nil
+}
+
+// NetworkPresetByID returns the preset of the network with the given id, nil if
+// there's none.
+func NetworkPresetByID(id uint64) *NetworkPreset {
+	presetsLock.RLock()
+	defer presetsLock.RUnlock()
+
+	return presets[id]
+}
+
+// NetworkPresets returns all the network presets, sorted by network id.
+func NetworkPresets() []*NetworkPreset {
+	presetsLock.RLock()
+	defer presetsLock.RUnlock()
+
+	list := make([]*NetworkPreset, 0, len(presets))
+	for _, preset := range presets {
+		list = append(list, preset)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NetworkId < list[j].NetworkId })
+	return list
+}
+
+// LoadNetworkPresets reads a JSON array of network presets from file and
+// registers them.
+func LoadNetworkPresets(file string) ([]*NetworkPreset, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []*NetworkPreset
+	if err := json.NewDecoder(f).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid network presets file: %v", err)
+	}
+	for _, preset := range list {
+		if err := RegisterNetworkPreset(preset); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// LoadGenesis reads a JSON genesis from file.
+func LoadGenesis(file string) (*Genesis, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	genesis := new(Genesis)
+	if err := json.NewDecoder(f).Decode(genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	if err := checkGenesis(genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	return genesis, nil
+}
+
+// checkGenesis makes sure a custom genesis has a chain configuration with an id.
+func checkGenesis(genesis *Genesis) error {
+	switch {
+	case genesis == nil:
+		return errors.New("no genesis")
+	case genesis.Config == nil:
+		return errGenesisNoConfig
+	case genesis.Config.ChainId == nil || genesis.Config.ChainId.Sign() <= 0:
+		return errGenesisNoChainId
+	}
+	return nil
+}
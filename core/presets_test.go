@@ -0,0 +1,72 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that network presets load from JSON and are found by network id, and
+// that incomplete or clashing presets are refused.
+func TestNetworkPresets(t *testing.T) {
+	if preset := NetworkPresetByID(params.TestnetChainConfig.ChainId.Uint64()); preset == nil || preset.GenesisBlock().ToBlock(nil).Hash() != DefaultTestnetGenesisBlock().ToBlock(nil).Hash() {
+		t.Fatalf("built-in testnet preset missing or wrong")
+	}
+	dir, err := ioutil.TempDir("", "aquachain-presets-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	file := write("presets.json", `[{
+		"name": "private",
+		"networkId": 7771,
+		"bootnodes": ["enode://0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000@127.0.0.1:21303"],
+		"genesis": {"alloc": {}, "difficulty": "0x20000", "gasLimit": "0x2fefd8", "config": {"chainId": 7771}}
+	}]`)
+	if _, err := LoadNetworkPresets(file); err != nil {
+		t.Fatalf("failed to load presets: %v", err)
+	}
+	preset := NetworkPresetByID(7771)
+	if preset == nil || preset.Name != "private" || len(preset.Bootnodes) != 1 || preset.GenesisBlock().Config.ChainId.Uint64() != 7771 {
+		t.Fatalf("loaded preset mismatch: %+v", preset)
+	}
+	// Loading the same network again clashes
+	if _, err := LoadNetworkPresets(file); err == nil {
+		t.Errorf("clashing preset registered")
+	}
+	// Presets and genesis files need a chain id
+	noid := `{"alloc": {}, "difficulty": "0x20000", "gasLimit": "0x2fefd8", "config": {}}`
+	if _, err := LoadNetworkPresets(write("noid.json", `[{"name": "noid", "networkId": 7772, "genesis": `+noid+`}]`)); err == nil {
+		t.Errorf("preset without chain id registered")
+	}
+	if _, err := LoadGenesis(write("genesis.json", noid)); err == nil {
+		t.Errorf("genesis without chain id loaded")
+	}
+}
@@ -88,6 +88,8 @@ type cachingDB struct {
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
+
+	flat FlatState // Optional flat state to read from, see SetFlatState
 }
 
 // OpenTrie opens the main account trie.
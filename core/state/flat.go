@@ -0,0 +1,121 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"gitlab.com/aquachain/aquachain/common"
+)
+
+// FlatState gives direct access to the accounts and storage slots of a state,
+// sparing the trie traversals. Keys are the hashes used by the secure tries,
+// values their trie encoding. Lookups it can't serve, e.g. of other states or
+// of parts not flattened yet, return false and are served by the tries.
+type FlatState interface {
+	// Account returns the encoded account with the given hash in the state
+	// with the given root, nil if there's no such account.
+	Account(root, hash common.Hash) ([]byte, bool)
+
+	// Storage returns the encoded value of a storage slot of an account in the
+	// state with the given root, nil if the slot is empty.
+	Storage(root, accountHash, slotHash common.Hash) ([]byte, bool)
+}
+
+// SetFlatState makes the states opened from db read from flat when they can. It
+// only applies to databases created by NewDatabase.
+func SetFlatState(db Database, flat FlatState) {
+	if db, ok := db.(*cachingDB); ok {
+		db.flat = flat
+	}
+}
+
+// StateDiff holds the changes made to the accounts and storage slots of a state,
+// keyed by the hashes used by the secure tries. It's used to keep a FlatState
+// up to date.
+type StateDiff struct {
+	// Destructs are the accounts deleted or recreated, whose storage is wiped
+	// before applying the changes below.
+	Destructs map[common.Hash]struct{}
+
+	// Accounts are the new encoded accounts, nil for deleted ones.
+	Accounts map[common.Hash][]byte
+
+	// Storage are the new encoded values of storage slots by account, nil for
+	// emptied ones.
+	Storage map[common.Hash]map[common.Hash][]byte
+}
+
+func newStateDiff() *StateDiff {
+	return &StateDiff{
+		Destructs: make(map[common.Hash]struct{}),
+		Accounts:  make(map[common.Hash][]byte),
+		Storage:   make(map[common.Hash]map[common.Hash][]byte),
+	}
+}
+
+// copy returns a deep copy of the diff.
+func (d *StateDiff) copy() *StateDiff {
+	cpy := newStateDiff()
+	for hash := range d.Destructs {
+		cpy.Destructs[hash] = struct{}{}
+	}
+	for hash, data := range d.Accounts {
+		cpy.Accounts[hash] = data
+	}
+	for hash, slots := range d.Storage {
+		cpy.Storage[hash] = make(map[common.Hash][]byte, len(slots))
+		for slot, data := range slots {
+			cpy.Storage[hash][slot] = data
+		}
+	}
+	return cpy
+}
+
+// destruct records the deletion or recreation of an account, dropping its
+// changes recorded so far.
+func (d *StateDiff) destruct(hash common.Hash) {
+	d.Destructs[hash] = struct{}{}
+	d.Accounts[hash] = nil
+	delete(d.Storage, hash)
+}
+
+// setStorage records the new value of a storage slot.
+func (d *StateDiff) setStorage(hash, slot common.Hash, data []byte) {
+	slots := d.Storage[hash]
+	if slots == nil {
+		slots = make(map[common.Hash][]byte)
+		d.Storage[hash] = slots
+	}
+	slots[slot] = data
+}
+
+// flatAccount reports whether the flat state can be used to read an account,
+// which is the case if it wasn't changed since the state was opened.
+func (d *StateDiff) flatAccount(hash common.Hash) bool {
+	_, changed := d.Accounts[hash]
+	return !changed
+}
+
+// flatStorage reports whether the flat state can be used to read a storage
+// slot, which is the case if neither the slot was changed nor the account was
+// recreated since the state was opened.
+func (d *StateDiff) flatStorage(hash, slot common.Hash) bool {
+	if _, destructed := d.Destructs[hash]; destructed {
+		return false
+	}
+	_, changed := d.Storage[hash][slot]
+	return !changed
+}
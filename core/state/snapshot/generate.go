@@ -0,0 +1,357 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/rlp"
+	"gitlab.com/aquachain/aquachain/trie"
+)
+
+// generateChunk is the number of accounts generated in one pass of the
+// generation loop. The lock is only held to commit the generated entries, in
+// batches of about aquadb.IdealBatchSize or one account with storage.
+const generateChunk = 1024
+
+// generate starts generating the snapshot in the background. The caller must
+// hold the lock or be the only user of the snapshot.
+func (s *Snapshot) generate() {
+	s.generating = true
+	s.wg.Add(1)
+	go s.generateLoop()
+}
+
+// generateLoop wipes the leftovers of earlier snapshots, then flattens the
+// state trie a chunk at a time, following the head the snapshot moves to.
+func (s *Snapshot) generateLoop() {
+	defer s.wg.Done()
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for {
+		select {
+		case <-s.quit:
+			s.stopGenerating()
+			return
+		default:
+		}
+		s.lock.RLock()
+		wiped := s.meta.Wiped
+		s.lock.RUnlock()
+
+		var err error
+		if !wiped {
+			err = s.wipe()
+		} else {
+			err = s.generateAccounts()
+		}
+		if err != nil {
+			log.Error("State snapshot generation failed", "err", err)
+			s.stopGenerating()
+			return
+		}
+		s.lock.Lock()
+		if s.meta.Done {
+			log.Info("Generated state snapshot", "number", s.meta.Number, "hash", s.meta.Block, "elapsed", common.PrettyDuration(time.Since(start)))
+			s.generating = false
+			s.lock.Unlock()
+			return
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "number", s.meta.Number, "marker", common.BytesToHash(s.meta.Marker), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (s *Snapshot) stopGenerating() {
+	s.lock.Lock()
+	s.generating = false
+	s.lock.Unlock()
+}
+
+// wipe deletes all the snapshot entries in the database. As the snapshot
+// doesn't write any until wiped, the walk needs no locking.
+func (s *Snapshot) wipe() error {
+	if err := aquadb.Flush(s.diskdb); err != nil {
+		return err
+	}
+	var keys [][]byte
+	err := aquadb.Unwrap(s.diskdb).(aquadb.Walker).Walk(func(key, value []byte) error {
+		if isAccountKey(key) || isStorageKey(key) {
+			keys = append(keys, common.CopyBytes(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := s.diskdb.NewBatch()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	next := s.meta
+	next.Wiped, next.Marker = true, nil
+	if len(keys) > 0 {
+		log.Info("Wiped stale state snapshot", "entries", len(keys))
+	}
+	return s.commit(batch, next)
+}
+
+// generateAccounts flattens the next chunk of accounts after the marker, with
+// their storage. The tries are read without holding the lock, so that chain
+// head updates go on meanwhile; the generated entries are only committed if
+// the snapshot still holds the state they were read from, otherwise the chunk
+// is dropped and generated again from the new state.
+func (s *Snapshot) generateAccounts() error {
+	s.lock.RLock()
+	root, marker := s.meta.Root, s.meta.Marker
+	s.lock.RUnlock()
+
+	tr, err := trie.New(root, s.triedb.TrieDB())
+	if err != nil {
+		return s.generateError(root, err)
+	}
+	var (
+		batch = s.diskdb.NewBatch()
+		it    = trie.NewIterator(tr.NodeIterator(marker))
+		last  []byte // Last account in the batch
+		count int
+	)
+	for count < generateChunk && it.Next() {
+		if bytes.Equal(it.Key, marker) {
+			continue
+		}
+		hash := common.BytesToHash(it.Key)
+		var account state.Account
+		if err := rlp.DecodeBytes(it.Value, &account); err != nil {
+			return err
+		}
+		count++
+
+		// Accounts with storage are generated and committed on their own
+		if account.Root != emptyRoot {
+			if last != nil {
+				if ok, err := s.commitGenerated(batch, root, last, false); !ok || err != nil {
+					return err
+				}
+				batch, last = s.diskdb.NewBatch(), nil
+			}
+			if ok, err := s.generateStorage(root, hash, common.CopyBytes(it.Value), account.Root); !ok || err != nil {
+				return err
+			}
+			continue
+		}
+		if err := batch.Put(accountKey(hash), common.CopyBytes(it.Value)); err != nil {
+			return err
+		}
+		last = hash.Bytes()
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if ok, err := s.commitGenerated(batch, root, last, false); !ok || err != nil {
+				return err
+			}
+			batch, last = s.diskdb.NewBatch(), nil
+		}
+	}
+	if it.Err != nil {
+		return s.generateError(root, it.Err)
+	}
+	_, err = s.commitGenerated(batch, root, last, count < generateChunk)
+	return err
+}
+
+// generateStorage flattens an account along with its storage, reporting
+// whether it was committed. Large storage is written in several batches ahead
+// of the commit, which are deleted again if the account changed meanwhile.
+func (s *Snapshot) generateStorage(root, hash common.Hash, data []byte, storageRoot common.Hash) (bool, error) {
+	storage, err := trie.New(storageRoot, s.triedb.TrieDB())
+	if err != nil {
+		return false, s.generateError(root, err)
+	}
+	batch := s.diskdb.NewBatch()
+	if err := batch.Put(accountKey(hash), data); err != nil {
+		return false, err
+	}
+	var (
+		it      = trie.NewIterator(storage.NodeIterator(nil))
+		written bool // Whether entries were written ahead of the commit
+	)
+	for it.Next() {
+		if err := batch.Put(storageKey(hash, common.BytesToHash(it.Key)), common.CopyBytes(it.Value)); err != nil {
+			return false, err
+		}
+		if batch.ValueSize() < aquadb.IdealBatchSize {
+			continue
+		}
+		if !written {
+			// Entries past the marker are written before it moves, so flag the
+			// snapshot on disk as needing a wipe should the account not complete.
+			s.lock.Lock()
+			s.partial = true
+			err = s.commit(batch, s.meta)
+			s.lock.Unlock()
+			written = true
+		} else {
+			err = batch.Write()
+		}
+		if err != nil {
+			return false, err
+		}
+		batch.Reset()
+	}
+	if it.Err != nil {
+		if err := s.generateError(root, it.Err); err != nil {
+			return false, err
+		}
+	} else if ok, err := s.commitAccount(batch, root, hash, data); ok || err != nil {
+		return ok, err
+	}
+	// The account changed while generated, delete what was written of it
+	if written {
+		if err := s.dropStorage(hash, storageRoot); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// commitAccount commits a generated account with storage, moving the marker
+// onto it, unless the head moved meanwhile and the account is no longer the
+// one next to the marker in the new state.
+func (s *Snapshot) commitAccount(batch aquadb.Batch, root, hash common.Hash, data []byte) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.meta.Wiped {
+		return false, nil
+	}
+	if s.meta.Root != root {
+		tr, err := trie.New(s.meta.Root, s.triedb.TrieDB())
+		if err != nil {
+			return false, nil
+		}
+		it := trie.NewIterator(tr.NodeIterator(s.meta.Marker))
+		for it.Next() && bytes.Equal(it.Key, s.meta.Marker) {
+		}
+		if it.Err != nil || !bytes.Equal(it.Key, hash[:]) || !bytes.Equal(it.Value, data) {
+			return false, nil
+		}
+	}
+	s.partial = false
+	next := s.meta
+	next.Marker = hash.Bytes()
+	return true, s.commit(batch, next)
+}
+
+// dropStorage deletes the entries of an account written ahead of a commit that
+// failed, then clears the wipe flag on disk again. If the storage can't be read
+// any more, the snapshot is wiped and generated anew.
+func (s *Snapshot) dropStorage(hash, storageRoot common.Hash) error {
+	batch := s.diskdb.NewBatch()
+	err := func() error {
+		if err := batch.Delete(accountKey(hash)); err != nil {
+			return err
+		}
+		storage, err := trie.New(storageRoot, s.triedb.TrieDB())
+		if err != nil {
+			return err
+		}
+		it := trie.NewIterator(storage.NodeIterator(nil))
+		for it.Next() {
+			if err := batch.Delete(storageKey(hash, common.BytesToHash(it.Key))); err != nil {
+				return err
+			}
+			if batch.ValueSize() >= aquadb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		return it.Err
+	}()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.partial = false
+	next := s.meta
+	if err != nil {
+		log.Warn("Failed to drop partially generated snapshot storage", "account", hash, "err", err)
+		next.Wiped, next.Done, next.Marker = false, false, nil
+		batch.Reset()
+	}
+	return s.commit(batch, next)
+}
+
+// commitGenerated commits a batch of generated accounts, moving the marker to
+// last if set, unless the head moved meanwhile. It reports whether the batch
+// was committed.
+func (s *Snapshot) commitGenerated(batch aquadb.Batch, root common.Hash, last []byte, done bool) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.meta.Wiped || s.meta.Root != root {
+		return false, nil
+	}
+	next := s.meta
+	if last != nil {
+		next.Marker = last
+	}
+	next.Done = done
+	return true, s.commit(batch, next)
+}
+
+// generateError filters the errors of reading the tries of the state with the
+// given root: if the head moved meanwhile, they're dropped along with the
+// chunk, as the state may have been pruned since.
+func (s *Snapshot) generateError(root common.Hash, err error) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.meta.Root != root {
+		return nil
+	}
+	return err
+}
+
+func isAccountKey(key []byte) bool {
+	return len(key) == len(AccountPrefix)+common.HashLength && bytes.HasPrefix(key, AccountPrefix)
+}
+
+func isStorageKey(key []byte) bool {
+	return len(key) == len(StoragePrefix)+2*common.HashLength && bytes.HasPrefix(key, StoragePrefix)
+}
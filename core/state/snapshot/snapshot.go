@@ -0,0 +1,473 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot maintains a flat copy of the latest state, serving account
+// and storage reads with a single database lookup instead of a trie traversal.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/rlp"
+	"gitlab.com/aquachain/aquachain/trie"
+)
+
+var (
+	// AccountPrefix + account hash -> account, encoded as in the state trie.
+	AccountPrefix = []byte("A")
+
+	// StoragePrefix + account hash + slot hash -> slot, encoded as in the
+	// storage trie.
+	StoragePrefix = []byte("O")
+
+	// metaKey tracks the state the snapshot holds and its generation progress.
+	metaKey = []byte("SnapshotMeta")
+
+	// emptyRoot is the root of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+)
+
+var (
+	hitMeter  = metrics.NewRegisteredMeter("state/snapshot/hit", nil)
+	missMeter = metrics.NewRegisteredMeter("state/snapshot/miss", nil)
+)
+
+var (
+	// ErrUnsupported is returned if the database can't enumerate its entries,
+	// needed to wipe a stale snapshot.
+	ErrUnsupported = errors.New("database iteration unsupported")
+
+	errMissingHeader = errors.New("missing header")
+	errMissingDiff   = errors.New("missing state diff")
+	errTooDeep       = errors.New("reorg too deep")
+)
+
+// maxDiffs is the number of recent block diffs kept to follow the chain head
+// across reorgs without regenerating the snapshot.
+const maxDiffs = 128
+
+// HeaderReader retrieves headers of the chain the snapshot follows.
+type HeaderReader interface {
+	GetHeader(hash common.Hash, number uint64) *types.Header
+}
+
+// meta is the persisted state of the snapshot.
+type meta struct {
+	Block  common.Hash // Block whose state the snapshot holds
+	Number uint64      // Number of that block
+	Root   common.Hash // State root of that block
+	Wiped  bool        // Whether leftovers of earlier snapshots were deleted
+	Done   bool        // Whether the snapshot was fully generated
+	Marker []byte      // Last account generated, with all its storage
+}
+
+// blockDiff is the changes made by a block, kept along with its header as rewinds
+// delete the headers before the snapshot reverts them.
+type blockDiff struct {
+	header  *types.Header
+	changes *state.StateDiff
+}
+
+// Snapshot is a flat copy of the state of a block, moved along with the chain
+// head by applying the changes recorded for the blocks. Until generated in the
+// background from the state trie, it only serves the accounts up to the
+// generation marker. It implements state.FlatState.
+type Snapshot struct {
+	diskdb aquadb.Database
+	triedb state.Database
+
+	lock  sync.RWMutex
+	meta  meta
+	diffs map[common.Hash]*blockDiff // Changes made by recent blocks
+	order []common.Hash              // Blocks of the diffs, oldest first
+
+	generating bool
+	partial    bool // Whether storage was written past the marker, persisted as unwiped
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New opens the snapshot stored in diskdb, regenerating it from the tries of
+// triedb unless it holds the state of head.
+func New(diskdb aquadb.Database, triedb state.Database, head *types.Header) (*Snapshot, error) {
+	if _, ok := aquadb.Unwrap(diskdb).(aquadb.Walker); !ok {
+		return nil, ErrUnsupported
+	}
+	s := &Snapshot{
+		diskdb: diskdb,
+		triedb: triedb,
+		diffs:  make(map[common.Hash]*blockDiff),
+		quit:   make(chan struct{}),
+	}
+	if data, err := diskdb.Get(metaKey); err == nil {
+		if err := rlp.DecodeBytes(data, &s.meta); err != nil {
+			log.Warn("Invalid state snapshot metadata", "err", err)
+			s.meta = meta{}
+		}
+	}
+	if s.meta.Block != head.Hash() {
+		if err := s.reset(head); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Info("Loaded state snapshot", "number", s.meta.Number, "hash", s.meta.Block, "done", s.meta.Done)
+	}
+	if !s.meta.Done {
+		s.generate()
+	}
+	return s, nil
+}
+
+// Close stops the snapshot generation. The snapshot stays valid and resumes
+// from where it stopped when opened again.
+func (s *Snapshot) Close() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// Generated reports whether the snapshot was fully generated, serving all the
+// reads of the state it holds.
+func (s *Snapshot) Generated() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.meta.Wiped && s.meta.Done
+}
+
+// Account implements state.FlatState.
+func (s *Snapshot) Account(root, hash common.Hash) ([]byte, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if root != s.meta.Root || !s.covers(hash) {
+		missMeter.Mark(1)
+		return nil, false
+	}
+	hitMeter.Mark(1)
+	data, err := s.diskdb.Get(accountKey(hash))
+	if err != nil {
+		return nil, true
+	}
+	return data, true
+}
+
+// Storage implements state.FlatState.
+func (s *Snapshot) Storage(root, accountHash, slotHash common.Hash) ([]byte, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if root != s.meta.Root || !s.covers(accountHash) {
+		missMeter.Mark(1)
+		return nil, false
+	}
+	hitMeter.Mark(1)
+	data, err := s.diskdb.Get(storageKey(accountHash, slotHash))
+	if err != nil {
+		return nil, true
+	}
+	return data, true
+}
+
+// Record keeps the changes made by a block, to apply them once the block
+// becomes the chain head.
+func (s *Snapshot) Record(header *types.Header, changes *state.StateDiff) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hash := header.Hash()
+	if _, ok := s.diffs[hash]; ok {
+		return
+	}
+	s.diffs[hash] = &blockDiff{header: header, changes: changes}
+	s.order = append(s.order, hash)
+	if len(s.order) > maxDiffs {
+		delete(s.diffs, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Update moves the snapshot to the state of head, reverting and applying the
+// recorded block changes. If it can't, e.g. after a reorg deeper than the
+// changes kept, the snapshot is regenerated.
+func (s *Snapshot) Update(head *types.Header, chain HeaderReader) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if head.Hash() == s.meta.Block {
+		return
+	}
+	err := s.follow(head, chain)
+	if err == nil {
+		return
+	}
+	log.Info("Regenerating state snapshot", "number", head.Number, "hash", head.Hash(), "err", err)
+	if err := s.reset(head); err != nil {
+		log.Error("Failed to reset state snapshot", "err", err)
+		return
+	}
+	if !s.generating {
+		s.generate()
+	}
+}
+
+// covers reports whether the snapshot holds the account with the given hash,
+// along with its storage.
+func (s *Snapshot) covers(hash common.Hash) bool {
+	return s.meta.Wiped && (s.meta.Done || bytes.Compare(hash[:], s.meta.Marker) <= 0)
+}
+
+// follow moves the snapshot from its block to head through their common
+// ancestor.
+func (s *Snapshot) follow(head *types.Header, chain HeaderReader) error {
+	oldHead := s.header(chain, s.meta.Block, s.meta.Number)
+	if oldHead == nil {
+		return errMissingHeader
+	}
+	var (
+		newHead  = head
+		forward  []*types.Header // Blocks to apply, newest first
+		backward []*types.Header // Blocks to revert, newest first
+	)
+	for newHead != nil && oldHead != nil && newHead.Hash() != oldHead.Hash() {
+		if len(forward)+len(backward) > maxDiffs {
+			return errTooDeep
+		}
+		if newHead.Number.Uint64() >= oldHead.Number.Uint64() {
+			forward = append(forward, newHead)
+			newHead = chain.GetHeader(newHead.ParentHash, newHead.Number.Uint64()-1)
+		} else {
+			backward = append(backward, oldHead)
+			oldHead = s.header(chain, oldHead.ParentHash, oldHead.Number.Uint64()-1)
+		}
+	}
+	if newHead == nil || oldHead == nil {
+		return errMissingHeader
+	}
+	for _, header := range backward {
+		diff := s.diffs[header.Hash()]
+		if diff == nil {
+			return errMissingDiff
+		}
+		parent := s.header(chain, header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return errMissingHeader
+		}
+		reverse, err := s.reverse(diff.changes, parent.Root)
+		if err != nil {
+			return err
+		}
+		if err := s.apply(parent, reverse); err != nil {
+			return err
+		}
+	}
+	for i := len(forward) - 1; i >= 0; i-- {
+		diff := s.diffs[forward[i].Hash()]
+		if diff == nil {
+			return errMissingDiff
+		}
+		if err := s.apply(forward[i], diff.changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// header retrieves a header from the chain, or from the recorded diffs if
+// it was rewound.
+func (s *Snapshot) header(chain HeaderReader, hash common.Hash, number uint64) *types.Header {
+	if header := chain.GetHeader(hash, number); header != nil {
+		return header
+	}
+	if diff := s.diffs[hash]; diff != nil {
+		return diff.header
+	}
+	return nil
+}
+
+// reverse returns the diff undoing the given one, reading the prior values
+// from the state with the given root.
+func (s *Snapshot) reverse(diff *state.StateDiff, root common.Hash) (*state.StateDiff, error) {
+	tr, err := trie.New(root, s.triedb.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	reverse := &state.StateDiff{
+		Destructs: diff.Destructs,
+		Accounts:  make(map[common.Hash][]byte),
+		Storage:   make(map[common.Hash]map[common.Hash][]byte),
+	}
+	for hash := range diff.Accounts {
+		data, err := tr.TryGet(hash[:])
+		if err != nil {
+			return nil, err
+		}
+		reverse.Accounts[hash] = data
+		if len(data) == 0 {
+			continue
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(data, &account); err != nil {
+			return nil, err
+		}
+		if account.Root == emptyRoot {
+			continue
+		}
+		storage, err := trie.New(account.Root, s.triedb.TrieDB())
+		if err != nil {
+			return nil, err
+		}
+		slots := make(map[common.Hash][]byte)
+		if _, destructed := diff.Destructs[hash]; destructed {
+			// The whole storage is wiped, restore all of it
+			it := trie.NewIterator(storage.NodeIterator(nil))
+			for it.Next() {
+				slots[common.BytesToHash(it.Key)] = common.CopyBytes(it.Value)
+			}
+			if it.Err != nil {
+				return nil, it.Err
+			}
+		} else {
+			for slot := range diff.Storage[hash] {
+				data, err := storage.TryGet(slot[:])
+				if err != nil {
+					return nil, err
+				}
+				slots[slot] = data
+			}
+		}
+		reverse.Storage[hash] = slots
+	}
+	return reverse, nil
+}
+
+// apply writes the changes made by the block with the given header into the
+// snapshot, which must hold the state of its parent.
+func (s *Snapshot) apply(header *types.Header, diff *state.StateDiff) error {
+	batch := s.diskdb.NewBatch()
+	for hash := range diff.Destructs {
+		if !s.covers(hash) {
+			continue
+		}
+		if err := s.wipeStorage(batch, hash); err != nil {
+			return err
+		}
+	}
+	for hash, data := range diff.Accounts {
+		if !s.covers(hash) {
+			continue
+		}
+		if err := put(batch, accountKey(hash), data); err != nil {
+			return err
+		}
+	}
+	for hash, slots := range diff.Storage {
+		if !s.covers(hash) {
+			continue
+		}
+		for slot, data := range slots {
+			if err := put(batch, storageKey(hash, slot), data); err != nil {
+				return err
+			}
+		}
+	}
+	next := s.meta
+	next.Block, next.Number, next.Root = header.Hash(), header.Number.Uint64(), header.Root
+	return s.commit(batch, next)
+}
+
+// wipeStorage deletes the storage of an account held by the snapshot.
+func (s *Snapshot) wipeStorage(batch aquadb.Batch, hash common.Hash) error {
+	data, err := s.diskdb.Get(accountKey(hash))
+	if err != nil {
+		return nil
+	}
+	var account state.Account
+	if err := rlp.DecodeBytes(data, &account); err != nil {
+		return err
+	}
+	if account.Root == emptyRoot {
+		return nil
+	}
+	storage, err := trie.New(account.Root, s.triedb.TrieDB())
+	if err != nil {
+		return err
+	}
+	it := trie.NewIterator(storage.NodeIterator(nil))
+	for it.Next() {
+		if err := batch.Delete(storageKey(hash, common.BytesToHash(it.Key))); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
+// reset points the snapshot at the state of head, to be generated anew.
+func (s *Snapshot) reset(head *types.Header) error {
+	return s.commit(s.diskdb.NewBatch(), meta{
+		Block:  head.Hash(),
+		Number: head.Number.Uint64(),
+		Root:   head.Root,
+	})
+}
+
+// commit writes the batch along with the new snapshot metadata. While storage
+// is written past the marker, the metadata is stored as unwiped, so that the
+// entries are deleted if the generation is interrupted by a crash.
+func (s *Snapshot) commit(batch aquadb.Batch, next meta) error {
+	stored := next
+	if s.partial {
+		stored.Wiped = false
+	}
+	data, err := rlp.EncodeToBytes(&stored)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(metaKey, data); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	s.meta = next
+	return nil
+}
+
+// put writes the value of a snapshot entry, deleting it if empty.
+func put(batch aquadb.Batch, key, data []byte) error {
+	if len(data) == 0 {
+		return batch.Delete(key)
+	}
+	return batch.Put(key, data)
+}
+
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, AccountPrefix...), hash[:]...)
+}
+
+func storageKey(accountHash, slotHash common.Hash) []byte {
+	key := make([]byte, 0, len(StoragePrefix)+2*common.HashLength)
+	key = append(key, StoragePrefix...)
+	key = append(key, accountHash[:]...)
+	return append(key, slotHash[:]...)
+}
@@ -0,0 +1,307 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/rlp"
+	"gitlab.com/aquachain/aquachain/trie"
+)
+
+// testChain is a HeaderReader over a set of headers.
+type testChain map[common.Hash]*types.Header
+
+func (c testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c[hash]
+}
+
+func (c testChain) add(parent *types.Header, root common.Hash) *types.Header {
+	header := &types.Header{Root: root, Number: big.NewInt(0), Extra: []byte{byte(len(c))}, Version: types.H_KECCAK256}
+	if parent != nil {
+		header.ParentHash = parent.Hash()
+		header.Number = new(big.Int).Add(parent.Number, common.Big1)
+	}
+	c[header.Hash()] = header
+	return header
+}
+
+// waitGenerated waits for the snapshot generation to complete.
+func waitGenerated(t *testing.T, s *Snapshot) {
+	for start := time.Now(); !s.Generated(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("snapshot generation timed out")
+		}
+	}
+}
+
+// checkSnapshot verifies that the snapshot in db holds exactly the state with
+// the given root.
+func checkSnapshot(t *testing.T, db *aquadb.MemDatabase, sdb state.Database, s *Snapshot, root common.Hash) {
+	t.Helper()
+
+	want := make(map[string][]byte)
+	tr, err := trie.New(root, sdb.TrieDB())
+	if err != nil {
+		t.Fatalf("failed to open state trie: %v", err)
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		want[string(accountKey(hash))] = common.CopyBytes(it.Value)
+
+		var account state.Account
+		if err := rlp.DecodeBytes(it.Value, &account); err != nil {
+			t.Fatalf("failed to decode account: %v", err)
+		}
+		storage, err := trie.New(account.Root, sdb.TrieDB())
+		if err != nil {
+			t.Fatalf("failed to open storage trie: %v", err)
+		}
+		sit := trie.NewIterator(storage.NodeIterator(nil))
+		for sit.Next() {
+			want[string(storageKey(hash, common.BytesToHash(sit.Key)))] = common.CopyBytes(sit.Value)
+		}
+	}
+	have := make(map[string][]byte)
+	db.Walk(func(key, value []byte) error {
+		if isAccountKey(key) || isStorageKey(key) {
+			have[string(key)] = common.CopyBytes(value)
+		}
+		return nil
+	})
+	if len(have) != len(want) {
+		t.Errorf("entry count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for key, value := range want {
+		if !bytes.Equal(have[key], value) {
+			t.Errorf("entry %x mismatch: have %x, want %x", key, have[key], value)
+		}
+	}
+	// Spot check the reads, and that other states are left to the tries
+	for key, value := range want {
+		if isAccountKey([]byte(key)) {
+			hash := common.BytesToHash([]byte(key)[1:])
+			if data, ok := s.Account(root, hash); !ok || !bytes.Equal(data, value) {
+				t.Errorf("account %x read mismatch: have %x/%v, want %x", hash, data, ok, value)
+			}
+			if _, ok := s.Account(common.Hash{1}, hash); ok {
+				t.Errorf("account %x read from another state", hash)
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var (
+		db      = aquadb.NewMemDatabase()
+		sdb     = state.NewDatabase(db)
+		chain   = make(testChain)
+		recycle = common.Address{0xaa}
+		killed  = common.Address{0xbb}
+	)
+	// Create a state with enough accounts to take several generation chunks
+	st, _ := state.New(common.Hash{}, sdb)
+	for i := 0; i < 2*generateChunk+100; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		st.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%100 == 0 {
+			for j := 0; j < 10; j++ {
+				st.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.Hash{byte(i), byte(j + 1)})
+			}
+		}
+	}
+	for _, addr := range []common.Address{recycle, killed} {
+		st.SetNonce(addr, 1)
+		st.SetState(addr, common.Hash{1}, common.Hash{1})
+		st.SetState(addr, common.Hash{2}, common.Hash{2})
+	}
+	root, _ := st.Commit(true)
+	genesis := chain.add(nil, root)
+
+	// Leave stale entries around to be wiped
+	db.Put(accountKey(common.Hash{0xff}), []byte{0x01})
+
+	s, err := New(db, sdb, genesis)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	defer s.Close()
+	waitGenerated(t, s)
+	checkSnapshot(t, db, sdb, s, root)
+
+	state.SetFlatState(sdb, s)
+
+	// Move the snapshot along a block changing balances, storage and recreating
+	// and deleting accounts
+	st, _ = state.New(root, sdb)
+	st.AddBalance(common.BigToAddress(big.NewInt(5)), big.NewInt(1000))
+	st.SetState(common.BigToAddress(big.NewInt(1)), common.BigToHash(big.NewInt(3)), common.Hash{})
+	st.SetState(common.BigToAddress(big.NewInt(1)), common.BigToHash(big.NewInt(20)), common.Hash{0x20})
+	if have := st.GetState(recycle, common.Hash{1}); have != (common.Hash{1}) {
+		t.Fatalf("storage read mismatch: have %x, want %x", have, common.Hash{1})
+	}
+	st.CreateAccount(recycle)
+	st.SetNonce(recycle, 2)
+	st.SetState(recycle, common.Hash{3}, common.Hash{3})
+	st.Suicide(killed)
+	root1, _ := st.Commit(true)
+	block1 := chain.add(genesis, root1)
+	s.Record(block1, st.Diff())
+	s.Update(block1, chain)
+	checkSnapshot(t, db, sdb, s, root1)
+
+	// Reorg onto a sibling block, reverting the changes of the first one
+	st, _ = state.New(root, sdb)
+	st.AddBalance(common.BigToAddress(big.NewInt(6)), big.NewInt(1000))
+	st.SetState(recycle, common.Hash{2}, common.Hash{})
+	root2, _ := st.Commit(true)
+	block2 := chain.add(genesis, root2)
+	s.Record(block2, st.Diff())
+
+	st, _ = state.New(root2, sdb)
+	st.AddBalance(common.BigToAddress(big.NewInt(7)), big.NewInt(1000))
+	root3, _ := st.Commit(true)
+	block3 := chain.add(block2, root3)
+	s.Record(block3, st.Diff())
+
+	s.Update(block3, chain)
+	if !s.Generated() {
+		t.Fatal("snapshot regenerated on a shallow reorg")
+	}
+	checkSnapshot(t, db, sdb, s, root3)
+
+	// Moving to a block whose changes are unknown regenerates the snapshot
+	block4 := chain.add(block3, root1)
+	s.Update(block4, chain)
+	waitGenerated(t, s)
+	checkSnapshot(t, db, sdb, s, root1)
+}
+
+// Tests that an account with storage generated from a state the head moved
+// away from is only committed if it's unchanged in the new state, and that its
+// entries written ahead of the commit are deleted otherwise.
+func TestGenerateStorageMoved(t *testing.T) {
+	var (
+		db    = aquadb.NewMemDatabase()
+		sdb   = state.NewDatabase(db)
+		large = common.Address{0xaa}
+		other = common.Address{0xbb}
+	)
+	// Create a contract with storage large enough to be written in batches
+	st, _ := state.New(common.Hash{}, sdb)
+	st.SetNonce(large, 1)
+	for i := 0; i < 4000; i++ {
+		st.SetState(large, common.BigToHash(big.NewInt(int64(i))), common.Hash{0xff, byte(i)})
+	}
+	root, _ := st.Commit(true)
+
+	st, _ = state.New(root, sdb)
+	st.AddBalance(other, big.NewInt(1))
+	unrelated, _ := st.Commit(true)
+
+	st, _ = state.New(root, sdb)
+	st.SetState(large, common.Hash{}, common.Hash{})
+	changed, _ := st.Commit(true)
+
+	tr, _ := trie.New(root, sdb.TrieDB())
+	hash := crypto.Keccak256Hash(large[:])
+	data, _ := tr.TryGet(hash[:])
+	var account state.Account
+	rlp.DecodeBytes(data, &account)
+
+	for i, tt := range []struct {
+		head      common.Hash
+		committed bool
+	}{
+		{unrelated, true},
+		{changed, false},
+	} {
+		db := aquadb.NewMemDatabase()
+		s := &Snapshot{diskdb: db, triedb: sdb, meta: meta{Root: tt.head, Wiped: true}}
+		ok, err := s.generateStorage(root, hash, data, account.Root)
+		if err != nil || ok != tt.committed {
+			t.Fatalf("test %d: commit mismatch: have %v/%v, want %v/nil", i, ok, err, tt.committed)
+		}
+		entries := 0
+		db.Walk(func(key, value []byte) error {
+			if isAccountKey(key) || isStorageKey(key) {
+				entries++
+			}
+			return nil
+		})
+		if tt.committed && entries != 4001 {
+			t.Errorf("test %d: entry count mismatch: have %d, want 4001", i, entries)
+		}
+		if !tt.committed && entries != 0 {
+			t.Errorf("test %d: entries left behind: %d", i, entries)
+		}
+		var stored meta
+		blob, _ := db.Get(metaKey)
+		if err := rlp.DecodeBytes(blob, &stored); err != nil || !stored.Wiped {
+			t.Errorf("test %d: stored metadata not wiped: %+v, %v", i, stored, err)
+		}
+	}
+}
+
+// Tests that the snapshot follows the head while an account with large storage
+// is being generated.
+func TestGenerateFollowingHead(t *testing.T) {
+	var (
+		db    = aquadb.NewMemDatabase()
+		sdb   = state.NewDatabase(db)
+		chain = make(testChain)
+		large = common.Address{0xaa}
+	)
+	st, _ := state.New(common.Hash{}, sdb)
+	for i := 0; i < 200; i++ {
+		st.AddBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(1))
+	}
+	st.SetNonce(large, 1)
+	for i := 0; i < 4000; i++ {
+		st.SetState(large, common.BigToHash(big.NewInt(int64(i))), common.Hash{0xff, byte(i)})
+	}
+	root, _ := st.Commit(true)
+	head := chain.add(nil, root)
+
+	s, err := New(db, sdb, head)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	defer s.Close()
+	state.SetFlatState(sdb, s)
+
+	for i := 0; i < 20; i++ {
+		st, _ = state.New(root, sdb)
+		st.SetState(large, common.BigToHash(big.NewInt(int64(i))), common.Hash{})
+		st.AddBalance(common.BigToAddress(big.NewInt(int64(1000+i))), big.NewInt(1))
+		root, _ = st.Commit(true)
+		head = chain.add(head, root)
+		s.Record(head, st.Diff())
+		s.Update(head, chain)
+	}
+	waitGenerated(t, s)
+	checkSnapshot(t, db, sdb, s, root)
+}
@@ -88,6 +88,10 @@ type stateObject struct {
 	touched   bool
 	deleted   bool
 	onDirty   func(addr common.Address) // Callback method to mark a state object newly dirty
+
+	// fresh is set on objects created anew rather than loaded, until their
+	// recreation is recorded in the state diff.
+	fresh bool
 }
 
 // empty returns whether the account is considered empty.
@@ -174,8 +178,20 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	if exists {
 		return value
 	}
-	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
+	// Load from DB in case it is missing, preferring the flat state
+	var (
+		enc []byte
+		err error
+		hit bool
+	)
+	if flat := self.db.flat; flat != nil && !self.fresh {
+		if hash := crypto.Keccak256Hash(key[:]); self.db.diff.flatStorage(self.addrHash, hash) {
+			enc, hit = flat.Storage(self.db.originalRoot, self.addrHash, hash)
+		}
+	}
+	if !hit {
+		enc, err = self.getTrie(db).TryGet(key[:])
+	}
 	if err != nil {
 		self.setError(err)
 		return common.Hash{}
@@ -216,15 +232,27 @@ func (self *stateObject) setState(key, value common.Hash) {
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
+	diff := self.db.diff
+	if diff != nil && self.fresh {
+		// Anything stored under the account before its recreation is gone
+		diff.destruct(self.addrHash)
+		self.fresh = false
+	}
 	for key, value := range self.dirtyStorage {
 		delete(self.dirtyStorage, key)
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			if diff != nil {
+				diff.setStorage(self.addrHash, crypto.Keccak256Hash(key[:]), nil)
+			}
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		if diff != nil {
+			diff.setStorage(self.addrHash, crypto.Keccak256Hash(key[:]), v)
+		}
 	}
 	return tr
 }
@@ -303,6 +331,7 @@ func (self *stateObject) deepCopy(db *StateDB, onDirty func(addr common.Address)
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
+	stateObject.fresh = self.fresh
 	return stateObject
 }
 
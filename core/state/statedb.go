@@ -83,6 +83,12 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Flat state to read from, along with the root it's read at and the changes
+	// since, which are read from the tries instead.
+	flat         FlatState
+	originalRoot common.Hash
+	diff         *StateDiff
+
 	lock sync.Mutex
 }
 
@@ -92,14 +98,31 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	statedb := &StateDB{
 		db:                db,
 		trie:              tr,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	statedb.openFlat(root)
+	return statedb, nil
+}
+
+// openFlat sets up reading from the flat state of the database, if any.
+func (self *StateDB) openFlat(root common.Hash) {
+	if db, ok := self.db.(*cachingDB); ok && db.flat != nil {
+		self.flat = db.flat
+		self.originalRoot = root
+		self.diff = newStateDiff()
+	}
+}
+
+// Diff returns the changes made to the state since it was opened, or nil if
+// they aren't tracked as the database has no flat state.
+func (self *StateDB) Diff() *StateDiff {
+	return self.diff
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -130,6 +153,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.logSize = 0
 	self.preimages = make(map[common.Hash][]byte)
 	self.clearJournalAndRefund()
+	self.openFlat(root)
 	return nil
 }
 
@@ -391,6 +415,9 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+	if self.diff != nil {
+		self.diff.Accounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -398,6 +425,9 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+	if self.diff != nil {
+		self.diff.destruct(stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -410,8 +440,20 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the database, preferring the flat state
+	var (
+		enc []byte
+		err error
+		hit bool
+	)
+	if self.flat != nil {
+		if hash := crypto.Keccak256Hash(addr[:]); self.diff.flatAccount(hash) {
+			enc, hit = self.flat.Account(self.originalRoot, hash)
+		}
+	}
+	if !hit {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -451,6 +493,7 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
+	newobj.fresh = true
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
 		self.journal = append(self.journal, createObjectChange{account: &addr})
@@ -515,6 +558,11 @@ func (self *StateDB) Copy() *StateDB {
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte),
+		flat:              self.flat,
+		originalRoot:      self.originalRoot,
+	}
+	if self.diff != nil {
+		state.diff = self.diff.copy()
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateObjectsDirty {
@@ -0,0 +1,63 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync/atomic"
+
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// statePrefetcher runs the transactions of an upcoming block on a throwaway
+// copy of its parent state while the current block is being processed. The
+// results are discarded, the point is to load the accounts and storage the
+// block touches into the state caches, and to recover the transaction senders
+// ahead of time.
+type statePrefetcher struct {
+	config *params.ChainConfig
+	bc     *BlockChain
+}
+
+func newStatePrefetcher(config *params.ChainConfig, bc *BlockChain) *statePrefetcher {
+	return &statePrefetcher{config: config, bc: bc}
+}
+
+// Prefetch executes the transactions of the block on statedb until done, until
+// one of them fails or until interrupt is set. The EVM runs without the
+// tracing options of the chain, as the tracer would otherwise be called from
+// two imports at once.
+func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, interrupt *uint32) {
+	var (
+		header  = block.Header()
+		gaspool = new(GasPool).AddGas(block.GasLimit())
+		cfg     = vm.Config{}
+	)
+	header.Version = p.config.GetBlockVersion(header.Number)
+
+	for i, tx := range block.Transactions() {
+		if interrupt != nil && atomic.LoadUint32(interrupt) == 1 {
+			return
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if _, _, err := ApplyTransaction(p.config, p.bc, nil, gaspool, statedb, header, tx, new(uint64), cfg); err != nil {
+			return // Invalid blocks are rejected by the import itself
+		}
+	}
+}
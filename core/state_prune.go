@@ -0,0 +1,194 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/rlp"
+	"gitlab.com/aquachain/aquachain/trie"
+)
+
+var (
+	// errPruneUnsupported is returned if the database can't enumerate its entries.
+	errPruneUnsupported = errors.New("database iteration unsupported")
+
+	// emptyCode is the hash of the code of accounts without code.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+// PruneStats summarizes an offline pruning of the state.
+type PruneStats struct {
+	States  []uint64 // Numbers of the blocks whose state was kept
+	Kept    int      // Number of trie nodes and contract codes kept
+	Deleted int      // Number of trie nodes and contract codes deleted
+}
+
+// PruneState deletes the state trie nodes and contract codes from the database
+// which are neither part of the state of one of the keep most recent blocks nor
+// of the genesis block. Only states fully stored in the database are kept,
+// pruning nodes write the state of a block to disk just every now and then. It
+// must not be run on a database in use by a node.
+//
+// All entries to keep are collected before deleting anything, so a failure
+// leaves the database untouched.
+func PruneState(db aquadb.Database, keep uint64) (*PruneStats, error) {
+	walker, ok := aquadb.Unwrap(db).(aquadb.Walker)
+	if !ok {
+		return nil, errPruneUnsupported
+	}
+	if keep == 0 {
+		return nil, errors.New("no state to keep")
+	}
+	if err := aquadb.Flush(db); err != nil {
+		return nil, err
+	}
+	head := GetHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return nil, errors.New("no head block stored")
+	}
+	number := GetBlockNumber(db, head)
+	if number == missingNumber {
+		return nil, fmt.Errorf("number of head block %x unknown", head)
+	}
+	// Collect the trie nodes and codes of the states to keep
+	numbers := []uint64{}
+	for i := uint64(0); i < keep && i <= number; i++ {
+		numbers = append(numbers, number-i)
+	}
+	if numbers[len(numbers)-1] != 0 {
+		numbers = append(numbers, 0)
+	}
+	var (
+		stats  = new(PruneStats)
+		sdb    = state.NewDatabase(db)
+		marked = make(map[common.Hash]struct{})
+	)
+	for _, n := range numbers {
+		header := GetHeaderNoVersion(db, GetCanonicalHash(db, n), n)
+		if header == nil {
+			return nil, fmt.Errorf("missing header of block %d", n)
+		}
+		if _, err := state.New(header.Root, sdb); err != nil {
+			continue
+		}
+		log.Info("Collecting state to keep", "block", n, "root", header.Root, "entries", len(marked))
+		if err := markState(sdb, header.Root, marked); err != nil {
+			return nil, fmt.Errorf("state of block %d: %v", n, err)
+		}
+		stats.States = append(stats.States, n)
+	}
+	if len(stats.States) == 0 || (stats.States[0] == 0 && number > 0) {
+		return nil, fmt.Errorf("no state stored within %d blocks of head block %d", keep, number)
+	}
+	// Delete all other trie nodes and codes, recognized by being keyed by the
+	// hash of their value
+	var (
+		batch  = db.NewBatch()
+		logged = time.Now()
+	)
+	err := walker.Walk(func(key, value []byte) error {
+		if len(key) != common.HashLength {
+			return nil
+		}
+		if _, ok := marked[common.BytesToHash(key)]; ok {
+			stats.Kept++
+			return nil
+		}
+		if !bytes.Equal(crypto.Keccak256(value), key) {
+			return nil
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return err
+		}
+		stats.Deleted++
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state", "kept", stats.Kept, "deleted", stats.Deleted)
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// markState adds the hashes of all trie nodes and contract codes of the state
+// with the given root to marked.
+func markState(sdb state.Database, root common.Hash, marked map[common.Hash]struct{}) error {
+	tr, err := sdb.OpenTrie(root)
+	if err != nil {
+		return err
+	}
+	return markTrie(tr.NodeIterator(nil), marked, func(key, blob []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return err
+		}
+		storage, err := sdb.OpenStorageTrie(common.BytesToHash(key), account.Root)
+		if err != nil {
+			return err
+		}
+		if err := markTrie(storage.NodeIterator(nil), marked, nil); err != nil {
+			return err
+		}
+		if code := common.BytesToHash(account.CodeHash); code != emptyCode {
+			marked[code] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// markTrie adds the hashes of the trie nodes reached by it to marked, calling
+// onLeaf, if set, for every leaf found. Nodes marked before are not descended
+// into, the states of consecutive blocks share most of their nodes.
+func markTrie(it trie.NodeIterator, marked map[common.Hash]struct{}, onLeaf func(key, blob []byte) error) error {
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if _, ok := marked[hash]; ok {
+				descend = false
+				continue
+			}
+			marked[hash] = struct{}{}
+		}
+		if onLeaf != nil && it.Leaf() {
+			if err := onLeaf(it.LeafKey(), it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
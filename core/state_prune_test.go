@@ -0,0 +1,91 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that offline pruning keeps the recent and the genesis states intact
+// and deletes the others.
+func TestPruneState(t *testing.T) {
+	var (
+		engine   = aquahash.NewFaker()
+		db       = aquadb.NewMemDatabase()
+		contract = common.Address{0xc0}
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{contract: {
+				Balance: big.NewInt(1),
+				Code:    []byte{0x60, 0x00},
+				Storage: map[common.Hash]common.Hash{{1}: {2}},
+			}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 10, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	// Import the chain as an archive node, storing all states
+	diskdb := aquadb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+	chain, err := NewBlockChain(diskdb, &CacheConfig{Disabled: true}, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	stats, err := PruneState(diskdb, 3)
+	if err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if want := []uint64{10, 9, 8, 0}; len(stats.States) != len(want) || stats.States[0] != 10 || stats.States[3] != 0 {
+		t.Errorf("kept states mismatch: have %v, want %v", stats.States, want)
+	}
+	if stats.Deleted == 0 {
+		t.Error("no state deleted")
+	}
+	sdb := state.NewDatabase(diskdb)
+	for _, block := range append(blocks[7:], genesis) {
+		statedb, err := state.New(block.Root(), sdb)
+		if err != nil {
+			t.Fatalf("state of block %d missing: %v", block.NumberU64(), err)
+		}
+		it := state.NewNodeIterator(statedb)
+		for it.Next() {
+		}
+		if it.Error != nil {
+			t.Fatalf("state of block %d incomplete: %v", block.NumberU64(), it.Error)
+		}
+		if len(statedb.GetCode(contract)) != 2 || statedb.GetState(contract, common.Hash{1}) != (common.Hash{2}) {
+			t.Errorf("contract in state of block %d damaged", block.NumberU64())
+		}
+	}
+	if _, err := state.New(blocks[4].Root(), sdb); err == nil {
+		t.Errorf("state of block %d not pruned", blocks[4].NumberU64())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+)
+
+// countingSigner is a Homestead signer counting the sender recoveries.
+type countingSigner struct {
+	types.HomesteadSigner
+	recovered *int32
+}
+
+func (s countingSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	atomic.AddInt32(s.recovered, 1)
+	return s.HomesteadSigner.Sender(tx)
+}
+
+func (s countingSigner) Equal(s2 types.Signer) bool {
+	_, ok := s2.(countingSigner)
+	return ok
+}
+
+// Tests that the sender cacher recovers every transaction exactly once and
+// caches the senders in the transactions.
+func TestTxSenderCacher(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	txs := make([]*types.Transaction, 37)
+	for i := range txs {
+		tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		txs[i], _ = types.SignTx(tx, types.HomesteadSigner{}, key)
+	}
+	var recovered int32
+	signer := countingSigner{recovered: &recovered}
+
+	newTxSenderCacher(4).recover(signer, txs)
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&recovered) < int32(len(txs)); {
+		if time.Now().After(deadline) {
+			t.Fatalf("senders not recovered: have %d, want %d", atomic.LoadInt32(&recovered), len(txs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i, tx := range txs {
+		if from, err := types.Sender(signer, tx); err != nil || from != addr {
+			t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, addr)
+		}
+	}
+	if n := atomic.LoadInt32(&recovered); n != int32(len(txs)) {
+		t.Errorf("recovery count mismatch: have %d, want %d", n, len(txs))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+// maxConditionSlots is the maximum number of storage slots a conditional
+// transaction may require, to bound the cost of checking it for every block.
+const maxConditionSlots = 1000
+
+var (
+	// ErrConditionExpired is returned if the block number or timestamp bounds
+	// of a conditional transaction can no longer be met by any future block.
+	ErrConditionExpired = errors.New("transaction conditions expired")
+
+	// ErrConditionTooLarge is returned if a conditional transaction requires
+	// more storage slots than permitted.
+	ErrConditionTooLarge = errors.New("too many conditional storage slots")
+)
+
+// TxConditions are the inclusion constraints attached to a conditionally
+// submitted transaction. Bounds left unset are not enforced.
+type TxConditions struct {
+	BlockNumberMin *hexutil.Uint64 `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Uint64 `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64 `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64 `json:"timestampMax,omitempty"`
+
+	// KnownAccounts maps accounts to the storage slot values they must hold
+	// right before the transaction is executed.
+	KnownAccounts map[common.Address]map[common.Hash]common.Hash `json:"knownAccounts,omitempty"`
+}
+
+// validate checks that the conditions are well formed.
+func (c *TxConditions) validate() error {
+	slots := 0
+	for _, storage := range c.KnownAccounts {
+		slots += len(storage)
+	}
+	if slots > maxConditionSlots {
+		return ErrConditionTooLarge
+	}
+	if c.BlockNumberMin != nil && c.BlockNumberMax != nil && *c.BlockNumberMin > *c.BlockNumberMax {
+		return fmt.Errorf("minimum block number %d above maximum %d", *c.BlockNumberMin, *c.BlockNumberMax)
+	}
+	if c.TimestampMin != nil && c.TimestampMax != nil && *c.TimestampMin > *c.TimestampMax {
+		return fmt.Errorf("minimum timestamp %d above maximum %d", *c.TimestampMin, *c.TimestampMax)
+	}
+	return nil
+}
+
+// Expired reports whether the block number or timestamp bounds can no longer be
+// met by any block built on top of the given head.
+func (c *TxConditions) Expired(head *types.Header) bool {
+	if c.BlockNumberMax != nil && head.Number.Uint64()+1 > uint64(*c.BlockNumberMax) {
+		return true
+	}
+	if c.TimestampMax != nil && head.Time.Uint64()+1 > uint64(*c.TimestampMax) {
+		return true
+	}
+	return false
+}
+
+// Check verifies the conditions against the header of the block being built and
+// the state the transaction would be executed on.
+func (c *TxConditions) Check(header *types.Header, statedb *state.StateDB) error {
+	number, time := header.Number.Uint64(), header.Time.Uint64()
+
+	if c.BlockNumberMin != nil && number < uint64(*c.BlockNumberMin) {
+		return fmt.Errorf("block number %d below minimum %d", number, *c.BlockNumberMin)
+	}
+	if c.BlockNumberMax != nil && number > uint64(*c.BlockNumberMax) {
+		return fmt.Errorf("block number %d above maximum %d", number, *c.BlockNumberMax)
+	}
+	if c.TimestampMin != nil && time < uint64(*c.TimestampMin) {
+		return fmt.Errorf("timestamp %d below minimum %d", time, *c.TimestampMin)
+	}
+	if c.TimestampMax != nil && time > uint64(*c.TimestampMax) {
+		return fmt.Errorf("timestamp %d above maximum %d", time, *c.TimestampMax)
+	}
+	return c.checkState(statedb)
+}
+
+// checkState verifies the required storage slot values against the given state.
+func (c *TxConditions) checkState(statedb *state.StateDB) error {
+	for addr, storage := range c.KnownAccounts {
+		for slot, want := range storage {
+			if have := statedb.GetState(addr, slot); have != want {
+				return fmt.Errorf("storage slot %x of %x is %x, want %x", slot, addr, have, want)
+			}
+		}
+	}
+	return nil
+}
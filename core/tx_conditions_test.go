@@ -0,0 +1,139 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+func uint64Ptr(n uint64) *hexutil.Uint64 { return (*hexutil.Uint64)(&n) }
+
+// Tests that transaction conditions are checked against the block bounds and
+// the required storage state.
+func TestTxConditionsCheck(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x01")
+		slot     = common.HexToHash("0x02")
+		value    = common.HexToHash("0x03")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(aquadb.NewMemDatabase()))
+	statedb.SetState(contract, slot, value)
+
+	header := &types.Header{Number: big.NewInt(10), Time: big.NewInt(1000)}
+	tests := []struct {
+		cond    TxConditions
+		ok      bool
+		expired bool
+	}{
+		{TxConditions{}, true, false},
+		{TxConditions{BlockNumberMin: uint64Ptr(10), BlockNumberMax: uint64Ptr(10)}, true, true},
+		{TxConditions{BlockNumberMin: uint64Ptr(11)}, false, false},
+		{TxConditions{BlockNumberMax: uint64Ptr(9)}, false, true},
+		{TxConditions{BlockNumberMax: uint64Ptr(11)}, true, false},
+		{TxConditions{TimestampMin: uint64Ptr(1001)}, false, false},
+		{TxConditions{TimestampMax: uint64Ptr(999)}, false, true},
+		{TxConditions{TimestampMin: uint64Ptr(1000), TimestampMax: uint64Ptr(2000)}, true, false},
+		{TxConditions{KnownAccounts: map[common.Address]map[common.Hash]common.Hash{contract: {slot: value}}}, true, false},
+		{TxConditions{KnownAccounts: map[common.Address]map[common.Hash]common.Hash{contract: {slot: common.Hash{}}}}, false, false},
+	}
+	for i, tt := range tests {
+		if err := tt.cond.Check(header, statedb); (err == nil) != tt.ok {
+			t.Errorf("test %d: check mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+		if expired := tt.cond.Expired(header); expired != tt.expired {
+			t.Errorf("test %d: expiry mismatch: have %v, want %v", i, expired, tt.expired)
+		}
+	}
+	if err := (&TxConditions{BlockNumberMin: uint64Ptr(2), BlockNumberMax: uint64Ptr(1)}).validate(); err == nil {
+		t.Errorf("inverted block bounds accepted")
+	}
+}
+
+// Tests that conditional transactions are rejected if already unsatisfiable,
+// are not journaled and are dropped once their bounds expire.
+func TestTxPoolConditional(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(aquadb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	// Unsatisfiable conditions must be rejected up front
+	contract, slot := common.HexToAddress("0x01"), common.HexToHash("0x02")
+	mismatch := &TxConditions{KnownAccounts: map[common.Address]map[common.Hash]common.Hash{contract: {slot: common.HexToHash("0x03")}}}
+	if err := pool.AddConditional(transaction(0, 100000, key), mismatch); err == nil {
+		t.Fatalf("transaction with mismatching storage condition accepted")
+	}
+	if err := pool.AddConditional(transaction(0, 100000, key), &TxConditions{BlockNumberMax: uint64Ptr(0)}); err != ErrConditionExpired {
+		t.Fatalf("expired condition error mismatch: have %v, want %v", err, ErrConditionExpired)
+	}
+	// Add a satisfiable conditional transaction and ensure it's tracked but not journaled
+	tx := transaction(0, 100000, key)
+	if err := pool.AddConditional(tx, &TxConditions{BlockNumberMax: uint64Ptr(1)}); err != nil {
+		t.Fatalf("failed to add conditional transaction: %v", err)
+	}
+	if pool.Conditions(tx.Hash()) == nil {
+		t.Fatalf("conditions of transaction not tracked")
+	}
+	pool.mu.Lock()
+	journaled := pool.local()
+	pool.mu.Unlock()
+	for _, txs := range journaled {
+		if len(txs) > 0 {
+			t.Fatalf("conditional transaction journaled: %v", txs)
+		}
+	}
+	// Advance the head past the block bound and ensure the transaction is dropped
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), GasLimit: 1000000})
+
+	if pool.Get(tx.Hash()) != nil {
+		t.Errorf("expired conditional transaction not dropped")
+	}
+	if pool.Conditions(tx.Hash()) != nil {
+		t.Errorf("conditions of dropped transaction retained")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
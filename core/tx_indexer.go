@@ -0,0 +1,191 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// errTxIndexInterrupted is returned when (un)indexing transactions is aborted.
+var errTxIndexInterrupted = errors.New("transaction indexing interrupted")
+
+// IndexTransactions writes the transaction lookup entries of the canonical
+// blocks in [from, to), returning the number of transactions indexed. The
+// transaction index tail is moved down to from if the range reaches it.
+func IndexTransactions(db aquadb.Database, config *params.ChainConfig, from, to uint64, interrupt <-chan struct{}) (int, error) {
+	var (
+		batch  = db.NewBatch()
+		count  = 0
+		logged = time.Now()
+	)
+	for n := from; n < to; n++ {
+		select {
+		case <-interrupt:
+			return count, errTxIndexInterrupted
+		default:
+		}
+		hash := GetCanonicalHash(db, n)
+		if hash == (common.Hash{}) {
+			break
+		}
+		block := GetBlockNoVersion(db, hash, n)
+		if block == nil {
+			return count, fmt.Errorf("block %d [%x…] missing", n, hash[:4])
+		}
+		block.SetVersion(config.GetBlockVersion(block.Number()))
+		if err := WriteTxLookupEntries(batch, block); err != nil {
+			return count, err
+		}
+		count += len(block.Transactions())
+
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing transactions", "number", n, "to", to, "txs", count)
+			logged = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return count, err
+	}
+	if tail, ok := GetTxIndexTail(db); ok && from < tail && to >= tail {
+		if from == 0 {
+			return count, DeleteTxIndexTail(db)
+		}
+		return count, WriteTxIndexTail(db, from)
+	}
+	return count, nil
+}
+
+// UnindexTransactions removes the transaction lookup entries of the canonical
+// blocks in [from, to), returning the number of transactions unindexed. The
+// transaction index tail is moved up along the way, so an interrupted run can
+// be resumed.
+func UnindexTransactions(db aquadb.Database, from, to uint64, interrupt <-chan struct{}) (int, error) {
+	var (
+		batch  = db.NewBatch()
+		count  = 0
+		logged = time.Now()
+	)
+	for n := from; n < to; n++ {
+		select {
+		case <-interrupt:
+			// Only move the tail once the deletions below it are on disk
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			if err := WriteTxIndexTail(db, n); err != nil {
+				return count, err
+			}
+			return count, errTxIndexInterrupted
+		default:
+		}
+		hash := GetCanonicalHash(db, n)
+		if body := GetBodyNoVersion(db, hash, n); body != nil {
+			for _, tx := range body.Transactions {
+				// Keep the entry if a later block included the transaction again
+				if blockHash, _, _ := GetTxLookupEntry(db, tx.Hash()); blockHash == hash {
+					DeleteTxLookupEntry(batch, tx.Hash())
+				}
+			}
+			count += len(body.Transactions)
+		}
+		if batch.ValueSize() >= aquadb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			batch.Reset()
+			if err := WriteTxIndexTail(db, n+1); err != nil {
+				return count, err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Unindexing transactions", "number", n, "to", to, "txs", count)
+			logged = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return count, err
+	}
+	return count, WriteTxIndexTail(db, to)
+}
+
+// txIndexLoop unindexes the transactions of the blocks falling out of the
+// configured lookup limit as the chain head moves.
+func (bc *BlockChain) txIndexLoop() {
+	defer bc.wg.Done()
+
+	for {
+		select {
+		case <-bc.txIndexCh:
+			bc.unindexTxs(atomic.LoadUint64(&bc.txIndexHead))
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// unindexTxs drops the transaction lookups of the blocks more than the lookup
+// limit behind head.
+func (bc *BlockChain) unindexTxs(head uint64) {
+	limit := bc.cacheConfig.TxLookupLimit
+	if head < limit {
+		return
+	}
+	cutoff := head - limit + 1
+	tail, _ := GetTxIndexTail(bc.db)
+	if tail >= cutoff {
+		return
+	}
+	start := time.Now()
+	count, err := UnindexTransactions(bc.db, tail, cutoff, bc.quit)
+	switch {
+	case err == errTxIndexInterrupted:
+		log.Info("Transaction unindexing interrupted", "tail", tail, "cutoff", cutoff)
+	case err != nil:
+		log.Error("Failed to unindex transactions", "err", err)
+	case cutoff-tail > 1:
+		log.Info("Unindexed transactions", "blocks", cutoff-tail, "txs", count, "tail", cutoff, "elapsed", common.PrettyDuration(time.Since(start)))
+	default:
+		log.Debug("Unindexed transactions", "number", tail, "txs", count)
+	}
+}
+
+// indexTxs notifies the transaction indexer of a new canonical head.
+func (bc *BlockChain) indexTxs(head uint64) {
+	if bc.txIndexCh == nil {
+		return
+	}
+	atomic.StoreUint64(&bc.txIndexHead, head)
+	select {
+	case bc.txIndexCh <- struct{}{}:
+	default:
+		// The indexer is already notified, it picks up the latest head
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that a limited transaction index only keeps the recent blocks indexed,
+// and that reindexing restores the full index.
+func TestTxLookupLimit(t *testing.T) {
+	var (
+		engine  = aquahash.NewFaker()
+		gendb   = aquadb.NewMemDatabase()
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 8, func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{1}, big.NewInt(1), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	db := aquadb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, &CacheConfig{TxLookupLimit: 3}, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// check verifies that exactly the blocks from tail on are indexed
+	check := func(tail uint64) {
+		t.Helper()
+		for _, block := range blocks {
+			hash, _, _ := GetTxLookupEntry(db, block.Transactions()[0].Hash())
+			if indexed, want := hash == block.Hash(), block.NumberU64() >= tail; indexed != want {
+				t.Errorf("block %d: indexed %v, want %v", block.NumberU64(), indexed, want)
+			}
+		}
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if tail, _ := GetTxIndexTail(db); tail == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			tail, ok := GetTxIndexTail(db)
+			t.Fatalf("index tail mismatch: have %d (%v), want 6", tail, ok)
+		}
+	}
+	chain.Stop()
+	check(6)
+
+	// Reindexing part of the pruned range moves the tail down
+	if _, err := IndexTransactions(db, gspec.Config, 3, 6, nil); err != nil {
+		t.Fatalf("failed to reindex transactions: %v", err)
+	}
+	if tail, _ := GetTxIndexTail(db); tail != 3 {
+		t.Errorf("index tail mismatch: have %d, want 3", tail)
+	}
+	check(3)
+
+	// Reindexing everything completes the index
+	if count, err := IndexTransactions(db, gspec.Config, 0, 9, nil); err != nil || count != 8 {
+		t.Fatalf("failed to reindex transactions: %d, %v", count, err)
+	}
+	if _, ok := GetTxIndexTail(db); ok {
+		t.Errorf("index tail left after full reindex")
+	}
+	check(0)
+}
@@ -18,9 +18,13 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/core/types"
@@ -42,16 +46,62 @@ func (*devNull) Close() error                      { return nil }
 
 // txJournal is a rotating log of transactions with the aim of storing locally
 // created transactions to allow non-executed ones to survive node restarts.
+//
+// If a secret is configured, the journal starts with the key derivation salt,
+// followed by the individually sealed RLP encodings of the transactions.
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
+
+	secret []byte            // Secret to encrypt the journal with (nil = plain)
+	salt   []byte            // Key derivation salt of the current journal
+	enc    *aquadb.Encryptor // Encryptor derived from the secret and salt
+}
+
+// remoteJournalPath derives the path of the remote transaction journal from the
+// local one, e.g. transactions.rlp becomes transactions.remote.rlp.
+func remoteJournalPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".remote" + ext
 }
 
 // newTxJournal creates a new transaction journal to
-func newTxJournal(path string) *txJournal {
+func newTxJournal(path string, secret []byte) *txJournal {
 	return &txJournal{
-		path: path,
+		path:   path,
+		secret: secret,
+	}
+}
+
+// encode writes a transaction into the given journal stream, sealing it first
+// if the journal is encrypted.
+func (journal *txJournal) encode(w io.Writer, tx *types.Transaction) error {
+	if journal.enc == nil {
+		return rlp.Encode(w, tx)
+	}
+	blob, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, journal.enc.Seal(nil, blob))
+}
+
+// decode reads the next transaction from the given journal stream, opening it
+// first if the journal is encrypted.
+func (journal *txJournal) decode(stream *rlp.Stream) (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if journal.enc == nil {
+		return tx, stream.Decode(tx)
+	}
+	sealed, err := stream.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := journal.enc.Open(nil, sealed)
+	if err != nil {
+		return nil, err
 	}
+	return tx, rlp.DecodeBytes(blob, tx)
 }
 
 // load parses a transaction journal dump from disk, loading its contents into
@@ -76,11 +126,24 @@ func (journal *txJournal) load(add func(*types.Transaction) error) error {
 	stream := rlp.NewStream(input, 0)
 	total, dropped := 0, 0
 
+	if journal.secret != nil {
+		salt, err := stream.Bytes()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid encrypted journal header: %v", err)
+		}
+		if journal.enc, err = aquadb.NewEncryptor(journal.secret, salt); err != nil {
+			return err
+		}
+		journal.salt = salt
+	}
 	var failure error
 	for {
 		// Parse the next transaction and terminate on error
-		tx := new(types.Transaction)
-		if err = stream.Decode(tx); err != nil {
+		tx, err := journal.decode(stream)
+		if err != nil {
 			if err != io.EOF {
 				failure = err
 			}
@@ -94,7 +157,7 @@ func (journal *txJournal) load(add func(*types.Transaction) error) error {
 			continue
 		}
 	}
-	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	log.Info("Loaded transaction journal", "path", journal.path, "transactions", total, "dropped", dropped)
 
 	return failure
 }
@@ -104,7 +167,7 @@ func (journal *txJournal) insert(tx *types.Transaction) error {
 	if journal.writer == nil {
 		return errNoActiveJournal
 	}
-	if err := rlp.Encode(journal.writer, tx); err != nil {
+	if err := journal.encode(journal.writer, tx); err != nil {
 		return err
 	}
 	return nil
@@ -125,10 +188,27 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 	if err != nil {
 		return err
 	}
+	if journal.secret != nil {
+		// Derive the key only once, reusing the salt across rotations
+		if journal.enc == nil {
+			if journal.salt, err = aquadb.NewEncryptionSalt(); err != nil {
+				replacement.Close()
+				return err
+			}
+			if journal.enc, err = aquadb.NewEncryptor(journal.secret, journal.salt); err != nil {
+				replacement.Close()
+				return err
+			}
+		}
+		if err = rlp.Encode(replacement, journal.salt); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
 	journaled := 0
 	for _, txs := range all {
 		for _, tx := range txs {
-			if err = rlp.Encode(replacement, tx); err != nil {
+			if err = journal.encode(replacement, tx); err != nil {
 				replacement.Close()
 				return err
 			}
@@ -146,7 +226,7 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		return err
 	}
 	journal.writer = sink
-	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
+	log.Info("Regenerated transaction journal", "path", journal.path, "transactions", journaled, "accounts", len(all))
 
 	return nil
 }
@@ -78,6 +78,20 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrSenderLimit is returned if a remote transaction's sender already has
+	// the maximum permitted number of transactions in the pool.
+	ErrSenderLimit = errors.New("sender transaction limit reached")
+
+	// ErrOriginLimit is returned if the peer relaying a remote transaction
+	// already has the maximum permitted number of transactions in the pool.
+	ErrOriginLimit = errors.New("origin transaction limit reached")
+)
+
+// Queue eviction strategies of the transaction pool.
+const (
+	EvictAccounts     = "accounts"     // Evict all queued transactions of senders idle for the lifetime
+	EvictTransactions = "transactions" // Evict every queued transaction older than the lifetime
 )
 
 var (
@@ -97,10 +111,15 @@ var (
 	queuedReplaceCounter   = metrics.NewRegisteredCounter("txpool/queued/replace", nil)
 	queuedRateLimitCounter = metrics.NewRegisteredCounter("txpool/queued/ratelimit", nil) // Dropped due to rate limiting
 	queuedNofundsCounter   = metrics.NewRegisteredCounter("txpool/queued/nofunds", nil)   // Dropped due to out-of-funds
+	queuedEvictionCounter  = metrics.NewRegisteredCounter("txpool/queued/eviction", nil)  // Dropped due to lifetime
+	queuedAgeGauge         = metrics.NewRegisteredGauge("txpool/queued/age", nil)         // Age of the oldest queued transaction in seconds
 
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
+	evictedTxCounter     = metrics.NewRegisteredCounter("txpool/evicted", nil) // Dropped by forced sender eviction
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+	limitedTxCounter     = metrics.NewRegisteredCounter("txpool/limited", nil) // Rejected by the sender or origin limits
+	expiredTxCounter     = metrics.NewRegisteredCounter("txpool/expired", nil) // Dropped due to expired inclusion conditions
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -129,6 +148,9 @@ type TxPoolConfig struct {
 	Journal   string        // Journal of local transactions to survive node restarts
 	Rejournal time.Duration // Time interval to regenerate the local transaction journal
 
+	JournalSecret  []byte `toml:"-"` // Secret to encrypt the journal with (nil = plain)
+	JournalRemotes bool   // Whether to also journal pending remote transactions
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -138,6 +160,10 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	Eviction string        // Queue eviction strategy (accounts or transactions)
+
+	SenderLimit uint64 // Maximum number of remote transactions pooled per sender account (0 = unlimited)
+	OriginLimit uint64 // Maximum number of remote transactions pooled per origin peer address (0 = unlimited)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -155,6 +181,7 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+	Eviction: EvictAccounts,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -173,6 +200,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.Eviction != EvictAccounts && conf.Eviction != EvictTransactions {
+		log.Warn("Sanitizing invalid txpool eviction strategy", "provided", conf.Eviction, "updated", DefaultTxPoolConfig.Eviction)
+		conf.Eviction = DefaultTxPoolConfig.Eviction
+	}
 	return conf
 }
 
@@ -199,12 +230,17 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals  *accountSet                         // Set of local transaction to exempt from eviction rules
+	origins map[string]map[common.Hash]struct{} // Remote transactions pooled per origin peer address
+	journal *txJournal                          // Journal of local transaction to back up to disk
+	remotes *txJournal                          // Journal of remote transactions to back up to disk (optional)
+
+	conditions map[common.Hash]*TxConditions // Inclusion constraints of conditionally submitted transactions
 
 	pending map[common.Address]*txList         // All currently processable transactions
 	queue   map[common.Address]*txList         // Queued but non-processable transactions
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
+	ages    map[common.Hash]time.Time          // Time queued transactions entered the queue (transaction eviction only)
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	priced  *txPricedList                      // All transactions sorted by price
 
@@ -228,7 +264,10 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pending:     make(map[common.Address]*txList),
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
+		ages:        make(map[common.Hash]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		origins:     make(map[string]map[common.Hash]struct{}),
+		conditions:  make(map[common.Hash]*TxConditions),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
@@ -238,7 +277,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
-		pool.journal = newTxJournal(config.Journal)
+		pool.journal = newTxJournal(config.Journal, config.JournalSecret)
 
 		if err := pool.journal.load(pool.AddLocal); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
@@ -247,6 +286,17 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote journaling is requested too, load those without local privileges
+	if config.JournalRemotes && config.Journal != "" {
+		pool.remotes = newTxJournal(remoteJournalPath(config.Journal), config.JournalSecret)
+
+		if err := pool.remotes.load(pool.AddRemote); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+		if err := pool.remotes.rotate(pool.remote()); err != nil {
+			log.Warn("Failed to rotate remote transaction journal", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
@@ -312,29 +362,28 @@ func (pool *TxPool) loop() {
 		// Handle inactive account transaction eviction
 		case <-evict.C:
 			pool.mu.Lock()
-			for addr := range pool.queue {
-				// Skip local transactions from the eviction mechanism
-				if pool.locals.contains(addr) {
-					continue
-				}
-				// Any non-locals old enough should be removed
-				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
-					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash())
-					}
-				}
+			pool.evictQueued()
+
+			// Forget the origins without any transactions left in the pool
+			for origin := range pool.origins {
+				pool.originTxs(origin)
 			}
 			pool.mu.Unlock()
 
-		// Handle local transaction journal rotation
+		// Handle local and remote transaction journal rotation
 		case <-journal.C:
+			pool.mu.Lock()
 			if pool.journal != nil {
-				pool.mu.Lock()
 				if err := pool.journal.rotate(pool.local()); err != nil {
 					log.Warn("Failed to rotate local tx journal", "err", err)
 				}
-				pool.mu.Unlock()
 			}
+			if pool.remotes != nil {
+				if err := pool.remotes.rotate(pool.remote()); err != nil {
+					log.Warn("Failed to rotate remote tx journal", "err", err)
+				}
+			}
+			pool.mu.Unlock()
 		}
 	}
 }
@@ -417,6 +466,19 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	pool.addTxsLocked(reinject, false)
 
+	// Drop the conditional transactions that can't be included any more
+	for hash, cond := range pool.conditions {
+		if pool.all[hash] == nil {
+			delete(pool.conditions, hash)
+			continue
+		}
+		if cond.Expired(newHead) {
+			log.Trace("Discarding expired conditional transaction", "hash", hash)
+			expiredTxCounter.Inc(1)
+			pool.removeTx(hash)
+			delete(pool.conditions, hash)
+		}
+	}
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
 	// have been invalidated because of another transaction (e.g.
@@ -445,6 +507,9 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remotes != nil {
+		pool.remotes.close()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -475,6 +540,38 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// Config returns a copy of the currently active transaction pool configuration.
+func (pool *TxPool) Config() TxPoolConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.config
+}
+
+// SetConfig updates the mempool policy (price bump, slot and queue limits and
+// queue lifetime) of a running transaction pool and enforces the new limits on
+// the current contents. Journal settings and the price limit are retained, the
+// latter being controlled via SetGasPrice.
+func (pool *TxPool) SetConfig(config TxPoolConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	config.NoLocals = pool.config.NoLocals
+	config.Journal = pool.config.Journal
+	config.Rejournal = pool.config.Rejournal
+	config.JournalSecret = pool.config.JournalSecret
+	config.JournalRemotes = pool.config.JournalRemotes
+	config.PriceLimit = pool.config.PriceLimit
+
+	pool.config = (&config).sanitize()
+	pool.promoteExecutables(nil)
+
+	log.Info("Transaction pool policy updated", "pricebump", pool.config.PriceBump,
+		"accountslots", pool.config.AccountSlots, "globalslots", pool.config.GlobalSlots,
+		"accountqueue", pool.config.AccountQueue, "globalqueue", pool.config.GlobalQueue,
+		"lifetime", pool.config.Lifetime)
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
@@ -537,6 +634,18 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// Locals retrieves the accounts currently considered local by the pool.
+func (pool *TxPool) Locals() []common.Address {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	locals := make([]common.Address, 0, len(pool.locals.accounts))
+	for addr := range pool.locals.accounts {
+		locals = append(locals, addr)
+	}
+	return locals
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -544,10 +653,45 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	txs := make(map[common.Address]types.Transactions)
 	for addr := range pool.locals.accounts {
 		if pending := pool.pending[addr]; pending != nil {
-			txs[addr] = append(txs[addr], pending.Flatten()...)
+			txs[addr] = append(txs[addr], pool.unconditional(pending.Flatten())...)
 		}
 		if queued := pool.queue[addr]; queued != nil {
-			txs[addr] = append(txs[addr], queued.Flatten()...)
+			txs[addr] = append(txs[addr], pool.unconditional(queued.Flatten())...)
+		}
+	}
+	return txs
+}
+
+// unconditional filters out the conditional transactions from a batch, which
+// must not be journaled as their conditions would be lost across restarts.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) unconditional(txs types.Transactions) types.Transactions {
+	if len(pool.conditions) == 0 {
+		return txs
+	}
+	filtered := txs[:0]
+	for _, tx := range txs {
+		if pool.conditions[tx.Hash()] == nil {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// remote retrieves all currently known remote transactions, groupped by origin
+// account and sorted by nonce. The returned transaction set is a copy and can be
+// freely modified by calling code.
+func (pool *TxPool) remote() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for addr, pending := range pool.pending {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], pool.unconditional(pending.Flatten())...)
+		}
+	}
+	for addr, queued := range pool.queue {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], pool.unconditional(queued.Flatten())...)
 		}
 	}
 	return txs
@@ -619,6 +763,15 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		invalidTxCounter.Inc(1)
 		return false, err
 	}
+	// Bound the number of remote transactions a single account can have pooled
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	if !local && pool.config.SenderLimit > 0 && !pool.locals.contains(from) && !pool.overlaps(from, tx) {
+		if pool.senderTxs(from) >= pool.config.SenderLimit {
+			log.Trace("Discarding transaction over sender limit", "hash", hash, "from", from)
+			limitedTxCounter.Inc(1)
+			return false, ErrSenderLimit
+		}
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -636,7 +789,6 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
-	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, pool.config.PriceBump)
@@ -699,14 +851,31 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	}
 	pool.all[hash] = tx
 	pool.priced.Put(tx)
+	if pool.config.Eviction == EvictTransactions {
+		pool.ages[hash] = time.Now()
+	}
 	return old != nil, nil
 }
 
 // journalTx adds the specified transaction to the local disk journal if it is
-// deemed to have been sent from a local account.
+// deemed to have been sent from a local account, or to the remote journal if
+// remote journaling is enabled.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
+	// Conditional transactions are never journaled, their conditions would be lost
+	if pool.conditions[tx.Hash()] != nil {
+		return
+	}
+	if !pool.locals.contains(from) {
+		if pool.remotes == nil {
+			return
+		}
+		if err := pool.remotes.insert(tx); err != nil {
+			log.Warn("Failed to journal remote transaction", "err", err)
+		}
+		return
+	}
 	// Only journal if it's enabled and the transaction is local
-	if pool.journal == nil || !pool.locals.contains(from) {
+	if pool.journal == nil {
 		return
 	}
 	if err := pool.journal.insert(tx); err != nil {
@@ -780,6 +949,214 @@ func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
 	return pool.addTxs(txs, false)
 }
 
+// evictQueued drops the non-executable remote transactions that outlived the
+// queue lifetime, according to the configured eviction strategy.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) evictQueued() {
+	var (
+		now    = time.Now()
+		oldest = now
+		ages   = make(map[common.Hash]time.Time)
+	)
+	for addr, list := range pool.queue {
+		// Skip local transactions from the eviction mechanism
+		if pool.locals.contains(addr) {
+			continue
+		}
+		if pool.config.Eviction == EvictTransactions {
+			// Any non-local transaction queued for too long should be removed
+			for _, tx := range list.Flatten() {
+				hash := tx.Hash()
+				queued, ok := pool.ages[hash]
+				if !ok {
+					queued = now
+				}
+				if now.Sub(queued) > pool.config.Lifetime {
+					pool.removeTx(hash)
+					queuedEvictionCounter.Inc(1)
+					continue
+				}
+				if queued.Before(oldest) {
+					oldest = queued
+				}
+				ages[hash] = queued
+			}
+			continue
+		}
+		// Any non-locals old enough should be removed
+		if beat := pool.beats[addr]; now.Sub(beat) > pool.config.Lifetime {
+			for _, tx := range list.Flatten() {
+				pool.removeTx(tx.Hash())
+				queuedEvictionCounter.Inc(1)
+			}
+		} else if beat.Before(oldest) {
+			oldest = beat
+		}
+	}
+	pool.ages = ages
+	queuedAgeGauge.Update(int64(now.Sub(oldest) / time.Second))
+}
+
+// EvictSender drops all pending and queued transactions of the given account
+// from the pool, returning the number of transactions removed.
+func (pool *TxPool) EvictSender(addr common.Address) int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var txs types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	if list := pool.queue[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	for _, tx := range txs {
+		pool.removeTx(tx.Hash())
+	}
+	evictedTxCounter.Inc(int64(len(txs)))
+	log.Info("Evicted sender from transaction pool", "sender", addr, "transactions", len(txs))
+
+	return len(txs)
+}
+
+// AddRemotesFrom enqueues a batch of transactions relayed by the given origin
+// (the address of the sending peer) into the pool, bounding the number of
+// transactions a single origin can have pooled on top of the AddRemotes rules.
+func (pool *TxPool) AddRemotesFrom(origin string, txs []*types.Transaction) []error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.config.OriginLimit == 0 || origin == "" {
+		return pool.addTxsLocked(txs, false)
+	}
+	pooled := pool.originTxs(origin)
+
+	dirty := make(map[common.Address]struct{})
+	errs := make([]error, len(txs))
+
+	for i, tx := range txs {
+		if uint64(len(pooled)) >= pool.config.OriginLimit {
+			log.Trace("Discarding transaction over origin limit", "hash", tx.Hash(), "origin", origin)
+			limitedTxCounter.Inc(1)
+			errs[i] = ErrOriginLimit
+			continue
+		}
+		var replace bool
+		if replace, errs[i] = pool.add(tx, false); errs[i] == nil {
+			pooled[tx.Hash()] = struct{}{}
+			if !replace {
+				from, _ := types.Sender(pool.signer, tx) // already validated
+				dirty[from] = struct{}{}
+			}
+		}
+	}
+	if len(pooled) > 0 {
+		pool.origins[origin] = pooled
+	}
+	// Only reprocess the internal state if something was actually added
+	if len(dirty) > 0 {
+		addrs := make([]common.Address, 0, len(dirty))
+		for addr := range dirty {
+			addrs = append(addrs, addr)
+		}
+		pool.promoteExecutables(addrs)
+	}
+	return errs
+}
+
+// originTxs returns the set of transactions relayed by the given origin that are
+// still in the pool, forgetting the ones that have been removed since.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) originTxs(origin string) map[common.Hash]struct{} {
+	pooled := pool.origins[origin]
+	for hash := range pooled {
+		if pool.all[hash] == nil {
+			delete(pooled, hash)
+		}
+	}
+	if len(pooled) == 0 {
+		delete(pool.origins, origin)
+		return make(map[common.Hash]struct{})
+	}
+	return pooled
+}
+
+// senderTxs returns the number of transactions of the given account that are
+// pending or queued in the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) senderTxs(addr common.Address) uint64 {
+	var count int
+	if list := pool.pending[addr]; list != nil {
+		count += list.Len()
+	}
+	if list := pool.queue[addr]; list != nil {
+		count += list.Len()
+	}
+	return uint64(count)
+}
+
+// overlaps reports whether the given transaction would replace one of the same
+// account and nonce already pending or queued in the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) overlaps(addr common.Address, tx *types.Transaction) bool {
+	if list := pool.pending[addr]; list != nil && list.Overlaps(tx) {
+		return true
+	}
+	if list := pool.queue[addr]; list != nil && list.Overlaps(tx) {
+		return true
+	}
+	return false
+}
+
+// AddConditional enqueues a local transaction into the pool that may only be
+// included in a block satisfying the given conditions. Transactions whose
+// conditions can't be met by the next block any more are rejected, and dropped
+// later once their bounds expire.
+func (pool *TxPool) AddConditional(tx *types.Transaction, cond *TxConditions) error {
+	if err := cond.validate(); err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if cond.Expired(pool.chain.CurrentBlock().Header()) {
+		return ErrConditionExpired
+	}
+	if err := cond.checkState(pool.currentState); err != nil {
+		return err
+	}
+	// Register the conditions up front so the transaction is never journaled
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return fmt.Errorf("known transaction: %x", hash)
+	}
+	pool.conditions[hash] = cond
+
+	replace, err := pool.add(tx, !pool.config.NoLocals)
+	if err != nil {
+		delete(pool.conditions, hash)
+		return err
+	}
+	if !replace {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		pool.promoteExecutables([]common.Address{from})
+	}
+	return nil
+}
+
+// Conditions returns the inclusion constraints of a conditionally submitted
+// transaction, or nil if the transaction is unconditional (or unknown).
+func (pool *TxPool) Conditions(hash common.Hash) *TxConditions {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.conditions[hash]
+}
+
 // addTx enqueues a single transaction into the pool if it is valid.
 func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	pool.mu.Lock()
@@ -862,6 +1239,23 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all[hash]
 }
 
+// getByNonce returns the pending or queued transaction of the given account with
+// the given nonce, or nil if there is none in the pool.
+func (pool *TxPool) getByNonce(addr common.Address, nonce uint64) *types.Transaction {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if list := pool.pending[addr]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[addr]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash) {
@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -881,6 +882,79 @@ func testTransactionQueueTimeLimiting(t *testing.T, nolocals bool) {
 	}
 }
 
+// Tests that the transaction eviction strategy drops individual stale queued
+// transactions even if their sender keeps being active.
+func TestTransactionQueueEvictionStrategy(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	config := pool.Config()
+	config.Eviction = EvictTransactions
+	pool.SetConfig(config)
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	stale, fresh := transaction(1, 100000, key), transaction(3, 100000, key)
+	pool.AddRemotes([]*types.Transaction{stale, fresh})
+
+	// Age the first transaction beyond the lifetime and run an eviction pass
+	pool.mu.Lock()
+	pool.ages[stale.Hash()] = time.Now().Add(-2 * config.Lifetime)
+	pool.evictQueued()
+	pool.mu.Unlock()
+
+	if pool.Get(stale.Hash()) != nil {
+		t.Errorf("stale transaction not evicted")
+	}
+	if pool.Get(fresh.Hash()) == nil {
+		t.Errorf("fresh transaction evicted")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Ensure an invalid strategy is sanitized to the default
+	config.Eviction = "bogus"
+	pool.SetConfig(config)
+	if have := pool.Config().Eviction; have != EvictAccounts {
+		t.Errorf("eviction strategy mismatch: have %q, want %q", have, EvictAccounts)
+	}
+}
+
+// Tests that a sender can be forcibly evicted with all its pending and queued
+// transactions.
+func TestTransactionEvictSender(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000))
+
+	pool.AddRemotes([]*types.Transaction{
+		transaction(0, 100000, key), transaction(1, 100000, key), transaction(3, 100000, key),
+		transaction(0, 100000, other),
+	})
+	if evicted := pool.EvictSender(account); evicted != 3 {
+		t.Fatalf("evicted transactions mismatch: have %d, want %d", evicted, 3)
+	}
+	pending, queued := pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if queued != 0 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that even if the transaction count belonging to a single account goes
 // above some threshold, as long as the transactions are executable, they are
 // accepted.
@@ -1449,12 +1523,137 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that updating the pool policy at runtime enforces the new limits on the
+// current pool contents and retains the journal settings.
+func TestTransactionPoolSetConfig(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	// Queue up a batch of future transactions
+	txs := types.Transactions{}
+	for i := uint64(1); i <= 10; i++ {
+		txs = append(txs, transaction(i, 100000, key))
+	}
+	pool.AddRemotes(txs)
+
+	if _, queued := pool.Stats(); queued != 10 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 10)
+	}
+	// Shrink the queue limits and ensure the surplus is dropped
+	config := pool.Config()
+	config.AccountQueue = 4
+	config.PriceBump = 0
+	config.Journal = "ignored.rlp"
+	pool.SetConfig(config)
+
+	if _, queued := pool.Stats(); queued != 4 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	have := pool.Config()
+	if have.AccountQueue != 4 {
+		t.Errorf("account queue mismatch: have %d, want %d", have.AccountQueue, 4)
+	}
+	if have.PriceBump != DefaultTxPoolConfig.PriceBump {
+		t.Errorf("price bump not sanitized: have %d, want %d", have.PriceBump, DefaultTxPoolConfig.PriceBump)
+	}
+	if have.Journal != testTxPoolConfig.Journal {
+		t.Errorf("journal changed at runtime: have %q, want %q", have.Journal, testTxPoolConfig.Journal)
+	}
+}
+
+// Tests that the per sender admission limit bounds the number of pooled remote
+// transactions of an account, while still allowing replacements and locals.
+func TestTransactionSenderLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	config := pool.Config()
+	config.SenderLimit = 3
+	pool.SetConfig(config)
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	for i := uint64(0); i < 3; i++ {
+		if err := pool.AddRemote(transaction(i, 100000, key)); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(transaction(3, 100000, key)); err != ErrSenderLimit {
+		t.Fatalf("over limit transaction error mismatch: have %v, want %v", err, ErrSenderLimit)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace transaction at limit: %v", err)
+	}
+	if err := pool.AddLocal(transaction(3, 100000, key)); err != nil {
+		t.Fatalf("failed to add local transaction over limit: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the per origin admission limit bounds the number of pooled remote
+// transactions relayed by a single peer address.
+func TestTransactionOriginLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	config := pool.Config()
+	config.OriginLimit = 2
+	pool.SetConfig(config)
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	txs := make([]*types.Transaction, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+		txs[i] = transaction(0, 100000, keys[i])
+	}
+	errs := pool.AddRemotesFrom("10.0.0.1", txs[:3])
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("failed to add transactions under limit: %v", errs)
+	}
+	if errs[2] != ErrOriginLimit {
+		t.Fatalf("over limit transaction error mismatch: have %v, want %v", errs[2], ErrOriginLimit)
+	}
+	// Other origins are unaffected, and dropping pooled transactions frees up room
+	if errs := pool.AddRemotesFrom("10.0.0.2", txs[2:3]); errs[0] != nil {
+		t.Fatalf("failed to add transaction from other origin: %v", errs[0])
+	}
+	pool.mu.Lock()
+	pool.removeTx(txs[0].Hash())
+	pool.mu.Unlock()
+
+	if errs := pool.AddRemotesFrom("10.0.0.1", txs[3:]); errs[0] != nil {
+		t.Fatalf("failed to add transaction after room freed up: %v", errs[0])
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
-func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
-func TestTransactionJournalingNoLocals(t *testing.T) { testTransactionJournaling(t, true) }
+func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false, nil) }
+func TestTransactionJournalingNoLocals(t *testing.T) { testTransactionJournaling(t, true, nil) }
+func TestTransactionJournalingEncrypted(t *testing.T) {
+	testTransactionJournaling(t, false, []byte("journal secret"))
+}
 
-func testTransactionJournaling(t *testing.T, nolocals bool) {
+func testTransactionJournaling(t *testing.T, nolocals bool, secret []byte) {
 	t.Parallel()
 
 	// Create a temporary file for the journal
@@ -1478,6 +1677,7 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	config.NoLocals = nolocals
 	config.Journal = journal
 	config.Rejournal = time.Second
+	config.JournalSecret = secret
 
 	pool := NewTxPool(config, params.TestChainConfig, blockchain)
 
@@ -1563,6 +1763,83 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that remote transactions are persisted across pool restarts only if
+// remote journaling is enabled, and that they are not promoted to locals when
+// reloaded.
+func TestTransactionJournalingRemotes(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary directory for the journals
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := aquadb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+	config.JournalRemotes = true
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.Stop()
+
+	if _, err := os.Stat(filepath.Join(dir, "transactions.remote.rlp")); err != nil {
+		t.Fatalf("remote journal missing: %v", err)
+	}
+	// Restart the pool and ensure both locals and remotes survived
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+
+	pending, queued := pool.Stats()
+	if pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Fatalf("remote account reloaded as local")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	pool.Stop()
+
+	// Restart once more with remote journaling disabled, remotes must be gone
+	config.JournalRemotes = false
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued = pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if queued != 0 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 0)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
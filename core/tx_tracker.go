@@ -0,0 +1,264 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+const (
+	// trackerConfirmations is the number of blocks after which a mined local
+	// transaction is considered final and no longer checked for reorgs.
+	trackerConfirmations = 12
+
+	// trackerRetention is the maximum number of finalized local transactions
+	// kept around for status queries.
+	trackerRetention = 1024
+
+	// trackerTxChanSize is the size of channel listening to TxPreEvent.
+	trackerTxChanSize = 4096
+)
+
+// Lifecycle states of a local transaction.
+const (
+	LocalTxSubmitted = "submitted" // Accepted by the node, not yet seen in the pool
+	LocalTxPending   = "pending"   // Waiting in the transaction pool
+	LocalTxMined     = "mined"     // Included in a canonical block
+	LocalTxDropped   = "dropped"   // Evicted from the pool without being mined
+	LocalTxReplaced  = "replaced"  // Superseded by another transaction of the same nonce
+)
+
+// LocalTxStatus is the lifecycle status of a transaction submitted through
+// this node.
+type LocalTxStatus struct {
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Status      string          `json:"status"`
+	Submitted   time.Time       `json:"submitted"`
+	Updated     time.Time       `json:"updated"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`
+}
+
+// LocalTxEvent is posted when a tracked local transaction changes state.
+type LocalTxEvent struct{ Status LocalTxStatus }
+
+// trackedTx is a local transaction with its current lifecycle status.
+type trackedTx struct {
+	tx     *types.Transaction
+	status LocalTxStatus
+	final  bool // Whether the status can no longer change
+}
+
+// TxTracker follows the transactions submitted through this node from the
+// transaction pool into the chain, reporting their lifecycle changes to
+// subscribers.
+type TxTracker struct {
+	pool  *TxPool
+	chain blockChain
+	db    aquadb.Database
+
+	txs   map[common.Hash]*trackedTx
+	order []common.Hash // Tracking order, used to evict old finalized entries
+	mu    sync.RWMutex
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewTxTracker creates a local transaction tracker on top of the given pool and
+// chain, using db to look up transaction inclusions.
+func NewTxTracker(pool *TxPool, chain blockChain, db aquadb.Database) *TxTracker {
+	tracker := &TxTracker{
+		pool:  pool,
+		chain: chain,
+		db:    db,
+		txs:   make(map[common.Hash]*trackedTx),
+		quit:  make(chan struct{}),
+	}
+	tracker.wg.Add(1)
+	go tracker.loop()
+
+	return tracker
+}
+
+// Stop terminates the tracker and all its subscriptions.
+func (t *TxTracker) Stop() {
+	close(t.quit)
+	t.wg.Wait()
+	t.scope.Close()
+}
+
+// Track starts following the given transaction, which was just submitted
+// through this node.
+func (t *TxTracker) Track(tx *types.Transaction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hash := tx.Hash()
+	if _, ok := t.txs[hash]; ok {
+		return
+	}
+	from, _ := types.Sender(t.pool.signer, tx) // already validated by the pool
+	now := time.Now()
+
+	t.txs[hash] = &trackedTx{
+		tx: tx,
+		status: LocalTxStatus{
+			Hash:      hash,
+			From:      from,
+			Nonce:     hexutil.Uint64(tx.Nonce()),
+			Status:    LocalTxSubmitted,
+			Submitted: now,
+			Updated:   now,
+		},
+	}
+	t.order = append(t.order, hash)
+	t.update(t.txs[hash])
+}
+
+// Status returns the lifecycle status of a tracked transaction, or nil if the
+// transaction was not submitted through this node (or was forgotten since).
+func (t *TxTracker) Status(hash common.Hash) *LocalTxStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if tracked, ok := t.txs[hash]; ok {
+		status := tracked.status
+		return &status
+	}
+	return nil
+}
+
+// SubscribeLocalTxEvent registers a subscription of LocalTxEvent.
+func (t *TxTracker) SubscribeLocalTxEvent(ch chan<- LocalTxEvent) event.Subscription {
+	return t.scope.Track(t.feed.Subscribe(ch))
+}
+
+// loop re-evaluates the tracked transactions whenever the chain head changes or
+// a transaction enters the pool (possibly replacing a tracked one).
+func (t *TxTracker) loop() {
+	defer t.wg.Done()
+
+	heads := make(chan ChainHeadEvent, chainHeadChanSize)
+	headSub := t.chain.SubscribeChainHeadEvent(heads)
+	defer headSub.Unsubscribe()
+
+	txs := make(chan TxPreEvent, trackerTxChanSize)
+	txSub := t.pool.SubscribeTxPreEvent(txs)
+	defer txSub.Unsubscribe()
+
+	for {
+		select {
+		case <-heads:
+			t.refresh()
+		case <-txs:
+			t.refresh()
+		case <-headSub.Err():
+			return
+		case <-txSub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// refresh re-evaluates all non-final tracked transactions and evicts the oldest
+// finalized ones beyond the retention limit.
+func (t *TxTracker) refresh() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	final := 0
+	for _, hash := range t.order {
+		tracked := t.txs[hash]
+		if !tracked.final {
+			t.update(tracked)
+		}
+		if tracked.final {
+			final++
+		}
+	}
+	for i := 0; final > trackerRetention && i < len(t.order); {
+		if hash := t.order[i]; t.txs[hash].final {
+			delete(t.txs, hash)
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			final--
+			continue
+		}
+		i++
+	}
+}
+
+// update re-evaluates the status of a single tracked transaction, posting an
+// event if it changed.
+//
+// Note, this method assumes the tracker lock is held!
+func (t *TxTracker) update(tracked *trackedTx) {
+	var (
+		hash   = tracked.status.Hash
+		status = tracked.status
+	)
+	status.BlockHash, status.BlockNumber, status.ReplacedBy = nil, nil, nil
+
+	current := t.chain.CurrentBlock()
+	head := current.NumberU64()
+	if blockHash, number, _ := GetTxLookupEntry(t.db, hash); blockHash != (common.Hash{}) && GetCanonicalHash(t.db, number) == blockHash {
+		status.Status = LocalTxMined
+		status.BlockHash, status.BlockNumber = &blockHash, (*hexutil.Uint64)(&number)
+		tracked.final = head >= number+trackerConfirmations
+	} else if t.pool.Get(hash) != nil {
+		status.Status = LocalTxPending
+	} else if replacement := t.pool.getByNonce(status.From, uint64(status.Nonce)); replacement != nil {
+		replacedBy := replacement.Hash()
+		status.Status, status.ReplacedBy = LocalTxReplaced, &replacedBy
+	} else if statedb, err := t.chain.StateAt(current.Root()); err == nil && statedb.GetNonce(status.From) > uint64(status.Nonce) {
+		// Nonce used up by a transaction other than ours
+		status.Status = LocalTxReplaced
+		tracked.final = true
+	} else {
+		status.Status = LocalTxDropped
+		tracked.final = true
+	}
+	if status.Status == tracked.status.Status && sameHash(status.BlockHash, tracked.status.BlockHash) && sameHash(status.ReplacedBy, tracked.status.ReplacedBy) {
+		return
+	}
+	status.Updated = time.Now()
+	tracked.status = status
+
+	go t.feed.Send(LocalTxEvent{Status: status})
+}
+
+// sameHash reports whether two optional hashes are equal.
+func sameHash(a, b *common.Hash) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that the tracker follows local transactions through their lifecycle.
+func TestTxTrackerLifecycle(t *testing.T) {
+	t.Parallel()
+
+	db := aquadb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(aquadb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	tracker := NewTxTracker(pool, blockchain, db)
+	defer tracker.Stop()
+
+	events := make(chan LocalTxEvent, 16)
+	sub := tracker.SubscribeLocalTxEvent(events)
+	defer sub.Unsubscribe()
+
+	replaced, _ := crypto.GenerateKey()
+	mined, _ := crypto.GenerateKey()
+	dropped, _ := crypto.GenerateKey()
+	for _, key := range []*ecdsa.PrivateKey{replaced, mined, dropped} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+	}
+	// Submit a transaction from each account and ensure they are pending
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), replaced),
+		pricedTransaction(0, 100000, big.NewInt(1), mined),
+		pricedTransaction(0, 100000, big.NewInt(1), dropped),
+	}
+	for i, tx := range txs {
+		if err := pool.AddLocal(tx); err != nil {
+			t.Fatalf("tx %d: failed to add local transaction: %v", i, err)
+		}
+		tracker.Track(tx)
+	}
+	for i, tx := range txs {
+		if status := tracker.Status(tx.Hash()); status == nil || status.Status != LocalTxPending {
+			t.Fatalf("tx %d: status mismatch: have %v, want %s", i, status, LocalTxPending)
+		}
+	}
+	select {
+	case ev := <-events:
+		if ev.Status.Status != LocalTxPending {
+			t.Errorf("event status mismatch: have %s, want %s", ev.Status.Status, LocalTxPending)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no lifecycle event delivered")
+	}
+	// Replace the first, mine the second and drop the third transaction
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), replaced)
+	if err := pool.AddLocal(replacement); err != nil {
+		t.Fatalf("failed to add replacement transaction: %v", err)
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), Version: 1}, types.Transactions{txs[1]}, nil, nil)
+	WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	WriteTxLookupEntries(db, block)
+
+	pool.currentState.SubBalance(crypto.PubkeyToAddress(dropped.PublicKey), big.NewInt(1000000))
+	statedb.SetBalance(crypto.PubkeyToAddress(dropped.PublicKey), new(big.Int))
+	pool.lockedReset(nil, nil)
+
+	tracker.refresh()
+
+	if status := tracker.Status(txs[0].Hash()); status.Status != LocalTxReplaced || status.ReplacedBy == nil || *status.ReplacedBy != replacement.Hash() {
+		t.Errorf("replaced tx: status mismatch: have %s (by %v), want %s (by %x)", status.Status, status.ReplacedBy, LocalTxReplaced, replacement.Hash())
+	}
+	if status := tracker.Status(txs[1].Hash()); status.Status != LocalTxMined || status.BlockHash == nil || *status.BlockHash != block.Hash() {
+		t.Errorf("mined tx: status mismatch: have %s (in %v), want %s (in %x)", status.Status, status.BlockHash, LocalTxMined, block.Hash())
+	}
+	if status := tracker.Status(txs[2].Hash()); status.Status != LocalTxDropped {
+		t.Errorf("dropped tx: status mismatch: have %s, want %s", status.Status, LocalTxDropped)
+	}
+	if status := tracker.Status(common.Hash{}); status != nil {
+		t.Errorf("untracked tx: have status %v, want nil", status)
+	}
+}
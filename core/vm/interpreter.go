@@ -165,6 +165,9 @@ func (in *Interpreter) Run(contract *Contract, input []byte) (ret []byte, err er
 		if !operation.valid {
 			return nil, fmt.Errorf("invalid opcode 0x%x", int(op))
 		}
+		if opcodeMetrics.Enabled() {
+			countOpcode(op)
+		}
 		if err := operation.validateStack(stack); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,45 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"sync"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+// opcodeMetrics switches the per-opcode execution counters, which are too
+// expensive to collect all the time.
+var opcodeMetrics = metrics.NewModule("evm/opcodes", false)
+
+var (
+	opcodeCounters     [256]metrics.Counter
+	opcodeCountersOnce sync.Once
+)
+
+// countOpcode counts an executed opcode, registering the counters of all known
+// opcodes on first use.
+func countOpcode(op OpCode) {
+	opcodeCountersOnce.Do(func() {
+		for code, name := range opCodeToString {
+			opcodeCounters[code] = opcodeMetrics.Counter("evm/opcodes/"+name, nil)
+		}
+	})
+	if counter := opcodeCounters[op]; counter != nil {
+		counter.Inc(1)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"gitlab.com/aquachain/aquachain/aqua/accounts/abi"
 	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/metrics"
 	"gitlab.com/aquachain/aquachain/core/state"
 	"gitlab.com/aquachain/aquachain/core/vm"
 )
@@ -93,6 +94,47 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestOpcodeMetrics(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 10,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	count := func(name string) int64 {
+		if counter, ok := metrics.DefaultRegistry.Get("evm/opcodes/" + name).(metrics.Counter); ok {
+			return counter.Count()
+		}
+		return 0
+	}
+	if err := metrics.SetModule("evm/opcodes", true); err != nil {
+		t.Fatal(err)
+	}
+	pushes, stores := count("PUSH1"), count("MSTORE")
+	if _, _, err := Execute(code, nil, nil); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if have := count("PUSH1") - pushes; have != 4 {
+		t.Errorf("PUSH1 count mismatch: have %d, want 4", have)
+	}
+	if have := count("MSTORE") - stores; have != 1 {
+		t.Errorf("MSTORE count mismatch: have %d, want 1", have)
+	}
+	// Disabled modules must not count
+	if err := metrics.SetModule("evm/opcodes", false); err != nil {
+		t.Fatal(err)
+	}
+	pushes = count("PUSH1")
+	if _, _, err := Execute(code, nil, nil); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if have := count("PUSH1") - pushes; have != 0 {
+		t.Errorf("PUSH1 counted while disabled: %d", have)
+	}
+}
+
 func TestCall(t *testing.T) {
 	db := aquadb.NewMemDatabase()
 	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
@@ -3,24 +3,29 @@ module gitlab.com/aquachain/aquachain
 go 1.12
 
 require (
+	github.com/AndreasBriese/bbloom v0.0.0-20190306092124-e2d15f34fcf9 // indirect
 	github.com/aerth/tgun v0.1.4
 	github.com/aristanetworks/goarista v0.0.0-20180719204922-32a4de07828f
 	github.com/btcsuite/btcd v0.0.0-20180924021209-2a560b2036be
 	github.com/cespare/cp v0.1.0
+	github.com/cockroachdb/pebble v0.0.0-20200617141519-3b241b76ed3b
 	github.com/davecgh/go-spew v1.1.1
 	github.com/deckarep/golang-set v0.0.0-20180927150649-699df6a3acf6
+	github.com/dgraph-io/badger v1.5.4
+	github.com/dgryski/go-farm v0.0.0-20190423205320-6a90982ecee2 // indirect
 	github.com/edsrzf/mmap-go v0.0.0-20170320065105-0bce6a688712
 	github.com/fatih/color v0.0.0-20180516100307-2d684516a886
 	github.com/gizak/termui v0.0.0-20180614095157-19bab32e9cf4
 	github.com/go-stack/stack v1.7.0
-	github.com/golang/protobuf v0.0.0-20180720233116-427e165155e0
-	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db
+	github.com/golang/protobuf v1.2.0
+	github.com/golang/snappy v0.0.2-0.20190904063534-ff6b7dc882cf
 	github.com/hashicorp/golang-lru v0.0.0-20180201235237-0fb14efe8c47
 	github.com/huin/goupnp v0.0.0-20180415215157-1395d1447324
 	github.com/influxdata/influxdb v0.0.0-20180718194353-468497c11f25
 	github.com/jackpal/go-nat-pmp v0.0.0-20170405195558-28a68d0c24ad
 	github.com/julienschmidt/httprouter v1.2.0
 	github.com/karalabe/hid v0.0.0-20180420081245-2b4488a37358
+	github.com/klauspost/compress v1.9.7
 	github.com/kr/pretty v0.1.0
 	github.com/kr/text v0.1.0
 	github.com/maruel/panicparse v0.0.0-20180318230139-4417700b5a8d
@@ -39,12 +44,13 @@ require (
 	github.com/rs/cors v0.0.0-20180524071409-694cf2ad010f
 	github.com/stretchr/testify v1.2.2
 	github.com/syndtr/goleveldb v0.0.0-20180708030551-c4c61651e9e3
-	golang.org/x/crypto v0.0.0-20180830192347-182538f80094
-	golang.org/x/net v0.0.0-20180712200504-a1d68217f862
-	golang.org/x/sync v0.0.0-20180314180146-1d60e4601c6f
-	golang.org/x/sys v0.0.0-20180709060233-1b2967e3c290
-	golang.org/x/text v0.0.0-20180708171225-0605a8320ace
-	golang.org/x/tools v0.0.0-20180711203438-2087f8c10712
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
+	golang.org/x/sync v0.0.0-20190423024810-112230192c58
+	golang.org/x/sys v0.0.0-20200519105757-fe76b779f299
+	golang.org/x/text v0.3.0
+	golang.org/x/tools v0.0.0-20200207183749-b753a1ba74fa
+	google.golang.org/grpc v1.18.0
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127
 	gopkg.in/karalabe/cookiejar.v2 v2.0.0-20150724131613-8dcd6a7f4951
 	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce
@@ -936,6 +936,12 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0)
 }
 
+// NewRPCPendingTransaction returns a pending transaction that will serialize to
+// the RPC representation, for use by subscriptions outside of this package.
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+	return newRPCPendingTransaction(tx)
+}
+
 // newRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
 func newRPCTransactionFromBlockIndex(b *types.Block, index uint64) *RPCTransaction {
 	txs := b.Transactions()
@@ -1257,6 +1263,82 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	return submitTransaction(ctx, s.b, signed)
 }
 
+// maxSendTransactions is the maximum number of transactions accepted by a single
+// SendTransactions call.
+const maxSendTransactions = 256
+
+// SendTransactions creates a batch of transactions from a single account, assigns
+// them sequential nonces while holding the account's nonce lock, signs them and
+// submits them to the transaction pool in order. All transactions are signed
+// before any is submitted, but a submission failure leaves the transactions
+// before it in the pool.
+func (s *PublicTransactionPoolAPI) SendTransactions(ctx context.Context, args []SendTxArgs) ([]common.Hash, error) {
+	if err := checkSendBatch(args); err != nil {
+		return nil, err
+	}
+	from := args[0].From
+
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: from}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// Hold the address's mutex for the whole batch, so no other transaction of
+	// the account can grab a nonce in between
+	s.nonceLock.LockAddr(from)
+	defer s.nonceLock.UnlockAddr(from)
+
+	nonce, err := s.b.GetPoolNonce(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	var chainID *big.Int
+	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
+		chainID = config.ChainId
+	}
+	signed := make([]*types.Transaction, len(args))
+	for i := range args {
+		next := nonce + uint64(i)
+		args[i].Nonce = (*hexutil.Uint64)(&next)
+
+		if err := args[i].setDefaults(ctx, s.b); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		if signed[i], err = wallet.SignTx(account, args[i].toTransaction(), chainID); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+	}
+	hashes := make([]common.Hash, len(signed))
+	for i, tx := range signed {
+		if hashes[i], err = submitTransaction(ctx, s.b, tx); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+	}
+	return hashes, nil
+}
+
+// checkSendBatch verifies that a batch of transaction requests can be sent with
+// node assigned sequential nonces.
+func checkSendBatch(args []SendTxArgs) error {
+	if len(args) == 0 {
+		return errors.New("no transactions to send")
+	}
+	if len(args) > maxSendTransactions {
+		return fmt.Errorf("too many transactions: %d > %d", len(args), maxSendTransactions)
+	}
+	for i := range args {
+		if args[i].From != args[0].From {
+			return fmt.Errorf("transaction %d: sender %x differs from batch sender %x", i, args[i].From, args[0].From)
+		}
+		if args[i].Nonce != nil {
+			return fmt.Errorf("transaction %d: nonces are assigned by the node", i)
+		}
+	}
+	return nil
+}
+
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
@@ -1267,6 +1349,23 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return submitTransaction(ctx, s.b, tx)
 }
 
+// SendRawTransactionConditional will add the signed transaction to the transaction
+// pool, to be included only in a block satisfying the given conditions (block
+// number and timestamp bounds, required storage slot values). Conditional
+// transactions are not propagated to the network, only the local miner includes
+// them.
+func (s *PublicTransactionPoolAPI) SendRawTransactionConditional(ctx context.Context, encodedTx hexutil.Bytes, cond core.TxConditions) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.b.SendConditionalTx(ctx, tx, &cond); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Submitted conditional transaction", "fullhash", tx.Hash().Hex(), "recipient", tx.To())
+	return tx.Hash(), nil
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19AquaChain Signed Message:\n" + len(message) + message).
 //
@@ -1481,9 +1580,12 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+// SetHead rewinds the head of the blockchain to a previous block, dropping the
+// blocks above it along with their receipts and transaction lookups. If the
+// state of the block isn't available, the head falls back to the newest block
+// before it that has its state.
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) error {
+	return api.b.SetHead(uint64(number))
 }
 
 // PublicNetAPI offers network related RPC methods
@@ -0,0 +1,48 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquaapi
+
+import (
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+)
+
+func TestCheckSendBatch(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0x01")
+		bob   = common.HexToAddress("0x02")
+		nonce = hexutil.Uint64(1)
+	)
+	tests := []struct {
+		args []SendTxArgs
+		ok   bool
+	}{
+		{nil, false},
+		{[]SendTxArgs{{From: alice}}, true},
+		{[]SendTxArgs{{From: alice}, {From: alice}, {From: alice}}, true},
+		{[]SendTxArgs{{From: alice}, {From: bob}}, false},
+		{[]SendTxArgs{{From: alice}, {From: alice, Nonce: &nonce}}, false},
+		{make([]SendTxArgs, maxSendTransactions+1), false},
+	}
+	for i, tt := range tests {
+		if err := checkSendBatch(tt.args); (err == nil) != tt.ok {
+			t.Errorf("test %d: result mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquaapi
+
+import (
+	"context"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// CachePolicies returns the response cache policies of the API methods serving
+// chain data, caching results from blocks at least confirmations deep. Blocks
+// asked for by number are cached by their canonical hash, and transactions by
+// the block their lookup points to, so that the results of blocks reorged out
+// and of transactions unindexed since are not served from the cache.
+func CachePolicies(b Backend, confirmations uint64) map[string]rpc.CachePolicy {
+	final := func(number uint64) bool {
+		return number+confirmations <= b.CurrentBlock().NumberU64()
+	}
+	block := func(result interface{}) bool {
+		fields, ok := result.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		number, ok := fields["number"].(*hexutil.Big)
+		return ok && number != nil && final(number.ToInt().Uint64())
+	}
+	transaction := func(result interface{}) bool {
+		tx, ok := result.(*RPCTransaction)
+		return ok && tx != nil && tx.BlockNumber != nil && final(tx.BlockNumber.ToInt().Uint64())
+	}
+	receipt := func(result interface{}) bool {
+		fields, ok := result.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		number, ok := fields["blockNumber"].(hexutil.Uint64)
+		return ok && final(uint64(number))
+	}
+	canonical := func(params []interface{}) ([]interface{}, bool) {
+		number, ok := params[0].(rpc.BlockNumber)
+		if !ok || number < 0 {
+			return nil, false // pending or latest
+		}
+		header, err := b.HeaderByNumber(context.Background(), number)
+		if err != nil || header == nil {
+			return nil, false
+		}
+		return append([]interface{}{header.Hash()}, params[1:]...), true
+	}
+	indexed := func(params []interface{}) ([]interface{}, bool) {
+		hash, ok := params[0].(common.Hash)
+		if !ok {
+			return nil, false
+		}
+		block, _, _ := core.GetTxLookupEntry(b.ChainDb(), hash)
+		if block == (common.Hash{}) {
+			return nil, false // pending or not indexed
+		}
+		return []interface{}{hash, block}, true
+	}
+	policies := make(map[string]rpc.CachePolicy)
+	for _, namespace := range []string{"aqua", "eth"} {
+		policies[namespace+"_getBlockByHash"] = rpc.CachePolicy{Final: block}
+		policies[namespace+"_getBlockByNumber"] = rpc.CachePolicy{Final: block, Key: canonical}
+		policies[namespace+"_getTransactionByHash"] = rpc.CachePolicy{Final: transaction, Key: indexed}
+		policies[namespace+"_getTransactionReceipt"] = rpc.CachePolicy{Final: receipt, Key: indexed}
+	}
+	return policies
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquaapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// blockTimeSamples is the number of recent blocks the average block time is
+// measured over.
+const blockTimeSamples = 20
+
+// ConfirmationEstimate describes where a transaction would land among the
+// pending transactions if it was sent now.
+type ConfirmationEstimate struct {
+	Position hexutil.Uint   `json:"position"` // Pending transactions mined before it
+	GasAhead hexutil.Uint64 `json:"gasAhead"` // Gas used by the transactions mined before it
+	Blocks   hexutil.Uint64 `json:"blocks"`   // Expected number of blocks until inclusion, 1 being the next block
+	Seconds  hexutil.Uint64 `json:"seconds"`  // Expected time until inclusion at the recent block rate
+}
+
+// EstimateConfirmationTime simulates where a transaction with the given gas
+// price and gas (21000 if omitted) would be placed by a miner ordering the
+// pending transactions by price, and how many blocks it takes to be included.
+// The estimate assumes no other transactions arrive in the meantime.
+func (s *PublicAquaChainAPI) EstimateConfirmationTime(ctx context.Context, gasPrice hexutil.Big, gas *hexutil.Uint64) (*ConfirmationEstimate, error) {
+	txGas := params.TxGas
+	if gas != nil {
+		txGas = uint64(*gas)
+	}
+	head, err := s.b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, err
+	}
+	if txGas > head.GasLimit {
+		return nil, errors.New("gas exceeds block gas limit")
+	}
+	pending, _ := s.b.TxPoolContent()
+	signer := types.MakeSigner(s.b.ChainConfig(), head.Number)
+
+	estimate := estimateInclusion(signer, pending, (*big.Int)(&gasPrice), txGas, head.GasLimit)
+
+	// Convert the blocks to time using the recent average block interval
+	if number := head.Number.Uint64(); number > 0 {
+		samples := uint64(blockTimeSamples)
+		if number < samples {
+			samples = number
+		}
+		past, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(number-samples))
+		if past == nil {
+			return nil, err
+		}
+		interval := new(big.Int).Sub(head.Time, past.Time).Uint64() / samples
+		estimate.Seconds = hexutil.Uint64(uint64(estimate.Blocks) * interval)
+	}
+	return estimate, nil
+}
+
+// estimateInclusion walks the pending transactions in the order a miner would
+// include them, filling consecutive blocks up to the gas limit, until reaching
+// the first one priced below the given price. Transactions of equal price are
+// considered to be ahead, as they arrived earlier. The pending map is consumed.
+func estimateInclusion(signer types.Signer, pending map[common.Address]types.Transactions, price *big.Int, gas, gasLimit uint64) *ConfirmationEstimate {
+	var (
+		estimate = &ConfirmationEstimate{Blocks: 1}
+		blockGas uint64
+	)
+	txs := types.NewTransactionsByPriceAndNonce(signer, pending)
+	for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+		if tx.GasPrice().Cmp(price) < 0 {
+			break
+		}
+		if tx.Gas() > gasLimit {
+			// Can never be mined, skip the rest of the account
+			txs.Pop()
+			continue
+		}
+		if blockGas+tx.Gas() > gasLimit {
+			estimate.Blocks++
+			blockGas = 0
+		}
+		blockGas += tx.Gas()
+		estimate.Position++
+		estimate.GasAhead += hexutil.Uint64(tx.Gas())
+		txs.Shift()
+	}
+	if blockGas+gas > gasLimit {
+		estimate.Blocks++
+	}
+	return estimate
+}
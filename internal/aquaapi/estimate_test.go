@@ -0,0 +1,77 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package aquaapi
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+)
+
+func TestEstimateInclusion(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// pool creates pending transactions of 50000 gas, each account's prices
+	// in nonce order
+	pool := func() map[common.Address]types.Transactions {
+		pending := make(map[common.Address]types.Transactions)
+		for i, prices := range [][]int64{{10, 10}, {8, 30}, {5}} {
+			addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+			for nonce, price := range prices {
+				tx, _ := types.SignTx(types.NewTransaction(uint64(nonce), common.Address{}, nil, 50000, big.NewInt(price), nil), signer, keys[i])
+				pending[addr] = append(pending[addr], tx)
+			}
+		}
+		return pending
+	}
+	tests := []struct {
+		price    int64
+		gasLimit uint64
+		position uint
+		blocks   uint64
+	}{
+		// Above all transactions, the next block
+		{100, 1000000, 0, 1},
+		// Equal prices are ahead, and the 30 only follows its sender's 8
+		{10, 1000000, 2, 1},
+		{8, 1000000, 4, 1},
+		{1, 1000000, 5, 1},
+		// Small blocks push it back
+		{8, 100000, 4, 3},
+		{1, 100000, 5, 3},
+		{1, 120000, 5, 3},
+	}
+	for i, test := range tests {
+		estimate := estimateInclusion(signer, pool(), big.NewInt(test.price), 21000, test.gasLimit)
+		if uint(estimate.Position) != test.position {
+			t.Errorf("test %d: position mismatch: have %d, want %d", i, estimate.Position, test.position)
+		}
+		if uint64(estimate.GasAhead) != uint64(test.position)*50000 {
+			t.Errorf("test %d: gas ahead mismatch: have %d, want %d", i, estimate.GasAhead, test.position*50000)
+		}
+		if uint64(estimate.Blocks) != test.blocks {
+			t.Errorf("test %d: blocks mismatch: have %d, want %d", i, estimate.Blocks, test.blocks)
+		}
+	}
+}
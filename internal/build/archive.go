@@ -24,9 +24,36 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+var (
+	sourceDate     time.Time
+	sourceDateOnce sync.Once
+)
+
+// SourceDate returns the timestamp recorded in release artefacts instead of
+// the build time, so rebuilding the same sources yields identical archives.
+// It is taken from the SOURCE_DATE_EPOCH environment variable if set, the
+// time of the last commit otherwise.
+func SourceDate() time.Time {
+	sourceDateOnce.Do(func() {
+		epoch := os.Getenv("SOURCE_DATE_EPOCH")
+		if epoch == "" {
+			epoch = RunGit("log", "-1", "--format=%ct")
+		}
+		if secs, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			sourceDate = time.Unix(secs, 0).UTC()
+		} else {
+			sourceDate = time.Unix(0, 0).UTC()
+		}
+	})
+	return sourceDate
+}
+
 type Archive interface {
 	// Directory adds a new directory entry to the archive and sets the
 	// directory for subsequent calls to Header.
@@ -126,6 +153,7 @@ func (a *ZipArchive) Header(fi os.FileInfo) (io.Writer, error) {
 	}
 	head.Name = a.dir + head.Name
 	head.Method = zip.Deflate
+	head.Modified = SourceDate()
 	w, err := a.zipw.CreateHeader(head)
 	if err != nil {
 		return nil, fmt.Errorf("can't add zip header: %v", err)
@@ -159,6 +187,7 @@ func (a *TarballArchive) Directory(name string) error {
 		Name:     a.dir,
 		Mode:     0755,
 		Typeflag: tar.TypeDir,
+		ModTime:  SourceDate(),
 	})
 }
 
@@ -168,6 +197,8 @@ func (a *TarballArchive) Header(fi os.FileInfo) (io.Writer, error) {
 		return nil, fmt.Errorf("can't make tar header: %v", err)
 	}
 	head.Name = a.dir + head.Name
+	head.ModTime, head.AccessTime, head.ChangeTime = SourceDate(), time.Time{}, time.Time{}
+	head.Uid, head.Gid, head.Uname, head.Gname = 0, 0, "", ""
 	if err := a.tarw.WriteHeader(head); err != nil {
 		return nil, fmt.Errorf("can't add tar header: %v", err)
 	}
@@ -0,0 +1,158 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitLabRelease configures the publishing of a release on GitLab.
+type GitLabRelease struct {
+	API     string // base URL of the v4 API, e.g. https://gitlab.com/api/v4
+	Project string // path of the project, e.g. aquachain/aquachain
+	Token   string // personal or project access token with api scope
+
+	Tag         string // existing git tag the release is created for
+	Name        string // title of the release
+	Description string // release notes, in markdown
+}
+
+// GitLabLink is an asset link of a release.
+type GitLabLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// PublishGitLabRelease uploads the given files to the project and creates a
+// release for the tag linking to them. If the release exists already, the
+// links are added to it, so several jobs can publish into one release.
+func PublishGitLabRelease(rel GitLabRelease, files []string) error {
+	var links []GitLabLink
+	for _, file := range files {
+		link, err := rel.upload(file)
+		if err != nil {
+			return fmt.Errorf("can't upload %s: %v", file, err)
+		}
+		links = append(links, link)
+	}
+
+	create := map[string]interface{}{
+		"tag_name":    rel.Tag,
+		"name":        rel.Name,
+		"description": rel.Description,
+		"assets":      map[string]interface{}{"links": links},
+	}
+	err := rel.call("POST", "/releases", create, nil)
+	if apiErr, ok := err.(*gitLabError); !ok || apiErr.status != http.StatusConflict {
+		return err
+	}
+	for _, link := range links {
+		if err := rel.call("POST", "/releases/"+url.PathEscape(rel.Tag)+"/assets/links", link, nil); err != nil {
+			return fmt.Errorf("can't link %s: %v", link.Name, err)
+		}
+	}
+	return nil
+}
+
+// upload adds a file to the project uploads and returns its link.
+func (rel GitLabRelease) upload(file string) (GitLabLink, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return GitLabLink{}, err
+	}
+	defer f.Close()
+
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", filepath.Base(file))
+	if err != nil {
+		return GitLabLink{}, err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return GitLabLink{}, err
+	}
+	if err := form.Close(); err != nil {
+		return GitLabLink{}, err
+	}
+
+	var result struct {
+		URL string `json:"url"` // relative to the project's web URL
+	}
+	if err := rel.do("POST", "/uploads", form.FormDataContentType(), body, &result); err != nil {
+		return GitLabLink{}, err
+	}
+	web := strings.TrimSuffix(strings.TrimSuffix(rel.API, "/"), "/api/v4")
+	return GitLabLink{
+		Name: filepath.Base(file),
+		URL:  web + "/" + rel.Project + result.URL,
+	}, nil
+}
+
+// call sends a JSON request to a project endpoint, decoding the response
+// into result if it is non-nil.
+func (rel GitLabRelease) call(method, path string, args, result interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	return rel.do(method, path, "application/json", bytes.NewReader(body), result)
+}
+
+func (rel GitLabRelease) do(method, path, contentType string, body io.Reader, result interface{}) error {
+	endpoint := strings.TrimSuffix(rel.API, "/") + "/projects/" + url.PathEscape(rel.Project) + path
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("PRIVATE-TOKEN", rel.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &gitLabError{status: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// gitLabError is returned for API requests answered with an error status.
+type gitLabError struct {
+	status int
+	msg    string
+}
+
+func (err *gitLabError) Error() string {
+	return fmt.Sprintf("gitlab: %s: %s", http.StatusText(err.status), err.msg)
+}
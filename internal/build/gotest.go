@@ -0,0 +1,234 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package build
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// testEvent is an event printed by go test -json, see go doc test2json.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+	Elapsed float64 // seconds
+}
+
+// PackageResult is the outcome of testing a single package.
+type PackageResult struct {
+	Name    string
+	Elapsed time.Duration
+	Failed  bool
+	Output  string // output not belonging to any test
+	Tests   []*TestResult
+}
+
+// TestResult is the outcome of a single test.
+type TestResult struct {
+	Name    string
+	Elapsed time.Duration
+	Status  string // pass, fail or skip
+	Output  string
+}
+
+// TestReport collects the results of go test -json runs.
+type TestReport struct {
+	Verbose  bool // print the output of passing tests too
+	Packages []*PackageResult
+
+	pkgs  map[string]*PackageResult
+	tests map[string]*TestResult
+}
+
+// Run executes a go test -json command, printing its output the way
+// plain go test would and adding the results to the report.
+func (r *TestReport) Run(cmd *exec.Cmd) error {
+	fmt.Println(">>>", strings.Join(cmd.Args, " "))
+	if *DryRunFlag {
+		return nil
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || ev.Action == "" {
+			// Not an event, e.g. output of the build.
+			fmt.Println(scanner.Text())
+			continue
+		}
+		r.add(ev)
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+func (r *TestReport) add(ev testEvent) {
+	if r.pkgs == nil {
+		r.pkgs = make(map[string]*PackageResult)
+		r.tests = make(map[string]*TestResult)
+	}
+	pkg := r.pkgs[ev.Package]
+	if pkg == nil {
+		pkg = &PackageResult{Name: ev.Package}
+		r.pkgs[ev.Package] = pkg
+		r.Packages = append(r.Packages, pkg)
+	}
+	if ev.Test == "" {
+		switch ev.Action {
+		case "output":
+			pkg.Output += ev.Output
+			fmt.Print(ev.Output)
+		case "pass", "fail", "skip":
+			pkg.Elapsed = seconds(ev.Elapsed)
+			pkg.Failed = ev.Action == "fail"
+		}
+		return
+	}
+
+	key := ev.Package + "\x00" + ev.Test
+	test := r.tests[key]
+	if test == nil {
+		test = &TestResult{Name: ev.Test}
+		r.tests[key] = test
+		pkg.Tests = append(pkg.Tests, test)
+	}
+	switch ev.Action {
+	case "output":
+		test.Output += ev.Output
+		if r.Verbose {
+			fmt.Print(ev.Output)
+		}
+	case "pass", "fail", "skip":
+		test.Status = ev.Action
+		test.Elapsed = seconds(ev.Elapsed)
+		if ev.Action == "fail" && !r.Verbose {
+			fmt.Print(test.Output)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// PrintTimings lists the tested packages, slowest first.
+func (r *TestReport) PrintTimings(w io.Writer) {
+	pkgs := append([]*PackageResult(nil), r.Packages...)
+	sort.SliceStable(pkgs, func(i, j int) bool { return pkgs[i].Elapsed > pkgs[j].Elapsed })
+	for _, pkg := range pkgs {
+		status := "ok"
+		if pkg.Failed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%8.2fs  %-4s  %s\n", pkg.Elapsed.Seconds(), status, pkg.Name)
+	}
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test suite per package.
+// A package failing outside of its tests, e.g. because it doesn't build,
+// is reported as a failed test case named after the package.
+func (r *TestReport) WriteJUnit(file string) error {
+	var report junitSuites
+	for _, pkg := range r.Packages {
+		suite := junitSuite{Name: pkg.Name, Time: junitTime(pkg.Elapsed)}
+		failed := false
+		for _, test := range pkg.Tests {
+			tc := junitCase{Name: test.Name, Classname: pkg.Name, Time: junitTime(test.Elapsed)}
+			switch test.Status {
+			case "fail":
+				tc.Failure = &junitMessage{Message: "Failed", Output: test.Output}
+				suite.Failures++
+				failed = true
+			case "skip":
+				tc.Skipped = &junitMessage{Message: "Skipped", Output: test.Output}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		if pkg.Failed && !failed {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      pkg.Name,
+				Classname: pkg.Name,
+				Time:      junitTime(pkg.Elapsed),
+				Failure:   &junitMessage{Message: "Failed", Output: pkg.Output},
+			})
+			suite.Failures++
+		}
+		suite.Tests = len(suite.Cases)
+		report.Suites = append(report.Suites, suite)
+	}
+
+	out, err := xml.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append([]byte(xml.Header), append(out, '\n')...), 0644)
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -184,6 +184,9 @@ func ExpandPackagesNoVendor(patterns []string) (all, short, long []string) {
 			continue
 		}
 		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 		all = append(all, line)
 		if strings.HasSuffix(line, "aqua/downloader") ||
 			strings.HasSuffix(line, "aqua/fetcher") {
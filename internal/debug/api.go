@@ -22,6 +22,7 @@ package debug
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/user"
@@ -34,6 +35,7 @@ import (
 	"time"
 
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
 )
 
 // Handler is the global debugging handler.
@@ -76,6 +78,21 @@ func (*HandlerT) Vmodule(pattern string) error {
 	return glogger.Vmodule(wrapVmodule(pattern))
 }
 
+// MetricsModules returns whether each of the metrics modules, groups of metrics
+// too expensive to collect all the time, is currently collected.
+func (*HandlerT) MetricsModules() map[string]bool {
+	return metrics.Modules()
+}
+
+// SetMetricsModule switches the collection of a metrics module on or off.
+func (*HandlerT) SetMetricsModule(module string, enabled bool) error {
+	if err := metrics.SetModule(module, enabled); err != nil {
+		return fmt.Errorf("%v %q, have %v", err, module, metrics.ModuleNames())
+	}
+	log.Info("Switched metrics module", "module", module, "enabled", enabled)
+	return nil
+}
+
 // BacktraceAt sets the log backtrace location. See package log for details on
 // the pattern syntax.
 func (*HandlerT) BacktraceAt(location string) error {
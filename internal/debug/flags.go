@@ -66,6 +66,10 @@ var (
 		Usage: "pprof HTTP server listening interface",
 		Value: "127.0.0.1",
 	}
+	varsAddrFlag = cli.StringFlag{
+		Name:  "vars.addr",
+		Usage: "Listening address of a /debug/vars endpoint with node, chain and txpool counters (works without --metrics)",
+	}
 	memprofilerateFlag = cli.IntFlag{
 		Name:  "memprofilerate",
 		Usage: "Turn on memory profiling with the given rate",
@@ -88,7 +92,7 @@ var (
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
-	pprofFlag, pprofAddrFlag, pprofPortFlag,
+	pprofFlag, pprofAddrFlag, pprofPortFlag, varsAddrFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
 
@@ -108,10 +112,7 @@ func init() {
 func Setup(ctx *cli.Context) error {
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
-	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
-	glogger.Vmodule(wrapVmodule(ctx.GlobalString(vmoduleFlag.Name)))
-	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
-	log.Root().SetHandler(glogger)
+	setupLogging(ctx)
 
 	// profiling, tracing
 	runtime.MemProfileRate = ctx.GlobalInt(memprofilerateFlag.Name)
@@ -127,6 +128,13 @@ func Setup(ctx *cli.Context) error {
 		}
 	}
 
+	// always-on debug vars server
+	if address := ctx.GlobalString(varsAddrFlag.Name); address != "" {
+		if err := exp.SetupVars(address); err != nil {
+			return err
+		}
+	}
+
 	// pprof server
 	if ctx.GlobalBool(pprofFlag.Name) {
 		// Hook go-metrics into expvar on any /debug/metrics request, load all vars
@@ -145,6 +153,22 @@ func Setup(ctx *cli.Context) error {
 	return nil
 }
 
+// SetLogOutput redirects the log output to the given handler, retaining the
+// verbosity settings requested on the command line.
+func SetLogOutput(ctx *cli.Context, output log.Handler) {
+	glogger = log.NewGlogHandler(output)
+	setupLogging(ctx)
+}
+
+// setupLogging configures the glog filters from the CLI flags and installs
+// them as the root log handler.
+func setupLogging(ctx *cli.Context) {
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
+	glogger.Vmodule(wrapVmodule(ctx.GlobalString(vmoduleFlag.Name)))
+	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
+	log.Root().SetHandler(glogger)
+}
+
 // Exit stops all running profiles, flushing their output to the
 // respective file.
 func Exit() {
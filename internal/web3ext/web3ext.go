@@ -99,6 +99,28 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'backupDatabase',
+			call: 'admin_backupDatabase'
+		}),
+		new web3._extend.Method({
+			name: 'listBackups',
+			call: 'admin_listBackups'
+		}),
+		new web3._extend.Method({
+			name: 'restoreBackup',
+			call: 'admin_restoreBackup',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'compactDatabase',
+			call: 'admin_compactDatabase'
+		}),
+		new web3._extend.Method({
+			name: 'setCacheSize',
+			call: 'admin_setCacheSize',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'supply',
 			call: 'admin_supply',
@@ -135,12 +157,34 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig'
+		}),
+		new web3._extend.Method({
+			name: 'setTxPoolConfig',
+			call: 'admin_setTxPoolConfig',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'evictSender',
+			call: 'admin_evictSender',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
 			name: 'nodeInfo',
 			getter: 'admin_nodeInfo'
 		}),
+		new web3._extend.Property({
+			name: 'compactionProgress',
+			getter: 'admin_compactionProgress'
+		}),
+		new web3._extend.Property({
+			name: 'cacheStats',
+			getter: 'admin_cacheStats'
+		}),
 		new web3._extend.Property({
 			name: 'peers',
 			getter: 'admin_peers'
@@ -149,6 +193,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'txPoolConfig',
+			getter: 'admin_txPoolConfig'
+		}),
 	]
 });
 `
@@ -197,6 +245,16 @@ web3._extend({
 			call: 'debug_metrics',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'metricsModules',
+			call: 'debug_metricsModules',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setMetricsModule',
+			call: 'debug_setMetricsModule',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -318,6 +376,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -392,8 +456,53 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Method({
+			name: 'estimateConfirmationTime',
+			call: 'aqua_estimateConfirmationTime',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, function(gas) {
+				return (gas === null || gas === undefined) ? null : web3._extend.utils.fromDecimal(gas);
+			}]
+		}),
+		new web3._extend.Method({
+			name: 'getLogsPaged',
+			call: 'aqua_getLogsPaged',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getStateDiff',
+			call: function(args) {
+				return (web3._extend.utils.isString(args[0]) && args[0].length === 66) ? 'aqua_getStateDiffByHash' : 'aqua_getStateDiff';
+			},
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getLocalTxStatus',
+			call: 'aqua_getLocalTxStatus',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendTransactions',
+			call: 'aqua_sendTransactions',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendRawTransactionConditional',
+			call: 'aqua_sendRawTransactionConditional',
+			params: 2
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'chainStats',
+			getter: 'aqua_chainStats'
+		}),
+		new web3._extend.Property({
+			name: 'submitStats',
+			getter: 'aqua_submitStats'
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'aqua_pendingTransactions',
@@ -445,6 +554,26 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'setThreads',
+			call: 'miner_setThreads',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setThrottle',
+			call: 'miner_setThrottle',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setNice',
+			call: 'miner_setNice',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setAffinity',
+			call: 'miner_setAffinity',
+			params: 1
+		}),
 	],
 	properties: []
 });
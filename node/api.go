@@ -243,6 +243,15 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// ReloadConfig re-reads the node configuration and applies the settings that
+// can be changed without a restart, same as sending SIGHUP to the process.
+func (api *PrivateAdminAPI) ReloadConfig() (bool, error) {
+	if err := api.node.Reload(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -0,0 +1,80 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"reflect"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// CacheableService is implemented by services with RPC methods whose results
+// may be cached once they cannot change anymore.
+type CacheableService interface {
+	// CachePolicies returns the cache policies of the methods, keyed by their
+	// full name like "aqua_getBlockByHash".
+	CachePolicies() map[string]rpc.CachePolicy
+
+	// SubscribeCachePurge subscribes to the notifications that results deemed
+	// final may have changed after all, like when the chain is rewound. The
+	// subscription ends when the service stops.
+	SubscribeCachePurge(ch chan<- struct{}) event.Subscription
+}
+
+// responseCache returns the response cache of the HTTP and websocket RPC
+// endpoints for the methods of the given services, nil if disabled.
+func (n *Node) responseCache(services map[reflect.Type]Service) (*rpc.ResponseCache, error) {
+	if n.config.RPCCacheSize <= 0 {
+		return nil, nil
+	}
+	var cacheables []CacheableService
+	policies := make(map[string]rpc.CachePolicy)
+	for _, service := range services {
+		if cacheable, ok := service.(CacheableService); ok {
+			for method, policy := range cacheable.CachePolicies() {
+				policies[method] = policy
+			}
+			cacheables = append(cacheables, cacheable)
+		}
+	}
+	cache, err := rpc.NewResponseCache(n.config.RPCCacheSize, policies)
+	if err != nil {
+		return nil, err
+	}
+	for _, cacheable := range cacheables {
+		go purgeResponseCache(cache, cacheable)
+	}
+	return cache, nil
+}
+
+// purgeResponseCache purges the cache whenever the service says so, until the
+// service stops.
+func purgeResponseCache(cache *rpc.ResponseCache, service CacheableService) {
+	purgeCh := make(chan struct{}, 1)
+	sub := service.SubscribeCachePurge(purgeCh)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-purgeCh:
+			cache.Purge()
+		case <-sub.Err():
+			return
+		}
+	}
+}
@@ -18,21 +18,29 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/aqua/accounts"
 	"gitlab.com/aquachain/aquachain/aqua/accounts/keystore"
 	"gitlab.com/aquachain/aquachain/aqua/accounts/usbwallet"
+	"gitlab.com/aquachain/aquachain/aquadb"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/crypto"
 	"gitlab.com/aquachain/aquachain/p2p"
 	"gitlab.com/aquachain/aquachain/p2p/discover"
+	"gitlab.com/aquachain/aquachain/rpc"
 )
 
 const (
@@ -41,6 +49,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirChainDatabase   = "chaindata"          // Name of the chain database, which may be served remotely
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -91,6 +100,17 @@ type Config struct {
 	// relative), then that specific path is enforced. An empty path disables IPC.
 	IPCPath string `toml:",omitempty"`
 
+	// IPCMode is the octal file mode of the IPC socket, like "0660", and
+	// IPCGroup the name or id of the group owning it, giving other users of
+	// the group access to the node. Empty values keep the socket private to
+	// the user running the node. Both are ignored on Windows.
+	IPCMode  string `toml:",omitempty"`
+	IPCGroup string `toml:",omitempty"`
+
+	// IPCModules is a list of API modules to expose via the IPC interface. If
+	// the module list is empty, all API modules, public or not, are exposed.
+	IPCModules []string `toml:",omitempty"`
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string `toml:",omitempty"`
@@ -102,7 +122,8 @@ type Config struct {
 
 	// HTTPCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
-	// useless for custom HTTP clients.
+	// useless for custom HTTP clients. Entries may be wildcards like
+	// "https://*.example.com" and omit the scheme to match any.
 	HTTPCors []string `toml:",omitempty"`
 
 	// HTTPVirtualHosts is the list of virtual hostnames which are allowed on incoming requests.
@@ -131,7 +152,8 @@ type Config struct {
 
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
 	// aware that the server can only act upon the HTTP request the client sends and
-	// cannot verify the validity of the request header.
+	// cannot verify the validity of the request header. Entries are matched like
+	// HTTPCors.
 	WSOrigins []string `toml:",omitempty"`
 
 	// WSModules is a list of API modules to expose via the websocket RPC interface.
@@ -146,6 +168,11 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// HTTPEndpoints and WSEndpoints configure additional HTTP and websocket RPC
+	// listeners, each with its own interface and set of exposed API modules.
+	HTTPEndpoints []RPCEndpointConfig `toml:",omitempty"`
+	WSEndpoints   []RPCEndpointConfig `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -155,6 +182,102 @@ type Config struct {
 	// RPCBehindProxy if true, tried X-FORWARDED-FOR and X-REAL-IP headers to
 	// fetch client's remote IP
 	RPCBehindProxy bool
+
+	// RPCTrustedProxies are the CIDR masks of the reverse proxies whose
+	// forwarding headers are honored with RPCBehindProxy. Empty trusts only
+	// loopback peers, for a reverse proxy running on the same host.
+	RPCTrustedProxies []string `toml:",omitempty"`
+
+	// RPCMaxBodySize is the maximum size in bytes of HTTP RPC request bodies
+	// and websocket messages. Zero selects the default of 128KB.
+	RPCMaxBodySize int64 `toml:",omitempty"`
+
+	// RPCRateLimit and RPCMethodRateLimits throttle the calls of each HTTP and
+	// websocket client IP, in calls per second. RPCMethodRateLimits limits
+	// single methods, like "aqua_getLogs", RPCRateLimit all others together.
+	// Zero means unlimited, see rpc.RateLimits.
+	RPCRateLimit        float64            `toml:",omitempty"`
+	RPCMethodRateLimits map[string]float64 `toml:",omitempty"`
+
+	// RPCTimeout and RPCMethodTimeouts limit the execution time of HTTP and
+	// websocket calls, cancelling them once it is exceeded. RPCMethodTimeouts
+	// limits single methods, like "debug_traceTransaction", RPCTimeout all
+	// others. Zero means unlimited, see rpc.Timeouts.
+	RPCTimeout        time.Duration            `toml:",omitempty"`
+	RPCMethodTimeouts map[string]time.Duration `toml:",omitempty"`
+
+	// RPCBatchItemLimit is the maximum number of calls in a batch request and
+	// RPCBatchResponseMaxSize the maximum size of a batch response in bytes,
+	// over HTTP and websocket. Zero means unlimited.
+	RPCBatchItemLimit       int `toml:",omitempty"`
+	RPCBatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCNotificationQueue is the maximum number of notifications of a
+	// subscription waiting to be sent to a websocket or IPC client. Clients
+	// not keeping up are dropped from the subscription once it is reached.
+	// Zero selects the default of 10000.
+	RPCNotificationQueue int `toml:",omitempty"`
+
+	// RPCCacheSize is the number of results of HTTP and websocket RPC calls
+	// for chain data which cannot change anymore, like deep enough blocks, to
+	// keep in memory and answer repeated calls from. Zero disables caching.
+	RPCCacheSize int `toml:",omitempty"`
+
+	// JWTSecret is the path of a file holding the hex encoded 32 byte secret
+	// that HTTP and websocket RPC clients must sign their tokens with. A new
+	// secret is generated if the file does not exist. Empty disables
+	// authentication.
+	JWTSecret string `toml:",omitempty"`
+
+	// RPCTLSCert and RPCTLSKey are the paths of the PEM encoded certificate and
+	// private key to serve HTTP and websocket RPC over TLS with. If
+	// RPCTLSClientCA is set too, clients must present a certificate signed by
+	// one of the authorities in it.
+	RPCTLSCert     string `toml:",omitempty"`
+	RPCTLSKey      string `toml:",omitempty"`
+	RPCTLSClientCA string `toml:",omitempty"`
+
+	// RPCAccessLog enables the access log of the HTTP RPC endpoints. "log"
+	// writes one line per request to the node's log, anything else is the path
+	// of a file to append JSON records to. Empty disables the access log.
+	RPCAccessLog string `toml:",omitempty"`
+
+	// RPCShutdownTimeout is how long stopping an HTTP or websocket endpoint
+	// waits for the requests being served to finish before closing the
+	// connections. Zero closes them right away.
+	RPCShutdownTimeout time.Duration `toml:",omitempty"`
+
+	// HealthMinPeers and HealthMaxBlockAge are the conditions for the node to
+	// report being ready on the /ready path of the HTTP RPC endpoints, besides
+	// not syncing: the minimum number of peers and the maximum age of the head
+	// block. Zero disables the block age condition.
+	HealthMinPeers    int           `toml:",omitempty"`
+	HealthMaxBlockAge time.Duration `toml:",omitempty"`
+
+	// DatabaseEngine selects the key-value store used for new databases, see
+	// aquadb.Engines. Empty keeps the engine of existing databases and uses
+	// LevelDB for new ones.
+	DatabaseEngine string `toml:",omitempty"`
+
+	// DatabaseKeyFile is the path of a file holding the secret used to encrypt
+	// the databases and the transaction pool journal at rest. The file may be
+	// provisioned by an external key management system. Empty disables
+	// encryption.
+	DatabaseKeyFile string `toml:",omitempty"`
+
+	// DatabaseRemote is the address of a node serving its chain database, see
+	// opt/dbserver. If set, the chain is read from that node instead of the
+	// local datadir, turning this node into a read-only replica.
+	DatabaseRemote string `toml:",omitempty"`
+
+	// DatabaseRemoteKeyFile is the path of a file holding the secret shared
+	// with the node serving the chain database.
+	DatabaseRemoteKeyFile string `toml:",omitempty"`
+
+	// ServiceStartTimeout and ServiceStopTimeout bound how long the node waits
+	// for a single service to start or stop. Zero waits indefinitely.
+	ServiceStartTimeout time.Duration `toml:",omitempty"`
+	ServiceStopTimeout  time.Duration `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -182,6 +305,125 @@ func (c *Config) IPCEndpoint() string {
 	return c.IPCPath
 }
 
+// IPCPermissions parses the configured permissions of the IPC socket.
+func (c *Config) IPCPermissions() (rpc.IPCPermissions, error) {
+	perm := rpc.IPCPermissions{Group: c.IPCGroup}
+	if c.IPCMode != "" {
+		mode, err := strconv.ParseUint(c.IPCMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return perm, fmt.Errorf("invalid IPC socket mode %q", c.IPCMode)
+		}
+		perm.Mode = os.FileMode(mode)
+	}
+	return perm, nil
+}
+
+// DatabaseSecret loads the secret used to encrypt the databases at rest, or nil
+// if encryption is disabled.
+func (c *Config) DatabaseSecret() ([]byte, error) {
+	if c.DatabaseKeyFile == "" {
+		return nil, nil
+	}
+	return ReadSecretFile(c.DatabaseKeyFile)
+}
+
+// ReadSecretFile loads a secret from a file, ignoring surrounding whitespace.
+func ReadSecretFile(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	secret := []byte(strings.TrimSpace(string(blob)))
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// RPCJWTSecret loads the secret used to authenticate HTTP and websocket RPC
+// requests, creating the secret file if it does not exist yet, or nil if
+// authentication is disabled.
+func (c *Config) RPCJWTSecret() ([]byte, error) {
+	if c.JWTSecret == "" {
+		return nil, nil
+	}
+	blob, err := ioutil.ReadFile(c.JWTSecret)
+	if os.IsNotExist(err) {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(c.JWTSecret, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write jwt secret: %v", err)
+		}
+		log.Info("Generated JWT secret", "path", c.JWTSecret)
+		return secret, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt secret: %v", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+	if err != nil || len(secret) != 32 {
+		return nil, fmt.Errorf("invalid jwt secret in %s, want 32 hex encoded bytes", c.JWTSecret)
+	}
+	return secret, nil
+}
+
+// RPCTLSConfig loads the TLS configuration of the HTTP and websocket RPC
+// endpoints, or nil if they serve plain text.
+func (c *Config) RPCTLSConfig() (*tls.Config, error) {
+	if c.RPCTLSCert == "" && c.RPCTLSKey == "" {
+		if c.RPCTLSClientCA != "" {
+			return nil, errors.New("tls client CA set without a certificate and key")
+		}
+		return nil, nil
+	}
+	if c.RPCTLSCert == "" || c.RPCTLSKey == "" {
+		return nil, errors.New("tls needs both a certificate and a key")
+	}
+	return rpc.NewTLSConfig(c.RPCTLSCert, c.RPCTLSKey, c.RPCTLSClientCA)
+}
+
+// openRemoteDatabase connects to the chain database served by another node.
+func (c *Config) openRemoteDatabase() (aquadb.Database, error) {
+	if c.DatabaseRemoteKeyFile == "" {
+		return nil, errors.New("remote database requires a key file")
+	}
+	secret, err := ReadSecretFile(c.DatabaseRemoteKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return aquadb.NewRemoteDatabase(c.DatabaseRemote, secret)
+}
+
+// openDatabase opens the named database in the instance directory with the
+// configured engine. Encryption at rest and pending backup restores are only
+// supported by the LevelDB engine.
+func (c *Config) openDatabase(name string, cache, handles int) (aquadb.Database, error) {
+	path := c.resolvePath(name)
+	secret, err := c.DatabaseSecret()
+	if err != nil {
+		return nil, err
+	}
+	engine, existing := c.DatabaseEngine, aquadb.DetectEngine(path)
+	if engine == "" {
+		engine = existing
+	}
+	if engine == "" {
+		engine = aquadb.EngineLevelDB
+	}
+	if engine != aquadb.EngineLevelDB || (existing != "" && existing != engine) {
+		if secret != nil {
+			return nil, fmt.Errorf("database encryption requires the %s engine", aquadb.EngineLevelDB)
+		}
+		return aquadb.Open(engine, path, cache, handles)
+	}
+	if err := aquadb.ApplyPendingRestore(path); err != nil {
+		return nil, err
+	}
+	return aquadb.NewEncryptedLDBDatabase(path, cache, handles, secret)
+}
+
 // NodeDB returns the path to the discovery node database.
 func (c *Config) NodeDB() string {
 	if c.DataDir == "" {
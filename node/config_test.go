@@ -150,3 +150,38 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that the RPC JWT secret is generated on first use and loaded afterwards.
+func TestJWTSecretPersistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{JWTSecret: filepath.Join(dir, "jwtsecret")}
+	secret1, err := config.RPCJWTSecret()
+	if err != nil {
+		t.Fatalf("failed to generate jwt secret: %v", err)
+	}
+	if len(secret1) != 32 {
+		t.Fatalf("generated jwt secret length mismatch: have %d, want 32", len(secret1))
+	}
+	secret2, err := config.RPCJWTSecret()
+	if err != nil {
+		t.Fatalf("failed to load jwt secret: %v", err)
+	}
+	if !bytes.Equal(secret1, secret2) {
+		t.Fatalf("persisted jwt secret mismatch: have %x, want %x", secret2, secret1)
+	}
+
+	if err := ioutil.WriteFile(config.JWTSecret, []byte("0xabcd\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.RPCJWTSecret(); err == nil {
+		t.Fatalf("short jwt secret accepted")
+	}
+	if secret, err := (&Config{}).RPCJWTSecret(); secret != nil || err != nil {
+		t.Fatalf("disabled jwt secret: have %x, %v, want nil", secret, err)
+	}
+}
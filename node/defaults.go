@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"gitlab.com/aquachain/aquachain/p2p"
 	"gitlab.com/aquachain/aquachain/p2p/nat"
@@ -40,6 +41,11 @@ var DefaultConfig = Config{
 	HTTPModules: []string{"aqua", "eth", "net", "web3"},
 	WSPort:      DefaultWSPort,
 	WSModules:   []string{"aqua", "eth", "net", "web3"},
+
+	RPCBatchItemLimit:       1000,
+	RPCBatchResponseMaxSize: 25 * 1000 * 1000,
+	RPCShutdownTimeout:      5 * time.Second,
+	HealthMinPeers:          1,
 	P2P: p2p.Config{
 		ListenAddr: ":21303",
 		MaxPeers:   50,
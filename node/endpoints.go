@@ -0,0 +1,243 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// RPCEndpointConfig describes an additional HTTP or websocket RPC listener,
+// bound to its own interface and exposing its own set of API modules. It
+// allows for example serving the public aqua and net namespaces on all
+// interfaces, while keeping admin and debug reachable only from localhost.
+type RPCEndpointConfig struct {
+	// Host and Port are the interface and TCP port to listen on.
+	Host string
+	Port int
+
+	// Modules is the list of API modules to expose via this endpoint. If the
+	// list is empty, all RPC API endpoints designated public will be exposed.
+	Modules []string `toml:",omitempty"`
+
+	// Cors and VirtualHosts apply to HTTP endpoints only, see the HTTPCors and
+	// HTTPVirtualHosts fields of Config.
+	Cors         []string `toml:",omitempty"`
+	VirtualHosts []string `toml:",omitempty"`
+
+	// Origins applies to websocket endpoints only, see Config.WSOrigins.
+	Origins []string `toml:",omitempty"`
+
+	// AllowIP overrides the node wide RPCAllowIP list for this endpoint.
+	AllowIP []string `toml:",omitempty"`
+}
+
+// Endpoint resolves the listening address of the RPC endpoint.
+func (c *RPCEndpointConfig) Endpoint() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// rpcEndpoint is a running additional HTTP or websocket RPC listener.
+type rpcEndpoint struct {
+	kind     string // "HTTP" or "WebSocket"
+	endpoint string
+	listener net.Listener
+	server   *http.Server
+	handler  *rpc.Server
+}
+
+// newRPCHandler creates an RPC server exposing the APIs allowed by the given
+// module whitelist. An empty whitelist exposes all public APIs.
+func (n *Node) newRPCHandler(kind string, apis []rpc.API, modules []string, exposeAll bool) (*rpc.Server, error) {
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	handler := rpc.NewServer()
+	handler.SetMaxBodySize(n.config.RPCMaxBodySize)
+	handler.SetRateLimits(rpc.RateLimits{Default: n.config.RPCRateLimit, Methods: n.config.RPCMethodRateLimits})
+	handler.SetTimeouts(rpc.Timeouts{Default: n.config.RPCTimeout, Methods: n.config.RPCMethodTimeouts})
+	handler.SetBatchLimits(n.config.RPCBatchItemLimit, n.config.RPCBatchResponseMaxSize)
+	handler.SetNotificationQueue(n.config.RPCNotificationQueue)
+	handler.SetResponseCache(n.cache)
+	handler.SetJWTSecret(n.jwtSecret)
+	handler.SetTLSConfig(n.tlsConfig)
+	if err := handler.SetTrustedProxies(n.config.RPCTrustedProxies); err != nil {
+		return nil, err
+	}
+	if kind == "HTTP" {
+		handler.SetAccessLog(n.accessLog)
+		handler.SetHealthCheck(n.health)
+	}
+	for _, api := range apis {
+		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				return nil, err
+			}
+			n.log.Debug(kind+" registered", "service", api.Service, "namespace", api.Namespace)
+		}
+	}
+	return handler, nil
+}
+
+// openAccessLog sets up the configured access log of the HTTP RPC endpoints.
+func (n *Node) openAccessLog() error {
+	switch n.config.RPCAccessLog {
+	case "":
+		return nil
+	case "log":
+		n.accessLog = rpc.NewAccessLog(nil)
+		return nil
+	}
+	file, err := os.OpenFile(n.config.RPCAccessLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open rpc access log: %v", err)
+	}
+	n.accessLog, n.accessLogFile = rpc.NewAccessLog(file), file
+	return nil
+}
+
+// closeAccessLog closes the access log file, if any.
+func (n *Node) closeAccessLog() {
+	if n.accessLogFile != nil {
+		n.accessLogFile.Close()
+	}
+	n.accessLog, n.accessLogFile = nil, nil
+}
+
+// endpointURL formats the URL of an HTTP or websocket endpoint, using the
+// secure variant of the scheme if TLS is enabled.
+func (n *Node) endpointURL(scheme, endpoint string) string {
+	if n.tlsConfig != nil {
+		scheme += "s"
+	}
+	return fmt.Sprintf("%s://%s", scheme, endpoint)
+}
+
+// startExtraEndpoints starts all the additionally configured HTTP and websocket
+// RPC listeners, tearing all of them down if any fails.
+func (n *Node) startExtraEndpoints(apis []rpc.API) error {
+	start := func(kind string, config RPCEndpointConfig) error {
+		allowip := config.AllowIP
+		if len(allowip) == 0 {
+			allowip = n.config.RPCAllowIP
+		}
+		if kind == "HTTP" && (len(allowip) == 0 || allowip[0] == "none") {
+			n.log.Warn("No allowed IPs for HTTP endpoint, not opening", "endpoint", config.Endpoint())
+			return nil
+		}
+		handler, err := n.newRPCHandler(kind, apis, config.Modules, false)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", config.Endpoint())
+		if err != nil {
+			handler.Stop()
+			return err
+		}
+		var server *http.Server
+		if kind == "HTTP" {
+			server = rpc.NewHTTPServer(config.Cors, config.VirtualHosts, allowip, n.config.RPCBehindProxy, handler)
+			go rpc.ServeListener(server, listener)
+			n.log.Info("HTTP endpoint opened", "url", n.endpointURL("http", listener.Addr().String()), "modules", strings.Join(config.Modules, ","), "allowip", strings.Join(allowip, ","))
+		} else {
+			server = rpc.NewWSServer(config.Origins, allowip, n.config.RPCBehindProxy, handler)
+			go rpc.ServeListener(server, listener)
+			n.log.Info("WebSocket endpoint opened", "url", n.endpointURL("ws", listener.Addr().String()), "modules", strings.Join(config.Modules, ","))
+		}
+		n.extraEndpoints = append(n.extraEndpoints, &rpcEndpoint{
+			kind:     kind,
+			endpoint: listener.Addr().String(),
+			listener: listener,
+			server:   server,
+			handler:  handler,
+		})
+		return nil
+	}
+	for _, config := range n.config.HTTPEndpoints {
+		if err := start("HTTP", config); err != nil {
+			n.stopExtraEndpoints()
+			return err
+		}
+	}
+	for _, config := range n.config.WSEndpoints {
+		if err := start("WebSocket", config); err != nil {
+			n.stopExtraEndpoints()
+			return err
+		}
+	}
+	return nil
+}
+
+// stopExtraEndpoints terminates all the additional RPC listeners.
+func (n *Node) stopExtraEndpoints() {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.RPCShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, ep := range n.extraEndpoints {
+		wg.Add(1)
+		go func(ep *rpcEndpoint) {
+			defer wg.Done()
+			n.drainRPC(ctx, ep.server, ep.listener, ep.handler)
+			n.log.Info(ep.kind+" endpoint closed", "endpoint", ep.endpoint)
+		}(ep)
+	}
+	wg.Wait()
+	n.extraEndpoints = nil
+}
+
+// shutdownRPC stops an HTTP or websocket endpoint, giving the requests being
+// served RPCShutdownTimeout to finish.
+func (n *Node) shutdownRPC(server *http.Server, listener net.Listener, handler *rpc.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.RPCShutdownTimeout)
+	defer cancel()
+	n.drainRPC(ctx, server, listener, handler)
+}
+
+// drainRPC stops accepting connections on an HTTP or websocket endpoint, waits
+// for the requests being served to finish until ctx is done and then closes
+// all connections.
+func (n *Node) drainRPC(ctx context.Context, server *http.Server, listener net.Listener, handler *rpc.Server) {
+	if server != nil {
+		server.Shutdown(ctx)
+	}
+	listener.Close()
+	if err := handler.Shutdown(ctx); err != nil {
+		n.log.Warn("Aborted RPC requests on shutdown", "err", err)
+	}
+}
+
+// ExtraEndpoints retrieves the listening addresses of the additionally
+// configured HTTP and websocket RPC endpoints.
+func (n *Node) ExtraEndpoints() []string {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	endpoints := make([]string, len(n.extraEndpoints))
+	for i, ep := range n.extraEndpoints {
+		endpoints[i] = ep.endpoint
+	}
+	return endpoints
+}
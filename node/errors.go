@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"syscall"
+	"time"
 )
 
 var (
@@ -39,6 +40,21 @@ func convertFileLockError(err error) error {
 	return err
 }
 
+// DatadirUsedError is returned during Node startup if the datadir is locked by
+// another running instance. The lock is never broken, as the OS releases it when
+// its holder exits.
+type DatadirUsedError struct {
+	Owner *LockOwner // Process holding the lock, nil if not recorded
+}
+
+// Error generates a textual representation of the datadir used error.
+func (e *DatadirUsedError) Error() string {
+	if e.Owner == nil {
+		return ErrDatadirUsed.Error()
+	}
+	return fmt.Sprintf("%v (%v)", ErrDatadirUsed, e.Owner)
+}
+
 // DuplicateServiceError is returned during Node startup if a registered service
 // constructor returns a service of the same type that was already started.
 type DuplicateServiceError struct {
@@ -61,3 +77,38 @@ type StopError struct {
 func (e *StopError) Error() string {
 	return fmt.Sprintf("server: %v, services: %v", e.Server, e.Services)
 }
+
+// MissingDependencyError is returned during Node startup if a service declares
+// a dependency on a service type that was not registered.
+type MissingDependencyError struct {
+	Kind       reflect.Type
+	Dependency reflect.Type
+}
+
+// Error generates a textual representation of the missing dependency error.
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("service %v depends on unregistered service %v", e.Kind, e.Dependency)
+}
+
+// DependencyCycleError is returned during Node startup if the declared service
+// dependencies contain a cycle.
+type DependencyCycleError struct {
+	Path []reflect.Type
+}
+
+// Error generates a textual representation of the dependency cycle error.
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("service dependency cycle: %v", e.Path)
+}
+
+// ServiceTimeoutError is returned if a service fails to start or stop within
+// the configured timeout.
+type ServiceTimeoutError struct {
+	Kind    reflect.Type
+	Timeout time.Duration
+}
+
+// Error generates a textual representation of the service timeout error.
+func (e *ServiceTimeoutError) Error() string {
+	return fmt.Sprintf("service %v timed out after %v", e.Kind, e.Timeout)
+}
@@ -0,0 +1,60 @@
+// Copyright 2015 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"reflect"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// ChainStatus is implemented by services able to report the progress of their
+// chain, for the health checks of the HTTP RPC endpoints.
+type ChainStatus interface {
+	// ChainStatus returns whether the chain is being synchronised and the
+	// number and timestamp of its head block.
+	ChainStatus() (syncing bool, number uint64, timestamp time.Time)
+}
+
+// healthCheck returns the health check of the HTTP RPC endpoints, reporting the
+// peers of the given server and the chain of the first service implementing
+// ChainStatus. The node is ready once it has the configured number of peers,
+// is not syncing and its head block is recent enough.
+func (n *Node) healthCheck(server *p2p.Server, services map[reflect.Type]Service) rpc.HealthCheck {
+	var chain ChainStatus
+	for _, service := range services {
+		if status, ok := service.(ChainStatus); ok {
+			chain = status
+			break
+		}
+	}
+	minPeers, maxAge := n.config.HealthMinPeers, n.config.HealthMaxBlockAge
+	return func() rpc.Health {
+		health := rpc.Health{Peers: server.PeerCount()}
+		age := time.Duration(0)
+		if chain != nil {
+			var timestamp time.Time
+			health.Syncing, health.BlockNumber, timestamp = chain.ChainStatus()
+			age = time.Since(timestamp)
+			health.BlockAge = age.Seconds()
+		}
+		health.Ready = !health.Syncing && health.Peers >= minPeers && (maxAge == 0 || age <= maxAge)
+		return health
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DependentService is an optional interface a Service may implement to declare
+// the services it relies on. The node guarantees that all dependencies are
+// started before the service itself, and stopped only after it.
+type DependentService interface {
+	Service
+
+	// Dependencies returns the types of the services that need to be running
+	// before this one can be started, e.g. reflect.TypeOf((*aqua.AquaChain)(nil)).
+	Dependencies() []reflect.Type
+}
+
+// serviceOrder sorts the constructed services topologically according to their
+// declared dependencies. Services without any ordering constraints between them
+// keep the order in which they were constructed.
+func serviceOrder(constructed []reflect.Type, services map[reflect.Type]Service) ([]reflect.Type, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		order = make([]reflect.Type, 0, len(constructed))
+		state = make(map[reflect.Type]int)
+		visit func(kind reflect.Type, path []reflect.Type) error
+	)
+	visit = func(kind reflect.Type, path []reflect.Type) error {
+		switch state[kind] {
+		case visited:
+			return nil
+		case visiting:
+			return &DependencyCycleError{Path: append(path, kind)}
+		}
+		state[kind] = visiting
+		if dependent, ok := services[kind].(DependentService); ok {
+			for _, dep := range dependent.Dependencies() {
+				if _, exists := services[dep]; !exists {
+					return &MissingDependencyError{Kind: kind, Dependency: dep}
+				}
+				if err := visit(dep, append(path, kind)); err != nil {
+					return err
+				}
+			}
+		}
+		state[kind] = visited
+		order = append(order, kind)
+		return nil
+	}
+	for _, kind := range constructed {
+		if err := visit(kind, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// runWithTimeout executes a service lifecycle function, converting panics into
+// errors and giving up after the given timeout (zero meaning no timeout). On a
+// timeout the function keeps running in the background, but the node no longer
+// waits for it so a single misbehaving service cannot hang the whole stack.
+func runWithTimeout(kind reflect.Type, fn func() error, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errc <- fmt.Errorf("service %v panicked: %v", kind, r)
+			}
+		}()
+		errc <- fn()
+	}()
+	if timeout <= 0 {
+		return <-errc
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		return &ServiceTimeoutError{Kind: kind, Timeout: timeout}
+	}
+}
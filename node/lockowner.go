@@ -0,0 +1,70 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// datadirLockOwnerFile is the file next to the instance directory lock recording
+// which process holds the lock, reported when another instance fails to acquire it.
+const datadirLockOwnerFile = "LOCK.owner"
+
+// LockOwner describes the process holding a datadir lock.
+type LockOwner struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// String implements fmt.Stringer.
+func (o *LockOwner) String() string {
+	return fmt.Sprintf("pid %d on host %s since %v", o.PID, o.Host, o.Since.Format(time.RFC3339))
+}
+
+// readLockOwner retrieves the lock owner recorded in the instance directory,
+// or nil if none was recorded.
+func readLockOwner(instdir string) *LockOwner {
+	blob, err := ioutil.ReadFile(filepath.Join(instdir, datadirLockOwnerFile))
+	if err != nil {
+		return nil
+	}
+	owner := new(LockOwner)
+	if err := json.Unmarshal(blob, owner); err != nil {
+		return nil
+	}
+	return owner
+}
+
+// writeLockOwner records the current process as the lock owner of the instance
+// directory.
+func writeLockOwner(instdir string) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	blob, err := json.Marshal(&LockOwner{PID: os.Getpid(), Host: host, Since: time.Now()})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(instdir, datadirLockOwnerFile), blob, 0644)
+}
@@ -17,9 +17,11 @@
 package node
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -49,8 +51,12 @@ type Node struct {
 	serverConfig p2p.Config
 	server       *p2p.Server // Currently running P2P networking layer
 
-	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
+	serviceFuncs []ServiceConstructor     // Service constructors (in registration order)
 	services     map[reflect.Type]Service // Currently running services
+	serviceOrder []reflect.Type           // Order in which the running services were started
+
+	extraAPIs      []rpc.API      // RPC APIs registered directly, outside of any service
+	extraProtocols []p2p.Protocol // P2P protocols registered directly, outside of any service
 
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
@@ -62,12 +68,26 @@ type Node struct {
 	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
 	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
 	httpListener  net.Listener // HTTP RPC listener socket to server API requests
+	httpServer    *http.Server // HTTP server serving the listener (nil = no allowed IPs)
 	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
 
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
+	wsServer   *http.Server // HTTP server upgrading the listener's connections to websockets
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	extraEndpoints []*rpcEndpoint  // Additionally configured HTTP and websocket RPC listeners
+	jwtSecret      []byte          // Secret authenticating HTTP and websocket RPC requests (nil = disabled)
+	tlsConfig      *tls.Config     // TLS configuration of the HTTP and websocket RPC endpoints (nil = plain text)
+	accessLog      *rpc.AccessLog  // Access log of the HTTP RPC endpoints (nil = disabled)
+	accessLogFile  *os.File        // File the access log is written to, if any
+	health         rpc.HealthCheck // Health check served by the HTTP RPC endpoints
+
+	cache *rpc.ResponseCache // Results cache of the HTTP and websocket RPC endpoints (nil = disabled)
+
+	reloadHandler func() error // Invoked to reload the configuration, nil if unsupported
+	reloadLock    sync.Mutex   // Serializes configuration reloads
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -135,6 +155,33 @@ func (n *Node) Register(constructor ServiceConstructor) error {
 	return nil
 }
 
+// RegisterAPIs injects additional RPC APIs into the node's stack without the
+// need to wrap them into a full blown service. The APIs are exposed on the
+// various endpoints according to the same whitelisting rules as service APIs.
+func (n *Node) RegisterAPIs(apis []rpc.API) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	n.extraAPIs = append(n.extraAPIs, apis...)
+	return nil
+}
+
+// RegisterProtocols injects additional P2P protocols into the node's stack
+// without the need to wrap them into a full blown service.
+func (n *Node) RegisterProtocols(protocols []p2p.Protocol) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	n.extraProtocols = append(n.extraProtocols, protocols...)
+	return nil
+}
+
 // Start create a live P2P node and starts running it.
 func (n *Node) Start() error {
 	n.lock.Lock()
@@ -171,6 +218,7 @@ func (n *Node) Start() error {
 
 	// Otherwise copy and specialize the P2P configuration
 	services := make(map[reflect.Type]Service)
+	constructed := []reflect.Type{}
 	for _, constructor := range n.serviceFuncs {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
@@ -191,27 +239,33 @@ func (n *Node) Start() error {
 		if _, exists := services[kind]; exists {
 			return &DuplicateServiceError{Kind: kind}
 		}
+		if service == nil {
+			log.Warn("skipping service:", "kind", kind)
+			continue
+		}
 		services[kind] = service
+		constructed = append(constructed, kind)
+	}
+	// Order the services so that dependencies are started first
+	order, err := serviceOrder(constructed, services)
+	if err != nil {
+		return err
 	}
 	// Gather the protocols and start the freshly assembled P2P server
-	for _, service := range services {
-		running.Protocols = append(running.Protocols, service.Protocols()...)
+	for _, kind := range order {
+		running.Protocols = append(running.Protocols, services[kind].Protocols()...)
 	}
+	running.Protocols = append(running.Protocols, n.extraProtocols...)
 	if err := running.Start(); err != nil {
 		return convertFileLockError(err)
 	}
 	// Start each of the services
 	started := []reflect.Type{}
-	for kind, service := range services {
+	for _, kind := range order {
 		// Start the next service, stopping all previous upon failure
-		if service == nil {
-			log.Warn("skipping service:", "kind", kind)
-			continue
-		}
-		if err := service.Start(running); err != nil {
-			for _, kind := range started {
-				services[kind].Stop()
-			}
+		service := services[kind]
+		if err := runWithTimeout(kind, func() error { return service.Start(running) }, n.config.ServiceStartTimeout); err != nil {
+			n.stopServices(started, services)
 			running.Stop()
 
 			return err
@@ -220,17 +274,18 @@ func (n *Node) Start() error {
 		started = append(started, kind)
 	}
 	// Lastly start the configured RPC interfaces
+	n.health = n.healthCheck(running, services)
 	if err := n.startRPC(services); err != nil {
-		for _, service := range services {
-			service.Stop()
-		}
+		n.stopServices(started, services)
 		running.Stop()
 		return err
 	}
 	// Finish initializing the startup
 	n.services = services
+	n.serviceOrder = order
 	n.server = running
 	n.stop = make(chan struct{})
+	publishVars(running, n.config.Version)
 
 	return nil
 }
@@ -246,9 +301,24 @@ func (n *Node) openDataDir() error {
 	}
 	// Lock the instance directory to prevent concurrent use by another instance as well as
 	// accidental use of the instance directory as a database.
-	release, _, err := flock.New(filepath.Join(instdir, "LOCK"))
+	lockfile := filepath.Join(instdir, "LOCK")
+	release, _, err := flock.New(lockfile)
 	if err != nil {
-		return convertFileLockError(err)
+		err = convertFileLockError(err)
+		// A held lock always has a live owner, as the OS drops it when the holder
+		// exits. Report who that is, but never break the lock.
+		if err == ErrDatadirUsed {
+			return &DatadirUsedError{Owner: readLockOwner(instdir)}
+		}
+		return err
+	}
+	// Lock acquired, any recorded owner is a leftover of an unclean shutdown
+	if owner := readLockOwner(instdir); owner != nil {
+		n.log.Warn("Previous instance did not shut down cleanly", "pid", owner.PID, "host", owner.Host, "since", owner.Since)
+	}
+	if err := writeLockOwner(instdir); err != nil {
+		release.Release()
+		return err
 	}
 	n.instanceDirLock = release
 	return nil
@@ -263,23 +333,53 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	apis = append(apis, n.extraAPIs...)
+	secret, err := n.config.RPCJWTSecret()
+	if err != nil {
+		return err
+	}
+	n.jwtSecret = secret
+	if n.tlsConfig, err = n.config.RPCTLSConfig(); err != nil {
+		return err
+	}
+	if n.cache, err = n.responseCache(services); err != nil {
+		return err
+	}
+	if err := n.openAccessLog(); err != nil {
+		return err
+	}
+	if n.config.RPCBehindProxy && len(n.config.RPCTrustedProxies) == 0 {
+		n.log.Info("Trusting forwarded client IPs from loopback only, set the trusted reverse proxies of other hosts")
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
+		n.closeAccessLog()
 		return err
 	}
 	if err := n.startIPC(apis); err != nil {
 		n.stopInProc()
+		n.closeAccessLog()
 		return err
 	}
 	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.RPCAllowIP, n.config.RPCBehindProxy); err != nil {
 		n.stopIPC()
 		n.stopInProc()
+		n.closeAccessLog()
 		return err
 	}
 	if err := n.startWS(n.wsEndpoint, apis, n.config.WSModules, n.config.WSOrigins, n.config.WSExposeAll, n.config.RPCAllowIP, n.config.RPCBehindProxy); err != nil {
 		n.stopHTTP()
 		n.stopIPC()
 		n.stopInProc()
+		n.closeAccessLog()
+		return err
+	}
+	if err := n.startExtraEndpoints(apis); err != nil {
+		n.stopWS()
+		n.stopHTTP()
+		n.stopIPC()
+		n.stopInProc()
+		n.closeAccessLog()
 		return err
 	}
 	// All API endpoints started successfully
@@ -315,20 +415,29 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	if n.ipcEndpoint == "" {
 		return nil
 	}
-	// Register all the APIs exposed by the services
+	// Register all the APIs exposed by the services, or just the whitelisted ones
+	whitelist := make(map[string]bool)
+	for _, module := range n.config.IPCModules {
+		whitelist[module] = true
+	}
 	handler := rpc.NewServer()
+	handler.SetNotificationQueue(n.config.RPCNotificationQueue)
 	for _, api := range apis {
+		if len(whitelist) > 0 && !whitelist[api.Namespace] {
+			continue
+		}
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
 		}
 		n.log.Debug("IPC registered", "service", api.Service, "namespace", api.Namespace)
 	}
 	// All APIs registered, start the IPC listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = rpc.CreateIPCListener(n.ipcEndpoint); err != nil {
+	perm, err := n.config.IPCPermissions()
+	if err != nil {
+		return err
+	}
+	listener, err := rpc.CreateIPCListenerPerm(n.ipcEndpoint, perm)
+	if err != nil {
 		return err
 	}
 	go func() {
@@ -378,38 +487,28 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	// Generate the whitelist based on the allowed modules
-	whitelist := make(map[string]bool)
-	for _, module := range modules {
-		whitelist[module] = true
-	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
-	for _, api := range apis {
-		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return err
-			}
-			n.log.Debug("HTTP registered", "service", api.Service, "namespace", api.Namespace)
-		}
+	handler, err := n.newRPCHandler("HTTP", apis, modules, false)
+	if err != nil {
+		return err
 	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
 		return err
 	}
+	var server *http.Server
 	if len(allowip) == 0 || allowip[0] == "none" {
 		n.log.Warn("The '-allowip' flag has not been set. Please consider using it to restrict RPC access. HTTP server disabled. To allow any IP, use -allowip='*'")
 	} else {
-		go rpc.NewHTTPServer(cors, vhosts, allowip, behindreverseproxy, handler).Serve(listener)
-		n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","), "allowip", strings.Join(allowip, ","))
+		server = rpc.NewHTTPServer(cors, vhosts, allowip, behindreverseproxy, handler)
+		go rpc.ServeListener(server, listener)
+		n.log.Info("HTTP endpoint opened", "url", n.endpointURL("http", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","), "allowip", strings.Join(allowip, ","))
 	}
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
+	n.httpServer = server
 	n.httpHandler = handler
 
 	return nil
@@ -418,14 +517,10 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 // stopHTTP terminates the HTTP RPC endpoint.
 func (n *Node) stopHTTP() {
 	if n.httpListener != nil {
-		n.httpListener.Close()
-		n.httpListener = nil
+		n.shutdownRPC(n.httpServer, n.httpListener, n.httpHandler)
+		n.httpListener, n.httpServer, n.httpHandler = nil, nil, nil
 
-		n.log.Info("HTTP endpoint closed", "url", fmt.Sprintf("http://%s", n.httpEndpoint))
-	}
-	if n.httpHandler != nil {
-		n.httpHandler.Stop()
-		n.httpHandler = nil
+		n.log.Info("HTTP endpoint closed", "url", n.endpointURL("http", n.httpEndpoint))
 	}
 }
 
@@ -435,35 +530,24 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	// Generate the whitelist based on the allowed modules
-	whitelist := make(map[string]bool)
-	for _, module := range modules {
-		whitelist[module] = true
-	}
 	// Register all the APIs exposed by the services
-	handler := rpc.NewServer()
-	for _, api := range apis {
-		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
-			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
-				return err
-			}
-			n.log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
-		}
+	handler, err := n.newRPCHandler("WebSocket", apis, modules, exposeAll)
+	if err != nil {
+		return err
 	}
 	// All APIs registered, start the HTTP listener
-	var (
-		listener net.Listener
-		err      error
-	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
 		return err
 	}
-	go rpc.NewWSServer(wsOrigins, allowedip, behindproxy, handler).Serve(listener)
-	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
+	server := rpc.NewWSServer(wsOrigins, allowedip, behindproxy, handler)
+	go rpc.ServeListener(server, listener)
+	n.log.Info("WebSocket endpoint opened", "url", n.endpointURL("ws", listener.Addr().String()))
 
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
 	n.wsListener = listener
+	n.wsServer = server
 	n.wsHandler = handler
 
 	return nil
@@ -472,14 +556,10 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 // stopWS terminates the websocket RPC endpoint.
 func (n *Node) stopWS() {
 	if n.wsListener != nil {
-		n.wsListener.Close()
-		n.wsListener = nil
+		n.shutdownRPC(n.wsServer, n.wsListener, n.wsHandler)
+		n.wsListener, n.wsServer, n.wsHandler = nil, nil, nil
 
-		n.log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
-	}
-	if n.wsHandler != nil {
-		n.wsHandler.Stop()
-		n.wsHandler = nil
+		n.log.Info("WebSocket endpoint closed", "url", n.endpointURL("ws", n.wsEndpoint))
 	}
 }
 
@@ -495,24 +575,24 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopExtraEndpoints()
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
+	n.closeAccessLog()
 	n.rpcAPIs = nil
+	unpublishVars(n.server)
 	failure := &StopError{
-		Services: make(map[reflect.Type]error),
-	}
-	for kind, service := range n.services {
-		if err := service.Stop(); err != nil {
-			failure.Services[kind] = err
-		}
+		Services: n.stopServices(n.serviceOrder, n.services),
 	}
 	n.server.Stop()
 	n.services = nil
+	n.serviceOrder = nil
 	n.server = nil
 
 	// Release instance directory lock.
 	if n.instanceDirLock != nil {
+		os.Remove(filepath.Join(n.config.DataDir, n.config.name(), datadirLockOwnerFile))
 		if err := n.instanceDirLock.Release(); err != nil {
 			n.log.Error("Can't release datadir lock", "err", err)
 		}
@@ -537,6 +617,21 @@ func (n *Node) Stop() error {
 	return nil
 }
 
+// stopServices terminates the given services in the reverse order of starting
+// them. A failing or hanging service does not prevent the rest from stopping,
+// its error is collected and returned instead.
+func (n *Node) stopServices(started []reflect.Type, services map[reflect.Type]Service) map[reflect.Type]error {
+	failures := make(map[reflect.Type]error)
+	for i := len(started) - 1; i >= 0; i-- {
+		kind := started[i]
+		if err := runWithTimeout(kind, services[kind].Stop, n.config.ServiceStopTimeout); err != nil {
+			n.log.Error("Service failed to stop", "kind", kind, "err", err)
+			failures[kind] = err
+		}
+	}
+	return failures
+}
+
 // Wait blocks the thread until the node is stopped. If the node is not running
 // at the time of invocation, the method immediately returns.
 func (n *Node) Wait() {
@@ -663,7 +758,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (aquadb.Database, e
 	if n.config.DataDir == "" {
 		return aquadb.NewMemDatabase(), nil
 	}
-	return aquadb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	return n.config.openDatabase(name, cache, handles)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
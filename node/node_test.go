@@ -17,16 +17,21 @@
 package node
 
 import (
+	"encoding/json"
 	"errors"
+	"expvar"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
 
 	"gitlab.com/aquachain/aquachain/crypto"
 	"gitlab.com/aquachain/aquachain/p2p"
 	"gitlab.com/aquachain/aquachain/rpc"
+	rpcclient "gitlab.com/aquachain/aquachain/rpc/rpcclient"
 )
 
 var (
@@ -98,8 +103,42 @@ func TestNodeUsedDataDir(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create duplicate protocol stack: %v", err)
 	}
-	if err := duplicate.Start(); err != ErrDatadirUsed {
-		t.Fatalf("duplicate datadir failure mismatch: have %v, want %v", err, ErrDatadirUsed)
+	err = duplicate.Start()
+	used, ok := err.(*DatadirUsedError)
+	if !ok {
+		t.Fatalf("duplicate datadir failure mismatch: have %v, want %T", err, used)
+	}
+	if used.Owner == nil || used.Owner.PID != os.Getpid() {
+		t.Fatalf("lock owner mismatch: have %v, want pid %d", used.Owner, os.Getpid())
+	}
+}
+
+// Tests that the running instance is recorded as the datadir lock owner and
+// that the record is removed on a clean shutdown.
+func TestNodeLockOwner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{DataDir: dir, P2P: testp2p}
+	instdir := filepath.Join(dir, config.name())
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if owner := readLockOwner(instdir); owner == nil || owner.PID != os.Getpid() {
+		t.Errorf("lock owner mismatch: have %v, want pid %d", owner, os.Getpid())
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if owner := readLockOwner(instdir); owner != nil {
+		t.Errorf("lock owner left behind after stop: %v", owner)
 	}
 }
 
@@ -573,3 +612,365 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that APIs and protocols registered directly on the node, without any
+// wrapping service, get exposed and launched.
+func TestDirectRegistration(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	calls := make(chan string, 1)
+	apis := []rpc.API{
+		{Namespace: "extra", Version: "1", Service: &OneMethodApi{fun: func() { calls <- "extra" }}, Public: true},
+	}
+	if err := stack.RegisterAPIs(apis); err != nil {
+		t.Fatalf("failed to register APIs: %v", err)
+	}
+	if err := stack.RegisterProtocols([]p2p.Protocol{{Name: "extra", Version: 1}}); err != nil {
+		t.Fatalf("failed to register protocols: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	if err := stack.RegisterAPIs(apis); err != ErrNodeRunning {
+		t.Fatalf("running registration mismatch: have %v, want %v", err, ErrNodeRunning)
+	}
+	if protocols := stack.Server().Protocols; len(protocols) != 1 || protocols[0].Name != "extra" {
+		t.Fatalf("protocol mismatch: have %v", protocols)
+	}
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to connect to the inproc API server: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call(nil, "extra_theOneMethod"); err != nil {
+		t.Fatalf("API request failed: %v", err)
+	}
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatalf("rpc execution timeout")
+	}
+}
+
+// Tests that globally registered plugins are injected into a node on request.
+func TestPluginRegistration(t *testing.T) {
+	RegisterPlugin("test-noop", NewNoopService)
+	defer func() {
+		pluginsLock.Lock()
+		delete(plugins, "test-noop")
+		pluginsLock.Unlock()
+	}()
+
+	if names := Plugins(); len(names) != 1 || names[0] != "test-noop" {
+		t.Fatalf("plugin list mismatch: have %v", names)
+	}
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.RegisterPlugins(); err != nil {
+		t.Fatalf("failed to register plugins: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	var noop *NoopService
+	if err := stack.Service(&noop); err != nil {
+		t.Fatalf("plugin service retrieval mismatch: have %v, want %v", err, nil)
+	}
+}
+
+// Tests that services are started in dependency order and stopped in reverse,
+// regardless of the order in which they were registered.
+func TestServiceDependencyOrder(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var (
+		events = []string{}
+		typeA  = reflect.TypeOf(&DependentServiceA{})
+		typeB  = reflect.TypeOf(&DependentServiceB{})
+	)
+	hook := func(base *DependentServiceBase, id string, deps ...reflect.Type) {
+		base.deps = deps
+		base.startHook = func(*p2p.Server) { events = append(events, "start "+id) }
+		base.stopHook = func() { events = append(events, "stop "+id) }
+	}
+	constructors := []ServiceConstructor{
+		func(*ServiceContext) (Service, error) {
+			s := new(DependentServiceC)
+			hook(&s.DependentServiceBase, "C", typeB)
+			return s, nil
+		},
+		func(*ServiceContext) (Service, error) {
+			s := new(DependentServiceB)
+			hook(&s.DependentServiceBase, "B", typeA)
+			return s, nil
+		},
+		func(*ServiceContext) (Service, error) {
+			s := new(DependentServiceA)
+			hook(&s.DependentServiceBase, "A")
+			return s, nil
+		},
+	}
+	for i, constructor := range constructors {
+		if err := stack.Register(constructor); err != nil {
+			t.Fatalf("service #%d: registration failed: %v", i, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	want := []string{"start A", "start B", "start C", "stop C", "stop B", "stop A"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("lifecycle order mismatch: have %v, want %v", events, want)
+	}
+}
+
+// Tests that missing and cyclic service dependencies are reported on startup.
+func TestServiceDependencyErrors(t *testing.T) {
+	typeA := reflect.TypeOf(&DependentServiceA{})
+	typeB := reflect.TypeOf(&DependentServiceB{})
+
+	// A service depending on an unregistered one must abort startup
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.Register(func(*ServiceContext) (Service, error) {
+		s := new(DependentServiceA)
+		s.deps = []reflect.Type{typeB}
+		return s, nil
+	})
+	if err := stack.Start(); err == nil {
+		t.Fatalf("missing dependency accepted")
+	} else if _, ok := err.(*MissingDependencyError); !ok {
+		t.Fatalf("error type mismatch: have %T, want %T", err, new(MissingDependencyError))
+	}
+	// Services depending on each other must abort startup
+	stack, err = New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.Register(func(*ServiceContext) (Service, error) {
+		s := new(DependentServiceA)
+		s.deps = []reflect.Type{typeB}
+		return s, nil
+	})
+	stack.Register(func(*ServiceContext) (Service, error) {
+		s := new(DependentServiceB)
+		s.deps = []reflect.Type{typeA}
+		return s, nil
+	})
+	if err := stack.Start(); err == nil {
+		t.Fatalf("dependency cycle accepted")
+	} else if _, ok := err.(*DependencyCycleError); !ok {
+		t.Fatalf("error type mismatch: have %T, want %T", err, new(DependencyCycleError))
+	}
+}
+
+// Tests that a service hanging on shutdown does not block the node from
+// stopping the rest of its services.
+func TestServiceStopTimeout(t *testing.T) {
+	config := testNodeConfig()
+	config.ServiceStopTimeout = 50 * time.Millisecond
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+
+	stopped := false
+	stack.Register(InstrumentedServiceMakerA(func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{stopHook: func() { stopped = true }}, nil
+	}))
+	stack.Register(InstrumentedServiceMakerB(func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{stopHook: func() { <-release }}, nil
+	}))
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	err = stack.Stop()
+	failure, ok := err.(*StopError)
+	if !ok {
+		t.Fatalf("termination failure mismatch: have %v, want StopError", err)
+	}
+	if _, ok := failure.Services[reflect.TypeOf(&InstrumentedServiceB{})].(*ServiceTimeoutError); !ok {
+		t.Fatalf("timeout failure mismatch: have %v", failure.Services)
+	}
+	if !stopped {
+		t.Fatalf("healthy service not stopped")
+	}
+}
+
+// Tests that additionally configured RPC endpoints expose only their own set
+// of API modules.
+func TestExtraRPCEndpoints(t *testing.T) {
+	config := testNodeConfig()
+	config.RPCAllowIP = []string{"127.0.0.1/32"}
+	config.HTTPEndpoints = []RPCEndpointConfig{
+		{Host: "127.0.0.1", Modules: []string{"web3"}},
+		{Host: "127.0.0.1", Modules: []string{"admin"}},
+	}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	endpoints := stack.ExtraEndpoints()
+	if len(endpoints) != 2 {
+		t.Fatalf("endpoint count mismatch: have %d, want %d", len(endpoints), 2)
+	}
+	for i, want := range []string{"web3", "admin"} {
+		client, err := rpcclient.Dial("http://" + endpoints[i])
+		if err != nil {
+			t.Fatalf("endpoint %d: failed to dial: %v", i, err)
+		}
+		modules, err := client.SupportedModules()
+		client.Close()
+		if err != nil {
+			t.Fatalf("endpoint %d: failed to retrieve modules: %v", i, err)
+		}
+		if len(modules) != 2 || modules[want] == "" {
+			t.Errorf("endpoint %d: module mismatch: have %v, want %s", i, modules, want)
+		}
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if endpoints := stack.ExtraEndpoints(); len(endpoints) != 0 {
+		t.Fatalf("endpoints left open after stop: %v", endpoints)
+	}
+}
+
+// Tests that the IPC endpoint exposes only the whitelisted API modules, if any.
+func TestIPCModules(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("IPC endpoint is a named pipe on windows")
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.IPCPath = filepath.Join(dir, "test.ipc")
+	config.IPCModules = []string{"web3"}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	client, err := rpcclient.Dial(stack.IPCEndpoint())
+	if err != nil {
+		t.Fatalf("failed to dial IPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	modules, err := client.SupportedModules()
+	if err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	if len(modules) != 2 || modules["web3"] == "" {
+		t.Errorf("module mismatch: have %v, want web3", modules)
+	}
+}
+
+// Tests that configuration reloads are delegated to the installed handler and
+// that the reloadable settings are applied to a running node.
+func TestNodeReload(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPEndpoints = []RPCEndpointConfig{{Host: "127.0.0.1", Modules: []string{"web3"}}}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Reload(); err != ErrReloadUnsupported {
+		t.Fatalf("reload error mismatch: have %v, want %v", err, ErrReloadUnsupported)
+	}
+	if err := stack.Reconfigure(config); err != ErrNodeStopped {
+		t.Fatalf("reconfigure error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	// Without any allowed IPs, the HTTP endpoint must not be opened
+	if endpoints := stack.ExtraEndpoints(); len(endpoints) != 0 {
+		t.Fatalf("endpoints opened without allowed IPs: %v", endpoints)
+	}
+	reloaded := *config
+	reloaded.P2P.MaxPeers = 3
+	reloaded.RPCAllowIP = []string{"127.0.0.1/32"}
+
+	stack.SetReloadHandler(func() error { return stack.Reconfigure(&reloaded) })
+	if err := stack.Reload(); err != nil {
+		t.Fatalf("failed to reload configuration: %v", err)
+	}
+	if max := stack.Config().P2P.MaxPeers; max != 3 {
+		t.Errorf("peer limit mismatch: have %d, want %d", max, 3)
+	}
+	endpoints := stack.ExtraEndpoints()
+	if len(endpoints) != 1 {
+		t.Fatalf("endpoint count mismatch: have %d, want %d", len(endpoints), 1)
+	}
+	client, err := rpcclient.Dial("http://" + endpoints[0])
+	if err != nil {
+		t.Fatalf("failed to dial reloaded endpoint: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.SupportedModules(); err != nil {
+		t.Fatalf("failed to query reloaded endpoint: %v", err)
+	}
+}
+
+// Tests that a running node reports its counters in the "node" expvar.
+func TestNodeVars(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	var vars struct {
+		Name  string
+		Peers *int
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("node").String()), &vars); err != nil {
+		t.Fatalf("failed to decode node vars: %v", err)
+	}
+	if vars.Name != stack.Server().Name || vars.Peers == nil || *vars.Peers != 0 {
+		t.Errorf("node vars mismatch: have %+v", vars)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop node: %v", err)
+	}
+	if have := expvar.Get("node").String(); have != "null" {
+		t.Errorf("stopped node still reported: %s", have)
+	}
+}
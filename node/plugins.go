@@ -0,0 +1,74 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	pluginsLock sync.RWMutex
+	plugins     = make(map[string]ServiceConstructor)
+)
+
+// RegisterPlugin makes a named service constructor available to any node that
+// calls RegisterPlugins. It is meant to be called from the init function of
+// an external package, so that linking the package into a binary (e.g. via a
+// blank import) is enough to extend the node with custom protocols, background
+// workers or RPC namespaces.
+//
+// Registering two plugins with the same name panics.
+func RegisterPlugin(name string, constructor ServiceConstructor) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	if _, exists := plugins[name]; exists {
+		panic(fmt.Sprintf("node: plugin %q registered twice", name))
+	}
+	plugins[name] = constructor
+}
+
+// Plugins returns the names of all the registered plugins, sorted.
+func Plugins() []string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterPlugins injects all the globally registered plugins into the node's
+// stack, in the order of their names.
+func (n *Node) RegisterPlugins() error {
+	for _, name := range Plugins() {
+		pluginsLock.RLock()
+		constructor := plugins[name]
+		pluginsLock.RUnlock()
+
+		if err := n.Register(constructor); err != nil {
+			return fmt.Errorf("plugin %s: %v", name, err)
+		}
+		n.log.Debug("Registered node plugin", "name", name)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrReloadUnsupported is returned if a configuration reload is requested but
+// no reload handler was installed on the node.
+var ErrReloadUnsupported = errors.New("configuration reload not supported")
+
+// SetReloadHandler installs the function invoked whenever a configuration
+// reload is requested, either via SIGHUP or the admin_reloadConfig RPC call.
+// The handler is expected to re-read the configuration and apply the safe
+// subset of it, e.g. via Reconfigure.
+func (n *Node) SetReloadHandler(handler func() error) {
+	n.reloadLock.Lock()
+	defer n.reloadLock.Unlock()
+
+	n.reloadHandler = handler
+}
+
+// Reload runs the installed reload handler. Concurrent reloads are serialized.
+func (n *Node) Reload() error {
+	n.reloadLock.Lock()
+	defer n.reloadLock.Unlock()
+
+	if n.reloadHandler == nil {
+		return ErrReloadUnsupported
+	}
+	n.log.Info("Reloading configuration")
+	if err := n.reloadHandler(); err != nil {
+		n.log.Error("Configuration reload failed", "err", err)
+		return err
+	}
+	n.log.Info("Configuration reloaded")
+	return nil
+}
+
+// Reconfigure applies the subset of the given configuration that can be changed
+// on a running node: the peer limit, the HTTP CORS domains, the websocket
+// origins and the IPs allowed to access the RPC endpoints. Endpoints whose
+// access settings changed are restarted, peer connections are left untouched.
+// All other fields of the configuration are ignored.
+func (n *Node) Reconfigure(config *Config) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server == nil {
+		return ErrNodeStopped
+	}
+	if max := config.P2P.MaxPeers; max != n.config.P2P.MaxPeers {
+		n.log.Info("Updating peer limit", "old", n.config.P2P.MaxPeers, "new", max)
+		n.server.SetMaxPeers(max)
+		n.config.P2P.MaxPeers = max
+		n.serverConfig.MaxPeers = max
+	}
+	allowipChanged := !reflect.DeepEqual(config.RPCAllowIP, n.config.RPCAllowIP)
+	n.config.RPCAllowIP = config.RPCAllowIP
+
+	if allowipChanged || !reflect.DeepEqual(config.HTTPCors, n.config.HTTPCors) {
+		n.config.HTTPCors = config.HTTPCors
+		if endpoint := n.httpEndpoint; n.httpHandler != nil {
+			n.stopHTTP()
+			if err := n.startHTTP(endpoint, n.rpcAPIs, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.RPCAllowIP, n.config.RPCBehindProxy); err != nil {
+				return err
+			}
+		}
+	}
+	if allowipChanged || !reflect.DeepEqual(config.WSOrigins, n.config.WSOrigins) {
+		n.config.WSOrigins = config.WSOrigins
+		if endpoint := n.wsEndpoint; n.wsHandler != nil {
+			n.stopWS()
+			if err := n.startWS(endpoint, n.rpcAPIs, n.config.WSModules, n.config.WSOrigins, n.config.WSExposeAll, n.config.RPCAllowIP, n.config.RPCBehindProxy); err != nil {
+				return err
+			}
+		}
+	}
+	if allowipChanged {
+		// Extra endpoints without their own list inherit the node wide one
+		n.stopExtraEndpoints()
+		if err := n.startExtraEndpoints(n.rpcAPIs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
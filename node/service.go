@@ -38,16 +38,22 @@ type ServiceContext struct {
 
 // OpenDatabase opens an existing database with the given name (or creates one
 // if no previous can be found) from within the node's data directory. If the
-// node is an ephemeral one, a memory database is returned.
+// node is an ephemeral one, a memory database is returned. If the node is a
+// replica, the chain database is read from the remote node instead.
 func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (aquadb.Database, error) {
+	if name == datadirChainDatabase && ctx.config.DatabaseRemote != "" {
+		return ctx.config.openRemoteDatabase()
+	}
 	if ctx.config.DataDir == "" {
 		return aquadb.NewMemDatabase(), nil
 	}
-	db, err := aquadb.NewLDBDatabase(ctx.config.resolvePath(name), cache, handles)
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
+	return ctx.config.openDatabase(name, cache, handles)
+}
+
+// DatabaseSecret returns the secret services should use to encrypt their data
+// at rest, or nil if encryption is disabled.
+func (ctx *ServiceContext) DatabaseSecret() ([]byte, error) {
+	return ctx.config.DatabaseSecret()
 }
 
 // ResolvePath resolves a user path into the data directory if that was relative
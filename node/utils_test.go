@@ -131,3 +131,18 @@ func (api *OneMethodApi) TheOneMethod() {
 		api.fun()
 	}
 }
+
+// DependentServiceBase is an InstrumentedService that additionally declares its
+// dependencies on other services.
+type DependentServiceBase struct {
+	InstrumentedService
+	deps []reflect.Type
+}
+
+func (s *DependentServiceBase) Dependencies() []reflect.Type { return s.deps }
+
+// Set of services all wrapping the base DependentServiceBase resulting in the
+// same method signatures but different outer types.
+type DependentServiceA struct{ DependentServiceBase }
+type DependentServiceB struct{ DependentServiceBase }
+type DependentServiceC struct{ DependentServiceBase }
@@ -0,0 +1,74 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"expvar"
+	"runtime"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/p2p"
+)
+
+// nodeVars are the always-on counters of the running node, published as the
+// "node" expvar. Only one node per process is published, the last started.
+var nodeVars struct {
+	once    sync.Once
+	lock    sync.RWMutex
+	server  *p2p.Server
+	version string
+	started time.Time
+}
+
+// publishVars makes the given node the one reported in the "node" expvar.
+func publishVars(server *p2p.Server, version string) {
+	nodeVars.lock.Lock()
+	nodeVars.server, nodeVars.version, nodeVars.started = server, version, time.Now()
+	nodeVars.lock.Unlock()
+
+	nodeVars.once.Do(func() {
+		expvar.Publish("node", expvar.Func(readNodeVars))
+	})
+}
+
+// unpublishVars stops reporting the node with the given p2p server.
+func unpublishVars(server *p2p.Server) {
+	nodeVars.lock.Lock()
+	defer nodeVars.lock.Unlock()
+
+	if nodeVars.server == server {
+		nodeVars.server = nil
+	}
+}
+
+func readNodeVars() interface{} {
+	nodeVars.lock.RLock()
+	defer nodeVars.lock.RUnlock()
+
+	if nodeVars.server == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"name":     nodeVars.server.Name,
+		"version":  nodeVars.version,
+		"go":       runtime.Version(),
+		"platform": runtime.GOOS + "/" + runtime.GOARCH,
+		"uptime":   int64(time.Since(nodeVars.started) / time.Second),
+		"peers":    nodeVars.server.PeerCount(),
+	}
+}
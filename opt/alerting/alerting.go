@@ -0,0 +1,390 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package alerting implements a node service posting alerts to webhooks when
+// the chain or the network misbehaves, so unattended nodes can page their
+// operators.
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+const (
+	checkInterval    = 30 * time.Second // Interval between two checks of the alert conditions
+	peersGracePeriod = 5 * time.Minute  // Time the peer count must stay low before alerting
+	mismatchWindow   = 10 * time.Minute // Window the rejected peers are counted in
+	sendTimeout      = 10 * time.Second // Timeout of a single webhook request
+	sendQueue        = 64               // Number of alerts queued for sending before dropping
+)
+
+// Alert types.
+const (
+	AlertReorg    = "reorg"
+	AlertStall    = "stall"
+	AlertPeers    = "peers"
+	AlertMismatch = "mismatch"
+)
+
+// Config contains the settings of the alerting service.
+type Config struct {
+	Webhooks   []string      // URLs to POST the alerts to (empty = disabled)
+	ReorgDepth uint64        // Minimum depth of a reorg to alert on (0 = never)
+	Stall      time.Duration // Time without a new block to alert after (0 = never)
+	MinPeers   int           // Peer count below which to alert (0 = never)
+	Mismatches int           // Peers rejected for being on another chain within 10 minutes to alert on (0 = never)
+}
+
+// DefaultConfig contains the default alerting settings.
+var DefaultConfig = Config{
+	ReorgDepth: 6,
+	Stall:      30 * time.Minute,
+	MinPeers:   1,
+	Mismatches: 50,
+}
+
+// Alert is the JSON document posted to the webhooks. The text field makes it
+// directly usable with Slack and Mattermost compatible incoming webhooks.
+type Alert struct {
+	Type     string                 `json:"type"`
+	Resolved bool                   `json:"resolved"`
+	Text     string                 `json:"text"`
+	Node     string                 `json:"node"`
+	Host     string                 `json:"host"`
+	Time     time.Time              `json:"time"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// chain is the part of the blockchain the service watches.
+type chain interface {
+	CurrentBlock() *types.Block
+	GetHeader(hash common.Hash, number uint64) *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// condition tracks an alert condition which fires once when it has held for a
+// while, and resolves once when it clears.
+type condition struct {
+	since  time.Time // Time the condition started holding, zero if it doesn't
+	firing bool
+}
+
+// update records whether the condition holds, returning whether the alert
+// should be fired or resolved.
+func (c *condition) update(holds bool, now time.Time, after time.Duration) (fire, resolve bool) {
+	if !holds {
+		c.since = time.Time{}
+		if c.firing {
+			c.firing = false
+			return false, true
+		}
+		return false, false
+	}
+	if c.since.IsZero() {
+		c.since = now
+	}
+	if !c.firing && now.Sub(c.since) >= after {
+		c.firing = true
+		return true, false
+	}
+	return false, false
+}
+
+// Service watches the AquaChain service running in the same node and posts
+// alerts to the configured webhooks.
+type Service struct {
+	config Config
+	aqua   *aqua.AquaChain
+	server *p2p.Server
+	host   string
+	client *http.Client
+
+	head     *types.Header // Last head block seen
+	headTime time.Time     // Time the last head block was seen
+
+	stall, peers, mismatch condition
+	mismatches             uint64    // Rejected peer count at the start of the mismatch window
+	mismatchTime           time.Time // Start of the mismatch window
+
+	alerts chan *Alert
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates an alerting service for the AquaChain service running in the same
+// node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	if len(config.Webhooks) == 0 {
+		return nil, errors.New("alerting requires a webhook")
+	}
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("alerting requires a full node: %v", err)
+	}
+	s := newService(config)
+	s.aqua = aquachain
+	return s, nil
+}
+
+func newService(config Config) *Service {
+	host, _ := os.Hostname()
+	return &Service{
+		config: config,
+		host:   host,
+		client: &http.Client{Timeout: sendTimeout},
+		alerts: make(chan *Alert, sendQueue),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Dependencies implements node.DependentService, making sure the service is
+// stopped before the AquaChain service it watches.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to watch the node.
+func (s *Service) Start(server *p2p.Server) error {
+	s.server = server
+
+	s.wg.Add(2)
+	go s.loop()
+	go s.sendLoop()
+
+	log.Info("Alerting started", "webhooks", len(s.config.Webhooks))
+	return nil
+}
+
+// Stop implements node.Service, terminating the watch. Alerts still queued are
+// discarded.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Alerting stopped")
+	return nil
+}
+
+// loop checks the alert conditions on every new head and periodically.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := s.aqua.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	s.head, s.headTime = s.aqua.BlockChain().CurrentBlock().Header(), now
+	s.mismatches, s.mismatchTime = s.aqua.ChainMismatches(), now
+
+	for {
+		select {
+		case ev := <-heads:
+			s.checkHead(s.aqua.BlockChain(), ev.Block.Header(), time.Now())
+		case now := <-ticker.C:
+			s.checkStall(now)
+			if s.server != nil {
+				s.checkPeers(s.server.PeerCount(), now)
+			}
+			s.checkMismatches(s.aqua.ChainMismatches(), now)
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// checkHead alerts on deep reorgs, and resolves a stall alert.
+func (s *Service) checkHead(chain chain, head *types.Header, now time.Time) {
+	prev := s.head
+	s.head, s.headTime = head, now
+
+	if _, resolve := s.stall.update(false, now, 0); resolve {
+		s.alert(&Alert{Type: AlertStall, Resolved: true, Text: fmt.Sprintf("New block #%d imported, chain progressing again", head.Number)})
+	}
+	if prev == nil || s.config.ReorgDepth == 0 {
+		return
+	}
+	depth, ancestor := reorgDepth(chain, prev)
+	if depth >= s.config.ReorgDepth {
+		s.alert(&Alert{
+			Type: AlertReorg,
+			Text: fmt.Sprintf("Chain reorganised %d blocks deep, from #%d back to #%d", depth, prev.Number, ancestor),
+			Details: map[string]interface{}{
+				"depth":    depth,
+				"oldHead":  prev.Hash(),
+				"newHead":  head.Hash(),
+				"ancestor": ancestor,
+			},
+		})
+	}
+}
+
+// checkStall alerts if no new block has been seen for too long.
+func (s *Service) checkStall(now time.Time) {
+	if s.config.Stall == 0 {
+		return
+	}
+	if fire, _ := s.stall.update(now.Sub(s.headTime) >= s.config.Stall, now, 0); fire {
+		s.alert(&Alert{
+			Type:    AlertStall,
+			Text:    fmt.Sprintf("No new block for %v, head stuck at #%d", now.Sub(s.headTime).Round(time.Second), s.head.Number),
+			Details: map[string]interface{}{"head": s.head.Hash(), "number": s.head.Number},
+		})
+	}
+}
+
+// checkPeers alerts if the peer count stays below the threshold.
+func (s *Service) checkPeers(peers int, now time.Time) {
+	if s.config.MinPeers == 0 {
+		return
+	}
+	fire, resolve := s.peers.update(peers < s.config.MinPeers, now, peersGracePeriod)
+	switch {
+	case fire:
+		s.alert(&Alert{
+			Type:    AlertPeers,
+			Text:    fmt.Sprintf("Only %d peers connected, below the minimum of %d", peers, s.config.MinPeers),
+			Details: map[string]interface{}{"peers": peers},
+		})
+	case resolve:
+		s.alert(&Alert{Type: AlertPeers, Resolved: true, Text: fmt.Sprintf("%d peers connected again", peers), Details: map[string]interface{}{"peers": peers}})
+	}
+}
+
+// checkMismatches alerts if many peers were rejected for being on another
+// network or chain in the last window, hinting at a network split or at the
+// node itself being on a fork.
+func (s *Service) checkMismatches(total uint64, now time.Time) {
+	if s.config.Mismatches == 0 || now.Sub(s.mismatchTime) < mismatchWindow {
+		return
+	}
+	count := total - s.mismatches
+	s.mismatches, s.mismatchTime = total, now
+
+	fire, resolve := s.mismatch.update(count >= uint64(s.config.Mismatches), now, 0)
+	switch {
+	case fire:
+		s.alert(&Alert{
+			Type:    AlertMismatch,
+			Text:    fmt.Sprintf("%d peers rejected for being on another network or chain in the last %v", count, mismatchWindow),
+			Details: map[string]interface{}{"count": count},
+		})
+	case resolve:
+		s.alert(&Alert{Type: AlertMismatch, Resolved: true, Text: "Peer chain mismatches back to normal", Details: map[string]interface{}{"count": count}})
+	}
+}
+
+// reorgDepth returns the number of blocks of the given former head which are no
+// longer canonical, and the number of the common ancestor with the new chain.
+func reorgDepth(chain chain, prev *types.Header) (uint64, uint64) {
+	header := prev
+	for header != nil {
+		canon := chain.GetHeaderByNumber(header.Number.Uint64())
+		if canon != nil && canon.Hash() == header.Hash() {
+			break
+		}
+		if header.Number.Sign() == 0 {
+			break
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	if header == nil {
+		return 0, 0
+	}
+	return prev.Number.Uint64() - header.Number.Uint64(), header.Number.Uint64()
+}
+
+// alert queues an alert for sending.
+func (s *Service) alert(alert *Alert) {
+	alert.Time = time.Now()
+	alert.Host = s.host
+	if s.server != nil {
+		alert.Node = s.server.Name
+	}
+	if alert.Resolved {
+		log.Info("Alert resolved", "type", alert.Type, "msg", alert.Text)
+	} else {
+		log.Warn("Alert raised", "type", alert.Type, "msg", alert.Text)
+	}
+	select {
+	case s.alerts <- alert:
+	default:
+		log.Warn("Alert queue full, dropping alert", "type", alert.Type)
+	}
+}
+
+// sendLoop posts the queued alerts to all webhooks.
+func (s *Service) sendLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case alert := <-s.alerts:
+			for _, url := range s.config.Webhooks {
+				if err := s.send(url, alert); err != nil {
+					log.Warn("Failed to post alert", "url", url, "err", err)
+				}
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// send posts an alert to a webhook.
+func (s *Service) send(url string, alert *Alert) error {
+	blob, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
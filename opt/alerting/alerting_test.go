@@ -0,0 +1,142 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/params"
+)
+
+// Tests that the depth of a reorg is measured from the former head back to the
+// common ancestor of the old and new chains.
+func TestReorgDepth(t *testing.T) {
+	var (
+		db      = aquadb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+		engine  = aquahash.NewFaker()
+	)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	shared, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 4, nil)
+	short, _ := core.GenerateChain(gspec.Config, shared[len(shared)-1], engine, db, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	long, _ := core.GenerateChain(gspec.Config, shared[len(shared)-1], engine, db, 5, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	if _, err := chain.InsertChain(append(shared, short...)); err != nil {
+		t.Fatalf("failed to insert short chain: %v", err)
+	}
+	prev := chain.CurrentBlock().Header()
+	if depth, _ := reorgDepth(chain, prev); depth != 0 {
+		t.Errorf("depth mismatch for canonical head: have %d, want 0", depth)
+	}
+	if _, err := chain.InsertChain(long); err != nil {
+		t.Fatalf("failed to insert long chain: %v", err)
+	}
+	depth, ancestor := reorgDepth(chain, prev)
+	if depth != 3 || ancestor != 4 {
+		t.Errorf("reorg mismatch: have depth %d ancestor %d, want depth 3 ancestor 4", depth, ancestor)
+	}
+}
+
+// Tests that conditions fire once after holding long enough, and resolve once.
+func TestCondition(t *testing.T) {
+	var (
+		c   condition
+		now = time.Now()
+	)
+	tests := []struct {
+		holds         bool
+		elapsed       time.Duration
+		fire, resolve bool
+	}{
+		{false, 0, false, false},
+		{true, 0, false, false},
+		{true, time.Minute, false, false},
+		{true, 5 * time.Minute, true, false},
+		{true, 6 * time.Minute, false, false},
+		{false, 7 * time.Minute, false, true},
+		{false, 8 * time.Minute, false, false},
+	}
+	for i, tt := range tests {
+		fire, resolve := c.update(tt.holds, now.Add(tt.elapsed), 5*time.Minute)
+		if fire != tt.fire || resolve != tt.resolve {
+			t.Errorf("test %d: have fire %v resolve %v, want fire %v resolve %v", i, fire, resolve, tt.fire, tt.resolve)
+		}
+	}
+}
+
+// Tests that alerts are posted to all webhooks.
+func TestWebhooks(t *testing.T) {
+	alerts := make(chan *Alert, 2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type mismatch: have %s, want application/json", ct)
+		}
+		alert := new(Alert)
+		if err := json.NewDecoder(r.Body).Decode(alert); err != nil {
+			t.Errorf("failed to decode alert: %v", err)
+		}
+		alerts <- alert
+	})
+	srv1, srv2 := httptest.NewServer(handler), httptest.NewServer(handler)
+	defer srv1.Close()
+	defer srv2.Close()
+
+	config := DefaultConfig
+	config.Webhooks = []string{srv1.URL, srv2.URL}
+	s := newService(config)
+	s.wg.Add(1)
+	go s.sendLoop()
+	defer s.Stop()
+
+	now := time.Now()
+	for i := 0; i <= int(peersGracePeriod/checkInterval); i++ {
+		s.checkPeers(0, now.Add(time.Duration(i)*checkInterval))
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case alert := <-alerts:
+			if alert.Type != AlertPeers || alert.Resolved || alert.Text == "" {
+				t.Errorf("alert mismatch: have %+v", alert)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("alert %d not posted", i)
+		}
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("unexpected repeated alert: %+v", alert)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -512,6 +512,12 @@ func (c *Client) GetWork(ctx context.Context) ([3]string, error) {
 	return work, err
 }
 
+// SubscribeNewWork subscribes to notifications about new mining work packages
+// (hash, auxhash, difficulty), starting with the current one.
+func (c *Client) SubscribeNewWork(ctx context.Context, ch chan<- [3]string) (aquachain.Subscription, error) {
+	return c.c.AquaSubscribe(ctx, ch, "newWork")
+}
+
 // SubmitWork submits a completed work package (nonce, solution, hash)
 func (c *Client) SubmitWork(ctx context.Context, nonce types.BlockNonce, solution, digest common.Hash) bool {
 	var ok bool
@@ -0,0 +1,149 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dashboard
+
+// indexHTML is the dashboard page. It's self contained, drawing its charts on
+// plain canvases, so it works on hosts without internet access.
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>AquaChain Dashboard</title>
+<style>
+body { margin: 0; padding: 16px; background: #101820; color: #d0d8e0; font-family: sans-serif; }
+h1 { margin: 0 0 16px; font-size: 20px; font-weight: normal; }
+#status { font-size: 14px; color: #8090a0; margin-left: 8px; }
+#charts { display: grid; grid-template-columns: repeat(auto-fill, minmax(420px, 1fr)); gap: 16px; }
+.chart { background: #18242e; border-radius: 4px; padding: 8px 12px; }
+.chart h2 { margin: 0 0 4px; font-size: 14px; font-weight: normal; }
+.chart .value { float: right; color: #ffffff; }
+.chart canvas { width: 100%; height: 160px; }
+.legend { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>AquaChain Dashboard<span id="status">connecting...</span></h1>
+<div id="charts"></div>
+<script>
+"use strict";
+
+var colors = ["#4fc3f7", "#ffb74d", "#81c784"];
+
+function bytes(v) {
+	var units = ["B", "KiB", "MiB", "GiB", "TiB"], i = 0;
+	while (v >= 1024 && i < units.length - 1) { v /= 1024; i++; }
+	return v.toFixed(i ? 1 : 0) + " " + units[i];
+}
+function hashes(v) {
+	var units = ["H/s", "KH/s", "MH/s", "GH/s", "TH/s"], i = 0;
+	while (v >= 1000 && i < units.length - 1) { v /= 1000; i++; }
+	return v.toFixed(i ? 1 : 0) + " " + units[i];
+}
+function plain(v) { return Math.round(v).toString(); }
+
+var charts = [
+	{title: "Peers", series: [["peers", "peers"]], format: plain},
+	{title: "Sync progress", series: [["currentBlock", "current"], ["highestBlock", "highest"]], format: plain},
+	{title: "Transaction pool", series: [["pending", "pending"], ["queued", "queued"]], format: plain},
+	{title: "Hashrate", series: [["hashrate", "hashrate"]], format: hashes},
+	{title: "Memory", series: [["memory", "heap"]], format: bytes},
+	{title: "Goroutines", series: [["goroutines", "goroutines"]], format: plain},
+	{title: "Disk I/O", series: [["diskRead", "read/s"], ["diskWrite", "write/s"]], format: bytes}
+];
+var samples = [], limit = 360;
+
+charts.forEach(function(chart) {
+	var div = document.createElement("div");
+	div.className = "chart";
+	div.innerHTML = "<h2>" + chart.title + "<span class=\"value\"></span></h2><canvas></canvas><div class=\"legend\"></div>";
+	chart.value = div.querySelector(".value");
+	chart.canvas = div.querySelector("canvas");
+	div.querySelector(".legend").innerHTML = chart.series.map(function(s, i) {
+		return "<span style=\"color:" + colors[i] + "\">&#9632; " + s[1] + "</span>";
+	}).join(" &nbsp; ");
+	document.getElementById("charts").appendChild(div);
+});
+
+function draw(chart) {
+	var canvas = chart.canvas, ratio = window.devicePixelRatio || 1;
+	canvas.width = canvas.clientWidth * ratio;
+	canvas.height = canvas.clientHeight * ratio;
+	var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height;
+	ctx.clearRect(0, 0, w, h);
+	if (!samples.length) {
+		return;
+	}
+	var min = Infinity, max = -Infinity;
+	chart.series.forEach(function(s) {
+		samples.forEach(function(sample) {
+			min = Math.min(min, sample[s[0]]);
+			max = Math.max(max, sample[s[0]]);
+		});
+	});
+	if (max === min) { max = min + 1; }
+	var pad = 14 * ratio;
+	ctx.font = (10 * ratio) + "px sans-serif";
+	ctx.fillStyle = "#8090a0";
+	ctx.fillText(chart.format(max), 0, pad - 4 * ratio);
+	ctx.fillText(chart.format(min), 0, h - 2 * ratio);
+
+	chart.series.forEach(function(s, i) {
+		ctx.strokeStyle = colors[i];
+		ctx.lineWidth = 1.5 * ratio;
+		ctx.beginPath();
+		samples.forEach(function(sample, j) {
+			var x = samples.length > 1 ? j * w / (samples.length - 1) : w;
+			var y = pad + (h - 2 * pad) * (1 - (sample[s[0]] - min) / (max - min));
+			if (j === 0) { ctx.moveTo(x, y); } else { ctx.lineTo(x, y); }
+		});
+		ctx.stroke();
+	});
+	var last = samples[samples.length - 1];
+	chart.value.textContent = chart.series.map(function(s) { return chart.format(last[s[0]]); }).join(" / ");
+}
+
+function redraw() { charts.forEach(draw); }
+window.addEventListener("resize", redraw);
+
+function connect() {
+	var proto = location.protocol === "https:" ? "wss://" : "ws://";
+	var ws = new WebSocket(proto + location.host + "/api");
+	var status = document.getElementById("status");
+	ws.onopen = function() { status.textContent = "live"; };
+	ws.onmessage = function(event) {
+		var msg = JSON.parse(event.data);
+		if (msg.history) {
+			samples = msg.history;
+		}
+		if (msg.sample) {
+			samples.push(msg.sample);
+			if (samples.length > limit) {
+				samples.splice(0, samples.length - limit);
+			}
+		}
+		redraw();
+	};
+	ws.onclose = function() {
+		status.textContent = "disconnected, reconnecting...";
+		setTimeout(connect, 3000);
+	};
+}
+connect();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,316 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dashboard implements a node service serving a local web page with live
+// charts of the node's peers, sync progress, transaction pool, hashrate and
+// system usage, for operators not running a metrics stack of their own.
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	historyLimit  = 360 // Number of samples kept for newly connecting clients
+	clientBacklog = 16  // Number of samples queued for a client before dropping it
+)
+
+// Config contains the settings of the dashboard.
+type Config struct {
+	Addr    string        // Listening address of the dashboard (empty = disabled)
+	Refresh time.Duration // Time between two samples
+}
+
+// DefaultConfig contains the default dashboard settings.
+var DefaultConfig = Config{
+	Refresh: 5 * time.Second,
+}
+
+// Sample is a snapshot of the node's state pushed to the dashboard clients.
+type Sample struct {
+	Time         int64   `json:"time"` // Unix time in milliseconds
+	Peers        int     `json:"peers"`
+	CurrentBlock uint64  `json:"currentBlock"`
+	HighestBlock uint64  `json:"highestBlock"`
+	Pending      int     `json:"pending"`
+	Queued       int     `json:"queued"`
+	Hashrate     int64   `json:"hashrate"`
+	Memory       uint64  `json:"memory"` // Bytes allocated on the heap
+	Goroutines   int     `json:"goroutines"`
+	DiskRead     float64 `json:"diskRead"`  // Bytes read per second
+	DiskWrite    float64 `json:"diskWrite"` // Bytes written per second
+}
+
+// message is sent to the clients, carrying either the sample history upon
+// connecting or a single new sample.
+type message struct {
+	History []*Sample `json:"history,omitempty"`
+	Sample  *Sample   `json:"sample,omitempty"`
+}
+
+// Service samples the state of the AquaChain service running in the same node
+// and streams it to the connected dashboard pages over a websocket.
+type Service struct {
+	config Config
+	aqua   *aqua.AquaChain
+	server *p2p.Server
+
+	history []*Sample
+	clients map[chan *Sample]struct{}
+	lock    sync.Mutex
+
+	listener net.Listener
+	disk     *metrics.DiskStats // Last disk stats read, nil if unavailable
+	diskTime time.Time
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a dashboard for the AquaChain service running in the same node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	if config.Refresh <= 0 {
+		return nil, errors.New("dashboard refresh interval must be positive")
+	}
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("the dashboard requires a full node: %v", err)
+	}
+	return newService(config, aquachain), nil
+}
+
+func newService(config Config, aquachain *aqua.AquaChain) *Service {
+	return &Service{
+		config:  config,
+		aqua:    aquachain,
+		clients: make(map[chan *Sample]struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Dependencies implements node.DependentService, making sure the dashboard is
+// stopped before the AquaChain service it samples.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to sample the node and serve the
+// dashboard.
+func (s *Service) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.server = server
+	s.listener = listener
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		http.Serve(listener, s.handler())
+	}()
+	go s.loop()
+
+	log.Info("Dashboard started", "url", fmt.Sprintf("http://%s", listener.Addr()))
+	return nil
+}
+
+// Stop implements node.Service, disconnecting all clients.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.listener.Close()
+	s.wg.Wait()
+
+	log.Info("Dashboard stopped")
+	return nil
+}
+
+// handler returns the HTTP handler serving the dashboard page and its feed.
+func (s *Service) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(indexHTML))
+	})
+	mux.Handle("/api", websocket.Server{
+		Handshake: checkOrigin,
+		Handler:   s.serveClient,
+	})
+	return mux
+}
+
+// checkOrigin rejects websocket connections from pages served by other hosts,
+// so that arbitrary web sites can't read the node's state.
+func checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Host != r.Host {
+		return fmt.Errorf("origin %s not allowed", origin)
+	}
+	return nil
+}
+
+// serveClient sends the sample history and then every new sample to a client
+// until it disconnects or falls behind.
+func (s *Service) serveClient(conn *websocket.Conn) {
+	defer conn.Close()
+
+	samples := make(chan *Sample, clientBacklog)
+	s.lock.Lock()
+	history := append([]*Sample{}, s.history...)
+	s.clients[samples] = struct{}{}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.clients, samples)
+		s.lock.Unlock()
+	}()
+	if err := websocket.JSON.Send(conn, &message{History: history}); err != nil {
+		return
+	}
+	// Detect the client going away, it isn't expected to send anything
+	closed := make(chan struct{})
+	go func() {
+		var discard []byte
+		for websocket.Message.Receive(conn, &discard) == nil {
+		}
+		close(closed)
+	}()
+	for {
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(conn, &message{Sample: sample}); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// loop takes a sample of the node's state every refresh interval.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Refresh)
+	defer ticker.Stop()
+
+	for {
+		s.push(s.collect())
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// push adds a sample to the history and sends it to all clients, dropping
+// those not keeping up.
+func (s *Service) push(sample *Sample) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.history = append(s.history, sample)
+	if len(s.history) > historyLimit {
+		s.history = s.history[len(s.history)-historyLimit:]
+	}
+	for client := range s.clients {
+		select {
+		case client <- sample:
+		default:
+			close(client)
+			delete(s.clients, client)
+		}
+	}
+}
+
+// collect takes a sample of the node's state.
+func (s *Service) collect() *Sample {
+	now := time.Now()
+	sample := &Sample{
+		Time:       now.UnixNano() / int64(time.Millisecond),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if s.server != nil {
+		sample.Peers = s.server.PeerCount()
+	}
+	if s.aqua != nil {
+		sample.CurrentBlock = s.aqua.BlockChain().CurrentBlock().NumberU64()
+		if downloader := s.aqua.Downloader(); downloader != nil {
+			sample.HighestBlock = downloader.Progress().HighestBlock
+		}
+		if sample.HighestBlock < sample.CurrentBlock {
+			sample.HighestBlock = sample.CurrentBlock
+		}
+		sample.Pending, sample.Queued = s.aqua.TxPool().Stats()
+		if s.aqua.IsMining() {
+			sample.Hashrate = s.aqua.Miner().HashRate()
+		}
+	}
+	var memstats runtime.MemStats
+	runtime.ReadMemStats(&memstats)
+	sample.Memory = memstats.Alloc
+
+	disk := new(metrics.DiskStats)
+	if metrics.ReadDiskStats(disk) == nil {
+		if s.disk != nil {
+			elapsed := now.Sub(s.diskTime).Seconds()
+			sample.DiskRead = float64(disk.ReadBytes-s.disk.ReadBytes) / elapsed
+			sample.DiskWrite = float64(disk.WriteBytes-s.disk.WriteBytes) / elapsed
+		}
+		s.disk, s.diskTime = disk, now
+	}
+	return sample
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dashboard
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestFeed(t *testing.T) {
+	s := newService(DefaultConfig, nil)
+	for i := 0; i < historyLimit+10; i++ {
+		s.push(&Sample{Time: int64(i)})
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api"
+	conn, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Newly connected clients get the retained history
+	var msg message
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		t.Fatalf("failed to receive history: %v", err)
+	}
+	if len(msg.History) != historyLimit {
+		t.Fatalf("history length mismatch: have %d, want %d", len(msg.History), historyLimit)
+	}
+	if msg.History[0].Time != 10 {
+		t.Errorf("oldest sample mismatch: have %d, want 10", msg.History[0].Time)
+	}
+	// And then every new sample
+	s.push(&Sample{Time: 1000, Peers: 3})
+
+	msg = message{}
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		t.Fatalf("failed to receive sample: %v", err)
+	}
+	if msg.Sample == nil || msg.Sample.Time != 1000 || msg.Sample.Peers != 3 {
+		t.Errorf("sample mismatch: have %+v", msg.Sample)
+	}
+}
+
+func TestForeignOrigin(t *testing.T) {
+	s := newService(DefaultConfig, nil)
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api"
+	if conn, err := websocket.Dial(url, "", "http://example.com"); err == nil {
+		conn.Close()
+		t.Fatal("connection from foreign origin accepted")
+	}
+}
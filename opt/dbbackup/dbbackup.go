@@ -0,0 +1,311 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dbbackup implements a node service periodically backing up the chain
+// database.
+package dbbackup
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// backupSuffix is the file extension of the backup archives.
+const backupSuffix = ".bak.gz"
+
+// Config contains the settings of the backup service.
+type Config struct {
+	Dir      string        // Directory to store the backups in (empty = disabled)
+	Interval time.Duration // Time between two automatic backups (zero = manual only)
+	Keep     int           // Number of backups to retain (zero = keep all)
+	URL      string        `toml:",omitempty"` // Optional remote URL to also PUT each backup to
+}
+
+// DefaultConfig contains the default backup settings.
+var DefaultConfig = Config{
+	Interval: 24 * time.Hour,
+	Keep:     7,
+}
+
+// BackupInfo describes a single backup archive.
+type BackupInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// Service is a node service creating consistent snapshots of the chain
+// database on a schedule, or on demand via the admin API.
+type Service struct {
+	config  Config
+	chainDb aquadb.Database     // Chain database as used by the node, possibly buffered
+	db      *aquadb.LDBDatabase // Innermost chain database the backups are taken of
+
+	lock sync.Mutex // Serializes backups, restores and pruning
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a backup service for the chain database of the AquaChain service
+// running in the same node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	if config.Dir == "" {
+		return nil, errors.New("no backup directory configured")
+	}
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("backups require a full node: %v", err)
+	}
+	db, ok := aquadb.Unwrap(aquachain.ChainDb()).(*aquadb.LDBDatabase)
+	if !ok {
+		return nil, errors.New("backups require a persistent chain database")
+	}
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Service{
+		config:  config,
+		chainDb: aquachain.ChainDb(),
+		db:      db,
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// Dependencies implements node.DependentService, making sure the chain is
+// running before backups are scheduled.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the backup management methods.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "admin",
+		Version:   "1.0",
+		Service:   &PrivateBackupAPI{s},
+	}}
+}
+
+// Start implements node.Service, starting the backup scheduler.
+func (s *Service) Start(*p2p.Server) error {
+	if s.config.Interval > 0 {
+		s.wg.Add(1)
+		go s.loop()
+	}
+	log.Info("Database backups enabled", "dir", s.config.Dir, "interval", s.config.Interval, "keep", s.config.Keep)
+	return nil
+}
+
+// Stop implements node.Service, terminating the backup scheduler.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+	return nil
+}
+
+// loop creates a new backup every configured interval.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.Backup(); err != nil {
+				log.Error("Scheduled database backup failed", "err", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Backup creates a new backup archive, uploads it if a remote is configured
+// and prunes old archives beyond the retention limit.
+func (s *Service) Backup() (*BackupInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := aquadb.Flush(s.chainDb); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	name := fmt.Sprintf("%s-%s%s", filepath.Base(s.db.Path()), start.UTC().Format("20060102-150405"), backupSuffix)
+	path := filepath.Join(s.config.Dir, name)
+
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.db.Backup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Created database backup", "file", path, "entries", count, "size", stat.Size(), "elapsed", time.Since(start))
+
+	if s.config.URL != "" {
+		if err := s.upload(path); err != nil {
+			log.Error("Failed to upload database backup", "url", s.config.URL, "err", err)
+		}
+	}
+	if err := s.prune(); err != nil {
+		log.Warn("Failed to prune old database backups", "err", err)
+	}
+	return &BackupInfo{Name: name, Size: stat.Size(), Created: stat.ModTime()}, nil
+}
+
+// upload sends a backup archive to the configured remote via HTTP PUT.
+func (s *Service) upload(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(s.config.URL, "/")+"/"+filepath.Base(path), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = stat.Size()
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("upload rejected: %s", resp.Status)
+	}
+	return nil
+}
+
+// List returns the available backup archives, oldest first.
+func (s *Service) List() ([]BackupInfo, error) {
+	files, err := ioutil.ReadDir(s.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(s.db.Path()) + "-"
+
+	backups := []BackupInfo{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), prefix) || !strings.HasSuffix(file.Name(), backupSuffix) {
+			continue
+		}
+		backups = append(backups, BackupInfo{Name: file.Name(), Size: file.Size(), Created: file.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name < backups[j].Name })
+	return backups, nil
+}
+
+// prune deletes the oldest backups exceeding the retention limit.
+func (s *Service) prune() error {
+	if s.config.Keep <= 0 {
+		return nil
+	}
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.config.Keep {
+		if err := os.Remove(filepath.Join(s.config.Dir, backups[0].Name)); err != nil {
+			return err
+		}
+		log.Debug("Pruned database backup", "file", backups[0].Name)
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Restore schedules the chain database to be restored from the named backup
+// on the next node startup, as the live database cannot be replaced while in
+// use.
+func (s *Service) Restore(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if name != filepath.Base(name) || !strings.HasSuffix(name, backupSuffix) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	if err := aquadb.ScheduleRestore(s.db.Path(), filepath.Join(s.config.Dir, name)); err != nil {
+		return err
+	}
+	log.Warn("Database restore scheduled for next startup", "backup", name)
+	return nil
+}
+
+// PrivateBackupAPI exposes the backup management methods in the admin
+// namespace.
+type PrivateBackupAPI struct {
+	s *Service
+}
+
+// BackupDatabase creates a new backup of the chain database right away.
+func (api *PrivateBackupAPI) BackupDatabase() (*BackupInfo, error) {
+	return api.s.Backup()
+}
+
+// ListBackups returns the available chain database backups, oldest first.
+func (api *PrivateBackupAPI) ListBackups() ([]BackupInfo, error) {
+	return api.s.List()
+}
+
+// RestoreBackup schedules the chain database to be restored from the named
+// backup. The restore is applied when the node is restarted.
+func (api *PrivateBackupAPI) RestoreBackup(name string) (bool, error) {
+	if err := api.s.Restore(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
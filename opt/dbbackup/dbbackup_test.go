@@ -0,0 +1,171 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dbbackup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+)
+
+// newTestService creates a backup service for a fresh chain database in a
+// temporary directory, returning it along with the directory to clean up.
+func newTestService(t *testing.T, config Config) (*Service, string) {
+	dir, err := ioutil.TempDir("", "dbbackup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := aquadb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	config.Dir = filepath.Join(dir, "backups")
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return &Service{config: config, chainDb: db, db: db, quit: make(chan struct{})}, dir
+}
+
+// Tests that backups are created on schedule until the service stops.
+func TestBackupSchedule(t *testing.T) {
+	s, dir := newTestService(t, Config{Interval: 50 * time.Millisecond})
+	defer os.RemoveAll(dir)
+	defer s.db.Close()
+
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	var backups []BackupInfo
+	for start := time.Now(); len(backups) == 0 && time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if backups, err = s.List(); err != nil {
+			t.Fatalf("failed to list backups: %v", err)
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+	if len(backups) == 0 {
+		t.Fatalf("no scheduled backup created")
+	}
+	// Without an interval, backups are only taken on demand
+	manual, dir2 := newTestService(t, Config{})
+	defer os.RemoveAll(dir2)
+	defer manual.db.Close()
+
+	manual.Start(nil)
+	time.Sleep(100 * time.Millisecond)
+	manual.Stop()
+
+	if backups, _ := manual.List(); len(backups) != 0 {
+		t.Fatalf("backups created without a schedule: %v", backups)
+	}
+}
+
+// Tests that only the configured number of most recent backups is retained,
+// leaving unrelated files alone.
+func TestBackupRetention(t *testing.T) {
+	s, dir := newTestService(t, Config{Keep: 2})
+	defer os.RemoveAll(dir)
+	defer s.db.Close()
+
+	old := []string{
+		"chaindata-20180101-000000" + backupSuffix,
+		"chaindata-20180102-000000" + backupSuffix,
+		"chaindata-20180103-000000" + backupSuffix,
+	}
+	for _, name := range append(old, "other-20180101-000000"+backupSuffix, "chaindata-notes.txt") {
+		if err := ioutil.WriteFile(filepath.Join(s.config.Dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := s.Backup()
+	if err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+	backups, err := s.List()
+	if err != nil {
+		t.Fatalf("failed to list backups: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != old[2] || backups[1].Name != info.Name {
+		t.Fatalf("retained backups mismatch: have %v, want [%s %s]", backups, old[2], info.Name)
+	}
+	for _, name := range []string{"other-20180101-000000" + backupSuffix, "chaindata-notes.txt"} {
+		if _, err := os.Stat(filepath.Join(s.config.Dir, name)); err != nil {
+			t.Errorf("unrelated file %s removed: %v", name, err)
+		}
+	}
+}
+
+// Tests that a backup restored on the next open brings back the database as
+// it was at the time of the backup.
+func TestBackupRestore(t *testing.T) {
+	s, dir := newTestService(t, Config{})
+	defer os.RemoveAll(dir)
+
+	s.db.Put([]byte("kept"), []byte("value"))
+	s.db.Put([]byte("deleted"), []byte("value"))
+	info, err := s.Backup()
+	if err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+	s.db.Put([]byte("kept"), []byte("changed"))
+	s.db.Delete([]byte("deleted"))
+	s.db.Put([]byte("added"), []byte("value"))
+
+	if err := s.Restore("../" + info.Name); err == nil {
+		t.Fatalf("restore from outside the backup directory scheduled")
+	}
+	if err := s.Restore(info.Name); err != nil {
+		t.Fatalf("failed to schedule restore: %v", err)
+	}
+	// The restore is applied when the database is opened again
+	path := s.db.Path()
+	s.db.Close()
+	if err := aquadb.ApplyPendingRestore(path); err != nil {
+		t.Fatalf("failed to restore backup: %v", err)
+	}
+	db, err := aquadb.NewLDBDatabase(path, 16, 16)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if value, err := db.Get([]byte("kept")); err != nil || string(value) != "value" {
+		t.Errorf("restored value mismatch: have %q (%v), want %q", value, err, "value")
+	}
+	if has, _ := db.Has([]byte("deleted")); !has {
+		t.Errorf("deleted entry not restored")
+	}
+	if has, _ := db.Has([]byte("added")); has {
+		t.Errorf("entry added after the backup survived the restore")
+	}
+	// The restore is only applied once
+	db.Put([]byte("added"), []byte("value"))
+	if err := aquadb.ApplyPendingRestore(path); err != nil {
+		t.Fatalf("repeated restore failed: %v", err)
+	}
+	if has, _ := db.Has([]byte("added")); !has {
+		t.Errorf("restore applied twice")
+	}
+}
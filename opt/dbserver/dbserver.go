@@ -0,0 +1,103 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dbserver implements a node service exposing the chain database
+// read-only to replicas, allowing RPC serving to be scaled out separately from
+// the node syncing the network.
+package dbserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// Config contains the settings of the database server.
+type Config struct {
+	Addr    string // Listening address of the server (empty = disabled)
+	KeyFile string // File holding the secret shared with the replicas
+}
+
+// Service serves the chain database of the AquaChain service running in the
+// same node to authenticated replicas.
+type Service struct {
+	config Config
+	db     aquadb.Database
+	secret []byte
+	server *aquadb.RemoteServer
+}
+
+// New creates a database server for the chain database of the AquaChain
+// service running in the same node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	if config.KeyFile == "" {
+		return nil, errors.New("serving the database requires a key file")
+	}
+	secret, err := node.ReadSecretFile(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("serving the database requires a full node: %v", err)
+	}
+	if _, ok := aquadb.Unwrap(aquachain.ChainDb()).(*aquadb.RemoteDatabase); ok {
+		return nil, errors.New("cannot serve a remote database")
+	}
+	return &Service{
+		config: config,
+		db:     aquachain.ChainDb(),
+		secret: secret,
+	}, nil
+}
+
+// Dependencies implements node.DependentService, making sure the server is
+// stopped before the chain database is closed.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to accept replicas.
+func (s *Service) Start(*p2p.Server) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.server = aquadb.NewRemoteServer(listener, s.db, s.secret)
+	log.Info("Serving chain database to replicas", "addr", listener.Addr())
+	return nil
+}
+
+// Stop implements node.Service, disconnecting all replicas.
+func (s *Service) Stop() error {
+	s.server.Close()
+	log.Info("Chain database server stopped")
+	return nil
+}
@@ -164,6 +164,17 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetTxOrdering switches the transaction selection strategy of the miner to the
+// ordering registered under the given name.
+func (self *Miner) SetTxOrdering(name string) error {
+	ordering, err := lookupTxOrdering(name)
+	if err != nil {
+		return err
+	}
+	self.worker.setOrdering(ordering)
+	return nil
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -0,0 +1,166 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+// DefaultTxOrdering is the name of the transaction ordering used by the miner
+// unless configured otherwise.
+const DefaultTxOrdering = "price"
+
+// TxOrdering is a transaction selection strategy of the miner, yielding the
+// pending transactions in the order they should be included in a block. The
+// transactions of a single account must be yielded in nonce order.
+type TxOrdering interface {
+	// Peek returns the next transaction to include, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same
+	// account, after it was included.
+	Shift()
+
+	// Pop drops the current transaction along with all the remaining ones of
+	// the same account, after it couldn't be included.
+	Pop()
+}
+
+// TxOrderingFunc creates a transaction ordering over the pending transactions
+// of the pool for a new block. Locals are the accounts the pool considers local.
+type TxOrderingFunc func(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) TxOrdering
+
+var (
+	orderingsMu sync.RWMutex
+	orderings   = map[string]TxOrderingFunc{
+		DefaultTxOrdering: PriceOrdering,
+		"locals":          LocalsFirstOrdering,
+	}
+)
+
+// RegisterTxOrdering makes a transaction ordering available to the miner under
+// the given name, allowing custom strategies to be compiled in without changes
+// to this package. It is meant to be called from init functions.
+func RegisterTxOrdering(name string, fn TxOrderingFunc) {
+	orderingsMu.Lock()
+	defer orderingsMu.Unlock()
+
+	if _, ok := orderings[name]; ok {
+		panic(fmt.Sprintf("miner: transaction ordering %q registered twice", name))
+	}
+	orderings[name] = fn
+}
+
+// TxOrderings returns the names of the available transaction orderings.
+func TxOrderings() []string {
+	orderingsMu.RLock()
+	defer orderingsMu.RUnlock()
+
+	names := make([]string, 0, len(orderings))
+	for name := range orderings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupTxOrdering returns the transaction ordering registered under a name.
+func lookupTxOrdering(name string) (TxOrderingFunc, error) {
+	orderingsMu.RLock()
+	defer orderingsMu.RUnlock()
+
+	fn, ok := orderings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction ordering %q (available: %v)", name, TxOrderings())
+	}
+	return fn, nil
+}
+
+// PriceOrdering is the default transaction ordering, including transactions by
+// descending gas price while respecting the account nonces.
+func PriceOrdering(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) TxOrdering {
+	return types.NewTransactionsByPriceAndNonce(signer, pending)
+}
+
+// LocalsFirstOrdering includes all transactions of local accounts (by price)
+// before the ones of remote accounts (by price).
+func LocalsFirstOrdering(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address) TxOrdering {
+	return NewPriorityOrdering(signer, pending, locals)
+}
+
+// NewPriorityOrdering creates a transaction ordering including the transactions
+// of the given priority accounts by price before any other ones by price. It is
+// the building block of whitelist-first style strategies.
+func NewPriorityOrdering(signer types.Signer, pending map[common.Address]types.Transactions, priority []common.Address) TxOrdering {
+	preferred := make(map[common.Address]types.Transactions)
+	for _, addr := range priority {
+		if txs, ok := pending[addr]; ok {
+			preferred[addr] = txs
+		}
+	}
+	others := make(map[common.Address]types.Transactions, len(pending)-len(preferred))
+	for addr, txs := range pending {
+		if _, ok := preferred[addr]; !ok {
+			others[addr] = txs
+		}
+	}
+	return &chainedOrdering{orderings: []TxOrdering{
+		types.NewTransactionsByPriceAndNonce(signer, preferred),
+		types.NewTransactionsByPriceAndNonce(signer, others),
+	}}
+}
+
+// chainedOrdering yields the transactions of multiple orderings one after the
+// other.
+type chainedOrdering struct {
+	orderings []TxOrdering
+}
+
+// current returns the first ordering that still has transactions left.
+func (c *chainedOrdering) current() TxOrdering {
+	for len(c.orderings) > 0 && c.orderings[0].Peek() == nil {
+		c.orderings = c.orderings[1:]
+	}
+	if len(c.orderings) == 0 {
+		return nil
+	}
+	return c.orderings[0]
+}
+
+func (c *chainedOrdering) Peek() *types.Transaction {
+	if ordering := c.current(); ordering != nil {
+		return ordering.Peek()
+	}
+	return nil
+}
+
+func (c *chainedOrdering) Shift() {
+	if ordering := c.current(); ordering != nil {
+		ordering.Shift()
+	}
+}
+
+func (c *chainedOrdering) Pop() {
+	if ordering := c.current(); ordering != nil {
+		ordering.Pop()
+	}
+}
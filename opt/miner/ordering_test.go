@@ -0,0 +1,116 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+)
+
+// Tests that the locals first ordering includes all local transactions before
+// any remote ones, each in price and nonce order.
+func TestLocalsFirstOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	pending := make(map[common.Address]types.Transactions)
+	for i, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(int64(i+1)), nil), signer, key)
+			pending[addr] = append(pending[addr], tx)
+		}
+	}
+	// The cheapest account is local, it must still come first
+	local := crypto.PubkeyToAddress(keys[0].PublicKey)
+	ordering := LocalsFirstOrdering(signer, pending, []common.Address{local})
+
+	var senders []common.Address
+	for tx := ordering.Peek(); tx != nil; tx = ordering.Peek() {
+		from, _ := types.Sender(signer, tx)
+		senders = append(senders, from)
+		ordering.Shift()
+	}
+	want := []common.Address{
+		local, local,
+		crypto.PubkeyToAddress(keys[2].PublicKey), crypto.PubkeyToAddress(keys[2].PublicKey),
+		crypto.PubkeyToAddress(keys[1].PublicKey), crypto.PubkeyToAddress(keys[1].PublicKey),
+	}
+	if len(senders) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(senders), len(want))
+	}
+	for i := range want {
+		if senders[i] != want[i] {
+			t.Errorf("transaction %d: sender mismatch: have %x, want %x", i, senders[i], want[i])
+		}
+	}
+}
+
+// Tests that popping an account skips its remaining transactions but moves on
+// to the next ordering once the current one is exhausted.
+func TestChainedOrderingPop(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pending := make(map[common.Address]types.Transactions)
+	for _, key := range []*ecdsa.PrivateKey{local, remote} {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 3; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), signer, key)
+			pending[addr] = append(pending[addr], tx)
+		}
+	}
+	ordering := NewPriorityOrdering(signer, pending, []common.Address{crypto.PubkeyToAddress(local.PublicKey)})
+	ordering.Pop()
+
+	count := 0
+	for tx := ordering.Peek(); tx != nil; tx = ordering.Peek() {
+		if from, _ := types.Sender(signer, tx); from != crypto.PubkeyToAddress(remote.PublicKey) {
+			t.Fatalf("transaction of popped account yielded")
+		}
+		count++
+		ordering.Shift()
+	}
+	if count != 3 {
+		t.Fatalf("remote transaction count mismatch: have %d, want %d", count, 3)
+	}
+}
+
+// Tests that orderings can be looked up by name and unknown ones are rejected.
+func TestTxOrderingRegistry(t *testing.T) {
+	RegisterTxOrdering("test", PriceOrdering)
+
+	if _, err := lookupTxOrdering("test"); err != nil {
+		t.Fatalf("failed to look up registered ordering: %v", err)
+	}
+	if _, err := lookupTxOrdering(DefaultTxOrdering); err != nil {
+		t.Fatalf("failed to look up default ordering: %v", err)
+	}
+	if _, err := lookupTxOrdering("nonexistent"); err == nil {
+		t.Fatalf("unknown ordering accepted")
+	}
+}
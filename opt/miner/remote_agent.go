@@ -24,8 +24,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"gitlab.com/aquachain/aquachain/aqua/event"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/metrics"
 	"gitlab.com/aquachain/aquachain/consensus"
 	"gitlab.com/aquachain/aquachain/consensus/aquahash"
 	"gitlab.com/aquachain/aquachain/core"
@@ -33,12 +35,33 @@ import (
 	"gitlab.com/aquachain/aquachain/rlp"
 )
 
+// DefaultStaleWindow is the number of blocks the head may have moved past a
+// work package while solutions to it are still accepted.
+const DefaultStaleWindow = 2
+
+var (
+	acceptedSubmitCounter = metrics.NewRegisteredCounter("miner/remote/accepted", nil)
+	staleSubmitCounter    = metrics.NewRegisteredCounter("miner/remote/stale", nil)   // Accepted, but for work behind the head
+	expiredSubmitCounter  = metrics.NewRegisteredCounter("miner/remote/expired", nil) // Unknown work, or too far behind the head
+	invalidSubmitCounter  = metrics.NewRegisteredCounter("miner/remote/invalid", nil) // Failed seal verification
+)
+
 type hashrate struct {
 	ping time.Time
 	rate uint64
 }
 
+// SubmitStats counts the solutions submitted to a remote agent by outcome.
+type SubmitStats struct {
+	Accepted uint64 // Solutions to current work
+	Stale    uint64 // Solutions to work behind the head, still accepted
+	Expired  uint64 // Solutions to unknown work or work beyond the stale window
+	Invalid  uint64 // Solutions failing seal verification
+}
+
 type RemoteAgent struct {
+	stats SubmitStats // Submission counters, accessed atomically (first for 64-bit alignment)
+
 	mu sync.Mutex
 
 	quitCh   chan struct{}
@@ -53,18 +76,50 @@ type RemoteAgent struct {
 	hashrateMu sync.RWMutex
 	hashrate   map[common.Hash]hashrate
 
+	workFeed event.Feed
+	scope    event.SubscriptionScope
+
+	staleWindow uint64 // Number of blocks work stays valid after the head moved past it
+
 	running int32 // running indicates whether the agent is active. Call atomically
 }
 
 func NewRemoteAgent(chain consensus.ChainReader, engine consensus.Engine) *RemoteAgent {
 	return &RemoteAgent{
-		chain:    chain,
-		engine:   engine,
-		work:     make(map[common.Hash]*Work),
-		hashrate: make(map[common.Hash]hashrate),
+		chain:       chain,
+		engine:      engine,
+		work:        make(map[common.Hash]*Work),
+		hashrate:    make(map[common.Hash]hashrate),
+		staleWindow: DefaultStaleWindow,
+	}
+}
+
+// SetStaleWindow sets the number of blocks the head may move past a work
+// package while solutions to it are still accepted, flagged as stale. Zero
+// only accepts solutions to work on top of the head.
+func (a *RemoteAgent) SetStaleWindow(blocks uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.staleWindow = blocks
+}
+
+// SubmitStats returns the counts of the solutions submitted to the agent.
+func (a *RemoteAgent) SubmitStats() SubmitStats {
+	return SubmitStats{
+		Accepted: atomic.LoadUint64(&a.stats.Accepted),
+		Stale:    atomic.LoadUint64(&a.stats.Stale),
+		Expired:  atomic.LoadUint64(&a.stats.Expired),
+		Invalid:  atomic.LoadUint64(&a.stats.Invalid),
 	}
 }
 
+// expired reports whether the head moved past the work by more than the stale
+// window. The caller must hold a.mu.
+func (a *RemoteAgent) expired(work *Work, head uint64) bool {
+	return work.Block.NumberU64()+a.staleWindow <= head
+}
+
 func (a *RemoteAgent) SubmitHashrate(id common.Hash, rate uint64) {
 	//a.hashrateMu.Lock()
 	//defer a.hashrateMu.Unlock()
@@ -157,25 +212,36 @@ func (a *RemoteAgent) GetWork() ([3]string, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	var res [3]string
-
 	if a.currentWork != nil {
-		block := a.currentWork.Block
+		return a.workPackage(a.currentWork), nil
+	}
+	return [3]string{}, errors.New("No work available yet, don't panic.")
+}
 
-		res[0] = block.HashNoNonce().Hex()
-		seedHash := aquahash.SeedHash(block.NumberU64(), byte(block.Version()))
-		res[1] = common.BytesToHash(seedHash).Hex()
-		// Calculate the "target" to be returned to the external miner
-		n := big.NewInt(1)
-		n.Lsh(n, 255)
-		n.Div(n, block.Difficulty())
-		n.Lsh(n, 1)
-		res[2] = common.BytesToHash(n.Bytes()).Hex()
+// SubscribeNewWork subscribes to the work packages handed out to external
+// miners, in the format returned by GetWork, as soon as new work is available.
+func (a *RemoteAgent) SubscribeNewWork(ch chan<- [3]string) event.Subscription {
+	return a.scope.Track(a.workFeed.Subscribe(ch))
+}
 
-		a.work[block.HashNoNonce()] = a.currentWork
-		return res, nil
-	}
-	return res, errors.New("No work available yet, don't panic.")
+// workPackage returns the work package of the given work for external miners,
+// registering it so solutions to it are accepted. The caller must hold a.mu.
+func (a *RemoteAgent) workPackage(work *Work) [3]string {
+	var res [3]string
+
+	block := work.Block
+	res[0] = block.HashNoNonce().Hex()
+	seedHash := aquahash.SeedHash(block.NumberU64(), byte(block.Version()))
+	res[1] = common.BytesToHash(seedHash).Hex()
+	// Calculate the "target" to be returned to the external miner
+	n := big.NewInt(1)
+	n.Lsh(n, 255)
+	n.Div(n, block.Difficulty())
+	n.Lsh(n, 1)
+	res[2] = common.BytesToHash(n.Bytes()).Hex()
+
+	a.work[block.HashNoNonce()] = work
+	return res
 }
 
 // SubmitWork tries to inject a pow solution into the remote agent, returning
@@ -185,10 +251,13 @@ func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Make sure the work submitted is present
+	// Make sure the work submitted is present and not too far behind the head
+	head := a.chain.CurrentHeader().Number.Uint64()
 	work := a.work[hash]
-	if work == nil {
+	if work == nil || a.expired(work, head) {
 		log.Info("Work submitted but wasnt pending", "hash", hash)
+		atomic.AddUint64(&a.stats.Expired, 1)
+		expiredSubmitCounter.Inc(1)
 		return false
 	}
 	// Make sure the Engine solutions is indeed valid
@@ -200,10 +269,22 @@ func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.
 	}
 	if err := a.engine.VerifySeal(a.chain, result); err != nil {
 		log.Warn("Invalid proof-of-work submitted", "hash", hash, "err", err)
+		atomic.AddUint64(&a.stats.Invalid, 1)
+		invalidSubmitCounter.Inc(1)
 		return false
 	}
 	block := work.Block.WithSeal(result)
 
+	// Solutions to work the head moved past may still make it as side blocks
+	if number := block.NumberU64(); number <= head {
+		log.Info("Accepted solution to stale work", "number", number, "head", head, "hash", hash)
+		atomic.AddUint64(&a.stats.Stale, 1)
+		staleSubmitCounter.Inc(1)
+	} else {
+		atomic.AddUint64(&a.stats.Accepted, 1)
+		acceptedSubmitCounter.Inc(1)
+	}
+
 	// Solutions seems to be valid, return to the miner and notify acceptance
 	a.returnCh <- &Result{work, block}
 	delete(a.work, hash)
@@ -228,12 +309,22 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 		case work := <-workCh:
 			a.mu.Lock()
 			a.currentWork = work
+			var res [3]string
+			subscribed := a.scope.Count() > 0
+			if subscribed {
+				res = a.workPackage(work)
+			}
 			a.mu.Unlock()
+
+			if subscribed {
+				a.workFeed.Send(res)
+			}
 		case <-ticker.C:
-			// cleanup
+			// cleanup work the head moved past by more than the stale window
 			a.mu.Lock()
+			head := a.chain.CurrentHeader().Number.Uint64()
 			for hash, work := range a.work {
-				if time.Since(work.createdAt) > 7*(12*time.Second) {
+				if a.expired(work, head) {
 					delete(a.work, hash)
 				}
 			}
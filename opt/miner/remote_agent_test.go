@@ -0,0 +1,126 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+// headChain is a chain reader only reporting a settable head.
+type headChain struct {
+	consensus.ChainReader
+	head *types.Header
+}
+
+func (c *headChain) CurrentHeader() *types.Header { return c.head }
+
+// sealEngine is an engine only accepting seals with a non zero nonce.
+type sealEngine struct {
+	consensus.Engine
+}
+
+func (sealEngine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if header.Nonce == (types.BlockNonce{}) {
+		return errors.New("invalid seal")
+	}
+	return nil
+}
+
+// Tests that new work is pushed to the subscribers and registered for
+// submission without anybody calling GetWork.
+func TestRemoteAgentNewWork(t *testing.T) {
+	agent := NewRemoteAgent(nil, nil)
+	agent.Start()
+	defer agent.Stop()
+
+	works := make(chan [3]string, 1)
+	sub := agent.SubscribeNewWork(works)
+	defer sub.Unsubscribe()
+
+	for i := int64(1); i <= 2; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(1000), Version: 2})
+		agent.Work() <- &Work{Block: block, createdAt: time.Now()}
+
+		select {
+		case work := <-works:
+			if want := block.HashNoNonce().Hex(); work[0] != want {
+				t.Fatalf("work %d: hash mismatch: have %s, want %s", i, work[0], want)
+			}
+			if want := common.BytesToHash([]byte{2}).Hex(); work[1] != want {
+				t.Errorf("work %d: seed mismatch: have %s, want %s", i, work[1], want)
+			}
+			agent.mu.Lock()
+			_, ok := agent.work[block.HashNoNonce()]
+			agent.mu.Unlock()
+			if !ok {
+				t.Errorf("work %d: pushed work not registered for submission", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("work %d: not pushed", i)
+		}
+	}
+}
+
+// Tests that solutions to work the head moved past are accepted within the
+// stale window and that the submissions are counted by outcome.
+func TestRemoteAgentStaleWork(t *testing.T) {
+	chain := &headChain{head: &types.Header{Number: big.NewInt(9)}}
+	agent := NewRemoteAgent(chain, sealEngine{})
+	results := make(chan *Result, 8)
+	agent.SetReturnCh(results)
+
+	works := make(map[int64]common.Hash)
+	for i := int64(10); i <= 12; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(1000), Version: 2})
+		works[i] = block.HashNoNonce()
+		agent.work[works[i]] = &Work{Block: block, createdAt: time.Now()}
+	}
+	valid := types.EncodeNonce(1)
+
+	// Head at 11: work 12 is current, 11 and 10 are stale, 10 is one block past the window
+	chain.head = &types.Header{Number: big.NewInt(11)}
+	if agent.SubmitWork(types.BlockNonce{}, common.Hash{}, works[12]) {
+		t.Errorf("invalid seal accepted")
+	}
+	if !agent.SubmitWork(valid, common.Hash{}, works[12]) {
+		t.Errorf("solution to current work rejected")
+	}
+	if !agent.SubmitWork(valid, common.Hash{}, works[11]) {
+		t.Errorf("solution to stale work within window rejected")
+	}
+	if agent.SubmitWork(valid, common.Hash{}, works[12]) {
+		t.Errorf("duplicate solution accepted")
+	}
+	agent.SetStaleWindow(1)
+	if agent.SubmitWork(valid, common.Hash{}, works[10]) {
+		t.Errorf("solution to work beyond the window accepted")
+	}
+	if have := len(results); have != 2 {
+		t.Errorf("results mismatch: have %d, want 2", have)
+	}
+	want := SubmitStats{Accepted: 1, Stale: 1, Expired: 2, Invalid: 1}
+	if have := agent.SubmitStats(); have != want {
+		t.Errorf("stats mismatch: have %+v, want %+v", have, want)
+	}
+}
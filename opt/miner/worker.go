@@ -115,6 +115,7 @@ type worker struct {
 
 	currentMu sync.Mutex
 	current   *Work
+	ordering  TxOrderingFunc // Transaction selection strategy, protected by currentMu
 
 	uncleMu        sync.Mutex
 	possibleUncles map[common.Hash]*types.Block
@@ -143,6 +144,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		coinbase:       coinbase,
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(aqua.BlockChain(), miningLogAtDepth),
+		ordering:       PriceOrdering,
 	}
 	// Subscribe TxPreEvent for tx pool
 	worker.txSub = aqua.TxPool().SubscribeTxPreEvent(worker.txCh)
@@ -169,6 +171,12 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+func (w *worker) setOrdering(ordering TxOrderingFunc) {
+	w.currentMu.Lock()
+	defer w.currentMu.Unlock()
+	w.ordering = ordering
+}
+
 func (w *worker) pending() (*types.Block, *state.StateDB) {
 	w.currentMu.Lock()
 	defer w.currentMu.Unlock()
@@ -264,9 +272,9 @@ func (w *worker) update() {
 				w.currentMu.Lock()
 				acc, _ := types.Sender(w.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+				txset := w.ordering(w.current.signer, txs, w.aqua.TxPool().Locals())
 
-				w.current.commitTransactions(w.mux, txset, w.chain, w.coinbase)
+				w.current.commitTransactions(w.mux, txset, w.chain, w.aqua.TxPool(), w.coinbase)
 				w.currentMu.Unlock()
 			}
 
@@ -499,8 +507,8 @@ func (w *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(w.current.signer, pending)
-	work.commitTransactions(w.mux, txs, w.chain, w.coinbase)
+	txs := w.ordering(w.current.signer, pending, w.aqua.TxPool().Locals())
+	work.commitTransactions(w.mux, txs, w.chain, w.aqua.TxPool(), w.coinbase)
 
 	// Create the new block to seal with the consensus engine
 	if work.Block, err = w.engine.Finalize(w.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
@@ -536,7 +544,7 @@ func (w *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TxOrdering, bc *core.BlockChain, pool *core.TxPool, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -564,6 +572,14 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			txs.Pop()
 			continue
 		}
+		// Skip the account if a conditional transaction can't be included in this block
+		if cond := pool.Conditions(tx.Hash()); cond != nil {
+			if err := cond.Check(env.header, env.state); err != nil {
+				log.Trace("Ignoring conditional transaction", "hash", tx.Hash(), "err", err)
+				txs.Pop()
+				continue
+			}
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
 
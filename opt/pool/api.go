@@ -0,0 +1,181 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pool
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/rlp"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// maxListedBlocks is the number of most recent blocks returned by pool_blocks.
+const maxListedBlocks = 100
+
+// Stats is the summary of the pool returned by pool_stats.
+type Stats struct {
+	Scheme     string         `json:"scheme"`
+	Fee        hexutil.Uint64 `json:"fee"` // basis points of the block reward
+	Hashrate   hexutil.Uint64 `json:"hashrate"`
+	Workers    int            `json:"workers"` // workers submitting shares within the hashrate window
+	RoundShare hexutil.Uint64 `json:"roundShares"`
+	Window     hexutil.Uint64 `json:"windowShares"`
+	Blocks     hexutil.Uint64 `json:"blocks"`
+}
+
+// WorkerStats describes a worker, as returned by pool_workers.
+type WorkerStats struct {
+	Login     string         `json:"login"`
+	Account   string         `json:"account"`
+	Hashrate  hexutil.Uint64 `json:"hashrate"`
+	Shares    hexutil.Uint64 `json:"shares"`
+	LastShare hexutil.Uint64 `json:"lastShare"`
+}
+
+// BlockStats describes a block found by the pool, as returned by pool_blocks.
+type BlockStats struct {
+	Hash   common.Hash    `json:"hash"`
+	Finder string         `json:"finder"`
+	Time   hexutil.Uint64 `json:"time"`
+	Scheme string         `json:"scheme"`
+	Reward *hexutil.Big   `json:"reward"`
+	Shares hexutil.Uint64 `json:"shares"`
+}
+
+// PublicPoolAPI exposes the accounting of the pool.
+type PublicPoolAPI struct {
+	p *Pool
+}
+
+// NewPublicPoolAPI creates a new pool API.
+func NewPublicPoolAPI(p *Pool) *PublicPoolAPI {
+	return &PublicPoolAPI{p}
+}
+
+// APIs returns the RPC APIs of the pool.
+func (p *Pool) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "pool",
+			Version:   "1.0",
+			Service:   NewPublicPoolAPI(p),
+			Public:    true,
+		},
+	}
+}
+
+// Stats returns the summary of the pool.
+func (api *PublicPoolAPI) Stats() Stats {
+	p := api.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := Stats{
+		Scheme:     p.config.Scheme,
+		Fee:        hexutil.Uint64(p.config.Fee),
+		RoundShare: hexutil.Uint64(p.roundSum),
+		Window:     hexutil.Uint64(p.windowSum),
+		Blocks:     hexutil.Uint64(p.blocks),
+	}
+	now := time.Now()
+	for _, w := range p.workers {
+		stats.Hashrate += hexutil.Uint64(w.hashrate(now, p.config.Hashrate))
+		if len(w.samples) > 0 {
+			stats.Workers++
+		}
+	}
+	return stats
+}
+
+// Workers returns the workers which submitted shares since the pool started,
+// sorted by login.
+func (api *PublicPoolAPI) Workers() []WorkerStats {
+	p := api.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	workers := make([]WorkerStats, 0, len(p.workers))
+	for login, w := range p.workers {
+		workers = append(workers, WorkerStats{
+			Login:     login,
+			Account:   w.account,
+			Hashrate:  hexutil.Uint64(w.hashrate(now, p.config.Hashrate)),
+			Shares:    hexutil.Uint64(w.shares),
+			LastShare: hexutil.Uint64(w.last.Unix()),
+		})
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Login < workers[j].Login })
+	return workers
+}
+
+// Balance returns the balance credited to an account.
+func (api *PublicPoolAPI) Balance(account string) *hexutil.Big {
+	p := api.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if balance := p.balances[account]; balance != nil {
+		return (*hexutil.Big)(new(big.Int).Set(balance))
+	}
+	return (*hexutil.Big)(new(big.Int))
+}
+
+// Balances returns the balances credited to all accounts.
+func (api *PublicPoolAPI) Balances() map[string]*hexutil.Big {
+	p := api.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	balances := make(map[string]*hexutil.Big, len(p.balances))
+	for account, balance := range p.balances {
+		balances[account] = (*hexutil.Big)(new(big.Int).Set(balance))
+	}
+	return balances
+}
+
+// Blocks returns the most recent blocks found by the pool, newest first.
+func (api *PublicPoolAPI) Blocks() ([]BlockStats, error) {
+	p := api.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var blocks []BlockStats
+	for num := p.blocks; num > 0 && len(blocks) < maxListedBlocks; num-- {
+		blob, err := p.db.Get(numKey(blockPrefix, num-1))
+		if err != nil {
+			return nil, err
+		}
+		b := new(block)
+		if err := rlp.DecodeBytes(blob, b); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, BlockStats{
+			Hash:   b.Hash,
+			Finder: b.Finder,
+			Time:   hexutil.Uint64(b.Time),
+			Scheme: b.Scheme,
+			Reward: (*hexutil.Big)(b.Reward),
+			Shares: hexutil.Uint64(b.Shares),
+		})
+	}
+	return blocks, nil
+}
@@ -0,0 +1,387 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pool implements the share accounting of a small mining pool served by
+// the stratum server. It keeps a log of the accepted shares in an embedded
+// database, estimates the hashrate of every worker and credits the reward of
+// each block found to the accounts of the miners, either in proportion to their
+// shares of the round (PROP) or of the last N units of difficulty (PPLNS).
+//
+// Balances are only accounted for; paying them out is left to the operator.
+package pool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rlp"
+)
+
+// Payout schemes
+const (
+	SchemePPLNS = "pplns" // Pay per last N shares
+	SchemePROP  = "prop"  // Proportional to the shares of the round
+)
+
+var (
+	headKey       = []byte("pool-head")     // Sequence number of the next share
+	tailKey       = []byte("pool-tail")     // Sequence number of the oldest share stored
+	windowKey     = []byte("pool-window")   // Sequence number of the first share in the PPLNS window
+	roundKey      = []byte("pool-round")    // Sequence number of the first share in the round
+	blockCountKey = []byte("pool-blocks")   // Number of blocks found
+	balancesKey   = []byte("pool-balances") // Credited balances of all accounts
+
+	sharePrefix = []byte("pool-share-") // sharePrefix + num (uint64 big endian) -> share
+	blockPrefix = []byte("pool-block-") // blockPrefix + num (uint64 big endian) -> block
+)
+
+// Config contains the settings of the pool accounting.
+type Config struct {
+	Scheme   string        // Payout scheme, SchemePPLNS or SchemePROP
+	Window   uint64        // PPLNS window in units of difficulty (0 = twice the network difficulty)
+	Fee      uint64        // Pool fee in basis points of the block reward
+	Reward   *big.Int      // Reward of a block found, in wei
+	Hashrate time.Duration // Time window of estimating the hashrate of workers
+}
+
+// DefaultConfig contains the default pool accounting settings.
+var DefaultConfig = Config{
+	Scheme:   SchemePPLNS,
+	Fee:      100,
+	Reward:   params.BlockReward,
+	Hashrate: 10 * time.Minute,
+}
+
+// share is an accepted share as stored in the database.
+type share struct {
+	Account    string
+	Worker     string
+	Difficulty uint64
+	Time       uint64
+}
+
+// block is a block found by the pool as stored in the database.
+type block struct {
+	Hash   common.Hash // Header hash of the work sealed
+	Finder string      // Login of the worker sealing the block
+	Time   uint64
+	Scheme string
+	Reward *big.Int // Reward credited to the miners, net of the pool fee
+	Shares uint64   // Difficulty of the shares credited
+}
+
+// balance is the credited balance of an account as stored in the database.
+type balance struct {
+	Account string
+	Balance *big.Int
+}
+
+// sample is a share counted towards the hashrate of a worker.
+type sample struct {
+	time       time.Time
+	difficulty uint64
+}
+
+// worker tracks the shares of a single worker in memory.
+type worker struct {
+	account string
+	samples []sample // Shares within the hashrate window, oldest first
+	shares  uint64   // Number of shares since the pool started
+	last    time.Time
+}
+
+// hashrate estimates the hashes per second of the worker, dropping the samples
+// older than the window.
+func (w *worker) hashrate(now time.Time, window time.Duration) uint64 {
+	i := 0
+	for i < len(w.samples) && now.Sub(w.samples[i].time) > window {
+		i++
+	}
+	w.samples = w.samples[i:]
+
+	var sum uint64
+	for _, s := range w.samples {
+		sum += s.difficulty
+	}
+	return uint64(float64(sum) / window.Seconds())
+}
+
+// Pool accounts the shares of the workers of a stratum server.
+type Pool struct {
+	config Config
+	db     aquadb.Database
+
+	mu        sync.Mutex
+	head      uint64   // Sequence number of the next share
+	tail      uint64   // Sequence number of shares[0]
+	window    uint64   // Sequence number of the first share in the PPLNS window
+	round     uint64   // Sequence number of the first share in the round
+	blocks    uint64   // Number of blocks found
+	shares    []*share // Shares from tail up to head
+	windowSum uint64   // Difficulty of the shares in the PPLNS window
+	roundSum  uint64   // Difficulty of the shares in the round
+	balances  map[string]*big.Int
+	workers   map[string]*worker
+
+	sub  event.Subscription
+	done chan struct{}
+}
+
+// New creates the accounting of a pool, restoring its state from the database.
+func New(db aquadb.Database, config Config) (*Pool, error) {
+	if config.Scheme != SchemePPLNS && config.Scheme != SchemePROP {
+		return nil, fmt.Errorf("unknown payout scheme %q", config.Scheme)
+	}
+	if config.Fee > 10000 {
+		return nil, errors.New("pool fee above 100%")
+	}
+	if config.Reward == nil {
+		config.Reward = DefaultConfig.Reward
+	}
+	if config.Hashrate <= 0 {
+		config.Hashrate = DefaultConfig.Hashrate
+	}
+	p := &Pool{
+		config:   config,
+		db:       db,
+		head:     readCounter(db, headKey),
+		tail:     readCounter(db, tailKey),
+		window:   readCounter(db, windowKey),
+		round:    readCounter(db, roundKey),
+		blocks:   readCounter(db, blockCountKey),
+		balances: make(map[string]*big.Int),
+		workers:  make(map[string]*worker),
+	}
+	for num := p.tail; num < p.head; num++ {
+		blob, err := db.Get(numKey(sharePrefix, num))
+		if err != nil {
+			return nil, fmt.Errorf("missing share %d: %v", num, err)
+		}
+		s := new(share)
+		if err := rlp.DecodeBytes(blob, s); err != nil {
+			return nil, fmt.Errorf("invalid share %d: %v", num, err)
+		}
+		p.shares = append(p.shares, s)
+		if num >= p.window {
+			p.windowSum += s.Difficulty
+		}
+		if num >= p.round {
+			p.roundSum += s.Difficulty
+		}
+	}
+	if blob, err := db.Get(balancesKey); err == nil {
+		var balances []balance
+		if err := rlp.DecodeBytes(blob, &balances); err != nil {
+			return nil, fmt.Errorf("invalid balances: %v", err)
+		}
+		for _, b := range balances {
+			p.balances[b.Account] = b.Balance
+		}
+	}
+	log.Info("Pool accounting loaded", "scheme", config.Scheme, "shares", len(p.shares), "blocks", p.blocks, "accounts", len(p.balances))
+	return p, nil
+}
+
+// Start starts accounting the shares accepted by the stratum server.
+func (p *Pool) Start(server *stratum.Server) {
+	ch := make(chan stratum.ShareEvent, 256)
+	p.sub = server.SubscribeShares(ch)
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		for {
+			select {
+			case ev := <-ch:
+				if err := p.Share(ev); err != nil {
+					log.Error("Failed to account share", "worker", ev.Login, "err", err)
+				}
+			case <-p.sub.Err():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops accounting shares.
+func (p *Pool) Stop() {
+	p.sub.Unsubscribe()
+	<-p.done
+}
+
+// Share accounts an accepted share, crediting the block reward if it sealed a
+// block.
+func (p *Pool) Share(ev stratum.ShareEvent) error {
+	account, name := splitLogin(ev.Login)
+	s := &share{Account: account, Worker: name, Difficulty: ev.Difficulty, Time: uint64(ev.Time.Unix())}
+	blob, err := rlp.EncodeToBytes(s)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch := p.db.NewBatch()
+	batch.Put(numKey(sharePrefix, p.head), blob)
+	p.shares = append(p.shares, s)
+	p.head++
+	p.windowSum += s.Difficulty
+	p.roundSum += s.Difficulty
+
+	w := p.workers[ev.Login]
+	if w == nil {
+		w = &worker{account: account}
+		p.workers[ev.Login] = w
+	}
+	w.samples = append(w.samples, sample{ev.Time, ev.Difficulty})
+	w.shares++
+	w.last = ev.Time
+
+	// Slide the PPLNS window, keeping at least N units of difficulty
+	n := p.config.Window
+	if n == 0 {
+		n = 2 * ev.NetDifficulty
+	}
+	for p.window+1 < p.head {
+		oldest := p.shares[p.window-p.tail].Difficulty
+		if p.windowSum-oldest < n {
+			break
+		}
+		p.windowSum -= oldest
+		p.window++
+	}
+	if ev.Block {
+		if err := p.credit(batch, ev); err != nil {
+			return err
+		}
+	}
+	// Drop the shares neither in the window nor in the round
+	tail := p.window
+	if p.round < tail {
+		tail = p.round
+	}
+	for ; p.tail < tail; p.tail++ {
+		batch.Delete(numKey(sharePrefix, p.tail))
+		p.shares = p.shares[1:]
+	}
+	writeCounter(batch, headKey, p.head)
+	writeCounter(batch, tailKey, p.tail)
+	writeCounter(batch, windowKey, p.window)
+	writeCounter(batch, roundKey, p.round)
+	return batch.Write()
+}
+
+// credit splits the reward of a block found among the accounts with shares in
+// the window or round, and starts a new round.
+func (p *Pool) credit(batch aquadb.Batch, ev stratum.ShareEvent) error {
+	start := p.window
+	if p.config.Scheme == SchemePROP {
+		start = p.round
+	}
+	var (
+		shares = make(map[string]uint64)
+		total  uint64
+	)
+	for _, s := range p.shares[start-p.tail:] {
+		shares[s.Account] += s.Difficulty
+		total += s.Difficulty
+	}
+	net := new(big.Int).Mul(p.config.Reward, new(big.Int).SetUint64(10000-p.config.Fee))
+	net.Div(net, big.NewInt(10000))
+
+	for account, credit := range split(net, shares, total) {
+		if p.balances[account] == nil {
+			p.balances[account] = new(big.Int)
+		}
+		p.balances[account].Add(p.balances[account], credit)
+	}
+	balances := make([]balance, 0, len(p.balances))
+	for account, amount := range p.balances {
+		balances = append(balances, balance{account, amount})
+	}
+	blob, err := rlp.EncodeToBytes(balances)
+	if err != nil {
+		return err
+	}
+	batch.Put(balancesKey, blob)
+
+	found := &block{Hash: ev.Hash, Finder: ev.Login, Time: uint64(ev.Time.Unix()), Scheme: p.config.Scheme, Reward: net, Shares: total}
+	if blob, err = rlp.EncodeToBytes(found); err != nil {
+		return err
+	}
+	batch.Put(numKey(blockPrefix, p.blocks), blob)
+	p.blocks++
+	writeCounter(batch, blockCountKey, p.blocks)
+
+	log.Info("Pool block reward credited", "hash", ev.Hash, "finder", ev.Login, "scheme", p.config.Scheme,
+		"accounts", len(shares), "round", p.roundSum, "credited", total)
+	p.round, p.roundSum = p.head, 0
+	return nil
+}
+
+// split divides the amount among the accounts in proportion to their shares.
+// The remainder of the integer division is left to the pool.
+func split(amount *big.Int, shares map[string]uint64, total uint64) map[string]*big.Int {
+	credits := make(map[string]*big.Int, len(shares))
+	if total == 0 {
+		return credits
+	}
+	for account, difficulty := range shares {
+		credit := new(big.Int).Mul(amount, new(big.Int).SetUint64(difficulty))
+		credits[account] = credit.Div(credit, new(big.Int).SetUint64(total))
+	}
+	return credits
+}
+
+// splitLogin splits a worker login of the form "account.worker".
+func splitLogin(login string) (account, worker string) {
+	if i := strings.IndexByte(login, '.'); i >= 0 {
+		return login[:i], login[i+1:]
+	}
+	return login, ""
+}
+
+func numKey(prefix []byte, num uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], num)
+	return key
+}
+
+func readCounter(db aquadb.Database, key []byte) uint64 {
+	blob, err := db.Get(key)
+	if err != nil || len(blob) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(blob)
+}
+
+func writeCounter(db aquadb.Putter, key []byte, num uint64) {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, num)
+	db.Put(key, blob)
+}
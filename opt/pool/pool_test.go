@@ -0,0 +1,142 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pool
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/opt/stratum"
+)
+
+// testShares submits shares of 5 by alice and 4 by bob, the last one sealing a
+// block.
+func testShares(t *testing.T, p *Pool, now time.Time) {
+	shares := []stratum.ShareEvent{
+		{Login: "alice.rig1", Difficulty: 5},
+		{Login: "alice.rig2", Difficulty: 5},
+		{Login: "alice.rig1", Difficulty: 5},
+		{Login: "bob", Difficulty: 4},
+		{Login: "bob", Difficulty: 4, Block: true, Hash: common.HexToHash("0x01")},
+	}
+	for i, ev := range shares {
+		ev.NetDifficulty = 1000
+		ev.Time = now
+		if err := p.Share(ev); err != nil {
+			t.Fatalf("share %d: failed to account: %v", i, err)
+		}
+	}
+}
+
+func checkBalances(t *testing.T, p *Pool, want map[string]int64) {
+	balances := NewPublicPoolAPI(p).Balances()
+	if len(balances) != len(want) {
+		t.Errorf("balance count mismatch: have %d, want %d", len(balances), len(want))
+	}
+	for account, amount := range want {
+		if have := balances[account]; have == nil || have.ToInt().Int64() != amount {
+			t.Errorf("balance of %s mismatch: have %v, want %d", account, have, amount)
+		}
+	}
+}
+
+func TestPayoutSchemes(t *testing.T) {
+	tests := []struct {
+		scheme string
+		fee    uint64
+		want   map[string]int64
+	}{
+		// The window of 10 covers the last three shares: 5 of alice, 8 of bob
+		{SchemePPLNS, 0, map[string]int64{"alice": 384, "bob": 615}},
+		// The round covers all shares: 15 of alice, 8 of bob
+		{SchemePROP, 0, map[string]int64{"alice": 652, "bob": 347}},
+		{SchemePROP, 100, map[string]int64{"alice": 645, "bob": 344}},
+	}
+	for _, tt := range tests {
+		p, err := New(aquadb.NewMemDatabase(), Config{Scheme: tt.scheme, Window: 10, Fee: tt.fee, Reward: big.NewInt(1000)})
+		if err != nil {
+			t.Fatalf("%s: failed to create pool: %v", tt.scheme, err)
+		}
+		testShares(t, p, time.Now())
+		checkBalances(t, p, tt.want)
+	}
+}
+
+func TestPoolPersistence(t *testing.T) {
+	db := aquadb.NewMemDatabase()
+	config := Config{Scheme: SchemePPLNS, Window: 10, Reward: big.NewInt(1000)}
+
+	p, err := New(db, config)
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	testShares(t, p, time.Now())
+
+	// Shares outside the window are pruned, the rest survive a restart
+	if p.tail != 2 || len(p.shares) != 3 {
+		t.Fatalf("pruning mismatch: tail %d, %d shares, want tail 2, 3 shares", p.tail, len(p.shares))
+	}
+	if has, _ := db.Has(numKey(sharePrefix, 1)); has {
+		t.Errorf("pruned share still in the database")
+	}
+	if p, err = New(db, config); err != nil {
+		t.Fatalf("failed to reload pool: %v", err)
+	}
+	if p.head != 5 || p.window != 2 || p.round != 5 || p.windowSum != 13 || p.roundSum != 0 {
+		t.Errorf("state mismatch: head %d window %d round %d windowSum %d roundSum %d", p.head, p.window, p.round, p.windowSum, p.roundSum)
+	}
+	checkBalances(t, p, map[string]int64{"alice": 384, "bob": 615})
+
+	blocks, err := NewPublicPoolAPI(p).Blocks()
+	if err != nil {
+		t.Fatalf("failed to list blocks: %v", err)
+	}
+	if len(blocks) != 1 || blocks[0].Finder != "bob" || blocks[0].Shares != 13 || blocks[0].Reward.ToInt().Int64() != 1000 {
+		t.Errorf("block mismatch: %+v", blocks)
+	}
+}
+
+func TestWorkerHashrate(t *testing.T) {
+	p, err := New(aquadb.NewMemDatabase(), Config{Scheme: SchemePPLNS, Hashrate: 100 * time.Second})
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	testShares(t, p, time.Now())
+
+	workers := NewPublicPoolAPI(p).Workers()
+	if len(workers) != 3 {
+		t.Fatalf("worker count mismatch: have %d, want 3", len(workers))
+	}
+	// 10 units of difficulty per 100 seconds round down to nothing
+	if w := workers[0]; w.Login != "alice.rig1" || w.Account != "alice" || w.Shares != 2 || w.Hashrate != 0 {
+		t.Errorf("worker mismatch: %+v", w)
+	}
+	p.workers["alice.rig1"].samples[0].difficulty = 1000 // 1005 per 100 seconds
+	if rate := p.workers["alice.rig1"].hashrate(time.Now(), p.config.Hashrate); rate != 10 {
+		t.Errorf("hashrate mismatch: have %d, want 10", rate)
+	}
+	// Samples outside the window are forgotten
+	if rate := p.workers["alice.rig1"].hashrate(time.Now().Add(time.Hour), p.config.Hashrate); rate != 0 {
+		t.Errorf("stale hashrate mismatch: have %d, want 0", rate)
+	}
+	if stats := NewPublicPoolAPI(p).Stats(); stats.Workers != 2 || stats.Blocks != 1 {
+		t.Errorf("stats mismatch: %+v", stats)
+	}
+}
@@ -0,0 +1,629 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stratum implements a stratum work distribution server, allowing GPU
+// miners and farm proxies to hold a persistent connection to a work source
+// instead of long-polling aqua_getWork.
+//
+// The server speaks the line delimited JSON-RPC dialect known as stratum proxy
+// (eth_submitLogin, eth_getWork, eth_submitWork and eth_submitHashrate) and
+// pushes new jobs to logged in workers as soon as they become available. Every
+// worker mines against its own share difficulty. Shares are verified by the
+// server, and only those also meeting the network difficulty are handed on to
+// the work source.
+package stratum
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/crypto"
+)
+
+const (
+	maxJobs        = 8                // Number of recent jobs shares are accepted for
+	maxRequestSize = 4096             // Maximum length of a single request line
+	idleTimeout    = 10 * time.Minute // Time after which silent workers are dropped
+	writeTimeout   = 10 * time.Second // Time allowed for writing a message to a worker
+	sendQueue      = 16               // Messages queued for a worker before it is dropped
+)
+
+var (
+	errStaleShare     = errors.New("stale share")
+	errDuplicateShare = errors.New("duplicate share")
+	errLowDifficulty  = errors.New("low difficulty share")
+	errNoWork         = errors.New("no work available yet")
+	errNotLoggedIn    = errors.New("not logged in")
+	errSlowWorker     = errors.New("worker not keeping up")
+)
+
+// maxUint256 is a big integer representing 2^256-1
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+
+// Backend is the source of the work distributed by the server, either the
+// remote agent of a local miner or the RPC client of an upstream node.
+type Backend interface {
+	// GetWork returns the current work package (header hash, seed hash and
+	// target), as served by aqua_getWork.
+	GetWork() ([3]string, error)
+
+	// SubmitWork submits a solution for a work package previously returned by
+	// GetWork, reporting whether it was accepted.
+	SubmitWork(nonce types.BlockNonce, mixDigest, hash common.Hash) bool
+}
+
+// Config contains the settings of the stratum server.
+type Config struct {
+	Addr          string        // Listening address of the server (empty = disabled)
+	Difficulty    uint64        // Share difficulty of workers not requesting one
+	MinDifficulty uint64        // Lowest share difficulty a worker may request
+	Recommit      time.Duration // Interval of polling the backend for new work
+	MaxConns      int           // Maximum number of connected workers
+	MaxConnsPerIP int           // Maximum number of workers connected from a single IP
+}
+
+// DefaultConfig contains the default stratum server settings.
+var DefaultConfig = Config{
+	Difficulty:    100000,
+	MinDifficulty: 1000,
+	Recommit:      500 * time.Millisecond,
+	MaxConns:      1024,
+	MaxConnsPerIP: 32,
+}
+
+// ShareEvent is posted for every share accepted by the server.
+type ShareEvent struct {
+	Login         string      // Login of the worker submitting the share
+	Difficulty    uint64      // Difficulty the share was mined at
+	NetDifficulty uint64      // Difficulty of sealing the block at the time
+	Hash          common.Hash // Header hash of the job the share was mined for
+	Block         bool        // Whether the share sealed a block accepted by the backend
+	Time          time.Time
+}
+
+// job is a work package handed out to the workers.
+type job struct {
+	hash    common.Hash
+	seed    common.Hash
+	version byte     // Hash version of the work, 0 if shares cannot be verified
+	target  *big.Int // Network target a share must meet to seal the block
+
+	nonces map[uint64]struct{} // Nonces already submitted for the job
+}
+
+// newJob parses a work package as returned by the backend. The seed hash of
+// argon2id work carries the header version, older work can only be verified
+// by the backend itself.
+func newJob(work [3]string) *job {
+	j := &job{
+		hash:   common.HexToHash(work[0]),
+		seed:   common.HexToHash(work[1]),
+		target: new(big.Int).SetBytes(common.HexToHash(work[2]).Bytes()),
+		nonces: make(map[uint64]struct{}),
+	}
+	if v := new(big.Int).SetBytes(j.seed.Bytes()); v.Cmp(big.NewInt(2)) >= 0 && v.Cmp(big.NewInt(4)) <= 0 {
+		j.version = byte(v.Uint64())
+	}
+	return j
+}
+
+// shareTarget returns the target of shares at the given difficulty. Shares are
+// never harder than sealing the block, and work which cannot be verified is
+// always mined against the network target.
+func (j *job) shareTarget(difficulty uint64) *big.Int {
+	if j.version == 0 || difficulty == 0 {
+		return j.target
+	}
+	target := new(big.Int).Div(maxUint256, new(big.Int).SetUint64(difficulty))
+	if target.Cmp(j.target) < 0 {
+		return j.target
+	}
+	return target
+}
+
+// targetDifficulty converts a target into a difficulty, capped to 64 bits.
+func targetDifficulty(target *big.Int) uint64 {
+	if target.Sign() <= 0 {
+		return math.MaxUint64
+	}
+	difficulty := new(big.Int).Div(maxUint256, target)
+	if !difficulty.IsUint64() {
+		return math.MaxUint64
+	}
+	return difficulty.Uint64()
+}
+
+// Server distributes work to stratum workers and verifies their shares.
+type Server struct {
+	backend Backend
+	config  Config
+
+	mu       sync.Mutex
+	listener net.Listener
+	sessions map[*session]struct{}
+	peers    map[string]int // Number of connected workers per IP
+	jobs     map[common.Hash]*job
+	recent   []*job // Jobs in the order they were received, newest last
+
+	shareFeed event.Feed
+	scope     event.SubscriptionScope
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewServer creates a stratum server distributing the work of the backend.
+func NewServer(backend Backend, config Config) *Server {
+	if config.Recommit <= 0 {
+		config.Recommit = DefaultConfig.Recommit
+	}
+	if config.MaxConns <= 0 {
+		config.MaxConns = DefaultConfig.MaxConns
+	}
+	if config.MaxConnsPerIP <= 0 {
+		config.MaxConnsPerIP = DefaultConfig.MaxConnsPerIP
+	}
+	if config.Difficulty < config.MinDifficulty {
+		config.Difficulty = config.MinDifficulty
+	}
+	return &Server{
+		backend:  backend,
+		config:   config,
+		sessions: make(map[*session]struct{}),
+		peers:    make(map[string]int),
+		jobs:     make(map[common.Hash]*job),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start starts accepting workers on the listener and polling the backend for
+// new work.
+func (s *Server) Start(listener net.Listener) {
+	s.listener = listener
+	s.refresh()
+
+	s.wg.Add(2)
+	go s.accept()
+	go s.loop()
+}
+
+// Stop stops the server and disconnects all workers.
+func (s *Server) Stop() {
+	close(s.quit)
+	s.listener.Close()
+	s.scope.Close()
+
+	s.mu.Lock()
+	for sess := range s.sessions {
+		sess.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// SubscribeShares subscribes to the shares accepted by the server.
+func (s *Server) SubscribeShares(ch chan<- ShareEvent) event.Subscription {
+	return s.scope.Track(s.shareFeed.Subscribe(ch))
+}
+
+// accept accepts incoming worker connections until the listener is closed.
+func (s *Server) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				log.Warn("Stratum listener failed", "err", err)
+			}
+			return
+		}
+		ip := conn.RemoteAddr().String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		sess := newSession(s, conn)
+
+		s.mu.Lock()
+		if len(s.sessions) >= s.config.MaxConns || s.peers[ip] >= s.config.MaxConnsPerIP {
+			s.mu.Unlock()
+			log.Debug("Rejected stratum worker, too many connections", "addr", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		s.sessions[sess] = struct{}{}
+		s.peers[ip]++
+		s.mu.Unlock()
+
+		s.wg.Add(2)
+		go func() {
+			defer s.wg.Done()
+			sess.serve()
+
+			s.mu.Lock()
+			delete(s.sessions, sess)
+			if s.peers[ip]--; s.peers[ip] == 0 {
+				delete(s.peers, ip)
+			}
+			s.mu.Unlock()
+		}()
+		go func() {
+			defer s.wg.Done()
+			sess.write()
+		}()
+	}
+}
+
+// loop polls the backend for new work until the server is stopped.
+func (s *Server) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Recommit)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			s.refresh()
+		}
+	}
+}
+
+// refresh fetches the current work from the backend, pushing it to all logged
+// in workers if it changed. Jobs are only queued for the workers, those unable
+// to keep up are dropped instead of holding up the others.
+func (s *Server) refresh() {
+	work, err := s.backend.GetWork()
+	if err != nil {
+		log.Debug("Failed to fetch stratum work", "err", err)
+		return
+	}
+	j := newJob(work)
+
+	s.mu.Lock()
+	if _, ok := s.jobs[j.hash]; ok {
+		s.mu.Unlock()
+		return
+	}
+	s.jobs[j.hash] = j
+	s.recent = append(s.recent, j)
+	if len(s.recent) > maxJobs {
+		delete(s.jobs, s.recent[0].hash)
+		s.recent = s.recent[1:]
+	}
+	var notify []*session
+	for sess := range s.sessions {
+		if sess.login != "" {
+			notify = append(notify, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	log.Debug("New stratum job", "hash", j.hash, "workers", len(notify))
+	for _, sess := range notify {
+		sess.notify(j)
+	}
+}
+
+// current returns the most recent job, or nil if no work is available yet.
+func (s *Server) current() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.recent) == 0 {
+		return nil
+	}
+	return s.recent[len(s.recent)-1]
+}
+
+// submit verifies a share of the worker, handing it on to the backend if it
+// also seals the block.
+func (s *Server) submit(sess *session, nonce types.BlockNonce, hash, mixDigest common.Hash) error {
+	s.mu.Lock()
+	j := s.jobs[hash]
+	if j == nil {
+		s.mu.Unlock()
+		return errStaleShare
+	}
+	if _, ok := j.nonces[nonce.Uint64()]; ok {
+		s.mu.Unlock()
+		return errDuplicateShare
+	}
+	j.nonces[nonce.Uint64()] = struct{}{}
+	target := j.shareTarget(sess.difficulty)
+	s.mu.Unlock()
+
+	ev := ShareEvent{
+		Login:         sess.login,
+		Difficulty:    targetDifficulty(target),
+		NetDifficulty: targetDifficulty(j.target),
+		Hash:          hash,
+		Time:          time.Now(),
+	}
+	if j.version == 0 {
+		// The work can't be verified here, leave it to the backend
+		if !s.backend.SubmitWork(nonce, mixDigest, hash) {
+			return errLowDifficulty
+		}
+		log.Info("Stratum worker sealed block", "worker", sess.login, "hash", hash)
+		ev.Block = true
+		s.shareFeed.Send(ev)
+		return nil
+	}
+	seed := make([]byte, 40)
+	copy(seed, hash.Bytes())
+	binary.LittleEndian.PutUint64(seed[32:], nonce.Uint64())
+	result := new(big.Int).SetBytes(crypto.VersionHash(j.version, seed))
+
+	if result.Cmp(target) > 0 {
+		return errLowDifficulty
+	}
+	if result.Cmp(j.target) <= 0 {
+		if s.backend.SubmitWork(nonce, common.Hash{}, hash) {
+			log.Info("Stratum worker sealed block", "worker", sess.login, "hash", hash)
+			ev.Block = true
+		} else {
+			log.Warn("Block sealed by stratum worker rejected", "worker", sess.login, "hash", hash)
+		}
+	}
+	s.shareFeed.Send(ev)
+	return nil
+}
+
+// request is a stratum request sent by a worker.
+type request struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []string        `json:"params"`
+	Worker string          `json:"worker"`
+}
+
+// response is a stratum response, or a job notification if Id is 0.
+type response struct {
+	Id      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// session is the connection of a single worker.
+type session struct {
+	server *Server
+	conn   net.Conn
+
+	out  chan []byte   // Queue of messages to write to the worker
+	done chan struct{} // Closed when the worker disconnects
+
+	// Fields protected by the server lock
+	login      string
+	difficulty uint64
+
+	// Fields only accessed by the serving goroutine
+	accepted, rejected uint64
+	hashrate           uint64
+}
+
+func newSession(server *Server, conn net.Conn) *session {
+	return &session{
+		server:     server,
+		conn:       conn,
+		difficulty: server.config.Difficulty,
+		out:        make(chan []byte, sendQueue),
+		done:       make(chan struct{}),
+	}
+}
+
+// serve handles the requests of the worker until it disconnects.
+func (sess *session) serve() {
+	defer close(sess.done)
+	defer sess.conn.Close()
+
+	scanner := bufio.NewScanner(sess.conn)
+	scanner.Buffer(make([]byte, 0, 512), maxRequestSize)
+	for {
+		sess.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Debug("Malformed stratum request", "addr", sess.conn.RemoteAddr(), "err", err)
+			break
+		}
+		if err := sess.handle(&req); err != nil {
+			break
+		}
+	}
+	if sess.login != "" {
+		log.Info("Stratum worker disconnected", "worker", sess.login, "addr", sess.conn.RemoteAddr(),
+			"accepted", sess.accepted, "rejected", sess.rejected, "hashrate", sess.hashrate)
+	}
+}
+
+// handle answers a single request of the worker.
+func (sess *session) handle(req *request) error {
+	switch req.Method {
+	case "eth_submitLogin":
+		if len(req.Params) == 0 || req.Params[0] == "" {
+			return sess.reply(req, nil, errors.New("missing login"))
+		}
+		login := req.Params[0]
+		if req.Worker != "" {
+			login += "." + req.Worker
+		}
+		difficulty := sess.server.config.Difficulty
+		if len(req.Params) > 1 {
+			difficulty = sess.server.parseDifficulty(req.Params[1])
+		}
+		sess.server.mu.Lock()
+		sess.login, sess.difficulty = login, difficulty
+		sess.server.mu.Unlock()
+
+		log.Info("Stratum worker logged in", "worker", login, "addr", sess.conn.RemoteAddr(), "difficulty", difficulty)
+		if err := sess.reply(req, true, nil); err != nil {
+			return err
+		}
+		if j := sess.server.current(); j != nil {
+			return sess.notify(j)
+		}
+		return nil
+
+	case "eth_getWork":
+		if sess.login == "" {
+			return sess.reply(req, nil, errNotLoggedIn)
+		}
+		j := sess.server.current()
+		if j == nil {
+			return sess.reply(req, nil, errNoWork)
+		}
+		return sess.reply(req, sess.work(j), nil)
+
+	case "eth_submitWork":
+		if sess.login == "" {
+			return sess.reply(req, nil, errNotLoggedIn)
+		}
+		if len(req.Params) < 3 {
+			return sess.reply(req, false, errors.New("invalid share"))
+		}
+		raw, err := hexutil.Decode(req.Params[0])
+		if err != nil || len(raw) != len(types.BlockNonce{}) {
+			return sess.reply(req, false, errors.New("invalid nonce"))
+		}
+		var nonce types.BlockNonce
+		copy(nonce[:], raw)
+
+		if err := sess.server.submit(sess, nonce, common.HexToHash(req.Params[1]), common.HexToHash(req.Params[2])); err != nil {
+			sess.rejected++
+			log.Debug("Rejected stratum share", "worker", sess.login, "err", err)
+			return sess.reply(req, false, err)
+		}
+		sess.accepted++
+		return sess.reply(req, true, nil)
+
+	case "eth_submitHashrate":
+		if len(req.Params) > 0 {
+			if rate, err := hexutil.DecodeUint64(req.Params[0]); err == nil {
+				sess.hashrate = rate
+			}
+		}
+		return sess.reply(req, true, nil)
+
+	default:
+		return sess.reply(req, nil, errors.New("unsupported method "+req.Method))
+	}
+}
+
+// parseDifficulty extracts the share difficulty requested in a login password
+// of the form "d=<difficulty>", falling back to the configured default.
+func (s *Server) parseDifficulty(password string) uint64 {
+	for _, field := range strings.Split(password, ",") {
+		if !strings.HasPrefix(field, "d=") {
+			continue
+		}
+		difficulty, err := strconv.ParseUint(field[2:], 10, 64)
+		if err != nil {
+			break
+		}
+		if difficulty < s.config.MinDifficulty {
+			difficulty = s.config.MinDifficulty
+		}
+		return difficulty
+	}
+	return s.config.Difficulty
+}
+
+// work returns the job as seen by the worker, with its own share target.
+func (sess *session) work(j *job) [3]string {
+	sess.server.mu.Lock()
+	difficulty := sess.difficulty
+	sess.server.mu.Unlock()
+
+	return [3]string{j.hash.Hex(), j.seed.Hex(), common.BytesToHash(j.shareTarget(difficulty).Bytes()).Hex()}
+}
+
+// notify pushes a new job to the worker.
+func (sess *session) notify(j *job) error {
+	return sess.send(&response{Id: json.RawMessage("0"), Version: "2.0", Result: sess.work(j)})
+}
+
+// reply answers a request of the worker.
+func (sess *session) reply(req *request, result interface{}, err error) error {
+	res := &response{Id: req.Id, Version: "2.0", Result: result}
+	if len(res.Id) == 0 {
+		res.Id = json.RawMessage("null")
+	}
+	if err != nil {
+		res.Error = &responseError{Code: -1, Message: err.Error()}
+	}
+	return sess.send(res)
+}
+
+// send queues a message for the worker, dropping the worker if its queue is
+// already full.
+func (sess *session) send(res *response) error {
+	blob, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	select {
+	case sess.out <- append(blob, '\n'):
+		return nil
+	default:
+		log.Debug("Dropping slow stratum worker", "addr", sess.conn.RemoteAddr())
+		sess.conn.Close()
+		return errSlowWorker
+	}
+}
+
+// write writes the queued messages to the worker until it disconnects.
+func (sess *session) write() {
+	for {
+		select {
+		case blob := <-sess.out:
+			sess.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := sess.conn.Write(blob); err != nil {
+				sess.conn.Close()
+				return
+			}
+		case <-sess.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core/types"
+)
+
+// testBackend is a work source handing out a fixed work package.
+type testBackend struct {
+	mu        sync.Mutex
+	work      [3]string
+	submitted []types.BlockNonce
+}
+
+func (b *testBackend) setWork(hash common.Hash, difficulty *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	target := new(big.Int).Div(maxUint256, difficulty)
+	b.work = [3]string{hash.Hex(), common.BytesToHash([]byte{2}).Hex(), common.BytesToHash(target.Bytes()).Hex()}
+}
+
+func (b *testBackend) GetWork() ([3]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.work, nil
+}
+
+func (b *testBackend) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.Hash) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.submitted = append(b.submitted, nonce)
+	return true
+}
+
+// testWorker is a stratum client connected to the server.
+type testWorker struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+	id      int
+}
+
+func (w *testWorker) call(method string, params ...string) (json.RawMessage, *responseError) {
+	w.id++
+	blob, _ := json.Marshal(map[string]interface{}{"id": w.id, "jsonrpc": "2.0", "method": method, "params": params})
+	if _, err := w.conn.Write(append(blob, '\n')); err != nil {
+		w.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	res := w.read()
+	if string(res.Id) != fmt.Sprint(w.id) {
+		w.t.Fatalf("%s: response id mismatch: have %s, want %d", method, res.Id, w.id)
+	}
+	return res.Result, res.Error
+}
+
+func (w *testWorker) read() *struct {
+	Id     json.RawMessage
+	Result json.RawMessage
+	Error  *responseError
+} {
+	w.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if !w.scanner.Scan() {
+		w.t.Fatalf("failed to read from server: %v", w.scanner.Err())
+	}
+	res := new(struct {
+		Id     json.RawMessage
+		Result json.RawMessage
+		Error  *responseError
+	})
+	if err := json.Unmarshal(w.scanner.Bytes(), res); err != nil {
+		w.t.Fatalf("malformed message %s: %v", w.scanner.Bytes(), err)
+	}
+	return res
+}
+
+// job reads a pushed job notification.
+func (w *testWorker) job() [3]string {
+	res := w.read()
+	if string(res.Id) != "0" {
+		w.t.Fatalf("expected job notification, got id %s", res.Id)
+	}
+	var work [3]string
+	if err := json.Unmarshal(res.Result, &work); err != nil {
+		w.t.Fatalf("malformed job %s: %v", res.Result, err)
+	}
+	return work
+}
+
+func TestServerShares(t *testing.T) {
+	backend := new(testBackend)
+	backend.setWork(common.HexToHash("0x01"), new(big.Int).Lsh(common.Big1, 200))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := NewServer(backend, Config{Difficulty: 1 << 40, MinDifficulty: 1, Recommit: 10 * time.Millisecond})
+	server.Start(listener)
+	defer server.Stop()
+
+	shares := make(chan ShareEvent, 8)
+	server.SubscribeShares(shares)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	w := &testWorker{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+
+	// Work is only handed out to logged in workers
+	if _, err := w.call("eth_getWork"); err == nil {
+		t.Fatalf("got work before logging in")
+	}
+	if res, err := w.call("eth_submitLogin", "0x0000000000000000000000000000000000000001", "d=1"); err != nil || string(res) != "true" {
+		t.Fatalf("login failed: %s %v", res, err)
+	}
+	work := w.job()
+	if want := common.BytesToHash(maxUint256.Bytes()).Hex(); work[2] != want {
+		t.Fatalf("share target mismatch: have %s, want %s", work[2], want)
+	}
+	// Any nonce is a share at difficulty 1, but none should seal the block
+	if res, err := w.call("eth_submitWork", "0x0000000000000001", work[0], common.Hash{}.Hex()); err != nil || string(res) != "true" {
+		t.Fatalf("valid share rejected: %s %v", res, err)
+	}
+	if _, err := w.call("eth_submitWork", "0x0000000000000001", work[0], common.Hash{}.Hex()); err == nil || err.Message != errDuplicateShare.Error() {
+		t.Fatalf("duplicate share error mismatch: have %v, want %v", err, errDuplicateShare)
+	}
+	if _, err := w.call("eth_submitWork", "0x0000000000000002", common.HexToHash("0xff").Hex(), common.Hash{}.Hex()); err == nil || err.Message != errStaleShare.Error() {
+		t.Fatalf("stale share error mismatch: have %v, want %v", err, errStaleShare)
+	}
+	if len(backend.submitted) != 0 {
+		t.Fatalf("shares below the network difficulty submitted: %v", backend.submitted)
+	}
+	// New work gets pushed, and block quality shares handed to the backend
+	backend.setWork(common.HexToHash("0x02"), common.Big1)
+	if work = w.job(); work[0] != common.HexToHash("0x02").Hex() {
+		t.Fatalf("pushed job mismatch: have %s, want %s", work[0], common.HexToHash("0x02").Hex())
+	}
+	if res, err := w.call("eth_submitWork", "0x0000000000000003", work[0], common.Hash{}.Hex()); err != nil || string(res) != "true" {
+		t.Fatalf("block rejected: %s %v", res, err)
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.submitted) != 1 || backend.submitted[0] != types.EncodeNonce(3) {
+		t.Fatalf("sealed blocks mismatch: have %v, want [3]", backend.submitted)
+	}
+	// Accepted shares are posted, at the difficulty they were mined at
+	if len(shares) != 2 {
+		t.Fatalf("share event count mismatch: have %d, want 2", len(shares))
+	}
+	if ev := <-shares; ev.Block || ev.Difficulty != 1 || ev.NetDifficulty != math.MaxUint64 || ev.Login != "0x0000000000000000000000000000000000000001" {
+		t.Errorf("share event mismatch: %+v", ev)
+	}
+	if ev := <-shares; !ev.Block || ev.Hash != common.HexToHash("0x02") {
+		t.Errorf("block event mismatch: %+v", ev)
+	}
+}
+
+func TestShareDifficulty(t *testing.T) {
+	server := NewServer(new(testBackend), Config{Difficulty: 5000, MinDifficulty: 1000})
+
+	tests := []struct {
+		password string
+		want     uint64
+	}{
+		{"", 5000},
+		{"x", 5000},
+		{"d=20000", 20000},
+		{"x,d=20000", 20000},
+		{"d=10", 1000},
+		{"d=nope", 5000},
+	}
+	for i, tt := range tests {
+		if have := server.parseDifficulty(tt.password); have != tt.want {
+			t.Errorf("test %d: difficulty mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+	// Shares are never harder than the block, and unverifiable work uses the network target
+	netTarget := new(big.Int).Div(maxUint256, big.NewInt(100))
+	j := &job{version: 2, target: netTarget}
+	if have, want := j.shareTarget(10), new(big.Int).Div(maxUint256, big.NewInt(10)); have.Cmp(want) != 0 {
+		t.Errorf("share target mismatch: have %x, want %x", have, want)
+	}
+	if have := j.shareTarget(1000); have.Cmp(netTarget) != 0 {
+		t.Errorf("share target above the network difficulty: have %x, want %x", have, netTarget)
+	}
+	j.version = 0
+	if have := j.shareTarget(10); have.Cmp(netTarget) != 0 {
+		t.Errorf("unverifiable share target mismatch: have %x, want %x", have, netTarget)
+	}
+}
+
+func TestSlowWorkerDropped(t *testing.T) {
+	server := NewServer(new(testBackend), Config{})
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	// Nothing drains the queue, so the worker is dropped once it fills up
+	sess := newSession(server, conn)
+	j := newJob([3]string{common.HexToHash("0x01").Hex(), common.Hash{}.Hex(), common.Hash{}.Hex()})
+	for i := 0; i < sendQueue; i++ {
+		if err := sess.notify(j); err != nil {
+			t.Fatalf("notification %d failed: %v", i, err)
+		}
+	}
+	if err := sess.notify(j); err != errSlowWorker {
+		t.Fatalf("overflow error mismatch: have %v, want %v", err, errSlowWorker)
+	}
+	if _, err := conn.Write([]byte{0}); err == nil {
+		t.Fatalf("slow worker not disconnected")
+	}
+}
+
+func TestServerConnLimits(t *testing.T) {
+	backend := new(testBackend)
+	backend.setWork(common.HexToHash("0x01"), common.Big1)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := NewServer(backend, Config{Difficulty: 1, MaxConns: 3, MaxConnsPerIP: 2})
+	server.Start(listener)
+	defer server.Stop()
+
+	dial := func() *testWorker {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		return &testWorker{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+	}
+	// Workers within the limit are served, the ones beyond get disconnected
+	for i := 0; i < 2; i++ {
+		w := dial()
+		defer w.conn.Close()
+		if _, err := w.call("eth_submitHashrate", "0x1"); err != nil {
+			t.Fatalf("worker %d rejected: %v", i, err)
+		}
+	}
+	w := dial()
+	defer w.conn.Close()
+	w.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := w.conn.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("worker beyond the per-IP limit not disconnected")
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.sessions) != 2 || server.peers["127.0.0.1"] != 2 {
+		t.Fatalf("connection count mismatch: have %d sessions, %v", len(server.sessions), server.peers)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package stratum
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/opt/miner"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// Service runs a stratum server distributing the work of the miner of the
+// AquaChain service running in the same node.
+type Service struct {
+	config    Config
+	aquachain *aqua.AquaChain
+	agent     *miner.RemoteAgent
+	server    *Server
+}
+
+// New creates a stratum server for the AquaChain service running in the same
+// node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("serving stratum work requires a full node: %v", err)
+	}
+	if config.Addr == "" {
+		return nil, errors.New("no stratum listening address")
+	}
+	return &Service{
+		config:    config,
+		aquachain: aquachain,
+		agent:     aquachain.NewRemoteAgent(),
+	}, nil
+}
+
+// Dependencies implements node.DependentService, making sure the server is
+// stopped before the miner it serves the work of.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to accept workers.
+func (s *Service) Start(*p2p.Server) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.aquachain.Miner().Register(s.agent)
+	s.server = NewServer(s, s.config)
+	s.server.Start(listener)
+
+	log.Info("Stratum server started", "addr", listener.Addr(), "difficulty", s.config.Difficulty)
+	return nil
+}
+
+// Stop implements node.Service, disconnecting all workers.
+func (s *Service) Stop() error {
+	s.server.Stop()
+	s.aquachain.Miner().Unregister(s.agent)
+	log.Info("Stratum server stopped")
+	return nil
+}
+
+// GetWork implements Backend, starting the miner on first use just like
+// aqua_getWork does.
+func (s *Service) GetWork() ([3]string, error) {
+	if !s.aquachain.IsMining() {
+		if err := s.aquachain.StartMining(false); err != nil {
+			return [3]string{}, err
+		}
+	}
+	return s.agent.GetWork()
+}
+
+// SubmitWork implements Backend, handing a sealed block to the miner.
+func (s *Service) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.Hash) bool {
+	return s.agent.SubmitWork(nonce, mixDigest, hash)
+}
@@ -19,11 +19,12 @@ package mailserver
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
-	"gitlab.com/aquachain/aquachain/cmd/utils"
 	"gitlab.com/aquachain/aquachain/common"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/crypto"
@@ -36,6 +37,9 @@ type WMailServer struct {
 	w   *whisper.Whisper
 	pow float64
 	key []byte
+
+	retention time.Duration                       // Time envelopes are kept for (zero = forever)
+	topics    map[whisper.TopicType]time.Duration // Retention overrides per topic
 }
 
 type DBKey struct {
@@ -56,18 +60,26 @@ func NewDbKey(t uint32, h common.Hash) *DBKey {
 }
 
 func (s *WMailServer) Init(shh *whisper.Whisper, path string, password string, pow float64) {
+	if err := s.open(shh, path, password, pow); err != nil {
+		log.Crit(err.Error())
+	}
+}
+
+// open opens the envelope archive and derives the symmetric key history
+// requests have to be encrypted with.
+func (s *WMailServer) open(shh *whisper.Whisper, path string, password string, pow float64) error {
 	var err error
 	if len(path) == 0 {
-		utils.Fatalf("DB file is not specified")
+		return errors.New("DB file is not specified")
 	}
 
 	if len(password) == 0 {
-		utils.Fatalf("Password is not specified for MailServer")
+		return errors.New("Password is not specified for MailServer")
 	}
 
 	s.db, err = leveldb.OpenFile(path, nil)
 	if err != nil {
-		utils.Fatalf("Failed to open DB file: %s", err)
+		return fmt.Errorf("Failed to open DB file: %s", err)
 	}
 
 	s.w = shh
@@ -75,12 +87,69 @@ func (s *WMailServer) Init(shh *whisper.Whisper, path string, password string, p
 
 	MailServerKeyID, err := s.w.AddSymKeyFromPassword(password)
 	if err != nil {
-		utils.Fatalf("Failed to create symmetric key for MailServer: %s", err)
+		s.db.Close()
+		return fmt.Errorf("Failed to create symmetric key for MailServer: %s", err)
 	}
 	s.key, err = s.w.GetSymKey(MailServerKeyID)
 	if err != nil {
-		utils.Fatalf("Failed to save symmetric key for MailServer")
+		s.db.Close()
+		return errors.New("Failed to save symmetric key for MailServer")
+	}
+	return nil
+}
+
+// SetRetention sets the time archived envelopes are kept for, by default and
+// for individual topics. A zero duration keeps envelopes forever.
+func (s *WMailServer) SetRetention(retention time.Duration, topics map[whisper.TopicType]time.Duration) {
+	s.retention, s.topics = retention, topics
+}
+
+// Prune deletes the archived envelopes older than the retention of their
+// topic, returning the number of envelopes deleted.
+func (s *WMailServer) Prune(now time.Time) (int, error) {
+	// Only envelopes older than the shortest retention may be deleted
+	oldest := s.retention
+	for _, retention := range s.topics {
+		if retention > 0 && (oldest == 0 || retention < oldest) {
+			oldest = retention
+		}
+	}
+	if oldest == 0 {
+		return 0, nil
+	}
+	var zero common.Hash
+	limit := NewDbKey(uint32(now.Add(-oldest).Unix()), zero)
+	it := s.db.NewIterator(&util.Range{Limit: limit.raw}, nil)
+	defer it.Release()
+
+	var (
+		batch   = new(leveldb.Batch)
+		deleted int
+	)
+	for it.Next() {
+		var envelope whisper.Envelope
+		if err := rlp.DecodeBytes(it.Value(), &envelope); err != nil {
+			log.Error(fmt.Sprintf("RLP decoding failed: %s", err))
+			continue
+		}
+		retention, ok := s.topics[envelope.Topic]
+		if !ok {
+			retention = s.retention
+		}
+		sent := binary.BigEndian.Uint32(it.Key()[:4])
+		if retention == 0 || int64(sent) >= now.Add(-retention).Unix() {
+			continue
+		}
+		batch.Delete(common.CopyBytes(it.Key()))
+		deleted++
+	}
+	if err := it.Error(); err != nil {
+		return 0, err
+	}
+	if err := s.db.Write(batch, nil); err != nil {
+		return 0, err
 	}
+	return deleted, nil
 }
 
 func (s *WMailServer) Close() {
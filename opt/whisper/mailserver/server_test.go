@@ -21,6 +21,8 @@ import (
 	"encoding/binary"
 	"io/ioutil"
 	"math/rand"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -58,10 +60,14 @@ func TestDBKey(t *testing.T) {
 }
 
 func generateEnvelope(t *testing.T) *whisper.Envelope {
+	return generateTopicEnvelope(t, whisper.TopicType{})
+}
+
+func generateTopicEnvelope(t *testing.T, topic whisper.TopicType) *whisper.Envelope {
 	h := crypto.Keccak256Hash([]byte("test sample data"))
 	params := &whisper.MessageParams{
 		KeySym:   h[:],
-		Topic:    whisper.TopicType{},
+		Topic:    topic,
 		Payload:  []byte("test payload"),
 		PoW:      powRequirement,
 		WorkTime: 2,
@@ -202,3 +208,64 @@ func createRequest(t *testing.T, p *ServerTestParams) *whisper.Envelope {
 	}
 	return env
 }
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-server-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var server WMailServer
+	shh = whisper.New(&whisper.DefaultConfig)
+	if err := server.open(shh, dir, "password_for_this_test", powRequirement); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// Archive one envelope of the default and one of a short lived topic
+	short := whisper.TopicType{0x01, 0x02, 0x03, 0x04}
+	env := generateEnvelope(t)
+	server.Archive(env)
+	server.Archive(generateTopicEnvelope(t, short))
+
+	server.SetRetention(48*time.Hour, map[whisper.TopicType]time.Duration{short: time.Hour})
+	sent := time.Unix(int64(env.Expiry-env.TTL), 0)
+
+	for i, test := range []struct {
+		now     time.Time
+		deleted int
+	}{
+		{sent.Add(30 * time.Minute), 0},
+		{sent.Add(2 * time.Hour), 1},
+		{sent.Add(24 * time.Hour), 0},
+		{sent.Add(72 * time.Hour), 1},
+	} {
+		deleted, err := server.Prune(test.now)
+		if err != nil {
+			t.Fatalf("test %d: prune failed: %v", i, err)
+		}
+		if deleted != test.deleted {
+			t.Errorf("test %d: deleted %d envelopes, want %d", i, deleted, test.deleted)
+		}
+	}
+}
+
+func TestParseTopicRetention(t *testing.T) {
+	topics, err := ParseTopicRetention("0x01020304=24h, 0xaabbccdd=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[whisper.TopicType]time.Duration{
+		{0x01, 0x02, 0x03, 0x04}: 24 * time.Hour,
+		{0xaa, 0xbb, 0xcc, 0xdd}: 0,
+	}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics mismatch: got %v, want %v", topics, want)
+	}
+	for _, invalid := range []string{"0x01020304", "0x0102=1h", "0x01020304=soon", "0x01020304=-1h"} {
+		if _, err := ParseTopicRetention(invalid); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package mailserver
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/node"
+	whisper "gitlab.com/aquachain/aquachain/opt/whisper/whisperv6"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+)
+
+// pruneInterval is the time between two deletions of expired envelopes.
+const pruneInterval = time.Hour
+
+// Config contains the settings of the mail server service.
+type Config struct {
+	Dir       string                              // Directory to archive envelopes in (empty = disabled)
+	Password  string                              // Password deriving the symmetric key of history requests
+	MinPoW    float64                             // Minimum PoW of history requests (zero = any)
+	Retention time.Duration                       // Time envelopes are kept for (zero = forever)
+	Topics    map[whisper.TopicType]time.Duration `toml:",omitempty"` // Retention overrides per topic
+}
+
+// DefaultConfig contains the default mail server settings.
+var DefaultConfig = Config{
+	Retention: 30 * 24 * time.Hour,
+}
+
+// ParseTopicRetention parses a comma-separated list of topic=duration pairs,
+// e.g. 0x01020304=24h,0xaabbccdd=0.
+func ParseTopicRetention(s string) (map[whisper.TopicType]time.Duration, error) {
+	topics := make(map[whisper.TopicType]time.Duration)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid topic retention %q, want topic=duration", field)
+		}
+		raw, err := hexutil.Decode(parts[0])
+		if err != nil || len(raw) != whisper.TopicLength {
+			return nil, fmt.Errorf("invalid topic %q", parts[0])
+		}
+		retention, err := time.ParseDuration(parts[1])
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("invalid retention %q of topic %s", parts[1], parts[0])
+		}
+		topics[whisper.BytesToTopic(raw)] = retention
+	}
+	return topics, nil
+}
+
+// Service is a node service archiving the envelopes relayed by the whisper
+// service of the same node, and delivering them to peers requesting history
+// with the configured symmetric key.
+type Service struct {
+	config Config
+	shh    *whisper.Whisper
+	server WMailServer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a mail server for the whisper service running in the same node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	if config.Dir == "" {
+		return nil, errors.New("no mail server directory configured")
+	}
+	var shh *whisper.Whisper
+	if err := ctx.Service(&shh); err != nil {
+		return nil, fmt.Errorf("mail server requires whisper: %v", err)
+	}
+	s := &Service{
+		config: config,
+		shh:    shh,
+		quit:   make(chan struct{}),
+	}
+	if err := s.server.open(shh, config.Dir, config.Password, config.MinPoW); err != nil {
+		return nil, err
+	}
+	s.server.SetRetention(config.Retention, config.Topics)
+
+	// Register before whisper starts relaying, it doesn't synchronize access
+	shh.RegisterServer(&s.server)
+	return s, nil
+}
+
+// Dependencies implements node.DependentService, making sure whisper is
+// running before envelopes are archived.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*whisper.Whisper)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the pruning of expired envelopes.
+func (s *Service) Start(*p2p.Server) error {
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Info("Whisper mail server enabled", "dir", s.config.Dir, "retention", s.config.Retention, "topics", len(s.config.Topics))
+	return nil
+}
+
+// Stop implements node.Service, terminating the pruning and closing the
+// envelope archive.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+	s.server.Close()
+	return nil
+}
+
+// loop deletes the expired envelopes on startup and every prune interval.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := s.server.Prune(time.Now()); err != nil {
+			log.Error("Failed to prune whisper mail archive", "err", err)
+		} else if deleted > 0 {
+			log.Info("Pruned whisper mail archive", "deleted", deleted)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -342,6 +342,25 @@ seek:
 		}
 		nodes = append(nodes, n)
 	}
+	// Random seeks favour nodes following large gaps in the key space, so fill
+	// up with a linear pass in case some were missed.
+	if len(nodes) < n {
+		seen := make(map[NodeID]bool, len(nodes))
+		for _, node := range nodes {
+			seen[node.ID] = true
+		}
+		for valid := it.First(); valid && len(nodes) < n; valid = it.Next() {
+			node := nextNode(it)
+			if node == nil {
+				break
+			}
+			if seen[node.ID] || node.ID == db.self || now.Sub(db.bondTime(node.ID)) > maxAge {
+				continue
+			}
+			seen[node.ID] = true
+			nodes = append(nodes, node)
+		}
+	}
 	return nodes
 }
 
@@ -358,6 +377,7 @@ func nextNode(it iterator.Iterator) *Node {
 			log.Warn("Failed to decode node RLP", "id", id, "err", err)
 			continue
 		}
+		n.sha = crypto.Keccak256Hash(n.ID[:])
 		return &n
 	}
 	return nil
@@ -0,0 +1,147 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the filtering of incoming packets by source address.
+
+package discover
+
+import (
+	"net"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+	"gitlab.com/aquachain/aquachain/p2p/netutil"
+)
+
+var (
+	ingressDeniedMeter  = metrics.NewRegisteredMeter("discover/ingress/denied", nil)
+	ingressLimitedMeter = metrics.NewRegisteredMeter("discover/ingress/limited", nil)
+)
+
+const (
+	// maxLimitedIPs caps the number of source addresses tracked by the rate
+	// limiter. Sources beyond it share a single bucket, so packets from many
+	// (possibly forged) addresses can't exhaust the memory.
+	maxLimitedIPs = 1 << 16
+
+	// limiterSweepInterval is how often buckets of idle sources are dropped.
+	limiterSweepInterval = time.Minute
+)
+
+// packetFilter decides whether an incoming packet is processed, based on the
+// allowed and denied networks and a per source IP rate limit. It is only used
+// by the read loop and is not safe for concurrent use.
+type packetFilter struct {
+	allow   *netutil.Netlist // if set, only packets from these networks are accepted
+	deny    *netutil.Netlist // packets from these networks are dropped
+	limiter *ipLimiter       // nil if packets are not rate limited
+}
+
+func newPacketFilter(cfg Config) *packetFilter {
+	f := &packetFilter{allow: cfg.NetRestrict, deny: cfg.NetDeny}
+	if cfg.RateLimit > 0 {
+		f.limiter = newIPLimiter(float64(cfg.RateLimit))
+	}
+	return f
+}
+
+// accept reports whether a packet from the given address should be handled.
+func (f *packetFilter) accept(from *net.UDPAddr, now time.Time) bool {
+	if (f.allow != nil && !f.allow.Contains(from.IP)) || (f.deny != nil && f.deny.Contains(from.IP)) {
+		ingressDeniedMeter.Mark(1)
+		return false
+	}
+	if f.limiter != nil && !f.limiter.allow(from.IP, now) {
+		ingressLimitedMeter.Mark(1)
+		return false
+	}
+	return true
+}
+
+// ipLimiter is a token bucket rate limiter keyed by source IP. Each source may
+// send up to rate packets per second on average, with bursts of up to one
+// second worth of packets.
+type ipLimiter struct {
+	rate      float64 // tokens added per second
+	burst     float64 // maximum number of tokens in a bucket
+	buckets   map[string]*ipBucket
+	overflow  ipBucket // shared by sources not fitting in buckets
+	lastSweep time.Time
+}
+
+type ipBucket struct {
+	tokens float64
+	last   time.Time // time tokens were last refilled
+}
+
+func newIPLimiter(rate float64) *ipLimiter {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &ipLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*ipBucket),
+	}
+}
+
+// allow takes a token from the bucket of the given source, reporting whether
+// one was available.
+func (l *ipLimiter) allow(ip net.IP, now time.Time) bool {
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		l.sweep(now)
+	}
+	key := string(ip.To16())
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxLimitedIPs {
+			return l.take(&l.overflow, now)
+		}
+		b = &ipBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	return l.take(b, now)
+}
+
+// take refills a bucket for the time passed since the last call and takes a
+// token from it.
+func (l *ipLimiter) take(b *ipBucket, now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops the buckets which would be full by now, they are no different
+// from the bucket of a new source.
+func (l *ipLimiter) sweep(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/p2p/netutil"
+)
+
+func TestPacketFilter_networks(t *testing.T) {
+	allow, _ := netutil.ParseNetlist("10.0.0.0/8")
+	deny, _ := netutil.ParseNetlist("10.1.0.0/16")
+	f := newPacketFilter(Config{NetRestrict: allow, NetDeny: deny})
+
+	now := time.Now()
+	tests := []struct {
+		ip     string
+		accept bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"192.168.0.1", false},
+	}
+	for _, test := range tests {
+		from := &net.UDPAddr{IP: net.ParseIP(test.ip), Port: 30303}
+		if got := f.accept(from, now); got != test.accept {
+			t.Errorf("%s: accept = %v, want %v", test.ip, got, test.accept)
+		}
+	}
+}
+
+func TestPacketFilter_rateLimit(t *testing.T) {
+	f := newPacketFilter(Config{RateLimit: 5})
+	var (
+		start = time.Now()
+		a     = &net.UDPAddr{IP: net.ParseIP("1.2.3.4")}
+		b     = &net.UDPAddr{IP: net.ParseIP("5.6.7.8")}
+	)
+	for i := 0; i < 5; i++ {
+		if !f.accept(a, start) {
+			t.Fatalf("packet %d within burst rejected", i)
+		}
+	}
+	if f.accept(a, start) {
+		t.Fatal("packet exceeding burst accepted")
+	}
+	if !f.accept(b, start) {
+		t.Fatal("packet from other source rejected")
+	}
+	// One token is refilled every 200ms
+	if !f.accept(a, start.Add(200*time.Millisecond)) {
+		t.Fatal("packet rejected after refill")
+	}
+	if f.accept(a, start.Add(200*time.Millisecond)) {
+		t.Fatal("second packet accepted after single token refill")
+	}
+	// Idle sources are dropped by the sweep
+	f.accept(b, start.Add(limiterSweepInterval))
+	if n := len(f.limiter.buckets); n != 1 {
+		t.Fatalf("limiter tracks %d sources after sweep, want 1", n)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the meters and statistics of the discovery protocol.
+
+package discover
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+var (
+	ingressTrafficMeter = metrics.NewRegisteredMeter("discover/ingress/traffic", nil)
+	egressTrafficMeter  = metrics.NewRegisteredMeter("discover/egress/traffic", nil)
+	ingressBadMeter     = metrics.NewRegisteredMeter("discover/ingress/bad", nil)
+
+	// Packet meters by packet name
+	ingressPacketMeters = packetMeters("discover/ingress/")
+	egressPacketMeters  = packetMeters("discover/egress/")
+)
+
+func packetMeters(prefix string) map[string]metrics.Meter {
+	meters := make(map[string]metrics.Meter)
+	for _, p := range []packet{new(ping), new(pong), new(findnode), new(neighbors)} {
+		meters[p.name()] = metrics.NewRegisteredMeter(prefix+packetMetricName(p), nil)
+	}
+	return meters
+}
+
+// packetMetricName returns the metric name of a packet, e.g. ping for PING/v4.
+func packetMetricName(p packet) string {
+	switch p.(type) {
+	case *ping:
+		return "ping"
+	case *pong:
+		return "pong"
+	case *findnode:
+		return "findnode"
+	default:
+		return "neighbors"
+	}
+}
+
+// maxSeenNodes caps the number of distinct nodes tracked per hour, so packets
+// with forged identities can't exhaust the memory.
+const maxSeenNodes = 1 << 20
+
+// seenNodes counts the distinct nodes sending valid packets per hour.
+type seenNodes struct {
+	lock     sync.Mutex
+	hour     time.Time // Start of the current hour
+	current  map[NodeID]struct{}
+	lastHour int // Distinct nodes seen in the previous hour
+}
+
+func newSeenNodes() *seenNodes {
+	return &seenNodes{
+		hour:    time.Now().Truncate(time.Hour),
+		current: make(map[NodeID]struct{}),
+	}
+}
+
+// add records a node as seen now.
+func (s *seenNodes) add(id NodeID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rotate(time.Now())
+	if len(s.current) < maxSeenNodes {
+		s.current[id] = struct{}{}
+	}
+}
+
+// counts returns the number of distinct nodes seen in the current and the
+// previous hour.
+func (s *seenNodes) counts() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rotate(time.Now())
+	return len(s.current), s.lastHour
+}
+
+// rotate starts counting a new hour if the current one is over. The caller
+// must hold the lock.
+func (s *seenNodes) rotate(now time.Time) {
+	hour := now.Truncate(time.Hour)
+	if !hour.After(s.hour) {
+		return
+	}
+	s.lastHour = len(s.current)
+	if hour.Sub(s.hour) > time.Hour {
+		s.lastHour = 0 // nothing was seen in the previous hour
+	}
+	s.hour, s.current = hour, make(map[NodeID]struct{})
+}
+
+// TableStats describes the occupancy of the node table.
+type TableStats struct {
+	Nodes        int   `json:"nodes"`        // Live nodes in the table
+	Capacity     int   `json:"capacity"`     // Maximum number of live nodes in the table
+	Replacements int   `json:"replacements"` // Nodes waiting to replace dead ones
+	Buckets      []int `json:"buckets"`      // Live nodes per bucket, closest first
+	SeenThisHour int   `json:"seenThisHour"` // Distinct nodes sending valid packets this hour
+	SeenLastHour int   `json:"seenLastHour"` // Distinct nodes sending valid packets last hour
+}
+
+// Stats returns the occupancy of the table and the number of distinct nodes
+// recently seen.
+func (tab *Table) Stats() TableStats {
+	tab.mutex.Lock()
+	stats := TableStats{
+		Capacity: len(tab.buckets) * tab.bucketSize,
+		Buckets:  make([]int, len(tab.buckets)),
+	}
+	for i, b := range tab.buckets {
+		stats.Buckets[i] = len(b.entries)
+		stats.Nodes += len(b.entries)
+		stats.Replacements += len(b.replacements)
+	}
+	tab.mutex.Unlock()
+
+	stats.SeenThisHour, stats.SeenLastHour = tab.seen.counts()
+	return stats
+}
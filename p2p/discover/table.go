@@ -84,6 +84,10 @@ type Table struct {
 
 	nodeAddedHook func(*Node) // for testing
 
+	bucketSize int        // maximum number of live entries per bucket
+	seedCount  int        // number of nodes loaded from the database at startup
+	seen       *seenNodes // distinct nodes sending valid packets per hour
+
 	net  transport
 	self *Node // metadata of the local node
 }
@@ -113,6 +117,12 @@ type bucket struct {
 }
 
 func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string, bootnodes []*Node) (*Table, error) {
+	return newSizedTable(t, ourID, ourAddr, nodeDBPath, bootnodes, bucketSize, seedCount)
+}
+
+// newSizedTable creates a table holding up to size live nodes per bucket, and
+// loading up to seeds nodes from the database at startup.
+func newSizedTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string, bootnodes []*Node, size, seeds int) (*Table, error) {
 	// If no node database was given, use an in-memory one
 	db, err := newNodeDB(nodeDBPath, Version, ourID)
 	if err != nil {
@@ -130,6 +140,9 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 		closed:     make(chan struct{}),
 		rand:       mrand.New(mrand.NewSource(0)),
 		ips:        netutil.DistinctNetSet{Subnet: tableSubnet, Limit: tableIPLimit},
+		bucketSize: size,
+		seedCount:  seeds,
+		seen:       newSeenNodes(),
 	}
 	if err := tab.setFallbackNodes(bootnodes); err != nil {
 		return nil, err
@@ -401,7 +414,7 @@ loop:
 		case <-revalidateDone:
 			revalidate.Reset(tab.nextRevalidateTime())
 		case <-copyNodes.C:
-			go tab.copyBondedNodes()
+			go tab.copyBondedNodes(seedMinTableTime)
 		case <-tab.closeReq:
 			break loop
 		}
@@ -416,6 +429,8 @@ loop:
 	for _, ch := range waiting {
 		close(ch)
 	}
+	// Persist the whole table so it can be reloaded on restart
+	tab.copyBondedNodes(0)
 	tab.db.close()
 	close(tab.closed)
 }
@@ -448,7 +463,7 @@ func (tab *Table) doRefresh(done chan struct{}) {
 }
 
 func (tab *Table) loadSeedNodes(bond bool) {
-	seeds := tab.db.querySeeds(seedCount, seedMaxAge)
+	seeds := tab.db.querySeeds(tab.seedCount, seedMaxAge)
 	seeds = append(seeds, tab.nursery...)
 	if bond {
 		seeds = tab.bondall(seeds)
@@ -521,14 +536,14 @@ func (tab *Table) nextRevalidateTime() time.Duration {
 
 // copyBondedNodes adds nodes from the table to the database if they have been in the table
 // longer then minTableTime.
-func (tab *Table) copyBondedNodes() {
+func (tab *Table) copyBondedNodes(minTableTime time.Duration) {
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
 
 	now := time.Now()
 	for _, b := range tab.buckets {
 		for _, n := range b.entries {
-			if now.Sub(n.addedAt) >= seedMinTableTime {
+			if now.Sub(n.addedAt) >= minTableTime {
 				tab.db.updateNode(n)
 			}
 		}
@@ -708,7 +723,7 @@ func (tab *Table) stuff(nodes []*Node) {
 			continue // don't add self
 		}
 		b := tab.bucket(n.sha)
-		if len(b.entries) < bucketSize {
+		if len(b.entries) < tab.bucketSize {
 			tab.bumpOrAdd(b, n)
 		}
 	}
@@ -803,10 +818,10 @@ func (tab *Table) bumpOrAdd(b *bucket, n *Node) bool {
 	if b.bump(n) {
 		return true
 	}
-	if len(b.entries) >= bucketSize || !tab.addIP(b, n.IP) {
+	if len(b.entries) >= tab.bucketSize || !tab.addIP(b, n.IP) {
 		return false
 	}
-	b.entries, _ = pushNode(b.entries, n, bucketSize)
+	b.entries, _ = pushNode(b.entries, n, tab.bucketSize)
 	b.replacements = deleteNode(b.replacements, n)
 	n.addedAt = time.Now()
 	if tab.nodeAddedHook != nil {
@@ -19,7 +19,10 @@ package discover
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"net"
@@ -172,6 +175,41 @@ func TestTable_BucketIPLimit(t *testing.T) {
 // fillBucket inserts nodes into the given bucket until
 // it is full. The node's IDs dont correspond to their
 // hashes.
+// Tests that the nodes of a closed table are reloaded by the next table using
+// the same node database.
+func TestTable_persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nodes")
+
+	tab, err := newSizedTable(newPingRecorder(), NodeID{}, &net.UDPAddr{}, path, nil, bucketSize, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-tab.initDone
+	for i := 0; i < 20; i++ {
+		var id NodeID
+		rand.Read(id[:])
+		n := NewNode(id, net.IP{10, byte(i), 0, 1}, 30303, 30303)
+		tab.db.updateBondTime(n.ID, time.Now())
+		tab.add(n)
+	}
+	want := tab.len()
+	tab.Close()
+
+	tab, err = newSizedTable(newPingRecorder(), NodeID{}, &net.UDPAddr{}, path, nil, bucketSize, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tab.Close()
+	if have := tab.len(); have != want {
+		t.Errorf("reloaded table size mismatch: have %d, want %d", have, want)
+	}
+}
+
 func fillBucket(tab *Table, n *Node) (last *Node) {
 	ld := logdist(tab.self.sha, n.sha)
 	b := tab.bucket(n.sha)
@@ -666,3 +704,22 @@ func newkey() *ecdsa.PrivateKey {
 	}
 	return key
 }
+
+func TestSeenNodes(t *testing.T) {
+	s := newSeenNodes()
+	hour := s.hour
+	s.add(NodeID{1})
+	s.add(NodeID{2})
+	s.add(NodeID{1})
+	if cur, last := s.counts(); cur != 2 || last != 0 {
+		t.Fatalf("counts mismatch: have %d/%d, want 2/0", cur, last)
+	}
+	s.rotate(hour.Add(time.Hour))
+	if cur, last := len(s.current), s.lastHour; cur != 0 || last != 2 {
+		t.Fatalf("counts after an hour mismatch: have %d/%d, want 0/2", cur, last)
+	}
+	s.rotate(hour.Add(3 * time.Hour))
+	if last := s.lastHour; last != 0 {
+		t.Fatalf("counts after idle hours mismatch: have %d, want 0", last)
+	}
+}
@@ -169,6 +169,7 @@ type conn interface {
 type udp struct {
 	conn        conn
 	netrestrict *netutil.Netlist
+	filter      *packetFilter
 	priv        *ecdsa.PrivateKey
 	ourEndpoint rpcEndpoint
 
@@ -233,9 +234,13 @@ type Config struct {
 	AnnounceAddr *net.UDPAddr      // local address announced in the DHT
 	NodeDBPath   string            // if set, the node database is stored at this filesystem location
 	NetRestrict  *netutil.Netlist  // network whitelist
+	NetDeny      *netutil.Netlist  // packets from these networks are dropped
 	Bootnodes    []*Node           // list of bootstrap nodes
 	Unhandled    chan<- ReadPacket // unhandled packets are sent on this channel
 	ChainId      uint64
+	BucketSize   int // maximum number of nodes per table bucket (0 = 16)
+	SeedCount    int // maximum number of nodes reloaded from the node database at startup (0 = 30)
+	RateLimit    int // maximum number of packets per second accepted from a single IP (0 = unlimited)
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
@@ -253,6 +258,7 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 		conn:        c,
 		priv:        cfg.PrivateKey,
 		netrestrict: cfg.NetRestrict,
+		filter:      newPacketFilter(cfg),
 		closing:     make(chan struct{}),
 		gotreply:    make(chan reply),
 		addpending:  make(chan *pending),
@@ -267,7 +273,14 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
-	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
+	size, seeds := cfg.BucketSize, cfg.SeedCount
+	if size == 0 {
+		size = bucketSize
+	}
+	if seeds == 0 {
+		seeds = seedCount
+	}
+	tab, err := newSizedTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes, size, seeds)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -519,6 +532,12 @@ func (t *udp) send(toaddr *net.UDPAddr, ptype byte, req packet) ([]byte, error)
 func (t *udp) write(toaddr *net.UDPAddr, what string, packet []byte) error {
 	_, err := t.conn.WriteToUDP(packet, toaddr)
 	log.Trace(">> "+what, "addr", toaddr, "err", err)
+	if err == nil {
+		egressTrafficMeter.Mark(int64(len(packet)))
+		if meter, ok := egressPacketMeters[what]; ok {
+			meter.Mark(1)
+		}
+	}
 	return err
 }
 
@@ -569,6 +588,11 @@ func (t *udp) readLoop(unhandled chan<- ReadPacket) {
 			log.Debug("UDP read error", "err", err)
 			return
 		}
+		if !t.filter.accept(from, time.Now()) {
+			// Drop packets from denied networks or flooding sources
+			// without decoding them.
+			continue
+		}
 		if t.handlePacket(from, buf[:nbytes]) != nil && unhandled != nil {
 			select {
 			case unhandled <- ReadPacket{buf[:nbytes], from}:
@@ -579,12 +603,18 @@ func (t *udp) readLoop(unhandled chan<- ReadPacket) {
 }
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
+	ingressTrafficMeter.Mark(int64(len(buf)))
 	packet, fromID, hash, err := decodePacket(t.netcompat(), buf)
 	if err != nil {
+		ingressBadMeter.Mark(1)
 		log.Debug("Bad discv4 packet", "addr", from, "err", err)
 		return err
 	}
+	ingressPacketMeters[packet.name()].Mark(1)
 	err = packet.handle(t, from, fromID, hash)
+	if err == nil {
+		t.seen.add(fromID)
+	}
 	log.Trace("<< "+packet.name(), "addr", from, "err", err)
 	return err
 }
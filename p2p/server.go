@@ -173,6 +173,7 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	setmaxpeers   chan int
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
@@ -304,6 +305,15 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// SetMaxPeers changes the maximum number of connected peers. Existing peers are
+// kept even if the new limit is lower, the limit only applies to new connections.
+func (srv *Server) SetMaxPeers(max int) {
+	select {
+	case srv.setmaxpeers <- max:
+	case <-srv.quit:
+	}
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -417,6 +427,7 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.setmaxpeers = make(chan int)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
@@ -545,6 +556,7 @@ type dialer interface {
 	taskDone(task, time.Time)
 	addStatic(*discover.Node)
 	removeStatic(*discover.Node)
+	setMaxDynDials(int)
 }
 
 func (srv *Server) run(dialstate dialer) {
@@ -617,6 +629,12 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case max := <-srv.setmaxpeers:
+			// This channel is used by SetMaxPeers to change the peer
+			// limit, which also scales the number of dialed peers.
+			srv.log.Debug("Changing peer limit", "max", max)
+			srv.MaxPeers = max
+			dialstate.setMaxDynDials(srv.maxDialedConns())
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
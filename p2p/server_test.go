@@ -310,6 +310,8 @@ func (tg taskgen) addStatic(*discover.Node) {
 }
 func (tg taskgen) removeStatic(*discover.Node) {
 }
+func (tg taskgen) setMaxDynDials(int) {
+}
 
 type testTask struct {
 	index  int
@@ -0,0 +1,106 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/common/log"
+)
+
+// AccessLog records every HTTP request served, for finding abusive clients of
+// public endpoints. It may be shared by several servers.
+type AccessLog struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAccessLog creates an access log writing one JSON record per line to out,
+// or one structured line per request to the node's logger if out is nil.
+func NewAccessLog(out io.Writer) *AccessLog {
+	return &AccessLog{out: out}
+}
+
+// accessRecord is the access log entry of a single HTTP request.
+type accessRecord struct {
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip"`
+	Methods []string  `json:"methods,omitempty"`
+	Batch   int       `json:"batch,omitempty"` // number of calls, 0 if not a batch
+	Status  int       `json:"status"`
+	Size    int64     `json:"size"`    // response size in bytes
+	Latency float64   `json:"latency"` // in milliseconds
+}
+
+// accessRecordKey is the context key of the access log entry of a request.
+type accessRecordKey struct{}
+
+// recordCalls stores the methods called by a request in its access log entry,
+// if it has one.
+func recordCalls(ctx context.Context, reqs []*serverRequest, batch bool) {
+	rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord)
+	if !ok {
+		return
+	}
+	for _, req := range reqs {
+		rec.Methods = append(rec.Methods, req.method)
+	}
+	if batch {
+		rec.Batch = len(reqs)
+	}
+}
+
+// write finishes the entry and adds it to the log.
+func (l *AccessLog) write(rec *accessRecord) {
+	latency := time.Since(rec.Time)
+	rec.Latency = float64(latency) / float64(time.Millisecond)
+	if l.out == nil {
+		log.Info("RPC request", "ip", rec.IP, "methods", rec.Methods, "batch", rec.Batch, "status", rec.Status, "size", rec.Size, "latency", latency)
+		return
+	}
+	blob, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(append(blob, '\n')); err != nil {
+		log.Warn("Failed to write RPC access log", "err", err)
+	}
+}
+
+// accessLogWriter captures the status and size of a response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	rec *accessRecord
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	w.rec.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.rec.Size += int64(n)
+	return n, err
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPAccessLog(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	server.SetAccessLog(NewAccessLog(&out))
+	handler := NewHTTPServer(nil, []string{"*"}, []string{"0.0.0.0/0"}, true, server).Handler
+
+	call := func(contentType, body string) {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		request.RemoteAddr = "127.0.0.1:1234" // local reverse proxy
+		request.Header.Set("X-Forwarded-For", "8.8.8.8, 10.0.0.1")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	call("application/json", `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1,null]}`)
+	call("application/json", `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1,null]},{"jsonrpc":"2.0","id":2,"method":"test_missing"}]`)
+	call("text/plain", `{}`)
+
+	var records []accessRecord
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var rec accessRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid access log record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 3 {
+		t.Fatalf("have %d access log records, want 3", len(records))
+	}
+	want := []struct {
+		methods []string
+		batch   int
+		status  int
+	}{
+		{[]string{"test_echo"}, 0, http.StatusOK},
+		{[]string{"test_echo", "test_missing"}, 2, http.StatusOK},
+		{nil, 0, http.StatusUnsupportedMediaType},
+	}
+	for i, rec := range records {
+		if rec.IP != "8.8.8.8" {
+			t.Errorf("record %d: have ip %s, want 8.8.8.8", i, rec.IP)
+		}
+		if !reflect.DeepEqual(rec.Methods, want[i].methods) || rec.Batch != want[i].batch || rec.Status != want[i].status {
+			t.Errorf("record %d: have methods %v batch %d status %d, want %v %d %d", i, rec.Methods, rec.Batch, rec.Status, want[i].methods, want[i].batch, want[i].status)
+		}
+		if rec.Size == 0 {
+			t.Errorf("record %d: response size not recorded", i)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeJWT creates a token with the given header and claims, signed with secret.
+func makeJWT(secret []byte, header, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	var (
+		secret = []byte("0123456789abcdef0123456789abcdef")
+		now    = time.Unix(1600000000, 0)
+		header = `{"alg":"HS256","typ":"JWT"}`
+	)
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{makeJWT(secret, header, `{"iat":1600000000}`), nil},
+		{makeJWT(secret, header, `{"iat":1599999950}`), nil},
+		{makeJWT(secret, header, `{"iat":1600000050}`), nil},
+		{makeJWT(secret, header, `{"iat":1599999900}`), errStaleToken},
+		{makeJWT(secret, header, `{"iat":1600000100}`), errStaleToken},
+		{makeJWT(secret, header, `{}`), errInvalidToken},
+		{makeJWT([]byte("wrong"), header, `{"iat":1600000000}`), errInvalidToken},
+		{makeJWT(secret, `{"alg":"none"}`, `{"iat":1600000000}`), errInvalidToken},
+		{strings.TrimSuffix(makeJWT(secret, header, `{"iat":1600000000}`), "A") + "B", errInvalidToken},
+		{"garbage", errInvalidToken},
+	}
+	for i, tt := range tests {
+		if err := verifyJWT(secret, tt.token, now); err != tt.err {
+			t.Errorf("test %d: have error %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestHTTPJWTAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	srv := NewServer()
+	defer srv.Stop()
+	srv.SetJWTSecret(secret)
+	handler := NewHTTPServer(nil, []string{"*"}, []string{"127.0.0.1/8", "192.0.2.0/24"}, false, srv).Handler
+
+	call := func(auth string) int {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+		request.Header.Set("content-type", contentType)
+		if auth != "" {
+			request.Header.Set("Authorization", auth)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	if code := call(""); code != http.StatusUnauthorized {
+		t.Errorf("no token: response code should be %d not %d", http.StatusUnauthorized, code)
+	}
+	if code := call("Bearer " + makeJWT([]byte("wrong"), `{"alg":"HS256"}`, `{"iat":1}`)); code != http.StatusUnauthorized {
+		t.Errorf("invalid token: response code should be %d not %d", http.StatusUnauthorized, code)
+	}
+	token := makeJWT(secret, `{"alg":"HS256"}`, `{"iat":`+strconv.FormatInt(time.Now().Unix(), 10)+`}`)
+	if code := call("Bearer " + token); code != http.StatusOK {
+		t.Errorf("valid token: response code should be %d not %d", http.StatusOK, code)
+	}
+}
+
+// Tests that health checks, CORS preflights and OPTIONS/HEAD probes are
+// answered without a token.
+func TestHTTPJWTAuthExempt(t *testing.T) {
+	srv := NewServer()
+	defer srv.Stop()
+	srv.SetJWTSecret([]byte("0123456789abcdef0123456789abcdef"))
+	srv.SetHealthCheck(func() Health { return Health{Ready: true} })
+	handler := NewHTTPServer([]string{"https://example.com"}, []string{"*"}, []string{"127.0.0.1/8", "192.0.2.0/24"}, false, srv).Handler
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		code         int
+	}{
+		{http.MethodGet, "/health", nil, http.StatusOK},
+		{http.MethodHead, "/ready", nil, http.StatusOK},
+		{http.MethodOptions, "/", nil, http.StatusNoContent},
+		{http.MethodHead, "/", nil, http.StatusOK},
+		{http.MethodOptions, "/", map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST"}, http.StatusOK},
+		{http.MethodGet, "/?x=1", nil, http.StatusUnauthorized},
+		{http.MethodPost, "/", map[string]string{"Origin": "https://example.com"}, http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		request := httptest.NewRequest(tt.method, "http://localhost"+tt.path, nil)
+		for key, value := range tt.header {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != tt.code {
+			t.Errorf("test %d (%s %s): response code should be %d not %d", i, tt.method, tt.path, tt.code, recorder.Code)
+		}
+	}
+}
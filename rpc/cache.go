@@ -0,0 +1,111 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// CachePolicy decides which results of a method may be served from the response
+// cache to later calls with the same parameters.
+type CachePolicy struct {
+	// Final reports whether a result cannot change anymore.
+	Final func(result interface{}) bool
+
+	// Key optionally maps the parameters of a call to those identifying the
+	// data asked for, like a block number to the canonical hash of the block,
+	// so that the results cached before a reorg are not served after it. The
+	// call is not cached if it returns false.
+	Key func(params []interface{}) ([]interface{}, bool)
+}
+
+// ResponseCache is an LRU cache of the results of read-only methods which
+// cannot change anymore, like blocks deep enough in the chain. Only methods
+// with a cache policy are cached. A cache may be shared by several servers, and
+// must be purged when final results change after all, like on a chain rewind.
+type ResponseCache struct {
+	results  *lru.Cache // JSON encoded results by method and parameters
+	policies map[string]CachePolicy
+}
+
+// NewResponseCache creates a cache of up to size results of the methods with
+// the given policies, keyed by their full name like "aqua_getBlockByHash".
+func NewResponseCache(size int, policies map[string]CachePolicy) (*ResponseCache, error) {
+	results, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseCache{results: results, policies: policies}, nil
+}
+
+// get returns the cached result of calling method with args, if any, and the
+// key to cache its result under otherwise. The key is empty if the method is
+// not cached.
+func (c *ResponseCache) get(method string, args []reflect.Value) (json.RawMessage, string) {
+	policy, ok := c.policies[method]
+	if !ok {
+		return nil, ""
+	}
+	// Key on the parsed arguments, so that equal parameters encoded
+	// differently share the same result.
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = arg.Interface()
+	}
+	if policy.Key != nil {
+		if params, ok = policy.Key(params); !ok {
+			return nil, ""
+		}
+	}
+	blob, err := json.Marshal(params)
+	if err != nil {
+		return nil, ""
+	}
+	key := method + string(blob)
+	if result, ok := c.results.Get(key); ok {
+		rpcCacheHitMeter.Mark(1)
+		return result.(json.RawMessage), key
+	}
+	rpcCacheMissMeter.Mark(1)
+	return nil, key
+}
+
+// put caches the result of a call under key if the policy of the method
+// allows it.
+func (c *ResponseCache) put(key, method string, result interface{}) {
+	if result == nil || !c.policies[method].Final(result) {
+		return
+	}
+	if blob, err := json.Marshal(result); err == nil {
+		c.results.Add(key, json.RawMessage(blob))
+	}
+}
+
+// Purge drops all the cached results.
+func (c *ResponseCache) Purge() {
+	c.results.Purge()
+	rpcCachePurgeMeter.Mark(1)
+}
+
+// SetResponseCache makes the server answer the calls of cached methods from
+// the given cache. It must be called before the server handles any requests.
+func (s *Server) SetResponseCache(cache *ResponseCache) {
+	s.cache = cache
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type CacheTestService struct {
+	calls int
+}
+
+func (s *CacheTestService) Square(n int) int {
+	s.calls++
+	return n * n
+}
+
+func TestResponseCache(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	service := new(CacheTestService)
+	if err := server.RegisterName("test", service); err != nil {
+		t.Fatal(err)
+	}
+	// Only results of at least 100 cannot change anymore.
+	cache, err := NewResponseCache(16, map[string]CachePolicy{
+		"test_square": {Final: func(result interface{}) bool { return result.(int) >= 100 }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetResponseCache(cache)
+
+	call := func(params string) int {
+		body := `{"jsonrpc":"2.0","id":1,"method":"test_square","params":` + params + `}`
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		var result struct{ Result int }
+		if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response %q: %v", response.Body.String(), err)
+		}
+		return result.Result
+	}
+	tests := []struct {
+		params string
+		result int
+		calls  int
+	}{
+		{"[3]", 9, 1},
+		{"[3]", 9, 2}, // not cacheable
+		{"[12]", 144, 3},
+		{"[12]", 144, 3},
+		{"[ 12 ]", 144, 3}, // same parameters, encoded differently
+		{"[13]", 169, 4},
+	}
+	for i, tt := range tests {
+		if result := call(tt.params); result != tt.result {
+			t.Errorf("call %d: result mismatch: have %d, want %d", i, result, tt.result)
+		}
+		if service.calls != tt.calls {
+			t.Errorf("call %d: method calls mismatch: have %d, want %d", i, service.calls, tt.calls)
+		}
+	}
+	// Purged results are computed again
+	cache.Purge()
+	if call("[12]"); service.calls != 5 {
+		t.Errorf("method calls mismatch after purge: have %d, want %d", service.calls, 5)
+	}
+}
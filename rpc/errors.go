@@ -16,7 +16,10 @@
 
 package rpc
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // request is for an unknown service
 type methodNotFoundError struct {
@@ -58,6 +61,50 @@ func (e *callbackError) ErrorCode() int { return -32000 }
 
 func (e *callbackError) Error() string { return e.message }
 
+// issued when a client calls a method more often than its rate limit allows
+type rateLimitError struct{ method string }
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string { return fmt.Sprintf("rate limit of %s exceeded", e.method) }
+
+// issued when a call runs for longer than the timeout of its method
+type timeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e *timeoutError) ErrorCode() int { return -32002 }
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %v", e.method, e.timeout)
+}
+
+// issued when too many timed out calls are still running to accept another
+type busyError struct{ method string }
+
+func (e *busyError) ErrorCode() int { return -32004 }
+
+func (e *busyError) Error() string {
+	return fmt.Sprintf("%s refused, too many timed out calls still running", e.method)
+}
+
+// issued for the calls of a batch exceeding the size limit of the response
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("batch response too large (limit %d bytes)", e.limit)
+}
+
+// issued to subscribers dropped for not keeping up with their notifications
+type subscriptionOverflowError struct{}
+
+func (e *subscriptionOverflowError) ErrorCode() int { return -32006 }
+
+func (e *subscriptionOverflowError) Error() string { return ErrSubscriptionQueueOverflow.Error() }
+
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct{}
 
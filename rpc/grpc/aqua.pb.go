@@ -0,0 +1,1177 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: aqua.proto
+
+package grpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type BlockRequest struct {
+	// Types that are valid to be assigned to Block:
+	//	*BlockRequest_Number
+	//	*BlockRequest_Hash
+	Block isBlockRequest_Block `protobuf_oneof:"block"`
+	// Include the transactions, not just their hashes.
+	FullTransactions     bool     `protobuf:"varint,3,opt,name=full_transactions,json=fullTransactions,proto3" json:"full_transactions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockRequest) Reset()         { *m = BlockRequest{} }
+func (m *BlockRequest) String() string { return proto.CompactTextString(m) }
+func (*BlockRequest) ProtoMessage()    {}
+func (*BlockRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{0}
+}
+func (m *BlockRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockRequest.Unmarshal(m, b)
+}
+func (m *BlockRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockRequest.Marshal(b, m, deterministic)
+}
+func (dst *BlockRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockRequest.Merge(dst, src)
+}
+func (m *BlockRequest) XXX_Size() int {
+	return xxx_messageInfo_BlockRequest.Size(m)
+}
+func (m *BlockRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockRequest proto.InternalMessageInfo
+
+type isBlockRequest_Block interface {
+	isBlockRequest_Block()
+}
+
+type BlockRequest_Number struct {
+	Number uint64 `protobuf:"varint,1,opt,name=number,proto3,oneof"`
+}
+
+type BlockRequest_Hash struct {
+	Hash []byte `protobuf:"bytes,2,opt,name=hash,proto3,oneof"`
+}
+
+func (*BlockRequest_Number) isBlockRequest_Block() {}
+
+func (*BlockRequest_Hash) isBlockRequest_Block() {}
+
+func (m *BlockRequest) GetBlock() isBlockRequest_Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+func (m *BlockRequest) GetNumber() uint64 {
+	if x, ok := m.GetBlock().(*BlockRequest_Number); ok {
+		return x.Number
+	}
+	return 0
+}
+
+func (m *BlockRequest) GetHash() []byte {
+	if x, ok := m.GetBlock().(*BlockRequest_Hash); ok {
+		return x.Hash
+	}
+	return nil
+}
+
+func (m *BlockRequest) GetFullTransactions() bool {
+	if m != nil {
+		return m.FullTransactions
+	}
+	return false
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*BlockRequest) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _BlockRequest_OneofMarshaler, _BlockRequest_OneofUnmarshaler, _BlockRequest_OneofSizer, []interface{}{
+		(*BlockRequest_Number)(nil),
+		(*BlockRequest_Hash)(nil),
+	}
+}
+
+func _BlockRequest_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*BlockRequest)
+	// block
+	switch x := m.Block.(type) {
+	case *BlockRequest_Number:
+		b.EncodeVarint(1<<3 | proto.WireVarint)
+		b.EncodeVarint(uint64(x.Number))
+	case *BlockRequest_Hash:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		b.EncodeRawBytes(x.Hash)
+	case nil:
+	default:
+		return fmt.Errorf("BlockRequest.Block has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _BlockRequest_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*BlockRequest)
+	switch tag {
+	case 1: // block.number
+		if wire != proto.WireVarint {
+			return true, proto.ErrInternalBadWireType
+		}
+		x, err := b.DecodeVarint()
+		m.Block = &BlockRequest_Number{x}
+		return true, err
+	case 2: // block.hash
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		x, err := b.DecodeRawBytes(true)
+		m.Block = &BlockRequest_Hash{x}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _BlockRequest_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*BlockRequest)
+	// block
+	switch x := m.Block.(type) {
+	case *BlockRequest_Number:
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(x.Number))
+	case *BlockRequest_Hash:
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(len(x.Hash)))
+		n += len(x.Hash)
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+type SubscribeBlocksRequest struct {
+	// Include the transactions, not just their hashes.
+	FullTransactions     bool     `protobuf:"varint,1,opt,name=full_transactions,json=fullTransactions,proto3" json:"full_transactions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeBlocksRequest) Reset()         { *m = SubscribeBlocksRequest{} }
+func (m *SubscribeBlocksRequest) String() string { return proto.CompactTextString(m) }
+func (*SubscribeBlocksRequest) ProtoMessage()    {}
+func (*SubscribeBlocksRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{1}
+}
+func (m *SubscribeBlocksRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeBlocksRequest.Unmarshal(m, b)
+}
+func (m *SubscribeBlocksRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeBlocksRequest.Marshal(b, m, deterministic)
+}
+func (dst *SubscribeBlocksRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeBlocksRequest.Merge(dst, src)
+}
+func (m *SubscribeBlocksRequest) XXX_Size() int {
+	return xxx_messageInfo_SubscribeBlocksRequest.Size(m)
+}
+func (m *SubscribeBlocksRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeBlocksRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeBlocksRequest proto.InternalMessageInfo
+
+func (m *SubscribeBlocksRequest) GetFullTransactions() bool {
+	if m != nil {
+		return m.FullTransactions
+	}
+	return false
+}
+
+type Block struct {
+	Hash                 []byte         `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	ParentHash           []byte         `protobuf:"bytes,2,opt,name=parent_hash,json=parentHash,proto3" json:"parent_hash,omitempty"`
+	Number               uint64         `protobuf:"varint,3,opt,name=number,proto3" json:"number,omitempty"`
+	Time                 uint64         `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	Miner                []byte         `protobuf:"bytes,5,opt,name=miner,proto3" json:"miner,omitempty"`
+	Difficulty           []byte         `protobuf:"bytes,6,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+	GasLimit             uint64         `protobuf:"varint,7,opt,name=gas_limit,json=gasLimit,proto3" json:"gas_limit,omitempty"`
+	GasUsed              uint64         `protobuf:"varint,8,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	StateRoot            []byte         `protobuf:"bytes,9,opt,name=state_root,json=stateRoot,proto3" json:"state_root,omitempty"`
+	TransactionsRoot     []byte         `protobuf:"bytes,10,opt,name=transactions_root,json=transactionsRoot,proto3" json:"transactions_root,omitempty"`
+	ReceiptsRoot         []byte         `protobuf:"bytes,11,opt,name=receipts_root,json=receiptsRoot,proto3" json:"receipts_root,omitempty"`
+	Extra                []byte         `protobuf:"bytes,12,opt,name=extra,proto3" json:"extra,omitempty"`
+	Nonce                uint64         `protobuf:"varint,13,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Version              uint32         `protobuf:"varint,14,opt,name=version,proto3" json:"version,omitempty"`
+	TransactionHashes    [][]byte       `protobuf:"bytes,15,rep,name=transaction_hashes,json=transactionHashes,proto3" json:"transaction_hashes,omitempty"`
+	Transactions         []*Transaction `protobuf:"bytes,16,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *Block) Reset()         { *m = Block{} }
+func (m *Block) String() string { return proto.CompactTextString(m) }
+func (*Block) ProtoMessage()    {}
+func (*Block) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{2}
+}
+func (m *Block) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Block.Unmarshal(m, b)
+}
+func (m *Block) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Block.Marshal(b, m, deterministic)
+}
+func (dst *Block) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Block.Merge(dst, src)
+}
+func (m *Block) XXX_Size() int {
+	return xxx_messageInfo_Block.Size(m)
+}
+func (m *Block) XXX_DiscardUnknown() {
+	xxx_messageInfo_Block.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Block proto.InternalMessageInfo
+
+func (m *Block) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Block) GetParentHash() []byte {
+	if m != nil {
+		return m.ParentHash
+	}
+	return nil
+}
+
+func (m *Block) GetNumber() uint64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *Block) GetTime() uint64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Block) GetMiner() []byte {
+	if m != nil {
+		return m.Miner
+	}
+	return nil
+}
+
+func (m *Block) GetDifficulty() []byte {
+	if m != nil {
+		return m.Difficulty
+	}
+	return nil
+}
+
+func (m *Block) GetGasLimit() uint64 {
+	if m != nil {
+		return m.GasLimit
+	}
+	return 0
+}
+
+func (m *Block) GetGasUsed() uint64 {
+	if m != nil {
+		return m.GasUsed
+	}
+	return 0
+}
+
+func (m *Block) GetStateRoot() []byte {
+	if m != nil {
+		return m.StateRoot
+	}
+	return nil
+}
+
+func (m *Block) GetTransactionsRoot() []byte {
+	if m != nil {
+		return m.TransactionsRoot
+	}
+	return nil
+}
+
+func (m *Block) GetReceiptsRoot() []byte {
+	if m != nil {
+		return m.ReceiptsRoot
+	}
+	return nil
+}
+
+func (m *Block) GetExtra() []byte {
+	if m != nil {
+		return m.Extra
+	}
+	return nil
+}
+
+func (m *Block) GetNonce() uint64 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+func (m *Block) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Block) GetTransactionHashes() [][]byte {
+	if m != nil {
+		return m.TransactionHashes
+	}
+	return nil
+}
+
+func (m *Block) GetTransactions() []*Transaction {
+	if m != nil {
+		return m.Transactions
+	}
+	return nil
+}
+
+type TransactionRequest struct {
+	Hash                 []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TransactionRequest) Reset()         { *m = TransactionRequest{} }
+func (m *TransactionRequest) String() string { return proto.CompactTextString(m) }
+func (*TransactionRequest) ProtoMessage()    {}
+func (*TransactionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{3}
+}
+func (m *TransactionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TransactionRequest.Unmarshal(m, b)
+}
+func (m *TransactionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TransactionRequest.Marshal(b, m, deterministic)
+}
+func (dst *TransactionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TransactionRequest.Merge(dst, src)
+}
+func (m *TransactionRequest) XXX_Size() int {
+	return xxx_messageInfo_TransactionRequest.Size(m)
+}
+func (m *TransactionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TransactionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TransactionRequest proto.InternalMessageInfo
+
+func (m *TransactionRequest) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+type Transaction struct {
+	Hash  []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Nonce uint64 `protobuf:"varint,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	From  []byte `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	// Empty for contract creations.
+	To                   []byte   `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	Value                []byte   `protobuf:"bytes,5,opt,name=value,proto3" json:"value,omitempty"`
+	Gas                  uint64   `protobuf:"varint,6,opt,name=gas,proto3" json:"gas,omitempty"`
+	GasPrice             []byte   `protobuf:"bytes,7,opt,name=gas_price,json=gasPrice,proto3" json:"gas_price,omitempty"`
+	Input                []byte   `protobuf:"bytes,8,opt,name=input,proto3" json:"input,omitempty"`
+	BlockHash            []byte   `protobuf:"bytes,9,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	BlockNumber          uint64   `protobuf:"varint,10,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Index                uint64   `protobuf:"varint,11,opt,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Transaction) Reset()         { *m = Transaction{} }
+func (m *Transaction) String() string { return proto.CompactTextString(m) }
+func (*Transaction) ProtoMessage()    {}
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{4}
+}
+func (m *Transaction) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Transaction.Unmarshal(m, b)
+}
+func (m *Transaction) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Transaction.Marshal(b, m, deterministic)
+}
+func (dst *Transaction) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Transaction.Merge(dst, src)
+}
+func (m *Transaction) XXX_Size() int {
+	return xxx_messageInfo_Transaction.Size(m)
+}
+func (m *Transaction) XXX_DiscardUnknown() {
+	xxx_messageInfo_Transaction.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Transaction proto.InternalMessageInfo
+
+func (m *Transaction) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Transaction) GetNonce() uint64 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+func (m *Transaction) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *Transaction) GetTo() []byte {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *Transaction) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Transaction) GetGas() uint64 {
+	if m != nil {
+		return m.Gas
+	}
+	return 0
+}
+
+func (m *Transaction) GetGasPrice() []byte {
+	if m != nil {
+		return m.GasPrice
+	}
+	return nil
+}
+
+func (m *Transaction) GetInput() []byte {
+	if m != nil {
+		return m.Input
+	}
+	return nil
+}
+
+func (m *Transaction) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *Transaction) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *Transaction) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type Receipt struct {
+	TransactionHash  []byte `protobuf:"bytes,1,opt,name=transaction_hash,json=transactionHash,proto3" json:"transaction_hash,omitempty"`
+	BlockHash        []byte `protobuf:"bytes,2,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	BlockNumber      uint64 `protobuf:"varint,3,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	TransactionIndex uint64 `protobuf:"varint,4,opt,name=transaction_index,json=transactionIndex,proto3" json:"transaction_index,omitempty"`
+	// Status is set for transactions of byzantium blocks, the post state
+	// root for the earlier ones.
+	Status            uint64 `protobuf:"varint,5,opt,name=status,proto3" json:"status,omitempty"`
+	PostState         []byte `protobuf:"bytes,6,opt,name=post_state,json=postState,proto3" json:"post_state,omitempty"`
+	GasUsed           uint64 `protobuf:"varint,7,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	CumulativeGasUsed uint64 `protobuf:"varint,8,opt,name=cumulative_gas_used,json=cumulativeGasUsed,proto3" json:"cumulative_gas_used,omitempty"`
+	// Empty unless the transaction created a contract.
+	ContractAddress      []byte   `protobuf:"bytes,9,opt,name=contract_address,json=contractAddress,proto3" json:"contract_address,omitempty"`
+	Logs                 []*Log   `protobuf:"bytes,10,rep,name=logs,proto3" json:"logs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Receipt) Reset()         { *m = Receipt{} }
+func (m *Receipt) String() string { return proto.CompactTextString(m) }
+func (*Receipt) ProtoMessage()    {}
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{5}
+}
+func (m *Receipt) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Receipt.Unmarshal(m, b)
+}
+func (m *Receipt) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Receipt.Marshal(b, m, deterministic)
+}
+func (dst *Receipt) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Receipt.Merge(dst, src)
+}
+func (m *Receipt) XXX_Size() int {
+	return xxx_messageInfo_Receipt.Size(m)
+}
+func (m *Receipt) XXX_DiscardUnknown() {
+	xxx_messageInfo_Receipt.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Receipt proto.InternalMessageInfo
+
+func (m *Receipt) GetTransactionHash() []byte {
+	if m != nil {
+		return m.TransactionHash
+	}
+	return nil
+}
+
+func (m *Receipt) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *Receipt) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *Receipt) GetTransactionIndex() uint64 {
+	if m != nil {
+		return m.TransactionIndex
+	}
+	return 0
+}
+
+func (m *Receipt) GetStatus() uint64 {
+	if m != nil {
+		return m.Status
+	}
+	return 0
+}
+
+func (m *Receipt) GetPostState() []byte {
+	if m != nil {
+		return m.PostState
+	}
+	return nil
+}
+
+func (m *Receipt) GetGasUsed() uint64 {
+	if m != nil {
+		return m.GasUsed
+	}
+	return 0
+}
+
+func (m *Receipt) GetCumulativeGasUsed() uint64 {
+	if m != nil {
+		return m.CumulativeGasUsed
+	}
+	return 0
+}
+
+func (m *Receipt) GetContractAddress() []byte {
+	if m != nil {
+		return m.ContractAddress
+	}
+	return nil
+}
+
+func (m *Receipt) GetLogs() []*Log {
+	if m != nil {
+		return m.Logs
+	}
+	return nil
+}
+
+type Log struct {
+	Address              []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Topics               [][]byte `protobuf:"bytes,2,rep,name=topics,proto3" json:"topics,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Index                uint64   `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Log) Reset()         { *m = Log{} }
+func (m *Log) String() string { return proto.CompactTextString(m) }
+func (*Log) ProtoMessage()    {}
+func (*Log) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{6}
+}
+func (m *Log) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Log.Unmarshal(m, b)
+}
+func (m *Log) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Log.Marshal(b, m, deterministic)
+}
+func (dst *Log) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Log.Merge(dst, src)
+}
+func (m *Log) XXX_Size() int {
+	return xxx_messageInfo_Log.Size(m)
+}
+func (m *Log) XXX_DiscardUnknown() {
+	xxx_messageInfo_Log.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Log proto.InternalMessageInfo
+
+func (m *Log) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+func (m *Log) GetTopics() [][]byte {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *Log) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Log) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type BalanceRequest struct {
+	Address []byte `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// The head block if not given.
+	//
+	// Types that are valid to be assigned to Block:
+	//	*BalanceRequest_Number
+	Block                isBalanceRequest_Block `protobuf_oneof:"block"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *BalanceRequest) Reset()         { *m = BalanceRequest{} }
+func (m *BalanceRequest) String() string { return proto.CompactTextString(m) }
+func (*BalanceRequest) ProtoMessage()    {}
+func (*BalanceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{7}
+}
+func (m *BalanceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BalanceRequest.Unmarshal(m, b)
+}
+func (m *BalanceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BalanceRequest.Marshal(b, m, deterministic)
+}
+func (dst *BalanceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BalanceRequest.Merge(dst, src)
+}
+func (m *BalanceRequest) XXX_Size() int {
+	return xxx_messageInfo_BalanceRequest.Size(m)
+}
+func (m *BalanceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BalanceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BalanceRequest proto.InternalMessageInfo
+
+func (m *BalanceRequest) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+type isBalanceRequest_Block interface {
+	isBalanceRequest_Block()
+}
+
+type BalanceRequest_Number struct {
+	Number uint64 `protobuf:"varint,2,opt,name=number,proto3,oneof"`
+}
+
+func (*BalanceRequest_Number) isBalanceRequest_Block() {}
+
+func (m *BalanceRequest) GetBlock() isBalanceRequest_Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+func (m *BalanceRequest) GetNumber() uint64 {
+	if x, ok := m.GetBlock().(*BalanceRequest_Number); ok {
+		return x.Number
+	}
+	return 0
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*BalanceRequest) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _BalanceRequest_OneofMarshaler, _BalanceRequest_OneofUnmarshaler, _BalanceRequest_OneofSizer, []interface{}{
+		(*BalanceRequest_Number)(nil),
+	}
+}
+
+func _BalanceRequest_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*BalanceRequest)
+	// block
+	switch x := m.Block.(type) {
+	case *BalanceRequest_Number:
+		b.EncodeVarint(2<<3 | proto.WireVarint)
+		b.EncodeVarint(uint64(x.Number))
+	case nil:
+	default:
+		return fmt.Errorf("BalanceRequest.Block has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _BalanceRequest_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*BalanceRequest)
+	switch tag {
+	case 2: // block.number
+		if wire != proto.WireVarint {
+			return true, proto.ErrInternalBadWireType
+		}
+		x, err := b.DecodeVarint()
+		m.Block = &BalanceRequest_Number{x}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _BalanceRequest_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*BalanceRequest)
+	// block
+	switch x := m.Block.(type) {
+	case *BalanceRequest_Number:
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(x.Number))
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+type Balance struct {
+	Balance []byte `protobuf:"bytes,1,opt,name=balance,proto3" json:"balance,omitempty"`
+	// Number of the block the balance was read at.
+	Number               uint64   `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Balance) Reset()         { *m = Balance{} }
+func (m *Balance) String() string { return proto.CompactTextString(m) }
+func (*Balance) ProtoMessage()    {}
+func (*Balance) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aqua_b336e9c61a9cbf4d, []int{8}
+}
+func (m *Balance) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Balance.Unmarshal(m, b)
+}
+func (m *Balance) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Balance.Marshal(b, m, deterministic)
+}
+func (dst *Balance) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Balance.Merge(dst, src)
+}
+func (m *Balance) XXX_Size() int {
+	return xxx_messageInfo_Balance.Size(m)
+}
+func (m *Balance) XXX_DiscardUnknown() {
+	xxx_messageInfo_Balance.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Balance proto.InternalMessageInfo
+
+func (m *Balance) GetBalance() []byte {
+	if m != nil {
+		return m.Balance
+	}
+	return nil
+}
+
+func (m *Balance) GetNumber() uint64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*BlockRequest)(nil), "aqua.BlockRequest")
+	proto.RegisterType((*SubscribeBlocksRequest)(nil), "aqua.SubscribeBlocksRequest")
+	proto.RegisterType((*Block)(nil), "aqua.Block")
+	proto.RegisterType((*TransactionRequest)(nil), "aqua.TransactionRequest")
+	proto.RegisterType((*Transaction)(nil), "aqua.Transaction")
+	proto.RegisterType((*Receipt)(nil), "aqua.Receipt")
+	proto.RegisterType((*Log)(nil), "aqua.Log")
+	proto.RegisterType((*BalanceRequest)(nil), "aqua.BalanceRequest")
+	proto.RegisterType((*Balance)(nil), "aqua.Balance")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// AquaClient is the client API for Aqua service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AquaClient interface {
+	// GetBlock returns a block by number or hash, the head block if neither is
+	// given.
+	GetBlock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Block, error)
+	// GetTransaction returns a mined transaction by hash.
+	GetTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Transaction, error)
+	// GetReceipt returns the receipt of a mined transaction by hash.
+	GetReceipt(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Receipt, error)
+	// GetBalance returns the balance of an account.
+	GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	// SubscribeBlocks streams the new head blocks of the chain.
+	SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Aqua_SubscribeBlocksClient, error)
+}
+
+type aquaClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAquaClient(cc *grpc.ClientConn) AquaClient {
+	return &aquaClient{cc}
+}
+
+func (c *aquaClient) GetBlock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, "/aqua.Aqua/GetBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aquaClient) GetTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/aqua.Aqua/GetTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aquaClient) GetReceipt(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Receipt, error) {
+	out := new(Receipt)
+	err := c.cc.Invoke(ctx, "/aqua.Aqua/GetReceipt", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aquaClient) GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, "/aqua.Aqua/GetBalance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aquaClient) SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Aqua_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Aqua_serviceDesc.Streams[0], "/aqua.Aqua/SubscribeBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aquaSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Aqua_SubscribeBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type aquaSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *aquaSubscribeBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AquaServer is the server API for Aqua service.
+type AquaServer interface {
+	// GetBlock returns a block by number or hash, the head block if neither is
+	// given.
+	GetBlock(context.Context, *BlockRequest) (*Block, error)
+	// GetTransaction returns a mined transaction by hash.
+	GetTransaction(context.Context, *TransactionRequest) (*Transaction, error)
+	// GetReceipt returns the receipt of a mined transaction by hash.
+	GetReceipt(context.Context, *TransactionRequest) (*Receipt, error)
+	// GetBalance returns the balance of an account.
+	GetBalance(context.Context, *BalanceRequest) (*Balance, error)
+	// SubscribeBlocks streams the new head blocks of the chain.
+	SubscribeBlocks(*SubscribeBlocksRequest, Aqua_SubscribeBlocksServer) error
+}
+
+func RegisterAquaServer(s *grpc.Server, srv AquaServer) {
+	s.RegisterService(&_Aqua_serviceDesc, srv)
+}
+
+func _Aqua_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AquaServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aqua.Aqua/GetBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AquaServer).GetBlock(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Aqua_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AquaServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aqua.Aqua/GetTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AquaServer).GetTransaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Aqua_GetReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AquaServer).GetReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aqua.Aqua/GetReceipt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AquaServer).GetReceipt(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Aqua_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AquaServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/aqua.Aqua/GetBalance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AquaServer).GetBalance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Aqua_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AquaServer).SubscribeBlocks(m, &aquaSubscribeBlocksServer{stream})
+}
+
+type Aqua_SubscribeBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type aquaSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *aquaSubscribeBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Aqua_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "aqua.Aqua",
+	HandlerType: (*AquaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBlock",
+			Handler:    _Aqua_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Aqua_GetTransaction_Handler,
+		},
+		{
+			MethodName: "GetReceipt",
+			Handler:    _Aqua_GetReceipt_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _Aqua_GetBalance_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _Aqua_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "aqua.proto",
+}
+
+func init() { proto.RegisterFile("aqua.proto", fileDescriptor_aqua_b336e9c61a9cbf4d) }
+
+var fileDescriptor_aqua_b336e9c61a9cbf4d = []byte{
+	// 834 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0xdd, 0x8e, 0xe3, 0x34,
+	0x14, 0xde, 0xa6, 0xe9, 0xdf, 0x69, 0xfa, 0x33, 0xa6, 0x1a, 0x99, 0x85, 0x85, 0x52, 0x6e, 0xba,
+	0x5a, 0x31, 0x82, 0x45, 0x7b, 0x85, 0x40, 0xda, 0x4a, 0xa8, 0x45, 0x1a, 0x10, 0xf2, 0xc0, 0x0d,
+	0x37, 0x91, 0x9b, 0xba, 0x99, 0x88, 0x34, 0xce, 0xc4, 0xce, 0x68, 0x78, 0x09, 0x9e, 0x80, 0x77,
+	0xe4, 0x82, 0x17, 0x40, 0x3e, 0x76, 0xa6, 0x49, 0xa7, 0xa3, 0xbd, 0xf3, 0xf7, 0xf9, 0xf8, 0xf8,
+	0xfc, 0x7c, 0xc7, 0x06, 0xe0, 0x77, 0x25, 0xbf, 0xca, 0x0b, 0xa9, 0x25, 0xf1, 0xcd, 0x7a, 0xa1,
+	0x21, 0x58, 0xa5, 0x32, 0xfa, 0x93, 0x89, 0xbb, 0x52, 0x28, 0x4d, 0x28, 0x74, 0xb3, 0xf2, 0xb0,
+	0x15, 0x05, 0x6d, 0xcd, 0x5b, 0x4b, 0x7f, 0xf3, 0x82, 0x39, 0x4c, 0x66, 0xe0, 0xdf, 0x72, 0x75,
+	0x4b, 0xbd, 0x79, 0x6b, 0x19, 0x6c, 0x5e, 0x30, 0x44, 0xe4, 0x0d, 0x5c, 0xec, 0xcb, 0x34, 0x0d,
+	0x75, 0xc1, 0x33, 0xc5, 0x23, 0x9d, 0xc8, 0x4c, 0xd1, 0xf6, 0xbc, 0xb5, 0xec, 0xb3, 0xa9, 0xd9,
+	0xf8, 0xad, 0xc6, 0xaf, 0x7a, 0xd0, 0xd9, 0x9a, 0xcb, 0x16, 0x3f, 0xc2, 0xe5, 0x4d, 0xb9, 0x55,
+	0x51, 0x91, 0x6c, 0x05, 0x5e, 0xaf, 0xaa, 0xfb, 0xcf, 0xfa, 0x6b, 0x9d, 0xf7, 0xb7, 0xf8, 0xaf,
+	0x0d, 0x1d, 0x3c, 0x4e, 0x88, 0x0b, 0xce, 0x58, 0x06, 0x2e, 0xb4, 0xcf, 0x61, 0x98, 0xf3, 0x42,
+	0x64, 0x3a, 0x3c, 0xc6, 0xcd, 0xc0, 0x52, 0x1b, 0x63, 0x70, 0xf9, 0x98, 0xab, 0x09, 0xd8, 0x7f,
+	0xcc, 0x94, 0x80, 0xaf, 0x93, 0x83, 0xa0, 0x3e, 0xb2, 0xb8, 0x26, 0x33, 0xe8, 0x1c, 0x92, 0x4c,
+	0x14, 0xb4, 0x83, 0x6e, 0x2c, 0x20, 0x9f, 0x01, 0xec, 0x92, 0xfd, 0x3e, 0x89, 0xca, 0x54, 0xff,
+	0x45, 0xbb, 0xf6, 0x86, 0x23, 0x43, 0x3e, 0x81, 0x41, 0xcc, 0x55, 0x98, 0x26, 0x87, 0x44, 0xd3,
+	0x1e, 0xba, 0xeb, 0xc7, 0x5c, 0x5d, 0x1b, 0x4c, 0x3e, 0x06, 0xb3, 0x0e, 0x4b, 0x25, 0x76, 0xb4,
+	0x8f, 0x7b, 0xbd, 0x98, 0xab, 0xdf, 0x95, 0xd8, 0x91, 0x57, 0x00, 0x4a, 0x73, 0x2d, 0xc2, 0x42,
+	0x4a, 0x4d, 0x07, 0xe8, 0x77, 0x80, 0x0c, 0x93, 0x12, 0x8b, 0x54, 0xaf, 0x8f, 0xb5, 0x02, 0xb4,
+	0x9a, 0xd6, 0x37, 0xd0, 0xf8, 0x4b, 0x18, 0x15, 0x22, 0x12, 0x49, 0xae, 0x9d, 0xe1, 0x10, 0x0d,
+	0x83, 0x8a, 0x44, 0xa3, 0x19, 0x74, 0xc4, 0x83, 0x2e, 0x38, 0x0d, 0x6c, 0x7a, 0x08, 0x0c, 0x9b,
+	0xc9, 0x2c, 0x12, 0x74, 0x84, 0xe1, 0x59, 0x40, 0x28, 0xf4, 0xee, 0x45, 0xa1, 0x12, 0x99, 0xd1,
+	0xf1, 0xbc, 0xb5, 0x1c, 0xb1, 0x0a, 0x92, 0xaf, 0x80, 0xd4, 0xae, 0xc7, 0xb2, 0x0b, 0x45, 0x27,
+	0xf3, 0xf6, 0x32, 0x60, 0xf5, 0x88, 0x37, 0xb8, 0x41, 0xde, 0x41, 0xd0, 0x68, 0xf3, 0x74, 0xde,
+	0x5e, 0x0e, 0xdf, 0x5e, 0x5c, 0xa1, 0x48, 0x6b, 0x8d, 0x66, 0x0d, 0xb3, 0xc5, 0x12, 0x48, 0x7d,
+	0xd3, 0x09, 0xe7, 0x8c, 0x02, 0x16, 0x7f, 0x7b, 0x30, 0xac, 0x99, 0x9e, 0x55, 0xc9, 0x63, 0x8e,
+	0x5e, 0x3d, 0x47, 0x02, 0xfe, 0xbe, 0x90, 0x07, 0x14, 0x46, 0xc0, 0x70, 0x4d, 0xc6, 0xe0, 0x69,
+	0x89, 0xa2, 0x08, 0x98, 0xa7, 0xa5, 0x39, 0x79, 0xcf, 0xd3, 0x52, 0x54, 0x92, 0x40, 0x40, 0xa6,
+	0xd0, 0x8e, 0xb9, 0x42, 0x2d, 0xf8, 0xcc, 0x2c, 0x2b, 0x11, 0xe4, 0x45, 0x12, 0x09, 0x14, 0x41,
+	0x80, 0x22, 0xf8, 0xd5, 0x60, 0xe3, 0x24, 0xc9, 0xf2, 0x52, 0xa3, 0x02, 0x02, 0x66, 0x81, 0xe9,
+	0x3f, 0x0e, 0x8a, 0x55, 0xae, 0xeb, 0x3f, 0x32, 0x28, 0xdc, 0x2f, 0x20, 0xb0, 0xdb, 0x4e, 0xbe,
+	0x80, 0x97, 0x0d, 0x91, 0xfb, 0xa5, 0x9a, 0xd6, 0x4e, 0x92, 0xed, 0xc4, 0x03, 0x76, 0xdb, 0x67,
+	0x16, 0x2c, 0xfe, 0xf5, 0xa0, 0xc7, 0x6c, 0xdf, 0xc9, 0x6b, 0x98, 0x9e, 0x36, 0xcb, 0x15, 0x66,
+	0x72, 0xd2, 0xaa, 0x93, 0x70, 0xbc, 0x0f, 0x85, 0xd3, 0x7e, 0x1a, 0x4e, 0x53, 0xb1, 0xa1, 0x0d,
+	0xcd, 0xce, 0x57, 0x3d, 0x8a, 0x9f, 0x0c, 0x6f, 0xe6, 0xd2, 0x68, 0xbd, 0x54, 0x58, 0x59, 0x9f,
+	0x39, 0x64, 0xc2, 0xc8, 0xa5, 0xd2, 0xa1, 0x81, 0xc2, 0x4d, 0xdb, 0xc0, 0x30, 0x37, 0x86, 0x68,
+	0xcc, 0x53, 0xaf, 0x39, 0x4f, 0x57, 0xf0, 0x51, 0x54, 0x1e, 0xca, 0x94, 0xeb, 0xe4, 0x5e, 0x84,
+	0x27, 0x53, 0x77, 0x71, 0xdc, 0x5a, 0x3b, 0xfb, 0xd7, 0x30, 0x8d, 0x64, 0xa6, 0x0b, 0x1e, 0xe9,
+	0x90, 0xef, 0x76, 0x85, 0x50, 0xca, 0x75, 0x61, 0x52, 0xf1, 0xef, 0x2d, 0x4d, 0x5e, 0x81, 0x9f,
+	0xca, 0x58, 0x51, 0x40, 0xf1, 0x0e, 0xac, 0x78, 0xaf, 0x65, 0xcc, 0x90, 0x5e, 0x70, 0x68, 0x5f,
+	0xcb, 0xd8, 0xcc, 0x4c, 0xe5, 0xc7, 0xd6, 0xb8, 0x82, 0x26, 0x59, 0x2d, 0xf3, 0x24, 0x52, 0xd4,
+	0xc3, 0x39, 0x71, 0xc8, 0x28, 0x70, 0xc7, 0x35, 0xaf, 0x14, 0x68, 0xd6, 0xc7, 0xa6, 0xfa, 0xf5,
+	0xa6, 0xfe, 0x0c, 0xe3, 0x15, 0x4f, 0x79, 0x16, 0x89, 0xe3, 0x23, 0xfe, 0xdc, 0x6d, 0xc7, 0xe7,
+	0xdd, 0x6b, 0x3e, 0xef, 0xc7, 0xb7, 0xf9, 0x3b, 0xe8, 0x39, 0x77, 0xc6, 0xcf, 0xd6, 0x2e, 0x2b,
+	0x3f, 0x0e, 0x92, 0xcb, 0xa6, 0x9f, 0xca, 0xcb, 0xdb, 0x7f, 0x3c, 0xf0, 0xdf, 0xdf, 0x95, 0x9c,
+	0xbc, 0x81, 0xfe, 0x5a, 0x68, 0xf7, 0x38, 0xdb, 0xa2, 0xd4, 0xff, 0x99, 0x97, 0xc3, 0x1a, 0x47,
+	0xbe, 0x87, 0xf1, 0x5a, 0xe8, 0xfa, 0xa4, 0xd2, 0xa7, 0x8f, 0x80, 0x3b, 0xf8, 0xf4, 0x79, 0x20,
+	0xef, 0x00, 0xd6, 0x42, 0x57, 0xba, 0x7e, 0xfe, 0xe8, 0xc8, 0xee, 0x54, 0x86, 0xdf, 0xe0, 0xb1,
+	0x2a, 0xd7, 0x99, 0x0b, 0xa8, 0x51, 0xc9, 0x97, 0xa3, 0x06, 0x4b, 0x7e, 0x80, 0xc9, 0xc9, 0xbf,
+	0x45, 0x3e, 0xb5, 0x16, 0xe7, 0xbf, 0xb3, 0x46, 0x9a, 0x5f, 0xb7, 0x56, 0xdd, 0x3f, 0xfc, 0xb8,
+	0xc8, 0xa3, 0x6d, 0x17, 0xbf, 0xe0, 0x6f, 0xff, 0x1f, 0x00, 0x2d, 0xeb, 0x47, 0xd3, 0x90, 0x07,
+	0x00, 0x00,
+}
@@ -0,0 +1,245 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:generate protoc --go_out=plugins=grpc:. aqua.proto
+
+// Package grpc implements a gRPC server for the chain data of an AquaChain
+// node, serving typed block, transaction, receipt and balance queries and
+// block streams to backend services. The schema is defined in aqua.proto.
+package grpc
+
+import (
+	"math/big"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is the chain access needed by the server, implemented by the API
+// backend of the AquaChain service.
+type Backend interface {
+	ChainDb() aquadb.Database
+	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
+	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
+	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
+	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Server implements AquaServer on top of a Backend.
+type Server struct {
+	b Backend
+}
+
+// NewServer creates a server answering queries from the given backend.
+func NewServer(b Backend) *Server {
+	return &Server{b: b}
+}
+
+// GetBlock implements AquaServer, returning a block by number or hash, or the
+// head block.
+func (s *Server) GetBlock(ctx context.Context, req *BlockRequest) (*Block, error) {
+	var (
+		block *types.Block
+		err   error
+	)
+	switch sel := req.Block.(type) {
+	case *BlockRequest_Number:
+		block, err = s.b.BlockByNumber(ctx, rpc.BlockNumber(sel.Number))
+	case *BlockRequest_Hash:
+		block, err = s.b.GetBlock(ctx, common.BytesToHash(sel.Hash))
+	default:
+		block, err = s.b.BlockByNumber(ctx, rpc.LatestBlockNumber)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if block == nil {
+		return nil, status.Error(codes.NotFound, "unknown block")
+	}
+	return newBlock(block, req.FullTransactions), nil
+}
+
+// GetTransaction implements AquaServer, returning a mined transaction.
+func (s *Server) GetTransaction(ctx context.Context, req *TransactionRequest) (*Transaction, error) {
+	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), common.BytesToHash(req.Hash))
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "unknown transaction")
+	}
+	return newTransaction(tx, blockHash, blockNumber, index), nil
+}
+
+// GetReceipt implements AquaServer, returning the receipt of a mined
+// transaction.
+func (s *Server) GetReceipt(ctx context.Context, req *TransactionRequest) (*Receipt, error) {
+	hash := common.BytesToHash(req.Hash)
+	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash)
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "unknown transaction")
+	}
+	receipts, err := s.b.GetReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if len(receipts) <= int(index) {
+		return nil, status.Error(codes.NotFound, "unknown receipt")
+	}
+	receipt := receipts[index]
+	result := &Receipt{
+		TransactionHash:   hash.Bytes(),
+		BlockHash:         blockHash.Bytes(),
+		BlockNumber:       blockNumber,
+		TransactionIndex:  index,
+		Status:            uint64(receipt.Status),
+		PostState:         receipt.PostState,
+		GasUsed:           receipt.GasUsed,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		result.ContractAddress = receipt.ContractAddress.Bytes()
+	}
+	for _, log := range receipt.Logs {
+		topics := make([][]byte, len(log.Topics))
+		for i, topic := range log.Topics {
+			topics[i] = topic.Bytes()
+		}
+		result.Logs = append(result.Logs, &Log{
+			Address: log.Address.Bytes(),
+			Topics:  topics,
+			Data:    log.Data,
+			Index:   uint64(log.Index),
+		})
+	}
+	return result, nil
+}
+
+// GetBalance implements AquaServer, returning the balance of an account at the
+// requested or head block.
+func (s *Server) GetBalance(ctx context.Context, req *BalanceRequest) (*Balance, error) {
+	if len(req.Address) != common.AddressLength {
+		return nil, status.Error(codes.InvalidArgument, "invalid address")
+	}
+	number := rpc.LatestBlockNumber
+	if sel, ok := req.Block.(*BalanceRequest_Number); ok {
+		number = rpc.BlockNumber(sel.Number)
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if state == nil {
+		return nil, status.Error(codes.NotFound, "unknown block")
+	}
+	balance := state.GetBalance(common.BytesToAddress(req.Address))
+	return &Balance{Balance: balance.Bytes(), Number: header.Number.Uint64()}, state.Error()
+}
+
+// SubscribeBlocks implements AquaServer, streaming the new head blocks until
+// the client goes away.
+func (s *Server) SubscribeBlocks(req *SubscribeBlocksRequest, stream Aqua_SubscribeBlocksServer) error {
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := s.b.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-heads:
+			if err := stream.Send(newBlock(head.Block, req.FullTransactions)); err != nil {
+				return err
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				return status.Error(codes.Unavailable, "chain closed")
+			}
+			return err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// newBlock converts a block to its protobuf representation.
+func newBlock(block *types.Block, fullTx bool) *Block {
+	head := block.Header()
+	result := &Block{
+		Hash:             block.Hash().Bytes(),
+		ParentHash:       head.ParentHash.Bytes(),
+		Number:           head.Number.Uint64(),
+		Time:             head.Time.Uint64(),
+		Miner:            head.Coinbase.Bytes(),
+		Difficulty:       bigBytes(head.Difficulty),
+		GasLimit:         head.GasLimit,
+		GasUsed:          head.GasUsed,
+		StateRoot:        head.Root.Bytes(),
+		TransactionsRoot: head.TxHash.Bytes(),
+		ReceiptsRoot:     head.ReceiptHash.Bytes(),
+		Extra:            head.Extra,
+		Nonce:            block.Nonce(),
+		Version:          uint32(head.Version),
+	}
+	for i, tx := range block.Transactions() {
+		if fullTx {
+			result.Transactions = append(result.Transactions, newTransaction(tx, block.Hash(), block.NumberU64(), uint64(i)))
+		} else {
+			result.TransactionHashes = append(result.TransactionHashes, tx.Hash().Bytes())
+		}
+	}
+	return result
+}
+
+// newTransaction converts a transaction to its protobuf representation.
+func newTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber, index uint64) *Transaction {
+	var signer types.Signer = types.FrontierSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, _ := types.Sender(signer, tx)
+
+	result := &Transaction{
+		Hash:     tx.Hash().Bytes(),
+		Nonce:    tx.Nonce(),
+		From:     from.Bytes(),
+		Value:    bigBytes(tx.Value()),
+		Gas:      tx.Gas(),
+		GasPrice: bigBytes(tx.GasPrice()),
+		Input:    tx.Data(),
+	}
+	if to := tx.To(); to != nil {
+		result.To = to.Bytes()
+	}
+	if blockHash != (common.Hash{}) {
+		result.BlockHash = blockHash.Bytes()
+		result.BlockNumber = blockNumber
+		result.Index = index
+	}
+	return result
+}
+
+// bigBytes returns the big-endian bytes of x, nil if x is nil.
+func bigBytes(x *big.Int) []byte {
+	if x == nil {
+		return nil
+	}
+	return x.Bytes()
+}
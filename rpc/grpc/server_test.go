@@ -0,0 +1,237 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package grpc
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"gitlab.com/aquachain/aquachain/aqua/event"
+	"gitlab.com/aquachain/aquachain/aquadb"
+	"gitlab.com/aquachain/aquachain/common"
+	"gitlab.com/aquachain/aquachain/consensus/aquahash"
+	"gitlab.com/aquachain/aquachain/core"
+	"gitlab.com/aquachain/aquachain/core/state"
+	"gitlab.com/aquachain/aquachain/core/types"
+	"gitlab.com/aquachain/aquachain/core/vm"
+	"gitlab.com/aquachain/aquachain/crypto"
+	"gitlab.com/aquachain/aquachain/params"
+	"gitlab.com/aquachain/aquachain/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testPayee   = common.HexToAddress("0x1a2b3c")
+	testBalance = big.NewInt(1000000)
+)
+
+// testBackend serves the chain data of a blockchain.
+type testBackend struct {
+	db    aquadb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) ChainDb() aquadb.Database { return b.db }
+
+func (b *testBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	if blockNr == rpc.LatestBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(blockNr)), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(blockHash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	return core.GetBlockReceipts(b.db, blockHash, core.GetBlockNumber(b.db, blockHash)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, blockNr)
+	if block == nil {
+		return nil, nil, nil
+	}
+	state, err := b.chain.StateAt(block.Root())
+	return state, block.Header(), err
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
+// newTestServer starts a server for a chain whose first block holds a transfer,
+// returning a client connected to it and blocks to extend the chain with.
+func newTestServer(t *testing.T) (AquaClient, *core.BlockChain, []*types.Block, func()) {
+	var (
+		db    = aquadb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testAddr: {Balance: testBalance}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, aquahash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, aquahash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(0, testPayee, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testKey)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := chain.InsertChain(blocks[:2]); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterAquaServer(server, NewServer(&testBackend{db, chain}))
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAquaClient(conn), chain, blocks[2:], func() {
+		conn.Close()
+		server.Stop()
+		chain.Stop()
+	}
+}
+
+func TestQueries(t *testing.T) {
+	client, chain, _, stop := newTestServer(t)
+	defer stop()
+	ctx := context.Background()
+
+	// Blocks by number, by hash and the head block
+	block, err := client.GetBlock(ctx, &BlockRequest{Block: &BlockRequest_Number{Number: 1}, FullTransactions: true})
+	if err != nil {
+		t.Fatalf("failed to get block: %v", err)
+	}
+	want := chain.GetBlockByNumber(1)
+	if !bytes.Equal(block.Hash, want.Hash().Bytes()) || block.Number != 1 {
+		t.Errorf("block mismatch: have %x (#%d), want %x", block.Hash, block.Number, want.Hash())
+	}
+	if len(block.Transactions) != 1 || !bytes.Equal(block.Transactions[0].From, testAddr.Bytes()) {
+		t.Fatalf("transactions mismatch: have %v", block.Transactions)
+	}
+	txHash := block.Transactions[0].Hash
+
+	block, err = client.GetBlock(ctx, &BlockRequest{Block: &BlockRequest_Hash{Hash: want.Hash().Bytes()}})
+	if err != nil {
+		t.Fatalf("failed to get block by hash: %v", err)
+	}
+	if block.Number != 1 || len(block.TransactionHashes) != 1 || !bytes.Equal(block.TransactionHashes[0], txHash) {
+		t.Errorf("block by hash mismatch: have #%d with %x", block.Number, block.TransactionHashes)
+	}
+	if block, err = client.GetBlock(ctx, &BlockRequest{}); err != nil || block.Number != 2 {
+		t.Errorf("head block mismatch: have %v, %v, want #2", block, err)
+	}
+	_, err = client.GetBlock(ctx, &BlockRequest{Block: &BlockRequest_Hash{Hash: []byte{1}}})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown block error mismatch: have %v, want %v", err, codes.NotFound)
+	}
+
+	// Transactions and receipts
+	tx, err := client.GetTransaction(ctx, &TransactionRequest{Hash: txHash})
+	if err != nil {
+		t.Fatalf("failed to get transaction: %v", err)
+	}
+	if tx.BlockNumber != 1 || !bytes.Equal(tx.To, testPayee.Bytes()) || new(big.Int).SetBytes(tx.Value).Int64() != 1000 {
+		t.Errorf("transaction mismatch: have %v", tx)
+	}
+	receipt, err := client.GetReceipt(ctx, &TransactionRequest{Hash: txHash})
+	if err != nil {
+		t.Fatalf("failed to get receipt: %v", err)
+	}
+	if receipt.GasUsed != params.TxGas || receipt.BlockNumber != 1 || !bytes.Equal(receipt.TransactionHash, txHash) {
+		t.Errorf("receipt mismatch: have %v", receipt)
+	}
+	_, err = client.GetReceipt(ctx, &TransactionRequest{Hash: []byte{1}})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown receipt error mismatch: have %v, want %v", err, codes.NotFound)
+	}
+
+	// Balances at the head and earlier blocks
+	balance, err := client.GetBalance(ctx, &BalanceRequest{Address: testPayee.Bytes()})
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if new(big.Int).SetBytes(balance.Balance).Int64() != 1000 || balance.Number != 2 {
+		t.Errorf("balance mismatch: have %x at #%d, want 1000 at #2", balance.Balance, balance.Number)
+	}
+	balance, err = client.GetBalance(ctx, &BalanceRequest{Address: testAddr.Bytes(), Block: &BalanceRequest_Number{Number: 0}})
+	if err != nil {
+		t.Fatalf("failed to get genesis balance: %v", err)
+	}
+	if new(big.Int).SetBytes(balance.Balance).Cmp(testBalance) != 0 {
+		t.Errorf("genesis balance mismatch: have %x, want %v", balance.Balance, testBalance)
+	}
+	_, err = client.GetBalance(ctx, &BalanceRequest{Address: []byte{1}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid address error mismatch: have %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	client, chain, blocks, stop := newTestServer(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.SubscribeBlocks(ctx, &SubscribeBlocksRequest{})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	// The subscription is set up asynchronously, keep extending the chain
+	// until blocks are delivered.
+	go func() {
+		for _, block := range blocks {
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil || ctx.Err() != nil {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive block: %v", err)
+	}
+	for i := uint64(1); i < 3; i++ {
+		block, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("failed to receive block: %v", err)
+		}
+		if block.Number != first.Number+i {
+			t.Errorf("block %d: number mismatch: have %d, want %d", i, block.Number, first.Number+i)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package grpc
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	"gitlab.com/aquachain/aquachain/aqua"
+	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/node"
+	"gitlab.com/aquachain/aquachain/p2p"
+	"gitlab.com/aquachain/aquachain/rpc"
+	"google.golang.org/grpc"
+)
+
+// Config contains the settings of the gRPC server.
+type Config struct {
+	Addr string // Listening address of the server (empty = disabled)
+}
+
+// Service serves the chain data of the AquaChain service running in the same
+// node over gRPC.
+type Service struct {
+	config  Config
+	backend Backend
+	server  *grpc.Server
+}
+
+// New creates a gRPC server for the AquaChain service running in the same
+// node.
+func New(ctx *node.ServiceContext, config Config) (*Service, error) {
+	var aquachain *aqua.AquaChain
+	if err := ctx.Service(&aquachain); err != nil {
+		return nil, fmt.Errorf("the gRPC server requires a full node: %v", err)
+	}
+	return &Service{config: config, backend: aquachain.ApiBackend}, nil
+}
+
+// Dependencies implements node.DependentService, making sure the server is
+// stopped before the chain is.
+func (s *Service) Dependencies() []reflect.Type {
+	return []reflect.Type{reflect.TypeOf((*aqua.AquaChain)(nil))}
+}
+
+// Protocols implements node.Service, returning no p2p protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to accept gRPC clients.
+func (s *Service) Start(*p2p.Server) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.server = grpc.NewServer()
+	RegisterAquaServer(s.server, NewServer(s.backend))
+	go s.server.Serve(listener)
+	log.Info("gRPC server started", "addr", listener.Addr())
+	return nil
+}
+
+// Stop implements node.Service, closing all client connections.
+func (s *Service) Stop() error {
+	s.server.Stop()
+	log.Info("gRPC server stopped")
+	return nil
+}
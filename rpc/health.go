@@ -0,0 +1,68 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Health is the status of a node reported on the /health and /ready paths of
+// the HTTP server.
+type Health struct {
+	Syncing     bool    `json:"syncing"`
+	Peers       int     `json:"peers"`
+	BlockNumber uint64  `json:"blockNumber"`
+	BlockAge    float64 `json:"blockAge"` // seconds since the head block was mined
+	Ready       bool    `json:"ready"`    // whether the node should be sent requests
+}
+
+// HealthCheck reports the current health of a node.
+type HealthCheck func() Health
+
+// SetHealthCheck enables the /health and /ready paths of the HTTP server,
+// which answer GET requests with the status reported by check. /health
+// responds with 200 OK while the server is up, /ready with 503 Service
+// Unavailable unless the node is ready. HEAD requests get the same status
+// without a body. Without a check, GET and HEAD requests to any path are
+// answered with an empty 200 OK.
+func (s *Server) SetHealthCheck(check HealthCheck) {
+	s.healthCheck = check
+}
+
+// serveHealth answers health check requests, reporting whether it did.
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) bool {
+	if s.healthCheck == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	var ready bool
+	switch r.URL.Path {
+	case "/health":
+	case "/ready":
+		ready = true
+	default:
+		return false
+	}
+	health := s.healthCheck()
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("cache-control", "no-cache")
+	if ready && !health.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+	return true
+}
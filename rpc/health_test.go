@@ -0,0 +1,71 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHealth(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	health := Health{Peers: 3, BlockNumber: 100, BlockAge: 12}
+	server.SetHealthCheck(func() Health { return health })
+
+	tests := []struct {
+		path  string
+		ready bool
+		code  int
+	}{
+		{"/health", false, http.StatusOK},
+		{"/ready", false, http.StatusServiceUnavailable},
+		{"/health", true, http.StatusOK},
+		{"/ready", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		health.Ready = tt.ready
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s (ready %v): status %d, want %d", tt.path, tt.ready, w.Code, tt.code)
+		}
+		var got Health
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: invalid response %q: %v", tt.path, w.Body.String(), err)
+		}
+		if got != health {
+			t.Errorf("%s: reported %+v, want %+v", tt.path, got, health)
+		}
+	}
+	// HEAD requests only get the status.
+	health.Ready = false
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("HEAD /ready: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	// Other paths are left to the JSON-RPC handler.
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("GET /: status %d, body %q", w.Code, w.Body.String())
+	}
+}
@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -25,15 +26,26 @@ import (
 	"net/http"
 
 	"strings"
+	"time"
 
 	"github.com/rs/cors"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/tracing"
 	"gitlab.com/aquachain/aquachain/p2p/netutil"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
-	contentType                 = "application/json"
+	contentType = "application/json"
+
+	// maxHTTPRequestContentLength is the default limit of request bodies,
+	// see Server.SetMaxBodySize.
 	maxHTTPRequestContentLength = 1024 * 128
+
+	// allowedMethods lists the HTTP methods the server answers, for the
+	// Allow header.
+	allowedMethods = "GET, HEAD, POST, OPTIONS"
 )
 
 var nullAddr, _ = net.ResolveTCPAddr("tcp", "127.0.0.1:0")
@@ -53,45 +65,98 @@ func (t *httpReadWriteNopCloser) Close() error {
 //
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, vhosts []string, allowIP []string, behindreverseproxy bool, srv *Server) *http.Server {
-	// Wrap the CORS-handler within a host-handler
-	handler := newCorsHandler(srv, cors)
+	// Check tokens within the CORS-handler, so preflights pass unauthenticated,
+	// and wrap the CORS-handler within a host-handler
+	var handler http.Handler = srv
+	if srv.jwtSecret != nil {
+		handler = newJWTHandler(srv.jwtSecret, httpAuthExempt, handler)
+	}
+	handler = newCorsHandler(handler, cors)
 	handler = newVHostHandler(vhosts, handler)
-	handler = newAllowIPHandler(allowIP, behindreverseproxy, handler)
-	return &http.Server{Handler: handler}
+	handler = newAllowIPHandler(allowIP, behindreverseproxy, srv.trustedProxies, handler)
+	srv.reverseproxy = behindreverseproxy
+	// Clients may multiplex their requests over a single HTTP/2 connection,
+	// with prior knowledge or by upgrading. Over TLS, net/http negotiates
+	// HTTP/2 by itself.
+	handler = h2c.NewHandler(handler, new(http2.Server))
+	return &http.Server{Handler: handler, TLSConfig: srv.tlsConfig}
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
 func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Permit dumb empty requests for remote health-checks (AWS)
-	if r.Method == http.MethodGet && r.ContentLength == 0 && r.URL.RawQuery == "" {
+	if srv.serveHealth(w, r) {
 		return
 	}
-	uip := getIP(r, srv.reverseproxy)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// Permit dumb empty requests for remote health-checks (AWS)
+		if r.ContentLength == 0 && r.URL.RawQuery == "" {
+			return
+		}
+	case http.MethodOptions:
+		// CORS preflights are answered by the CORS handler, if enabled
+		w.Header().Set("allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	uip := getIP(r, srv.reverseproxy, srv.trustedProxies)
+	ctx := context.Background()
+	if srv.accessLog != nil {
+		rec := &accessRecord{Time: time.Now(), IP: uip.String(), Status: http.StatusOK}
+		w = &accessLogWriter{w, rec}
+		ctx = context.WithValue(ctx, accessRecordKey{}, rec)
+		defer srv.accessLog.write(rec)
+	}
 	log.Debug("handling http request", "from", uip, "path", r.URL.Path, "ua", r.UserAgent(), "http", r.Method, "host", r.Host, "size", r.ContentLength)
-	if code, err := validateRequest(r); err != nil {
+	if code, err := validateRequest(r, srv.bodyLimit()); err != nil {
 		log.Debug("invalid request", "from", uip, "size", r.ContentLength)
+		if code == http.StatusMethodNotAllowed {
+			w.Header().Set("allow", allowedMethods)
+		}
 		http.Error(w, err.Error(), code)
 		return
 	}
 	// All checks passed, create a codec that reads direct from the request body
 	// untilEOF and writes the response to w and order the server to process a
 	// single request.
-	body := io.LimitReader(r.Body, maxHTTPRequestContentLength)
+	body := io.LimitReader(r.Body, srv.bodyLimit())
 	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
 	defer codec.Close()
 
 	w.Header().Set("content-type", contentType)
-	srv.ServeSingleRequest(codec, OptionMethodInvocation)
+
+	// Continue the trace of the caller, if any
+	ctx = tracing.ContextWithTraceParent(ctx, r.Header.Get("traceparent"))
+	ctx = withClientIP(ctx, uip)
+	srv.serveRequest(ctx, codec, true, OptionMethodInvocation)
+}
+
+// httpAuthExempt reports whether a request is answered without reaching the
+// APIs, so it needs no token: health checks, OPTIONS requests and empty GET or
+// HEAD probes.
+func httpAuthExempt(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodOptions:
+		return true
+	case http.MethodGet, http.MethodHead:
+		if r.URL.Path == "/health" || r.URL.Path == "/ready" {
+			return true
+		}
+		return r.ContentLength == 0 && r.URL.RawQuery == ""
+	}
+	return false
 }
 
 // validateRequest returns a non-zero response code and error message if the
-// request is invalid.
-func validateRequest(r *http.Request) (int, error) {
-	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+// request is invalid. Bodies larger than limit are rejected.
+func validateRequest(r *http.Request, limit int64) (int, error) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions:
+	default:
 		return http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxHTTPRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxHTTPRequestContentLength)
+	if r.ContentLength > limit {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, limit)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
@@ -102,18 +167,24 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(next http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
-		return srv
+		return next
 	}
-	c := cors.New(cors.Options{
+	options := cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{http.MethodPost, http.MethodGet},
+		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodHead},
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
-	})
-	return c.Handler(srv)
+	}
+	// Check origins like the websocket handshake does, with wildcard
+	// subdomains and scheme-less entries
+	if origins := newOriginList(allowedOrigins); !origins.all {
+		options.AllowOriginFunc = origins.allowed
+	}
+	c := cors.New(options)
+	return c.Handler(next)
 }
 
 // virtualHostHandler is a handler which validates the Host-header of incoming requests.
@@ -167,11 +238,22 @@ func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
 type allowIPHandler struct {
 	allowedIPs   *netutil.Netlist
 	next         http.Handler
-	reverseproxy bool // if behind a reverse proxy (uses X-FORWARDED-FOR header)
+	reverseproxy bool             // if behind a reverse proxy (uses X-FORWARDED-FOR header)
+	trusted      *netutil.Netlist // reverse proxies to accept the header from, nil for loopback only
 }
 
-func getIP(r *http.Request, reverseproxy bool) net.IP {
-	if reverseproxy {
+// getIP returns the IP address of the client sending a request. Behind a
+// reverse proxy, it is taken from the forwarding headers if the request comes
+// from one of the trusted proxies, or from a loopback peer if trusted is nil.
+func getIP(r *http.Request, reverseproxy bool, trusted *netutil.Netlist) net.IP {
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Either invalid (too many colons) or no port specified
+		remoteAddr = strings.Split(r.RemoteAddr, ":")[0]
+	}
+	peer := net.ParseIP(remoteAddr)
+
+	if reverseproxy && (trusted.Contains(peer) || (trusted == nil && peer.IsLoopback())) {
 		for _, h := range []string{"X-Forwarded-For", "X-Real-Ip"} {
 			addresses := strings.Split(r.Header.Get(h), ",")
 			// march from right to left until we get a public address
@@ -183,26 +265,21 @@ func getIP(r *http.Request, reverseproxy bool) net.IP {
 				if realIP == nil {
 					continue
 				}
-				if !realIP.IsGlobalUnicast() || netutil.IsLAN(realIP) || netutil.IsSpecialNetwork(realIP) {
-					// bad address, go to next
+				if !realIP.IsGlobalUnicast() || netutil.IsLAN(realIP) || netutil.IsSpecialNetwork(realIP) || trusted.Contains(realIP) {
+					// bad address or one of our proxies, go to next
 					continue
 				}
 
-				return net.ParseIP(ip)
+				return realIP
 			}
 		}
 	}
-	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		// Either invalid (too many colons) or no port specified
-		remoteAddr = strings.Split(r.RemoteAddr, ":")[0]
-	}
-	return net.ParseIP(remoteAddr)
+	return peer
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
 func (h *allowIPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ip := getIP(r, h.reverseproxy)
+	ip := getIP(r, h.reverseproxy, h.trusted)
 	log.Trace("checking vs allow IPs", "ip", ip)
 	if h.allowedIPs.Contains(ip) {
 		h.next.ServeHTTP(w, r)
@@ -212,10 +289,10 @@ func (h *allowIPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "", http.StatusForbidden)
 }
 
-func newAllowIPHandler(allowIPmasks []string, behindreverseproxy bool, next http.Handler) http.Handler {
+func newAllowIPHandler(allowIPmasks []string, behindreverseproxy bool, trusted *netutil.Netlist, next http.Handler) http.Handler {
 	var allowIPMap = new(netutil.Netlist)
 	for i := range allowIPmasks {
 		allowIPMap.Add(allowIPmasks[i])
 	}
-	return &allowIPHandler{allowIPMap, next, behindreverseproxy}
+	return &allowIPHandler{allowIPMap, next, behindreverseproxy, trusted}
 }
@@ -17,10 +17,15 @@
 package rpc
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"golang.org/x/net/http2"
 )
 
 func TestHTTPErrorResponseWithDelete(t *testing.T) {
@@ -31,12 +36,134 @@ func TestHTTPErrorResponseWithPut(t *testing.T) {
 	testHTTPErrorResponse(t, http.MethodPut, contentType, "", http.StatusMethodNotAllowed)
 }
 
+func TestHTTPErrorResponseWithPatch(t *testing.T) {
+	testHTTPErrorResponse(t, http.MethodPatch, contentType, "", http.StatusMethodNotAllowed)
+}
+
+func TestHTTPOptionsAndHead(t *testing.T) {
+	srv := NewServer()
+	defer srv.Stop()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "http://url.com", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("allow") != allowedMethods {
+		t.Errorf("OPTIONS: status %d, allow %q", w.Code, w.Header().Get("allow"))
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "http://url.com", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("HEAD: status %d, body %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "http://url.com", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("allow") != allowedMethods {
+		t.Errorf("PATCH: status %d, allow %q", w.Code, w.Header().Get("allow"))
+	}
+}
+
+func TestHTTP2Cleartext(t *testing.T) {
+	srv := NewServer()
+	defer srv.Stop()
+	server := httptest.NewServer(NewHTTPServer(nil, []string{"*"}, []string{"127.0.0.1/8"}, false, srv).Handler)
+	defer server.Close()
+
+	// Connect with prior knowledge of HTTP/2 support
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, config *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`)
+	resp, err := client.Post(server.URL, contentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("response protocol %s, want HTTP/2", resp.Proto)
+	}
+	var result struct{ Result map[string]string }
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result.Result[MetadataApi]; !ok {
+		t.Errorf("modules %v lack %s", result.Result, MetadataApi)
+	}
+}
+
 func TestHTTPErrorResponseWithMaxContentLength(t *testing.T) {
 	body := make([]rune, maxHTTPRequestContentLength+1)
 	testHTTPErrorResponse(t,
 		http.MethodPost, contentType, string(body), http.StatusRequestEntityTooLarge)
 }
 
+func TestHTTPMaxBodySize(t *testing.T) {
+	body := strings.Repeat(" ", maxHTTPRequestContentLength+1) + `{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`
+	serve := func(srv *Server) int {
+		request := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		recorder := httptest.NewRecorder()
+		srv.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	srv := NewServer()
+	defer srv.Stop()
+	if code := serve(srv); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("default limit: response code should be %d not %d", http.StatusRequestEntityTooLarge, code)
+	}
+	srv.SetMaxBodySize(2 * maxHTTPRequestContentLength)
+	if code := serve(srv); code != http.StatusOK {
+		t.Errorf("raised limit: response code should be %d not %d", http.StatusOK, code)
+	}
+}
+
+func TestGetIPTrustedProxies(t *testing.T) {
+	srv := NewServer()
+	if err := srv.SetTrustedProxies([]string{"10.0.0.0/8", "5.6.7.8/32"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote, forwarded string
+		want              string
+	}{
+		// Headers of trusted proxies are honored, skipping chained proxies.
+		{"10.1.2.3:1234", "1.1.1.1", "1.1.1.1"},
+		{"10.1.2.3:1234", "1.1.1.1, 5.6.7.8", "1.1.1.1"},
+		// Headers of anyone else are ignored.
+		{"8.8.8.8:1234", "1.1.1.1", "8.8.8.8"},
+		{"5.6.7.9:1234", "1.1.1.1", "5.6.7.9"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("X-Forwarded-For", tt.forwarded)
+		if ip := getIP(r, true, srv.trustedProxies); !ip.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("remote %s, forwarded %q: got %v, want %s", tt.remote, tt.forwarded, ip, tt.want)
+		}
+	}
+	if err := srv.SetTrustedProxies([]string{"10.0.0.1"}); err == nil {
+		t.Error("expected error for mask without prefix length")
+	}
+	// Without trusted proxies, only the headers of loopback peers are honored.
+	if err := srv.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ remote, want string }{
+		{"127.0.0.1:1234", "1.1.1.1"},
+		{"[::1]:1234", "1.1.1.1"},
+		{"10.1.2.3:1234", "10.1.2.3"},
+		{"8.8.8.8:1234", "8.8.8.8"},
+	} {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("X-Forwarded-For", "1.1.1.1")
+		if ip := getIP(r, true, srv.trustedProxies); !ip.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("default proxies, remote %s: got %v, want %s", tt.remote, ip, tt.want)
+		}
+	}
+}
+
 func TestHTTPErrorResponseWithEmptyContentType(t *testing.T) {
 	testHTTPErrorResponse(t, http.MethodPost, "", "", http.StatusUnsupportedMediaType)
 }
@@ -48,7 +175,7 @@ func TestHTTPErrorResponseWithValidRequest(t *testing.T) {
 func testHTTPErrorResponse(t *testing.T, method, contentType, body string, expected int) {
 	request := httptest.NewRequest(method, "http://url.com", strings.NewReader(body))
 	request.Header.Set("content-type", contentType)
-	if code, _ := validateRequest(request); code != expected {
+	if code, _ := validateRequest(request, maxHTTPRequestContentLength); code != expected {
 		t.Fatalf("response code should be %d not %d", expected, code)
 	}
 }
@@ -19,14 +19,28 @@ package rpc
 import (
 	"fmt"
 	"net"
+	"os"
 
 	"gitlab.com/aquachain/aquachain/common/log"
 )
 
+// IPCPermissions configures the access to a unix socket created by
+// CreateIPCListenerPerm. Named pipes on Windows ignore it.
+type IPCPermissions struct {
+	Mode  os.FileMode // file mode of the socket, 0 for owner-only access (0600)
+	Group string      // name or id of the group to own the socket, empty for the default
+}
+
 // CreateIPCListener creates an listener, on Unix platforms this is a unix socket, on
 // Windows this is a named pipe
 func CreateIPCListener(endpoint string) (net.Listener, error) {
-	return ipcListen(endpoint)
+	return ipcListen(endpoint, IPCPermissions{})
+}
+
+// CreateIPCListenerPerm is like CreateIPCListener, setting the file mode and
+// the group of a unix socket.
+func CreateIPCListenerPerm(endpoint string, perm IPCPermissions) (net.Listener, error) {
+	return ipcListen(endpoint, perm)
 }
 
 // ServeListener accepts connections on l, serving JSON-RPC on them.
@@ -19,13 +19,16 @@
 package rpc
 
 import (
+	"fmt"
 	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 )
 
 // ipcListen will create a Unix socket on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+func ipcListen(endpoint string, perm IPCPermissions) (net.Listener, error) {
 	// Ensure the IPC path exists and remove any previous leftover
 	if err := os.MkdirAll(filepath.Dir(endpoint), 0751); err != nil {
 		return nil, err
@@ -35,6 +38,37 @@ func ipcListen(endpoint string) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
-	os.Chmod(endpoint, 0600)
+	mode := perm.Mode
+	if mode == 0 {
+		mode = 0600
+	}
+	if err := os.Chmod(endpoint, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if perm.Group != "" {
+		gid, err := lookupGroup(perm.Group)
+		if err == nil {
+			err = os.Chown(endpoint, -1, gid)
+		}
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("can't set group of IPC socket: %v", err)
+		}
+	}
 	return l, nil
 }
+
+// lookupGroup resolves a group name or numeric id.
+func lookupGroup(group string) (int, error) {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		if _, numErr := strconv.Atoi(group); numErr != nil {
+			return 0, err
+		}
+		if g, err = user.LookupGroupId(group); err != nil {
+			return 0, err
+		}
+	}
+	return strconv.Atoi(g.Gid)
+}
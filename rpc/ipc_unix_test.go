@@ -0,0 +1,63 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build darwin || dragonfly || freebsd || linux || nacl || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux nacl netbsd openbsd solaris
+
+package rpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestIPCPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc-ipc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	check := func(perm IPCPermissions, mode os.FileMode, gid int) {
+		endpoint := filepath.Join(dir, "test.ipc")
+		l, err := CreateIPCListenerPerm(endpoint, perm)
+		if err != nil {
+			t.Fatalf("%+v: failed to create listener: %v", perm, err)
+		}
+		defer l.Close()
+
+		info, err := os.Stat(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := info.Mode().Perm(); have != mode {
+			t.Errorf("%+v: have mode %o, want %o", perm, have, mode)
+		}
+		if have := int(info.Sys().(*syscall.Stat_t).Gid); have != gid {
+			t.Errorf("%+v: have group %d, want %d", perm, have, gid)
+		}
+	}
+	check(IPCPermissions{}, 0600, os.Getgid())
+	check(IPCPermissions{Mode: 0660, Group: strconv.Itoa(os.Getgid())}, 0660, os.Getgid())
+
+	if _, err := CreateIPCListenerPerm(filepath.Join(dir, "bad.ipc"), IPCPermissions{Group: "no-such-group-exists"}); err == nil {
+		t.Error("unknown group accepted")
+	}
+}
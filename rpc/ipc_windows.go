@@ -24,7 +24,8 @@ import (
 	"gopkg.in/natefinch/npipe.v2"
 )
 
-// ipcListen will create a named pipe on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+// ipcListen will create a named pipe on the given endpoint. Permissions of unix
+// sockets do not apply to named pipes.
+func ipcListen(endpoint string, perm IPCPermissions) (net.Listener, error) {
 	return npipe.Listen(endpoint)
 }
@@ -0,0 +1,36 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the meters and timers used by the RPC server.
+
+package rpc
+
+import (
+	"gitlab.com/aquachain/aquachain/common/metrics"
+)
+
+var (
+	rpcRequestMeter = metrics.NewRegisteredMeter("rpc/requests", nil)
+	rpcSuccessMeter = metrics.NewRegisteredMeter("rpc/success", nil)
+	rpcFailureMeter = metrics.NewRegisteredMeter("rpc/failure", nil)
+	rpcServingTimer = metrics.NewRegisteredTimer("rpc/duration", nil)
+	rpcLimitedMeter = metrics.NewRegisteredMeter("rpc/limited", nil)
+	rpcTimeoutMeter = metrics.NewRegisteredMeter("rpc/timeouts", nil)
+
+	rpcCacheHitMeter   = metrics.NewRegisteredMeter("rpc/cache/hits", nil)
+	rpcCacheMissMeter  = metrics.NewRegisteredMeter("rpc/cache/misses", nil)
+	rpcCachePurgeMeter = metrics.NewRegisteredMeter("rpc/cache/purges", nil)
+)
@@ -0,0 +1,106 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/url"
+	"strings"
+)
+
+// originPattern is a single entry of an origin allow-list.
+type originPattern struct {
+	scheme   string // empty matches any scheme
+	host     string // host, with the port if any
+	wildcard bool   // if set, subdomains of host match instead of host itself
+}
+
+// originList checks the Origin header of browser requests against an
+// allow-list, used for both CORS and the websocket handshake. Entries are
+// either "*" for all origins, exact origins like "https://mydapp.com" or
+// "mydapp.com" for any scheme, or wildcards like "https://*.mydapp.com"
+// matching all subdomains of a domain.
+type originList struct {
+	all      bool
+	patterns []originPattern
+}
+
+func newOriginList(origins []string) *originList {
+	list := new(originList)
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			list.all = true
+			continue
+		}
+		var pattern originPattern
+		if i := strings.Index(origin, "://"); i >= 0 {
+			pattern.scheme, origin = origin[:i], origin[i+3:]
+		}
+		if strings.HasPrefix(origin, "*.") {
+			pattern.wildcard, origin = true, origin[2:]
+		}
+		pattern.host = strings.TrimSuffix(origin, "/")
+		list.patterns = append(list.patterns, pattern)
+	}
+	return list
+}
+
+// empty returns whether the list allows no origin at all.
+func (l *originList) empty() bool {
+	return !l.all && len(l.patterns) == 0
+}
+
+// allowed returns whether the given Origin header value is on the list.
+func (l *originList) allowed(origin string) bool {
+	if l.all {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	for _, p := range l.patterns {
+		if p.scheme != "" && p.scheme != u.Scheme {
+			continue
+		}
+		if p.wildcard && strings.HasSuffix(u.Host, "."+p.host) || !p.wildcard && u.Host == p.host {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the list in its configuration syntax.
+func (l *originList) String() string {
+	if l.all {
+		return "*"
+	}
+	entries := make([]string, len(l.patterns))
+	for i, p := range l.patterns {
+		if p.scheme != "" {
+			entries[i] = p.scheme + "://"
+		}
+		if p.wildcard {
+			entries[i] += "*."
+		}
+		entries[i] += p.host
+	}
+	return strings.Join(entries, ",")
+}
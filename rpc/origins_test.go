@@ -0,0 +1,79 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOriginList(t *testing.T) {
+	list := newOriginList([]string{"https://mydapp.com", "*.Wallet.org", "https://*.mydapp.com", "localhost:8080"})
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://mydapp.com", true},
+		{"http://mydapp.com", false},
+		{"https://app.mydapp.com", true},
+		{"https://a.b.mydapp.com", true},
+		{"http://app.mydapp.com", false},
+		{"https://evilmydapp.com", false},
+		{"https://mydapp.com.evil.com", false},
+		{"https://app.mydapp.com:8443", false},
+		{"http://x.wallet.org", true},
+		{"https://X.WALLET.ORG", true},
+		{"https://wallet.org", false},
+		{"http://localhost:8080", true},
+		{"http://localhost", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if allowed := list.allowed(tt.origin); allowed != tt.allowed {
+			t.Errorf("origin %q: have allowed %v, want %v", tt.origin, allowed, tt.allowed)
+		}
+	}
+	if !newOriginList([]string{"https://mydapp.com", "*"}).allowed("http://anything") {
+		t.Error("'*' does not allow all origins")
+	}
+	if !newOriginList([]string{"", " "}).empty() {
+		t.Error("list of blank entries not empty")
+	}
+}
+
+func TestHTTPCorsWildcard(t *testing.T) {
+	srv := NewServer()
+	defer srv.Stop()
+	handler := NewHTTPServer([]string{"https://*.mydapp.com"}, []string{"*"}, []string{"127.0.0.1/8", "192.0.2.0/24"}, false, srv).Handler
+
+	for origin, want := range map[string]string{
+		"https://app.mydapp.com": "https://app.mydapp.com",
+		"https://evil.com":       "",
+	} {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+		request.Header.Set("content-type", contentType)
+		request.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if have := recorder.Header().Get("Access-Control-Allow-Origin"); have != want {
+			t.Errorf("origin %s: have allowed origin %q, want %q", origin, have, want)
+		}
+	}
+}
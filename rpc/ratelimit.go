@@ -0,0 +1,165 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitedClients caps the number of client IPs tracked by the rate
+	// limiter. Clients beyond it share a bucket per method, so requests from
+	// many addresses can't exhaust the memory.
+	maxRateLimitedClients = 1 << 16
+
+	// rateLimitSweepInterval is how often buckets of idle clients are dropped.
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimits configures the throttling of RPC calls per client IP. Rates are
+// given in calls per second, allowing bursts of up to one second worth of
+// calls. Calls over IPC and in-process are never limited.
+type RateLimits struct {
+	// Default limits the calls of all methods not listed in Methods, which
+	// share a single budget per client. Zero leaves them unlimited.
+	Default float64
+
+	// Methods limits single methods, like "aqua_getLogs". Zero leaves a
+	// method unlimited, even if there is a default limit.
+	Methods map[string]float64
+}
+
+// clientIPKey is the context key of the IP address of the calling client.
+type clientIPKey struct{}
+
+// withClientIP returns a copy of ctx carrying the IP address of the client,
+// subjecting its calls to the rate limits of the server.
+func withClientIP(ctx context.Context, ip net.IP) context.Context {
+	if ip == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// rateLimiter is a token bucket rate limiter keyed by client IP and method.
+type rateLimiter struct {
+	limits RateLimits
+
+	mu        sync.Mutex
+	buckets   map[rateKey]*rateBucket
+	overflow  map[string]*rateBucket // shared by clients not fitting in buckets
+	lastSweep time.Time
+}
+
+type rateKey struct {
+	ip     string
+	method string // empty for the bucket shared by all unlisted methods
+}
+
+type rateBucket struct {
+	rate   float64   // tokens added per second
+	tokens float64   // tokens available
+	last   time.Time // time tokens were last refilled
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	if limits.Default <= 0 && len(limits.Methods) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limits:   limits,
+		buckets:  make(map[rateKey]*rateBucket),
+		overflow: make(map[string]*rateBucket),
+	}
+}
+
+// allow takes a token from the bucket of the given client and method,
+// reporting whether one was available.
+func (l *rateLimiter) allow(ip net.IP, method string, now time.Time) bool {
+	rate, listed := l.limits.Methods[method]
+	if !listed {
+		rate, method = l.limits.Default, ""
+	}
+	if rate <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+	key := rateKey{string(ip.To16()), method}
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxRateLimitedClients {
+			if b = l.overflow[method]; b == nil {
+				b = newRateBucket(rate, now)
+				l.overflow[method] = b
+			}
+			return b.take(now)
+		}
+		b = newRateBucket(rate, now)
+		l.buckets[key] = b
+	}
+	return b.take(now)
+}
+
+// sweep drops the buckets which would be full by now, they are no different
+// from the bucket of a new client.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds()*b.rate >= b.burst() {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func newRateBucket(rate float64, now time.Time) *rateBucket {
+	b := &rateBucket{rate: rate, last: now}
+	b.tokens = b.burst()
+	return b
+}
+
+// burst is the maximum number of tokens in the bucket.
+func (b *rateBucket) burst() float64 {
+	if b.rate < 1 {
+		return 1
+	}
+	return b.rate
+}
+
+// take refills the bucket for the time passed since the last call and takes a
+// token from it.
+func (b *rateBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if burst := b.burst(); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
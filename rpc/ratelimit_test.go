@@ -0,0 +1,94 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimits{
+		Default: 2,
+		Methods: map[string]float64{"aqua_getLogs": 1, "aqua_blockNumber": 0},
+	})
+	var (
+		now   = time.Unix(1000, 0)
+		ip1   = net.IP{10, 0, 0, 1}
+		ip2   = net.IP{10, 0, 0, 2}
+		allow = func(ip net.IP, method string) bool { return limiter.allow(ip, method, now) }
+	)
+	// Listed methods have their own budget, unlisted ones share the default
+	if !allow(ip1, "aqua_getLogs") || allow(ip1, "aqua_getLogs") {
+		t.Error("aqua_getLogs: want one call per second")
+	}
+	if !allow(ip1, "aqua_call") || !allow(ip1, "aqua_getBalance") || allow(ip1, "aqua_call") {
+		t.Error("unlisted methods: want two calls per second in total")
+	}
+	for i := 0; i < 100; i++ {
+		if !allow(ip1, "aqua_blockNumber") {
+			t.Fatal("aqua_blockNumber: want no limit")
+		}
+	}
+	// Other clients have their own budget
+	if !allow(ip2, "aqua_getLogs") {
+		t.Error("aqua_getLogs: second client limited by first")
+	}
+	// Budgets refill over time
+	now = now.Add(500 * time.Millisecond)
+	if allow(ip1, "aqua_getLogs") || !allow(ip1, "aqua_call") {
+		t.Error("after half a second: want one unlisted call and no aqua_getLogs")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !allow(ip1, "aqua_getLogs") {
+		t.Error("after a second: aqua_getLogs still limited")
+	}
+	// Idle clients are forgotten
+	now = now.Add(rateLimitSweepInterval)
+	allow(ip1, "aqua_call")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("bucket count after sweep mismatch: have %d, want 1", len(limiter.buckets))
+	}
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	srv := NewServer()
+	defer srv.Stop()
+	srv.SetRateLimits(RateLimits{Methods: map[string]float64{"rpc_modules": 1}})
+
+	call := func(remote string) string {
+		request := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+		request.Header.Set("content-type", contentType)
+		request.RemoteAddr = remote
+		recorder := httptest.NewRecorder()
+		srv.ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+	if resp := call("192.0.2.1:1000"); !strings.Contains(resp, `"result"`) {
+		t.Fatalf("first call failed: %s", resp)
+	}
+	if resp := call("192.0.2.1:1001"); !strings.Contains(resp, `"code":-32005`) {
+		t.Fatalf("second call not limited: %s", resp)
+	}
+	if resp := call("192.0.2.2:1000"); !strings.Contains(resp, `"result"`) {
+		t.Fatalf("call of other client failed: %s", resp)
+	}
+}
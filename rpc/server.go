@@ -18,15 +18,21 @@ package rpc
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	set "github.com/deckarep/golang-set"
 	"gitlab.com/aquachain/aquachain/common/log"
+	"gitlab.com/aquachain/aquachain/common/tracing"
+	"gitlab.com/aquachain/aquachain/p2p/netutil"
 )
 
 const MetadataApi = "rpc"
@@ -73,6 +79,102 @@ func (s *RPCService) Modules() map[string]string {
 	return modules
 }
 
+// SetMaxBodySize limits the size of HTTP request bodies and websocket messages
+// the server accepts. Zero selects the default of 128KB. It must be called
+// before the server handles any requests.
+func (s *Server) SetMaxBodySize(size int64) {
+	s.maxBodySize = size
+}
+
+// SetRateLimits throttles the calls of HTTP and websocket clients per IP
+// address and method. It must be called before the server handles any
+// requests.
+func (s *Server) SetRateLimits(limits RateLimits) {
+	s.limiter = newRateLimiter(limits)
+}
+
+// SetBatchLimits limits the number of calls in a batch request and the total
+// size of the batch response in bytes. Larger batches are rejected as a whole.
+// A call whose response would grow the batch response beyond its limit fails
+// with an error, as do the calls after it. Zero disables a limit. It must be called before the server handles
+// any requests.
+func (s *Server) SetBatchLimits(items, responseBytes int) {
+	s.batchItemLimit, s.batchResponseLimit = items, responseBytes
+}
+
+// SetJWTSecret makes the HTTP and websocket servers created by NewHTTPServer
+// and NewWSServer require a JSON web token signed with the given secret (HS256)
+// on every request. The token must be sent as a bearer token in the
+// Authorization header and carry an issue time (iat) within a minute of the
+// server's clock. It must be called before those servers are created.
+func (s *Server) SetJWTSecret(secret []byte) {
+	s.jwtSecret = secret
+}
+
+// SetTLSConfig makes the HTTP and websocket servers created by NewHTTPServer
+// and NewWSServer serve HTTPS and WSS with the given configuration when started
+// with ServeListener. It must be called before those servers are created.
+func (s *Server) SetTLSConfig(config *tls.Config) {
+	s.tlsConfig = config
+}
+
+// SetAccessLog makes the server log every HTTP request to l, nil disables the
+// access log. Websocket, IPC and in-process connections are not logged.
+func (s *Server) SetAccessLog(l *AccessLog) {
+	s.accessLog = l
+}
+
+// SetTrustedProxies sets the reverse proxies whose X-Forwarded-For and X-Real-IP
+// headers are honored to the peers within the given CIDR masks. By default only
+// the headers of loopback peers are honored when running behind a reverse proxy,
+// so that clients connecting directly cannot spoof their IP. It must be called
+// before the HTTP and websocket servers are created.
+func (s *Server) SetTrustedProxies(cidrs []string) error {
+	if len(cidrs) == 0 {
+		s.trustedProxies = nil
+		return nil
+	}
+	list, err := netutil.ParseNetlist(strings.Join(cidrs, ","))
+	if err != nil {
+		return err
+	}
+	s.trustedProxies = list
+	return nil
+}
+
+// SetTimeouts limits the execution time of regular calls, cancelling their
+// context and answering with a timeout error once it is exceeded. It must be
+// called before the server handles any requests.
+func (s *Server) SetTimeouts(timeouts Timeouts) {
+	s.timeouts = timeouts
+}
+
+// SetNotificationQueue limits the number of notifications of a subscription
+// waiting to be sent to the client. Subscriptions of clients not reading their
+// notifications fast enough are dropped once the limit is reached, sending the
+// client an error notification. Zero selects the default of 10000. It must be
+// called before the server handles any requests.
+func (s *Server) SetNotificationQueue(size int) {
+	s.notifyQueue = size
+}
+
+// notifyQueueSize returns the configured subscription queue size or the default.
+func (s *Server) notifyQueueSize() int {
+	if s.notifyQueue > 0 {
+		return s.notifyQueue
+	}
+	return defaultNotificationQueue
+}
+
+// bodyLimit returns the maximum size of HTTP request bodies and websocket
+// messages.
+func (s *Server) bodyLimit() int64 {
+	if s.maxBodySize > 0 {
+		return s.maxBodySize
+	}
+	return maxHTTPRequestContentLength
+}
+
 // RegisterName will create a service for the given rcvr type under the given name. When no methods on the given rcvr
 // match the criteria to be either a RPC method or a subscription an error is returned. Otherwise a new service is
 // created and added to the service collection this server instance serves.
@@ -122,7 +224,7 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 // If singleShot is true it will process a single request, otherwise it will handle
 // requests until the codec returns an error when reading a request (in most cases
 // an EOF). It executes requests in parallel when singleShot is false.
-func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption) error {
+func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot bool, options CodecOption) error {
 	var pend sync.WaitGroup
 
 	defer func() {
@@ -139,14 +241,14 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		}
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// if the codec supports notification include a notifier that callbacks can use
 	// to send notification to clients. It is thight to the codec/connection. If the
 	// connection is closed the notifier will stop and cancels all active subscriptions.
 	if options&OptionSubscriptions == OptionSubscriptions {
-		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec))
+		ctx = context.WithValue(ctx, notifierKey{}, newNotifier(codec, s.notifyQueueSize()))
 	}
 	s.codecsMu.Lock()
 	if atomic.LoadInt32(&s.run) != 1 { // server stopped
@@ -169,10 +271,19 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 			pend.Wait()
 			return nil
 		}
+		recordCalls(ctx, reqs, batch)
+		if batch && s.batchItemLimit > 0 && len(reqs) > s.batchItemLimit {
+			err := &invalidRequestError{fmt.Sprintf("batch too large (%d>%d calls)", len(reqs), s.batchItemLimit)}
+			codec.Write(codec.CreateErrorResponse(nil, err))
+			if singleShot {
+				return nil
+			}
+			continue
+		}
 
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
-		if atomic.LoadInt32(&s.run) != 1 {
+		if !s.startCall() {
 			err = &shutdownError{}
 			if batch {
 				resps := make([]interface{}, len(reqs))
@@ -187,6 +298,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
+			defer s.pending.Done()
 			if batch {
 				s.execBatch(ctx, codec, reqs)
 			} else {
@@ -199,6 +311,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 		go func(reqs []*serverRequest, batch bool) {
 			defer pend.Done()
+			defer s.pending.Done()
 			if batch {
 				s.execBatch(ctx, codec, reqs)
 			} else {
@@ -213,29 +326,78 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(context.Background(), codec, options)
+}
+
+// serveCodec is like ServeCodec, serving the requests within the given context.
+func (s *Server) serveCodec(ctx context.Context, codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(codec, false, options)
+	s.serveRequest(ctx, codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
 func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
-	s.serveRequest(codec, true, options)
+	s.serveRequest(context.Background(), codec, true, options)
 }
 
-// Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
-// close all codecs which will cancel pending requests/subscriptions.
+// startCall registers the execution of a (batch) request, unless the server
+// is stopped.
+func (s *Server) startCall() bool {
+	s.codecsMu.Lock()
+	defer s.codecsMu.Unlock()
+
+	if atomic.LoadInt32(&s.run) != 1 {
+		return false
+	}
+	s.pending.Add(1)
+	return true
+}
+
+// Stop will stop reading new requests and close all codecs, which will cancel
+// pending requests/subscriptions.
 func (s *Server) Stop() {
-	if atomic.CompareAndSwapInt32(&s.run, 1, 0) {
-		log.Debug("RPC Server shutdown initiatied")
-		s.codecsMu.Lock()
-		defer s.codecsMu.Unlock()
-		s.codecs.Each(func(c interface{}) bool {
-			c.(ServerCodec).Close()
-			return true
-		})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Shutdown(ctx)
+}
+
+// Shutdown stops the server gracefully. It stops reading new requests, waits
+// for the requests being executed to finish and then closes all codecs, ending
+// the active subscriptions. If ctx is done first, the codecs are closed right
+// away, cancelling the pending requests, and the context's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.codecsMu.Lock()
+	stopping := atomic.CompareAndSwapInt32(&s.run, 1, 0)
+	s.codecsMu.Unlock()
+	if !stopping {
+		return nil
 	}
+	log.Debug("RPC Server shutdown initiated")
+
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		default:
+			err = ctx.Err()
+		}
+	}
+	s.codecsMu.Lock()
+	defer s.codecsMu.Unlock()
+	s.codecs.Each(func(c interface{}) bool {
+		c.(ServerCodec).Close()
+		return true
+	})
+	return err
 }
 
 // createSubscription will call the subscription callback and returns the subscription id or error.
@@ -258,6 +420,17 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
 
+	if s.limiter != nil && !req.isUnsubscribe {
+		method := req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name)
+		if req.callb.isSubscribe {
+			method = req.svcname + subscribeMethodSuffix
+		}
+		if ip, ok := ctx.Value(clientIPKey{}).(net.IP); ok && !s.limiter.allow(ip, method, time.Now()) {
+			rpcLimitedMeter.Mark(1)
+			return codec.CreateErrorResponse(&req.id, &rateLimitError{method}), nil
+		}
+	}
+
 	if req.isUnsubscribe { // cancel subscription, first param must be the subscription id
 		if len(req.args) >= 1 && req.args[0].Kind() == reflect.String {
 			notifier, supported := NotifierFromContext(ctx)
@@ -298,6 +471,18 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	method := req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name)
+	ctx, span := tracing.StartServerSpan(ctx, "rpc."+method)
+	defer span.End()
+
+	// cancel calls running for longer than their method is allowed to
+	timeout := s.timeouts.timeout(method)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -306,19 +491,54 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		arguments = append(arguments, req.args...)
 	}
 
+	// serve the result from the cache if it cannot change anymore
+	var cacheKey string
+	if s.cache != nil {
+		var cached json.RawMessage
+		if cached, cacheKey = s.cache.get(method, req.args); cached != nil {
+			rpcRequestMeter.Mark(1)
+			rpcSuccessMeter.Mark(1)
+			return codec.CreateResponse(req.id, cached), nil
+		}
+	}
+
 	// execute RPC method and return result
-	reply := req.callb.method.Func.Call(arguments)
+	start := time.Now()
+	rpcRequestMeter.Mark(1)
+	var reply []reflect.Value
+	if timeout > 0 {
+		var err Error
+		if reply, err = s.callWithTimeout(ctx, method, timeout, req.callb, arguments); err != nil {
+			rpcServingTimer.UpdateSince(start)
+			rpcFailureMeter.Mark(1)
+			if _, ok := err.(*timeoutError); ok {
+				rpcTimeoutMeter.Mark(1)
+			}
+			span.SetError(err)
+			return codec.CreateErrorResponse(&req.id, err), nil
+		}
+	} else {
+		reply = req.callb.method.Func.Call(arguments)
+	}
+	rpcServingTimer.UpdateSince(start)
 	if len(reply) == 0 {
+		rpcSuccessMeter.Mark(1)
 		return codec.CreateResponse(req.id, nil), nil
 	}
 
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
+			rpcFailureMeter.Mark(1)
 			e := reply[req.callb.errPos].Interface().(error)
+			span.SetError(e)
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
 	}
+	rpcSuccessMeter.Mark(1)
+	if cacheKey != "" {
+		s.cache.put(cacheKey, method, reply[0].Interface())
+	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
@@ -348,15 +568,45 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
+	var (
+		size     int  // of the responses so far, if limited
+		exceeded bool // whether the response size limit was hit
+	)
 	for i, req := range requests {
+		if exceeded {
+			responses[i] = codec.CreateErrorResponse(&req.id, &responseTooLargeError{s.batchResponseLimit})
+			continue
+		}
+		// Check that a subscription id still fits before creating the
+		// subscription, as it could not be dropped afterwards
+		if s.batchResponseLimit > 0 && req.err == nil && req.callb != nil && req.callb.isSubscribe {
+			if enc, err := json.Marshal(codec.CreateResponse(req.id, maxSubscriptionID)); err == nil && size+len(enc) > s.batchResponseLimit {
+				responses[i] = codec.CreateErrorResponse(&req.id, &responseTooLargeError{s.batchResponseLimit})
+				exceeded = true
+				continue
+			}
+		}
+		var callback func()
 		if req.err != nil {
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
 		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
+			responses[i], callback = s.handle(ctx, codec, req)
+		}
+		// Encode limited responses once, keeping the encoding for the batch
+		if s.batchResponseLimit > 0 {
+			if enc, err := json.Marshal(responses[i]); err == nil {
+				if size+len(enc) > s.batchResponseLimit {
+					responses[i] = codec.CreateErrorResponse(&req.id, &responseTooLargeError{s.batchResponseLimit})
+					exceeded, callback = true, nil
+				} else {
+					responses[i] = json.RawMessage(enc)
+					size += len(enc)
+				}
 			}
 		}
+		if callback != nil {
+			callbacks = append(callbacks, callback)
+		}
 	}
 
 	if err := codec.Write(responses); err != nil {
@@ -439,6 +689,11 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 	}
+	for i, r := range reqs {
+		if r.method != "" {
+			requests[i].method = r.service + serviceMethodSeparator + r.method
+		}
+	}
 
 	return requests, batch, nil
 }
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"net"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -160,3 +161,160 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+func TestServerBatchLimits(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	// Each echo response is about 70 bytes, the second one would cross the limit
+	server.SetBatchLimits(3, 100)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	var (
+		in   = json.NewDecoder(clientConn)
+		out  = json.NewEncoder(clientConn)
+		call = func(id int) map[string]interface{} {
+			return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": "test_echo", "params": []interface{}{"hello", id, nil}}
+		}
+	)
+	// Batches with too many calls are rejected as a whole, without closing
+	// the connection
+	out.Encode([]interface{}{call(1), call(2), call(3), call(4)})
+	var rejected jsonErrResponse
+	if err := in.Decode(&rejected); err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Error.Code != -32600 {
+		t.Errorf("oversized batch: have error %+v, want code -32600", rejected.Error)
+	}
+	// Calls beyond the response size limit fail
+	out.Encode([]interface{}{call(1), call(2), call(3)})
+	var responses []map[string]interface{}
+	if err := in.Decode(&responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("response count mismatch: have %d, want 3", len(responses))
+	}
+	for i, resp := range responses {
+		_, failed := resp["error"]
+		if want := i > 0; failed != want {
+			t.Errorf("response %d: have error %v, want %t", i, resp["error"], want)
+		}
+	}
+	if code := responses[1]["error"].(map[string]interface{})["code"]; code != float64(-32003) {
+		t.Errorf("oversized response: have error code %v, want -32003", code)
+	}
+}
+
+type CountingSubService struct {
+	created int32
+}
+
+func (s *CountingSubService) Count(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	atomic.AddInt32(&s.created, 1)
+	return notifier.CreateSubscription(), nil
+}
+
+// Tests that subscriptions whose id does not fit in the batch response anymore
+// are not created, as the client would never learn to unsubscribe them.
+func TestServerBatchLimitsSubscription(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	service := new(CountingSubService)
+	if err := server.RegisterName("sub", service); err != nil {
+		t.Fatal(err)
+	}
+	server.SetBatchLimits(3, 100)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation|OptionSubscriptions)
+
+	out := json.NewEncoder(clientConn)
+	out.Encode([]interface{}{
+		map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "test_echo", "params": []interface{}{"hello", 1, nil}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "sub_subscribe", "params": []interface{}{"count"}},
+	})
+	var responses []map[string]interface{}
+	if err := json.NewDecoder(clientConn).Decode(&responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("response count mismatch: have %d, want 2", len(responses))
+	}
+	if _, failed := responses[1]["error"]; !failed {
+		t.Errorf("oversized subscription response: have %v, want error", responses[1])
+	}
+	if created := atomic.LoadInt32(&service.created); created != 0 {
+		t.Errorf("dropped subscriptions created: have %d, want 0", created)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	responses := make(chan jsonSuccessResponse, 1)
+	go func() {
+		var resp jsonSuccessResponse
+		if err := json.NewDecoder(clientConn).Decode(&resp); err == nil {
+			responses <- resp
+		}
+		close(responses)
+	}()
+	sleep := 200 * time.Millisecond
+	json.NewEncoder(clientConn).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "test_sleep", "params": []interface{}{sleep}})
+	time.Sleep(50 * time.Millisecond)
+
+	// The pending call finishes before the connection is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if _, ok := <-responses; !ok {
+		t.Fatal("no response to the pending call")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	json.NewEncoder(clientConn).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "test_sleep", "params": []interface{}{time.Second}})
+	time.Sleep(50 * time.Millisecond)
+
+	// Calls still running at the deadline are abandoned
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("have shutdown error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("shutdown took %v, beyond its deadline", elapsed)
+	}
+}
@@ -27,8 +27,15 @@ var (
 	ErrNotificationsUnsupported = errors.New("notifications not supported")
 	// ErrNotificationNotFound is returned when the notification for the given id is not found
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionQueueOverflow is returned when a subscription is dropped
+	// because the client doesn't keep up with its notifications
+	ErrSubscriptionQueueOverflow = errors.New("subscription queue overflow")
 )
 
+// defaultNotificationQueue is the default number of notifications of a
+// subscription waiting to be sent before the subscription is dropped.
+const defaultNotificationQueue = 10000
+
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
 type ID string
 
@@ -38,6 +45,36 @@ type Subscription struct {
 	ID        ID
 	namespace string
 	err       chan error // closed on unsubscribe
+
+	queueMu  sync.Mutex    // guards queue
+	queue    []interface{} // notifications waiting to be sent
+	wake     chan struct{} // signals the sender about queued notifications
+	overflow bool          // set before err is closed if the queue overflowed
+}
+
+// enqueue adds a notification to the queue of the subscription, reporting
+// false if the queue is full.
+func (s *Subscription) enqueue(data interface{}, limit int) bool {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if len(s.queue) >= limit {
+		return false
+	}
+	s.queue = append(s.queue, data)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// dequeue takes all notifications waiting to be sent.
+func (s *Subscription) dequeue() []interface{} {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	queue := s.queue
+	s.queue = nil
+	return queue
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -51,19 +88,22 @@ type notifierKey struct{}
 // Notifier is tight to a RPC connection that supports subscriptions.
 // Server callbacks use the notifier to send notifications.
 type Notifier struct {
-	codec    ServerCodec
-	subMu    sync.RWMutex // guards active and inactive maps
-	active   map[ID]*Subscription
-	inactive map[ID]*Subscription
+	codec     ServerCodec
+	queueSize int          // maximum number of notifications waiting per subscription
+	subMu     sync.RWMutex // guards active and inactive maps
+	active    map[ID]*Subscription
+	inactive  map[ID]*Subscription
 }
 
 // newNotifier creates a new notifier that can be used to send subscription
-// notifications to the client.
-func newNotifier(codec ServerCodec) *Notifier {
+// notifications to the client, queueing up to queueSize notifications for
+// each subscription.
+func newNotifier(codec ServerCodec, queueSize int) *Notifier {
 	return &Notifier{
-		codec:    codec,
-		active:   make(map[ID]*Subscription),
-		inactive: make(map[ID]*Subscription),
+		codec:     codec,
+		queueSize: queueSize,
+		active:    make(map[ID]*Subscription),
+		inactive:  make(map[ID]*Subscription),
 	}
 }
 
@@ -78,28 +118,58 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 // are dropped until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
 func (n *Notifier) CreateSubscription() *Subscription {
-	s := &Subscription{ID: NewID(), err: make(chan error)}
+	s := &Subscription{ID: NewID(), err: make(chan error), wake: make(chan struct{}, 1)}
 	n.subMu.Lock()
 	n.inactive[s.ID] = s
 	n.subMu.Unlock()
 	return s
 }
 
-// Notify sends a notification to the client with the given data as payload.
-// If an error occurs the RPC connection is closed and the error is returned.
+// Notify queues a notification to the client with the given data as payload.
+// If the client doesn't keep up with the notifications of the subscription and
+// its queue is full, the subscription is dropped, the client sent an error
+// notification and ErrSubscriptionQueueOverflow returned.
 func (n *Notifier) Notify(id ID, data interface{}) error {
 	n.subMu.RLock()
-	defer n.subMu.RUnlock()
-
 	sub, active := n.active[id]
-	if active {
-		notification := n.codec.CreateNotification(string(id), sub.namespace, data)
-		if err := n.codec.Write(notification); err != nil {
-			n.codec.Close()
-			return err
+	n.subMu.RUnlock()
+
+	if !active || sub.enqueue(data, n.queueSize) {
+		return nil
+	}
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+	if _, active := n.active[id]; active {
+		sub.overflow = true
+		close(sub.err)
+		delete(n.active, id)
+	}
+	return ErrSubscriptionQueueOverflow
+}
+
+// send writes the queued notifications of a subscription to the client until
+// it is unsubscribed or the connection is closed. If an error occurs the RPC
+// connection is closed.
+func (n *Notifier) send(sub *Subscription) {
+	for {
+		select {
+		case <-sub.wake:
+			for _, data := range sub.dequeue() {
+				notification := n.codec.CreateNotification(string(sub.ID), sub.namespace, data)
+				if err := n.codec.Write(notification); err != nil {
+					n.codec.Close()
+					return
+				}
+			}
+		case <-sub.err:
+			if sub.overflow {
+				n.codec.Write(n.codec.CreateErrorNotification(string(sub.ID), sub.namespace, &subscriptionOverflowError{}))
+			}
+			return
+		case <-n.codec.Closed():
+			return
 		}
 	}
-	return nil
 }
 
 // Closed returns a channel that is closed when the RPC connection is closed.
@@ -131,5 +201,6 @@ func (n *Notifier) activate(id ID, namespace string) {
 		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
+		go n.send(sub)
 	}
 }
@@ -214,7 +214,7 @@ func waitForMessages(t *testing.T, in *json.Decoder, successes chan<- jsonSucces
 				notifications <- jsonNotification{
 					Version: msg["jsonrpc"].(string),
 					Method:  msg["method"].(string),
-					Params:  jsonSubscription{params["subscription"].(string), params["result"]},
+					Params:  jsonSubscription{Subscription: params["subscription"].(string), Result: params["result"]},
 				}
 				continue
 			}
@@ -328,3 +328,69 @@ func TestSubscriptionMultipleNamespaces(t *testing.T) {
 		}
 	}
 }
+
+// Tests that subscriptions of clients not reading their notifications are
+// dropped once their queue is full, sending the client an error notification.
+func TestNotificationQueueOverflow(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	codec := NewJSONCodec(serverConn)
+	defer codec.Close()
+	notifier := newNotifier(codec, 2)
+	sub := notifier.CreateSubscription()
+	notifier.activate(sub.ID, "aqua")
+
+	// Nothing is read from the connection, so the notifications pile up.
+	var sent int
+	for ; sent < 10; sent++ {
+		if err := notifier.Notify(sub.ID, sent); err != nil {
+			if err != ErrSubscriptionQueueOverflow {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			break
+		}
+	}
+	if sent == 10 {
+		t.Fatal("subscription not dropped on full queue")
+	}
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatal("subscription error channel not closed")
+	}
+	if err := notifier.Notify(sub.ID, sent); err != nil {
+		t.Fatalf("notify after drop: %v", err)
+	}
+
+	// The queued notifications are followed by the error notification.
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	in := json.NewDecoder(clientConn)
+	for i := 0; ; i++ {
+		var msg struct {
+			Params struct {
+				Subscription string
+				Result       *int
+				Error        *jsonError
+			}
+		}
+		if err := in.Decode(&msg); err != nil {
+			t.Fatalf("failed to read notification %d: %v", i, err)
+		}
+		if msg.Params.Subscription != string(sub.ID) {
+			t.Fatalf("notification %d: subscription mismatch: have %s, want %s", i, msg.Params.Subscription, sub.ID)
+		}
+		if msg.Params.Error != nil {
+			if msg.Params.Error.Code != -32006 {
+				t.Errorf("error code mismatch: have %d, want %d", msg.Params.Error.Code, -32006)
+			}
+			if i > sent {
+				t.Errorf("too many notifications before the error: have %d, want <= %d", i, sent)
+			}
+			break
+		}
+		if msg.Params.Result == nil || *msg.Params.Result != i {
+			t.Fatalf("notification %d: unexpected result %v", i, msg.Params.Result)
+		}
+	}
+}
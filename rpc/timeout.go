@@ -0,0 +1,89 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// maxAbandonedCalls is the number of timed out calls which may still be running,
+// having ignored the cancellation, before the server refuses further calls of
+// methods with a timeout.
+const maxAbandonedCalls = 64
+
+// Timeouts configures how long RPC calls may execute before their context is
+// cancelled and the client receives a timeout error. Calls which ignore the
+// cancellation are abandoned, their results discarded once they return, and
+// up to maxAbandonedCalls of them may be running at once. Subscriptions are
+// not subject to timeouts.
+type Timeouts struct {
+	// Default limits the execution time of all methods not listed in
+	// Methods. Zero leaves them unlimited.
+	Default time.Duration
+
+	// Methods limits single methods, like "debug_traceTransaction". Zero
+	// leaves a method unlimited, even if there is a default timeout.
+	Methods map[string]time.Duration
+}
+
+// timeout returns the execution time allowed for calls of the given method,
+// zero if unlimited.
+func (t *Timeouts) timeout(method string) time.Duration {
+	if timeout, ok := t.Methods[method]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// callWithTimeout invokes the method of a regular RPC call, returning a
+// timeout error if it does not return before the deadline of ctx. The call
+// keeps running on its own goroutine in that case, or if ctx is cancelled
+// otherwise, like when the client goes away.
+func (s *Server) callWithTimeout(ctx context.Context, method string, timeout time.Duration, callb *callback, arguments []reflect.Value) ([]reflect.Value, Error) {
+	if atomic.LoadInt32(&s.abandoned) >= maxAbandonedCalls {
+		return nil, &busyError{method}
+	}
+	var (
+		done  = make(chan []reflect.Value, 1)
+		state int32 // 0 while running, 1 once returned, 2 once abandoned
+	)
+	go func() {
+		done <- callb.method.Func.Call(arguments)
+		if !atomic.CompareAndSwapInt32(&state, 0, 1) {
+			atomic.AddInt32(&s.abandoned, -1)
+		}
+	}()
+	select {
+	case reply := <-done:
+		// Methods observing the cancellation return the context's error
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &timeoutError{method, timeout}
+		}
+		return reply, nil
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&state, 0, 2) {
+			atomic.AddInt32(&s.abandoned, 1)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &timeoutError{method, timeout}
+		}
+		return nil, &callbackError{ctx.Err().Error()}
+	}
+}
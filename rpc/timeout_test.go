@@ -0,0 +1,160 @@
+// Copyright 2018 The aquachain Authors
+// This file is part of the aquachain library.
+//
+// The aquachain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aquachain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aquachain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type TimeoutTestService struct {
+	release chan struct{}
+}
+
+// Block ignores the cancellation of its context.
+func (s *TimeoutTestService) Block() bool {
+	<-s.release
+	return true
+}
+
+func TestTimeouts(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	service := &TimeoutTestService{release: make(chan struct{})}
+	defer close(service.release)
+	if err := server.RegisterName("timeout", service); err != nil {
+		t.Fatal(err)
+	}
+	server.SetTimeouts(Timeouts{
+		Default: 50 * time.Millisecond,
+		Methods: map[string]time.Duration{"test_sleep": 100 * time.Millisecond},
+	})
+
+	call := func(method, params string) *jsonError {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + params + `}`
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		var result struct{ Error *jsonError }
+		if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response %q: %v", response.Body.String(), err)
+		}
+		return result.Error
+	}
+	tests := []struct {
+		method, params string
+		timeout        bool
+	}{
+		{"test_sleep", "[1000000]", false},    // 1ms, within its own timeout
+		{"test_sleep", "[80000000]", false},   // 80ms, over the default timeout
+		{"test_sleep", "[10000000000]", true}, // 10s, cancelled
+		{"timeout_block", "[]", true},         // abandoned
+	}
+	for i, tt := range tests {
+		start := time.Now()
+		err := call(tt.method, tt.params)
+		switch {
+		case tt.timeout && (err == nil || err.Code != -32002):
+			t.Errorf("call %d: want timeout error, got %v", i, err)
+		case !tt.timeout && err != nil:
+			t.Errorf("call %d: unexpected error: %v", i, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("call %d: took %v", i, elapsed)
+		}
+	}
+}
+
+// Tests that calls are refused while too many abandoned ones are still running,
+// until they return.
+func TestTimeoutsAbandonedLimit(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	service := &TimeoutTestService{release: make(chan struct{})}
+	if err := server.RegisterName("timeout", service); err != nil {
+		t.Fatal(err)
+	}
+	server.SetTimeouts(Timeouts{Default: 20 * time.Millisecond})
+
+	call := func() *jsonError {
+		body := `{"jsonrpc":"2.0","id":1,"method":"timeout_block","params":[]}`
+		request := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		request.Header.Set("content-type", contentType)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		var result struct{ Error *jsonError }
+		if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response %q: %v", response.Body.String(), err)
+		}
+		return result.Error
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < maxAbandonedCalls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call()
+		}()
+	}
+	wg.Wait()
+	if err := call(); err == nil || err.Code != -32004 {
+		t.Errorf("want busy error, got %v", err)
+	}
+	// Abandoned calls returning make room again
+	close(service.release)
+	for start := time.Now(); atomic.LoadInt32(&server.abandoned) > 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("abandoned calls not accounted as returned: %d", atomic.LoadInt32(&server.abandoned))
+		}
+	}
+	if err := call(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Tests that calls cancelled before their deadline do not report a timeout.
+func TestTimeoutsCancelled(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	service := &TimeoutTestService{release: make(chan struct{})}
+	defer close(service.release)
+	if err := server.RegisterName("timeout", service); err != nil {
+		t.Fatal(err)
+	}
+	callb := server.services["timeout"].callbacks["block"]
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	cancel()
+	_, err := server.callWithTimeout(ctx, "timeout_block", time.Minute, callb, []reflect.Value{callb.rcvr})
+	if _, ok := err.(*timeoutError); ok || err == nil {
+		t.Errorf("want cancellation error, got %v", err)
+	}
+}
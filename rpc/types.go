@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"crypto/tls"
 	"fmt"
 	"math"
 	"reflect"
@@ -25,6 +26,7 @@ import (
 
 	set "github.com/deckarep/golang-set"
 	"gitlab.com/aquachain/aquachain/common/hexutil"
+	"gitlab.com/aquachain/aquachain/p2p/netutil"
 )
 
 // API describes the set of methods offered over the RPC interface
@@ -56,6 +58,7 @@ type service struct {
 // serverRequest is an incoming request
 type serverRequest struct {
 	id            interface{}
+	method        string // method name as requested, for the access log
 	svcname       string
 	callb         *callback
 	args          []reflect.Value
@@ -74,8 +77,24 @@ type Server struct {
 	run          int32
 	codecsMu     sync.Mutex
 	codecs       set.Set
-	reverseproxy bool // if true, check X-FORWARDED-FOR header
-
+	reverseproxy bool         // if true, check X-FORWARDED-FOR header
+	maxBodySize  int64        // limit of HTTP request bodies and websocket messages, 0 for the default
+	limiter      *rateLimiter // nil if calls are not rate limited
+	jwtSecret    []byte       // if set, HTTP and websocket requests need a token signed with it
+	tlsConfig    *tls.Config  // if set, HTTP and websocket servers use TLS
+	accessLog    *AccessLog   // if set, HTTP requests are logged to it
+
+	trustedProxies *netutil.Netlist // if set, only these peers may forward client IPs
+	healthCheck    HealthCheck      // if set, served on the /health and /ready paths
+	notifyQueue    int              // pending notifications per subscription, 0 for the default
+	cache          *ResponseCache   // if set, results of cached methods are served from it
+	timeouts       Timeouts         // execution time allowed for regular calls
+	abandoned      int32            // timed out calls still running, accessed atomically
+
+	batchItemLimit     int // maximum number of calls in a batch, 0 for unlimited
+	batchResponseLimit int // maximum size of a batch response in bytes, 0 for unlimited
+
+	pending sync.WaitGroup // calls being executed, added to with codecsMu held
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -110,6 +129,8 @@ type ServerCodec interface {
 	CreateErrorResponseWithInfo(id interface{}, err Error, info interface{}) interface{}
 	// Create notification response
 	CreateNotification(id, namespace string, event interface{}) interface{}
+	// Create notification ending a subscription with an error
+	CreateErrorNotification(id, namespace string, err Error) interface{}
 	// Write msg to client.
 	Write(msg interface{}) error
 	// Close underlying data stream
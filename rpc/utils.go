@@ -35,6 +35,9 @@ import (
 var (
 	subscriptionIDGenMu sync.Mutex
 	subscriptionIDGen   = idGenerator()
+
+	// maxSubscriptionID is as long as the longest identifier NewID generates.
+	maxSubscriptionID = ID("0x" + strings.Repeat("f", 32))
 )
 
 // Is this an exported - upper case - name?
@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -27,7 +28,6 @@ import (
 
 	"golang.org/x/net/websocket"
 
-	set "github.com/deckarep/golang-set"
 	"gitlab.com/aquachain/aquachain/common/log"
 	"gitlab.com/aquachain/aquachain/p2p/netutil"
 )
@@ -55,11 +55,11 @@ var websocketJSONCodec = websocket.Codec{
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string, allowedIP []string, reverseproxy bool) http.Handler {
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins, allowedIP, reverseproxy),
+		Handshake: wsHandshakeValidator(allowedOrigins, allowedIP, reverseproxy, srv.trustedProxies),
 		Handler: func(conn *websocket.Conn) {
 
 			// Create a custom encode/decode pair to enforce payload size and number encoding
-			conn.MaxPayloadBytes = maxHTTPRequestContentLength
+			conn.MaxPayloadBytes = int(srv.bodyLimit())
 
 			encoder := func(v interface{}) error {
 				return websocketJSONCodec.Send(conn, v)
@@ -67,7 +67,8 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string, allowedIP []string,
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			ctx := withClientIP(context.Background(), getIP(conn.Request(), reverseproxy, srv.trustedProxies))
+			srv.serveCodec(ctx, NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
@@ -76,14 +77,17 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string, allowedIP []string,
 //
 // Deprecated: use Server.WebsocketHandler
 func NewWSServer(allowedOrigins []string, allowedIP []string, reverseproxy bool, srv *Server) *http.Server {
-	return &http.Server{Handler: srv.WebsocketHandler(allowedOrigins, allowedIP, reverseproxy)}
+	handler := srv.WebsocketHandler(allowedOrigins, allowedIP, reverseproxy)
+	if srv.jwtSecret != nil {
+		handler = newJWTHandler(srv.jwtSecret, nil, handler)
+	}
+	return &http.Server{Handler: handler, TLSConfig: srv.tlsConfig}
 }
 
 // wsHandshakeValidator returns a handler that verifies the origin during the
 // websocket upgrade process. When a '*' is specified as an allowed origins all
-// connections are accepted.
-func wsHandshakeValidator(allowedOrigins, allowedIP []string, reverseProxy bool) func(*websocket.Config, *http.Request) error {
-	origins := set.NewSet()
+// connections are accepted, see originList for the other patterns.
+func wsHandshakeValidator(allowedOrigins, allowedIP []string, reverseProxy bool, trustedProxies *netutil.Netlist) func(*websocket.Config, *http.Request) error {
 	allowIPset := make(netutil.Netlist, 0)
 	ws := strings.NewReplacer(" ", "", "\n", "", "\t", "")
 	for _, mask := range allowedIP {
@@ -102,30 +106,23 @@ func wsHandshakeValidator(allowedOrigins, allowedIP []string, reverseProxy bool)
 		}
 		allowIPset = append(allowIPset, *n)
 	}
-	allowAllOrigins := false
-	for _, origin := range allowedOrigins {
-		if origin == "*" {
-			allowAllOrigins = true
-		}
-		if origin != "" {
-			origins.Add(strings.ToLower(origin))
-		}
-	}
+	origins := newOriginList(allowedOrigins)
 
 	// allow localhost if no allowedOrigins are specified.
-	if len(origins.ToSlice()) == 0 {
-		origins.Add("http://localhost")
+	if origins.empty() {
+		defaults := []string{"http://localhost"}
 		if hostname, err := os.Hostname(); err == nil {
-			origins.Add("http://" + strings.ToLower(hostname))
+			defaults = append(defaults, "http://"+hostname)
 		}
+		origins = newOriginList(defaults)
 	}
 
-	log.Debug(fmt.Sprintf("Allowed origin(s) for WS RPC interface %v\n", origins.ToSlice()))
+	log.Debug(fmt.Sprintf("Allowed origin(s) for WS RPC interface %v\n", origins))
 	log.Debug(fmt.Sprintf("Allowed IP(s) for WS RPC interface %s\n", allowIPset.String()))
 
 	f := func(cfg *websocket.Config, req *http.Request) error {
 		checkip := func(r *http.Request, reverseProxy bool) error {
-			ip := getIP(r, reverseProxy)
+			ip := getIP(r, reverseProxy, trustedProxies)
 			if allowIPset.Contains(ip) {
 				return nil
 			}
@@ -140,7 +137,7 @@ func wsHandshakeValidator(allowedOrigins, allowedIP []string, reverseProxy bool)
 
 		// check origin header
 		origin := strings.ToLower(req.Header.Get("Origin"))
-		if allowAllOrigins || origins.Contains(origin) {
+		if origins.allowed(origin) {
 			return nil
 		}
 		log.Warn(fmt.Sprintf("origin '%s' not allowed on WS-RPC interface\n", origin))